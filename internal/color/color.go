@@ -0,0 +1,272 @@
+// Package color converts between the color spaces used by the dashboard
+// (sRGB, hex, HSV, Kelvin) and the CIE xy / mired values used by Philips Hue.
+package color
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Mired limits supported by Hue white ambiance lights
+const (
+	MinMired = 153 // ~6500K
+	MaxMired = 500 // 2000K
+)
+
+// RGB represents an 8-bit sRGB color
+type RGB struct {
+	R uint8 `json:"r"`
+	G uint8 `json:"g"`
+	B uint8 `json:"b"`
+}
+
+// XY represents a CIE 1931 chromaticity coordinate
+type XY struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// WhitePoint is the D65 white point, used when a color has no chromaticity
+var WhitePoint = XY{X: 0.3127, Y: 0.3290}
+
+// ParseHex parses "#rrggbb", "rrggbb" or the short "#rgb" form
+func ParseHex(hex string) (RGB, error) {
+	value := strings.TrimPrefix(strings.TrimSpace(hex), "#")
+	if len(value) == 3 {
+		value = string([]byte{value[0], value[0], value[1], value[1], value[2], value[2]})
+	}
+	if len(value) != 6 {
+		return RGB{}, fmt.Errorf("invalid hex color %q", hex)
+	}
+
+	parsed, err := strconv.ParseUint(value, 16, 32)
+	if err != nil {
+		return RGB{}, fmt.Errorf("invalid hex color %q", hex)
+	}
+	return RGB{R: uint8(parsed >> 16), G: uint8(parsed >> 8), B: uint8(parsed)}, nil
+}
+
+// Hex formats the color as a lowercase #rrggbb string
+func (c RGB) Hex() string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// Distance returns the euclidean distance between two colors in RGB space
+func (c RGB) Distance(other RGB) float64 {
+	dr := float64(c.R) - float64(other.R)
+	dg := float64(c.G) - float64(other.G)
+	db := float64(c.B) - float64(other.B)
+	return math.Sqrt(dr*dr + dg*dg + db*db)
+}
+
+// ToXY converts the color to CIE xy using the Hue wide gamut conversion.
+// The returned brightness is the relative luminance (0-1).
+func (c RGB) ToXY() (XY, float64) {
+	r := linearize(float64(c.R) / 255)
+	g := linearize(float64(c.G) / 255)
+	b := linearize(float64(c.B) / 255)
+
+	X := r*0.664511 + g*0.154324 + b*0.162028
+	Y := r*0.283881 + g*0.668433 + b*0.047685
+	Z := r*0.000088 + g*0.072310 + b*0.986039
+
+	sum := X + Y + Z
+	if sum == 0 {
+		return WhitePoint, 0
+	}
+	return XY{X: X / sum, Y: Y / sum}, Y
+}
+
+// FromXY converts a CIE xy coordinate at the given brightness (0-1) back to sRGB.
+// The result is scaled so its brightest channel is not clipped.
+func FromXY(xy XY, brightness float64) RGB {
+	if xy.Y == 0 {
+		return RGB{}
+	}
+
+	Y := brightness
+	X := (Y / xy.Y) * xy.X
+	Z := (Y / xy.Y) * (1 - xy.X - xy.Y)
+
+	r := X*1.656492 - Y*0.354851 - Z*0.255038
+	g := -X*0.707196 + Y*1.655397 + Z*0.036152
+	b := X*0.051713 - Y*0.121364 + Z*1.011530
+
+	r, g, b = delinearize(r), delinearize(g), delinearize(b)
+
+	if maxC := math.Max(r, math.Max(g, b)); maxC > 1 {
+		r, g, b = r/maxC, g/maxC, b/maxC
+	}
+	return RGB{R: toByte(r), G: toByte(g), B: toByte(b)}
+}
+
+// FromHSV converts hue (degrees), saturation and value (0-1) to sRGB
+func FromHSV(h, s, v float64) RGB {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	s = clamp01(s)
+	v = clamp01(v)
+
+	chroma := v * s
+	x := chroma * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := v - chroma
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = chroma, x, 0
+	case h < 120:
+		r, g, b = x, chroma, 0
+	case h < 180:
+		r, g, b = 0, chroma, x
+	case h < 240:
+		r, g, b = 0, x, chroma
+	case h < 300:
+		r, g, b = x, 0, chroma
+	default:
+		r, g, b = chroma, 0, x
+	}
+	return RGB{R: toByte(r + m), G: toByte(g + m), B: toByte(b + m)}
+}
+
+// ToHSV converts the color to hue (degrees), saturation and value (0-1)
+func (c RGB) ToHSV() (float64, float64, float64) {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	maxC := math.Max(r, math.Max(g, b))
+	minC := math.Min(r, math.Min(g, b))
+	delta := maxC - minC
+
+	var h float64
+	switch {
+	case delta == 0:
+		h = 0
+	case maxC == r:
+		h = 60 * math.Mod((g-b)/delta, 6)
+	case maxC == g:
+		h = 60 * ((b-r)/delta + 2)
+	default:
+		h = 60 * ((r-g)/delta + 4)
+	}
+	if h < 0 {
+		h += 360
+	}
+
+	var s float64
+	if maxC > 0 {
+		s = delta / maxC
+	}
+	return h, s, maxC
+}
+
+// FromHueSat converts native Hue hue (0-65535), sat (0-254) and bri (0-254) to sRGB
+func FromHueSat(hue, sat, bri int) RGB {
+	return FromHSV(float64(hue)*360/65536, float64(sat)/254, float64(bri)/254)
+}
+
+// ToHueSat converts the color to native Hue hue (0-65535), sat (0-254) and bri (0-254)
+func (c RGB) ToHueSat() (int, int, int) {
+	h, s, v := c.ToHSV()
+	return int(math.Round(h*65536/360)) % 65536, int(math.Round(s * 254)), int(math.Round(v * 254))
+}
+
+// KelvinToMired converts a color temperature in Kelvin to mireds
+func KelvinToMired(kelvin int) int {
+	if kelvin <= 0 {
+		return 0
+	}
+	return int(math.Round(1e6 / float64(kelvin)))
+}
+
+// MiredToKelvin converts a color temperature in mireds to Kelvin
+func MiredToKelvin(mired int) int {
+	if mired <= 0 {
+		return 0
+	}
+	return int(math.Round(1e6 / float64(mired)))
+}
+
+// ClampMired limits a mired value to the range supported by Hue lights
+func ClampMired(mired int) int {
+	if mired < MinMired {
+		return MinMired
+	}
+	if mired > MaxMired {
+		return MaxMired
+	}
+	return mired
+}
+
+// KelvinToXY returns the chromaticity of a black body at the given temperature
+// (Kim et al. cubic spline approximation, valid from 1667K to 25000K).
+func KelvinToXY(kelvin int) XY {
+	t := math.Max(1667, math.Min(25000, float64(kelvin)))
+
+	var x float64
+	if t <= 4000 {
+		x = -0.2661239e9/(t*t*t) - 0.2343589e6/(t*t) + 0.8776956e3/t + 0.179910
+	} else {
+		x = -3.0258469e9/(t*t*t) + 2.1070379e6/(t*t) + 0.2226347e3/t + 0.240390
+	}
+
+	var y float64
+	switch {
+	case t <= 2222:
+		y = -1.1063814*x*x*x - 1.34811020*x*x + 2.18555832*x - 0.20219683
+	case t <= 4000:
+		y = -0.9549476*x*x*x - 1.37418593*x*x + 2.09137015*x - 0.16748867
+	default:
+		y = 3.0817580*x*x*x - 5.87338670*x*x + 3.75112997*x - 0.37001483
+	}
+	return XY{X: x, Y: y}
+}
+
+// KelvinToRGB approximates the sRGB color of a white light at the given temperature
+func KelvinToRGB(kelvin int) RGB {
+	t := math.Max(1000, math.Min(40000, float64(kelvin))) / 100
+
+	var r, g, b float64
+	if t <= 66 {
+		r = 255
+		g = 99.4708025861*math.Log(t) - 161.1195681661
+		if t <= 19 {
+			b = 0
+		} else {
+			b = 138.5177312231*math.Log(t-10) - 305.0447927307
+		}
+	} else {
+		r = 329.698727446 * math.Pow(t-60, -0.1332047592)
+		g = 288.1221695283 * math.Pow(t-60, -0.0755148492)
+		b = 255
+	}
+	return RGB{R: toByte(r / 255), G: toByte(g / 255), B: toByte(b / 255)}
+}
+
+// linearize converts a gamma encoded sRGB channel to linear light
+func linearize(v float64) float64 {
+	if v > 0.04045 {
+		return math.Pow((v+0.055)/1.055, 2.4)
+	}
+	return v / 12.92
+}
+
+// delinearize converts a linear light channel to gamma encoded sRGB
+func delinearize(v float64) float64 {
+	if v <= 0.0031308 {
+		return 12.92 * v
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// toByte converts a 0-1 channel value to 0-255
+func toByte(v float64) uint8 {
+	return uint8(math.Round(clamp01(v) * 255))
+}
+
+// clamp01 limits a value to the 0-1 range
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
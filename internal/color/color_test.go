@@ -0,0 +1,104 @@
+package color
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseHex(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    RGB
+		wantErr bool
+	}{
+		{"#ff8800", RGB{255, 136, 0}, false},
+		{"FF8800", RGB{255, 136, 0}, false},
+		{"#f80", RGB{255, 136, 0}, false},
+		{"#ff88", RGB{}, true},
+		{"#gg0000", RGB{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseHex(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseHex(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Fatalf("ParseHex(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+
+	if hex := (RGB{255, 136, 0}).Hex(); hex != "#ff8800" {
+		t.Fatalf("Expected #ff8800, got %s", hex)
+	}
+}
+
+func TestMiredKelvin(t *testing.T) {
+	if mired := KelvinToMired(2700); mired != 370 {
+		t.Fatalf("Expected 370 mireds for 2700K, got %d", mired)
+	}
+	if kelvin := MiredToKelvin(370); kelvin != 2703 {
+		t.Fatalf("Expected 2703K for 370 mireds, got %d", kelvin)
+	}
+	if mired := ClampMired(KelvinToMired(10000)); mired != MinMired {
+		t.Fatalf("Expected 10000K to clamp to %d, got %d", MinMired, mired)
+	}
+	if mired := ClampMired(KelvinToMired(1000)); mired != MaxMired {
+		t.Fatalf("Expected 1000K to clamp to %d, got %d", MaxMired, mired)
+	}
+}
+
+func TestXYRoundTrip(t *testing.T) {
+	for _, c := range []RGB{{255, 0, 0}, {0, 255, 0}, {0, 0, 255}, {255, 136, 0}} {
+		xy, _ := c.ToXY()
+		back := FromXY(xy, 1)
+		// FromXY normalizes to full brightness, so the dominant channel maxes out
+		if back.Distance(c) > 8 {
+			t.Fatalf("Round trip of %s through %v gave %s", c.Hex(), xy, back.Hex())
+		}
+	}
+
+	xy := KelvinToXY(6500)
+	if math.Abs(xy.X-WhitePoint.X) > 0.01 || math.Abs(xy.Y-WhitePoint.Y) > 0.01 {
+		t.Fatalf("Expected 6500K near the D65 white point, got %v", xy)
+	}
+}
+
+func TestGamutClamp(t *testing.T) {
+	inside := XY{X: 0.4, Y: 0.4}
+	if got := GamutB.Clamp(inside); got != inside {
+		t.Fatalf("Expected %v inside gamut B to be unchanged, got %v", inside, got)
+	}
+
+	// Pure sRGB green lies outside gamut B
+	green, _ := RGB{0, 255, 0}.ToXY()
+	if GamutB.Contains(green) {
+		t.Fatalf("Expected %v to be outside gamut B", green)
+	}
+	clamped := GamutB.Clamp(green)
+	// Nudge towards the centroid to allow for floating point error on the edge
+	// (D65 itself sits just outside gamut B)
+	centroid := XY{X: (GamutB[0].X + GamutB[1].X + GamutB[2].X) / 3, Y: (GamutB[0].Y + GamutB[1].Y + GamutB[2].Y) / 3}
+	nudged := XY{X: clamped.X + (centroid.X-clamped.X)*1e-6, Y: clamped.Y + (centroid.Y-clamped.Y)*1e-6}
+	if !GamutB.Contains(nudged) {
+		t.Fatalf("Clamped point %v is not on gamut B", clamped)
+	}
+	if distance(clamped, green) > distance(GamutB[1], green) {
+		t.Fatalf("Clamped point %v is further than the green corner", clamped)
+	}
+}
+
+func TestGamutForModel(t *testing.T) {
+	if gamut := GamutForModel("LCT001"); gamut != GamutB {
+		t.Fatalf("Expected gamut B for LCT001, got %v", gamut)
+	}
+	if gamut := GamutForModel("LST001"); gamut != GamutA {
+		t.Fatalf("Expected gamut A for LST001, got %v", gamut)
+	}
+	if gamut := GamutForModel("unknown"); gamut != GamutC {
+		t.Fatalf("Expected gamut C fallback, got %v", gamut)
+	}
+	if _, ok := GamutFromPoints([][]float64{{0.1, 0.2}}); ok {
+		t.Fatal("Expected incomplete points to be rejected")
+	}
+}
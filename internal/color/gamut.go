@@ -0,0 +1,120 @@
+package color
+
+import (
+	"math"
+	"strings"
+)
+
+// Gamut is the triangle of xy colors a light can reproduce (red, green, blue corners)
+type Gamut [3]XY
+
+// Gamuts published by Philips Hue for their light generations
+var (
+	GamutA = Gamut{{0.704, 0.296}, {0.2151, 0.7106}, {0.138, 0.08}}
+	GamutB = Gamut{{0.675, 0.322}, {0.409, 0.518}, {0.167, 0.04}}
+	GamutC = Gamut{{0.6915, 0.3083}, {0.17, 0.7}, {0.1532, 0.0475}}
+)
+
+// modelGamuts maps Hue model IDs to the gamut type of the light
+var modelGamuts = map[string]string{
+	// Gamut A: LivingColors and LightStrips v1
+	"LST001": "A", "LLC005": "A", "LLC006": "A", "LLC007": "A",
+	"LLC010": "A", "LLC011": "A", "LLC012": "A", "LLC013": "A", "LLC014": "A",
+	// Gamut B: first generation Hue bulbs
+	"LCT001": "B", "LCT002": "B", "LCT003": "B", "LCT007": "B", "LLM001": "B",
+	// Gamut C: current generation bulbs, strips and Go
+	"LCT010": "C", "LCT011": "C", "LCT012": "C", "LCT014": "C", "LCT015": "C",
+	"LCT016": "C", "LLC020": "C", "LST002": "C", "LCA001": "C", "LCA002": "C",
+	"LCA003": "C",
+}
+
+// GamutByType returns the gamut for a type letter ("A", "B" or "C")
+func GamutByType(gamutType string) (Gamut, bool) {
+	switch strings.ToUpper(gamutType) {
+	case "A":
+		return GamutA, true
+	case "B":
+		return GamutB, true
+	case "C":
+		return GamutC, true
+	}
+	return Gamut{}, false
+}
+
+// GamutTypeForModel returns the gamut type letter for a Hue model ID, if known
+func GamutTypeForModel(modelID string) (string, bool) {
+	gamutType, exists := modelGamuts[strings.ToUpper(modelID)]
+	return gamutType, exists
+}
+
+// GamutForModel returns the gamut of a Hue model ID, defaulting to gamut C
+// for color lights that are newer than the table
+func GamutForModel(modelID string) Gamut {
+	if gamutType, exists := GamutTypeForModel(modelID); exists {
+		gamut, _ := GamutByType(gamutType)
+		return gamut
+	}
+	return GamutC
+}
+
+// GamutFromPoints builds a gamut from the [[x, y], ...] corners reported by the bridge
+func GamutFromPoints(points [][]float64) (Gamut, bool) {
+	if len(points) != 3 {
+		return Gamut{}, false
+	}
+	var gamut Gamut
+	for i, point := range points {
+		if len(point) != 2 {
+			return Gamut{}, false
+		}
+		gamut[i] = XY{X: point[0], Y: point[1]}
+	}
+	return gamut, true
+}
+
+// Contains reports whether the xy point lies inside the gamut
+func (g Gamut) Contains(xy XY) bool {
+	cross := func(p1, p2 XY) float64 {
+		return (xy.X-p2.X)*(p1.Y-p2.Y) - (p1.X-p2.X)*(xy.Y-p2.Y)
+	}
+	d1 := cross(g[0], g[1])
+	d2 := cross(g[1], g[2])
+	d3 := cross(g[2], g[0])
+	hasNeg := d1 < 0 || d2 < 0 || d3 < 0
+	hasPos := d1 > 0 || d2 > 0 || d3 > 0
+	return !(hasNeg && hasPos)
+}
+
+// Clamp moves an xy point to the closest point inside the gamut
+func (g Gamut) Clamp(xy XY) XY {
+	if g.Contains(xy) {
+		return xy
+	}
+
+	best := closestPointOnSegment(xy, g[0], g[1])
+	bestDist := distance(xy, best)
+	for _, edge := range [][2]XY{{g[1], g[2]}, {g[2], g[0]}} {
+		point := closestPointOnSegment(xy, edge[0], edge[1])
+		if dist := distance(xy, point); dist < bestDist {
+			best, bestDist = point, dist
+		}
+	}
+	return best
+}
+
+// closestPointOnSegment projects p onto the segment ab
+func closestPointOnSegment(p, a, b XY) XY {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	lengthSq := dx*dx + dy*dy
+	if lengthSq == 0 {
+		return a
+	}
+	t := ((p.X-a.X)*dx + (p.Y-a.Y)*dy) / lengthSq
+	t = math.Max(0, math.Min(1, t))
+	return XY{X: a.X + t*dx, Y: a.Y + t*dy}
+}
+
+// distance returns the euclidean distance between two xy points
+func distance(a, b XY) float64 {
+	return math.Hypot(a.X-b.X, a.Y-b.Y)
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -18,9 +19,13 @@ type Config struct {
 	// Email Configuration
 	Email EmailConfig
 	
+	// Home location, used for sun times
+	Location LocationConfig
+
 	// External Services
-	Sonos SonosConfig
-	Hue   HueConfig
+	Sonos    SonosConfig
+	Hue      HueConfig
+	Calendar CalendarConfig
 	
 	// Logging Configuration
 	Logging LoggingConfig
@@ -34,6 +39,9 @@ type DatabaseConfig struct {
 	Password string
 	Database string
 	Timeout  time.Duration
+
+	// Local SQLite file for household data such as light routines
+	SQLitePath string
 }
 
 // EmailConfig holds email service settings
@@ -44,6 +52,13 @@ type EmailConfig struct {
 	FromPassword string
 }
 
+// LocationConfig holds the coordinates and time zone of the home
+type LocationConfig struct {
+	Latitude  float64
+	Longitude float64
+	TimeZone  string // IANA name such as "America/Chicago", the server's zone when empty
+}
+
 // SonosConfig holds Sonos service settings
 type SonosConfig struct {
 	APIURL   string
@@ -57,6 +72,49 @@ type HueConfig struct {
 	Username    string
 	Timeout     time.Duration
 	RetryCount  int
+
+	// Name of the bridge above, and any additional bridges
+	BridgeID string
+	Bridges  []HueBridgeConfig
+
+	// How often motion sensors and switches are checked
+	SensorPollInterval time.Duration
+
+	// Circadian lighting mode
+	CircadianRooms      []string
+	CircadianInterval   time.Duration
+	CircadianNightStart string
+
+	// Wake-up and sleep routines
+	RoutineStepInterval time.Duration
+
+	// Lights-follow-music mode
+	MusicSyncPollInterval   time.Duration
+	MusicSyncTransitionTime int
+	MusicSyncPaletteSize    int
+}
+
+// HueBridgeConfig holds the connection settings of an additional Hue bridge
+type HueBridgeConfig struct {
+	ID       string
+	IP       string
+	Username string
+}
+
+// CalendarConfig holds calendar sync settings
+type CalendarConfig struct {
+	SyncInterval time.Duration
+	SyncPastDays int // How far back the first sync of a calendar reaches
+
+	// Push notifications, which need a public address such as the Cloudflare tunnel
+	WebhookURL string // Public URL of /webhooks/google/calendar, empty to disable
+	WatchTTL   time.Duration
+
+	// Subscribed ICS feeds
+	FeedRefreshInterval time.Duration
+
+	// Reminders delivered to the home
+	ReminderLateWindow time.Duration // How late a reminder is still delivered, such as after a restart
 }
 
 // LoggingConfig holds logging settings
@@ -81,6 +139,8 @@ func Load() *Config {
 			Password: getEnv("DB_PASSWORD", ""),
 			Database: getEnv("DB_NAME", "woodhome"),
 			Timeout:  time.Duration(getEnvAsInt("DB_TIMEOUT", 30)) * time.Second,
+
+			SQLitePath: getEnv("SQLITE_PATH", "./woodhome.db"),
 		},
 		
 		Email: EmailConfig{
@@ -90,6 +150,12 @@ func Load() *Config {
 			FromPassword: getEnv("FROM_PASSWORD", ""),
 		},
 		
+		Location: LocationConfig{
+			Latitude:  getEnvAsFloat("HOME_LATITUDE", 0),
+			Longitude: getEnvAsFloat("HOME_LONGITUDE", 0),
+			TimeZone:  getEnv("HOME_TIMEZONE", ""),
+		},
+
 		Sonos: SonosConfig{
 			APIURL:     getEnv("SONOS_API_URL", "http://localhost:5005"),
 			Timeout:    time.Duration(getEnvAsInt("SONOS_TIMEOUT", 30)) * time.Second,
@@ -101,6 +167,32 @@ func Load() *Config {
 			Username:   getEnv("HUE_USERNAME", ""),
 			Timeout:    time.Duration(getEnvAsInt("HUE_TIMEOUT", 30)) * time.Second,
 			RetryCount: getEnvAsInt("HUE_RETRY_COUNT", 3),
+
+			BridgeID: getEnv("HUE_BRIDGE_ID", "home"),
+			Bridges:  getHueBridges(),
+
+			SensorPollInterval: time.Duration(getEnvAsInt("HUE_SENSOR_POLL_SECONDS", 2)) * time.Second,
+
+			CircadianRooms:      getEnvAsList("HUE_CIRCADIAN_ROOMS"),
+			CircadianInterval:   time.Duration(getEnvAsInt("HUE_CIRCADIAN_INTERVAL_SECONDS", 30)) * time.Second,
+			CircadianNightStart: getEnv("HUE_CIRCADIAN_NIGHT_START", "22:30"),
+
+			RoutineStepInterval: time.Duration(getEnvAsInt("HUE_ROUTINE_STEP_SECONDS", 60)) * time.Second,
+
+			MusicSyncPollInterval:   time.Duration(getEnvAsInt("HUE_MUSIC_SYNC_POLL_SECONDS", 5)) * time.Second,
+			MusicSyncTransitionTime: getEnvAsInt("HUE_MUSIC_SYNC_TRANSITION", 20),
+			MusicSyncPaletteSize:    getEnvAsInt("HUE_MUSIC_SYNC_PALETTE_SIZE", 5),
+		},
+
+		Calendar: CalendarConfig{
+			SyncInterval: time.Duration(getEnvAsInt("CALENDAR_SYNC_INTERVAL_SECONDS", 300)) * time.Second,
+			SyncPastDays: getEnvAsInt("CALENDAR_SYNC_PAST_DAYS", 90),
+			WebhookURL:   getEnv("CALENDAR_WEBHOOK_URL", ""),
+			WatchTTL:     time.Duration(getEnvAsInt("CALENDAR_WATCH_TTL_HOURS", 168)) * time.Hour,
+
+			FeedRefreshInterval: time.Duration(getEnvAsInt("CALENDAR_FEED_REFRESH_MINUTES", 60)) * time.Minute,
+
+			ReminderLateWindow: time.Duration(getEnvAsInt("CALENDAR_REMINDER_LATE_MINUTES", 15)) * time.Minute,
 		},
 		
 		Logging: LoggingConfig{
@@ -137,3 +229,39 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// getEnvAsFloat gets an environment variable as float with a default value
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsList gets a comma-separated environment variable as a list
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getHueBridges reads the additional bridges listed in HUE_BRIDGES.
+// Each bridge is configured by HUE_BRIDGE_<ID>_IP and HUE_BRIDGE_<ID>_USERNAME.
+func getHueBridges() []HueBridgeConfig {
+	var bridges []HueBridgeConfig
+	for _, id := range getEnvAsList("HUE_BRIDGES") {
+		prefix := "HUE_BRIDGE_" + strings.ToUpper(id) + "_"
+		bridges = append(bridges, HueBridgeConfig{
+			ID:       id,
+			IP:       getEnv(prefix+"IP", ""),
+			Username: getEnv(prefix+"USERNAME", ""),
+		})
+	}
+	return bridges
+}
@@ -1,24 +1,43 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"os"
-	"time"
 
 	"woodhome-webapp/internal/services"
 
 	"github.com/gorilla/sessions"
 	"golang.org/x/oauth2"
-	_ "modernc.org/sqlite"
 )
 
 var (
 	sessionStore *sessions.CookieStore
+
+	loginHooks  []func(userID int)
+	logoutHooks []func(userID int)
+
+	googleAccounts *services.GoogleAccountService
 )
 
+// SetGoogleAccountService links each Google account that signs in as its own account, so
+// several can be linked per household
+func SetGoogleAccountService(accountService *services.GoogleAccountService) {
+	googleAccounts = accountService
+}
+
+// OnLogin registers a function called after a user logs in
+func OnLogin(hook func(userID int)) {
+	loginHooks = append(loginHooks, hook)
+}
+
+// OnLogout registers a function called when a user logs out
+func OnLogout(hook func(userID int)) {
+	logoutHooks = append(logoutHooks, hook)
+}
+
 // GetSessionStore creates session store dynamically
 func GetSessionStore() *sessions.CookieStore {
 	if sessionStore == nil {
@@ -50,6 +69,7 @@ func GoogleLoginHandler(w http.ResponseWriter, r *http.Request) {
 	// Store state in session (expires in 10 minutes)
 	session, _ := GetSessionStore().Get(r, "auth-session")
 	session.Values["oauth_state"] = state
+	delete(session.Values, "google_link")
 	session.Options = &sessions.Options{
 		Path:     "/",
 		MaxAge:   600, // 10 minutes
@@ -86,6 +106,11 @@ func GoogleCallbackHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Clear state token (one-time use)
 	delete(session.Values, "oauth_state")
+	// Only a signed-in household member can link another account
+	linking, _ := session.Values["google_link"].(bool)
+	signedIn, _ := session.Values["oauth_authenticated"].(bool)
+	linking = linking && signedIn
+	delete(session.Values, "google_link")
 
 	// Exchange authorization code for access token
 	code := r.URL.Query().Get("code")
@@ -97,11 +122,46 @@ func GoogleCallbackHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Store token in SQLite database
-	userID := 1
-	err = saveOAuthTokenToSQLite(userID, token)
-	if err != nil {
-		log.Printf("Failed to save OAuth token to SQLite: %v", err)
-		http.Error(w, "Failed to save token", http.StatusInternalServerError)
+	userID := services.HouseholdUserID
+	if googleAccounts != nil {
+		// Signing in only accepts linked accounts, new ones are linked from the settings
+		link := googleAccounts.SignIn
+		if linking {
+			link = googleAccounts.LinkAccount
+		}
+		account, err := link(r.Context(), token)
+		if err != nil {
+			log.Printf("Failed to link Google account: %v", err)
+			if linking {
+				session.Save(r, w)
+				http.Redirect(w, r, "/?google_account=failed", http.StatusSeeOther)
+				return
+			}
+			if errors.Is(err, services.ErrGoogleAccountNotLinked) {
+				http.Error(w, "This Google account is not linked to the household", http.StatusForbidden)
+				return
+			}
+			http.Error(w, "Failed to save token", http.StatusInternalServerError)
+			return
+		}
+		userID = account.UserID
+	} else {
+		err = saveOAuthTokenToSQLite(userID, token)
+		if err != nil {
+			log.Printf("Failed to save OAuth token to SQLite: %v", err)
+			http.Error(w, "Failed to save token", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Linking another account from the settings keeps the signed in user
+	if linking {
+		session.Save(r, w)
+		for _, hook := range loginHooks {
+			hook(userID)
+		}
+		log.Printf("Google account linked for user %d", userID)
+		http.Redirect(w, r, "/?google_account=linked", http.StatusSeeOther)
 		return
 	}
 
@@ -118,6 +178,10 @@ func GoogleCallbackHandler(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("OAuth token stored in memory for user %d", userID)
 
+	for _, hook := range loginHooks {
+		hook(userID)
+	}
+
 	log.Printf("OAuth token stored successfully, redirecting to SPA dashboard")
 	// Redirect to SPA dashboard with success parameter
 	http.Redirect(w, r, "/?auth=success", http.StatusSeeOther)
@@ -173,77 +237,26 @@ func AuthStatusHandler(w http.ResponseWriter, r *http.Request) {
 // LogoutHandler clears the session and logs out the user
 func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	session, _ := GetSessionStore().Get(r, "auth-session")
+	userID, loggedIn := session.Values["user_id"].(int)
 	session.Options.MaxAge = -1 // Delete the cookie
 	session.Save(r, w)
 
+	if loggedIn {
+		for _, hook := range logoutHooks {
+			hook(userID)
+		}
+	}
+
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
 // SQLite database functions for OAuth token storage
 func saveOAuthTokenToSQLite(userID int, token *oauth2.Token) error {
-	// Open SQLite database
-	db, err := sql.Open("sqlite", "./oauth_tokens.db")
-	if err != nil {
-		return err
-	}
-	defer db.Close()
-
-	// Create table if not exists
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS oauth_tokens (
-			user_id INTEGER PRIMARY KEY,
-			access_token TEXT NOT NULL,
-			refresh_token TEXT,
-			token_type TEXT DEFAULT 'Bearer',
-			expiry DATETIME NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	// Insert or update token
-	_, err = db.Exec(`
-		INSERT OR REPLACE INTO oauth_tokens 
-		(user_id, access_token, refresh_token, token_type, expiry, updated_at)
-		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
-	`, userID, token.AccessToken, token.RefreshToken, token.TokenType, token.Expiry.Format(time.RFC3339))
-
-	return err
+	return services.SaveOAuthToken(userID, token)
 }
 
 func getOAuthTokenFromSQLite(userID int) (*oauth2.Token, error) {
-	// Open SQLite database
-	db, err := sql.Open("sqlite", "./oauth_tokens.db")
-	if err != nil {
-		return nil, err
-	}
-	defer db.Close()
-
-	var accessToken, refreshToken, tokenType, expiryStr string
-	err = db.QueryRow(`
-		SELECT access_token, refresh_token, token_type, expiry 
-		FROM oauth_tokens WHERE user_id = ?
-	`, userID).Scan(&accessToken, &refreshToken, &tokenType, &expiryStr)
-
-	if err != nil {
-		return nil, err
-	}
-
-	// Parse expiry
-	expiry, err := time.Parse(time.RFC3339, expiryStr)
-	if err != nil {
-		return nil, err
-	}
-
-	return &oauth2.Token{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		TokenType:    tokenType,
-		Expiry:       expiry,
-	}, nil
+	return services.LoadOAuthToken(userID)
 }
 
 func getUserInfoFromDB(userID int) (map[string]string, error) {
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"html/template"
 	"log"
 	"net/http"
@@ -17,8 +19,15 @@ import (
 
 // CalendarHandler handles HTTP requests for calendar operations
 type CalendarHandler struct {
-	calendarService      *services.CalendarService
-	calendarCacheService *services.CalendarCacheService
+	calendarService        *services.CalendarService
+	calendarCacheService   *services.CalendarCacheService
+	calendarSyncService    *services.CalendarSyncService
+	calendarWatchService   *services.CalendarWatchService
+	calendarFeedService    *services.CalendarFeedService
+	calendarExportService  *services.CalendarExportService
+	calendarAccountService *services.CalendarAccountService
+	householdService       *services.HouseholdCalendarService
+	googleAccountService   *services.GoogleAccountService
 }
 
 // NewCalendarHandler creates a new CalendarHandler instance
@@ -33,6 +42,38 @@ func NewCalendarHandler(calendarService *services.CalendarService) *CalendarHand
 	}
 }
 
+// SetSyncService serves events from the local calendar store once it has synced
+func (h *CalendarHandler) SetSyncService(calendarSyncService *services.CalendarSyncService) {
+	h.calendarSyncService = calendarSyncService
+}
+
+// SetWatchService reports push notification channels on /watch
+func (h *CalendarHandler) SetWatchService(calendarWatchService *services.CalendarWatchService) {
+	h.calendarWatchService = calendarWatchService
+}
+
+// SetFeedService merges the events of subscribed ICS feeds into the Google events
+func (h *CalendarHandler) SetFeedService(calendarFeedService *services.CalendarFeedService) {
+	h.calendarFeedService = calendarFeedService
+}
+
+// SetAccountService merges the events of linked calendar accounts, such as CalDAV calendars,
+// into the Google events
+func (h *CalendarHandler) SetAccountService(calendarAccountService *services.CalendarAccountService) {
+	h.calendarAccountService = calendarAccountService
+}
+
+// SetGoogleAccountService reads the Google calendars of every linked Google account, not
+// only the signed in one
+func (h *CalendarHandler) SetGoogleAccountService(googleAccountService *services.GoogleAccountService) {
+	h.googleAccountService = googleAccountService
+}
+
+// CacheService returns the cache behind the calendar routes
+func (h *CalendarHandler) CacheService() *services.CalendarCacheService {
+	return h.calendarCacheService
+}
+
 // RegisterRoutes registers all calendar routes
 func (h *CalendarHandler) RegisterRoutes(router *mux.Router) {
 	// Calendar API routes
@@ -40,10 +81,34 @@ func (h *CalendarHandler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/calendars", h.GetCalendarsHandler).Methods("GET")
 	router.HandleFunc("/colors", h.GetColorsHandler).Methods("GET")
 
+	// Household calendar routes
+	if h.householdService != nil {
+		router.HandleFunc("/events", h.CreateEventHandler).Methods("POST")
+		router.HandleFunc("/events/{id}", h.UpdateEventHandler).Methods("PUT")
+		router.HandleFunc("/events/{id}", h.DeleteEventHandler).Methods("DELETE")
+		router.HandleFunc("/household", h.GetHouseholdCalendarsHandler).Methods("GET")
+		router.HandleFunc("/household", h.CreateHouseholdCalendarHandler).Methods("POST")
+		router.HandleFunc("/household/{id}", h.UpdateHouseholdCalendarHandler).Methods("PUT")
+		router.HandleFunc("/household/{id}", h.DeleteHouseholdCalendarHandler).Methods("DELETE")
+	}
+
 	// Cache management routes
 	router.HandleFunc("/cache/refresh", h.RefreshCacheHandler).Methods("POST")
 	router.HandleFunc("/cache/stats", h.GetCacheStatsHandler).Methods("GET")
 	router.HandleFunc("/cache/clear", h.ClearCacheHandler).Methods("POST")
+
+	// Local store routes
+	router.HandleFunc("/sync", h.GetSyncStatusHandler).Methods("GET")
+	router.HandleFunc("/sync", h.SyncHandler).Methods("POST")
+	router.HandleFunc("/watch", h.GetWatchStatusHandler).Methods("GET")
+
+	// ICS export routes
+	if h.calendarExportService != nil {
+		router.HandleFunc("/export.ics", h.ExportHandler).Methods("GET")
+		router.HandleFunc("/subscriptions", h.GetSubscriptionsHandler).Methods("GET")
+		router.HandleFunc("/subscriptions", h.CreateSubscriptionHandler).Methods("POST")
+		router.HandleFunc("/subscriptions/{id}", h.DeleteSubscriptionHandler).Methods("DELETE")
+	}
 }
 
 // CalendarPageHandler serves the calendar HTML page
@@ -75,14 +140,6 @@ func (h *CalendarHandler) GetEventsHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Get token from SQLite
-	token, err := getOAuthTokenFromSQLite(userID)
-	if err != nil {
-		log.Printf("Failed to get token from SQLite: %v", err)
-		http.Error(w, "Token not found", http.StatusUnauthorized)
-		return
-	}
-
 	// Parse date range from query parameters
 	startStr := r.URL.Query().Get("start")
 	endStr := r.URL.Query().Get("end")
@@ -111,21 +168,91 @@ func (h *CalendarHandler) GetEventsHandler(w http.ResponseWriter, r *http.Reques
 		}
 	}
 
+	events, err := h.collectEvents(r.Context(), userID, start, end, selectedCalendars)
+	if errors.Is(err, errCalendarTokenNotFound) {
+		http.Error(w, "Token not found", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to fetch calendar events: %v", err)
+		http.Error(w, "Failed to fetch events", http.StatusInternalServerError)
+		return
+	}
+
+	// Return JSON response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+// errCalendarTokenNotFound is returned by collectEvents when the user has no stored OAuth token
+var errCalendarTokenNotFound = errors.New("calendar token not found")
+
+// collectEvents gathers a user's events from start to end: Google events from the local store
+// or the cache, merged with the subscribed ICS feeds, linked accounts and household calendars.
+// An empty calendar filter includes every calendar.
+func (h *CalendarHandler) collectEvents(ctx context.Context, userID int, start, end time.Time, selectedCalendars []string) ([]services.CalendarEvent, error) {
+	// Events of subscribed ICS feeds, linked accounts and household calendars are merged with
+	// the Google events
+	googleCalendars, feedCalendars := services.SplitCalendarFeedIDs(selectedCalendars)
+	googleCalendars, accountCalendars := services.SplitCalendarAccountIDs(googleCalendars)
+	googleCalendars, householdCalendars := services.SplitHouseholdCalendarIDs(googleCalendars)
+	filtered := len(selectedCalendars) > 0
+	var mergedEvents []services.CalendarEvent
+	if h.calendarFeedService != nil && (!filtered || len(feedCalendars) > 0) {
+		mergedEvents = h.calendarFeedService.GetEvents(start, end, feedCalendars)
+	}
+	if h.calendarAccountService != nil && (!filtered || len(accountCalendars) > 0) {
+		mergedEvents = append(mergedEvents, h.calendarAccountService.GetEvents(start, end, accountCalendars)...)
+	}
+	if h.householdService != nil && (!filtered || len(householdCalendars) > 0) {
+		mergedEvents = append(mergedEvents, h.householdService.GetEvents(start, end, householdCalendars)...)
+	}
+	if filtered && len(googleCalendars) == 0 {
+		return append([]services.CalendarEvent{}, mergedEvents...), nil
+	}
+	selectedCalendars = googleCalendars
+
+	// Fan out across every linked Google account, each event tagged with its account
+	if h.googleAccountService != nil {
+		events, err := h.googleAccountService.GetEvents(ctx, start, end, selectedCalendars)
+		if errors.Is(err, services.ErrNoGoogleAccounts) {
+			return nil, errCalendarTokenNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return append(events, mergedEvents...), nil
+	}
+
+	// Serve the household's events from the local store once it has synced
+	if h.calendarSyncService != nil && h.calendarSyncService.Ready() && h.calendarSyncService.UserID() == userID {
+		return append(h.calendarSyncService.GetEvents(start, end, selectedCalendars), mergedEvents...), nil
+	}
+
+	// Get token from SQLite
+	token, err := getOAuthTokenFromSQLite(userID)
+	if err != nil {
+		log.Printf("Failed to get token from SQLite: %v", err)
+		return nil, errCalendarTokenNotFound
+	}
+
 	// Fetch events from Google Calendar (with caching)
 	var events []services.CalendarEvent
 
 	if len(selectedCalendars) > 0 {
 		// Use filtered events if calendar IDs are provided
-		events, err = h.calendarCacheService.GetCalendarEventsFiltered(r.Context(), token, start, end, selectedCalendars)
+		events, err = h.calendarCacheService.GetCalendarEventsFiltered(ctx, token, start, end, selectedCalendars)
 	} else {
 		// Use all events if no calendar filter is provided
-		events, err = h.calendarCacheService.GetCalendarEvents(r.Context(), token, start, end)
+		events, err = h.calendarCacheService.GetCalendarEvents(ctx, token, start, end)
 	}
-
 	if err != nil {
-		log.Printf("Failed to fetch calendar events: %v", err)
-		http.Error(w, "Failed to fetch events", http.StatusInternalServerError)
-		return
+		return nil, err
+	}
+
+	if len(mergedEvents) > 0 {
+		// Copy first, the cached slice is shared between requests
+		events = append(append([]services.CalendarEvent{}, events...), mergedEvents...)
 	}
 
 	// Save refreshed token back to SQLite if it was updated
@@ -135,9 +262,13 @@ func (h *CalendarHandler) GetEventsHandler(w http.ResponseWriter, r *http.Reques
 		// Don't fail the request, just log the error
 	}
 
-	// Return JSON response
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(events)
+	return events, nil
+}
+
+// HouseholdEvents lists the events of every household calendar source the way /events does,
+// for background jobs such as reminders
+func (h *CalendarHandler) HouseholdEvents(ctx context.Context, start, end time.Time, calendarIDs []string) ([]services.CalendarEvent, error) {
+	return h.collectEvents(ctx, services.HouseholdUserID, start, end, calendarIDs)
 }
 
 // AuthRequired middleware protects calendar routes
@@ -167,32 +298,84 @@ func (h *CalendarHandler) GetCalendarsHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	// 2. Get OAuth token
-	tokenData, ok := session.Values["oauth_token"].(string)
-	if !ok || tokenData == "" {
-		http.Error(w, "No OAuth token found", http.StatusUnauthorized)
-		return
-	}
+	var calendars []services.CalendarInfo
+	if h.googleAccountService != nil {
+		// 2. Fetch the calendars of every linked Google account
+		var err error
+		calendars, err = h.googleAccountService.GetCalendars(r.Context())
+		if errors.Is(err, services.ErrNoGoogleAccounts) {
+			http.Error(w, "No OAuth token found", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to fetch calendars: %v", err)
+			http.Error(w, "Failed to fetch calendars", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		// 2. Get OAuth token
+		tokenData, ok := session.Values["oauth_token"].(string)
+		if !ok || tokenData == "" {
+			http.Error(w, "No OAuth token found", http.StatusUnauthorized)
+			return
+		}
 
-	var token oauth2.Token
-	if err := json.Unmarshal([]byte(tokenData), &token); err != nil {
-		http.Error(w, "Invalid OAuth token", http.StatusUnauthorized)
-		return
-	}
+		var token oauth2.Token
+		if err := json.Unmarshal([]byte(tokenData), &token); err != nil {
+			http.Error(w, "Invalid OAuth token", http.StatusUnauthorized)
+			return
+		}
 
-	// 3. Fetch calendars (with caching)
-	calendars, err := h.calendarCacheService.GetCalendars(r.Context(), &token)
-	if err != nil {
-		log.Printf("Failed to fetch calendars: %v", err)
-		http.Error(w, "Failed to fetch calendars", http.StatusInternalServerError)
-		return
+		// 3. Fetch calendars (with caching)
+		var err error
+		calendars, err = h.calendarCacheService.GetCalendars(r.Context(), &token)
+		if err != nil {
+			log.Printf("Failed to fetch calendars: %v", err)
+			http.Error(w, "Failed to fetch calendars", http.StatusInternalServerError)
+			return
+		}
 	}
 
+	// Subscribed ICS feeds, linked accounts and household calendars are listed after the
+	// Google calendars
+	calendars = append(calendars, h.mergedCalendars()...)
+
 	// 4. Return JSON response
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(calendars)
 }
 
+// mergedCalendars lists the calendars of subscribed ICS feeds, linked accounts and household
+// calendars
+func (h *CalendarHandler) mergedCalendars() []services.CalendarInfo {
+	var calendars []services.CalendarInfo
+	if h.calendarFeedService != nil {
+		calendars = append(calendars, h.calendarFeedService.GetCalendars()...)
+	}
+	if h.calendarAccountService != nil {
+		calendars = append(calendars, h.calendarAccountService.GetCalendars()...)
+	}
+	if h.householdService != nil {
+		calendars = append(calendars, h.householdService.GetCalendars()...)
+	}
+	return calendars
+}
+
+// HouseholdCalendars lists the calendars of every source the way /calendars does, for
+// background jobs such as the agenda email. Google calendars are only included when the
+// linked Google accounts are known.
+func (h *CalendarHandler) HouseholdCalendars(ctx context.Context) ([]services.CalendarInfo, error) {
+	var calendars []services.CalendarInfo
+	if h.googleAccountService != nil {
+		var err error
+		calendars, err = h.googleAccountService.GetCalendars(ctx)
+		if err != nil && !errors.Is(err, services.ErrNoGoogleAccounts) {
+			return nil, err
+		}
+	}
+	return append(calendars, h.mergedCalendars()...), nil
+}
+
 // GetColorsHandler returns the Google Calendar color palette and calendar colors
 func (h *CalendarHandler) GetColorsHandler(w http.ResponseWriter, r *http.Request) {
 	// 1. Check authentication
@@ -354,3 +537,69 @@ func (h *CalendarHandler) ClearCacheHandler(w http.ResponseWriter, r *http.Reque
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// GetSyncStatusHandler reports the local calendar store
+func (h *CalendarHandler) GetSyncStatusHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Check authentication
+	session, _ := GetSessionStore().Get(r, "auth-session")
+	authenticated, ok := session.Values["oauth_authenticated"].(bool)
+	if !ok || !authenticated {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if h.calendarSyncService == nil {
+		http.Error(w, "Calendar sync is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.calendarSyncService.GetStatus())
+}
+
+// SyncHandler pulls calendar changes into the local store right away
+func (h *CalendarHandler) SyncHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Check authentication
+	session, _ := GetSessionStore().Get(r, "auth-session")
+	authenticated, ok := session.Values["oauth_authenticated"].(bool)
+	if !ok || !authenticated {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if h.calendarSyncService == nil {
+		http.Error(w, "Calendar sync is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	if err := h.calendarSyncService.Sync(r.Context()); err != nil {
+		log.Printf("Failed to sync calendars: %v", err)
+		http.Error(w, "Failed to sync calendars", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"sync":   h.calendarSyncService.GetStatus(),
+	})
+}
+
+// GetWatchStatusHandler reports the push notification channels
+func (h *CalendarHandler) GetWatchStatusHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Check authentication
+	session, _ := GetSessionStore().Get(r, "auth-session")
+	authenticated, ok := session.Values["oauth_authenticated"].(bool)
+	if !ok || !authenticated {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if h.calendarWatchService == nil {
+		http.Error(w, "Calendar push notifications are not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.calendarWatchService.GetStatus())
+}
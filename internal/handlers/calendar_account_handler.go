@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"woodhome-webapp/internal/models"
+	"woodhome-webapp/internal/services"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+// CalendarAccountHandler handles HTTP requests for linked calendar accounts
+type CalendarAccountHandler struct {
+	accountService *services.CalendarAccountService
+}
+
+// NewCalendarAccountHandler creates a new CalendarAccountHandler
+func NewCalendarAccountHandler(accountService *services.CalendarAccountService) *CalendarAccountHandler {
+	return &CalendarAccountHandler{
+		accountService: accountService,
+	}
+}
+
+// RegisterRoutes registers all account routes
+func (h *CalendarAccountHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("", h.GetAccounts).Methods("GET")
+	router.HandleFunc("", h.CreateAccount).Methods("POST")
+	router.HandleFunc("/{id}", h.GetAccount).Methods("GET")
+	router.HandleFunc("/{id}", h.UpdateAccount).Methods("PUT")
+	router.HandleFunc("/{id}", h.DeleteAccount).Methods("DELETE")
+	router.HandleFunc("/{id}/sync", h.SyncAccount).Methods("POST")
+	router.HandleFunc("/{id}/events", h.CreateEvent).Methods("POST")
+	router.HandleFunc("/{id}/events/{eventID}", h.UpdateEvent).Methods("PUT")
+	router.HandleFunc("/{id}/events/{eventID}", h.DeleteEvent).Methods("DELETE")
+}
+
+// RegisterAuthRoutes registers the Microsoft sign in, which links a Microsoft 365 account
+// next to the Google login
+func (h *CalendarAccountHandler) RegisterAuthRoutes(router *mux.Router) {
+	router.HandleFunc("/auth/microsoft/login", h.MicrosoftLoginHandler).Methods("GET")
+	router.HandleFunc("/auth/microsoft/callback", h.MicrosoftCallbackHandler).Methods("GET")
+}
+
+// authenticated checks the session like the other calendar routes
+func (h *CalendarAccountHandler) authenticated(w http.ResponseWriter, r *http.Request) bool {
+	session, _ := GetSessionStore().Get(r, "auth-session")
+	authenticated, ok := session.Values["oauth_authenticated"].(bool)
+	if !ok || !authenticated {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// GetAccounts returns every account
+func (h *CalendarAccountHandler) GetAccounts(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	accounts := h.accountService.GetAccounts()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"accounts": accounts,
+		"count":    len(accounts),
+	})
+}
+
+// GetAccount returns a single account
+func (h *CalendarAccountHandler) GetAccount(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	account, exists := h.accountService.GetAccount(mux.Vars(r)["id"])
+	if !exists {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(account)
+}
+
+// CreateAccount links an account and syncs it in the background
+func (h *CalendarAccountHandler) CreateAccount(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	var account models.CalendarAccount
+	if err := json.NewDecoder(r.Body).Decode(&account); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.accountService.CreateAccount(account)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if created.Enabled {
+		go func() {
+			if err := h.accountService.SyncAccount(context.Background(), created.ID); err != nil {
+				logrus.Warnf("Failed to sync new calendar account: %v", err)
+			}
+		}()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"account": created,
+	})
+}
+
+// UpdateAccount replaces the settings of an account. Leaving the password out keeps it.
+func (h *CalendarAccountHandler) UpdateAccount(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if _, exists := h.accountService.GetAccount(id); !exists {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	var account models.CalendarAccount
+	if err := json.NewDecoder(r.Body).Decode(&account); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := h.accountService.UpdateAccount(id, account)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"account": updated,
+	})
+}
+
+// DeleteAccount unlinks an account
+func (h *CalendarAccountHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if _, exists := h.accountService.GetAccount(id); !exists {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.accountService.DeleteAccount(id); err != nil {
+		logrus.Errorf("Failed to delete calendar account %s: %v", id, err)
+		http.Error(w, "Failed to delete account", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// SyncAccount syncs an account right away
+func (h *CalendarAccountHandler) SyncAccount(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if _, exists := h.accountService.GetAccount(id); !exists {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.accountService.SyncAccount(r.Context(), id); err != nil {
+		logrus.Warnf("Failed to sync calendar account %s: %v", id, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	account, _ := h.accountService.GetAccount(id)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"account": account,
+	})
+}
+
+// CreateEvent adds an event to a writable calendar of an account
+func (h *CalendarAccountHandler) CreateEvent(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if _, exists := h.accountService.GetAccount(id); !exists {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	var input models.CalendarAccountEventInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	event, err := h.accountService.CreateEvent(r.Context(), id, input)
+	if err != nil {
+		h.writeEventError(w, "create", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"event":  event,
+	})
+}
+
+// UpdateEvent changes an event. A conflicting change made elsewhere since the last sync
+// returns 409, and the client should reload the events.
+func (h *CalendarAccountHandler) UpdateEvent(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	var input models.CalendarAccountEventInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	event, err := h.accountService.UpdateEvent(r.Context(), mux.Vars(r)["eventID"], input)
+	if err != nil {
+		h.writeEventError(w, "update", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"event":  event,
+	})
+}
+
+// DeleteEvent removes an event
+func (h *CalendarAccountHandler) DeleteEvent(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	if err := h.accountService.DeleteEvent(r.Context(), mux.Vars(r)["eventID"]); err != nil {
+		h.writeEventError(w, "delete", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// MicrosoftLoginHandler sends a signed in user to Microsoft to link an account
+func (h *CalendarAccountHandler) MicrosoftLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	// Generate state token for CSRF protection
+	state, err := services.GenerateStateToken()
+	if err != nil {
+		http.Error(w, "Failed to generate state token", http.StatusInternalServerError)
+		return
+	}
+
+	session, _ := GetSessionStore().Get(r, "auth-session")
+	session.Values["microsoft_oauth_state"] = state
+	if err := session.Save(r, w); err != nil {
+		logrus.Errorf("Failed to save session: %v", err)
+		http.Error(w, "Failed to save session", http.StatusInternalServerError)
+		return
+	}
+
+	// Microsoft returns a refresh token for the offline_access scope
+	oauthConfig := services.NewMicrosoftOAuthConfigWithRequest(r)
+	http.Redirect(w, r, oauthConfig.AuthCodeURL(state, oauth2.SetAuthURLParam("prompt", "select_account")), http.StatusTemporaryRedirect)
+}
+
+// MicrosoftCallbackHandler links the account Microsoft signed in and syncs it in the background
+func (h *CalendarAccountHandler) MicrosoftCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	// Validate state token (CSRF protection)
+	session, _ := GetSessionStore().Get(r, "auth-session")
+	storedState, ok := session.Values["microsoft_oauth_state"].(string)
+	if !ok || r.URL.Query().Get("state") != storedState {
+		http.Error(w, "Invalid state parameter", http.StatusBadRequest)
+		return
+	}
+	delete(session.Values, "microsoft_oauth_state")
+	session.Save(r, w)
+
+	if errorCode := r.URL.Query().Get("error"); errorCode != "" {
+		logrus.Warnf("Microsoft sign in failed: %s %s", errorCode, r.URL.Query().Get("error_description"))
+		http.Redirect(w, r, "/?calendar_account=failed", http.StatusSeeOther)
+		return
+	}
+
+	oauthConfig := services.NewMicrosoftOAuthConfigWithRequest(r)
+	token, err := oauthConfig.Exchange(r.Context(), r.URL.Query().Get("code"))
+	if err != nil {
+		logrus.Errorf("Failed to exchange Microsoft token: %v", err)
+		http.Error(w, "Failed to exchange token", http.StatusInternalServerError)
+		return
+	}
+
+	account, err := h.accountService.LinkMicrosoftAccount(r.Context(), token)
+	if err != nil {
+		logrus.Errorf("Failed to link Microsoft account: %v", err)
+		http.Error(w, "Failed to link account", http.StatusInternalServerError)
+		return
+	}
+
+	if account.Enabled {
+		go func() {
+			if err := h.accountService.SyncAccount(context.Background(), account.ID); err != nil {
+				logrus.Warnf("Failed to sync linked Microsoft account: %v", err)
+			}
+		}()
+	}
+
+	http.Redirect(w, r, "/?calendar_account=linked", http.StatusSeeOther)
+}
+
+// writeEventError maps the errors of the event routes to status codes
+func (h *CalendarAccountHandler) writeEventError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case errors.Is(err, services.ErrCalendarAccountNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrEventConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, services.ErrInvalidAccountEvent):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrEventReadOnly):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		logrus.Warnf("Failed to %s calendar account event: %v", action, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	}
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"woodhome-webapp/internal/models"
+	"woodhome-webapp/internal/services"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// CalendarDigestHandler handles HTTP requests for the daily agenda email
+type CalendarDigestHandler struct {
+	digestService *services.CalendarDigestService
+	location      *time.Location
+}
+
+// NewCalendarDigestHandler creates a new CalendarDigestHandler. Preview dates are read in
+// location, the home time zone.
+func NewCalendarDigestHandler(digestService *services.CalendarDigestService, location *time.Location) *CalendarDigestHandler {
+	return &CalendarDigestHandler{
+		digestService: digestService,
+		location:      location,
+	}
+}
+
+// RegisterRoutes registers all digest routes
+func (h *CalendarDigestHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("", h.GetSettings).Methods("GET")
+	router.HandleFunc("", h.UpdateSettings).Methods("PUT")
+	router.HandleFunc("/preview", h.Preview).Methods("GET")
+	router.HandleFunc("/send", h.Send).Methods("POST")
+}
+
+// authenticated checks the session like the other calendar routes
+func (h *CalendarDigestHandler) authenticated(w http.ResponseWriter, r *http.Request) bool {
+	session, _ := GetSessionStore().Get(r, "auth-session")
+	authenticated, ok := session.Values["oauth_authenticated"].(bool)
+	if !ok || !authenticated {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// GetSettings returns the schedule, recipients and calendars of the digest
+func (h *CalendarDigestHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.digestService.GetSettings())
+}
+
+// UpdateSettings replaces the schedule, recipients and calendars of the digest
+func (h *CalendarDigestHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	var settings models.CalendarDigestSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := h.digestService.UpdateSettings(settings)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "success",
+		"settings": updated,
+	})
+}
+
+// Preview renders the digest in the browser. The optional date query parameter (YYYY-MM-DD)
+// previews another day than today.
+func (h *CalendarDigestHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	day := time.Now()
+	if date := r.URL.Query().Get("date"); date != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", date, h.location)
+		if err != nil {
+			http.Error(w, "Invalid date, use YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		day = parsed
+	}
+
+	body, err := h.digestService.Preview(r.Context(), day)
+	if err != nil {
+		logrus.Errorf("Failed to render calendar digest: %v", err)
+		http.Error(w, "Failed to render digest", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(body))
+}
+
+// Send emails the digest of today right away
+func (h *CalendarDigestHandler) Send(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	if err := h.digestService.Send(r.Context()); err != nil {
+		logrus.Warnf("Failed to send calendar digest: %v", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "success",
+		"settings": h.digestService.GetSettings(),
+	})
+}
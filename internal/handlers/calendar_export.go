@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"woodhome-webapp/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+// SetExportService enables the ICS export and subscription links
+func (h *CalendarHandler) SetExportService(calendarExportService *services.CalendarExportService) {
+	h.calendarExportService = calendarExportService
+}
+
+// RegisterSubscriptionRoutes registers the subscription links, which authenticate with the
+// token in the URL instead of the session as calendar apps cannot sign in
+func (h *CalendarHandler) RegisterSubscriptionRoutes(router *mux.Router) {
+	router.HandleFunc("/{token}.ics", h.SubscriptionFeedHandler).Methods("GET")
+}
+
+// ExportHandler returns the signed in user's events as an .ics file. The range defaults to
+// the export window and can be narrowed with start, end and calendars like /events.
+func (h *CalendarHandler) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := GetSessionStore().Get(r, "auth-session")
+	authenticated, ok := session.Values["oauth_authenticated"].(bool)
+	if !ok || !authenticated {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(int)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusUnauthorized)
+		return
+	}
+
+	start, end := h.calendarExportService.Range()
+	if startStr := r.URL.Query().Get("start"); startStr != "" {
+		parsed, err := time.Parse(time.RFC3339, startStr)
+		if err != nil {
+			http.Error(w, "Invalid start date", http.StatusBadRequest)
+			return
+		}
+		start = parsed
+	}
+	if endStr := r.URL.Query().Get("end"); endStr != "" {
+		parsed, err := time.Parse(time.RFC3339, endStr)
+		if err != nil {
+			http.Error(w, "Invalid end date", http.StatusBadRequest)
+			return
+		}
+		end = parsed
+	}
+
+	var selectedCalendars []string
+	if calendarIDs := r.URL.Query().Get("calendars"); calendarIDs != "" {
+		for _, id := range strings.Split(calendarIDs, ",") {
+			selectedCalendars = append(selectedCalendars, strings.TrimSpace(id))
+		}
+	}
+
+	h.writeCalendar(w, r, userID, start, end, selectedCalendars, "attachment")
+}
+
+// SubscriptionFeedHandler serves the family calendar to a subscription link
+func (h *CalendarHandler) SubscriptionFeedHandler(w http.ResponseWriter, r *http.Request) {
+	subscription, ok := h.calendarExportService.Authenticate(mux.Vars(r)["token"])
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	start, end := h.calendarExportService.Range()
+	h.writeCalendar(w, r, subscription.UserID, start, end, subscription.Calendars, "inline")
+}
+
+// writeCalendar collects a user's events and writes them as iCalendar
+func (h *CalendarHandler) writeCalendar(w http.ResponseWriter, r *http.Request, userID int, start, end time.Time, selectedCalendars []string, disposition string) {
+	events, err := h.collectEvents(r.Context(), userID, start, end, selectedCalendars)
+	if errors.Is(err, errCalendarTokenNotFound) {
+		http.Error(w, "Token not found", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to fetch calendar events for export: %v", err)
+		http.Error(w, "Failed to fetch events", http.StatusInternalServerError)
+		return
+	}
+
+	// Rendered before writing the header so a failure can still be reported
+	var buf bytes.Buffer
+	if err := h.calendarExportService.Write(&buf, events); err != nil {
+		log.Printf("Failed to render calendar export: %v", err)
+		http.Error(w, "Failed to render calendar", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", disposition+`; filename="woodhome.ics"`)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(buf.Bytes())
+}
+
+// GetSubscriptionsHandler lists the signed in user's subscription links
+func (h *CalendarHandler) GetSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := GetSessionStore().Get(r, "auth-session")
+	authenticated, ok := session.Values["oauth_authenticated"].(bool)
+	if !ok || !authenticated {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(int)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusUnauthorized)
+		return
+	}
+
+	subscriptions := h.calendarExportService.GetSubscriptions(userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"subscriptions": subscriptions,
+		"count":         len(subscriptions),
+	})
+}
+
+// CreateSubscriptionHandler creates a subscription link. Its URL holds the secret token and is
+// only returned here.
+func (h *CalendarHandler) CreateSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := GetSessionStore().Get(r, "auth-session")
+	authenticated, ok := session.Values["oauth_authenticated"].(bool)
+	if !ok || !authenticated {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(int)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusUnauthorized)
+		return
+	}
+
+	var request struct {
+		Name      string   `json:"name"`
+		Calendars []string `json:"calendars"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	subscription, token, err := h.calendarExportService.CreateSubscription(userID, request.Name, request.Calendars)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	path := "/calendar/subscribe/" + token + ".ics"
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":       "success",
+		"subscription": subscription,
+		"url":          scheme + "://" + r.Host + path,
+		"webcal_url":   "webcal://" + r.Host + path,
+	})
+}
+
+// DeleteSubscriptionHandler revokes a subscription link
+func (h *CalendarHandler) DeleteSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := GetSessionStore().Get(r, "auth-session")
+	authenticated, ok := session.Values["oauth_authenticated"].(bool)
+	if !ok || !authenticated {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(int)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusUnauthorized)
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if _, exists := h.calendarExportService.GetSubscription(userID, id); !exists {
+		http.Error(w, "Subscription not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.calendarExportService.RevokeSubscription(userID, id); err != nil {
+		log.Printf("Failed to revoke calendar subscription %s: %v", id, err)
+		http.Error(w, "Failed to revoke subscription", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"woodhome-webapp/internal/models"
+	"woodhome-webapp/internal/services"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// CalendarFeedHandler handles HTTP requests for ICS feed subscriptions
+type CalendarFeedHandler struct {
+	feedService *services.CalendarFeedService
+}
+
+// NewCalendarFeedHandler creates a new CalendarFeedHandler
+func NewCalendarFeedHandler(feedService *services.CalendarFeedService) *CalendarFeedHandler {
+	return &CalendarFeedHandler{
+		feedService: feedService,
+	}
+}
+
+// RegisterRoutes registers all feed routes
+func (h *CalendarFeedHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("", h.GetFeeds).Methods("GET")
+	router.HandleFunc("", h.CreateFeed).Methods("POST")
+	router.HandleFunc("/{id}", h.GetFeed).Methods("GET")
+	router.HandleFunc("/{id}", h.UpdateFeed).Methods("PUT")
+	router.HandleFunc("/{id}", h.DeleteFeed).Methods("DELETE")
+	router.HandleFunc("/{id}/refresh", h.RefreshFeed).Methods("POST")
+}
+
+// authenticated checks the session like the other calendar routes
+func (h *CalendarFeedHandler) authenticated(w http.ResponseWriter, r *http.Request) bool {
+	session, _ := GetSessionStore().Get(r, "auth-session")
+	authenticated, ok := session.Values["oauth_authenticated"].(bool)
+	if !ok || !authenticated {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// GetFeeds returns every feed
+func (h *CalendarFeedHandler) GetFeeds(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	feeds := h.feedService.GetFeeds()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"feeds": feeds,
+		"count": len(feeds),
+	})
+}
+
+// GetFeed returns a single feed
+func (h *CalendarFeedHandler) GetFeed(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	feed, exists := h.feedService.GetFeed(mux.Vars(r)["id"])
+	if !exists {
+		http.Error(w, "Feed not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(feed)
+}
+
+// CreateFeed subscribes to a feed and fetches it in the background
+func (h *CalendarFeedHandler) CreateFeed(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	var feed models.CalendarFeed
+	if err := json.NewDecoder(r.Body).Decode(&feed); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.feedService.CreateFeed(feed)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if created.Enabled {
+		go func() {
+			if _, err := h.feedService.Refresh(context.Background(), created.ID); err != nil {
+				logrus.Warnf("Failed to fetch new calendar feed: %v", err)
+			}
+		}()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"feed":   created,
+	})
+}
+
+// UpdateFeed replaces the settings of a feed
+func (h *CalendarFeedHandler) UpdateFeed(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if _, exists := h.feedService.GetFeed(id); !exists {
+		http.Error(w, "Feed not found", http.StatusNotFound)
+		return
+	}
+
+	var feed models.CalendarFeed
+	if err := json.NewDecoder(r.Body).Decode(&feed); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := h.feedService.UpdateFeed(id, feed)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"feed":   updated,
+	})
+}
+
+// DeleteFeed unsubscribes from a feed
+func (h *CalendarFeedHandler) DeleteFeed(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if _, exists := h.feedService.GetFeed(id); !exists {
+		http.Error(w, "Feed not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.feedService.DeleteFeed(id); err != nil {
+		logrus.Errorf("Failed to delete calendar feed %s: %v", id, err)
+		http.Error(w, "Failed to delete feed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// RefreshFeed fetches a feed right away
+func (h *CalendarFeedHandler) RefreshFeed(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if _, exists := h.feedService.GetFeed(id); !exists {
+		http.Error(w, "Feed not found", http.StatusNotFound)
+		return
+	}
+
+	feed, err := h.feedService.Refresh(r.Context(), id)
+	if err != nil {
+		logrus.Warnf("Failed to refresh calendar feed %s: %v", id, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"feed":   feed,
+	})
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"woodhome-webapp/internal/models"
+	"woodhome-webapp/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+// SetHouseholdService merges the household calendars kept in WoodHome into the Google events
+// and enables creating, changing and deleting their events
+func (h *CalendarHandler) SetHouseholdService(householdService *services.HouseholdCalendarService) {
+	h.householdService = householdService
+}
+
+// householdAuthenticated checks the session like the other calendar routes
+func householdAuthenticated(w http.ResponseWriter, r *http.Request) bool {
+	session, _ := GetSessionStore().Get(r, "auth-session")
+	authenticated, ok := session.Values["oauth_authenticated"].(bool)
+	if !ok || !authenticated {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// CreateEventHandler adds an event to a household calendar. Google events are read only on
+// the dashboard, so events posted to the primary calendar, as the event modal does, go to the
+// default household calendar. The event is returned the way /events lists it.
+func (h *CalendarHandler) CreateEventHandler(w http.ResponseWriter, r *http.Request) {
+	if !householdAuthenticated(w, r) {
+		return
+	}
+
+	var input models.HouseholdEventInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if input.CalendarID != "" && input.CalendarID != "primary" && !services.IsHouseholdID(input.CalendarID) {
+		http.Error(w, "Events can only be created in household calendars", http.StatusBadRequest)
+		return
+	}
+
+	event, err := h.householdService.CreateEvent(input)
+	if err != nil {
+		writeHouseholdError(w, "create", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(event)
+}
+
+// UpdateEventHandler changes a household event. Changing one occurrence of a recurring event
+// changes the whole series.
+func (h *CalendarHandler) UpdateEventHandler(w http.ResponseWriter, r *http.Request) {
+	if !householdAuthenticated(w, r) {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if !services.IsHouseholdID(id) {
+		http.Error(w, "Only household calendar events can be changed here", http.StatusBadRequest)
+		return
+	}
+
+	var input models.HouseholdEventInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	event, err := h.householdService.UpdateEvent(id, input)
+	if err != nil {
+		writeHouseholdError(w, "update", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
+}
+
+// DeleteEventHandler removes a household event. Deleting one occurrence of a recurring event
+// removes only that occurrence, unless series=true is given.
+func (h *CalendarHandler) DeleteEventHandler(w http.ResponseWriter, r *http.Request) {
+	if !householdAuthenticated(w, r) {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if !services.IsHouseholdID(id) {
+		http.Error(w, "Only household calendar events can be deleted here", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.householdService.DeleteEvent(id, r.URL.Query().Get("series") == "true"); err != nil {
+		writeHouseholdError(w, "delete", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// GetHouseholdCalendarsHandler returns the household calendars
+func (h *CalendarHandler) GetHouseholdCalendarsHandler(w http.ResponseWriter, r *http.Request) {
+	if !householdAuthenticated(w, r) {
+		return
+	}
+
+	calendars := h.householdService.GetCalendarList()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"calendars": calendars,
+		"count":     len(calendars),
+	})
+}
+
+// CreateHouseholdCalendarHandler adds a household calendar
+func (h *CalendarHandler) CreateHouseholdCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	if !householdAuthenticated(w, r) {
+		return
+	}
+
+	var cal models.HouseholdCalendar
+	if err := json.NewDecoder(r.Body).Decode(&cal); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.householdService.CreateCalendar(cal)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "success",
+		"calendar": created,
+	})
+}
+
+// UpdateHouseholdCalendarHandler renames or recolors a household calendar, or makes it the
+// default one
+func (h *CalendarHandler) UpdateHouseholdCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	if !householdAuthenticated(w, r) {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if _, exists := h.householdService.GetCalendar(id); !exists {
+		http.Error(w, "Calendar not found", http.StatusNotFound)
+		return
+	}
+
+	var cal models.HouseholdCalendar
+	if err := json.NewDecoder(r.Body).Decode(&cal); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := h.householdService.UpdateCalendar(id, cal)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "success",
+		"calendar": updated,
+	})
+}
+
+// DeleteHouseholdCalendarHandler removes a household calendar with its events
+func (h *CalendarHandler) DeleteHouseholdCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	if !householdAuthenticated(w, r) {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	cal, exists := h.householdService.GetCalendar(id)
+	if !exists {
+		http.Error(w, "Calendar not found", http.StatusNotFound)
+		return
+	}
+	if cal.Default {
+		http.Error(w, "The default calendar cannot be deleted", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.householdService.DeleteCalendar(id); err != nil {
+		log.Printf("Failed to delete household calendar %s: %v", id, err)
+		http.Error(w, "Failed to delete calendar", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// writeHouseholdError maps a household event error to a status
+func writeHouseholdError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case errors.Is(err, services.ErrHouseholdNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidHouseholdEvent):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Failed to %s household event: %v", action, err)
+		http.Error(w, "Failed to "+action+" event", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"woodhome-webapp/internal/models"
+	"woodhome-webapp/internal/services"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// CalendarReminderHandler handles HTTP requests for calendar reminders delivered to the home
+type CalendarReminderHandler struct {
+	reminderService *services.CalendarReminderService
+}
+
+// NewCalendarReminderHandler creates a new CalendarReminderHandler
+func NewCalendarReminderHandler(reminderService *services.CalendarReminderService) *CalendarReminderHandler {
+	return &CalendarReminderHandler{
+		reminderService: reminderService,
+	}
+}
+
+// RegisterRoutes registers all reminder routes
+func (h *CalendarReminderHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("", h.GetRules).Methods("GET")
+	router.HandleFunc("/deliveries", h.GetDeliveries).Methods("GET")
+	router.HandleFunc("/{calendarID}", h.GetRule).Methods("GET")
+	router.HandleFunc("/{calendarID}", h.SetRule).Methods("PUT")
+	router.HandleFunc("/{calendarID}", h.DeleteRule).Methods("DELETE")
+	router.HandleFunc("/{calendarID}/test", h.TestRule).Methods("POST")
+}
+
+// authenticated checks the session like the other calendar routes
+func (h *CalendarReminderHandler) authenticated(w http.ResponseWriter, r *http.Request) bool {
+	session, _ := GetSessionStore().Get(r, "auth-session")
+	authenticated, ok := session.Values["oauth_authenticated"].(bool)
+	if !ok || !authenticated {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// GetRules returns the reminder rule of every calendar
+func (h *CalendarReminderHandler) GetRules(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	rules := h.reminderService.GetRules()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"rules": rules,
+		"count": len(rules),
+	})
+}
+
+// GetDeliveries returns the reminders delivered, failed or missed, the latest first
+func (h *CalendarReminderHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	reminders := h.reminderService.GetReminders()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reminders": reminders,
+		"count":     len(reminders),
+	})
+}
+
+// GetRule returns the reminder rule of a calendar
+func (h *CalendarReminderHandler) GetRule(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	rule, exists := h.reminderService.GetRule(mux.Vars(r)["calendarID"])
+	if !exists {
+		http.Error(w, "Rule not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rule)
+}
+
+// SetRule adds or replaces the reminder rule of a calendar
+func (h *CalendarReminderHandler) SetRule(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	var rule models.CalendarReminderRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	rule.CalendarID = mux.Vars(r)["calendarID"]
+
+	saved, err := h.reminderService.SetRule(rule)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"rule":   saved,
+	})
+}
+
+// DeleteRule stops reminding of a calendar
+func (h *CalendarReminderHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	calendarID := mux.Vars(r)["calendarID"]
+	if err := h.reminderService.DeleteRule(calendarID); err != nil {
+		if errors.Is(err, services.ErrReminderRuleNotFound) {
+			http.Error(w, "Rule not found", http.StatusNotFound)
+			return
+		}
+		logrus.Errorf("Failed to delete reminder rule of %s: %v", calendarID, err)
+		http.Error(w, "Failed to delete rule", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// TestRule delivers a sample reminder the way the rule of a calendar would
+func (h *CalendarReminderHandler) TestRule(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	calendarID := mux.Vars(r)["calendarID"]
+	if _, exists := h.reminderService.GetRule(calendarID); !exists {
+		http.Error(w, "Rule not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.reminderService.TestRule(r.Context(), calendarID); err != nil {
+		logrus.Warnf("Failed to deliver test reminder of %s: %v", calendarID, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
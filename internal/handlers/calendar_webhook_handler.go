@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+
+	"woodhome-webapp/internal/models"
+	"woodhome-webapp/internal/services"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// CalendarWebhookHandler receives Google Calendar push notifications. Google does not sign
+// in with a session, so each notification is checked against the channel ID and token
+// given to Google when the channel was opened.
+type CalendarWebhookHandler struct {
+	watchService *services.CalendarWatchService
+}
+
+// NewCalendarWebhookHandler creates a new CalendarWebhookHandler
+func NewCalendarWebhookHandler(watchService *services.CalendarWatchService) *CalendarWebhookHandler {
+	return &CalendarWebhookHandler{
+		watchService: watchService,
+	}
+}
+
+// RegisterRoutes registers the notification receiver
+func (h *CalendarWebhookHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("", h.ReceiveNotification).Methods("POST")
+}
+
+// ReceiveNotification handles a notification. The refresh it triggers runs in the background
+// so Google gets its answer right away.
+func (h *CalendarWebhookHandler) ReceiveNotification(w http.ResponseWriter, r *http.Request) {
+	notification := models.CalendarWatchNotification{
+		ChannelID:     r.Header.Get("X-Goog-Channel-ID"),
+		Token:         r.Header.Get("X-Goog-Channel-Token"),
+		ResourceID:    r.Header.Get("X-Goog-Resource-ID"),
+		ResourceState: r.Header.Get("X-Goog-Resource-State"),
+		MessageNumber: r.Header.Get("X-Goog-Message-Number"),
+	}
+
+	if notification.ChannelID == "" || notification.ResourceState == "" {
+		http.Error(w, "Missing channel headers", http.StatusBadRequest)
+		return
+	}
+
+	if !h.watchService.HasChannel(notification.ChannelID) {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.watchService.Notify(notification); err != nil {
+		logrus.Warnf("Rejected calendar notification: %v", err)
+		http.Error(w, "Invalid channel token", http.StatusForbidden)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"woodhome-webapp/internal/services"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// eventKeepAliveInterval keeps idle event streams open through proxies
+const eventKeepAliveInterval = 30 * time.Second
+
+// EventHandler streams home events to the dashboard
+type EventHandler struct {
+	eventBus *services.EventBus
+}
+
+// NewEventHandler creates a new EventHandler instance
+func NewEventHandler(eventBus *services.EventBus) *EventHandler {
+	return &EventHandler{
+		eventBus: eventBus,
+	}
+}
+
+// RegisterRoutes registers all event routes
+func (h *EventHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("", h.StreamEvents).Methods("GET")
+}
+
+// StreamEvents streams events as server-sent events.
+// The optional types query parameter takes a comma-separated list of event type prefixes, e.g. ?types=hue.motion,hue.button
+func (h *EventHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	var prefixes []string
+	if types := r.URL.Query().Get("types"); types != "" {
+		prefixes = strings.Split(types, ",")
+	}
+
+	// Event streams outlive the server write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		logrus.Warnf("Failed to clear write deadline for event stream: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	events, unsubscribe := h.eventBus.Subscribe()
+	defer unsubscribe()
+
+	keepAlive := time.NewTicker(eventKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if !matchesEventType(event.Type, prefixes) {
+				continue
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				logrus.Errorf("Failed to encode %s event: %v", event.Type, err)
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// matchesEventType reports whether an event type starts with one of the requested prefixes
+func matchesEventType(eventType string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(eventType, strings.TrimSpace(prefix)) {
+			return true
+		}
+	}
+	return false
+}
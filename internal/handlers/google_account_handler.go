@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"woodhome-webapp/internal/services"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+// GoogleAccountHandler handles HTTP requests for the Google accounts linked to the household
+type GoogleAccountHandler struct {
+	accountService *services.GoogleAccountService
+}
+
+// NewGoogleAccountHandler creates a new GoogleAccountHandler
+func NewGoogleAccountHandler(accountService *services.GoogleAccountService) *GoogleAccountHandler {
+	return &GoogleAccountHandler{
+		accountService: accountService,
+	}
+}
+
+// RegisterRoutes registers all Google account routes
+func (h *GoogleAccountHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("", h.GetAccounts).Methods("GET")
+	router.HandleFunc("/link", h.LinkHandler).Methods("GET")
+	router.HandleFunc("/{id}", h.UnlinkAccount).Methods("DELETE")
+}
+
+// authenticated checks the session like the other calendar routes
+func (h *GoogleAccountHandler) authenticated(w http.ResponseWriter, r *http.Request) bool {
+	session, _ := GetSessionStore().Get(r, "auth-session")
+	authenticated, ok := session.Values["oauth_authenticated"].(bool)
+	if !ok || !authenticated {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// GetAccounts returns every linked Google account
+func (h *GoogleAccountHandler) GetAccounts(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	accounts := h.accountService.GetAccounts()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"accounts": accounts,
+		"count":    len(accounts),
+	})
+}
+
+// UnlinkAccount removes a Google account and its token. The primary account cannot be unlinked.
+func (h *GoogleAccountHandler) UnlinkAccount(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+	account, exists := h.accountService.GetAccount(userID)
+	if !exists {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+	if account.Primary {
+		http.Error(w, "The primary Google account cannot be unlinked", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.accountService.UnlinkAccount(userID); err != nil {
+		if errors.Is(err, services.ErrGoogleAccountNotFound) {
+			http.Error(w, "Account not found", http.StatusNotFound)
+			return
+		}
+		logrus.Errorf("Failed to unlink Google account %d: %v", userID, err)
+		http.Error(w, "Failed to unlink account", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// LinkHandler sends a signed in user to Google to link another account. Google returns to
+// the usual login callback, which links the account without changing the signed in user.
+func (h *GoogleAccountHandler) LinkHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+
+	// Generate state token for CSRF protection
+	state, err := services.GenerateStateToken()
+	if err != nil {
+		http.Error(w, "Failed to generate state token", http.StatusInternalServerError)
+		return
+	}
+
+	session, _ := GetSessionStore().Get(r, "auth-session")
+	session.Values["oauth_state"] = state
+	session.Values["google_link"] = true
+	if err := session.Save(r, w); err != nil {
+		logrus.Errorf("Failed to save session: %v", err)
+		http.Error(w, "Failed to save session", http.StatusInternalServerError)
+		return
+	}
+
+	// Let the user pick another account, and ask again so Google returns a refresh token
+	oauthConfig := services.NewGoogleOAuthConfigWithRequest(r)
+	url := oauthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.SetAuthURLParam("prompt", "select_account consent"))
+	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"woodhome-webapp/internal/models"
+	"woodhome-webapp/internal/services"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// HomeRoutineHandler handles HTTP requests for routines of lights and audio
+type HomeRoutineHandler struct {
+	routineService *services.HomeRoutineService
+}
+
+// NewHomeRoutineHandler creates a new HomeRoutineHandler
+func NewHomeRoutineHandler(routineService *services.HomeRoutineService) *HomeRoutineHandler {
+	return &HomeRoutineHandler{
+		routineService: routineService,
+	}
+}
+
+// RegisterRoutes registers all home routine routes
+func (h *HomeRoutineHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("", h.GetRoutines).Methods("GET")
+	router.HandleFunc("", h.CreateRoutine).Methods("POST")
+	router.HandleFunc("/runs", h.GetRuns).Methods("GET")
+	router.HandleFunc("/runs/{run}", h.GetRun).Methods("GET")
+	router.HandleFunc("/runs/{run}/cancel", h.CancelRun).Methods("POST")
+	router.HandleFunc("/{id}", h.GetRoutine).Methods("GET")
+	router.HandleFunc("/{id}", h.UpdateRoutine).Methods("PUT")
+	router.HandleFunc("/{id}", h.DeleteRoutine).Methods("DELETE")
+	router.HandleFunc("/{id}/run", h.RunRoutine).Methods("POST")
+}
+
+// GetRoutines returns every home routine
+func (h *HomeRoutineHandler) GetRoutines(w http.ResponseWriter, r *http.Request) {
+	routines := h.routineService.GetRoutines()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"routines": routines,
+		"count":    len(routines),
+	})
+}
+
+// GetRoutine returns a single home routine
+func (h *HomeRoutineHandler) GetRoutine(w http.ResponseWriter, r *http.Request) {
+	routine, exists := h.routineService.GetRoutine(mux.Vars(r)["id"])
+	if !exists {
+		http.Error(w, "Routine not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(routine)
+}
+
+// CreateRoutine adds a home routine
+func (h *HomeRoutineHandler) CreateRoutine(w http.ResponseWriter, r *http.Request) {
+	var routine models.HomeRoutine
+	if err := json.NewDecoder(r.Body).Decode(&routine); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.routineService.CreateRoutine(routine)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"routine": created,
+	})
+}
+
+// UpdateRoutine replaces a home routine
+func (h *HomeRoutineHandler) UpdateRoutine(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if _, exists := h.routineService.GetRoutine(id); !exists {
+		http.Error(w, "Routine not found", http.StatusNotFound)
+		return
+	}
+
+	var routine models.HomeRoutine
+	if err := json.NewDecoder(r.Body).Decode(&routine); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := h.routineService.UpdateRoutine(id, routine)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"routine": updated,
+	})
+}
+
+// DeleteRoutine removes a home routine
+func (h *HomeRoutineHandler) DeleteRoutine(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if _, exists := h.routineService.GetRoutine(id); !exists {
+		http.Error(w, "Routine not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.routineService.DeleteRoutine(id); err != nil {
+		logrus.Errorf("Failed to delete home routine %s: %v", id, err)
+		http.Error(w, "Failed to delete routine", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// RunRoutine starts a routine in the background and returns its run for progress polling
+func (h *HomeRoutineHandler) RunRoutine(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if _, exists := h.routineService.GetRoutine(id); !exists {
+		http.Error(w, "Routine not found", http.StatusNotFound)
+		return
+	}
+
+	run, err := h.routineService.Run(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"run":    run,
+	})
+}
+
+// GetRuns returns the running and recently finished routine runs
+func (h *HomeRoutineHandler) GetRuns(w http.ResponseWriter, r *http.Request) {
+	runs := h.routineService.GetRuns()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"runs":  runs,
+		"count": len(runs),
+	})
+}
+
+// GetRun returns the progress of a single run
+func (h *HomeRoutineHandler) GetRun(w http.ResponseWriter, r *http.Request) {
+	run, exists := h.routineService.GetRun(mux.Vars(r)["run"])
+	if !exists {
+		http.Error(w, "Run not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(run)
+}
+
+// CancelRun stops a running routine
+func (h *HomeRoutineHandler) CancelRun(w http.ResponseWriter, r *http.Request) {
+	runID := mux.Vars(r)["run"]
+
+	if _, exists := h.routineService.GetRun(runID); !exists {
+		http.Error(w, "Run not found", http.StatusNotFound)
+		return
+	}
+
+	run, err := h.routineService.Cancel(runID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"run":    run,
+	})
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"woodhome-webapp/internal/models"
+	"woodhome-webapp/internal/services"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// HomeSceneHandler handles HTTP requests for scenes combining lights and audio
+type HomeSceneHandler struct {
+	sceneService *services.HomeSceneService
+}
+
+// NewHomeSceneHandler creates a new HomeSceneHandler
+func NewHomeSceneHandler(sceneService *services.HomeSceneService) *HomeSceneHandler {
+	return &HomeSceneHandler{
+		sceneService: sceneService,
+	}
+}
+
+// RegisterRoutes registers all home scene routes
+func (h *HomeSceneHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("", h.GetScenes).Methods("GET")
+	router.HandleFunc("", h.CreateScene).Methods("POST")
+	router.HandleFunc("/{id}", h.GetScene).Methods("GET")
+	router.HandleFunc("/{id}", h.UpdateScene).Methods("PUT")
+	router.HandleFunc("/{id}", h.DeleteScene).Methods("DELETE")
+	router.HandleFunc("/{id}/activate", h.ActivateScene).Methods("POST")
+	router.HandleFunc("/{id}/end", h.EndScene).Methods("POST")
+	router.HandleFunc("/{id}/activation", h.GetActivation).Methods("GET")
+}
+
+// GetScenes returns every home scene
+func (h *HomeSceneHandler) GetScenes(w http.ResponseWriter, r *http.Request) {
+	scenes := h.sceneService.GetScenes()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"scenes": scenes,
+		"count":  len(scenes),
+	})
+}
+
+// GetScene returns a single home scene
+func (h *HomeSceneHandler) GetScene(w http.ResponseWriter, r *http.Request) {
+	scene, exists := h.sceneService.GetScene(mux.Vars(r)["id"])
+	if !exists {
+		http.Error(w, "Scene not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scene)
+}
+
+// CreateScene adds a home scene
+func (h *HomeSceneHandler) CreateScene(w http.ResponseWriter, r *http.Request) {
+	var scene models.HomeScene
+	if err := json.NewDecoder(r.Body).Decode(&scene); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.sceneService.CreateScene(scene)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"scene":  created,
+	})
+}
+
+// UpdateScene replaces a home scene
+func (h *HomeSceneHandler) UpdateScene(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if _, exists := h.sceneService.GetScene(id); !exists {
+		http.Error(w, "Scene not found", http.StatusNotFound)
+		return
+	}
+
+	var scene models.HomeScene
+	if err := json.NewDecoder(r.Body).Decode(&scene); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := h.sceneService.UpdateScene(id, scene)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"scene":  updated,
+	})
+}
+
+// DeleteScene removes a home scene
+func (h *HomeSceneHandler) DeleteScene(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if _, exists := h.sceneService.GetScene(id); !exists {
+		http.Error(w, "Scene not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.sceneService.DeleteScene(id); err != nil {
+		logrus.Errorf("Failed to delete home scene %s: %v", id, err)
+		http.Error(w, "Failed to delete scene", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// ActivateScene runs the steps of a scene and returns the result of each step
+func (h *HomeSceneHandler) ActivateScene(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if _, exists := h.sceneService.GetScene(id); !exists {
+		http.Error(w, "Scene not found", http.StatusNotFound)
+		return
+	}
+
+	activation, err := h.sceneService.Activate(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":     "success",
+		"activation": activation,
+	})
+}
+
+// EndScene puts back the state saved when the scene was activated
+func (h *HomeSceneHandler) EndScene(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if _, exists := h.sceneService.GetScene(id); !exists {
+		http.Error(w, "Scene not found", http.StatusNotFound)
+		return
+	}
+
+	activation, err := h.sceneService.End(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":     "success",
+		"activation": activation,
+	})
+}
+
+// GetActivation returns the last activation of a scene
+func (h *HomeSceneHandler) GetActivation(w http.ResponseWriter, r *http.Request) {
+	activation, exists := h.sceneService.GetActivation(mux.Vars(r)["id"])
+	if !exists {
+		http.Error(w, "Scene has not been activated", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(activation)
+}
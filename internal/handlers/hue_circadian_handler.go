@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"woodhome-webapp/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+// HueCircadianHandler handles HTTP requests for the circadian lighting mode
+type HueCircadianHandler struct {
+	circadianService *services.HueCircadianService
+}
+
+// NewHueCircadianHandler creates a new HueCircadianHandler
+func NewHueCircadianHandler(circadianService *services.HueCircadianService) *HueCircadianHandler {
+	return &HueCircadianHandler{
+		circadianService: circadianService,
+	}
+}
+
+// RegisterRoutes registers all circadian lighting routes
+func (h *HueCircadianHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("", h.GetStatus).Methods("GET")
+	router.HandleFunc("/{room}", h.SetRoom).Methods("PUT")
+	router.HandleFunc("/{room}", h.RemoveRoom).Methods("DELETE")
+	router.HandleFunc("/{room}/resume", h.Resume).Methods("POST")
+}
+
+// GetStatus returns the current circadian target and the state of every room
+func (h *HueCircadianHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.circadianService.GetStatus())
+}
+
+// SetRoom enables or disables the circadian mode for a room
+func (h *HueCircadianHandler) SetRoom(w http.ResponseWriter, r *http.Request) {
+	roomID := mux.Vars(r)["room"]
+
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	room, err := h.circadianService.SetRoom(roomID, req.Enabled)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"room":   room,
+	})
+}
+
+// RemoveRoom stops a room from following the sun
+func (h *HueCircadianHandler) RemoveRoom(w http.ResponseWriter, r *http.Request) {
+	roomID := mux.Vars(r)["room"]
+
+	removed, err := h.circadianService.RemoveRoom(roomID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// Resume lifts a manual-change pause of a room
+func (h *HueCircadianHandler) Resume(w http.ResponseWriter, r *http.Request) {
+	roomID := mux.Vars(r)["room"]
+
+	if err := h.circadianService.Resume(roomID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
@@ -2,9 +2,10 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
-	"time"
+	"strings"
 
 	"woodhome-webapp/internal/models"
 	"woodhome-webapp/internal/services"
@@ -28,10 +29,48 @@ func NewHueHandler(hueService *services.HueService) *HueHandler {
 // RegisterRoutes registers all Hue routes
 func (h *HueHandler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/lights", h.GetLights).Methods("GET")
+	router.HandleFunc("/lights/search", h.SearchLights).Methods("POST")
+	router.HandleFunc("/lights/new", h.GetLightSearch).Methods("GET")
+	router.HandleFunc("/lights/{id}", h.RenameLight).Methods("PUT")
+	router.HandleFunc("/lights/{id}", h.DeleteLight).Methods("DELETE")
 	router.HandleFunc("/rooms", h.GetRooms).Methods("GET")
+	router.HandleFunc("/rooms", h.CreateGroup).Methods("POST")
+	router.HandleFunc("/rooms/classes", h.GetRoomClasses).Methods("GET")
+	router.HandleFunc("/rooms/{id}", h.UpdateGroup).Methods("PUT")
+	router.HandleFunc("/rooms/{id}", h.DeleteGroup).Methods("DELETE")
+	router.HandleFunc("/rooms/{id}/lights/{lightId}", h.AddLightToGroup).Methods("PUT")
+	router.HandleFunc("/rooms/{id}/lights/{lightId}", h.RemoveLightFromGroup).Methods("DELETE")
+	router.HandleFunc("/zones", h.GetZones).Methods("GET")
+	router.HandleFunc("/zones", h.CreateGroup).Methods("POST")
+	router.HandleFunc("/zones/{id}", h.UpdateGroup).Methods("PUT")
+	router.HandleFunc("/zones/{id}", h.DeleteGroup).Methods("DELETE")
+	router.HandleFunc("/zones/{id}/lights/{lightId}", h.AddLightToGroup).Methods("PUT")
+	router.HandleFunc("/zones/{id}/lights/{lightId}", h.RemoveLightFromGroup).Methods("DELETE")
 	router.HandleFunc("/lights/{id}/toggle", h.ToggleLight).Methods("POST")
 	router.HandleFunc("/rooms/{id}/toggle", h.ToggleGroup).Methods("POST")
 	router.HandleFunc("/groups/{id}/toggle", h.ToggleGroup).Methods("POST")
+	router.HandleFunc("/lights/{id}/color", h.SetLightColor).Methods("PUT")
+	router.HandleFunc("/rooms/{id}/color", h.SetGroupColor).Methods("PUT")
+	router.HandleFunc("/groups/{id}/color", h.SetGroupColor).Methods("PUT")
+	router.HandleFunc("/sensors", h.GetSensors).Methods("GET")
+	router.HandleFunc("/sensors/{id}", h.GetSensor).Methods("GET")
+	router.HandleFunc("/scenes", h.GetScenes).Methods("GET")
+	router.HandleFunc("/scenes", h.CreateScene).Methods("POST")
+	router.HandleFunc("/scenes/{id}", h.GetScene).Methods("GET")
+	router.HandleFunc("/scenes/{id}", h.RenameScene).Methods("PUT")
+	router.HandleFunc("/scenes/{id}", h.DeleteScene).Methods("DELETE")
+	router.HandleFunc("/scenes/{id}/activate", h.ActivateScene).Methods("POST")
+	router.HandleFunc("/scenes/{id}/lights/{lightId}", h.SetSceneLightState).Methods("PUT")
+	router.HandleFunc("/schedules", h.GetSchedules).Methods("GET")
+	router.HandleFunc("/schedules", h.CreateSchedule).Methods("POST")
+	router.HandleFunc("/schedules/{id}", h.GetSchedule).Methods("GET")
+	router.HandleFunc("/schedules/{id}", h.UpdateSchedule).Methods("PUT")
+	router.HandleFunc("/schedules/{id}", h.DeleteSchedule).Methods("DELETE")
+	router.HandleFunc("/rules", h.GetRules).Methods("GET")
+	router.HandleFunc("/rules", h.CreateRule).Methods("POST")
+	router.HandleFunc("/rules/{id}", h.GetRule).Methods("GET")
+	router.HandleFunc("/rules/{id}", h.UpdateRule).Methods("PUT")
+	router.HandleFunc("/rules/{id}", h.DeleteRule).Methods("DELETE")
 }
 
 // GetLights returns all lights
@@ -68,6 +107,207 @@ func (h *HueHandler) GetRooms(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// GetZones returns all zones
+func (h *HueHandler) GetZones(w http.ResponseWriter, r *http.Request) {
+	zones := h.hueService.GetZones()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(zones); err != nil {
+		logrus.Errorf("Failed to encode zones response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetRoomClasses returns the room classes (icons) a room or zone can have
+func (h *HueHandler) GetRoomClasses(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.HueRoomClasses)
+}
+
+// CreateGroup creates a room, or a zone when posted to /zones
+func (h *HueHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	var groupRequest models.HueGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&groupRequest); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	groupRequest.Type = models.HueGroupTypeRoom
+	if strings.HasSuffix(r.URL.Path, "/zones") {
+		groupRequest.Type = models.HueGroupTypeZone
+	}
+
+	if groupRequest.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	group, err := h.hueService.CreateGroup(&groupRequest)
+	if err != nil {
+		logrus.Errorf("Failed to create %s: %v", groupRequest.Type, err)
+		http.Error(w, "Failed to create "+strings.ToLower(groupRequest.Type), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(group)
+}
+
+// UpdateGroup renames a room or zone, changes its class or replaces its lights
+func (h *HueHandler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
+	groupID := mux.Vars(r)["id"]
+
+	var groupRequest models.HueGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&groupRequest); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.hueService.UpdateGroup(groupID, &groupRequest); err != nil {
+		logrus.Errorf("Failed to update group %s: %v", groupID, err)
+		http.Error(w, "Failed to update group", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// DeleteGroup removes a room or zone
+func (h *HueHandler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	groupID := mux.Vars(r)["id"]
+
+	if err := h.hueService.DeleteGroup(groupID); err != nil {
+		logrus.Errorf("Failed to delete group %s: %v", groupID, err)
+		http.Error(w, "Failed to delete group", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// AddLightToGroup moves a light into a room or adds it to a zone
+func (h *HueHandler) AddLightToGroup(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.hueService.AddLightToGroup(vars["id"], vars["lightId"]); err != nil {
+		logrus.Errorf("Failed to add light %s to group %s: %v", vars["lightId"], vars["id"], err)
+		http.Error(w, "Failed to add light to group", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// RemoveLightFromGroup takes a light out of a room or zone
+func (h *HueHandler) RemoveLightFromGroup(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.hueService.RemoveLightFromGroup(vars["id"], vars["lightId"]); err != nil {
+		logrus.Errorf("Failed to remove light %s from group %s: %v", vars["lightId"], vars["id"], err)
+		http.Error(w, "Failed to remove light from group", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// RenameLight changes the name of a light
+func (h *HueHandler) RenameLight(w http.ResponseWriter, r *http.Request) {
+	lightID := mux.Vars(r)["id"]
+
+	var renameRequest struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&renameRequest); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if renameRequest.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.hueService.RenameLight(lightID, renameRequest.Name); err != nil {
+		logrus.Errorf("Failed to rename light %s: %v", lightID, err)
+		http.Error(w, "Failed to rename light", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// DeleteLight removes a light from the bridge
+func (h *HueHandler) DeleteLight(w http.ResponseWriter, r *http.Request) {
+	lightID := mux.Vars(r)["id"]
+
+	if err := h.hueService.DeleteLight(lightID); err != nil {
+		logrus.Errorf("Failed to delete light %s: %v", lightID, err)
+		http.Error(w, "Failed to delete light", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// SearchLights starts a search for new lights
+func (h *HueHandler) SearchLights(w http.ResponseWriter, r *http.Request) {
+	if err := h.hueService.SearchLights(); err != nil {
+		logrus.Errorf("Failed to start light search: %v", err)
+		http.Error(w, "Failed to start light search", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "searching"})
+}
+
+// GetLightSearch reports the lights found by the last search
+func (h *HueHandler) GetLightSearch(w http.ResponseWriter, r *http.Request) {
+	search, err := h.hueService.GetLightSearch()
+	if err != nil {
+		logrus.Errorf("Failed to get light search: %v", err)
+		http.Error(w, "Failed to get light search", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(search)
+}
+
+// GetSensors returns all sensors, optionally filtered by ?kind=motion|temperature|light_level|switch|daylight
+func (h *HueHandler) GetSensors(w http.ResponseWriter, r *http.Request) {
+	sensors := h.hueService.GetSensors(r.URL.Query().Get("kind"))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(sensors); err != nil {
+		logrus.Errorf("Failed to encode sensors response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetSensor returns a single sensor
+func (h *HueHandler) GetSensor(w http.ResponseWriter, r *http.Request) {
+	sensorID := mux.Vars(r)["id"]
+
+	sensor, exists := h.hueService.GetSensor(sensorID)
+	if !exists {
+		http.Error(w, "Sensor not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sensor)
+}
+
 // GetScenes returns all scenes
 func (h *HueHandler) GetScenes(w http.ResponseWriter, r *http.Request) {
 	scenes := h.hueService.GetScenes()
@@ -169,6 +409,111 @@ func (h *HueHandler) ActivateScene(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
 
+// GetScene returns a scene including its per-light states
+func (h *HueHandler) GetScene(w http.ResponseWriter, r *http.Request) {
+	sceneID := mux.Vars(r)["id"]
+
+	scene, err := h.hueService.GetScene(sceneID)
+	if errors.Is(err, services.ErrHueResourceNotFound) {
+		http.Error(w, "Scene not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Failed to get scene %s: %v", sceneID, err)
+		http.Error(w, "Failed to get scene", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scene)
+}
+
+// CreateScene creates a scene from the current state of a room's lights
+func (h *HueHandler) CreateScene(w http.ResponseWriter, r *http.Request) {
+	var sceneRequest models.HueSceneRequest
+	if err := json.NewDecoder(r.Body).Decode(&sceneRequest); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if sceneRequest.Name == "" || sceneRequest.RoomID == "" {
+		http.Error(w, "Scene name and room ID are required", http.StatusBadRequest)
+		return
+	}
+
+	scene, err := h.hueService.CreateSceneFromRoom(sceneRequest.RoomID, sceneRequest.Name)
+	if err != nil {
+		logrus.Errorf("Failed to create scene: %v", err)
+		http.Error(w, "Failed to create scene", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(scene)
+}
+
+// RenameScene changes the name of a scene
+func (h *HueHandler) RenameScene(w http.ResponseWriter, r *http.Request) {
+	sceneID := mux.Vars(r)["id"]
+
+	var sceneRequest models.HueSceneRequest
+	if err := json.NewDecoder(r.Body).Decode(&sceneRequest); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if sceneRequest.Name == "" {
+		http.Error(w, "Scene name is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.hueService.RenameScene(sceneID, sceneRequest.Name); err != nil {
+		logrus.Errorf("Failed to rename scene %s: %v", sceneID, err)
+		http.Error(w, "Failed to rename scene", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// DeleteScene removes a scene from the bridge
+func (h *HueHandler) DeleteScene(w http.ResponseWriter, r *http.Request) {
+	sceneID := mux.Vars(r)["id"]
+
+	if err := h.hueService.DeleteScene(sceneID); err != nil {
+		logrus.Errorf("Failed to delete scene %s: %v", sceneID, err)
+		http.Error(w, "Failed to delete scene", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// SetSceneLightState changes the state a scene stores for one light
+func (h *HueHandler) SetSceneLightState(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sceneID := vars["id"]
+	lightID := vars["lightId"]
+
+	var lightRequest models.HueSceneLightRequest
+	if err := json.NewDecoder(r.Body).Decode(&lightRequest); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.hueService.SetSceneLightState(sceneID, lightID, &lightRequest); err != nil {
+		logrus.Errorf("Failed to update scene %s light %s: %v", sceneID, lightID, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
 // ToggleLight toggles a light on/off
 func (h *HueHandler) ToggleLight(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -179,12 +524,7 @@ func (h *HueHandler) ToggleLight(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Refresh light state from bridge to get current state
-	if err := h.hueService.RefreshLights(); err != nil {
-		logrus.Warnf("Failed to refresh lights before toggle: %v", err)
-	}
-
-	// Get current light state from bridge to determine toggle action
+	// Get the current light state to determine toggle action
 	lights := h.hueService.GetLights()
 	var currentLight *models.HueLight
 	for _, light := range lights {
@@ -229,16 +569,8 @@ func (h *HueHandler) ToggleGroup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get current group state to determine toggle action
-	rooms := h.hueService.GetRooms()
-	var currentRoom *models.HueRoom
-	for _, room := range rooms {
-		if room.ID == groupID {
-			currentRoom = room
-			break
-		}
-	}
-
-	if currentRoom == nil {
+	currentRoom, exists := h.hueService.GetGroup(groupID)
+	if !exists {
 		http.Error(w, "Group not found", http.StatusNotFound)
 		return
 	}
@@ -255,11 +587,6 @@ func (h *HueHandler) ToggleGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Refresh room state from bridge to get current state
-	if err := h.hueService.RefreshRooms(); err != nil {
-		logrus.Warnf("Failed to refresh rooms after toggle: %v", err)
-	}
-
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status": "success",
@@ -357,23 +684,16 @@ func (h *HueHandler) SetLightColor(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var colorRequest struct {
-		Hue        *int      `json:"hue,omitempty"`
-		Saturation *int      `json:"saturation,omitempty"`
-		ColorTemp  *int      `json:"color_temp,omitempty"`
-		XY         []float64 `json:"xy,omitempty"`
-	}
-
+	var colorRequest models.HueColorRequest
 	if err := json.NewDecoder(r.Body).Decode(&colorRequest); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
-	state := &models.HueLightState{
-		Hue:        colorRequest.Hue,
-		Saturation: colorRequest.Saturation,
-		ColorTemp:  colorRequest.ColorTemp,
-		XY:         colorRequest.XY,
+	state, err := h.hueService.LightColorState(lightID, &colorRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	if err := h.hueService.SetLightState(lightID, state); err != nil {
@@ -396,23 +716,16 @@ func (h *HueHandler) SetGroupColor(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var colorRequest struct {
-		Hue        *int      `json:"hue,omitempty"`
-		Saturation *int      `json:"saturation,omitempty"`
-		ColorTemp  *int      `json:"color_temp,omitempty"`
-		XY         []float64 `json:"xy,omitempty"`
-	}
-
+	var colorRequest models.HueColorRequest
 	if err := json.NewDecoder(r.Body).Decode(&colorRequest); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
-	state := &models.HueGroupState{
-		Hue:        colorRequest.Hue,
-		Saturation: colorRequest.Saturation,
-		ColorTemp:  colorRequest.ColorTemp,
-		XY:         colorRequest.XY,
+	state, err := services.GroupColorState(&colorRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	if err := h.hueService.SetGroupState(groupID, state); err != nil {
@@ -445,7 +758,9 @@ func (h *HueHandler) GetSystemStatus(w http.ResponseWriter, r *http.Request) {
 		"scenes": map[string]interface{}{
 			"total": len(scenes),
 		},
-		"last_update": time.Now(),
+		"last_update": h.hueService.LastUpdate(),
+		"refresh":     h.hueService.GetRefreshStatus(),
+		"bridges":     h.hueService.GetBridgeStatuses(),
 	}
 
 	if err != nil {
@@ -483,3 +798,175 @@ func countOfflineLights(lights []*models.HueLight) int {
 	}
 	return count
 }
+
+// GetSchedules returns the schedules stored on the bridge
+func (h *HueHandler) GetSchedules(w http.ResponseWriter, r *http.Request) {
+	schedules, err := h.hueService.GetSchedules()
+	if err != nil {
+		logrus.Errorf("Failed to get schedules: %v", err)
+		http.Error(w, "Failed to get schedules", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"schedules": schedules,
+		"count":     len(schedules),
+	})
+}
+
+// GetSchedule returns a single bridge schedule
+func (h *HueHandler) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	scheduleID := mux.Vars(r)["id"]
+
+	schedule, err := h.hueService.GetSchedule(scheduleID)
+	if err != nil {
+		logrus.Errorf("Failed to get schedule %s: %v", scheduleID, err)
+		http.Error(w, "Failed to get schedule", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedule)
+}
+
+// CreateSchedule stores a new schedule on the bridge
+func (h *HueHandler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	var scheduleRequest models.HueScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&scheduleRequest); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	schedule, err := h.hueService.CreateSchedule(&scheduleRequest)
+	if err != nil {
+		logrus.Errorf("Failed to create schedule: %v", err)
+		http.Error(w, "Failed to create schedule: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(schedule)
+}
+
+// UpdateSchedule changes a bridge schedule
+func (h *HueHandler) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	scheduleID := mux.Vars(r)["id"]
+
+	var scheduleRequest models.HueScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&scheduleRequest); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	schedule, err := h.hueService.UpdateSchedule(scheduleID, &scheduleRequest)
+	if err != nil {
+		logrus.Errorf("Failed to update schedule %s: %v", scheduleID, err)
+		http.Error(w, "Failed to update schedule: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedule)
+}
+
+// DeleteSchedule removes a schedule from the bridge
+func (h *HueHandler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	scheduleID := mux.Vars(r)["id"]
+
+	if err := h.hueService.DeleteSchedule(scheduleID); err != nil {
+		logrus.Errorf("Failed to delete schedule %s: %v", scheduleID, err)
+		http.Error(w, "Failed to delete schedule", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// GetRules returns the rules stored on the bridge
+func (h *HueHandler) GetRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.hueService.GetRules()
+	if err != nil {
+		logrus.Errorf("Failed to get rules: %v", err)
+		http.Error(w, "Failed to get rules", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"rules": rules,
+		"count": len(rules),
+	})
+}
+
+// GetRule returns a single bridge rule
+func (h *HueHandler) GetRule(w http.ResponseWriter, r *http.Request) {
+	ruleID := mux.Vars(r)["id"]
+
+	rule, err := h.hueService.GetRule(ruleID)
+	if err != nil {
+		logrus.Errorf("Failed to get rule %s: %v", ruleID, err)
+		http.Error(w, "Failed to get rule", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rule)
+}
+
+// CreateRule stores a new rule on the bridge
+func (h *HueHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	var ruleRequest models.HueRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&ruleRequest); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	rule, err := h.hueService.CreateRule(&ruleRequest)
+	if err != nil {
+		logrus.Errorf("Failed to create rule: %v", err)
+		http.Error(w, "Failed to create rule: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rule)
+}
+
+// UpdateRule changes a bridge rule
+func (h *HueHandler) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	ruleID := mux.Vars(r)["id"]
+
+	var ruleRequest models.HueRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&ruleRequest); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	rule, err := h.hueService.UpdateRule(ruleID, &ruleRequest)
+	if err != nil {
+		logrus.Errorf("Failed to update rule %s: %v", ruleID, err)
+		http.Error(w, "Failed to update rule: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rule)
+}
+
+// DeleteRule removes a rule from the bridge
+func (h *HueHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	ruleID := mux.Vars(r)["id"]
+
+	if err := h.hueService.DeleteRule(ruleID); err != nil {
+		logrus.Errorf("Failed to delete rule %s: %v", ruleID, err)
+		http.Error(w, "Failed to delete rule", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
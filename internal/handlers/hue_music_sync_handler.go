@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"woodhome-webapp/internal/models"
+	"woodhome-webapp/internal/services"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// HueMusicSyncHandler handles HTTP requests for the lights-follow-music mode
+type HueMusicSyncHandler struct {
+	musicSyncService *services.HueMusicSyncService
+}
+
+// NewHueMusicSyncHandler creates a new HueMusicSyncHandler
+func NewHueMusicSyncHandler(musicSyncService *services.HueMusicSyncService) *HueMusicSyncHandler {
+	return &HueMusicSyncHandler{
+		musicSyncService: musicSyncService,
+	}
+}
+
+// RegisterRoutes registers all music sync routes
+func (h *HueMusicSyncHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("", h.GetStatus).Methods("GET")
+	router.HandleFunc("", h.SetMapping).Methods("POST")
+	router.HandleFunc("/{room}", h.RemoveMapping).Methods("DELETE")
+	router.HandleFunc("/{room}/sync", h.SyncNow).Methods("POST")
+}
+
+// GetStatus returns every music sync mapping with its last applied palette
+func (h *HueMusicSyncHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	statuses := h.musicSyncService.GetStatus()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"mappings": statuses,
+		"count":    len(statuses),
+	})
+}
+
+// SetMapping creates or replaces the mapping for a Sonos room
+func (h *HueMusicSyncHandler) SetMapping(w http.ResponseWriter, r *http.Request) {
+	var mapping models.HueMusicSyncMapping
+	if err := json.NewDecoder(r.Body).Decode(&mapping); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	saved, err := h.musicSyncService.SetMapping(mapping)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"mapping": saved,
+	})
+}
+
+// RemoveMapping stops a Sonos room from driving its Hue room
+func (h *HueMusicSyncHandler) RemoveMapping(w http.ResponseWriter, r *http.Request) {
+	room := mux.Vars(r)["room"]
+
+	if !h.musicSyncService.RemoveMapping(room) {
+		http.Error(w, "Mapping not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// SyncNow applies the current album art of a Sonos room immediately
+func (h *HueMusicSyncHandler) SyncNow(w http.ResponseWriter, r *http.Request) {
+	room := mux.Vars(r)["room"]
+
+	status, err := h.musicSyncService.SyncNow(r.Context(), room)
+	if err != nil {
+		logrus.Errorf("Failed to sync lights to music for %s: %v", room, err)
+		http.Error(w, "Failed to sync lights: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"sync":   status,
+	})
+}
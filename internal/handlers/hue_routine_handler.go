@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"woodhome-webapp/internal/models"
+	"woodhome-webapp/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+// HueRoutineHandler handles HTTP requests for the wake-up and sleep light routines
+type HueRoutineHandler struct {
+	routineService *services.HueLightRoutineService
+}
+
+// NewHueRoutineHandler creates a new HueRoutineHandler
+func NewHueRoutineHandler(routineService *services.HueLightRoutineService) *HueRoutineHandler {
+	return &HueRoutineHandler{
+		routineService: routineService,
+	}
+}
+
+// RegisterRoutes registers all light routine routes
+func (h *HueRoutineHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("", h.GetRoutines).Methods("GET")
+	router.HandleFunc("", h.CreateRoutine).Methods("POST")
+	router.HandleFunc("/{id}", h.GetRoutine).Methods("GET")
+	router.HandleFunc("/{id}", h.UpdateRoutine).Methods("PUT")
+	router.HandleFunc("/{id}", h.DeleteRoutine).Methods("DELETE")
+	router.HandleFunc("/{id}/run", h.RunRoutine).Methods("POST")
+	router.HandleFunc("/{id}/cancel", h.CancelRoutine).Methods("POST")
+}
+
+// GetRoutines returns every routine with its next run
+func (h *HueRoutineHandler) GetRoutines(w http.ResponseWriter, r *http.Request) {
+	routines := h.routineService.GetRoutines()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"routines": routines,
+		"count":    len(routines),
+	})
+}
+
+// GetRoutine returns a single routine
+func (h *HueRoutineHandler) GetRoutine(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	routine, exists := h.routineService.GetRoutine(id)
+	if !exists {
+		http.Error(w, "Routine not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(routine)
+}
+
+// CreateRoutine adds a wake-up or sleep routine
+func (h *HueRoutineHandler) CreateRoutine(w http.ResponseWriter, r *http.Request) {
+	var routine models.HueLightRoutine
+	if err := json.NewDecoder(r.Body).Decode(&routine); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	status, err := h.routineService.CreateRoutine(routine)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"routine": status,
+	})
+}
+
+// UpdateRoutine replaces a routine
+func (h *HueRoutineHandler) UpdateRoutine(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if _, exists := h.routineService.GetRoutine(id); !exists {
+		http.Error(w, "Routine not found", http.StatusNotFound)
+		return
+	}
+
+	var routine models.HueLightRoutine
+	if err := json.NewDecoder(r.Body).Decode(&routine); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	status, err := h.routineService.UpdateRoutine(id, routine)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"routine": status,
+	})
+}
+
+// DeleteRoutine stops and removes a routine
+func (h *HueRoutineHandler) DeleteRoutine(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if _, exists := h.routineService.GetRoutine(id); !exists {
+		http.Error(w, "Routine not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.routineService.DeleteRoutine(id); err != nil {
+		http.Error(w, "Failed to delete routine: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// RunRoutine starts a routine right away
+func (h *HueRoutineHandler) RunRoutine(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if _, exists := h.routineService.GetRoutine(id); !exists {
+		http.Error(w, "Routine not found", http.StatusNotFound)
+		return
+	}
+
+	status, err := h.routineService.RunNow(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"routine": status,
+	})
+}
+
+// CancelRoutine stops a running fade, or skips the next run if none is in progress
+func (h *HueRoutineHandler) CancelRoutine(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if _, exists := h.routineService.GetRoutine(id); !exists {
+		http.Error(w, "Routine not found", http.StatusNotFound)
+		return
+	}
+
+	status, err := h.routineService.Cancel(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"routine": status,
+	})
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"woodhome-webapp/internal/models"
+	"woodhome-webapp/internal/services"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// RoomHandler handles HTTP requests for the household rooms
+type RoomHandler struct {
+	roomService *services.RoomService
+}
+
+// NewRoomHandler creates a new RoomHandler
+func NewRoomHandler(roomService *services.RoomService) *RoomHandler {
+	return &RoomHandler{
+		roomService: roomService,
+	}
+}
+
+// RegisterRoutes registers all room routes. Rooms can be addressed by ID or name.
+func (h *RoomHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("", h.GetRooms).Methods("GET")
+	router.HandleFunc("", h.CreateRoom).Methods("POST")
+	router.HandleFunc("/suggestions", h.GetSuggestions).Methods("GET")
+	router.HandleFunc("/{room}", h.GetRoom).Methods("GET")
+	router.HandleFunc("/{room}", h.UpdateRoom).Methods("PUT")
+	router.HandleFunc("/{room}", h.DeleteRoom).Methods("DELETE")
+	router.HandleFunc("/{room}/status", h.GetRoomStatus).Methods("GET")
+	router.HandleFunc("/{room}/off", h.AllOff).Methods("POST")
+}
+
+// GetRooms returns every room
+func (h *RoomHandler) GetRooms(w http.ResponseWriter, r *http.Request) {
+	rooms := h.roomService.GetRooms()
+	for _, room := range rooms {
+		h.hideAccessKey(r, room)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"rooms": rooms,
+		"count": len(rooms),
+	})
+}
+
+// GetRoom returns a single room
+func (h *RoomHandler) GetRoom(w http.ResponseWriter, r *http.Request) {
+	room, exists := h.roomService.GetRoom(mux.Vars(r)["room"])
+	if !exists {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+	h.hideAccessKey(r, room)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(room)
+}
+
+// CreateRoom adds a room. Only signed-in household members may add rooms.
+func (h *RoomHandler) CreateRoom(w http.ResponseWriter, r *http.Request) {
+	if !isSignedIn(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var room models.Room
+	if err := json.NewDecoder(r.Body).Decode(&room); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.roomService.CreateRoom(room)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"room":   created,
+	})
+}
+
+// UpdateRoom replaces the devices, calendars and permissions of a room
+func (h *RoomHandler) UpdateRoom(w http.ResponseWriter, r *http.Request) {
+	existing, ok := h.controlledRoom(w, r)
+	if !ok {
+		return
+	}
+
+	var room models.Room
+	if err := json.NewDecoder(r.Body).Decode(&room); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := h.roomService.UpdateRoom(existing.ID, room)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"room":   updated,
+	})
+}
+
+// DeleteRoom removes a room
+func (h *RoomHandler) DeleteRoom(w http.ResponseWriter, r *http.Request) {
+	room, ok := h.controlledRoom(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.roomService.DeleteRoom(room.ID); err != nil {
+		logrus.Errorf("Failed to delete room %s: %v", room.ID, err)
+		http.Error(w, "Failed to delete room", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// GetSuggestions proposes rooms for Hue groups and Sonos players with matching names
+func (h *RoomHandler) GetSuggestions(w http.ResponseWriter, r *http.Request) {
+	suggestions := h.roomService.Suggest()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"suggestions": suggestions,
+		"count":       len(suggestions),
+	})
+}
+
+// GetRoomStatus returns the lights, playback and volume of a room
+func (h *RoomHandler) GetRoomStatus(w http.ResponseWriter, r *http.Request) {
+	room, exists := h.roomService.GetRoom(mux.Vars(r)["room"])
+	if !exists {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+	h.hideAccessKey(r, room)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.roomService.GetStatus(room))
+}
+
+// AllOff switches off the lights and music of a room
+func (h *RoomHandler) AllOff(w http.ResponseWriter, r *http.Request) {
+	room, ok := h.controlledRoom(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.roomService.AllOff(r.Context(), room); err != nil {
+		logrus.Errorf("Failed to switch off room %s: %v", room.Name, err)
+		http.Error(w, "Failed to switch off everything in the room: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// controlledRoom looks up the room of the request and checks that the caller may control it.
+// It writes the error response and returns false otherwise. The check only guards the room
+// endpoints; the devices of a room can still be controlled through the Hue, Sonos, scene
+// and routine APIs.
+func (h *RoomHandler) controlledRoom(w http.ResponseWriter, r *http.Request) (*models.Room, bool) {
+	room, exists := h.roomService.GetRoom(mux.Vars(r)["room"])
+	if !exists {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return nil, false
+	}
+	if !h.roomService.CanControl(room, isSignedIn(r), roomAccessKey(r)) {
+		http.Error(w, "This room needs a household sign-in or its access key", http.StatusForbidden)
+		return nil, false
+	}
+	return room, true
+}
+
+// hideAccessKey blanks the access key of a room unless the caller may control it anyway
+func (h *RoomHandler) hideAccessKey(r *http.Request, room *models.Room) {
+	if !h.roomService.CanControl(room, isSignedIn(r), roomAccessKey(r)) {
+		room.Permissions.AccessKey = ""
+	}
+}
+
+// isSignedIn reports whether the request comes from a signed-in household member
+func isSignedIn(r *http.Request) bool {
+	session, _ := GetSessionStore().Get(r, "auth-session")
+	authenticated, ok := session.Values["oauth_authenticated"].(bool)
+	return ok && authenticated
+}
+
+// roomAccessKey returns the room access key sent with a request
+func roomAccessKey(r *http.Request) string {
+	if key := r.Header.Get("X-Room-Key"); key != "" {
+		return key
+	}
+	return r.URL.Query().Get("key")
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"woodhome-webapp/internal/models"
+	"woodhome-webapp/internal/services"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// SolarHandler handles HTTP requests for sun times and sun triggers
+type SolarHandler struct {
+	solarService *services.SolarService
+}
+
+// NewSolarHandler creates a new SolarHandler
+func NewSolarHandler(solarService *services.SolarService) *SolarHandler {
+	return &SolarHandler{
+		solarService: solarService,
+	}
+}
+
+// RegisterRoutes registers all sun time routes
+func (h *SolarHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("", h.GetStatus).Methods("GET")
+	router.HandleFunc("/days", h.GetDays).Methods("GET")
+	router.HandleFunc("/triggers", h.GetTriggers).Methods("GET")
+	router.HandleFunc("/triggers", h.CreateTrigger).Methods("POST")
+	router.HandleFunc("/triggers/{id}", h.GetTrigger).Methods("GET")
+	router.HandleFunc("/triggers/{id}", h.UpdateTrigger).Methods("PUT")
+	router.HandleFunc("/triggers/{id}", h.DeleteTrigger).Methods("DELETE")
+}
+
+// GetStatus returns today's and tomorrow's sun times for the dashboard
+func (h *SolarHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.solarService.GetStatus())
+}
+
+// GetDays returns the sun times for a range of days, from ?date=YYYY-MM-DD for ?days=N
+func (h *SolarHandler) GetDays(w http.ResponseWriter, r *http.Request) {
+	count := 7
+	if value := r.URL.Query().Get("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "days must be a number", http.StatusBadRequest)
+			return
+		}
+		count = parsed
+	}
+
+	days, err := h.solarService.GetDays(r.URL.Query().Get("date"), count)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"days":  days,
+		"count": len(days),
+	})
+}
+
+// GetTriggers returns every sun trigger
+func (h *SolarHandler) GetTriggers(w http.ResponseWriter, r *http.Request) {
+	triggers := h.solarService.GetTriggers()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"triggers": triggers,
+		"count":    len(triggers),
+	})
+}
+
+// GetTrigger returns a single sun trigger
+func (h *SolarHandler) GetTrigger(w http.ResponseWriter, r *http.Request) {
+	trigger, exists := h.solarService.GetTrigger(mux.Vars(r)["id"])
+	if !exists {
+		http.Error(w, "Trigger not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trigger)
+}
+
+// CreateTrigger adds a sun trigger
+func (h *SolarHandler) CreateTrigger(w http.ResponseWriter, r *http.Request) {
+	var trigger models.SolarTrigger
+	if err := json.NewDecoder(r.Body).Decode(&trigger); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.solarService.CreateTrigger(trigger)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"trigger": created,
+	})
+}
+
+// UpdateTrigger replaces a sun trigger
+func (h *SolarHandler) UpdateTrigger(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if _, exists := h.solarService.GetTrigger(id); !exists {
+		http.Error(w, "Trigger not found", http.StatusNotFound)
+		return
+	}
+
+	var trigger models.SolarTrigger
+	if err := json.NewDecoder(r.Body).Decode(&trigger); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := h.solarService.UpdateTrigger(id, trigger)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"trigger": updated,
+	})
+}
+
+// DeleteTrigger removes a sun trigger
+func (h *SolarHandler) DeleteTrigger(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if _, exists := h.solarService.GetTrigger(id); !exists {
+		http.Error(w, "Trigger not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.solarService.DeleteTrigger(id); err != nil {
+		logrus.Errorf("Failed to delete sun trigger %s: %v", id, err)
+		http.Error(w, "Failed to delete trigger", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
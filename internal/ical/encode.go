@@ -0,0 +1,212 @@
+package ical
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxOctets is the longest a content line may be before it is folded
+const maxOctets = 75
+
+// Encoder writes calendars as iCalendar streams that phones and calendar apps can subscribe to
+type Encoder struct {
+	ProdID   string         // Identifies the product that wrote the stream
+	Method   string         // Such as PUBLISH for a feed, left out when empty as CalDAV requires
+	Location *time.Location // Timed events are written in this zone, described by a VTIMEZONE
+	Stamp    time.Time      // DTSTAMP of every event
+}
+
+// Encode writes a calendar. Events are written as they are, one VEVENT each, so recurring
+// events should be expanded first. All-day events are written as the dates of their Start
+// and End.
+func (e Encoder) Encode(w io.Writer, cal *Calendar) error {
+	loc := e.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	lw := &lineWriter{w: w}
+
+	lw.line("BEGIN", "VCALENDAR")
+	lw.line("VERSION", "2.0")
+	lw.line("PRODID", e.ProdID)
+	lw.line("CALSCALE", "GREGORIAN")
+	if e.Method != "" {
+		lw.line("METHOD", e.Method)
+	}
+	if cal.Name != "" {
+		lw.line("X-WR-CALNAME", escapeText(cal.Name))
+	}
+	if loc != time.UTC {
+		if _, err := time.LoadLocation(loc.String()); err == nil && loc != time.Local {
+			lw.line("X-WR-TIMEZONE", loc.String())
+		}
+		if from, to, ok := timedSpan(cal.Events); ok {
+			writeTimeZone(lw, loc, from, to)
+		}
+	}
+
+	stamp := e.Stamp.UTC().Format("20060102T150405Z")
+	for _, event := range cal.Events {
+		lw.line("BEGIN", "VEVENT")
+		lw.line("UID", event.UID)
+		lw.line("DTSTAMP", stamp)
+		if event.AllDay {
+			lw.line("DTSTART;VALUE=DATE", event.Start.Format("20060102"))
+			if !event.End.IsZero() {
+				lw.line("DTEND;VALUE=DATE", event.End.Format("20060102"))
+			}
+		} else {
+			lw.line(dateTimeProperty("DTSTART", event.Start, loc))
+			if !event.End.IsZero() {
+				lw.line(dateTimeProperty("DTEND", event.End, loc))
+			}
+		}
+		lw.line("SUMMARY", escapeText(event.Summary))
+		if event.Description != "" {
+			lw.line("DESCRIPTION", escapeText(event.Description))
+		}
+		if event.Location != "" {
+			lw.line("LOCATION", escapeText(event.Location))
+		}
+		if event.Status != "" {
+			lw.line("STATUS", event.Status)
+		}
+		lw.line("END", "VEVENT")
+	}
+
+	lw.line("END", "VCALENDAR")
+	return lw.err
+}
+
+// dateTimeProperty names a DATE-TIME property and formats its value in loc
+func dateTimeProperty(name string, t time.Time, loc *time.Location) (string, string) {
+	if loc == time.UTC {
+		return name, t.UTC().Format("20060102T150405Z")
+	}
+	return name + ";TZID=" + loc.String(), t.In(loc).Format("20060102T150405")
+}
+
+// timedSpan returns the earliest start and latest end of the timed events
+func timedSpan(events []*Event) (from, to time.Time, ok bool) {
+	for _, event := range events {
+		if event.AllDay {
+			continue
+		}
+		end := event.End
+		if end.IsZero() {
+			end = event.Start
+		}
+		if !ok || event.Start.Before(from) {
+			from = event.Start
+		}
+		if !ok || end.After(to) {
+			to = end
+		}
+		ok = true
+	}
+	return from, to, ok
+}
+
+// writeTimeZone describes loc from one day before from to to as a VTIMEZONE: the offset in
+// force at the start, then one observance for each change of offset Go's zone data has
+func writeTimeZone(lw *lineWriter, loc *time.Location, from, to time.Time) {
+	from = from.Add(-24 * time.Hour)
+
+	lw.line("BEGIN", "VTIMEZONE")
+	lw.line("TZID", loc.String())
+	name, offset := from.In(loc).Zone()
+	writeObservance(lw, from.In(loc).IsDST(), from.Add(time.Duration(offset)*time.Second), offset, offset, name)
+
+	for day := from; day.Before(to); day = day.Add(24 * time.Hour) {
+		next := day.Add(24 * time.Hour)
+		if _, nextOffset := next.In(loc).Zone(); nextOffset == offset {
+			continue
+		}
+		// Narrow the change down to the second it happens
+		before, after := day, next
+		for after.Sub(before) > time.Second {
+			middle := before.Add(after.Sub(before) / 2)
+			if _, middleOffset := middle.In(loc).Zone(); middleOffset == offset {
+				before = middle
+			} else {
+				after = middle
+			}
+		}
+		newName, newOffset := after.In(loc).Zone()
+		// The onset is written as a wall clock time in the offset before it
+		writeObservance(lw, after.In(loc).IsDST(), after.Add(time.Duration(offset)*time.Second), offset, newOffset, newName)
+		offset = newOffset
+	}
+	lw.line("END", "VTIMEZONE")
+}
+
+// writeObservance writes a STANDARD or DAYLIGHT block with its onset as a wall clock in UTC fields
+func writeObservance(lw *lineWriter, daylight bool, onset time.Time, offsetFrom, offsetTo int, name string) {
+	kind := "STANDARD"
+	if daylight {
+		kind = "DAYLIGHT"
+	}
+	lw.line("BEGIN", kind)
+	lw.line("DTSTART", onset.UTC().Format("20060102T150405"))
+	lw.line("TZOFFSETFROM", formatUTCOffset(offsetFrom))
+	lw.line("TZOFFSETTO", formatUTCOffset(offsetTo))
+	if name != "" {
+		lw.line("TZNAME", escapeText(name))
+	}
+	lw.line("END", kind)
+}
+
+// formatUTCOffset formats seconds east of UTC as an offset such as -0500 or +053000
+func formatUTCOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	formatted := fmt.Sprintf("%s%02d%02d", sign, offset/3600, offset/60%60)
+	if offset%60 != 0 {
+		formatted += fmt.Sprintf("%02d", offset%60)
+	}
+	return formatted
+}
+
+// escapeText escapes a TEXT value, the reverse of unescapeText
+func escapeText(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(value)
+}
+
+// lineWriter writes content lines, folding them at 75 octets, and keeps the first error
+type lineWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (lw *lineWriter) line(name, value string) {
+	if lw.err != nil {
+		return
+	}
+	_, lw.err = io.WriteString(lw.w, fold(name+":"+value))
+}
+
+// fold splits a content line into CRLF terminated lines of at most 75 octets, continuation
+// lines starting with a space, without splitting a UTF-8 sequence
+func fold(line string) string {
+	var b strings.Builder
+	limit := maxOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = maxOctets - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+	return b.String()
+}
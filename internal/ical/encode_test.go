@@ -0,0 +1,104 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Skipf("Time zone data not available: %v", err)
+	}
+	cal := &Calendar{
+		Name: "Wood family",
+		Events: []*Event{
+			{
+				UID:         "soccer@woodhome",
+				Summary:     "[Kids] Soccer; bring cleats, water",
+				Description: "Field 3\nPark entrance on Elm — look for the blue tent, it is right behind the snack stand next to the parking lot",
+				Start:       time.Date(2024, 11, 2, 9, 0, 0, 0, chicago),
+				End:         time.Date(2024, 11, 2, 10, 30, 0, 0, chicago),
+			},
+			{
+				UID:     "dentist@woodhome",
+				Summary: "Dentist",
+				Start:   time.Date(2024, 11, 4, 15, 0, 0, 0, chicago),
+				End:     time.Date(2024, 11, 4, 16, 0, 0, 0, chicago),
+			},
+			{
+				UID:     "trip@woodhome",
+				Summary: "Camping",
+				Start:   time.Date(2024, 11, 8, 0, 0, 0, 0, time.UTC),
+				End:     time.Date(2024, 11, 10, 0, 0, 0, 0, time.UTC),
+				AllDay:  true,
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	encoder := Encoder{ProdID: "-//WoodHome//Test//EN", Location: chicago, Stamp: time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)}
+	if err := encoder.Encode(&buf, cal); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	out := buf.String()
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(line) > maxOctets {
+			t.Fatalf("Line longer than %d octets: %q", maxOctets, line)
+		}
+	}
+	for _, want := range []string{
+		"DTSTART;TZID=America/Chicago:20241102T090000\r\n",
+		"DTSTART;VALUE=DATE:20241108\r\n",
+		`SUMMARY:[Kids] Soccer\; bring cleats\, water` + "\r\n",
+		// The change back to standard time on November 3, at 2:00 daylight time
+		"BEGIN:STANDARD\r\nDTSTART:20241103T020000\r\nTZOFFSETFROM:-0500\r\nTZOFFSETTO:-0600\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("Expected %q in:\n%s", want, out)
+		}
+	}
+
+	parsed, err := Parse(strings.NewReader(out), time.UTC)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if parsed.Name != cal.Name || len(parsed.Events) != len(cal.Events) {
+		t.Fatalf("Unexpected calendar: %+v", parsed)
+	}
+	for i, event := range parsed.Events {
+		original := cal.Events[i]
+		if event.Summary != original.Summary || event.Description != original.Description || event.AllDay != original.AllDay {
+			t.Fatalf("Event %d did not survive the round trip: %+v", i, event)
+		}
+		if !original.AllDay && (!event.Start.Equal(original.Start) || !event.End.Equal(original.End)) {
+			t.Fatalf("Event %d moved from %v to %v", i, original.Start, event.Start)
+		}
+	}
+
+	// The VTIMEZONE alone gives the same instants as Go's zone data
+	root, err := parseComponents(strings.NewReader(out))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var tz *timeZone
+	for _, child := range root.Components {
+		if child.Name == "VTIMEZONE" {
+			if tz, err = parseTimeZone(child); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+	}
+	for _, local := range []time.Time{
+		time.Date(2024, 11, 2, 9, 0, 0, 0, chicago),
+		time.Date(2024, 11, 3, 12, 0, 0, 0, chicago),
+		time.Date(2024, 11, 4, 15, 0, 0, 0, chicago),
+	} {
+		wall := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), 0, 0, 0, time.UTC)
+		if got := tz.at(wall); !got.Equal(local) {
+			t.Fatalf("VTIMEZONE placed %v at %v, want %v", wall, got, local.UTC())
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package ical
+
+import (
+	"sort"
+	"time"
+)
+
+// Instance is one occurrence of an event
+type Instance struct {
+	Event  *Event // The event, or the override replacing this occurrence
+	Start  time.Time
+	End    time.Time
+	AllDay bool
+}
+
+// Expand returns the occurrences overlapping from to to in order of their start. Repeats
+// follow RRULE and RDATE, minus EXDATE; an event with a RECURRENCE-ID replaces the
+// occurrence it names, and a cancelled one removes it.
+func (c *Calendar) Expand(from, to time.Time) []Instance {
+	overrides := make(map[string]map[int64]bool)
+	for _, event := range c.Events {
+		if event.RecurrenceID.IsZero() {
+			continue
+		}
+		if overrides[event.UID] == nil {
+			overrides[event.UID] = make(map[int64]bool)
+		}
+		overrides[event.UID][event.RecurrenceID.Unix()] = true
+	}
+
+	var instances []Instance
+	add := func(event *Event, start, end time.Time) {
+		if event.Status == "CANCELLED" {
+			return
+		}
+		overlaps := start.Before(to) && end.After(from)
+		instant := start.Equal(end) && !start.Before(from) && start.Before(to)
+		if overlaps || instant {
+			instances = append(instances, Instance{Event: event, Start: start, End: end, AllDay: event.AllDay})
+		}
+	}
+
+	for _, event := range c.Events {
+		if !event.RecurrenceID.IsZero() {
+			add(event, event.Start, event.End)
+			continue
+		}
+		if !event.Recurring() {
+			add(event, event.Start, event.End)
+			continue
+		}
+
+		for _, wall := range event.occurrences(from, to) {
+			start := event.zone.at(wall)
+			if overrides[event.UID][start.Unix()] {
+				continue
+			}
+			add(event, start, event.endOf(wall))
+		}
+	}
+
+	sort.SliceStable(instances, func(i, j int) bool {
+		return instances[i].Start.Before(instances[j].Start)
+	})
+	return instances
+}
+
+// occurrences returns the wall clock starts of a recurring event that may overlap from to to
+func (e *Event) occurrences(from, to time.Time) []time.Time {
+	// An occurrence starting before from can still be running at from
+	length := e.End.Sub(e.Start)
+	earliest := from.Add(-length)
+
+	var walls []time.Time
+	if e.Rule != nil {
+		walls = e.Rule.Between(e.wallStart, e.zone.at, earliest, to)
+	} else if start := e.Start; !start.Before(earliest) && !start.After(to) {
+		walls = append(walls, e.wallStart)
+	}
+
+	// RDATE values are instants, so they are turned back into wall clocks of the event's zone
+	for _, rdate := range e.RDates {
+		if rdate.Before(earliest) || rdate.After(to) {
+			continue
+		}
+		walls = append(walls, e.wallOf(rdate))
+	}
+
+	excluded := make(map[int64]bool)
+	for _, exdate := range e.ExDates {
+		excluded[exdate.Unix()] = true
+	}
+	seen := make(map[int64]bool)
+	kept := walls[:0]
+	for _, wall := range walls {
+		key := e.zone.at(wall).Unix()
+		if excluded[key] || seen[key] {
+			continue
+		}
+		seen[key] = true
+		kept = append(kept, wall)
+	}
+	return kept
+}
+
+// wallOf finds the wall clock in the event's zone of an instant
+func (e *Event) wallOf(instant time.Time) time.Time {
+	// The offset at the instant is close enough to look up the exact one
+	utc := instant.UTC()
+	guess := utc.Add(utc.Sub(e.zone.at(utc)))
+	return utc.Add(guess.Sub(e.zone.at(guess)))
+}
@@ -0,0 +1,475 @@
+// Package ical reads and writes iCalendar (RFC 5545) feeds, such as the .ics links published by
+// schools, sports leagues and trash pickup services, and expands their recurring events.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxLineLength bounds a single unfolded content line
+const maxLineLength = 1 << 20
+
+// Property is a content line such as DTSTART;TZID=America/Chicago:20240901T083000
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Component is a BEGIN/END block such as VEVENT
+type Component struct {
+	Name       string
+	Properties []Property
+	Components []*Component
+}
+
+// Get returns the first property with a name, or nil
+func (c *Component) Get(name string) *Property {
+	for i := range c.Properties {
+		if c.Properties[i].Name == name {
+			return &c.Properties[i]
+		}
+	}
+	return nil
+}
+
+// GetAll returns every property with a name
+func (c *Component) GetAll(name string) []Property {
+	var properties []Property
+	for _, property := range c.Properties {
+		if property.Name == name {
+			properties = append(properties, property)
+		}
+	}
+	return properties
+}
+
+// text returns the unescaped value of a text property, or "" when it is missing
+func (c *Component) text(name string) string {
+	property := c.Get(name)
+	if property == nil {
+		return ""
+	}
+	return unescapeText(property.Value)
+}
+
+// Calendar is a parsed VCALENDAR
+type Calendar struct {
+	Name     string // X-WR-CALNAME
+	TimeZone string // X-WR-TIMEZONE
+	Events   []*Event
+}
+
+// Event is a VEVENT. A recurring event describes its first occurrence; Calendar.Expand
+// lists every occurrence.
+type Event struct {
+	UID          string
+	Summary      string
+	Description  string
+	Location     string
+	Status       string // TENTATIVE, CONFIRMED or CANCELLED
+	Start        time.Time
+	End          time.Time
+	AllDay       bool
+	Rule         *Rule
+	RDates       []time.Time
+	ExDates      []time.Time
+	RecurrenceID time.Time // Set on an event that replaces one occurrence of a recurring event
+
+	wallStart time.Time // DTSTART as a wall clock, in UTC fields
+	zone      zone
+	duration  time.Duration // Length of a timed event
+	days      int           // Length of an all-day event
+}
+
+// Recurring reports whether the event repeats
+func (e *Event) Recurring() bool {
+	return e.Rule != nil || len(e.RDates) > 0
+}
+
+// NewEvent creates an event that was not read from a stream, such as one kept in a database.
+// Its start is taken as a wall clock time in loc, so a Rule set on it repeats at the same time
+// of day across daylight saving changes. An all-day event lasts from the date of start to the
+// date of end, at least one day.
+func NewEvent(uid string, start, end time.Time, allDay bool, loc *time.Location) *Event {
+	wall := func(t time.Time) time.Time {
+		t = t.In(loc)
+		if allDay {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		}
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	}
+
+	event := &Event{
+		UID:       uid,
+		AllDay:    allDay,
+		wallStart: wall(start),
+		zone:      locationZone{loc},
+	}
+	if allDay {
+		event.days = int(wall(end).Sub(event.wallStart).Hours() / 24)
+		if event.days < 1 {
+			event.days = 1
+		}
+	} else if end.After(start) {
+		event.duration = end.Sub(start)
+	}
+	event.Start = event.zone.at(event.wallStart)
+	event.End = event.endOf(event.wallStart)
+	return event
+}
+
+// Parse reads a calendar. Floating times and all-day dates are placed in loc unless the
+// calendar names its own time zone.
+func Parse(r io.Reader, loc *time.Location) (*Calendar, error) {
+	root, err := parseComponents(r)
+	if err != nil {
+		return nil, err
+	}
+	if root.Name != "VCALENDAR" {
+		return nil, fmt.Errorf("expected VCALENDAR, found %s", root.Name)
+	}
+
+	cal := &Calendar{
+		Name:     root.text("X-WR-CALNAME"),
+		TimeZone: root.text("X-WR-TIMEZONE"),
+	}
+	if cal.TimeZone != "" {
+		if named, err := time.LoadLocation(cal.TimeZone); err == nil {
+			loc = named
+		}
+	}
+
+	zones := zoneSet{floating: locationZone{loc}, defined: make(map[string]zone)}
+	for _, child := range root.Components {
+		if child.Name == "VTIMEZONE" {
+			if tz, err := parseTimeZone(child); err == nil {
+				zones.defined[tz.id] = tz
+			}
+		}
+	}
+
+	for _, child := range root.Components {
+		if child.Name != "VEVENT" {
+			continue
+		}
+		event, err := parseEvent(child, zones)
+		if err != nil {
+			// One broken event should not hide the rest of a school's calendar
+			continue
+		}
+		cal.Events = append(cal.Events, event)
+	}
+	return cal, nil
+}
+
+// parseComponents reads the content lines of a stream into its top component
+func parseComponents(r io.Reader) (*Component, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineLength)
+
+	var root *Component
+	var stack []*Component
+	handle := func(line string) error {
+		if strings.TrimSpace(line) == "" {
+			return nil
+		}
+		property, err := parseContentLine(line)
+		if err != nil {
+			return err
+		}
+
+		switch property.Name {
+		case "BEGIN":
+			component := &Component{Name: strings.ToUpper(property.Value)}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Components = append(parent.Components, component)
+			} else if root == nil {
+				root = component
+			} else {
+				return fmt.Errorf("unexpected second top-level %s", component.Name)
+			}
+			stack = append(stack, component)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(property.Value) {
+				return fmt.Errorf("unexpected END:%s", property.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return fmt.Errorf("property %s outside of a component", property.Name)
+			}
+			current := stack[len(stack)-1]
+			current.Properties = append(current.Properties, property)
+		}
+		return nil
+	}
+
+	// Lines starting with a space or tab continue the previous line
+	var line strings.Builder
+	for scanner.Scan() {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t") {
+			line.WriteString(text[1:])
+			continue
+		}
+		if line.Len() > 0 {
+			if err := handle(line.String()); err != nil {
+				return nil, err
+			}
+		}
+		line.Reset()
+		line.WriteString(strings.TrimPrefix(text, "\ufeff"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read calendar: %w", err)
+	}
+	if line.Len() > 0 {
+		if err := handle(line.String()); err != nil {
+			return nil, err
+		}
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("no calendar found")
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("%s is not closed", stack[len(stack)-1].Name)
+	}
+	return root, nil
+}
+
+// parseContentLine splits NAME;PARAM=VALUE:value, where parameter values may be quoted
+func parseContentLine(line string) (Property, error) {
+	property := Property{Params: make(map[string]string)}
+
+	quoted := false
+	nameEnd := -1
+	valueStart := -1
+	var params []string
+	paramStart := -1
+	for i, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case quoted:
+		case r == ';' || r == ':':
+			if nameEnd < 0 {
+				nameEnd = i
+			} else {
+				params = append(params, line[paramStart:i])
+			}
+			paramStart = i + 1
+			if r == ':' {
+				valueStart = i + 1
+			}
+		}
+		if valueStart >= 0 {
+			break
+		}
+	}
+	if nameEnd <= 0 || valueStart < 0 {
+		return property, fmt.Errorf("invalid content line %q", line)
+	}
+
+	property.Name = strings.ToUpper(line[:nameEnd])
+	property.Value = line[valueStart:]
+	for _, param := range params {
+		key, value, found := strings.Cut(param, "=")
+		if !found {
+			continue
+		}
+		property.Params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+	return property, nil
+}
+
+// unescapeText undoes the escaping of TEXT values
+func unescapeText(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var text strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i == len(value)-1 {
+			text.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'n', 'N':
+			text.WriteByte('\n')
+		default:
+			text.WriteByte(value[i])
+		}
+	}
+	return text.String()
+}
+
+// parseEvent reads a VEVENT
+func parseEvent(c *Component, zones zoneSet) (*Event, error) {
+	dtstart := c.Get("DTSTART")
+	if dtstart == nil {
+		return nil, fmt.Errorf("event has no DTSTART")
+	}
+
+	event := &Event{
+		UID:         c.text("UID"),
+		Summary:     c.text("SUMMARY"),
+		Description: c.text("DESCRIPTION"),
+		Location:    c.text("LOCATION"),
+		Status:      strings.ToUpper(c.text("STATUS")),
+	}
+
+	value, err := parseDateTime(*dtstart, zones)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DTSTART: %w", err)
+	}
+	event.wallStart = value.wall
+	event.zone = value.zone
+	event.AllDay = value.date
+	event.Start = value.zone.at(value.wall)
+
+	if dtend := c.Get("DTEND"); dtend != nil {
+		end, err := parseDateTime(*dtend, zones)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DTEND: %w", err)
+		}
+		if event.AllDay {
+			event.days = int(end.wall.Sub(event.wallStart).Hours() / 24)
+		} else {
+			event.duration = end.zone.at(end.wall).Sub(event.Start)
+		}
+	} else if duration := c.Get("DURATION"); duration != nil {
+		length, err := ParseDuration(duration.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DURATION: %w", err)
+		}
+		if event.AllDay {
+			event.days = int(length.Hours() / 24)
+		} else {
+			event.duration = length
+		}
+	} else if event.AllDay {
+		// An all-day event without an end lasts the day
+		event.days = 1
+	}
+	if event.AllDay && event.days < 1 {
+		event.days = 1
+	}
+	if event.duration < 0 {
+		event.duration = 0
+	}
+	event.End = event.endOf(event.wallStart)
+
+	if rrule := c.Get("RRULE"); rrule != nil {
+		// A rule this package cannot follow leaves just the first occurrence
+		if rule, err := ParseRule(rrule.Value); err == nil {
+			event.Rule = rule
+		}
+	}
+	for _, property := range c.GetAll("RDATE") {
+		if strings.EqualFold(property.Params["VALUE"], "PERIOD") {
+			// Only the start of each period is used
+			property.Value = periodStarts(property.Value)
+		}
+		dates, err := parseDateTimeList(property, zones)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RDATE: %w", err)
+		}
+		event.RDates = append(event.RDates, dates...)
+	}
+	for _, property := range c.GetAll("EXDATE") {
+		dates, err := parseDateTimeList(property, zones)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EXDATE: %w", err)
+		}
+		event.ExDates = append(event.ExDates, dates...)
+	}
+	if recurrenceID := c.Get("RECURRENCE-ID"); recurrenceID != nil {
+		value, err := parseDateTime(*recurrenceID, zones)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RECURRENCE-ID: %w", err)
+		}
+		event.RecurrenceID = value.zone.at(value.wall)
+	}
+	return event, nil
+}
+
+// endOf returns the end of the occurrence starting at a wall clock time
+func (e *Event) endOf(wallStart time.Time) time.Time {
+	if e.AllDay {
+		return e.zone.at(wallStart.AddDate(0, 0, e.days))
+	}
+	return e.zone.at(wallStart).Add(e.duration)
+}
+
+// periodStarts keeps the start of each start/end period in an RDATE list
+func periodStarts(value string) string {
+	periods := strings.Split(value, ",")
+	for i, period := range periods {
+		periods[i], _, _ = strings.Cut(period, "/")
+	}
+	return strings.Join(periods, ",")
+}
+
+// ParseDuration parses a duration such as PT1H30M, P1D or -P1W
+func ParseDuration(value string) (time.Duration, error) {
+	text := strings.ToUpper(strings.TrimSpace(value))
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(text, "-"):
+		sign = -1
+		text = text[1:]
+	case strings.HasPrefix(text, "+"):
+		text = text[1:]
+	}
+	if !strings.HasPrefix(text, "P") || len(text) < 3 {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	text = text[1:]
+
+	var total time.Duration
+	inTime := false
+	number := ""
+	for _, r := range text {
+		switch {
+		case r >= '0' && r <= '9':
+			number += string(r)
+			continue
+		case r == 'T':
+			inTime = true
+			continue
+		}
+
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		number = ""
+		switch {
+		case r == 'W' && !inTime:
+			total += time.Duration(n) * 7 * 24 * time.Hour
+		case r == 'D' && !inTime:
+			total += time.Duration(n) * 24 * time.Hour
+		case r == 'H' && inTime:
+			total += time.Duration(n) * time.Hour
+		case r == 'M' && inTime:
+			total += time.Duration(n) * time.Minute
+		case r == 'S' && inTime:
+			total += time.Duration(n) * time.Second
+		default:
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+	}
+	if number != "" {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return sign * total, nil
+}
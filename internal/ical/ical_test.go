@@ -0,0 +1,204 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// schoolFeed mixes the things real feeds do: a Windows time zone name with its own
+// VTIMEZONE, folded lines, a weekly event with exceptions and an override, and all-day events
+const schoolFeed = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//Lincoln Elementary//EN\r\n" +
+	"X-WR-CALNAME:Lincoln Elementary\r\n" +
+	"BEGIN:VTIMEZONE\r\n" +
+	"TZID:Central Standard Time\r\n" +
+	"BEGIN:STANDARD\r\n" +
+	"DTSTART:16010101T020000\r\n" +
+	"TZOFFSETFROM:-0500\r\n" +
+	"TZOFFSETTO:-0600\r\n" +
+	"RRULE:FREQ=YEARLY;BYDAY=1SU;BYMONTH=11\r\n" +
+	"END:STANDARD\r\n" +
+	"BEGIN:DAYLIGHT\r\n" +
+	"DTSTART:16010101T020000\r\n" +
+	"TZOFFSETFROM:-0600\r\n" +
+	"TZOFFSETTO:-0500\r\n" +
+	"RRULE:FREQ=YEARLY;BYDAY=2SU;BYMONTH=3\r\n" +
+	"END:DAYLIGHT\r\n" +
+	"END:VTIMEZONE\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:band@lincoln\r\n" +
+	"SUMMARY:Band practice\\, room 12\r\n" +
+	"DESCRIPTION:Bring your instrument\\nand music\r\n" +
+	"DTSTART;TZID=Central Standard Time:20241028T153000\r\n" +
+	"DTEND;TZID=Central Standard Time:20241028T163000\r\n" +
+	"RRULE:FREQ=WEEKLY;BYDAY=MO,WE;UNTIL=20241113T235959Z\r\n" +
+	"EXDATE;TZID=Central Standard Time:20241030T153000\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:band@lincoln\r\n" +
+	"RECURRENCE-ID;TZID=Central Standard Time:20241106T153000\r\n" +
+	"SUMMARY:Band practice (gym)\r\n" +
+	"DTSTART;TZID=Central Standard Time:20241106T160000\r\n" +
+	"DTEND;TZID=Central Standard Time:20241106T170000\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:break@lincoln\r\n" +
+	"SUMMARY:Thanksgiving break - no sc\r\n" +
+	" hool\r\n" +
+	"DTSTART;VALUE=DATE:20241127\r\n" +
+	"DTEND;VALUE=DATE:20241130\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:cancelled@lincoln\r\n" +
+	"SUMMARY:Picture day\r\n" +
+	"STATUS:CANCELLED\r\n" +
+	"DTSTART:20241104T150000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseAndExpand(t *testing.T) {
+	chicago := time.FixedZone("CST", -6*60*60)
+	cal, err := Parse(strings.NewReader(schoolFeed), chicago)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cal.Name != "Lincoln Elementary" || len(cal.Events) != 4 {
+		t.Fatalf("Unexpected calendar: %+v", cal)
+	}
+	if cal.Events[0].Summary != "Band practice, room 12" || cal.Events[0].Description != "Bring your instrument\nand music" {
+		t.Fatalf("Expected unescaped text, got %q and %q", cal.Events[0].Summary, cal.Events[0].Description)
+	}
+
+	instances := cal.Expand(time.Date(2024, 10, 28, 0, 0, 0, 0, time.UTC), time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC))
+	var got []string
+	for _, instance := range instances {
+		got = append(got, instance.Start.UTC().Format("01-02 15:04")+" "+instance.Event.Summary)
+	}
+	want := []string{
+		"10-28 20:30 Band practice, room 12", // CDT, UTC-5
+		"11-04 21:30 Band practice, room 12", // CST after the change on November 3
+		"11-06 22:00 Band practice (gym)",    // Moved by the override
+		"11-11 21:30 Band practice, room 12",
+		"11-13 21:30 Band practice, room 12", // UNTIL includes this one
+		"11-27 06:00 Thanksgiving break - no school",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Unexpected occurrences:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	holiday := instances[len(instances)-1]
+	if !holiday.AllDay || holiday.End.Sub(holiday.Start) != 72*time.Hour {
+		t.Fatalf("Expected a three day all-day event, got %+v", holiday)
+	}
+}
+
+func TestRuleOccurrences(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart time.Time
+		want    []string
+	}{
+		{
+			name:    "trash pickup every other Tuesday",
+			rule:    "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU;COUNT=3",
+			dtstart: time.Date(2024, 1, 2, 7, 0, 0, 0, time.UTC),
+			want:    []string{"2024-01-02", "2024-01-16", "2024-01-30"},
+		},
+		{
+			name:    "last Friday of the month",
+			rule:    "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3",
+			dtstart: time.Date(2024, 1, 26, 18, 0, 0, 0, time.UTC),
+			want:    []string{"2024-01-26", "2024-02-23", "2024-03-29"},
+		},
+		{
+			name:    "the 31st skips short months",
+			rule:    "FREQ=MONTHLY;COUNT=3",
+			dtstart: time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC),
+			want:    []string{"2024-01-31", "2024-03-31", "2024-05-31"},
+		},
+		{
+			name:    "last weekday of the month",
+			rule:    "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1;COUNT=2",
+			dtstart: time.Date(2024, 8, 30, 9, 0, 0, 0, time.UTC),
+			want:    []string{"2024-08-30", "2024-09-30"},
+		},
+		{
+			name:    "Thanksgiving",
+			rule:    "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH;UNTIL=20261231",
+			dtstart: time.Date(2024, 11, 28, 0, 0, 0, 0, time.UTC),
+			want:    []string{"2024-11-28", "2025-11-27", "2026-11-26"},
+		},
+		{
+			name:    "weekdays",
+			rule:    "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR;COUNT=4",
+			dtstart: time.Date(2024, 6, 20, 8, 0, 0, 0, time.UTC),
+			want:    []string{"2024-06-20", "2024-06-21", "2024-06-24", "2024-06-25"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRule(tt.rule)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			walls := rule.Between(tt.dtstart, func(wall time.Time) time.Time { return wall }, tt.dtstart, tt.dtstart.AddDate(5, 0, 0))
+			var got []string
+			for _, wall := range walls {
+				got = append(got, wall.Format("2006-01-02"))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("Got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseRule("FREQ=HOURLY"); err == nil {
+		t.Fatal("Expected an unsupported frequency to be rejected")
+	}
+}
+
+func TestNewEvent(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Skip("No time zone data")
+	}
+
+	// Game night keeps its local time when daylight saving ends
+	start := time.Date(2024, 10, 25, 19, 0, 0, 0, chicago)
+	event := NewEvent("games", start.UTC(), start.Add(2*time.Hour).UTC(), false, chicago)
+	event.Rule, _ = ParseRule("FREQ=WEEKLY;COUNT=3")
+	event.ExDates = []time.Time{time.Date(2024, 11, 1, 19, 0, 0, 0, chicago)}
+	instances := (&Calendar{Events: []*Event{event}}).Expand(start, start.AddDate(0, 1, 0))
+	if len(instances) != 2 || instances[1].Start.In(chicago).Hour() != 19 || instances[1].End.Sub(instances[1].Start) != 2*time.Hour {
+		t.Fatalf("Unexpected instances: %+v", instances)
+	}
+
+	// An all-day event ending on the day it starts still lasts the day
+	day := NewEvent("chores", time.Date(2024, 6, 20, 0, 0, 0, 0, chicago), time.Date(2024, 6, 20, 0, 0, 0, 0, chicago), true, chicago)
+	if !day.End.Equal(time.Date(2024, 6, 21, 0, 0, 0, 0, chicago)) {
+		t.Fatalf("Unexpected end: %v", day.End)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"PT1H30M": 90 * time.Minute,
+		"P1D":     24 * time.Hour,
+		"P1W":     7 * 24 * time.Hour,
+		"-PT15M":  -15 * time.Minute,
+		"P1DT2H":  26 * time.Hour,
+	}
+	for value, want := range tests {
+		got, err := ParseDuration(value)
+		if err != nil || got != want {
+			t.Fatalf("ParseDuration(%q) = %v, %v; want %v", value, got, err, want)
+		}
+	}
+	if _, err := ParseDuration("1H"); err == nil {
+		t.Fatal("Expected an invalid duration to be rejected")
+	}
+}
@@ -0,0 +1,417 @@
+package ical
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is how often a rule repeats
+type Frequency string
+
+// Supported frequencies. Feeds for households repeat by the day at the finest.
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// maxPeriods bounds the periods a rule walks through, a daily rule for about 270 years
+const maxPeriods = 100000
+
+// WeekdayNum is a BYDAY entry such as MO, or 2SU for the second Sunday
+type WeekdayNum struct {
+	Weekday time.Weekday
+	N       int // 0 for every such weekday, negative to count from the end
+}
+
+// Rule is an RRULE
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int       // 0 for no limit
+	Until      time.Time // Zero for no limit
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+	BySetPos   []int
+	WeekStart  time.Weekday
+
+	untilWall bool // Until is a wall clock rather than an instant
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// ParseRule parses an RRULE value such as FREQ=WEEKLY;BYDAY=MO,WE;UNTIL=20240614T000000Z
+func ParseRule(value string) (*Rule, error) {
+	rule := &Rule{Interval: 1, WeekStart: time.Monday}
+
+	for _, part := range strings.Split(strings.TrimSpace(value), ";") {
+		if part == "" {
+			continue
+		}
+		key, val, found := strings.Cut(part, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid rule part %q", part)
+		}
+		key = strings.ToUpper(key)
+		val = strings.ToUpper(val)
+
+		var err error
+		switch key {
+		case "FREQ":
+			rule.Freq = Frequency(val)
+			switch rule.Freq {
+			case Daily, Weekly, Monthly, Yearly:
+			default:
+				return nil, fmt.Errorf("unsupported frequency %s", val)
+			}
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(val)
+			if err == nil && rule.Interval < 1 {
+				err = fmt.Errorf("interval must be positive")
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(val)
+		case "UNTIL":
+			err = rule.parseUntil(val)
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				var weekday WeekdayNum
+				if weekday, err = parseWeekdayNum(day); err != nil {
+					break
+				}
+				rule.ByDay = append(rule.ByDay, weekday)
+			}
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseNumbers(val, 1, 31)
+		case "BYMONTH":
+			var months []int
+			months, err = parseNumbers(val, 1, 12)
+			for _, month := range months {
+				if month < 0 {
+					err = fmt.Errorf("invalid month %d", month)
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(month))
+			}
+		case "BYSETPOS":
+			rule.BySetPos, err = parseNumbers(val, 1, 366)
+		case "WKST":
+			weekday, exists := weekdays[val]
+			if !exists {
+				err = fmt.Errorf("invalid week start %s", val)
+			}
+			rule.WeekStart = weekday
+		default:
+			return nil, fmt.Errorf("unsupported rule part %s", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("rule has no FREQ")
+	}
+	return rule, nil
+}
+
+// parseUntil reads UNTIL, which is an instant when it ends in Z and a wall clock otherwise.
+// A date includes the whole day.
+func (r *Rule) parseUntil(value string) error {
+	switch {
+	case len(value) == 8:
+		date, err := time.Parse("20060102", value)
+		if err != nil {
+			return err
+		}
+		r.Until = date.Add(24*time.Hour - time.Second)
+		r.untilWall = true
+	case strings.HasSuffix(value, "Z"):
+		until, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return err
+		}
+		r.Until = until
+	default:
+		until, err := time.Parse("20060102T150405", value)
+		if err != nil {
+			return err
+		}
+		r.Until = until
+		r.untilWall = true
+	}
+	return nil
+}
+
+func parseWeekdayNum(value string) (WeekdayNum, error) {
+	value = strings.TrimSpace(value)
+	if len(value) < 2 {
+		return WeekdayNum{}, fmt.Errorf("invalid weekday %q", value)
+	}
+	weekday, exists := weekdays[value[len(value)-2:]]
+	if !exists {
+		return WeekdayNum{}, fmt.Errorf("invalid weekday %q", value)
+	}
+	n := 0
+	if prefix := value[:len(value)-2]; prefix != "" {
+		var err error
+		if n, err = strconv.Atoi(strings.TrimPrefix(prefix, "+")); err != nil || n == 0 {
+			return WeekdayNum{}, fmt.Errorf("invalid weekday %q", value)
+		}
+	}
+	return WeekdayNum{Weekday: weekday, N: n}, nil
+}
+
+// parseNumbers reads a list of numbers between -max and max, excluding zero
+func parseNumbers(value string, min, max int) ([]int, error) {
+	var numbers []int
+	for _, part := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(part), "+"))
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 || n > max || n < -max || (n > 0 && n < min) {
+			return nil, fmt.Errorf("%d is out of range", n)
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, nil
+}
+
+// Between returns the occurrences starting from dtstart whose instant falls in from to to.
+// Occurrences are wall clock times in UTC fields; resolve turns one into an instant.
+// DTSTART is always the first occurrence.
+func (r *Rule) Between(dtstart time.Time, resolve func(time.Time) time.Time, from, to time.Time) []time.Time {
+	var occurrences []time.Time
+	count := 0
+
+	// emit handles the next occurrence in order, returning false once the rule has ended
+	emit := func(wall time.Time) bool {
+		if r.Count > 0 && count >= r.Count {
+			return false
+		}
+		instant := resolve(wall)
+		if !r.Until.IsZero() {
+			if (r.untilWall && wall.After(r.Until)) || (!r.untilWall && instant.After(r.Until)) {
+				return false
+			}
+		}
+		if instant.After(to) {
+			return false
+		}
+		count++
+		if !instant.Before(from) {
+			occurrences = append(occurrences, wall)
+		}
+		return true
+	}
+
+	if !emit(dtstart) {
+		return occurrences
+	}
+	for period := 0; period < maxPeriods; period++ {
+		anchor := r.periodStart(dtstart, period)
+		if resolve(anchor).After(to) {
+			break
+		}
+		for _, wall := range r.candidates(dtstart, anchor) {
+			if !wall.After(dtstart) {
+				continue
+			}
+			if !emit(wall) {
+				return occurrences
+			}
+		}
+	}
+	return occurrences
+}
+
+// periodStart returns the first day of the nth period of the rule, at midnight
+func (r *Rule) periodStart(dtstart time.Time, n int) time.Time {
+	day := time.Date(dtstart.Year(), dtstart.Month(), dtstart.Day(), 0, 0, 0, 0, time.UTC)
+	step := n * r.Interval
+	switch r.Freq {
+	case Daily:
+		return day.AddDate(0, 0, step)
+	case Weekly:
+		back := (int(day.Weekday()) - int(r.WeekStart) + 7) % 7
+		return day.AddDate(0, 0, -back+7*step)
+	case Monthly:
+		return time.Date(day.Year(), day.Month()+time.Month(step), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(day.Year()+step, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// candidates returns the occurrences of one period in order, at the time of day of dtstart
+func (r *Rule) candidates(dtstart, anchor time.Time) []time.Time {
+	var days []time.Time
+	switch r.Freq {
+	case Daily:
+		if r.matchesMonth(anchor) && r.matchesMonthDay(anchor) && r.matchesWeekday(anchor) {
+			days = append(days, anchor)
+		}
+	case Weekly:
+		for i := 0; i < 7; i++ {
+			day := anchor.AddDate(0, 0, i)
+			if !r.matchesMonth(day) {
+				continue
+			}
+			if len(r.ByDay) == 0 && day.Weekday() != dtstart.Weekday() {
+				continue
+			}
+			if len(r.ByDay) > 0 && !r.matchesWeekday(day) {
+				continue
+			}
+			days = append(days, day)
+		}
+	case Monthly:
+		if r.matchesMonth(anchor) {
+			days = r.monthDays(dtstart, anchor.Year(), anchor.Month())
+		}
+	case Yearly:
+		if len(r.ByMonth) == 0 && len(r.ByDay) > 0 && len(r.ByMonthDay) == 0 {
+			days = r.yearWeekdays(anchor.Year())
+		} else {
+			months := r.ByMonth
+			if len(months) == 0 && len(r.ByMonthDay) > 0 {
+				months = []time.Month{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+			} else if len(months) == 0 {
+				months = []time.Month{dtstart.Month()}
+			}
+			for _, month := range months {
+				days = append(days, r.monthDays(dtstart, anchor.Year(), month)...)
+			}
+		}
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	days = r.applySetPos(days)
+
+	occurrences := make([]time.Time, 0, len(days))
+	for _, day := range days {
+		occurrences = append(occurrences, time.Date(day.Year(), day.Month(), day.Day(), dtstart.Hour(), dtstart.Minute(), dtstart.Second(), 0, time.UTC))
+	}
+	return occurrences
+}
+
+// monthDays returns the days of a month matching BYMONTHDAY and BYDAY, or the day of
+// dtstart when neither is given. Days a short month does not have are skipped.
+func (r *Rule) monthDays(dtstart time.Time, year int, month time.Month) []time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	length := first.AddDate(0, 1, -1).Day()
+
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		if dtstart.Day() > length {
+			return nil
+		}
+		return []time.Time{first.AddDate(0, 0, dtstart.Day()-1)}
+	}
+
+	var days []time.Time
+	for d := 1; d <= length; d++ {
+		day := first.AddDate(0, 0, d-1)
+		if len(r.ByMonthDay) > 0 && !r.matchesMonthDay(day) {
+			continue
+		}
+		if len(r.ByDay) > 0 && !matchesWeekdayNum(r.ByDay, day, d, length) {
+			continue
+		}
+		days = append(days, day)
+	}
+	return days
+}
+
+// yearWeekdays returns the days of a year matching BYDAY, where 20MO is the 20th Monday of the year
+func (r *Rule) yearWeekdays(year int) []time.Time {
+	first := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	length := first.AddDate(1, 0, -1).YearDay()
+
+	var days []time.Time
+	for d := 1; d <= length; d++ {
+		day := first.AddDate(0, 0, d-1)
+		if matchesWeekdayNum(r.ByDay, day, d, length) {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// matchesWeekdayNum checks a day, the dth of a period of length days, against BYDAY
+func matchesWeekdayNum(byDay []WeekdayNum, day time.Time, d, length int) bool {
+	for _, weekday := range byDay {
+		if weekday.Weekday != day.Weekday() {
+			continue
+		}
+		switch {
+		case weekday.N == 0:
+			return true
+		case weekday.N > 0 && (d-1)/7+1 == weekday.N:
+			return true
+		case weekday.N < 0 && (length-d)/7+1 == -weekday.N:
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesMonth(day time.Time) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, month := range r.ByMonth {
+		if day.Month() == month {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesMonthDay(day time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	length := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, monthDay := range r.ByMonthDay {
+		if monthDay == day.Day() || (monthDay < 0 && length+1+monthDay == day.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesWeekday(day time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, weekday := range r.ByDay {
+		if weekday.Weekday == day.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// applySetPos keeps the nth days of a period, such as the last weekday of a month with BYSETPOS=-1
+func (r *Rule) applySetPos(days []time.Time) []time.Time {
+	if len(r.BySetPos) == 0 || len(days) == 0 {
+		return days
+	}
+	var kept []time.Time
+	for i, day := range days {
+		for _, pos := range r.BySetPos {
+			if pos == i+1 || pos == i-len(days) {
+				kept = append(kept, day)
+				break
+			}
+		}
+	}
+	return kept
+}
@@ -0,0 +1,304 @@
+package ical
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// zone turns a wall clock time, held in UTC fields, into an instant
+type zone interface {
+	at(wall time.Time) time.Time
+}
+
+// locationZone places wall clock times in a Go location
+type locationZone struct {
+	loc *time.Location
+}
+
+func (z locationZone) at(wall time.Time) time.Time {
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, z.loc)
+}
+
+// zoneSet resolves the TZID parameters of a calendar
+type zoneSet struct {
+	floating zone            // For times without a zone and for all-day dates
+	defined  map[string]zone // VTIMEZONE components by TZID
+}
+
+// lookup returns the zone for a TZID. IANA names use Go's zone data, anything else, such
+// as the Windows names Outlook writes, uses the calendar's own VTIMEZONE.
+func (zs zoneSet) lookup(tzid string) zone {
+	tzid = strings.TrimPrefix(strings.Trim(tzid, `"`), "/")
+	if tzid == "" {
+		return zs.floating
+	}
+	if loc, err := time.LoadLocation(tzid); err == nil {
+		return locationZone{loc}
+	}
+	if defined, exists := zs.defined[tzid]; exists {
+		return defined
+	}
+	return zs.floating
+}
+
+// dateTimeValue is a parsed DATE or DATE-TIME
+type dateTimeValue struct {
+	wall time.Time // Wall clock in UTC fields
+	zone zone
+	date bool // A DATE without a time of day
+}
+
+// parseDateTime reads a DATE or DATE-TIME property
+func parseDateTime(property Property, zones zoneSet) (dateTimeValue, error) {
+	return parseDateTimeValue(strings.TrimSpace(property.Value), property.Params, zones)
+}
+
+// parseDateTimeList reads a property holding a comma separated list of dates or times
+func parseDateTimeList(property Property, zones zoneSet) ([]time.Time, error) {
+	var times []time.Time
+	for _, value := range strings.Split(property.Value, ",") {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		parsed, err := parseDateTimeValue(strings.TrimSpace(value), property.Params, zones)
+		if err != nil {
+			return nil, err
+		}
+		times = append(times, parsed.zone.at(parsed.wall))
+	}
+	return times, nil
+}
+
+func parseDateTimeValue(value string, params map[string]string, zones zoneSet) (dateTimeValue, error) {
+	if strings.EqualFold(params["VALUE"], "DATE") || len(value) == 8 {
+		wall, err := time.Parse("20060102", value)
+		if err != nil {
+			return dateTimeValue{}, err
+		}
+		return dateTimeValue{wall: wall, zone: zones.floating, date: true}, nil
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		wall, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return dateTimeValue{}, err
+		}
+		return dateTimeValue{wall: wall, zone: locationZone{time.UTC}}, nil
+	}
+
+	wall, err := time.Parse("20060102T150405", value)
+	if err != nil {
+		return dateTimeValue{}, err
+	}
+	return dateTimeValue{wall: wall, zone: zones.lookup(params["TZID"])}, nil
+}
+
+// timeZone is a VTIMEZONE, a list of observances each switching to an offset from its onsets
+type timeZone struct {
+	id          string
+	observances []observance
+
+	mu    sync.Mutex
+	years map[int][]onset // Onsets by year, as looking them up walks the rules from their start
+}
+
+// onset is a switch to an offset
+type onset struct {
+	wall   time.Time
+	offset int
+}
+
+// observance is a STANDARD or DAYLIGHT block
+type observance struct {
+	start      time.Time // First onset as a wall clock in the offset before it
+	offsetFrom int       // Seconds east of UTC
+	offsetTo   int
+	rule       *Rule
+	dates      []time.Time // RDATE onsets
+}
+
+// parseTimeZone reads a VTIMEZONE
+func parseTimeZone(c *Component) (*timeZone, error) {
+	tzid := c.Get("TZID")
+	if tzid == nil {
+		return nil, fmt.Errorf("time zone has no TZID")
+	}
+	tz := &timeZone{id: strings.TrimPrefix(strings.TrimSpace(tzid.Value), "/"), years: make(map[int][]onset)}
+
+	for _, child := range c.Components {
+		if child.Name != "STANDARD" && child.Name != "DAYLIGHT" {
+			continue
+		}
+		dtstart, from, to := child.Get("DTSTART"), child.Get("TZOFFSETFROM"), child.Get("TZOFFSETTO")
+		if dtstart == nil || from == nil || to == nil {
+			continue
+		}
+		start, err := time.Parse("20060102T150405", strings.TrimSpace(dtstart.Value))
+		if err != nil {
+			continue
+		}
+		offsetFrom, err := parseUTCOffset(from.Value)
+		if err != nil {
+			continue
+		}
+		offsetTo, err := parseUTCOffset(to.Value)
+		if err != nil {
+			continue
+		}
+
+		obs := observance{start: start, offsetFrom: offsetFrom, offsetTo: offsetTo}
+		if rrule := child.Get("RRULE"); rrule != nil {
+			if rule, err := ParseRule(rrule.Value); err == nil {
+				obs.rule = rule
+			}
+		}
+		for _, rdate := range child.GetAll("RDATE") {
+			for _, value := range strings.Split(rdate.Value, ",") {
+				if date, err := time.Parse("20060102T150405", strings.TrimSpace(value)); err == nil {
+					obs.dates = append(obs.dates, date)
+				}
+			}
+		}
+		tz.observances = append(tz.observances, obs)
+	}
+
+	if len(tz.observances) == 0 {
+		return nil, fmt.Errorf("time zone %s has no observances", tz.id)
+	}
+	return tz, nil
+}
+
+// at uses the offset of the observance with the latest onset at or before the wall clock
+func (tz *timeZone) at(wall time.Time) time.Time {
+	// Zones switch at least yearly, so the onset in force is nearly always in this year or the last
+	for year := wall.Year(); year >= wall.Year()-1; year-- {
+		onsets := tz.onsets(year)
+		for i := len(onsets) - 1; i >= 0; i-- {
+			if !onsets[i].wall.After(wall) {
+				return wall.Add(-time.Duration(onsets[i].offset) * time.Second).In(time.UTC)
+			}
+		}
+	}
+	return tz.search(wall)
+}
+
+// onsets returns the onsets of every observance within a year, in order
+func (tz *timeZone) onsets(year int) []onset {
+	tz.mu.Lock()
+	defer tz.mu.Unlock()
+
+	if onsets, cached := tz.years[year]; cached {
+		return onsets
+	}
+	from := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(1, 0, 0).Add(-time.Second)
+
+	var onsets []onset
+	for _, obs := range tz.observances {
+		var walls []time.Time
+		if obs.rule != nil {
+			walls = obs.rule.Between(obs.start, wallClock, from, to)
+		} else if !obs.start.Before(from) && !obs.start.After(to) {
+			walls = append(walls, obs.start)
+		}
+		for _, date := range obs.dates {
+			if !date.Before(from) && !date.After(to) {
+				walls = append(walls, date)
+			}
+		}
+		for _, wall := range walls {
+			onsets = append(onsets, onset{wall: wall, offset: obs.offsetTo})
+		}
+	}
+	sort.Slice(onsets, func(i, j int) bool { return onsets[i].wall.Before(onsets[j].wall) })
+	tz.years[year] = onsets
+	return onsets
+}
+
+// search finds the offset in force by looking through every onset
+func (tz *timeZone) search(wall time.Time) time.Time {
+	offset := 0
+	var latest time.Time
+	found := false
+	for _, obs := range tz.observances {
+		onset, ok := obs.lastOnset(wall)
+		if ok && (!found || onset.After(latest)) {
+			latest = onset
+			offset = obs.offsetTo
+			found = true
+		}
+	}
+	if !found {
+		// Before every onset, so the offset in force is the one the earliest observance leaves
+		earliest := tz.observances[0]
+		for _, obs := range tz.observances[1:] {
+			if obs.start.Before(earliest.start) {
+				earliest = obs
+			}
+		}
+		offset = earliest.offsetFrom
+	}
+	return wall.Add(-time.Duration(offset) * time.Second).In(time.UTC)
+}
+
+// lastOnset returns the latest onset of an observance at or before a wall clock time
+func (obs observance) lastOnset(wall time.Time) (time.Time, bool) {
+	var onsets []time.Time
+	if !obs.start.After(wall) {
+		onsets = append(onsets, obs.start)
+	}
+	if obs.rule != nil {
+		onsets = append(onsets, obs.rule.Between(obs.start, wallClock, obs.start, wall)...)
+	}
+	for _, date := range obs.dates {
+		if !date.After(wall) {
+			onsets = append(onsets, date)
+		}
+	}
+	if len(onsets) == 0 {
+		return time.Time{}, false
+	}
+	sort.Slice(onsets, func(i, j int) bool { return onsets[i].Before(onsets[j]) })
+	return onsets[len(onsets)-1], true
+}
+
+// wallClock compares onsets in wall clock terms
+func wallClock(wall time.Time) time.Time {
+	return wall
+}
+
+// parseUTCOffset parses an offset such as -0500 or +053000 into seconds east of UTC
+func parseUTCOffset(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if len(value) != 5 && len(value) != 7 {
+		return 0, fmt.Errorf("invalid UTC offset %q", value)
+	}
+	sign := 1
+	switch value[0] {
+	case '-':
+		sign = -1
+	case '+':
+	default:
+		return 0, fmt.Errorf("invalid UTC offset %q", value)
+	}
+
+	hours, err := strconv.Atoi(value[1:3])
+	if err != nil {
+		return 0, fmt.Errorf("invalid UTC offset %q", value)
+	}
+	minutes, err := strconv.Atoi(value[3:5])
+	if err != nil {
+		return 0, fmt.Errorf("invalid UTC offset %q", value)
+	}
+	seconds := 0
+	if len(value) == 7 {
+		if seconds, err = strconv.Atoi(value[5:7]); err != nil {
+			return 0, fmt.Errorf("invalid UTC offset %q", value)
+		}
+	}
+	return sign * (hours*3600 + minutes*60 + seconds), nil
+}
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+const (
+	// CalendarProviderCalDAV is the provider of accounts on CalDAV servers such as iCloud and Nextcloud
+	CalendarProviderCalDAV = "caldav"
+	// CalendarProviderMicrosoft is the provider of Microsoft 365 and Outlook.com accounts, linked
+	// by signing in with Microsoft
+	CalendarProviderMicrosoft = "microsoft"
+)
+
+// CalendarAccountConfig represents configuration for linked calendar accounts
+type CalendarAccountConfig struct {
+	SyncInterval     time.Duration  `json:"sync_interval"`      // Time between syncs of each account
+	FullSyncInterval time.Duration  `json:"full_sync_interval"` // How often calendars are listed again in full
+	PastDays         int            `json:"past_days"`          // Days before today kept in the local copy
+	FutureDays       int            `json:"future_days"`        // Days after today recurring events are expanded to
+	Location         *time.Location `json:"-"`                  // For all-day dates and times without a zone
+	GraphURL         string         `json:"graph_url"`          // Microsoft Graph API, for tests
+}
+
+// CalendarAccount is a calendar account linked besides the household Google account, such as
+// a family member's iCloud or Nextcloud calendars or a parent's Microsoft 365 work calendar
+type CalendarAccount struct {
+	ID        string                    `json:"id"`
+	Name      string                    `json:"name"`
+	Provider  string                    `json:"provider"`
+	URL       string                    `json:"url,omitempty"`      // CalDAV accounts only
+	Username  string                    `json:"username"`           // Email address of Microsoft accounts
+	Password  string                    `json:"password,omitempty"` // App password, never returned by the API
+	Enabled   bool                      `json:"enabled"`
+	Calendars []CalendarAccountCalendar `json:"calendars"`
+	LastSync  *time.Time                `json:"last_sync,omitempty"`
+	LastError string                    `json:"last_error,omitempty"`
+	UpdatedAt time.Time                 `json:"updated_at"`
+}
+
+// CalendarAccountCalendar is a calendar of a linked account
+type CalendarAccountCalendar struct {
+	ID        string     `json:"id"` // Dashboard calendar ID
+	Name      string     `json:"name"`
+	Color     string     `json:"color"`
+	ReadOnly  bool       `json:"read_only,omitempty"`
+	Events    int        `json:"events"`
+	LastSync  *time.Time `json:"last_sync,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// CalendarAccountEventInput is an event created or changed on a linked account. Start and
+// End are RFC 3339 times, or dates for all-day events.
+type CalendarAccountEventInput struct {
+	CalendarID  string `json:"calendar_id"` // Dashboard calendar ID, when creating
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Location    string `json:"location"`
+	Start       string `json:"start"`
+	End         string `json:"end"`
+	AllDay      bool   `json:"all_day"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// CalendarDigestConfig represents configuration for the daily agenda email
+type CalendarDigestConfig struct {
+	CheckInterval time.Duration  `json:"check_interval"`
+	SendWindow    time.Duration  `json:"send_window"` // How late the digest is still sent, such as after a restart
+	TemplatePath  string         `json:"template_path"`
+	Location      *time.Location `json:"-"` // Home time zone, defaults to the server's
+}
+
+// CalendarDigestSettings are the schedule, recipients and calendars of the daily agenda email
+type CalendarDigestSettings struct {
+	Enabled     bool       `json:"enabled"`
+	Time        string     `json:"time"` // HH:MM in the home time zone
+	Recipients  []string   `json:"recipients"`
+	CalendarIDs []string   `json:"calendar_ids"` // Every calendar when empty
+	LastSent    *time.Time `json:"last_sent,omitempty"`
+	LastAttempt *time.Time `json:"last_attempt,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// CalendarDigest is the agenda of today and tomorrow as the email shows it
+type CalendarDigest struct {
+	Subject     string              `json:"subject"`
+	GeneratedAt time.Time           `json:"generated_at"`
+	Days        []CalendarDigestDay `json:"days"`
+}
+
+// CalendarDigestDay is the agenda of one day, grouped by calendar
+type CalendarDigestDay struct {
+	Label     string                `json:"label"` // "Today" or "Tomorrow"
+	Date      string                `json:"date"`  // Such as "Friday, October 25"
+	Groups    []CalendarDigestGroup `json:"groups"`
+	Events    int                   `json:"events"`
+	Conflicts int                   `json:"conflicts"` // Events overlapping another event of the day
+}
+
+// CalendarDigestGroup is the events of one calendar on one day, all-day events first
+type CalendarDigestGroup struct {
+	CalendarID string                `json:"calendar_id"`
+	Name       string                `json:"name"`
+	Account    string                `json:"account,omitempty"` // Google account the calendar belongs to
+	Color      string                `json:"color,omitempty"`
+	Events     []CalendarDigestEvent `json:"events"`
+}
+
+// CalendarDigestEvent is one event of the agenda
+type CalendarDigestEvent struct {
+	Title         string   `json:"title"`
+	Time          string   `json:"time"` // Such as "All day" or "4:00 PM - 5:30 PM"
+	AllDay        bool     `json:"all_day,omitempty"`
+	Conflict      bool     `json:"conflict,omitempty"`
+	ConflictsWith []string `json:"conflicts_with,omitempty"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// CalendarExportConfig represents configuration for the exported family calendar
+type CalendarExportConfig struct {
+	Name       string         `json:"name"`        // Calendar name shown by subscribing apps
+	PastDays   int            `json:"past_days"`   // Days before today included in the export
+	FutureDays int            `json:"future_days"` // Days after today included in the export
+	Location   *time.Location `json:"-"`           // Zone timed events are written in
+}
+
+// CalendarSubscription is a secret link to the family calendar, for phones and other
+// calendar apps that cannot sign in. Removing it revokes the link.
+type CalendarSubscription struct {
+	ID        string     `json:"id"`
+	UserID    int        `json:"user_id"`
+	Name      string     `json:"name"`                // Such as "Mom's phone"
+	Calendars []string   `json:"calendars,omitempty"` // Every calendar when empty
+	TokenHash string     `json:"-"`                   // SHA-256 of the secret, which is only shown once
+	CreatedAt time.Time  `json:"created_at"`
+	LastUsed  *time.Time `json:"last_used,omitempty"`
+}
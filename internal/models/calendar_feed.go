@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// CalendarFeedConfig represents configuration for ICS feed subscriptions
+type CalendarFeedConfig struct {
+	RefreshInterval time.Duration  `json:"refresh_interval"` // Default time between fetches of a feed
+	CheckInterval   time.Duration  `json:"check_interval"`   // How often feeds are checked for being due
+	FetchTimeout    time.Duration  `json:"fetch_timeout"`
+	MaxSize         int64          `json:"max_size"` // Largest feed accepted, in bytes
+	Location        *time.Location `json:"-"`        // For all-day dates and times without a zone
+}
+
+// CalendarFeed is a subscribed iCalendar feed, such as a school or trash pickup calendar
+type CalendarFeed struct {
+	ID             string     `json:"id"`
+	Name           string     `json:"name"` // Taken from the feed when left empty
+	URL            string     `json:"url"`
+	Color          string     `json:"color"`
+	Enabled        bool       `json:"enabled"`
+	RefreshMinutes int        `json:"refresh_minutes,omitempty"` // Overrides the default refresh interval
+	Events         int        `json:"events"`
+	ETag           string     `json:"etag,omitempty"`
+	LastModified   string     `json:"last_modified,omitempty"`
+	LastFetch      *time.Time `json:"last_fetch,omitempty"`
+	LastChange     *time.Time `json:"last_change,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
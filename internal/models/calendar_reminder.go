@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// Channels a calendar's reminders are delivered through
+const (
+	ReminderChannelDashboard = "dashboard" // Toast on the dashboards, over the event stream
+	ReminderChannelEmail     = "email"
+	ReminderChannelSonos     = "sonos" // Spoken announcement
+	ReminderChannelHue       = "hue"   // Light flash
+)
+
+// Delivery states of a reminder
+const (
+	ReminderDelivered = "delivered"
+	ReminderFailed    = "failed" // Retried until it is too late
+	ReminderMissed    = "missed" // Came due while WoodHome was down for too long
+)
+
+// CalendarReminderConfig represents configuration for the calendar reminder scheduler
+type CalendarReminderConfig struct {
+	CheckInterval  time.Duration  `json:"check_interval"`
+	Lookahead      time.Duration  `json:"lookahead"`       // How far ahead events are read, the longest reminder delivered
+	LateWindow     time.Duration  `json:"late_window"`     // How late a reminder is still delivered, such as after a restart
+	MaxAttempts    int            `json:"max_attempts"`    // Deliveries tried before a reminder is given up
+	DefaultMinutes []int          `json:"default_minutes"` // For events without reminders of their own
+	Location       *time.Location `json:"-"`               // For all-day events
+}
+
+// CalendarReminderRule chooses how the reminders of one calendar are delivered in the home.
+// Calendars without a rule are not reminded of.
+type CalendarReminderRule struct {
+	CalendarID     string    `json:"calendar_id"`
+	Channel        string    `json:"channel"`
+	Enabled        bool      `json:"enabled"`
+	Target         string    `json:"target,omitempty"`          // Sonos room, every room when empty, or Hue group, all lights of every bridge when empty
+	Volume         int       `json:"volume,omitempty"`          // Of Sonos announcements, the current volume when 0
+	Recipients     []string  `json:"recipients,omitempty"`      // Of emails
+	DefaultMinutes []int     `json:"default_minutes,omitempty"` // For events without reminders of their own
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// CalendarReminder is one reminder of an event occurrence and how its delivery went
+type CalendarReminder struct {
+	ID          string     `json:"id"`
+	EventID     string     `json:"event_id"`
+	CalendarID  string     `json:"calendar_id"`
+	Title       string     `json:"title"`
+	EventStart  time.Time  `json:"event_start"`
+	AllDay      bool       `json:"all_day,omitempty"`
+	Minutes     int        `json:"minutes"` // Before the start of the event
+	DueAt       time.Time  `json:"due_at"`
+	Channel     string     `json:"channel"`
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
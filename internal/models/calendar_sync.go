@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// CalendarSyncConfig represents configuration for the local calendar store
+type CalendarSyncConfig struct {
+	UserID   int           `json:"user_id"` // Account whose calendars are synced
+	Interval time.Duration `json:"interval"`
+	PastDays int           `json:"past_days"` // How far back the first sync of a calendar reaches
+}
+
+// CalendarSyncCalendar is the sync state of one calendar
+type CalendarSyncCalendar struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	Color        string     `json:"color"`
+	Events       int        `json:"events"`
+	LastSync     *time.Time `json:"last_sync,omitempty"`
+	LastFullSync *time.Time `json:"last_full_sync,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+}
+
+// CalendarSyncStatus reports the local calendar store
+type CalendarSyncStatus struct {
+	Ready     bool                    `json:"ready"` // Events are served from the store
+	LastSync  *time.Time              `json:"last_sync,omitempty"`
+	LastError string                  `json:"last_error,omitempty"`
+	Calendars []*CalendarSyncCalendar `json:"calendars"`
+}
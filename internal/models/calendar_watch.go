@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// CalendarWatchConfig represents configuration for Google Calendar push notifications
+type CalendarWatchConfig struct {
+	WebhookURL    string        `json:"webhook_url"` // Public address Google posts notifications to
+	TTL           time.Duration `json:"ttl"`         // Lifetime asked for when opening a channel
+	RenewBefore   time.Duration `json:"renew_before"`
+	CheckInterval time.Duration `json:"check_interval"`
+}
+
+// CalendarWatchChannel is a push notification channel open on one calendar
+type CalendarWatchChannel struct {
+	ID           string     `json:"id"`
+	CalendarID   string     `json:"calendar_id"`
+	CalendarName string     `json:"calendar_name"`
+	ResourceID   string     `json:"resource_id"`
+	Expiration   time.Time  `json:"expiration"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastMessage  *time.Time `json:"last_message,omitempty"`
+	Messages     int        `json:"messages"`
+}
+
+// CalendarWatchNotification is a push notification as received from Google
+type CalendarWatchNotification struct {
+	ChannelID     string `json:"channel_id"`     // X-Goog-Channel-ID
+	Token         string `json:"token"`          // X-Goog-Channel-Token
+	ResourceID    string `json:"resource_id"`    // X-Goog-Resource-ID
+	ResourceState string `json:"resource_state"` // X-Goog-Resource-State: sync, exists or not_exists
+	MessageNumber string `json:"message_number"` // X-Goog-Message-Number
+}
+
+// CalendarWatchStatus reports the open push notification channels
+type CalendarWatchStatus struct {
+	Enabled  bool                    `json:"enabled"`
+	Stopped  bool                    `json:"stopped"` // Channels were stopped by a logout
+	Channels []*CalendarWatchChannel `json:"channels"`
+}
@@ -0,0 +1,9 @@
+package models
+
+// EmailConfig represents the SMTP settings used to send household emails
+type EmailConfig struct {
+	SMTPHost string `json:"smtp_host"`
+	SMTPPort int    `json:"smtp_port"`
+	From     string `json:"from"`
+	Password string `json:"-"`
+}
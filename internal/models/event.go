@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Event types published on the event bus
+const (
+	EventHueMotion = "hue.motion"
+	EventHueButton = "hue.button"
+
+	EventHueRoutine  = "hue.routine"
+	EventHomeScene   = "home.scene"
+	EventHomeRoutine = "home.routine"
+	EventSolar       = "solar.trigger"
+
+	EventCalendarReminder = "calendar.reminder"
+)
+
+// Event represents something that happened in the home
+type Event struct {
+	Type      string      `json:"type"`
+	Source    string      `json:"source"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data,omitempty"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// GoogleAccount is a Google account linked to the household, such as each parent's and a
+// shared family account. Its OAuth token is kept in the oauth_tokens row of UserID.
+type GoogleAccount struct {
+	UserID    int        `json:"user_id"`
+	Email     string     `json:"email"`   // Empty until the account has been identified
+	Primary   bool       `json:"primary"` // Linked first, used by the calendar sync and day checks
+	Calendars int        `json:"calendars"`
+	LinkedAt  time.Time  `json:"linked_at"`
+	LastSync  *time.Time `json:"last_sync,omitempty"` // Last time its calendars were listed
+	LastError string     `json:"last_error,omitempty"`
+}
@@ -0,0 +1,85 @@
+package models
+
+import "time"
+
+// Routine step kinds, used alongside the home scene step kinds
+const (
+	HomeRoutineStepWait     = "wait"     // Pause the branch for DurationSec
+	HomeRoutineStepFade     = "fade"     // Move a Hue room or zone to State over DurationSec, then continue
+	HomeRoutineStepParallel = "parallel" // Run Branches at the same time and continue when all are done
+)
+
+// What happens to a routine that was running when the server stopped
+const (
+	HomeRoutineRestartResume = "resume" // Carry on from the first unfinished step
+	HomeRoutineRestartAbort  = "abort"  // Stop it and report it as aborted
+)
+
+// Routine run statuses
+const (
+	HomeRoutineRunning   = "running"
+	HomeRoutineCompleted = "completed"
+	HomeRoutineCancelled = "cancelled"
+	HomeRoutineAborted   = "aborted"
+)
+
+// HomeRoutine is a graph of Hue and Sonos steps with waits and parallel branches, such as "Good night"
+type HomeRoutine struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Steps       []HomeRoutineStep `json:"steps"`
+	OnRestart   string            `json:"on_restart"` // resume or abort, defaults to abort
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// HomeRoutineStep is a home scene step, a wait, a fade or a set of parallel branches
+type HomeRoutineStep struct {
+	HomeSceneStep
+	Label       string              `json:"label,omitempty"`
+	DurationSec int                 `json:"duration_sec,omitempty"` // wait and fade steps
+	Branches    [][]HomeRoutineStep `json:"branches,omitempty"`     // parallel steps
+}
+
+// HomeRoutineStepResult is the outcome of one step of a run
+type HomeRoutineStepResult struct {
+	Path   string    `json:"path"` // "2", or "2.1.0" for the first step of branch 1 of step 2
+	Kind   string    `json:"kind"`
+	Target string    `json:"target,omitempty"`
+	Action string    `json:"action,omitempty"`
+	Label  string    `json:"label,omitempty"`
+	Result string    `json:"result"`
+	Error  string    `json:"error,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// HomeRoutineCurrentStep is a step that is running now
+type HomeRoutineCurrentStep struct {
+	Path   string     `json:"path"`
+	Kind   string     `json:"kind"`
+	Target string     `json:"target,omitempty"`
+	Label  string     `json:"label,omitempty"`
+	Until  *time.Time `json:"until,omitempty"` // End of a wait or fade
+}
+
+// HomeRoutineRun reports the progress of a routine. The steps are copied when the run
+// starts, so editing the routine does not change a run that is resumed after a restart.
+type HomeRoutineRun struct {
+	ID          string                   `json:"id"`
+	RoutineID   string                   `json:"routine_id"`
+	RoutineName string                   `json:"routine_name"`
+	Steps       []HomeRoutineStep        `json:"steps"`
+	OnRestart   string                   `json:"on_restart"`
+	Status      string                   `json:"status"`
+	StartedAt   time.Time                `json:"started_at"`
+	FinishedAt  *time.Time               `json:"finished_at,omitempty"`
+	Total       int                      `json:"total"` // Steps other than parallel ones
+	Done        int                      `json:"done"`
+	Percent     int                      `json:"percent"`
+	Current     []HomeRoutineCurrentStep `json:"current"`
+	Completed   []string                 `json:"completed"`            // Paths of finished steps
+	WaitUntil   map[string]time.Time     `json:"wait_until,omitempty"` // End of started waits and fades by path
+	Results     []HomeRoutineStepResult  `json:"results"`
+	Failed      int                      `json:"failed"`
+	Resumed     int                      `json:"resumed,omitempty"` // Times the run was resumed after a restart
+}
@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+// Home scene step kinds
+const (
+	HomeSceneStepHueScene = "hue_scene" // Recall a Hue scene
+	HomeSceneStepHueGroup = "hue_group" // Set the state of a Hue room or zone
+	HomeSceneStepHueLight = "hue_light" // Set the state of a single Hue light
+	HomeSceneStepSonos    = "sonos"     // Run a Sonos action
+)
+
+// Sonos actions of a home scene step
+const (
+	HomeSceneSonosPlay        = "play"
+	HomeSceneSonosPause       = "pause"
+	HomeSceneSonosStop        = "stop"
+	HomeSceneSonosVolume      = "volume"       // Value is 0-100
+	HomeSceneSonosMute        = "mute"         // Value is "on" or "off"
+	HomeSceneSonosLineIn      = "linein"       // TV input on soundbars; Value optionally names the source player
+	HomeSceneSonosNightMode   = "nightmode"    // Value is "on" or "off"
+	HomeSceneSonosFavorite    = "favorite"     // Value is the favorite name
+	HomeSceneSonosJoin        = "join"         // Value is the player to join
+	HomeSceneSonosLeave       = "leave"        // Leave the current group
+	HomeSceneSonosPauseOthers = "pause_others" // Pause every player outside the target's group
+)
+
+// Step results
+const (
+	HomeSceneResultOK      = "ok"
+	HomeSceneResultFailed  = "failed"
+	HomeSceneResultSkipped = "skipped"
+)
+
+// HomeScene is a named list of Hue and Sonos actions run in order, such as "Movie Night"
+type HomeScene struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Steps       []HomeSceneStep `json:"steps"`
+	Snapshot    bool            `json:"snapshot"` // Save the prior state so the scene can be ended
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// HomeSceneStep is one action of a home scene
+type HomeSceneStep struct {
+	Kind    string         `json:"kind"`
+	Target  string         `json:"target"`           // Hue scene, group or light ID, or Sonos room name
+	State   *HueGroupState `json:"state,omitempty"`  // hue_group and hue_light steps
+	Action  string         `json:"action,omitempty"` // sonos steps
+	Value   string         `json:"value,omitempty"`
+	DelayMs int            `json:"delay_ms,omitempty"` // Wait before the step
+}
+
+// HomeSceneStepResult is the outcome of one step
+type HomeSceneStepResult struct {
+	Step   int       `json:"step"` // Index into the scene steps, or the restored item when ending
+	Kind   string    `json:"kind"`
+	Target string    `json:"target"`
+	Action string    `json:"action,omitempty"`
+	Result string    `json:"result"`
+	Error  string    `json:"error,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// HomeSceneActivation reports a run of a home scene
+type HomeSceneActivation struct {
+	SceneID     string                `json:"scene_id"`
+	StartedAt   time.Time             `json:"started_at"`
+	FinishedAt  time.Time             `json:"finished_at"`
+	Results     []HomeSceneStepResult `json:"results"`
+	Failed      int                   `json:"failed"`
+	Active      bool                  `json:"active"` // A snapshot is held and the scene can be ended
+	EndedAt     *time.Time            `json:"ended_at,omitempty"`
+	EndResults  []HomeSceneStepResult `json:"end_results,omitempty"`
+	Interrupted bool                  `json:"interrupted,omitempty"` // Stopped before all steps ran
+}
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// HouseholdCalendarConfig represents configuration for the calendars kept in WoodHome
+type HouseholdCalendarConfig struct {
+	Location *time.Location `json:"-"` // Zone of events created without one
+}
+
+// HouseholdCalendar is a calendar stored in the WoodHome database rather than in someone's
+// Google account, for chores, the babysitter schedule or game nights
+type HouseholdCalendar struct {
+	ID        string    `json:"id"` // Dashboard calendar ID
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	Default   bool      `json:"default"` // Receives events posted to the primary calendar
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// HouseholdEvent is an event of a household calendar. A recurring event holds its first
+// occurrence and is expanded when events are listed.
+type HouseholdEvent struct {
+	ID          string          `json:"id"`
+	CalendarID  string          `json:"calendar_id"`
+	Title       string          `json:"title"`
+	Description string          `json:"description,omitempty"`
+	Location    string          `json:"location,omitempty"`
+	Start       time.Time       `json:"start"`
+	End         time.Time       `json:"end"` // The day after the last day of an all-day event
+	AllDay      bool            `json:"all_day"`
+	TimeZone    string          `json:"time_zone"`            // Recurrences keep their time of day in this zone
+	Recurrence  []string        `json:"recurrence,omitempty"` // RRULE lines, as Google writes them
+	ExDates     []time.Time     `json:"exdates,omitempty"`    // Starts of deleted occurrences
+	Reminders   *EventReminders `json:"reminders,omitempty"`
+	Color       string          `json:"color,omitempty"` // Overrides the calendar color
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// HouseholdEventInput is an event created or changed on a household calendar, in the shape
+// the dashboard's event modal posts: Google style start and end, where an all-day end may be
+// the last day itself. Summary is accepted in place of Title.
+type HouseholdEventInput struct {
+	CalendarID  string          `json:"calendarId"` // Household calendar ID, or primary for the default one
+	Title       string          `json:"title"`
+	Summary     string          `json:"summary"`
+	Description string          `json:"description"`
+	Location    string          `json:"location"`
+	AllDay      bool            `json:"allDay"`
+	Start       *EventDateTime  `json:"start"`
+	End         *EventDateTime  `json:"end"`
+	Recurrence  []string        `json:"recurrence"`
+	Reminders   *EventReminders `json:"reminders"`
+	ColorID     string          `json:"colorId"` // Google event color
+	Color       string          `json:"color"`   // Hex color, used when ColorID is empty
+}
@@ -6,7 +6,7 @@ import (
 
 // HueBridge represents a Philips Hue bridge
 type HueBridge struct {
-	ID           string    `json:"id"`
+	ID           string    `json:"id"` // Configured bridge name, the prefix of its resource IDs
 	Name         string    `json:"name"`
 	IP           string    `json:"ip"`
 	Username     string    `json:"username"`
@@ -24,50 +24,41 @@ type HueBridge struct {
 
 // HueLight represents a Philips Hue light
 type HueLight struct {
-	ID           string    `json:"id"`
-	Name         string    `json:"name"`
-	Type         string    `json:"type"`
-	ModelID      string    `json:"modelid"`
-	Manufacturer string    `json:"manufacturername"`
-	ProductName  string    `json:"productname"`
-	UniqueID     string    `json:"uniqueid"`
-	RoomID       string    `json:"room_id"`
-	RoomName     string    `json:"room_name"`
-	IsOn         bool      `json:"on"`
-	Brightness   int       `json:"bri"`        // 0-254
-	Hue          int       `json:"hue"`        // 0-65535
-	Saturation   int       `json:"sat"`        // 0-254
-	ColorTemp    int       `json:"ct"`         // 153-500 (mired)
-	ColorMode    string    `json:"colormode"`  // "xy", "ct", "hs"
-	XY           []float64 `json:"xy"`         // [x, y] color coordinates
-	IsReachable  bool      `json:"reachable"`
-	LastSeen     time.Time `json:"last_seen"`
-	Effect       string    `json:"effect"` // "none", "colorloop"
-	Alert        string    `json:"alert"`  // "none", "select", "lselect"
-}
-
-// HueRoom represents a Philips Hue room/zone
-type HueRoom struct {
 	ID           string      `json:"id"`
+	BridgeID     string      `json:"bridge_id,omitempty"`
 	Name         string      `json:"name"`
 	Type         string      `json:"type"`
-	Class        string      `json:"class"`
-	Lights       []string    `json:"lights"` // Light IDs
-	LightObjects []*HueLight `json:"light_objects,omitempty"`
-	IsOn         bool        `json:"is_on"`
-	Brightness   int         `json:"brightness"`
-	Hue          int         `json:"hue"`
-	Saturation   int         `json:"saturation"`
-	ColorTemp    int         `json:"color_temp"`
-	ColorMode    string      `json:"color_mode"`
-	XY           []float64   `json:"xy"`
-	Effect       string      `json:"effect"`
-	Alert        string      `json:"alert"`
+	ModelID      string      `json:"modelid"`
+	Manufacturer string      `json:"manufacturername"`
+	ProductName  string      `json:"productname"`
+	UniqueID     string      `json:"uniqueid"`
+	RoomID       string      `json:"room_id"`
+	RoomName     string      `json:"room_name"`
+	IsOn         bool        `json:"on"`
+	Brightness   int         `json:"bri"`       // 0-254
+	Hue          int         `json:"hue"`       // 0-65535
+	Saturation   int         `json:"sat"`       // 0-254
+	ColorTemp    int         `json:"ct"`        // 153-500 (mired)
+	ColorMode    string      `json:"colormode"` // "xy", "ct", "hs"
+	XY           []float64   `json:"xy"`        // [x, y] color coordinates
+	IsReachable  bool        `json:"reachable"`
+	LastSeen     time.Time   `json:"last_seen"`
+	Effect       string      `json:"effect"`                   // "none", "colorloop"
+	Alert        string      `json:"alert"`                    // "none", "select", "lselect"
+	GamutType    string      `json:"colorgamuttype,omitempty"` // "A", "B", "C" or "other"
+	ColorGamut   [][]float64 `json:"colorgamut,omitempty"`     // Red, green and blue xy corners
+	Hex          string      `json:"hex,omitempty"`            // Current color as #rrggbb
+	Kelvin       int         `json:"kelvin,omitempty"`         // Current color temperature
 }
 
+// HueRoom represents a Philips Hue room or zone. It is the same type as HueGroup so the two
+// can never drift apart; Type tells rooms ("Room") and zones ("Zone") apart.
+type HueRoom = HueGroup
+
 // HueScene represents a Philips Hue scene
 type HueScene struct {
 	ID          string                 `json:"id"`
+	BridgeID    string                 `json:"bridge_id,omitempty"`
 	Name        string                 `json:"name"`
 	Type        string                 `json:"type"`
 	Group       string                 `json:"group"` // Room ID
@@ -79,11 +70,123 @@ type HueScene struct {
 	Picture     string                 `json:"picture"`
 	LastUpdated time.Time              `json:"last_updated"`
 	Version     int                    `json:"version"`
+	// LightStates holds the stored state per light ID. Only filled in when a
+	// single scene is fetched, the bridge leaves it out of the scene list.
+	LightStates map[string]*HueSceneLightState `json:"lightstates,omitempty"`
+}
+
+// HueSceneLightState represents the state a scene stores for one light
+type HueSceneLightState struct {
+	On         bool      `json:"on"`
+	Brightness int       `json:"bri,omitempty"`
+	Hue        *int      `json:"hue,omitempty"`
+	Saturation *int      `json:"sat,omitempty"`
+	ColorTemp  *int      `json:"ct,omitempty"`
+	XY         []float64 `json:"xy,omitempty"`
+	Effect     string    `json:"effect,omitempty"`
+	Hex        string    `json:"hex,omitempty"` // Preview color as #rrggbb
+}
+
+// HueSceneRequest represents a request to create or rename a scene
+type HueSceneRequest struct {
+	Name   string `json:"name"`
+	RoomID string `json:"room_id,omitempty"`
+}
+
+// HueSceneLightRequest represents a change to the state a scene stores for one light
+type HueSceneLightRequest struct {
+	On         *bool `json:"on,omitempty"`
+	Brightness *int  `json:"bri,omitempty"`
+	HueColorRequest
+}
+
+// Sensor kinds derived from the bridge sensor types
+const (
+	HueSensorMotion      = "motion"
+	HueSensorTemperature = "temperature"
+	HueSensorLightLevel  = "light_level"
+	HueSensorSwitch      = "switch"
+	HueSensorDaylight    = "daylight"
+	HueSensorOther       = "other"
+)
+
+// HueSensor represents a Philips Hue sensor (motion, temperature, light level, switch or daylight)
+type HueSensor struct {
+	ID               string          `json:"id"`
+	BridgeID         string          `json:"bridge_id,omitempty"`
+	Name             string          `json:"name"`
+	Type             string          `json:"type"` // Bridge type, e.g. ZLLPresence or ZLLSwitch
+	Kind             string          `json:"kind"`
+	ModelID          string          `json:"modelid"`
+	ManufacturerName string          `json:"manufacturername"`
+	UniqueID         string          `json:"uniqueid"`
+	DeviceID         string          `json:"device_id,omitempty"` // Shared by the sensors of one physical device
+	SwVersion        string          `json:"swversion"`
+	State            HueSensorState  `json:"state"`
+	Config           HueSensorConfig `json:"config"`
+	Temperature      *float64        `json:"temperature_celsius,omitempty"`
+	Lux              *float64        `json:"lux,omitempty"`
+	LastSeen         time.Time       `json:"last_seen"`
+}
+
+// HueSensorState represents the reported state of a sensor
+type HueSensorState struct {
+	Presence    *bool  `json:"presence,omitempty"`
+	Temperature *int   `json:"temperature,omitempty"` // Hundredths of a degree Celsius
+	LightLevel  *int   `json:"lightlevel,omitempty"`  // 10000 * log10(lux) + 1
+	Dark        *bool  `json:"dark,omitempty"`
+	Daylight    *bool  `json:"daylight,omitempty"`
+	ButtonEvent *int   `json:"buttonevent,omitempty"`
+	LastUpdated string `json:"lastupdated,omitempty"`
+}
+
+// HueSensorConfig represents the configuration of a sensor
+type HueSensorConfig struct {
+	On            bool  `json:"on"`
+	Reachable     *bool `json:"reachable,omitempty"`
+	Battery       *int  `json:"battery,omitempty"`
+	Sensitivity   *int  `json:"sensitivity,omitempty"`
+	SunriseOffset *int  `json:"sunriseoffset,omitempty"`
+	SunsetOffset  *int  `json:"sunsetoffset,omitempty"`
+}
+
+// HueMotionEvent is published when a motion sensor starts or stops detecting presence
+type HueMotionEvent struct {
+	SensorID string `json:"sensor_id"`
+	Name     string `json:"name"`
+	DeviceID string `json:"device_id,omitempty"`
+	Presence bool   `json:"presence"`
+}
+
+// HueButtonEvent is published when a button on a dimmer or tap switch is used
+type HueButtonEvent struct {
+	SensorID string `json:"sensor_id"`
+	Name     string `json:"name"`
+	Button   int    `json:"button"`
+	Action   string `json:"action"` // initial_press, hold, short_release or long_release
+	Code     int    `json:"code"`
+}
+
+// Group types used by the bridge
+const (
+	HueGroupTypeRoom = "Room"
+	HueGroupTypeZone = "Zone"
+)
+
+// HueRoomClasses lists the room classes (icons) accepted by the bridge
+var HueRoomClasses = []string{
+	"Living room", "Kitchen", "Dining", "Bedroom", "Kids bedroom", "Bathroom", "Nursery",
+	"Recreation", "Office", "Gym", "Hallway", "Toilet", "Front door", "Garage", "Terrace",
+	"Garden", "Driveway", "Carport", "Home", "Downstairs", "Upstairs", "Top floor", "Attic",
+	"Guest room", "Staircase", "Lounge", "Man cave", "Computer", "Studio", "Music", "TV",
+	"Reading", "Closet", "Storage", "Laundry room", "Balcony", "Porch", "Barbecue", "Pool",
+	"Free", "Other",
 }
 
 // HueGroup represents a Philips Hue group (room or zone)
 type HueGroup struct {
 	ID           string      `json:"id"`
+	BridgeID     string      `json:"bridge_id,omitempty"`
 	Name         string      `json:"name"`
 	Type         string      `json:"type"`
 	Class        string      `json:"class"`
@@ -100,8 +203,36 @@ type HueGroup struct {
 	Alert        string      `json:"alert"`
 }
 
+// HueGroupRequest represents a request to create or change a room or zone.
+// Fields left empty are not changed; Type is only used on create.
+type HueGroupRequest struct {
+	BridgeID string   `json:"bridge_id,omitempty"` // On create; defaults to the bridge of the lights
+	Name     string   `json:"name,omitempty"`
+	Type     string   `json:"type,omitempty"` // Room or Zone
+	Class    string   `json:"class,omitempty"`
+	Lights   []string `json:"lights,omitempty"`
+}
+
+// HueLightSearch represents the result of the last search for new lights
+type HueLightSearch struct {
+	Status   string      `json:"status"`              // "active" while searching, "none" if never searched
+	LastScan string      `json:"last_scan,omitempty"` // Time the last search finished
+	Lights   []*HueLight `json:"lights"`              // Lights found by the last search (ID and name only)
+}
+
+// HueResourceStatus records how fresh the in-memory copy of a bridge resource is
+type HueResourceStatus struct {
+	BridgeID    string    `json:"bridge_id"`
+	Resource    string    `json:"resource"`
+	LastChecked time.Time `json:"last_checked"` // Last successful fetch
+	LastChanged time.Time `json:"last_changed"` // Last fetch that returned new data
+	LastError   string    `json:"last_error,omitempty"`
+	LastErrorAt time.Time `json:"last_error_at,omitempty"`
+}
+
 // HueServiceConfig represents configuration for Hue service
 type HueServiceConfig struct {
+	BridgeID     string        `json:"bridge_id"` // Name of the primary bridge
 	BridgeIP     string        `json:"bridge_ip"`
 	Username     string        `json:"username"`
 	Timeout      time.Duration `json:"timeout"`
@@ -109,6 +240,31 @@ type HueServiceConfig struct {
 	PollInterval time.Duration `json:"poll_interval"`
 	AutoDiscover bool          `json:"auto_discover"`
 	AuthRequired bool          `json:"auth_required"`
+	// SensorPollInterval is polled separately so button presses and motion feel immediate
+	SensorPollInterval time.Duration `json:"sensor_poll_interval"`
+	// Bridges lists additional bridges, e.g. in a detached garage
+	Bridges []HueBridgeConfig `json:"bridges"`
+}
+
+// HueBridgeConfig represents an additional bridge.
+// Its light, room and scene IDs are prefixed with "<ID>:" so they don't collide with other bridges.
+type HueBridgeConfig struct {
+	ID       string `json:"id"`
+	IP       string `json:"ip"`
+	Username string `json:"username"`
+}
+
+// HueBridgeStatus reports the connection and refresh state of one bridge
+type HueBridgeStatus struct {
+	ID         string               `json:"id"`
+	IP         string               `json:"ip"`
+	Primary    bool                 `json:"primary"`
+	Configured bool                 `json:"configured"`
+	Bridge     *HueBridge           `json:"bridge,omitempty"` // From the last successful poll
+	Lights     int                  `json:"lights"`
+	Rooms      int                  `json:"rooms"`
+	LastUpdate time.Time            `json:"last_update"`
+	Refresh    []*HueResourceStatus `json:"refresh"`
 }
 
 // HueAuthRequest represents a request to authenticate with Hue bridge
@@ -137,10 +293,11 @@ type HueAuthStatus struct {
 	RequiresAuth    bool      `json:"requires_auth"`
 }
 
-// HueAPIResponse represents a generic API response
+// HueAPIResponse represents a generic API response.
+// Success is an object for most requests but a plain string for deletes.
 type HueAPIResponse struct {
-	Success map[string]interface{} `json:"success,omitempty"`
-	Error   *HueAPIError           `json:"error,omitempty"`
+	Success interface{}  `json:"success,omitempty"`
+	Error   *HueAPIError `json:"error,omitempty"`
 }
 
 // HueAPIError represents an API error response
@@ -176,6 +333,18 @@ type HueGroupState struct {
 	TransitionTime *int      `json:"transitiontime,omitempty"`
 }
 
+// HueColorRequest represents a color change in any of the supported color spaces.
+// Exactly one of Hex, Kelvin, XY, ColorTemp or Hue/Saturation is expected.
+type HueColorRequest struct {
+	Hue            *int      `json:"hue,omitempty"`
+	Saturation     *int      `json:"saturation,omitempty"`
+	ColorTemp      *int      `json:"color_temp,omitempty"` // Mireds
+	XY             []float64 `json:"xy,omitempty"`
+	Hex            *string   `json:"hex,omitempty"`
+	Kelvin         *int      `json:"kelvin,omitempty"`
+	TransitionTime *int      `json:"transitiontime,omitempty"`
+}
+
 // HueDiscoveryResponse represents the response from bridge discovery
 type HueDiscoveryResponse struct {
 	ID                string `json:"id"`
@@ -189,3 +358,219 @@ type HueDiscoveryResponse struct {
 	APIVersion        string `json:"apiversion"`
 	Name              string `json:"name"`
 }
+
+// HueMusicSyncMapping links a Sonos room to the Hue room whose lights follow its album art
+type HueMusicSyncMapping struct {
+	SonosRoom      string `json:"sonos_room"`
+	HueRoomID      string `json:"hue_room_id"`
+	Enabled        bool   `json:"enabled"`
+	TransitionTime int    `json:"transition_time"` // Deciseconds
+	PaletteSize    int    `json:"palette_size"`
+}
+
+// HueMusicSyncStatus reports what a music sync mapping last applied
+type HueMusicSyncStatus struct {
+	Mapping      HueMusicSyncMapping `json:"mapping"`
+	CurrentTrack *TrackInfo          `json:"current_track,omitempty"`
+	Palette      []string            `json:"palette"` // Hex colors
+	LastApplied  time.Time           `json:"last_applied,omitempty"`
+	LastError    string              `json:"last_error,omitempty"`
+}
+
+// HueMusicSyncConfig represents configuration for the lights-follow-music mode
+type HueMusicSyncConfig struct {
+	PollInterval          time.Duration `json:"poll_interval"`
+	DefaultTransitionTime int           `json:"default_transition_time"`
+	DefaultPaletteSize    int           `json:"default_palette_size"`
+	ArtFetchTimeout       time.Duration `json:"art_fetch_timeout"`
+}
+
+// HueCircadianConfig represents configuration for the circadian lighting mode
+type HueCircadianConfig struct {
+	Latitude          float64        `json:"latitude"`
+	Longitude         float64        `json:"longitude"`
+	Interval          time.Duration  `json:"interval"`
+	TransitionTime    int            `json:"transition_time"` // Deciseconds
+	MaxKelvin         int            `json:"max_kelvin"`      // Mid-day
+	EveningKelvin     int            `json:"evening_kelvin"`  // Sunrise and sunset
+	NightKelvin       int            `json:"night_kelvin"`    // Night light
+	MaxBrightness     int            `json:"max_brightness"`
+	EveningBrightness int            `json:"evening_brightness"`
+	NightBrightness   int            `json:"night_brightness"`
+	NightStart        string         `json:"night_start"` // HH:MM home time
+	Rooms             []string       `json:"rooms"`       // Rooms enabled at startup
+	Location          *time.Location `json:"-"`           // Home time zone, defaults to the server's
+}
+
+// HueCircadianTarget represents the light the circadian mode aims for at one moment
+type HueCircadianTarget struct {
+	Phase      string `json:"phase"` // day, evening or night
+	Kelvin     int    `json:"kelvin"`
+	Mired      int    `json:"mired"`
+	Brightness int    `json:"bri"`
+}
+
+// HueCircadianRoom represents the circadian mode of one room
+type HueCircadianRoom struct {
+	RoomID        string              `json:"room_id"`
+	Enabled       bool                `json:"enabled"`
+	Paused        bool                `json:"paused"` // Someone changed the lights by hand
+	PausedAt      time.Time           `json:"paused_at,omitempty"`
+	LastApplied   *HueCircadianTarget `json:"last_applied,omitempty"`
+	LastAppliedAt time.Time           `json:"last_applied_at,omitempty"`
+	LastError     string              `json:"last_error,omitempty"`
+}
+
+// HueCircadianStatus represents the current circadian target and the state of every room
+type HueCircadianStatus struct {
+	Target  HueCircadianTarget  `json:"target"`
+	Sunrise time.Time           `json:"sunrise"`
+	Sunset  time.Time           `json:"sunset"`
+	Rooms   []*HueCircadianRoom `json:"rooms"`
+}
+
+// Light routine types
+const (
+	HueRoutineWake  = "wake"  // Sunrise simulation ending at the routine time
+	HueRoutineSleep = "sleep" // Bedtime fade-out ending at the routine time
+)
+
+// HueLightRoutine represents a scheduled wake-up or sleep fade for a room
+type HueLightRoutine struct {
+	ID              string   `json:"id"`
+	Name            string   `json:"name"`
+	Type            string   `json:"type"` // wake or sleep
+	RoomID          string   `json:"room_id"`
+	Time            string   `json:"time"`     // HH:MM local time the fade ends
+	Days            []string `json:"days"`     // mon..sun, empty for every day
+	Duration        int      `json:"duration"` // Minutes
+	Kelvin          int      `json:"kelvin"`   // Final color temperature
+	Brightness      int      `json:"bri"`      // Final brightness of a wake-up
+	Enabled         bool     `json:"enabled"`
+	SkipKeywords    []string `json:"skip_keywords"`     // Calendar events that cancel the day, e.g. "no school"
+	SkipCalendarIDs []string `json:"skip_calendar_ids"` // Calendars to check, empty for all
+}
+
+// HueLightRoutineStatus represents a routine with its schedule and progress
+type HueLightRoutineStatus struct {
+	Routine    HueLightRoutine `json:"routine"`
+	Running    bool            `json:"running"`
+	Progress   float64         `json:"progress"` // 0-1 while running
+	StartedAt  time.Time       `json:"started_at,omitempty"`
+	EndsAt     time.Time       `json:"ends_at,omitempty"`
+	NextRun    *time.Time      `json:"next_run,omitempty"`
+	SkipNext   string          `json:"skip_next,omitempty"` // Date of a cancelled upcoming run
+	LastRunAt  time.Time       `json:"last_run_at,omitempty"`
+	LastResult string          `json:"last_result,omitempty"` // completed, cancelled, skipped or failed
+	LastReason string          `json:"last_reason,omitempty"`
+}
+
+// HueLightRoutineConfig represents configuration for the wake-up and sleep routines
+type HueLightRoutineConfig struct {
+	CheckInterval   time.Duration  `json:"check_interval"`
+	StepInterval    time.Duration  `json:"step_interval"` // Length of one fade segment
+	DefaultDuration int            `json:"default_duration"`
+	CalendarTimeout time.Duration  `json:"calendar_timeout"`
+	Location        *time.Location `json:"-"` // Home time zone, defaults to the server's
+}
+
+// Hue schedule time kinds
+const (
+	HueScheduleOnce      = "once"      // A single date and time
+	HueScheduleRecurring = "recurring" // A time on selected weekdays
+	HueScheduleTimer     = "timer"     // A countdown, optionally repeated
+)
+
+// Hue rule condition operators
+const (
+	HueRuleOpEquals      = "eq"
+	HueRuleOpGreaterThan = "gt"
+	HueRuleOpLessThan    = "lt"
+	HueRuleOpChanged     = "dx"  // The value changed
+	HueRuleOpChangedAt   = "ddx" // The value changed a given time ago
+	HueRuleOpStable      = "stable"
+	HueRuleOpNotStable   = "not stable"
+	HueRuleOpIn          = "in"     // Local time is within an interval
+	HueRuleOpNotIn       = "not in" // Local time is outside an interval
+)
+
+// HueCommand represents a bridge API call run by a schedule or a rule action.
+// Addresses are relative to the bridge user, e.g. /groups/1/action.
+type HueCommand struct {
+	Address string      `json:"address"`
+	Method  string      `json:"method"` // PUT, POST or DELETE
+	Body    interface{} `json:"body"`
+}
+
+// HueScheduleTime represents when a bridge schedule fires, in local time
+type HueScheduleTime struct {
+	Kind     string   `json:"kind"`               // once, recurring or timer
+	Date     string   `json:"date,omitempty"`     // YYYY-MM-DD, for once
+	Time     string   `json:"time,omitempty"`     // HH:MM:SS, for once and recurring
+	Weekdays []string `json:"weekdays,omitempty"` // mon..sun, for recurring
+	Duration string   `json:"duration,omitempty"` // HH:MM:SS, for timer
+	Repeat   int      `json:"repeat,omitempty"`   // Timer repetitions, -1 for forever
+	Random   string   `json:"random,omitempty"`   // HH:MM:SS of random delay
+}
+
+// HueSchedule represents a schedule stored and run by the bridge
+type HueSchedule struct {
+	ID          string           `json:"id"`
+	BridgeID    string           `json:"bridge_id,omitempty"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Command     HueCommand       `json:"command"`
+	LocalTime   string           `json:"localtime"`
+	Time        *HueScheduleTime `json:"time,omitempty"` // LocalTime decoded, when it is a supported format
+	Status      string           `json:"status"`         // enabled or disabled
+	AutoDelete  bool             `json:"autodelete"`
+	Recycle     bool             `json:"recycle"`
+	Created     string           `json:"created"`
+	StartTime   string           `json:"starttime,omitempty"`
+}
+
+// HueScheduleRequest represents a request to create or change a bridge schedule.
+// Fields left empty are not changed. Time takes precedence over a raw LocalTime.
+type HueScheduleRequest struct {
+	BridgeID    string           `json:"bridge_id,omitempty"` // On create; command addresses use that bridge's own IDs
+	Name        string           `json:"name,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Command     *HueCommand      `json:"command,omitempty"`
+	Time        *HueScheduleTime `json:"time,omitempty"`
+	LocalTime   string           `json:"localtime,omitempty"`
+	Status      string           `json:"status,omitempty"`
+	AutoDelete  *bool            `json:"autodelete,omitempty"`
+}
+
+// HueRuleCondition represents a condition of a bridge rule, e.g. a sensor state
+type HueRuleCondition struct {
+	Address  string `json:"address"` // e.g. /sensors/1/state/daylight
+	Operator string `json:"operator"`
+	Value    string `json:"value,omitempty"`
+}
+
+// HueRule represents a rule stored and run by the bridge.
+// All conditions must hold for the actions to run.
+type HueRule struct {
+	ID             string             `json:"id"`
+	BridgeID       string             `json:"bridge_id,omitempty"`
+	Name           string             `json:"name"`
+	Owner          string             `json:"owner"`
+	Created        string             `json:"created"`
+	LastTriggered  string             `json:"lasttriggered"`
+	TimesTriggered int                `json:"timestriggered"`
+	Status         string             `json:"status"` // enabled, disabled or resourcedeleted
+	Recycle        bool               `json:"recycle"`
+	Conditions     []HueRuleCondition `json:"conditions"`
+	Actions        []HueCommand       `json:"actions"`
+}
+
+// HueRuleRequest represents a request to create or change a bridge rule.
+// Fields left empty are not changed.
+type HueRuleRequest struct {
+	BridgeID   string             `json:"bridge_id,omitempty"` // On create; addresses use that bridge's own IDs
+	Name       string             `json:"name,omitempty"`
+	Status     string             `json:"status,omitempty"`
+	Conditions []HueRuleCondition `json:"conditions,omitempty"`
+	Actions    []HueCommand       `json:"actions,omitempty"`
+}
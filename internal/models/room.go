@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// Room is a room of the household. It ties together the Hue rooms and zones,
+// Sonos players and calendars that belong to the same physical room.
+type Room struct {
+	ID           string          `json:"id"` // Slug of the name, e.g. living-room
+	Name         string          `json:"name"`
+	HueGroups    []string        `json:"hue_groups"`    // Hue room and zone IDs
+	SonosPlayers []string        `json:"sonos_players"` // Sonos room names
+	CalendarIDs  []string        `json:"calendar_ids"`
+	Permissions  RoomPermissions `json:"permissions"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+}
+
+// RoomPermissions limits who may control a room through the room endpoints. Anyone may
+// control an unrestricted room; a restricted room needs a signed-in household member or the
+// room's access key, so a wall tablet can be given the key of its own room only.
+// The Hue, Sonos, scene and routine APIs do not check room permissions.
+type RoomPermissions struct {
+	Restricted bool   `json:"restricted"`
+	AccessKey  string `json:"access_key,omitempty"` // Generated for restricted rooms when not given
+}
+
+// RoomSuggestion proposes a room for Hue groups and Sonos players with matching names
+type RoomSuggestion struct {
+	Name         string   `json:"name"`
+	RoomID       string   `json:"room_id,omitempty"` // Existing room the devices could be added to
+	HueGroups    []string `json:"hue_groups"`
+	SonosPlayers []string `json:"sonos_players"`
+}
+
+// RoomStatus is the combined state of everything in a room
+type RoomStatus struct {
+	Room         *Room              `json:"room"`
+	Lights       RoomLightStatus    `json:"lights"`
+	Players      []RoomPlayerStatus `json:"players"`
+	NowPlaying   *TrackInfo         `json:"now_playing,omitempty"` // Track of the first playing player
+	IsPlaying    bool               `json:"is_playing"`
+	MissingHue   []string           `json:"missing_hue_groups,omitempty"` // Mapped groups no longer on a bridge
+	MissingSonos []string           `json:"missing_sonos_players,omitempty"`
+}
+
+// RoomLightStatus summarizes the Hue lights of a room
+type RoomLightStatus struct {
+	Total      int  `json:"total"`
+	On         int  `json:"on"`
+	Reachable  int  `json:"reachable"`
+	AnyOn      bool `json:"any_on"`
+	Brightness int  `json:"brightness"` // Average of the lights that are on
+}
+
+// RoomPlayerStatus is the state of one Sonos player in a room
+type RoomPlayerStatus struct {
+	Name   string     `json:"name"`
+	State  string     `json:"state"`
+	Volume int        `json:"volume"`
+	Mute   bool       `json:"mute"`
+	Track  *TrackInfo `json:"track,omitempty"`
+	Online bool       `json:"online"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// SolarConfig represents configuration for the sun time service
+type SolarConfig struct {
+	Latitude      float64        `json:"latitude"`
+	Longitude     float64        `json:"longitude"`
+	Location      *time.Location `json:"-"` // Home time zone, defaults to the server's
+	CheckInterval time.Duration  `json:"check_interval"`
+}
+
+// SolarDay holds the sun times of one day at the home.
+// A time is nil when the sun does not reach it that day.
+type SolarDay struct {
+	Date         string     `json:"date"` // YYYY-MM-DD in the home time zone
+	NauticalDawn *time.Time `json:"nautical_dawn"`
+	CivilDawn    *time.Time `json:"civil_dawn"`
+	Sunrise      *time.Time `json:"sunrise"`
+	SolarNoon    time.Time  `json:"solar_noon"`
+	Sunset       *time.Time `json:"sunset"`
+	CivilDusk    *time.Time `json:"civil_dusk"`
+	NauticalDusk *time.Time `json:"nautical_dusk"`
+	DayLength    string     `json:"day_length"` // Time between sunrise and sunset, such as "15h37m"
+	PolarNight   bool       `json:"polar_night,omitempty"`
+	MidnightSun  bool       `json:"midnight_sun,omitempty"`
+}
+
+// SolarStatus is the dashboard view of the sun
+type SolarStatus struct {
+	Latitude    float64               `json:"latitude"`
+	Longitude   float64               `json:"longitude"`
+	TimeZone    string                `json:"time_zone"`
+	Now         time.Time             `json:"now"`
+	IsDaylight  bool                  `json:"is_daylight"` // Between sunrise and sunset
+	Today       SolarDay              `json:"today"`
+	Tomorrow    SolarDay              `json:"tomorrow"`
+	NextEvent   string                `json:"next_event,omitempty"`
+	NextEventAt *time.Time            `json:"next_event_at,omitempty"`
+	Triggers    []*SolarTriggerStatus `json:"triggers"`
+}
+
+// SolarTrigger fires at a time relative to the sun, such as "sunset - 20m" for the porch lights.
+// Every firing is published on the event bus and may run a home routine.
+type SolarTrigger struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	At        string    `json:"at"`                   // Sun event and offset, such as "sunset - 20m"
+	RoutineID string    `json:"routine_id,omitempty"` // Home routine to run
+	Enabled   bool      `json:"enabled"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SolarTriggerStatus is a trigger with its next and last firing
+type SolarTriggerStatus struct {
+	SolarTrigger
+	NextFire  *time.Time `json:"next_fire,omitempty"`
+	LastFired *time.Time `json:"last_fired,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
@@ -65,6 +65,9 @@ func (s *Server) SetupRoutes() {
 	api.HandleFunc("/connectivity", s.connectivityHandler).Methods("GET")
 
 	// Initialize services
+	eventBus := services.NewEventBus()
+	eventHandler := handlers.NewEventHandler(eventBus)
+
 	sonosService := services.NewSonosService(&models.SonosServiceConfig{
 		JishiURL:   s.config.Sonos.APIURL,
 		Timeout:    s.config.Sonos.Timeout,
@@ -76,7 +79,14 @@ func (s *Server) SetupRoutes() {
 	log.Printf("Hue Bridge IP: %s", s.config.Hue.BridgeIP)
 	log.Printf("Hue Username: %s", s.config.Hue.Username)
 
+	hueBridges := make([]models.HueBridgeConfig, 0, len(s.config.Hue.Bridges))
+	for _, bridge := range s.config.Hue.Bridges {
+		hueBridges = append(hueBridges, models.HueBridgeConfig{ID: bridge.ID, IP: bridge.IP, Username: bridge.Username})
+	}
+
 	hueService := services.NewHueService(&models.HueServiceConfig{
+		BridgeID:     s.config.Hue.BridgeID,
+		Bridges:      hueBridges,
 		BridgeIP:     s.config.Hue.BridgeIP,
 		Username:     s.config.Hue.Username,
 		Timeout:      s.config.Hue.Timeout,
@@ -84,7 +94,10 @@ func (s *Server) SetupRoutes() {
 		PollInterval: 20 * time.Second, // Set a default poll interval
 		AutoDiscover: true,
 		AuthRequired: false, // We have credentials, so auth is not required
+
+		SensorPollInterval: s.config.Hue.SensorPollInterval,
 	})
+	hueService.SetEventBus(eventBus)
 
 	// Initialize the Hue service
 	if err := hueService.Start(context.Background()); err != nil {
@@ -93,6 +106,18 @@ func (s *Server) SetupRoutes() {
 
 	hueHandler := handlers.NewHueHandler(hueService)
 
+	// Initialize lights-follow-music mode
+	hueMusicSyncService := services.NewHueMusicSyncService(hueService, sonosService, &models.HueMusicSyncConfig{
+		PollInterval:          s.config.Hue.MusicSyncPollInterval,
+		DefaultTransitionTime: s.config.Hue.MusicSyncTransitionTime,
+		DefaultPaletteSize:    s.config.Hue.MusicSyncPaletteSize,
+		ArtFetchTimeout:       10 * time.Second,
+	})
+	if err := hueMusicSyncService.Start(context.Background()); err != nil {
+		log.Printf("Warning: Failed to start Hue music sync service: %v", err)
+	}
+	hueMusicSyncHandler := handlers.NewHueMusicSyncHandler(hueMusicSyncService)
+
 	// Initialize calendar service
 	oauthConfig := &oauth2.Config{
 		ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
@@ -104,20 +129,252 @@ func (s *Server) SetupRoutes() {
 	calendarService := services.NewCalendarService(oauthConfig)
 	calendarHandler := handlers.NewCalendarHandler(calendarService)
 
+	// Open the local household database
+	sqliteDB, err := database.OpenSQLite(s.config.Database.SQLitePath)
+	if err != nil {
+		log.Printf("Warning: Failed to open SQLite database, local data will not be saved: %v", err)
+	}
+
+	// Times of day, such as wake-ups and the night light, are in the home time zone
+	homeLocation := time.Local
+	if s.config.Location.TimeZone != "" {
+		loaded, err := time.LoadLocation(s.config.Location.TimeZone)
+		if err != nil {
+			log.Printf("Warning: Unknown HOME_TIMEZONE %q, using the server time zone: %v", s.config.Location.TimeZone, err)
+		} else {
+			homeLocation = loaded
+		}
+	}
+
+	// Initialize circadian lighting mode
+	hueCircadianService := services.NewHueCircadianService(hueService, sqliteDB, &models.HueCircadianConfig{
+		Latitude:   s.config.Location.Latitude,
+		Longitude:  s.config.Location.Longitude,
+		Interval:   s.config.Hue.CircadianInterval,
+		NightStart: s.config.Hue.CircadianNightStart,
+		Rooms:      s.config.Hue.CircadianRooms,
+		Location:   homeLocation,
+	})
+	if err := hueCircadianService.Start(context.Background()); err != nil {
+		log.Printf("Warning: Failed to start Hue circadian service: %v", err)
+	}
+	hueCircadianHandler := handlers.NewHueCircadianHandler(hueCircadianService)
+
+	// Initialize wake-up and sleep light routines
+	hueRoutineService := services.NewHueLightRoutineService(hueService, sqliteDB, &models.HueLightRoutineConfig{
+		StepInterval: s.config.Hue.RoutineStepInterval,
+		Location:     homeLocation,
+	})
+	hueRoutineService.SetDayChecker(services.NewCalendarDayChecker(calendarService, services.HouseholdUserID))
+	hueRoutineService.SetEventBus(eventBus)
+	if err := hueRoutineService.Start(context.Background()); err != nil {
+		log.Printf("Warning: Failed to start Hue light routine service: %v", err)
+	}
+	hueRoutineHandler := handlers.NewHueRoutineHandler(hueRoutineService)
+
+	// Initialize the household room registry
+	roomService := services.NewRoomService(hueService, sonosService, sqliteDB)
+	if err := roomService.Start(context.Background()); err != nil {
+		log.Printf("Warning: Failed to start room service: %v", err)
+	}
+	roomHandler := handlers.NewRoomHandler(roomService)
+
+	// Initialize scenes combining lights and audio
+	homeSceneService := services.NewHomeSceneService(hueService, sonosService, sqliteDB)
+	homeSceneService.SetEventBus(eventBus)
+	if err := homeSceneService.Start(context.Background()); err != nil {
+		log.Printf("Warning: Failed to start home scene service: %v", err)
+	}
+	homeSceneHandler := handlers.NewHomeSceneHandler(homeSceneService)
+
+	// Initialize routines with waits and parallel steps, resuming runs left by a restart
+	homeRoutineService := services.NewHomeRoutineService(hueService, sonosService, sqliteDB)
+	homeRoutineService.SetEventBus(eventBus)
+	if err := homeRoutineService.Start(context.Background()); err != nil {
+		log.Printf("Warning: Failed to start home routine service: %v", err)
+	}
+	homeRoutineHandler := handlers.NewHomeRoutineHandler(homeRoutineService)
+
+	// Initialize sun times and triggers such as "sunset - 20m"
+	solarService := services.NewSolarService(sqliteDB, &models.SolarConfig{
+		Latitude:  s.config.Location.Latitude,
+		Longitude: s.config.Location.Longitude,
+		Location:  homeLocation,
+	})
+	solarService.SetEventBus(eventBus)
+	solarService.SetRoutineRunner(homeRoutineService)
+	if err := solarService.Start(context.Background()); err != nil {
+		log.Printf("Warning: Failed to start sun time service: %v", err)
+	}
+	solarHandler := handlers.NewSolarHandler(solarService)
+
+	// Keep a local copy of the household calendars so events are served without waiting on Google
+	calendarSyncService := services.NewCalendarSyncService(calendarService, sqliteDB, &models.CalendarSyncConfig{
+		UserID:   services.HouseholdUserID,
+		Interval: s.config.Calendar.SyncInterval,
+		PastDays: s.config.Calendar.SyncPastDays,
+	})
+	if err := calendarSyncService.Start(context.Background()); err != nil {
+		log.Printf("Warning: Failed to start calendar sync service: %v", err)
+	}
+	calendarHandler.SetSyncService(calendarSyncService)
+
+	// Refresh calendars as soon as Google posts a change to the webhook
+	calendarWatchService := services.NewCalendarWatchService(calendarSyncService, sqliteDB, &models.CalendarWatchConfig{
+		WebhookURL: s.config.Calendar.WebhookURL,
+		TTL:        s.config.Calendar.WatchTTL,
+	})
+	calendarWatchService.SetCacheService(calendarHandler.CacheService())
+	if err := calendarWatchService.Start(context.Background()); err != nil {
+		log.Printf("Warning: Failed to start calendar watch service: %v", err)
+	}
+	calendarHandler.SetWatchService(calendarWatchService)
+
+	// Several Google accounts per household, such as each parent's and a shared family account.
+	// The sync, push notifications and day checks stay on the primary account.
+	googleAccountService := services.NewGoogleAccountService(calendarService, sqliteDB)
+	googleAccountService.SetCacheService(calendarHandler.CacheService())
+	googleAccountService.SetSyncService(calendarSyncService)
+	if err := googleAccountService.Start(context.Background()); err != nil {
+		log.Printf("Warning: Failed to start Google account service: %v", err)
+	}
+	handlers.SetGoogleAccountService(googleAccountService)
+	calendarHandler.SetGoogleAccountService(googleAccountService)
+	googleAccountHandler := handlers.NewGoogleAccountHandler(googleAccountService)
+	calendarWebhookHandler := handlers.NewCalendarWebhookHandler(calendarWatchService)
+	handlers.OnLogin(func(userID int) {
+		if userID == calendarSyncService.UserID() {
+			go func() {
+				if err := calendarWatchService.Resume(context.Background()); err != nil {
+					log.Printf("Warning: Failed to resume calendar watch channels: %v", err)
+				}
+			}()
+		}
+	})
+	handlers.OnLogout(func(userID int) {
+		if userID == calendarSyncService.UserID() {
+			go func() {
+				if err := calendarWatchService.StopAll(context.Background()); err != nil {
+					log.Printf("Warning: Failed to stop calendar watch channels: %v", err)
+				}
+			}()
+		}
+	})
+
+	// Subscribed ICS feeds, such as school or trash pickup calendars, are merged into the calendar events
+	calendarFeedService := services.NewCalendarFeedService(sqliteDB, &models.CalendarFeedConfig{
+		RefreshInterval: s.config.Calendar.FeedRefreshInterval,
+		Location:        homeLocation,
+	})
+	if err := calendarFeedService.Start(context.Background()); err != nil {
+		log.Printf("Warning: Failed to start calendar feed service: %v", err)
+	}
+	calendarHandler.SetFeedService(calendarFeedService)
+	calendarFeedHandler := handlers.NewCalendarFeedHandler(calendarFeedService)
+
+	// Linked calendar accounts, such as a family member's iCloud calendars over CalDAV or a
+	// Microsoft 365 work calendar
+	calendarAccountService := services.NewCalendarAccountService(sqliteDB, &models.CalendarAccountConfig{
+		SyncInterval: s.config.Calendar.SyncInterval,
+		Location:     homeLocation,
+	})
+	if err := calendarAccountService.Start(context.Background()); err != nil {
+		log.Printf("Warning: Failed to start calendar account service: %v", err)
+	}
+	calendarHandler.SetAccountService(calendarAccountService)
+	calendarAccountHandler := handlers.NewCalendarAccountHandler(calendarAccountService)
+
+	// Household calendars kept in WoodHome, for chores, the babysitter schedule or game nights
+	householdCalendarService := services.NewHouseholdCalendarService(sqliteDB, &models.HouseholdCalendarConfig{
+		Location: homeLocation,
+	})
+	if err := householdCalendarService.Start(context.Background()); err != nil {
+		log.Printf("Warning: Failed to start household calendar service: %v", err)
+	}
+	calendarHandler.SetHouseholdService(householdCalendarService)
+
+	// The family calendar as .ics, for phones and other calendar apps to subscribe to
+	calendarExportService := services.NewCalendarExportService(sqliteDB, &models.CalendarExportConfig{
+		Location: homeLocation,
+	})
+	if err := calendarExportService.Start(context.Background()); err != nil {
+		log.Printf("Warning: Failed to start calendar export service: %v", err)
+	}
+	calendarHandler.SetExportService(calendarExportService)
+
+	// Calendar reminders delivered to the home, as a dashboard toast, an email, a Sonos
+	// announcement or a light flash, chosen per calendar
+	emailService := services.NewEmailService(&models.EmailConfig{
+		SMTPHost: s.config.Email.SMTPHost,
+		SMTPPort: s.config.Email.SMTPPort,
+		From:     s.config.Email.FromEmail,
+		Password: s.config.Email.FromPassword,
+	})
+	calendarReminderService := services.NewCalendarReminderService(calendarHandler.HouseholdEvents, sqliteDB, &models.CalendarReminderConfig{
+		LateWindow: s.config.Calendar.ReminderLateWindow,
+		Location:   homeLocation,
+	})
+	calendarReminderService.SetEventBus(eventBus)
+	calendarReminderService.SetEmailService(emailService)
+	calendarReminderService.SetSonosService(sonosService)
+	calendarReminderService.SetHueService(hueService)
+	if err := calendarReminderService.Start(context.Background()); err != nil {
+		log.Printf("Warning: Failed to start calendar reminder service: %v", err)
+	}
+	calendarReminderHandler := handlers.NewCalendarReminderHandler(calendarReminderService)
+
+	// The family agenda of today and tomorrow, emailed each morning
+	calendarDigestService := services.NewCalendarDigestService(calendarHandler.HouseholdEvents, sqliteDB, &models.CalendarDigestConfig{
+		Location: homeLocation,
+	})
+	calendarDigestService.SetCalendarSource(calendarHandler.HouseholdCalendars)
+	calendarDigestService.SetEmailService(emailService)
+	if err := calendarDigestService.Start(context.Background()); err != nil {
+		log.Printf("Warning: Failed to start calendar digest service: %v", err)
+	}
+	calendarDigestHandler := handlers.NewCalendarDigestHandler(calendarDigestService, homeLocation)
+
 	// Register service routes
+	log.Println("Registering event routes...")
+	eventHandler.RegisterRoutes(api.PathPrefix("/events").Subrouter())
 	log.Println("Registering Sonos routes...")
 	sonosHandler.RegisterRoutes(api.PathPrefix("/sonos").Subrouter())
 	log.Println("Registering Hue routes...")
+	hueMusicSyncHandler.RegisterRoutes(api.PathPrefix("/hue/music-sync").Subrouter())
+	hueCircadianHandler.RegisterRoutes(api.PathPrefix("/hue/circadian").Subrouter())
+	hueRoutineHandler.RegisterRoutes(api.PathPrefix("/hue/routines").Subrouter())
 	hueHandler.RegisterRoutes(api.PathPrefix("/hue").Subrouter())
+	log.Println("Registering room routes...")
+	roomHandler.RegisterRoutes(api.PathPrefix("/rooms").Subrouter())
+	log.Println("Registering home scene routes...")
+	homeSceneHandler.RegisterRoutes(api.PathPrefix("/home-scenes").Subrouter())
+	log.Println("Registering home routine routes...")
+	homeRoutineHandler.RegisterRoutes(api.PathPrefix("/home-routines").Subrouter())
+	log.Println("Registering sun time routes...")
+	solarHandler.RegisterRoutes(api.PathPrefix("/solar").Subrouter())
 	log.Println("Registering Calendar routes...")
+	calendarFeedHandler.RegisterRoutes(api.PathPrefix("/calendar/feeds").Subrouter())
+	calendarAccountHandler.RegisterRoutes(api.PathPrefix("/calendar/accounts").Subrouter())
+	googleAccountHandler.RegisterRoutes(api.PathPrefix("/calendar/google-accounts").Subrouter())
+	calendarReminderHandler.RegisterRoutes(api.PathPrefix("/calendar/reminders").Subrouter())
+	calendarDigestHandler.RegisterRoutes(api.PathPrefix("/calendar/digest").Subrouter())
 	calendarHandler.RegisterRoutes(api.PathPrefix("/calendar").Subrouter())
 
+	// Calendar subscription links, fetched by calendar apps without a session
+	calendarHandler.RegisterSubscriptionRoutes(router.PathPrefix("/calendar/subscribe").Subrouter())
+
+	// Google Calendar push notifications, posted by Google without a session
+	calendarWebhookHandler.RegisterRoutes(router.PathPrefix("/webhooks/google/calendar").Subrouter())
+
 	// OAuth routes
 	router.HandleFunc("/auth/google/login", handlers.GoogleLoginHandler).Methods("GET")
 	router.HandleFunc("/auth/google/callback", handlers.GoogleCallbackHandler).Methods("GET")
 	router.HandleFunc("/auth/logout", handlers.LogoutHandler).Methods("POST")
 	router.HandleFunc("/auth/status", handlers.AuthStatusHandler).Methods("GET")
 
+	// Microsoft sign in, which links a Microsoft 365 calendar account
+	calendarAccountHandler.RegisterAuthRoutes(router)
+
 	// API OAuth routes (for frontend compatibility)
 	api.HandleFunc("/auth/status", handlers.AuthStatusHandler).Methods("GET")
 	api.HandleFunc("/auth/logout", handlers.LogoutHandler).Methods("POST")
@@ -0,0 +1,604 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"woodhome-webapp/internal/ical"
+
+	"github.com/google/uuid"
+)
+
+// caldavMultigetBatch is the most resources fetched per calendar-multiget REPORT
+const caldavMultigetBatch = 50
+
+// caldavMaxResponse bounds a single CalDAV response
+const caldavMaxResponse = 32 << 20
+
+// CalDAVProvider is the CalendarProvider for a CalDAV server, such as iCloud, Nextcloud,
+// Fastmail or Radicale. Calendars are found from the account URL, which may be the server,
+// a principal, a calendar home or a single calendar.
+type CalDAVProvider struct {
+	baseURL    *url.URL
+	username   string
+	password   string
+	location   *time.Location
+	httpClient *http.Client
+}
+
+// NewCalDAVProvider creates a provider that signs in with basic authentication, as the
+// app-specific passwords of iCloud and Nextcloud do. Floating times and all-day events are
+// placed in loc.
+func NewCalDAVProvider(accountURL, username, password string, loc *time.Location, httpClient *http.Client) (*CalDAVProvider, error) {
+	parsed, err := url.Parse(accountURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("caldav url must be an http or https link")
+	}
+	if parsed.Path == "" {
+		parsed.Path = "/"
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	// Redirects are followed by do, as net/http turns a redirected PROPFIND into a GET
+	client := *httpClient
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	return &CalDAVProvider{
+		baseURL:    parsed,
+		username:   username,
+		password:   password,
+		location:   loc,
+		httpClient: &client,
+	}, nil
+}
+
+// WebDAV and CalDAV XML, as far as this client reads it
+type davMultistatus struct {
+	XMLName   xml.Name      `xml:"DAV: multistatus"`
+	Responses []davResponse `xml:"DAV: response"`
+	SyncToken string        `xml:"DAV: sync-token"`
+}
+
+type davResponse struct {
+	Href      string        `xml:"DAV: href"`
+	Status    string        `xml:"DAV: status"`
+	Propstats []davPropstat `xml:"DAV: propstat"`
+}
+
+type davPropstat struct {
+	Status string  `xml:"DAV: status"`
+	Prop   davProp `xml:"DAV: prop"`
+}
+
+type davProp struct {
+	DisplayName          string          `xml:"DAV: displayname"`
+	ETag                 string          `xml:"DAV: getetag"`
+	ResourceType         davResourceType `xml:"DAV: resourcetype"`
+	CurrentUserPrincipal davHref         `xml:"DAV: current-user-principal"`
+	CalendarHomeSet      davHref         `xml:"urn:ietf:params:xml:ns:caldav calendar-home-set"`
+	CalendarData         string          `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+	CalendarColor        string          `xml:"http://apple.com/ns/ical/ calendar-color"`
+	Components           davComponentSet `xml:"urn:ietf:params:xml:ns:caldav supported-calendar-component-set"`
+	Privileges           davPrivileges   `xml:"DAV: current-user-privilege-set"`
+}
+
+type davResourceType struct {
+	Calendar *struct{} `xml:"urn:ietf:params:xml:ns:caldav calendar"`
+}
+
+type davHref struct {
+	Href string `xml:"DAV: href"`
+}
+
+type davComponentSet struct {
+	Components []struct {
+		Name string `xml:"name,attr"`
+	} `xml:"urn:ietf:params:xml:ns:caldav comp"`
+}
+
+type davPrivileges struct {
+	Privileges []struct {
+		Write        *struct{} `xml:"DAV: write"`
+		All          *struct{} `xml:"DAV: all"`
+		WriteContent *struct{} `xml:"DAV: write-content"`
+	} `xml:"DAV: privilege"`
+}
+
+// props returns the properties the server found for a response
+func (r davResponse) props() (davProp, bool) {
+	for _, propstat := range r.Propstats {
+		if statusOK(propstat.Status) {
+			return propstat.Prop, true
+		}
+	}
+	return davProp{}, false
+}
+
+// statusOK reports whether a status line such as "HTTP/1.1 200 OK" is a success
+func statusOK(status string) bool {
+	fields := strings.Fields(status)
+	return len(fields) >= 2 && strings.HasPrefix(fields[1], "2")
+}
+
+// ListCalendars finds the calendar home and lists the calendars holding events
+func (p *CalDAVProvider) ListCalendars(ctx context.Context) ([]ProviderCalendar, error) {
+	const discoverBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav" xmlns:a="http://apple.com/ns/ical/">
+  <d:prop>
+    <d:resourcetype/>
+    <d:displayname/>
+    <d:current-user-principal/>
+    <c:calendar-home-set/>
+    <c:supported-calendar-component-set/>
+    <a:calendar-color/>
+    <d:current-user-privilege-set/>
+  </d:prop>
+</d:propfind>`
+
+	// A bare server address is looked up through the well-known URL, which redirects to the
+	// server's CalDAV root where it is set up
+	target := p.baseURL.String()
+	if p.baseURL.Path == "/" {
+		target = p.resolve("/.well-known/caldav")
+	}
+	status, err := p.propfind(ctx, target, "0", discoverBody)
+	if err != nil && target != p.baseURL.String() {
+		target = p.baseURL.String()
+		status, err = p.propfind(ctx, target, "0", discoverBody)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(status.Responses) == 0 {
+		return nil, fmt.Errorf("caldav server sent no properties for %s", target)
+	}
+	prop, _ := status.Responses[0].props()
+
+	// The account URL may already be a single calendar
+	if prop.ResourceType.Calendar != nil {
+		return []ProviderCalendar{p.toCalendar(status.Responses[0].Href, prop)}, nil
+	}
+
+	home := prop.CalendarHomeSet.Href
+	if home == "" && prop.CurrentUserPrincipal.Href != "" {
+		principal, err := p.propfind(ctx, p.resolve(prop.CurrentUserPrincipal.Href), "0", discoverBody)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read principal: %w", err)
+		}
+		if len(principal.Responses) > 0 {
+			principalProp, _ := principal.Responses[0].props()
+			home = principalProp.CalendarHomeSet.Href
+		}
+	}
+	if home == "" {
+		// Treat the URL as the home, as some servers only answer on it
+		home = status.Responses[0].Href
+	}
+
+	listing, err := p.propfind(ctx, p.resolve(home), "1", discoverBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list calendars: %w", err)
+	}
+	var calendars []ProviderCalendar
+	for _, response := range listing.Responses {
+		prop, ok := response.props()
+		if !ok || prop.ResourceType.Calendar == nil || !holdsEvents(prop.Components) {
+			continue
+		}
+		calendars = append(calendars, p.toCalendar(response.Href, prop))
+	}
+	return calendars, nil
+}
+
+// toCalendar converts a calendar collection. Its ID is the collection's path.
+func (p *CalDAVProvider) toCalendar(href string, prop davProp) ProviderCalendar {
+	cal := ProviderCalendar{
+		ID:       p.path(href),
+		Name:     strings.TrimSpace(prop.DisplayName),
+		Color:    prop.CalendarColor,
+		ReadOnly: len(prop.Privileges.Privileges) > 0,
+	}
+	for _, privilege := range prop.Privileges.Privileges {
+		if privilege.Write != nil || privilege.All != nil || privilege.WriteContent != nil {
+			cal.ReadOnly = false
+		}
+	}
+	if cal.Name == "" {
+		cal.Name = strings.Trim(cal.ID[strings.LastIndex(strings.TrimSuffix(cal.ID, "/"), "/")+1:], "/")
+	}
+	// Apple writes colors as #RRGGBBAA
+	if len(cal.Color) == 9 && strings.HasPrefix(cal.Color, "#") {
+		cal.Color = cal.Color[:7]
+	}
+	return cal
+}
+
+// holdsEvents reports whether a calendar accepts VEVENTs. A calendar without the property
+// accepts every component.
+func holdsEvents(set davComponentSet) bool {
+	if len(set.Components) == 0 {
+		return true
+	}
+	for _, component := range set.Components {
+		if strings.EqualFold(component.Name, "VEVENT") {
+			return true
+		}
+	}
+	return false
+}
+
+// ListEvents runs a calendar-query for the events overlapping start to end and expands them
+func (p *CalDAVProvider) ListEvents(ctx context.Context, calendarID string, start, end time.Time) ([]ProviderEvent, error) {
+	body := fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop>
+    <d:getetag/>
+    <c:calendar-data/>
+  </d:prop>
+  <c:filter>
+    <c:comp-filter name="VCALENDAR">
+      <c:comp-filter name="VEVENT">
+        <c:time-range start="%s" end="%s"/>
+      </c:comp-filter>
+    </c:comp-filter>
+  </c:filter>
+</c:calendar-query>`, start.UTC().Format("20060102T150405Z"), end.UTC().Format("20060102T150405Z"))
+
+	status, err := p.report(ctx, p.resolve(calendarID), "1", body)
+	if err != nil {
+		return nil, err
+	}
+	var events []ProviderEvent
+	for _, response := range status.Responses {
+		prop, ok := response.props()
+		if !ok || prop.CalendarData == "" {
+			continue
+		}
+		events = append(events, p.expand(p.path(response.Href), prop.ETag, prop.CalendarData, start, end)...)
+	}
+	return events, nil
+}
+
+// Changes uses a sync-collection REPORT and fetches the changed resources with a
+// calendar-multiget. Servers without sync support get a full calendar-query every time.
+func (p *CalDAVProvider) Changes(ctx context.Context, calendarID, syncToken string, start, end time.Time) (*ProviderChanges, error) {
+	body := fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<d:sync-collection xmlns:d="DAV:">
+  <d:sync-token>%s</d:sync-token>
+  <d:sync-level>1</d:sync-level>
+  <d:prop>
+    <d:getetag/>
+  </d:prop>
+</d:sync-collection>`, xmlEscape(syncToken))
+
+	status, err := p.report(ctx, p.resolve(calendarID), "", body)
+	var statusErr *caldavStatusError
+	if errors.As(err, &statusErr) {
+		if syncToken != "" && (statusErr.code == http.StatusForbidden || statusErr.code == http.StatusConflict) {
+			// RFC 6578 answers an old token with valid-sync-token, which servers send as 403 or 409
+			return nil, ErrSyncTokenExpired
+		}
+		if syncToken == "" {
+			events, err := p.ListEvents(ctx, calendarID, start, end)
+			if err != nil {
+				return nil, err
+			}
+			return &ProviderChanges{Events: events, Full: true}, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	changes := &ProviderChanges{SyncToken: status.SyncToken, Full: syncToken == ""}
+	var changed []string
+	for _, response := range status.Responses {
+		href := p.path(response.Href)
+		if href == calendarID {
+			continue
+		}
+		if strings.Contains(response.Status, " 404") {
+			changes.Deleted = append(changes.Deleted, href)
+			continue
+		}
+		changed = append(changed, href)
+	}
+
+	for len(changed) > 0 {
+		batch := changed
+		if len(batch) > caldavMultigetBatch {
+			batch = batch[:caldavMultigetBatch]
+		}
+		changed = changed[len(batch):]
+
+		fetched, err := p.multiget(ctx, calendarID, batch)
+		if err != nil {
+			return nil, err
+		}
+		for _, response := range fetched.Responses {
+			href := p.path(response.Href)
+			prop, ok := response.props()
+			if !ok {
+				// Removed between the two reports
+				changes.Deleted = append(changes.Deleted, href)
+				continue
+			}
+			events := p.expand(href, prop.ETag, prop.CalendarData, start, end)
+			if len(events) == 0 {
+				// Outside the window, or no longer an event: nothing is kept for it
+				changes.Deleted = append(changes.Deleted, href)
+				continue
+			}
+			changes.Events = append(changes.Events, events...)
+		}
+	}
+	return changes, nil
+}
+
+// multiget fetches the data and ETags of resources
+func (p *CalDAVProvider) multiget(ctx context.Context, calendarID string, hrefs []string) (*davMultistatus, error) {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>
+<c:calendar-multiget xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop>
+    <d:getetag/>
+    <c:calendar-data/>
+  </d:prop>
+`)
+	for _, href := range hrefs {
+		b.WriteString("  <d:href>" + xmlEscape(href) + "</d:href>\n")
+	}
+	b.WriteString("</c:calendar-multiget>")
+	return p.report(ctx, p.resolve(calendarID), "1", b.String())
+}
+
+// expand turns a resource into its occurrences from start to end
+func (p *CalDAVProvider) expand(href, etag, data string, start, end time.Time) []ProviderEvent {
+	cal, err := ical.Parse(strings.NewReader(data), p.location)
+	if err != nil {
+		return nil
+	}
+	recurring := false
+	for _, event := range cal.Events {
+		if event.Recurring() || !event.RecurrenceID.IsZero() {
+			recurring = true
+		}
+	}
+
+	var events []ProviderEvent
+	for _, instance := range cal.Expand(start, end) {
+		id := href
+		if recurring {
+			id = fmt.Sprintf("%s#%d", href, instance.Start.Unix())
+		}
+		events = append(events, ProviderEvent{
+			ID:          id,
+			Resource:    href,
+			ETag:        etag,
+			Summary:     instance.Event.Summary,
+			Description: instance.Event.Description,
+			Location:    instance.Event.Location,
+			Start:       instance.Start,
+			End:         instance.End,
+			AllDay:      instance.AllDay,
+			Recurring:   recurring,
+		})
+	}
+	return events
+}
+
+// CreateEvent stores a new resource in the calendar. Servers may not send an ETag back, in
+// which case the next sync fills it in.
+func (p *CalDAVProvider) CreateEvent(ctx context.Context, calendarID string, event ProviderEvent) (*ProviderEvent, error) {
+	uid := uuid.NewString()
+	href := strings.TrimSuffix(calendarID, "/") + "/" + uid + ".ics"
+	data, err := p.encode(uid, event)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.do(ctx, http.MethodPut, p.resolve(href), "", data, map[string]string{
+		"Content-Type":  "text/calendar; charset=utf-8",
+		"If-None-Match": "*",
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	event.ID, event.Resource, event.ETag, event.Recurring = href, href, resp.Header.Get("ETag"), false
+	return &event, nil
+}
+
+// UpdateEvent replaces a resource, keeping its UID. Recurring events are left to the app
+// that created them, as rewriting one would drop its rules.
+func (p *CalDAVProvider) UpdateEvent(ctx context.Context, calendarID string, event ProviderEvent) (*ProviderEvent, error) {
+	if event.Recurring {
+		return nil, fmt.Errorf("%w: recurring events on CalDAV calendars", ErrEventReadOnly)
+	}
+
+	resp, err := p.do(ctx, http.MethodGet, p.resolve(event.Resource), "", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	current, err := io.ReadAll(io.LimitReader(resp.Body, caldavMaxResponse))
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	etag := event.ETag
+	if etag == "" {
+		etag = resp.Header.Get("ETag")
+	}
+	cal, err := ical.Parse(bytes.NewReader(current), p.location)
+	if err != nil || len(cal.Events) == 0 {
+		return nil, fmt.Errorf("failed to read event %s: %v", event.Resource, err)
+	}
+	if len(cal.Events) > 1 || cal.Events[0].Recurring() {
+		return nil, fmt.Errorf("%w: recurring events on CalDAV calendars", ErrEventReadOnly)
+	}
+
+	data, err := p.encode(cal.Events[0].UID, event)
+	if err != nil {
+		return nil, err
+	}
+	headers := map[string]string{"Content-Type": "text/calendar; charset=utf-8"}
+	if etag != "" {
+		headers["If-Match"] = etag
+	}
+	resp, err = p.do(ctx, http.MethodPut, p.resolve(event.Resource), "", data, headers)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	event.ETag = resp.Header.Get("ETag")
+	return &event, nil
+}
+
+// DeleteEvent removes a resource, with every occurrence of a recurring event
+func (p *CalDAVProvider) DeleteEvent(ctx context.Context, calendarID string, event ProviderEvent) error {
+	headers := map[string]string{}
+	if event.ETag != "" {
+		headers["If-Match"] = event.ETag
+	}
+	resp, err := p.do(ctx, http.MethodDelete, p.resolve(event.Resource), "", "", headers)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// encode writes an event as a calendar resource
+func (p *CalDAVProvider) encode(uid string, event ProviderEvent) (string, error) {
+	var buf bytes.Buffer
+	encoder := ical.Encoder{ProdID: calendarExportProdID, Stamp: time.Now()}
+	err := encoder.Encode(&buf, &ical.Calendar{Events: []*ical.Event{{
+		UID:         uid,
+		Summary:     event.Summary,
+		Description: event.Description,
+		Location:    event.Location,
+		Start:       event.Start,
+		End:         event.End,
+		AllDay:      event.AllDay,
+	}}})
+	return buf.String(), err
+}
+
+// caldavStatusError is an unexpected HTTP status from the server
+type caldavStatusError struct {
+	method string
+	target string
+	code   int
+	status string
+}
+
+func (e *caldavStatusError) Error() string {
+	return fmt.Sprintf("%s %s returned %s", e.method, e.target, e.status)
+}
+
+// propfind runs a PROPFIND
+func (p *CalDAVProvider) propfind(ctx context.Context, target, depth, body string) (*davMultistatus, error) {
+	return p.multistatus(ctx, "PROPFIND", target, depth, body)
+}
+
+// report runs a REPORT
+func (p *CalDAVProvider) report(ctx context.Context, target, depth, body string) (*davMultistatus, error) {
+	return p.multistatus(ctx, "REPORT", target, depth, body)
+}
+
+// multistatus sends a request answered with 207 Multi-Status and parses the answer
+func (p *CalDAVProvider) multistatus(ctx context.Context, method, target, depth, body string) (*davMultistatus, error) {
+	resp, err := p.do(ctx, method, target, depth, body, map[string]string{"Content-Type": "application/xml; charset=utf-8"})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, &caldavStatusError{method: method, target: target, code: resp.StatusCode, status: resp.Status}
+	}
+	var status davMultistatus
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, caldavMaxResponse)).Decode(&status); err != nil {
+		return nil, fmt.Errorf("failed to parse %s response: %w", method, err)
+	}
+	return &status, nil
+}
+
+// do sends a request, following redirects with the same method and body. Any status
+// other than 2xx is returned as a caldavStatusError, with 412 as ErrEventConflict.
+func (p *CalDAVProvider) do(ctx context.Context, method, target, depth, body string, headers map[string]string) (*http.Response, error) {
+	for redirects := 0; ; redirects++ {
+		req, err := http.NewRequestWithContext(ctx, method, target, strings.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth(p.username, p.password)
+		if depth != "" {
+			req.Header.Set("Depth", depth)
+		}
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+
+		resp, err := p.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case resp.StatusCode >= 300 && resp.StatusCode < 400 && resp.Header.Get("Location") != "" && redirects < 5:
+			next, err := req.URL.Parse(resp.Header.Get("Location"))
+			resp.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			target = next.String()
+			continue
+		case resp.StatusCode == http.StatusPreconditionFailed:
+			resp.Body.Close()
+			return nil, fmt.Errorf("%w: %s %s", ErrEventConflict, method, target)
+		case resp.StatusCode < 200 || resp.StatusCode >= 300:
+			resp.Body.Close()
+			return nil, &caldavStatusError{method: method, target: target, code: resp.StatusCode, status: resp.Status}
+		}
+		return resp, nil
+	}
+}
+
+// resolve turns an href from the server into a URL
+func (p *CalDAVProvider) resolve(href string) string {
+	ref, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	return p.baseURL.ResolveReference(ref).String()
+}
+
+// path reduces an href, which servers may send as a full URL, to its path
+func (p *CalDAVProvider) path(href string) string {
+	if parsed, err := url.Parse(href); err == nil && parsed.Path != "" {
+		return parsed.Path
+	}
+	return href
+}
+
+// xmlEscape escapes text for an XML element
+func xmlEscape(value string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
@@ -0,0 +1,322 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCalDAVServer is a small CalDAV server with one event calendar and one task list. Every
+// change bumps the version, which is also the sync token.
+type fakeCalDAVServer struct {
+	mu        sync.Mutex
+	resources map[string]fakeCalDAVResource
+	changes   map[string]int // Version each href last changed at
+	version   int
+	oldest    int // Tokens before this version are expired
+}
+
+type fakeCalDAVResource struct {
+	data string
+	etag string
+}
+
+const fakeCalDAVCalendar = "/dav/calendars/jo/family/"
+
+var fakeCalDAVHref = regexp.MustCompile(`<d:href>(.*?)</d:href>`)
+var fakeCalDAVToken = regexp.MustCompile(`<d:sync-token>(.*?)</d:sync-token>`)
+
+func newFakeCalDAVServer(t *testing.T) (*fakeCalDAVServer, *httptest.Server) {
+	fake := &fakeCalDAVServer{
+		resources: make(map[string]fakeCalDAVResource),
+		changes:   make(map[string]int),
+	}
+	server := httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+// put stores a resource as another client would
+func (f *fakeCalDAVServer) put(href, data string) string {
+	f.version++
+	etag := fmt.Sprintf(`"%d"`, f.version)
+	f.resources[href] = fakeCalDAVResource{data: data, etag: etag}
+	f.changes[href] = f.version
+	return etag
+}
+
+// remove deletes a resource as another client would
+func (f *fakeCalDAVServer) remove(href string) {
+	f.version++
+	delete(f.resources, href)
+	f.changes[href] = f.version
+}
+
+func (f *fakeCalDAVServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if username, password, ok := r.BasicAuth(); !ok || username != "jo" || password != "app-password" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+
+	switch {
+	case r.URL.Path == "/.well-known/caldav":
+		http.Redirect(w, r, "/dav/", http.StatusMovedPermanently)
+	case r.Method == "PROPFIND" && r.URL.Path == "/dav/":
+		f.multistatus(w, `<d:response><d:href>/dav/</d:href><d:propstat><d:prop>
+<d:current-user-principal><d:href>/dav/principals/jo/</d:href></d:current-user-principal>
+</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, "")
+	case r.Method == "PROPFIND" && r.URL.Path == "/dav/principals/jo/":
+		f.multistatus(w, `<d:response><d:href>/dav/principals/jo/</d:href><d:propstat><d:prop>
+<c:calendar-home-set><d:href>/dav/calendars/jo/</d:href></c:calendar-home-set>
+</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, "")
+	case r.Method == "PROPFIND" && r.URL.Path == "/dav/calendars/jo/":
+		f.multistatus(w, `<d:response><d:href>/dav/calendars/jo/</d:href><d:propstat><d:prop>
+<d:resourcetype><d:collection/></d:resourcetype>
+</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+<d:response><d:href>/dav/calendars/jo/family/</d:href><d:propstat><d:prop>
+<d:resourcetype><d:collection/><c:calendar/></d:resourcetype>
+<d:displayname>Family</d:displayname>
+<a:calendar-color>#FF2968FF</a:calendar-color>
+<c:supported-calendar-component-set><c:comp name="VEVENT"/></c:supported-calendar-component-set>
+<d:current-user-privilege-set><d:privilege><d:read/></d:privilege><d:privilege><d:write/></d:privilege></d:current-user-privilege-set>
+</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+<d:response><d:href>/dav/calendars/jo/tasks/</d:href><d:propstat><d:prop>
+<d:resourcetype><d:collection/><c:calendar/></d:resourcetype>
+<d:displayname>Tasks</d:displayname>
+<c:supported-calendar-component-set><c:comp name="VTODO"/></c:supported-calendar-component-set>
+</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, "")
+	case r.Method == "REPORT" && r.URL.Path == fakeCalDAVCalendar:
+		f.report(w, string(body))
+	case strings.HasPrefix(r.URL.Path, fakeCalDAVCalendar):
+		f.resource(w, r, string(body))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeCalDAVServer) report(w http.ResponseWriter, body string) {
+	var responses strings.Builder
+	switch {
+	case strings.Contains(body, "sync-collection"):
+		since := 0
+		if match := fakeCalDAVToken.FindStringSubmatch(body); match != nil && match[1] != "" {
+			version, err := strconv.Atoi(strings.TrimPrefix(match[1], "v"))
+			if err != nil || version < f.oldest {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			since = version
+		}
+		hrefs := make([]string, 0, len(f.changes))
+		for href, version := range f.changes {
+			if version > since {
+				hrefs = append(hrefs, href)
+			}
+		}
+		sort.Strings(hrefs)
+		for _, href := range hrefs {
+			if resource, exists := f.resources[href]; exists {
+				fmt.Fprintf(&responses, `<d:response><d:href>%s</d:href><d:propstat><d:prop><d:getetag>%s</d:getetag></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, href, resource.etag)
+			} else if since > 0 {
+				fmt.Fprintf(&responses, `<d:response><d:href>%s</d:href><d:status>HTTP/1.1 404 Not Found</d:status></d:response>`, href)
+			}
+		}
+		f.multistatus(w, responses.String(), fmt.Sprintf("v%d", f.version))
+	case strings.Contains(body, "calendar-multiget"):
+		for _, match := range fakeCalDAVHref.FindAllStringSubmatch(body, -1) {
+			if resource, exists := f.resources[match[1]]; exists {
+				fmt.Fprintf(&responses, `<d:response><d:href>http://caldav.example%s</d:href><d:propstat><d:prop><d:getetag>%s</d:getetag><c:calendar-data>%s</c:calendar-data></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, match[1], resource.etag, xmlEscape(resource.data))
+			} else {
+				fmt.Fprintf(&responses, `<d:response><d:href>%s</d:href><d:status>HTTP/1.1 404 Not Found</d:status></d:response>`, match[1])
+			}
+		}
+		f.multistatus(w, responses.String(), "")
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (f *fakeCalDAVServer) resource(w http.ResponseWriter, r *http.Request, body string) {
+	resource, exists := f.resources[r.URL.Path]
+	if match := r.Header.Get("If-Match"); match != "" && (!exists || match != resource.etag) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", resource.etag)
+		io.WriteString(w, resource.data)
+	case http.MethodPut:
+		if exists && r.Header.Get("If-None-Match") == "*" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		w.Header().Set("ETag", f.put(r.URL.Path, body))
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+		f.remove(r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeCalDAVServer) multistatus(w http.ResponseWriter, responses, syncToken string) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>
+<d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav" xmlns:a="http://apple.com/ns/ical/">%s`, responses)
+	if syncToken != "" {
+		fmt.Fprintf(w, `<d:sync-token>%s</d:sync-token>`, syncToken)
+	}
+	io.WriteString(w, `</d:multistatus>`)
+}
+
+// fakeCalDAVEvent returns a calendar resource with one event
+func fakeCalDAVEvent(uid, summary, start, extra string) string {
+	return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//Test//EN\r\nBEGIN:VEVENT\r\nUID:" + uid +
+		"\r\nSUMMARY:" + summary + "\r\nDTSTART:" + start + "\r\nDURATION:PT1H\r\n" + extra +
+		"END:VEVENT\r\nEND:VCALENDAR\r\n"
+}
+
+func TestCalDAVProvider(t *testing.T) {
+	fake, server := newFakeCalDAVServer(t)
+	fake.put(fakeCalDAVCalendar+"dentist.ics", fakeCalDAVEvent("dentist", "Dentist", "20240620T150000Z", ""))
+	fake.put(fakeCalDAVCalendar+"swim.ics", fakeCalDAVEvent("swim", "Swimming", "20240618T170000Z", "RRULE:FREQ=WEEKLY;COUNT=3\r\n"))
+
+	provider, err := NewCalDAVProvider(server.URL, "jo", "app-password", time.UTC, server.Client())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ctx := context.Background()
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)
+
+	// Discovery follows the well-known redirect, the principal and the calendar home
+	calendars, err := provider.ListCalendars(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(calendars) != 1 || calendars[0].ID != fakeCalDAVCalendar || calendars[0].Name != "Family" || calendars[0].Color != "#FF2968" || calendars[0].ReadOnly {
+		t.Fatalf("Unexpected calendars: %+v", calendars)
+	}
+
+	changes, err := provider.Changes(ctx, fakeCalDAVCalendar, "", start, end)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !changes.Full || len(changes.Events) != 4 || changes.SyncToken != "v2" {
+		t.Fatalf("Unexpected full sync: %+v", changes)
+	}
+	var dentist, swim ProviderEvent
+	for _, event := range changes.Events {
+		switch event.Summary {
+		case "Dentist":
+			dentist = event
+		case "Swimming":
+			swim = event
+		}
+	}
+	if dentist.ID != fakeCalDAVCalendar+"dentist.ics" || dentist.Recurring || dentist.ETag != `"1"` {
+		t.Fatalf("Unexpected event: %+v", dentist)
+	}
+	if !swim.Recurring || swim.Resource != fakeCalDAVCalendar+"swim.ics" || swim.ID == swim.Resource {
+		t.Fatalf("Unexpected occurrence: %+v", swim)
+	}
+
+	// Recurring events are not rewritten
+	if _, err := provider.UpdateEvent(ctx, fakeCalDAVCalendar, swim); !errors.Is(err, ErrEventReadOnly) {
+		t.Fatalf("Expected a recurring event to be read only, got %v", err)
+	}
+
+	// Changes made elsewhere come through the sync token
+	fake.mu.Lock()
+	fake.put(fakeCalDAVCalendar+"dentist.ics", fakeCalDAVEvent("dentist", "Dentist (moved)", "20240621T150000Z", ""))
+	fake.remove(fakeCalDAVCalendar + "swim.ics")
+	fake.mu.Unlock()
+	changes, err = provider.Changes(ctx, fakeCalDAVCalendar, changes.SyncToken, start, end)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if changes.Full || len(changes.Events) != 1 || changes.Events[0].Summary != "Dentist (moved)" || len(changes.Deleted) != 1 || changes.Deleted[0] != swim.Resource {
+		t.Fatalf("Unexpected changes: %+v", changes)
+	}
+
+	// The stale copy of the dentist event is refused
+	dentist.Summary = "Dentist (stale)"
+	if _, err := provider.UpdateEvent(ctx, fakeCalDAVCalendar, dentist); !errors.Is(err, ErrEventConflict) {
+		t.Fatalf("Expected a conflict, got %v", err)
+	}
+	current := changes.Events[0]
+	current.Summary = "Dentist with Sam"
+	updated, err := provider.UpdateEvent(ctx, fakeCalDAVCalendar, current)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	fake.mu.Lock()
+	stored := fake.resources[current.Resource]
+	fake.mu.Unlock()
+	if updated.ETag != stored.etag || !strings.Contains(stored.data, "UID:dentist\r\n") || !strings.Contains(stored.data, "SUMMARY:Dentist with Sam\r\n") {
+		t.Fatalf("Expected the update to keep the UID, got %q", stored.data)
+	}
+
+	created, err := provider.CreateEvent(ctx, fakeCalDAVCalendar, ProviderEvent{
+		Summary: "Camping",
+		Start:   time.Date(2024, 7, 5, 0, 0, 0, 0, time.UTC),
+		End:     time.Date(2024, 7, 7, 0, 0, 0, 0, time.UTC),
+		AllDay:  true,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(created.Resource, fakeCalDAVCalendar) || created.ETag == "" {
+		t.Fatalf("Unexpected created event: %+v", created)
+	}
+	if err := provider.DeleteEvent(ctx, fakeCalDAVCalendar, *updated); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// An expired token asks for a full sync
+	fake.mu.Lock()
+	fake.oldest = fake.version
+	fake.mu.Unlock()
+	if _, err := provider.Changes(ctx, fakeCalDAVCalendar, "v1", start, end); !errors.Is(err, ErrSyncTokenExpired) {
+		t.Fatalf("Expected the token to expire, got %v", err)
+	}
+	changes, err = provider.Changes(ctx, fakeCalDAVCalendar, "", start, end)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(changes.Events) != 1 || changes.Events[0].Summary != "Camping" || !changes.Events[0].AllDay {
+		t.Fatalf("Unexpected full sync: %+v", changes)
+	}
+
+	// A wrong password fails
+	provider, _ = NewCalDAVProvider(server.URL, "jo", "wrong", time.UTC, server.Client())
+	if _, err := provider.ListCalendars(ctx); err == nil {
+		t.Fatal("Expected a wrong password to fail")
+	}
+}
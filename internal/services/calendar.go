@@ -4,6 +4,8 @@ import (
 	"context"
 	"time"
 
+	"woodhome-webapp/internal/models"
+
 	"golang.org/x/oauth2"
 	"google.golang.org/api/calendar/v3"
 	"google.golang.org/api/option"
@@ -20,13 +22,17 @@ type CalendarEvent struct {
 	AllDay        bool   `json:"allDay,omitempty"`
 	CalendarID    string `json:"calendarId,omitempty"`
 	CalendarColor string `json:"calendarColor,omitempty"`
+	Account       string `json:"account,omitempty"` // Linked Google account the event was read through
+
+	Reminders *models.EventReminders `json:"reminders,omitempty"`
 }
 
 // CalendarInfo represents a calendar with its color information
 type CalendarInfo struct {
-	ID    string `json:"id"`
-	Name  string `json:"name"`
-	Color string `json:"color"`
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Color   string `json:"color"`
+	Account string `json:"account,omitempty"` // Linked Google account the calendar was read through
 }
 
 // CalendarColorPalette represents Google Calendar color palette
@@ -377,6 +383,7 @@ func (s *CalendarService) GetCalendarEvents(ctx context.Context, token *oauth2.T
 			Title:       item.Summary,
 			Description: item.Description,
 			Color:       getEventColor(item), // Custom function
+			Reminders:   googleEventReminders(item, nil),
 		}
 
 		// Add calendar information
@@ -514,6 +521,7 @@ func (s *CalendarService) GetCalendarEventsFiltered(ctx context.Context, token *
 			Title:       item.Summary,
 			Description: item.Description,
 			Color:       getEventColor(item),
+			Reminders:   googleEventReminders(item, nil),
 		}
 
 		// Add calendar information
@@ -569,3 +577,100 @@ func getEventColor(item *calendar.Event) string {
 	}
 	return "#3788d8" // Default blue
 }
+
+// newGoogleService creates a Calendar API client, refreshing the token first if it has expired.
+// The token is updated in place so callers can save it.
+func (s *CalendarService) newGoogleService(ctx context.Context, token *oauth2.Token) (*calendar.Service, error) {
+	if token.Expiry.Before(time.Now()) {
+		newToken, err := s.oauthConfig.TokenSource(ctx, token).Token()
+		if err != nil {
+			return nil, err
+		}
+		*token = *newToken
+	}
+	return calendar.NewService(ctx, option.WithHTTPClient(s.oauthConfig.Client(ctx, token)))
+}
+
+// calendarEntryColor returns the color of a calendar list entry
+func (s *CalendarService) calendarEntryColor(cal *calendar.CalendarListEntry) string {
+	if cal.BackgroundColor != "" {
+		return cal.BackgroundColor
+	}
+	return s.getCalendarColor(cal.ColorId)
+}
+
+// toCalendarEvent converts a Google event the same way GetCalendarEventsFiltered does
+func toCalendarEvent(item *calendar.Event, cal *calendar.CalendarListEntry, calendarColor string) CalendarEvent {
+	event := CalendarEvent{
+		ID:            item.Id,
+		Title:         item.Summary,
+		Description:   item.Description,
+		Color:         getEventColor(item),
+		CalendarID:    cal.Id,
+		CalendarColor: calendarColor,
+		Reminders:     googleEventReminders(item, cal.DefaultReminders),
+	}
+	if cal.Summary != "" {
+		event.Title = "[" + cal.Summary + "] " + event.Title
+	}
+	if event.Color == "" {
+		event.Color = calendarColor
+	}
+
+	if item.Start != nil && item.Start.DateTime != "" {
+		startTime, _ := time.Parse(time.RFC3339, item.Start.DateTime)
+		event.Start = startTime.Format(time.RFC3339)
+		if item.End != nil {
+			endTime, _ := time.Parse(time.RFC3339, item.End.DateTime)
+			event.End = endTime.Format(time.RFC3339)
+		}
+	} else if item.Start != nil {
+		event.Start = item.Start.Date
+		if item.End != nil {
+			event.End = item.End.Date
+		}
+		event.AllDay = true
+	}
+	return event
+}
+
+// googleEventReminders converts the reminders of a Google event. An event using the
+// calendar's default reminders gets defaults as its overrides, when they are known.
+func googleEventReminders(item *calendar.Event, defaults []*calendar.EventReminder) *models.EventReminders {
+	if item.Reminders == nil {
+		return nil
+	}
+	reminders := &models.EventReminders{UseDefault: item.Reminders.UseDefault}
+	overrides := item.Reminders.Overrides
+	if item.Reminders.UseDefault {
+		overrides = defaults
+	}
+	for _, override := range overrides {
+		reminders.Overrides = append(reminders.Overrides, &models.ReminderOverride{
+			Method:  override.Method,
+			Minutes: int(override.Minutes),
+		})
+	}
+	return reminders
+}
+
+// eventTimeRange parses the start and end of an event. All-day events run from midnight to
+// midnight in loc. An event without an end ends when it starts.
+func eventTimeRange(event CalendarEvent, loc *time.Location) (time.Time, time.Time, bool) {
+	parse := func(value string) (time.Time, error) {
+		if event.AllDay {
+			return time.ParseInLocation("2006-01-02", value, loc)
+		}
+		return time.Parse(time.RFC3339, value)
+	}
+
+	start, err := parse(event.Start)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	end, err := parse(event.End)
+	if err != nil || end.Before(start) {
+		end = start
+	}
+	return start, end, true
+}
@@ -0,0 +1,1052 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"woodhome-webapp/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+// CalendarAccountPrefix starts the dashboard IDs of linked account calendars and events
+const CalendarAccountPrefix = "account:"
+
+var (
+	// ErrCalendarAccountNotFound is returned for an unknown account, calendar or event
+	ErrCalendarAccountNotFound = errors.New("not found")
+	// ErrInvalidAccountEvent is returned for an event input that cannot be saved
+	ErrInvalidAccountEvent = errors.New("invalid event")
+)
+
+// accountCalendar is a calendar of a linked account with its local copy
+type accountCalendar struct {
+	providerID string
+	info       models.CalendarAccountCalendar
+	syncToken  string
+	lastFull   time.Time
+	resources  map[string][]ProviderEvent // Occurrences by provider resource
+}
+
+// accountCalendarRecord is how a calendar's copy is saved
+type accountCalendarRecord struct {
+	ProviderID string                         `json:"provider_id"`
+	Info       models.CalendarAccountCalendar `json:"info"`
+	SyncToken  string                         `json:"sync_token"`
+	LastFull   time.Time                      `json:"last_full"`
+	Resources  map[string][]ProviderEvent     `json:"resources"`
+}
+
+// calendarAccountState is an account with its calendars
+type calendarAccountState struct {
+	account   models.CalendarAccount
+	calendars map[string]*accountCalendar // By provider calendar ID
+}
+
+// CalendarAccountService links calendar accounts other than the household Google account,
+// such as a family member's iCloud or Nextcloud calendars over CalDAV or a Microsoft 365
+// account. Each account is kept in sync through a CalendarProvider and its events are served
+// alongside the Google events. App passwords are stored in the WoodHome database, and
+// Microsoft tokens next to the Google tokens.
+type CalendarAccountService struct {
+	config         *models.CalendarAccountConfig
+	db             *sql.DB
+	accounts       map[string]*calendarAccountState
+	newProvider    func(account models.CalendarAccount) (CalendarProvider, error)
+	microsoftOAuth *oauth2.Config
+	loadToken      func(provider, accountID string) (*oauth2.Token, error)
+	saveToken      func(provider, accountID string, token *oauth2.Token) error
+	deleteToken    func(provider, accountID string) error
+	httpClient     *http.Client
+	now            func() time.Time
+	syncMu         sync.Mutex // One sync at a time
+	mu             sync.RWMutex
+}
+
+// NewCalendarAccountService creates a new CalendarAccountService instance.
+// Accounts are kept in memory only when db is nil.
+func NewCalendarAccountService(db *sql.DB, config *models.CalendarAccountConfig) *CalendarAccountService {
+	if config == nil {
+		config = &models.CalendarAccountConfig{}
+	}
+	if config.SyncInterval <= 0 {
+		config.SyncInterval = 5 * time.Minute
+	}
+	if config.FullSyncInterval <= 0 {
+		config.FullSyncInterval = 24 * time.Hour
+	}
+	if config.PastDays <= 0 {
+		config.PastDays = 90
+	}
+	if config.FutureDays <= 0 {
+		config.FutureDays = 365
+	}
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+	if config.GraphURL == "" {
+		config.GraphURL = MicrosoftGraphURL
+	}
+
+	s := &CalendarAccountService{
+		config:         config,
+		db:             db,
+		accounts:       make(map[string]*calendarAccountState),
+		microsoftOAuth: NewMicrosoftOAuthConfig(),
+		loadToken:      LoadProviderOAuthToken,
+		saveToken:      SaveProviderOAuthToken,
+		deleteToken:    DeleteProviderOAuthToken,
+		httpClient:     &http.Client{Timeout: 30 * time.Second},
+		now:            time.Now,
+	}
+	s.newProvider = s.provider
+	return s
+}
+
+// Start loads the linked accounts and keeps them in sync in the background
+func (s *CalendarAccountService) Start(ctx context.Context) error {
+	logrus.Info("Starting calendar account service...")
+
+	if err := s.load(); err != nil {
+		return fmt.Errorf("failed to load calendar accounts: %w", err)
+	}
+
+	go s.startLoop(ctx)
+	return nil
+}
+
+// startLoop syncs right away and then on every interval until the context is cancelled
+func (s *CalendarAccountService) startLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.config.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, account := range s.GetAccounts() {
+			if !account.Enabled {
+				continue
+			}
+			if err := s.SyncAccount(ctx, account.ID); err != nil {
+				logrus.Warnf("Calendar accounts: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// provider creates the CalendarProvider of an account
+func (s *CalendarAccountService) provider(account models.CalendarAccount) (CalendarProvider, error) {
+	switch account.Provider {
+	case models.CalendarProviderCalDAV:
+		return NewCalDAVProvider(account.URL, account.Username, account.Password, s.config.Location, s.httpClient)
+	case models.CalendarProviderMicrosoft:
+		token, err := s.loadToken(models.CalendarProviderMicrosoft, account.ID)
+		if err != nil {
+			return nil, fmt.Errorf("no Microsoft token, sign in with Microsoft again: %w", err)
+		}
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, s.httpClient)
+		source := &savingTokenSource{
+			source: s.microsoftOAuth.TokenSource(ctx, token),
+			last:   token,
+			save: func(token *oauth2.Token) error {
+				return s.saveToken(models.CalendarProviderMicrosoft, account.ID, token)
+			},
+		}
+		return NewMicrosoftGraphProvider(s.config.GraphURL, source, s.config.Location, s.httpClient), nil
+	default:
+		return nil, fmt.Errorf("unknown calendar provider %q", account.Provider)
+	}
+}
+
+// GetAccounts returns every account sorted by name, without passwords
+func (s *CalendarAccountService) GetAccounts() []*models.CalendarAccount {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	accounts := make([]*models.CalendarAccount, 0, len(s.accounts))
+	for _, state := range s.accounts {
+		accounts = append(accounts, publicAccount(state.account))
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].Name < accounts[j].Name
+	})
+	return accounts
+}
+
+// GetAccount returns an account without its password
+func (s *CalendarAccountService) GetAccount(id string) (*models.CalendarAccount, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state, exists := s.accounts[id]
+	if !exists {
+		return nil, false
+	}
+	return publicAccount(state.account), true
+}
+
+// CreateAccount validates and saves a new CalDAV account. Its calendars appear after the
+// first sync. Microsoft accounts are linked by LinkMicrosoftAccount instead.
+func (s *CalendarAccountService) CreateAccount(account models.CalendarAccount) (*models.CalendarAccount, error) {
+	if strings.EqualFold(strings.TrimSpace(account.Provider), models.CalendarProviderMicrosoft) {
+		return nil, fmt.Errorf("microsoft accounts are linked by signing in at /auth/microsoft/login")
+	}
+	if account.Password == "" {
+		return nil, fmt.Errorf("password is required")
+	}
+	if err := validateCalendarAccount(&account); err != nil {
+		return nil, err
+	}
+	account = models.CalendarAccount{
+		ID:        uuid.NewString(),
+		Name:      account.Name,
+		Provider:  account.Provider,
+		URL:       account.URL,
+		Username:  account.Username,
+		Password:  account.Password,
+		Enabled:   account.Enabled,
+		UpdatedAt: s.now(),
+	}
+
+	if err := s.saveAccount(account); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.accounts[account.ID] = &calendarAccountState{account: account, calendars: make(map[string]*accountCalendar)}
+	s.mu.Unlock()
+
+	logrus.Infof("Calendar accounts: added %q", account.Name)
+	return publicAccount(account), nil
+}
+
+// UpdateAccount replaces the settings of an account. An empty password keeps the current one,
+// and a new server or user drops the local copy.
+func (s *CalendarAccountService) UpdateAccount(id string, update models.CalendarAccount) (*models.CalendarAccount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, exists := s.accounts[id]
+	if !exists {
+		return nil, fmt.Errorf("account %s %w", id, ErrCalendarAccountNotFound)
+	}
+	if update.Password == "" {
+		update.Password = state.account.Password
+	}
+	if state.account.Provider == models.CalendarProviderMicrosoft {
+		// Only the name and whether it syncs can change, the rest comes from signing in
+		update.Provider, update.URL, update.Username = state.account.Provider, "", state.account.Username
+	} else if strings.EqualFold(strings.TrimSpace(update.Provider), models.CalendarProviderMicrosoft) {
+		return nil, fmt.Errorf("microsoft accounts are linked by signing in at /auth/microsoft/login")
+	}
+	if err := validateCalendarAccount(&update); err != nil {
+		return nil, err
+	}
+
+	account := state.account
+	moved := account.Provider != update.Provider || account.URL != update.URL || account.Username != update.Username
+	account.Name = update.Name
+	account.Provider = update.Provider
+	account.URL = update.URL
+	account.Username = update.Username
+	account.Password = update.Password
+	account.Enabled = update.Enabled
+	account.UpdatedAt = s.now()
+	if moved {
+		account.Calendars = nil
+		account.LastSync = nil
+		account.LastError = ""
+		if err := s.deleteCalendars(id); err != nil {
+			return nil, err
+		}
+		state.calendars = make(map[string]*accountCalendar)
+	}
+
+	if err := s.saveAccount(account); err != nil {
+		return nil, err
+	}
+	state.account = account
+	return publicAccount(account), nil
+}
+
+// DeleteAccount unlinks an account and drops its local copy
+func (s *CalendarAccountService) DeleteAccount(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, exists := s.accounts[id]
+	if !exists {
+		return fmt.Errorf("account %s %w", id, ErrCalendarAccountNotFound)
+	}
+	if s.db != nil {
+		if _, err := s.db.Exec(`DELETE FROM calendar_accounts WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete account: %w", err)
+		}
+		if err := s.deleteCalendars(id); err != nil {
+			return err
+		}
+	}
+	if state.account.Provider == models.CalendarProviderMicrosoft {
+		if err := s.deleteToken(models.CalendarProviderMicrosoft, id); err != nil {
+			logrus.Warnf("Calendar accounts: failed to delete the token of %q: %v", state.account.Name, err)
+		}
+	}
+	delete(s.accounts, id)
+	logrus.Infof("Calendar accounts: removed %q", state.account.Name)
+	return nil
+}
+
+// LinkMicrosoftAccount saves the token of a Microsoft sign in, adding the account or
+// refreshing the token of the account it belongs to
+func (s *CalendarAccountService) LinkMicrosoftAccount(ctx context.Context, token *oauth2.Token) (*models.CalendarAccount, error) {
+	graph := NewMicrosoftGraphProvider(s.config.GraphURL, oauth2.StaticTokenSource(token), s.config.Location, s.httpClient)
+	name, email, err := graph.Me(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the Microsoft profile: %w", err)
+	}
+	if email == "" {
+		return nil, fmt.Errorf("the Microsoft profile has no email address")
+	}
+	if name == "" {
+		name = email
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var account models.CalendarAccount
+	for _, state := range s.accounts {
+		if state.account.Provider == models.CalendarProviderMicrosoft && strings.EqualFold(state.account.Username, email) {
+			account = state.account
+		}
+	}
+	if account.ID == "" {
+		account = models.CalendarAccount{
+			ID:        uuid.NewString(),
+			Name:      name,
+			Provider:  models.CalendarProviderMicrosoft,
+			Username:  email,
+			Enabled:   true,
+			UpdatedAt: s.now(),
+		}
+		if err := s.saveAccount(account); err != nil {
+			return nil, err
+		}
+		s.accounts[account.ID] = &calendarAccountState{account: account, calendars: make(map[string]*accountCalendar)}
+		logrus.Infof("Calendar accounts: added %q", account.Name)
+	}
+
+	if err := s.saveToken(models.CalendarProviderMicrosoft, account.ID, token); err != nil {
+		return nil, fmt.Errorf("failed to save Microsoft token: %w", err)
+	}
+	return publicAccount(account), nil
+}
+
+// SyncAccount lists an account's calendars and pulls the changes of each one
+func (s *CalendarAccountService) SyncAccount(ctx context.Context, id string) error {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	s.mu.RLock()
+	state, exists := s.accounts[id]
+	var account models.CalendarAccount
+	if exists {
+		account = state.account
+	}
+	s.mu.RUnlock()
+	if !exists {
+		return fmt.Errorf("account %s %w", id, ErrCalendarAccountNotFound)
+	}
+
+	err := s.syncAccount(ctx, account)
+
+	s.mu.Lock()
+	state, exists = s.accounts[id]
+	if !exists || state.account.UpdatedAt != account.UpdatedAt {
+		// Removed or edited during the sync, which a later sync picks up
+		s.mu.Unlock()
+		return err
+	}
+	now := s.now()
+	state.account.LastSync = &now
+	state.account.LastError = ""
+	if err != nil {
+		state.account.LastError = err.Error()
+	}
+	state.account.Calendars = state.calendarInfos()
+	saved := state.account
+	s.mu.Unlock()
+
+	if saveErr := s.saveAccount(saved); saveErr != nil {
+		return saveErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to sync %s: %w", account.Name, err)
+	}
+	return nil
+}
+
+// syncAccount does the work of SyncAccount
+func (s *CalendarAccountService) syncAccount(ctx context.Context, account models.CalendarAccount) error {
+	provider, err := s.newProvider(account)
+	if err != nil {
+		return err
+	}
+	calendars, err := provider.ListCalendars(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list calendars: %w", err)
+	}
+
+	listed := make(map[string]bool)
+	failed := 0
+	for _, cal := range calendars {
+		listed[cal.ID] = true
+		if err := s.syncCalendar(ctx, account, provider, cal); err != nil {
+			logrus.Warnf("Calendar accounts: failed to sync %s of %s: %v", cal.Name, account.Name, err)
+			failed++
+		}
+	}
+
+	s.mu.Lock()
+	var removed []string
+	if state, exists := s.accounts[account.ID]; exists {
+		for providerID := range state.calendars {
+			if !listed[providerID] {
+				delete(state.calendars, providerID)
+				removed = append(removed, providerID)
+			}
+		}
+	}
+	s.mu.Unlock()
+	for _, providerID := range removed {
+		if err := s.deleteCalendar(account.ID, providerID); err != nil {
+			logrus.Warnf("Calendar accounts: failed to remove calendar %s: %v", providerID, err)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d calendars failed to sync", failed, len(calendars))
+	}
+	return nil
+}
+
+// syncCalendar pulls the changes of one calendar. The window moves with the days, so the
+// calendar is listed again in full every FullSyncInterval and whenever its token expires.
+func (s *CalendarAccountService) syncCalendar(ctx context.Context, account models.CalendarAccount, provider CalendarProvider, cal ProviderCalendar) error {
+	now := s.now()
+	start := now.AddDate(0, 0, -s.config.PastDays)
+	end := now.AddDate(0, 0, s.config.FutureDays)
+
+	s.mu.RLock()
+	syncToken := ""
+	if state, exists := s.accounts[account.ID]; exists {
+		if existing, exists := state.calendars[cal.ID]; exists && now.Sub(existing.lastFull) < s.config.FullSyncInterval {
+			syncToken = existing.syncToken
+		}
+	}
+	s.mu.RUnlock()
+
+	changes, err := provider.Changes(ctx, cal.ID, syncToken, start, end)
+	if syncToken != "" && errors.Is(err, ErrSyncTokenExpired) {
+		logrus.Infof("Calendar accounts: sync token of %s expired, syncing it again in full", cal.Name)
+		changes, err = provider.Changes(ctx, cal.ID, "", start, end)
+	}
+
+	s.mu.Lock()
+	state, exists := s.accounts[account.ID]
+	if !exists || state.account.UpdatedAt != account.UpdatedAt {
+		s.mu.Unlock()
+		return nil
+	}
+	existing, exists := state.calendars[cal.ID]
+	if !exists {
+		existing = &accountCalendar{providerID: cal.ID, resources: make(map[string][]ProviderEvent)}
+		state.calendars[cal.ID] = existing
+	}
+	existing.info.ID = CalendarAccountPrefix + account.ID + ":" + cal.ID
+	existing.info.Name = cal.Name
+	existing.info.Color = cal.Color
+	if existing.info.Color == "" {
+		existing.info.Color = defaultFeedColor
+	}
+	existing.info.ReadOnly = cal.ReadOnly
+	if err != nil {
+		existing.info.LastError = err.Error()
+		s.mu.Unlock()
+		return err
+	}
+
+	if changes.Full {
+		existing.resources = make(map[string][]ProviderEvent)
+		existing.lastFull = now
+	}
+	for _, resource := range changes.Deleted {
+		delete(existing.resources, resource)
+	}
+	replaced := make(map[string]bool)
+	for _, event := range changes.Events {
+		if !replaced[event.Resource] {
+			existing.resources[event.Resource] = nil
+			replaced[event.Resource] = true
+		}
+		existing.resources[event.Resource] = append(existing.resources[event.Resource], event)
+	}
+	existing.syncToken = changes.SyncToken
+	existing.info.Events = 0
+	for _, events := range existing.resources {
+		existing.info.Events += len(events)
+	}
+	existing.info.LastSync = &now
+	existing.info.LastError = ""
+	record := existing.record()
+	s.mu.Unlock()
+
+	if len(changes.Events) > 0 || len(changes.Deleted) > 0 {
+		logrus.Infof("Calendar accounts: %s of %s has %d changes (full sync: %t)", cal.Name, account.Name, len(changes.Events)+len(changes.Deleted), changes.Full)
+	}
+	return s.saveCalendar(account.ID, record)
+}
+
+// GetCalendars lists the calendars of the enabled accounts
+func (s *CalendarAccountService) GetCalendars() []CalendarInfo {
+	calendars := []CalendarInfo{}
+	for _, account := range s.GetAccounts() {
+		if !account.Enabled {
+			continue
+		}
+		for _, cal := range account.Calendars {
+			calendars = append(calendars, CalendarInfo{ID: cal.ID, Name: cal.Name, Color: cal.Color})
+		}
+	}
+	return calendars
+}
+
+// GetEvents returns the events of the enabled accounts overlapping start to end, in the same
+// form as Google events. When calendarIDs is not empty only those calendars are included.
+func (s *CalendarAccountService) GetEvents(start, end time.Time, calendarIDs []string) []CalendarEvent {
+	selected := make(map[string]bool)
+	for _, id := range calendarIDs {
+		selected[id] = true
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var matches []ProviderEvent
+	var converted []CalendarEvent
+	for _, state := range s.accounts {
+		if !state.account.Enabled {
+			continue
+		}
+		for _, cal := range state.calendars {
+			if len(selected) > 0 && !selected[cal.info.ID] {
+				continue
+			}
+			for _, events := range cal.resources {
+				for _, event := range events {
+					if event.Start.Before(end) && (event.End.After(start) || (event.End.Equal(event.Start) && !event.Start.Before(start))) {
+						matches = append(matches, event)
+						converted = append(converted, s.toCalendarEvent(state.account.ID, cal, event))
+					}
+				}
+			}
+		}
+	}
+
+	order := make([]int, len(matches))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return matches[order[i]].Start.Before(matches[order[j]].Start)
+	})
+	events := make([]CalendarEvent, 0, len(order))
+	for _, i := range order {
+		events = append(events, converted[i])
+	}
+	return events
+}
+
+// toCalendarEvent converts an occurrence the way Google events are converted
+func (s *CalendarAccountService) toCalendarEvent(accountID string, cal *accountCalendar, event ProviderEvent) CalendarEvent {
+	converted := CalendarEvent{
+		ID:            accountEventID(accountID, cal.providerID, event.ID),
+		Title:         event.Summary,
+		Description:   event.Description,
+		Color:         cal.info.Color,
+		AllDay:        event.AllDay,
+		CalendarID:    cal.info.ID,
+		CalendarColor: cal.info.Color,
+	}
+	if cal.info.Name != "" {
+		converted.Title = "[" + cal.info.Name + "] " + converted.Title
+	}
+	if event.AllDay {
+		converted.Start = event.Start.Format("2006-01-02")
+		converted.End = event.End.Format("2006-01-02")
+	} else {
+		converted.Start = event.Start.In(s.config.Location).Format(time.RFC3339)
+		converted.End = event.End.In(s.config.Location).Format(time.RFC3339)
+	}
+	return converted
+}
+
+// CreateEvent adds an event to a calendar of an account
+func (s *CalendarAccountService) CreateEvent(ctx context.Context, accountID string, input models.CalendarAccountEventInput) (*CalendarEvent, error) {
+	event, err := s.eventFromInput(input)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	state, exists := s.accounts[accountID]
+	var account models.CalendarAccount
+	var target *accountCalendar
+	if exists {
+		account = state.account
+		for _, cal := range state.calendars {
+			if cal.info.ID == input.CalendarID {
+				target = cal
+			}
+		}
+	}
+	s.mu.RUnlock()
+	if !exists || target == nil {
+		return nil, fmt.Errorf("calendar %s %w", input.CalendarID, ErrCalendarAccountNotFound)
+	}
+	if target.info.ReadOnly {
+		return nil, fmt.Errorf("%w: %s is read only", ErrEventReadOnly, target.info.Name)
+	}
+
+	provider, err := s.newProvider(account)
+	if err != nil {
+		return nil, err
+	}
+	created, err := provider.CreateEvent(ctx, target.providerID, event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create event: %w", err)
+	}
+
+	s.refreshCalendar(ctx, account, provider, target.providerID)
+	converted := s.toCalendarEvent(accountID, target, *created)
+	return &converted, nil
+}
+
+// UpdateEvent changes an event, failing with ErrEventConflict when it was changed elsewhere
+// since the last sync
+func (s *CalendarAccountService) UpdateEvent(ctx context.Context, eventID string, input models.CalendarAccountEventInput) (*CalendarEvent, error) {
+	update, err := s.eventFromInput(input)
+	if err != nil {
+		return nil, err
+	}
+	account, cal, stored, err := s.findEvent(eventID)
+	if err != nil {
+		return nil, err
+	}
+	if cal.info.ReadOnly {
+		return nil, fmt.Errorf("%w: %s is read only", ErrEventReadOnly, cal.info.Name)
+	}
+
+	provider, err := s.newProvider(account)
+	if err != nil {
+		return nil, err
+	}
+	update.ID, update.Resource, update.ETag, update.Recurring = stored.ID, stored.Resource, stored.ETag, stored.Recurring
+	updated, err := provider.UpdateEvent(ctx, cal.providerID, update)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update event: %w", err)
+	}
+
+	s.refreshCalendar(ctx, account, provider, cal.providerID)
+	converted := s.toCalendarEvent(account.ID, cal, *updated)
+	return &converted, nil
+}
+
+// DeleteEvent removes an event. For a recurring CalDAV event that is the whole series.
+func (s *CalendarAccountService) DeleteEvent(ctx context.Context, eventID string) error {
+	account, cal, stored, err := s.findEvent(eventID)
+	if err != nil {
+		return err
+	}
+	if cal.info.ReadOnly {
+		return fmt.Errorf("%w: %s is read only", ErrEventReadOnly, cal.info.Name)
+	}
+
+	provider, err := s.newProvider(account)
+	if err != nil {
+		return err
+	}
+	if err := provider.DeleteEvent(ctx, cal.providerID, stored); err != nil {
+		return fmt.Errorf("failed to delete event: %w", err)
+	}
+
+	s.refreshCalendar(ctx, account, provider, cal.providerID)
+	return nil
+}
+
+// refreshCalendar pulls a calendar's changes after a write, so the store has the new ETags
+func (s *CalendarAccountService) refreshCalendar(ctx context.Context, account models.CalendarAccount, provider CalendarProvider, providerID string) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	s.mu.RLock()
+	var cal ProviderCalendar
+	if state, exists := s.accounts[account.ID]; exists {
+		if existing, exists := state.calendars[providerID]; exists {
+			cal = ProviderCalendar{ID: providerID, Name: existing.info.Name, Color: existing.info.Color, ReadOnly: existing.info.ReadOnly}
+		}
+	}
+	s.mu.RUnlock()
+	if cal.ID == "" {
+		return
+	}
+	if err := s.syncCalendar(ctx, account, provider, cal); err != nil {
+		logrus.Warnf("Calendar accounts: failed to refresh %s after a change: %v", cal.Name, err)
+	}
+}
+
+// findEvent looks up a stored occurrence by its dashboard event ID
+func (s *CalendarAccountService) findEvent(eventID string) (models.CalendarAccount, *accountCalendar, ProviderEvent, error) {
+	accountID, providerID, id, ok := parseAccountEventID(eventID)
+	if !ok {
+		return models.CalendarAccount{}, nil, ProviderEvent{}, fmt.Errorf("event %s %w", eventID, ErrCalendarAccountNotFound)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if state, exists := s.accounts[accountID]; exists {
+		if cal, exists := state.calendars[providerID]; exists {
+			for _, events := range cal.resources {
+				for _, event := range events {
+					if event.ID == id {
+						return state.account, cal, event, nil
+					}
+				}
+			}
+		}
+	}
+	return models.CalendarAccount{}, nil, ProviderEvent{}, fmt.Errorf("event %s %w", eventID, ErrCalendarAccountNotFound)
+}
+
+// eventFromInput parses the times of an event input
+func (s *CalendarAccountService) eventFromInput(input models.CalendarAccountEventInput) (ProviderEvent, error) {
+	event := ProviderEvent{
+		Summary:     strings.TrimSpace(input.Title),
+		Description: input.Description,
+		Location:    input.Location,
+		AllDay:      input.AllDay,
+	}
+	if event.Summary == "" {
+		return ProviderEvent{}, fmt.Errorf("%w: title is required", ErrInvalidAccountEvent)
+	}
+
+	parse := func(value string) (time.Time, error) {
+		if input.AllDay {
+			return time.Parse("2006-01-02", value)
+		}
+		return time.Parse(time.RFC3339, value)
+	}
+	var err error
+	if event.Start, err = parse(input.Start); err != nil {
+		return ProviderEvent{}, fmt.Errorf("%w: start %v", ErrInvalidAccountEvent, err)
+	}
+	if event.End, err = parse(input.End); err != nil {
+		return ProviderEvent{}, fmt.Errorf("%w: end %v", ErrInvalidAccountEvent, err)
+	}
+	if !event.End.After(event.Start) {
+		return ProviderEvent{}, fmt.Errorf("%w: end must be after start", ErrInvalidAccountEvent)
+	}
+	return event, nil
+}
+
+// SplitCalendarAccountIDs separates the linked account calendar IDs from the other calendar IDs
+func SplitCalendarAccountIDs(calendarIDs []string) (otherIDs, accountIDs []string) {
+	for _, id := range calendarIDs {
+		if strings.HasPrefix(id, CalendarAccountPrefix) {
+			accountIDs = append(accountIDs, id)
+		} else {
+			otherIDs = append(otherIDs, id)
+		}
+	}
+	return otherIDs, accountIDs
+}
+
+// accountEventID builds the dashboard ID of an occurrence. Provider IDs may hold slashes, so
+// they are encoded to keep the ID usable in a URL path.
+func accountEventID(accountID, providerCalendarID, eventID string) string {
+	return CalendarAccountPrefix + accountID + ":" + base64.RawURLEncoding.EncodeToString([]byte(providerCalendarID+"\n"+eventID))
+}
+
+// parseAccountEventID reverses accountEventID
+func parseAccountEventID(eventID string) (accountID, providerCalendarID, id string, ok bool) {
+	rest, found := strings.CutPrefix(eventID, CalendarAccountPrefix)
+	if !found {
+		return "", "", "", false
+	}
+	accountID, encoded, found := strings.Cut(rest, ":")
+	if !found {
+		return "", "", "", false
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", "", false
+	}
+	providerCalendarID, id, found = strings.Cut(string(decoded), "\n")
+	return accountID, providerCalendarID, id, found
+}
+
+// savingTokenSource saves a linked account's token whenever it is refreshed
+type savingTokenSource struct {
+	source oauth2.TokenSource
+	save   func(token *oauth2.Token) error
+	last   *oauth2.Token
+	mu     sync.Mutex
+}
+
+func (ts *savingTokenSource) Token() (*oauth2.Token, error) {
+	token, err := ts.source.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if token.AccessToken != ts.last.AccessToken || !token.Expiry.Equal(ts.last.Expiry) {
+		ts.last = token
+		if err := ts.save(token); err != nil {
+			logrus.Warnf("Calendar accounts: failed to save refreshed token: %v", err)
+		}
+	}
+	return token, nil
+}
+
+// publicAccount copies an account without its password
+func publicAccount(account models.CalendarAccount) *models.CalendarAccount {
+	account.Password = ""
+	account.Calendars = append([]models.CalendarAccountCalendar{}, account.Calendars...)
+	return &account
+}
+
+// calendarInfos lists the calendars of an account by name
+func (state *calendarAccountState) calendarInfos() []models.CalendarAccountCalendar {
+	infos := make([]models.CalendarAccountCalendar, 0, len(state.calendars))
+	for _, cal := range state.calendars {
+		infos = append(infos, cal.info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// record returns how a calendar is saved
+func (cal *accountCalendar) record() accountCalendarRecord {
+	resources := make(map[string][]ProviderEvent, len(cal.resources))
+	for resource, events := range cal.resources {
+		resources[resource] = append([]ProviderEvent{}, events...)
+	}
+	return accountCalendarRecord{
+		ProviderID: cal.providerID,
+		Info:       cal.info,
+		SyncToken:  cal.syncToken,
+		LastFull:   cal.lastFull,
+		Resources:  resources,
+	}
+}
+
+// validateCalendarAccount checks an account
+func validateCalendarAccount(account *models.CalendarAccount) error {
+	account.Provider = strings.ToLower(strings.TrimSpace(account.Provider))
+	if account.Provider == "" {
+		account.Provider = models.CalendarProviderCalDAV
+	}
+	switch account.Provider {
+	case models.CalendarProviderCalDAV:
+		account.URL = strings.TrimSpace(account.URL)
+		parsed, err := url.Parse(account.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("url must be an http or https link")
+		}
+	case models.CalendarProviderMicrosoft:
+	default:
+		return fmt.Errorf("provider must be %q or %q", models.CalendarProviderCalDAV, models.CalendarProviderMicrosoft)
+	}
+	account.Username = strings.TrimSpace(account.Username)
+	if account.Username == "" {
+		return fmt.Errorf("username is required")
+	}
+	account.Name = strings.TrimSpace(account.Name)
+	if account.Name == "" {
+		account.Name = account.Username
+	}
+	return nil
+}
+
+// load reads the saved accounts and their calendars
+func (s *CalendarAccountService) load() error {
+	if s.db == nil {
+		return nil
+	}
+
+	for _, statement := range []string{`
+		CREATE TABLE IF NOT EXISTS calendar_accounts (
+			id TEXT PRIMARY KEY,
+			data TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`, `
+		CREATE TABLE IF NOT EXISTS calendar_account_calendars (
+			account_id TEXT NOT NULL,
+			calendar_id TEXT NOT NULL,
+			data TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (account_id, calendar_id)
+		)`,
+	} {
+		if _, err := s.db.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	accounts := make(map[string]*calendarAccountState)
+	rows, err := s.db.Query(`SELECT id, data FROM calendar_accounts`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return err
+		}
+		var account models.CalendarAccount
+		if err := json.Unmarshal([]byte(data), &account); err != nil {
+			logrus.Warnf("Calendar accounts: ignoring unreadable account %s: %v", id, err)
+			continue
+		}
+		account.ID = id
+		accounts[id] = &calendarAccountState{account: account, calendars: make(map[string]*accountCalendar)}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = s.db.Query(`SELECT account_id, calendar_id, data FROM calendar_account_calendars`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var accountID, calendarID, data string
+		if err := rows.Scan(&accountID, &calendarID, &data); err != nil {
+			return err
+		}
+		state, exists := accounts[accountID]
+		if !exists {
+			continue
+		}
+		var record accountCalendarRecord
+		if err := json.Unmarshal([]byte(data), &record); err != nil {
+			logrus.Warnf("Calendar accounts: ignoring unreadable calendar %s: %v", calendarID, err)
+			continue
+		}
+		if record.Resources == nil {
+			record.Resources = make(map[string][]ProviderEvent)
+		}
+		state.calendars[calendarID] = &accountCalendar{
+			providerID: calendarID,
+			info:       record.Info,
+			syncToken:  record.SyncToken,
+			lastFull:   record.LastFull,
+			resources:  record.Resources,
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accounts = accounts
+	logrus.Infof("Calendar accounts: loaded %d accounts", len(accounts))
+	return nil
+}
+
+// saveAccount stores an account
+func (s *CalendarAccountService) saveAccount(account models.CalendarAccount) error {
+	if s.db == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(account)
+	if err != nil {
+		return fmt.Errorf("failed to marshal account: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+		INSERT OR REPLACE INTO calendar_accounts (id, data, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+	`, account.ID, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save account: %w", err)
+	}
+	return nil
+}
+
+// saveCalendar stores the local copy of a calendar
+func (s *CalendarAccountService) saveCalendar(accountID string, record accountCalendarRecord) error {
+	if s.db == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal calendar: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+		INSERT OR REPLACE INTO calendar_account_calendars (account_id, calendar_id, data, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+	`, accountID, record.ProviderID, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save calendar: %w", err)
+	}
+	return nil
+}
+
+// deleteCalendar removes the local copy of a calendar
+func (s *CalendarAccountService) deleteCalendar(accountID, calendarID string) error {
+	if s.db == nil {
+		return nil
+	}
+	_, err := s.db.Exec(`DELETE FROM calendar_account_calendars WHERE account_id = ? AND calendar_id = ?`, accountID, calendarID)
+	return err
+}
+
+// deleteCalendars removes the local copies of every calendar of an account
+func (s *CalendarAccountService) deleteCalendars(accountID string) error {
+	if s.db == nil {
+		return nil
+	}
+	if _, err := s.db.Exec(`DELETE FROM calendar_account_calendars WHERE account_id = ?`, accountID); err != nil {
+		return fmt.Errorf("failed to delete calendars: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"woodhome-webapp/internal/database"
+	"woodhome-webapp/internal/models"
+)
+
+func TestCalendarAccountSync(t *testing.T) {
+	fake, server := newFakeCalDAVServer(t)
+	fake.put(fakeCalDAVCalendar+"dentist.ics", fakeCalDAVEvent("dentist", "Dentist", "20240620T150000Z", ""))
+	fake.put(fakeCalDAVCalendar+"swim.ics", fakeCalDAVEvent("swim", "Swimming", "20240618T170000Z", "RRULE:FREQ=WEEKLY;COUNT=3\r\n"))
+
+	db, err := database.OpenSQLite(filepath.Join(t.TempDir(), "home.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	newAccountService := func() *CalendarAccountService {
+		service := NewCalendarAccountService(db, &models.CalendarAccountConfig{Location: time.UTC})
+		service.httpClient = server.Client()
+		service.now = func() time.Time { return time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC) }
+		if err := service.load(); err != nil {
+			t.Fatalf("Failed to load accounts: %v", err)
+		}
+		return service
+	}
+	service := newAccountService()
+	ctx := context.Background()
+
+	if _, err := service.CreateAccount(models.CalendarAccount{URL: server.URL, Username: "jo"}); err == nil {
+		t.Fatal("Expected an account without a password to be rejected")
+	}
+	account, err := service.CreateAccount(models.CalendarAccount{Name: "Jo's iCloud", URL: server.URL, Username: "jo", Password: "app-password", Enabled: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if account.Provider != models.CalendarProviderCalDAV || account.Password != "" {
+		t.Fatalf("Unexpected account: %+v", account)
+	}
+	if err := service.SyncAccount(ctx, account.ID); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	calendars := service.GetCalendars()
+	if len(calendars) != 1 || calendars[0].Name != "Family" || !strings.HasPrefix(calendars[0].ID, CalendarAccountPrefix) {
+		t.Fatalf("Unexpected calendars: %+v", calendars)
+	}
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	events := service.GetEvents(start, end, nil)
+	if len(events) != 3 || events[0].Title != "[Family] Swimming" || events[1].Title != "[Family] Dentist" || events[1].CalendarID != calendars[0].ID {
+		t.Fatalf("Unexpected events: %+v", events)
+	}
+	if len(service.GetEvents(start, end, []string{"feed:other"})) != 0 {
+		t.Fatal("Expected other calendars to be filtered out")
+	}
+
+	// The copy and the password survive a restart
+	service = newAccountService()
+	if stored, _ := service.GetAccount(account.ID); stored == nil || stored.Password != "" || len(stored.Calendars) != 1 || stored.LastSync == nil {
+		t.Fatalf("Unexpected stored account: %+v", stored)
+	}
+	if len(service.GetEvents(start, end, nil)) != 3 {
+		t.Fatal("Expected the events to be loaded from the database")
+	}
+	fake.mu.Lock()
+	fake.remove(fakeCalDAVCalendar + "swim.ics")
+	fake.mu.Unlock()
+	if err := service.SyncAccount(ctx, account.ID); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	events = service.GetEvents(start, end, nil)
+	if len(events) != 1 || events[0].Title != "[Family] Dentist" {
+		t.Fatalf("Unexpected events after the sync: %+v", events)
+	}
+
+	// Edits go to the server, and a change made elsewhere in the meantime is a conflict
+	dentistID := events[0].ID
+	updated, err := service.UpdateEvent(ctx, dentistID, models.CalendarAccountEventInput{Title: "Dentist with Sam", Start: "2024-06-20T15:00:00Z", End: "2024-06-20T16:00:00Z"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if updated.ID != dentistID || updated.Title != "[Family] Dentist with Sam" {
+		t.Fatalf("Unexpected updated event: %+v", updated)
+	}
+	fake.mu.Lock()
+	fake.put(fakeCalDAVCalendar+"dentist.ics", fakeCalDAVEvent("dentist", "Dentist (moved)", "20240621T150000Z", ""))
+	fake.mu.Unlock()
+	if _, err := service.UpdateEvent(ctx, dentistID, models.CalendarAccountEventInput{Title: "Dentist", Start: "2024-06-20T15:00:00Z", End: "2024-06-20T16:00:00Z"}); !errors.Is(err, ErrEventConflict) {
+		t.Fatalf("Expected a conflict, got %v", err)
+	}
+	if _, err := service.UpdateEvent(ctx, dentistID, models.CalendarAccountEventInput{Title: "Dentist", Start: "tomorrow"}); !errors.Is(err, ErrInvalidAccountEvent) {
+		t.Fatalf("Expected the input to be rejected, got %v", err)
+	}
+
+	created, err := service.CreateEvent(ctx, account.ID, models.CalendarAccountEventInput{CalendarID: calendars[0].ID, Title: "Camping", Start: "2024-06-28", End: "2024-06-30", AllDay: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if created.Start != "2024-06-28" || len(service.GetEvents(start, end, []string{calendars[0].ID})) != 2 {
+		t.Fatalf("Expected the new event in the store, got %+v", created)
+	}
+	if err := service.DeleteEvent(ctx, created.ID); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := service.DeleteEvent(ctx, created.ID); !errors.Is(err, ErrCalendarAccountNotFound) {
+		t.Fatalf("Expected the deleted event to be gone, got %v", err)
+	}
+
+	// Unlinking drops the copy
+	if err := service.DeleteAccount(account.ID); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if service = newAccountService(); len(service.GetAccounts()) != 0 || len(service.GetEvents(start, end, nil)) != 0 {
+		t.Fatal("Expected the account to be removed")
+	}
+}
+
+func TestAccountEventID(t *testing.T) {
+	id := accountEventID("acct", "/dav/calendars/jo/family/", "/dav/calendars/jo/family/swim.ics#1718730000")
+	if strings.ContainsAny(strings.TrimPrefix(id, CalendarAccountPrefix), "/#") {
+		t.Fatalf("Expected the ID to be usable in a path, got %q", id)
+	}
+	accountID, calendarID, eventID, ok := parseAccountEventID(id)
+	if !ok || accountID != "acct" || calendarID != "/dav/calendars/jo/family/" || eventID != "/dav/calendars/jo/family/swim.ics#1718730000" {
+		t.Fatalf("Unexpected parts: %q %q %q %v", accountID, calendarID, eventID, ok)
+	}
+	if _, _, _, ok := parseAccountEventID("feed:abc:def"); ok {
+		t.Fatal("Expected a feed ID to be rejected")
+	}
+
+	other, accounts := SplitCalendarAccountIDs([]string{"primary", "account:acct:/dav/calendars/jo/family/", "feed:abc"})
+	if len(other) != 2 || len(accounts) != 1 {
+		t.Fatalf("Unexpected split: %v %v", other, accounts)
+	}
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CalendarDayChecker looks through the household calendar for events that change a day's plans
+type CalendarDayChecker struct {
+	calendarService *CalendarService
+	userID          int
+}
+
+// NewCalendarDayChecker creates a new CalendarDayChecker for the given user's calendars
+func NewCalendarDayChecker(calendarService *CalendarService, userID int) *CalendarDayChecker {
+	return &CalendarDayChecker{
+		calendarService: calendarService,
+		userID:          userID,
+	}
+}
+
+// FindEvent returns the first event on the given day whose title contains one of the keywords,
+// or nil if there is none
+func (c *CalendarDayChecker) FindEvent(ctx context.Context, day time.Time, calendarIDs, keywords []string) (*CalendarEvent, error) {
+	if len(keywords) == 0 {
+		return nil, nil
+	}
+
+	token, err := LoadOAuthToken(c.userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load calendar token: %w", err)
+	}
+
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	end := start.AddDate(0, 0, 1)
+
+	var events []CalendarEvent
+	if len(calendarIDs) > 0 {
+		events, err = c.calendarService.GetCalendarEventsFiltered(ctx, token, start, end, calendarIDs)
+	} else {
+		events, err = c.calendarService.GetCalendarEvents(ctx, token, start, end)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch calendar events: %w", err)
+	}
+
+	// The token may have been refreshed
+	if err := SaveOAuthToken(c.userID, token); err != nil {
+		return nil, fmt.Errorf("failed to save calendar token: %w", err)
+	}
+
+	for i := range events {
+		if matchesKeyword(events[i].Title, keywords) {
+			return &events[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// matchesKeyword reports whether the title contains one of the keywords, ignoring case
+func matchesKeyword(title string, keywords []string) bool {
+	title = strings.ToLower(title)
+	for _, keyword := range keywords {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" && strings.Contains(title, keyword) {
+			return true
+		}
+	}
+	return false
+}
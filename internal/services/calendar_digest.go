@@ -0,0 +1,508 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"woodhome-webapp/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// calendarDigestRetryInterval is the wait before sending a failed digest again
+const calendarDigestRetryInterval = 10 * time.Minute
+
+// CalendarListSource lists the calendars of every household calendar source
+type CalendarListSource func(ctx context.Context) ([]CalendarInfo, error)
+
+// CalendarDigestService emails the family agenda of today and tomorrow each morning, grouped
+// by calendar with all-day events first and overlapping events highlighted. The time it was
+// last sent is saved, so a restart does not send it twice.
+type CalendarDigestService struct {
+	config         *models.CalendarDigestConfig
+	db             *sql.DB
+	settings       models.CalendarDigestSettings
+	eventSource    CalendarEventSource
+	calendarSource CalendarListSource
+	emailService   *EmailService
+	now            func() time.Time
+	sendMu         sync.Mutex // One digest at a time
+	mu             sync.RWMutex
+}
+
+// NewCalendarDigestService creates a new CalendarDigestService instance.
+// The settings are kept in memory only when db is nil.
+func NewCalendarDigestService(eventSource CalendarEventSource, db *sql.DB, config *models.CalendarDigestConfig) *CalendarDigestService {
+	if config == nil {
+		config = &models.CalendarDigestConfig{}
+	}
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = time.Minute
+	}
+	if config.SendWindow <= 0 {
+		config.SendWindow = 3 * time.Hour
+	}
+	if config.TemplatePath == "" {
+		config.TemplatePath = "web/templates/email/calendar-digest.html"
+	}
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+
+	return &CalendarDigestService{
+		config:      config,
+		db:          db,
+		settings:    models.CalendarDigestSettings{Time: "07:00", Recipients: []string{}, CalendarIDs: []string{}},
+		eventSource: eventSource,
+		now:         time.Now,
+	}
+}
+
+// SetCalendarSource names the calendars the events are grouped by
+func (s *CalendarDigestService) SetCalendarSource(calendarSource CalendarListSource) {
+	s.calendarSource = calendarSource
+}
+
+// SetEmailService sends the digest
+func (s *CalendarDigestService) SetEmailService(emailService *EmailService) {
+	s.emailService = emailService
+}
+
+// Start loads the settings and starts checking whether the digest is due
+func (s *CalendarDigestService) Start(ctx context.Context) error {
+	logrus.Info("Starting calendar digest service...")
+
+	if err := s.load(); err != nil {
+		return fmt.Errorf("failed to load calendar digest settings: %w", err)
+	}
+
+	go s.startLoop(ctx)
+	return nil
+}
+
+// startLoop checks whether the digest is due right away and then on every check interval
+func (s *CalendarDigestService) startLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		s.check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetSettings returns the schedule, recipients and calendars of the digest
+func (s *CalendarDigestService) GetSettings() models.CalendarDigestSettings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	settings := s.settings
+	settings.Recipients = append([]string{}, s.settings.Recipients...)
+	settings.CalendarIDs = append([]string{}, s.settings.CalendarIDs...)
+	return settings
+}
+
+// UpdateSettings replaces the schedule, recipients and calendars of the digest. A digest due
+// earlier today is not sent late because of the change.
+func (s *CalendarDigestService) UpdateSettings(update models.CalendarDigestSettings) (*models.CalendarDigestSettings, error) {
+	if err := validateCalendarDigestSettings(&update); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	settings := s.settings
+	settings.Enabled = update.Enabled
+	settings.Time = update.Time
+	settings.Recipients = update.Recipients
+	settings.CalendarIDs = update.CalendarIDs
+	settings.UpdatedAt = s.now()
+	s.mu.Unlock()
+
+	if err := s.save(settings); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.settings = settings
+	s.mu.Unlock()
+
+	logrus.Infof("Calendar digest: sending at %s to %d recipients (enabled: %v)", settings.Time, len(settings.Recipients), settings.Enabled)
+	updated := s.GetSettings()
+	return &updated, nil
+}
+
+// Preview renders the digest of day, and the day after, as the email would show it
+func (s *CalendarDigestService) Preview(ctx context.Context, day time.Time) (string, error) {
+	digest, err := s.Build(ctx, day)
+	if err != nil {
+		return "", err
+	}
+	return s.Render(digest)
+}
+
+// Send emails the digest of today to the recipients right away
+func (s *CalendarDigestService) Send(ctx context.Context) error {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	settings := s.GetSettings()
+	now := s.now()
+	err := s.send(ctx, settings.Recipients, now)
+
+	s.mu.Lock()
+	settings = s.settings
+	settings.LastAttempt = &now
+	settings.LastError = ""
+	if err != nil {
+		settings.LastError = err.Error()
+	} else {
+		settings.LastSent = &now
+	}
+	s.settings = settings
+	s.mu.Unlock()
+
+	if saveErr := s.save(settings); saveErr != nil {
+		logrus.Warnf("Calendar digest: %v", saveErr)
+	}
+	return err
+}
+
+// send builds, renders and emails the digest of the day of now
+func (s *CalendarDigestService) send(ctx context.Context, recipients []string, now time.Time) error {
+	if len(recipients) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+	if !s.emailService.Configured() {
+		return fmt.Errorf("email is not configured")
+	}
+
+	digest, err := s.Build(ctx, now)
+	if err != nil {
+		return err
+	}
+	body, err := s.Render(digest)
+	if err != nil {
+		return err
+	}
+	return s.emailService.SendHTML(recipients, digest.Subject, body)
+}
+
+// check sends the digest once it is due today, retrying a failed one now and then until the
+// send window closes
+func (s *CalendarDigestService) check(ctx context.Context) {
+	settings := s.GetSettings()
+	if !settings.Enabled || len(settings.Recipients) == 0 {
+		return
+	}
+
+	now := s.now().In(s.config.Location)
+	clock, err := time.Parse("15:04", settings.Time)
+	if err != nil {
+		return
+	}
+	scheduled := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, s.config.Location)
+	if now.Before(scheduled) || now.Sub(scheduled) > s.config.SendWindow || scheduled.Before(settings.UpdatedAt) {
+		return
+	}
+	if settings.LastSent != nil && !settings.LastSent.Before(scheduled) {
+		return
+	}
+	if settings.LastAttempt != nil && !settings.LastAttempt.Before(scheduled) && now.Sub(*settings.LastAttempt) < calendarDigestRetryInterval {
+		return
+	}
+
+	if err := s.Send(ctx); err != nil {
+		logrus.Warnf("Calendar digest: failed to send: %v", err)
+		return
+	}
+	logrus.Infof("Calendar digest: sent to %d recipients", len(settings.Recipients))
+}
+
+// Build gathers the agenda of day and the day after in the home time zone
+func (s *CalendarDigestService) Build(ctx context.Context, day time.Time) (*models.CalendarDigest, error) {
+	settings := s.GetSettings()
+	loc := s.config.Location
+	day = day.In(loc)
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+	end := start.AddDate(0, 0, 2)
+
+	events, err := s.eventSource(ctx, start, end, settings.CalendarIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read events: %w", err)
+	}
+
+	var calendars []CalendarInfo
+	if s.calendarSource != nil {
+		calendars, err = s.calendarSource(ctx)
+		if err != nil {
+			logrus.Warnf("Calendar digest: failed to list calendars: %v", err)
+		}
+	}
+
+	selected := make(map[string]bool)
+	for _, id := range settings.CalendarIDs {
+		selected[id] = true
+	}
+	var included []CalendarEvent
+	for _, event := range events {
+		if len(selected) == 0 || selected[event.CalendarID] {
+			included = append(included, event)
+		}
+	}
+
+	digest := &models.CalendarDigest{
+		Subject:     "Family agenda for " + start.Format("Monday, January 2"),
+		GeneratedAt: s.now(),
+	}
+	for i, label := range []string{"Today", "Tomorrow"} {
+		digest.Days = append(digest.Days, s.buildDay(label, start.AddDate(0, 0, i), included, calendars))
+	}
+	return digest, nil
+}
+
+// digestEntry is an event of one day with its times
+type digestEntry struct {
+	event CalendarEvent
+	start time.Time
+	end   time.Time
+	item  models.CalendarDigestEvent
+}
+
+// buildDay groups the events of one day by calendar and marks the ones that overlap
+func (s *CalendarDigestService) buildDay(label string, dayStart time.Time, events []CalendarEvent, calendars []CalendarInfo) models.CalendarDigestDay {
+	dayEnd := dayStart.AddDate(0, 0, 1)
+	day := models.CalendarDigestDay{
+		Label:  label,
+		Date:   dayStart.Format("Monday, January 2"),
+		Groups: []models.CalendarDigestGroup{},
+	}
+
+	var entries []*digestEntry
+	for _, event := range events {
+		start, end, ok := eventTimeRange(event, s.config.Location)
+		if !ok {
+			continue
+		}
+		if !start.Before(dayEnd) || end.Before(dayStart) || (end.Equal(dayStart) && !start.Equal(end)) {
+			continue
+		}
+		entries = append(entries, &digestEntry{
+			event: event,
+			start: start,
+			end:   end,
+			item: models.CalendarDigestEvent{
+				Title:  plainEventTitle(event.Title),
+				Time:   s.digestTime(event, start, end, dayStart, dayEnd),
+				AllDay: event.AllDay,
+			},
+		})
+	}
+
+	// Timed events overlapping each other, such as two practices at once, are conflicts
+	for i, a := range entries {
+		for _, b := range entries[i+1:] {
+			if a.event.AllDay || b.event.AllDay || !a.start.Before(b.end) || !b.start.Before(a.end) {
+				continue
+			}
+			a.item.Conflict, b.item.Conflict = true, true
+			a.item.ConflictsWith = append(a.item.ConflictsWith, b.item.Title)
+			b.item.ConflictsWith = append(b.item.ConflictsWith, a.item.Title)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].event.AllDay != entries[j].event.AllDay {
+			return entries[i].event.AllDay
+		}
+		if !entries[i].start.Equal(entries[j].start) {
+			return entries[i].start.Before(entries[j].start)
+		}
+		return entries[i].item.Title < entries[j].item.Title
+	})
+
+	// Calendars in the order they are listed, then any unknown ones by ID
+	order := make(map[string]int)
+	for i, cal := range calendars {
+		if _, exists := order[cal.ID]; !exists {
+			order[cal.ID] = i
+		}
+	}
+	groups := make(map[string]*models.CalendarDigestGroup)
+	var ids []string
+	for _, entry := range entries {
+		group, exists := groups[entry.event.CalendarID]
+		if !exists {
+			group = &models.CalendarDigestGroup{CalendarID: entry.event.CalendarID, Name: entry.event.CalendarID, Color: entry.event.CalendarColor}
+			if i, known := order[entry.event.CalendarID]; known {
+				group.Name = calendars[i].Name
+				group.Account = calendars[i].Account
+				if calendars[i].Color != "" {
+					group.Color = calendars[i].Color
+				}
+			}
+			groups[entry.event.CalendarID] = group
+			ids = append(ids, entry.event.CalendarID)
+		}
+		group.Events = append(group.Events, entry.item)
+		day.Events++
+		if entry.item.Conflict {
+			day.Conflicts++
+		}
+	}
+	sort.SliceStable(ids, func(i, j int) bool {
+		oi, knownI := order[ids[i]]
+		oj, knownJ := order[ids[j]]
+		if knownI != knownJ {
+			return knownI
+		}
+		if knownI {
+			return oi < oj
+		}
+		return ids[i] < ids[j]
+	})
+	for _, id := range ids {
+		day.Groups = append(day.Groups, *groups[id])
+	}
+	return day
+}
+
+// digestTime describes when an event happens on a day, such as "All day" or
+// "4:00 PM - 5:30 PM"
+func (s *CalendarDigestService) digestTime(event CalendarEvent, start, end, dayStart, dayEnd time.Time) string {
+	if event.AllDay {
+		return "All day"
+	}
+	start, end = start.In(s.config.Location), end.In(s.config.Location)
+
+	switch {
+	case start.Before(dayStart) && end.After(dayEnd):
+		return "All day"
+	case start.Before(dayStart):
+		return "Until " + end.Format("3:04 PM")
+	case end.After(dayEnd):
+		return "From " + start.Format("3:04 PM")
+	case start.Equal(end):
+		return start.Format("3:04 PM")
+	}
+	return start.Format("3:04 PM") + " - " + end.Format("3:04 PM")
+}
+
+// Render renders a digest with the email template
+func (s *CalendarDigestService) Render(digest *models.CalendarDigest) (string, error) {
+	tmpl, err := template.ParseFiles(s.config.TemplatePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse digest template: %w", err)
+	}
+
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, digest); err != nil {
+		return "", fmt.Errorf("failed to render digest: %w", err)
+	}
+	return body.String(), nil
+}
+
+// validateCalendarDigestSettings checks the schedule and recipients
+func validateCalendarDigestSettings(settings *models.CalendarDigestSettings) error {
+	if _, err := time.Parse("15:04", settings.Time); err != nil {
+		return fmt.Errorf("time must be HH:MM")
+	}
+
+	recipients := []string{}
+	for _, recipient := range settings.Recipients {
+		recipient = strings.TrimSpace(recipient)
+		if recipient == "" {
+			continue
+		}
+		if !strings.Contains(recipient, "@") || strings.ContainsAny(recipient, " \r\n") {
+			return fmt.Errorf("invalid recipient %q", recipient)
+		}
+		recipients = append(recipients, recipient)
+	}
+	if settings.Enabled && len(recipients) == 0 {
+		return fmt.Errorf("the digest needs recipients")
+	}
+	settings.Recipients = recipients
+
+	if settings.CalendarIDs == nil {
+		settings.CalendarIDs = []string{}
+	}
+	return nil
+}
+
+// load reads the settings
+func (s *CalendarDigestService) load() error {
+	if s.db == nil {
+		return nil
+	}
+
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS calendar_digest (
+			id TEXT PRIMARY KEY,
+			data TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	var data string
+	err = s.db.QueryRow(`SELECT data FROM calendar_digest WHERE id = 'settings'`).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var settings models.CalendarDigestSettings
+	if err := json.Unmarshal([]byte(data), &settings); err != nil {
+		logrus.Warnf("Calendar digest: ignoring unreadable settings: %v", err)
+		return nil
+	}
+	if settings.Recipients == nil {
+		settings.Recipients = []string{}
+	}
+	if settings.CalendarIDs == nil {
+		settings.CalendarIDs = []string{}
+	}
+
+	s.mu.Lock()
+	s.settings = settings
+	s.mu.Unlock()
+	return nil
+}
+
+// save stores the settings
+func (s *CalendarDigestService) save(settings models.CalendarDigestSettings) error {
+	if s.db == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to marshal digest settings: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+		INSERT OR REPLACE INTO calendar_digest (id, data, updated_at)
+		VALUES ('settings', ?, CURRENT_TIMESTAMP)
+	`, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save digest settings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"net/smtp"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"woodhome-webapp/internal/database"
+	"woodhome-webapp/internal/models"
+)
+
+func TestCalendarDigest(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Skip("No time zone data")
+	}
+
+	db, err := database.OpenSQLite(filepath.Join(t.TempDir(), "home.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	at := func(day, hour, minute int) string {
+		return time.Date(2024, 10, day, hour, minute, 0, 0, chicago).Format(time.RFC3339)
+	}
+	events := []CalendarEvent{
+		{ID: "soccer", CalendarID: "kids", Title: "[Kids] Soccer practice", Start: at(25, 16, 0), End: at(25, 17, 30)},
+		{ID: "piano", CalendarID: "kids", Title: "Piano", Start: at(25, 17, 0), End: at(25, 18, 0)},
+		{ID: "trash", CalendarID: "household:chores", Title: "Trash pickup", Start: "2024-10-25", End: "2024-10-26", AllDay: true},
+		{ID: "dentist", CalendarID: "parents", Title: "Dentist", Start: at(25, 8, 0), End: at(25, 9, 0)},
+		{ID: "trip", CalendarID: "parents", Title: "Camping trip", Start: at(25, 17, 45), End: at(26, 12, 0)},
+		{ID: "work", CalendarID: "work", Title: "Offsite", Start: at(25, 9, 0), End: at(25, 17, 0)},
+	}
+	calendars := []CalendarInfo{
+		{ID: "parents", Name: "Parents", Color: "#16a765", Account: "mom@example.com"},
+		{ID: "kids", Name: "Kids", Color: "#f691b2"},
+		{ID: "household:chores", Name: "Chores"},
+		{ID: "work", Name: "Work"},
+	}
+
+	var sent []string
+	emailService := NewEmailService(&models.EmailConfig{SMTPHost: "smtp.example.com", From: "home@example.com"})
+	emailService.sendMail = func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+		sent = append(sent, string(msg))
+		return nil
+	}
+
+	now := time.Date(2024, 10, 25, 6, 0, 0, 0, chicago)
+	newDigestService := func() *CalendarDigestService {
+		service := NewCalendarDigestService(func(ctx context.Context, start, end time.Time, calendarIDs []string) ([]CalendarEvent, error) {
+			return events, nil
+		}, db, &models.CalendarDigestConfig{
+			TemplatePath: filepath.Join("..", "..", "web", "templates", "email", "calendar-digest.html"),
+			Location:     chicago,
+		})
+		service.SetCalendarSource(func(ctx context.Context) ([]CalendarInfo, error) {
+			return calendars, nil
+		})
+		service.SetEmailService(emailService)
+		service.now = func() time.Time { return now }
+		if err := service.load(); err != nil {
+			t.Fatalf("Failed to load settings: %v", err)
+		}
+		return service
+	}
+	service := newDigestService()
+
+	if _, err := service.UpdateSettings(models.CalendarDigestSettings{Enabled: true, Time: "7am"}); err == nil {
+		t.Fatal("Expected an invalid time to be rejected")
+	}
+	if _, err := service.UpdateSettings(models.CalendarDigestSettings{
+		Enabled:     true,
+		Time:        "07:00",
+		Recipients:  []string{" parents@example.com "},
+		CalendarIDs: []string{"kids", "household:chores", "parents"},
+	}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Grouped in the order calendars are listed, all-day events first, overlapping events marked,
+	// and unselected calendars left out
+	digest, err := service.Build(context.Background(), now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	today := digest.Days[0]
+	if digest.Subject != "Family agenda for Friday, October 25" || today.Label != "Today" || today.Events != 5 || today.Conflicts != 3 {
+		t.Fatalf("Unexpected digest: %+v", digest)
+	}
+	if len(today.Groups) != 3 || today.Groups[0].Name != "Parents" || today.Groups[1].Name != "Kids" || today.Groups[2].Events[0].Time != "All day" {
+		t.Fatalf("Unexpected groups: %+v", today.Groups)
+	}
+	kids := today.Groups[1].Events
+	if kids[0].Title != "Soccer practice" || kids[0].Time != "4:00 PM - 5:30 PM" || !kids[0].Conflict || kids[0].ConflictsWith[0] != "Piano" {
+		t.Fatalf("Unexpected kids events: %+v", kids)
+	}
+	if trip := today.Groups[0].Events[1]; trip.Time != "From 5:45 PM" || !trip.Conflict {
+		t.Fatalf("Unexpected trip: %+v", trip)
+	}
+	tomorrow := digest.Days[1]
+	if tomorrow.Events != 1 || tomorrow.Groups[0].Events[0].Time != "Until 12:00 PM" || tomorrow.Conflicts != 0 {
+		t.Fatalf("Unexpected tomorrow: %+v", tomorrow)
+	}
+
+	body, err := service.Preview(context.Background(), now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(body, "Soccer practice") || !strings.Contains(body, "Overlaps with Piano") || !strings.Contains(body, "border-left-color: #f691b2") {
+		t.Fatalf("Unexpected preview: %s", body)
+	}
+
+	// Sent once after 7:00, also across a restart, and again the next morning
+	service.check(context.Background())
+	if len(sent) != 0 {
+		t.Fatal("Expected no digest before 7:00")
+	}
+	now = now.Add(90 * time.Minute)
+	service.check(context.Background())
+	service.check(context.Background())
+	if len(sent) != 1 || !strings.Contains(sent[0], "Content-Type: text/html") || !strings.Contains(sent[0], "To: parents@example.com") {
+		t.Fatalf("Expected one digest, got %d", len(sent))
+	}
+	service = newDigestService()
+	service.check(context.Background())
+	if len(sent) != 1 || service.GetSettings().LastSent == nil {
+		t.Fatal("Expected the digest not to be sent again after a restart")
+	}
+	now = now.Add(24 * time.Hour)
+	service.check(context.Background())
+	if len(sent) != 2 {
+		t.Fatal("Expected the digest to be sent the next morning")
+	}
+
+	// Changing the time after it passed does not send a late digest that day
+	now = now.Add(24 * time.Hour)
+	if _, err := service.UpdateSettings(models.CalendarDigestSettings{Enabled: true, Time: "06:30", Recipients: []string{"parents@example.com"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	service.check(context.Background())
+	if len(sent) != 2 {
+		t.Fatal("Expected no late digest after changing the time")
+	}
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"woodhome-webapp/internal/ical"
+	"woodhome-webapp/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// calendarExportProdID identifies WoodHome in exported calendars
+const calendarExportProdID = "-//WoodHome//Family Calendar//EN"
+
+// CalendarExportService renders the family calendar as iCalendar and manages the secret
+// subscription links that let phones and other calendar apps subscribe to it
+type CalendarExportService struct {
+	config        *models.CalendarExportConfig
+	db            *sql.DB
+	subscriptions map[string]*models.CalendarSubscription // By token hash
+	now           func() time.Time
+	mu            sync.RWMutex
+}
+
+// NewCalendarExportService creates a new CalendarExportService instance.
+// Subscriptions are kept in memory only when db is nil.
+func NewCalendarExportService(db *sql.DB, config *models.CalendarExportConfig) *CalendarExportService {
+	if config == nil {
+		config = &models.CalendarExportConfig{}
+	}
+	if config.Name == "" {
+		config.Name = "WoodHome"
+	}
+	if config.PastDays <= 0 {
+		config.PastDays = 30
+	}
+	if config.FutureDays <= 0 {
+		config.FutureDays = 365
+	}
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+
+	return &CalendarExportService{
+		config:        config,
+		db:            db,
+		subscriptions: make(map[string]*models.CalendarSubscription),
+		now:           time.Now,
+	}
+}
+
+// Start loads the saved subscriptions
+func (s *CalendarExportService) Start(ctx context.Context) error {
+	logrus.Info("Starting calendar export service...")
+
+	if err := s.load(); err != nil {
+		return fmt.Errorf("failed to load calendar subscriptions: %w", err)
+	}
+	return nil
+}
+
+// Range returns the window of events an export covers, from PastDays before today to
+// FutureDays after it
+func (s *CalendarExportService) Range() (time.Time, time.Time) {
+	now := s.now().In(s.config.Location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, s.config.Location)
+	return today.AddDate(0, 0, -s.config.PastDays), today.AddDate(0, 0, s.config.FutureDays)
+}
+
+// GetSubscriptions returns a user's subscriptions, oldest first
+func (s *CalendarExportService) GetSubscriptions(userID int) []*models.CalendarSubscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subscriptions := []*models.CalendarSubscription{}
+	for _, subscription := range s.subscriptions {
+		if subscription.UserID == userID {
+			copied := *subscription
+			subscriptions = append(subscriptions, &copied)
+		}
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].CreatedAt.Before(subscriptions[j].CreatedAt)
+	})
+	return subscriptions
+}
+
+// GetSubscription returns one of a user's subscriptions by ID
+func (s *CalendarExportService) GetSubscription(userID int, id string) (*models.CalendarSubscription, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, subscription := range s.subscriptions {
+		if subscription.ID == id && subscription.UserID == userID {
+			copied := *subscription
+			return &copied, true
+		}
+	}
+	return nil, false
+}
+
+// CreateSubscription creates a subscription link for a user and returns it with its secret
+// token. Only a hash of the token is kept, so it cannot be shown again.
+func (s *CalendarExportService) CreateSubscription(userID int, name string, calendars []string) (*models.CalendarSubscription, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", fmt.Errorf("subscription name is required")
+	}
+	var selected []string
+	for _, id := range calendars {
+		if id = strings.TrimSpace(id); id != "" {
+			selected = append(selected, id)
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate subscription token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	subscription := models.CalendarSubscription{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Name:      name,
+		Calendars: selected,
+		TokenHash: hashSubscriptionToken(token),
+		CreatedAt: s.now(),
+	}
+	if err := s.save(subscription); err != nil {
+		return nil, "", err
+	}
+
+	s.mu.Lock()
+	s.subscriptions[subscription.TokenHash] = &subscription
+	s.mu.Unlock()
+
+	logrus.Infof("Calendar export: created subscription %q for user %d", name, userID)
+	copied := subscription
+	return &copied, token, nil
+}
+
+// RevokeSubscription deletes a subscription, after which its link stops working
+func (s *CalendarExportService) RevokeSubscription(userID int, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, subscription := range s.subscriptions {
+		if subscription.ID != id || subscription.UserID != userID {
+			continue
+		}
+		if s.db != nil {
+			if _, err := s.db.Exec(`DELETE FROM calendar_subscriptions WHERE id = ?`, id); err != nil {
+				return fmt.Errorf("failed to delete subscription: %w", err)
+			}
+		}
+		delete(s.subscriptions, hash)
+		logrus.Infof("Calendar export: revoked subscription %q", subscription.Name)
+		return nil
+	}
+	return fmt.Errorf("subscription %s not found", id)
+}
+
+// Authenticate finds the subscription a token belongs to and records its use
+func (s *CalendarExportService) Authenticate(token string) (*models.CalendarSubscription, bool) {
+	if token == "" {
+		return nil, false
+	}
+	hash := hashSubscriptionToken(token)
+
+	// Saved under the lock so a revoke cannot be undone by a request in flight
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subscription, exists := s.subscriptions[hash]
+	if !exists {
+		return nil, false
+	}
+	now := s.now()
+	subscription.LastUsed = &now
+	if err := s.save(*subscription); err != nil {
+		logrus.Warnf("Calendar export: %v", err)
+	}
+	copied := *subscription
+	return &copied, true
+}
+
+// Write renders events as an iCalendar stream
+func (s *CalendarExportService) Write(w io.Writer, events []CalendarEvent) error {
+	cal := &ical.Calendar{Name: s.config.Name}
+	for _, event := range events {
+		converted, err := s.toICalEvent(event)
+		if err != nil {
+			logrus.Warnf("Calendar export: skipping event %s: %v", event.ID, err)
+			continue
+		}
+		cal.Events = append(cal.Events, converted)
+	}
+
+	encoder := ical.Encoder{ProdID: calendarExportProdID, Method: "PUBLISH", Location: s.config.Location, Stamp: s.now()}
+	return encoder.Encode(w, cal)
+}
+
+// toICalEvent converts an event in the form the calendar page uses
+func (s *CalendarExportService) toICalEvent(event CalendarEvent) (*ical.Event, error) {
+	layout := time.RFC3339
+	if event.AllDay {
+		layout = "2006-01-02"
+	}
+	start, err := time.Parse(layout, event.Start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start: %w", err)
+	}
+	var end time.Time
+	if event.End != "" {
+		if end, err = time.Parse(layout, event.End); err != nil {
+			return nil, fmt.Errorf("invalid end: %w", err)
+		}
+	}
+
+	// The same event can show up in two calendars, so the calendar is part of the UID
+	uid := event.ID
+	if event.CalendarID != "" {
+		uid += "/" + event.CalendarID
+	}
+	return &ical.Event{
+		UID:         uid + "@woodhome",
+		Summary:     event.Title,
+		Description: event.Description,
+		Start:       start,
+		End:         end,
+		AllDay:      event.AllDay,
+	}, nil
+}
+
+// hashSubscriptionToken returns the form a token is stored in
+func hashSubscriptionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// load reads the saved subscriptions
+func (s *CalendarExportService) load() error {
+	if s.db == nil {
+		return nil
+	}
+
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS calendar_subscriptions (
+			id TEXT PRIMARY KEY,
+			token_hash TEXT NOT NULL UNIQUE,
+			data TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.db.Query(`SELECT id, token_hash, data FROM calendar_subscriptions`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	subscriptions := make(map[string]*models.CalendarSubscription)
+	for rows.Next() {
+		var id, hash, data string
+		if err := rows.Scan(&id, &hash, &data); err != nil {
+			return err
+		}
+		var subscription models.CalendarSubscription
+		if err := json.Unmarshal([]byte(data), &subscription); err != nil {
+			logrus.Warnf("Calendar export: ignoring unreadable subscription %s: %v", id, err)
+			continue
+		}
+		subscription.ID = id
+		subscription.TokenHash = hash
+		subscriptions[hash] = &subscription
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscriptions = subscriptions
+	logrus.Infof("Calendar export: loaded %d subscriptions", len(subscriptions))
+	return nil
+}
+
+// save stores a subscription
+func (s *CalendarExportService) save(subscription models.CalendarSubscription) error {
+	if s.db == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(subscription)
+	if err != nil {
+		return fmt.Errorf("failed to marshal subscription: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+		INSERT OR REPLACE INTO calendar_subscriptions (id, token_hash, data, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+	`, subscription.ID, subscription.TokenHash, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save subscription: %w", err)
+	}
+	return nil
+}
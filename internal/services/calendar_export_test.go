@@ -0,0 +1,108 @@
+package services
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"woodhome-webapp/internal/database"
+	"woodhome-webapp/internal/ical"
+	"woodhome-webapp/internal/models"
+)
+
+func TestCalendarSubscriptions(t *testing.T) {
+	db, err := database.OpenSQLite(filepath.Join(t.TempDir(), "home.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	newExportService := func() *CalendarExportService {
+		service := NewCalendarExportService(db, nil)
+		if err := service.load(); err != nil {
+			t.Fatalf("Failed to load subscriptions: %v", err)
+		}
+		return service
+	}
+	service := newExportService()
+
+	if _, _, err := service.CreateSubscription(1, " ", nil); err == nil {
+		t.Fatal("Expected a subscription without a name to be rejected")
+	}
+	subscription, token, err := service.CreateSubscription(1, "Kitchen iPad", []string{"family", " "})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(token) < 40 || len(subscription.Calendars) != 1 || strings.Contains(subscription.TokenHash, token) {
+		t.Fatalf("Unexpected subscription: %+v with token %q", subscription, token)
+	}
+
+	// Tokens survive a restart, and only the hash is stored
+	service = newExportService()
+	var stored string
+	if err := db.QueryRow(`SELECT data FROM calendar_subscriptions`).Scan(&stored); err != nil || strings.Contains(stored, token) {
+		t.Fatalf("Expected the token to stay out of the stored data, got %q and %v", stored, err)
+	}
+	authenticated, ok := service.Authenticate(token)
+	if !ok || authenticated.ID != subscription.ID || authenticated.UserID != 1 || authenticated.LastUsed == nil {
+		t.Fatalf("Expected the token to authenticate, got %+v", authenticated)
+	}
+	if _, ok := service.Authenticate(token + "x"); ok {
+		t.Fatal("Expected a wrong token to be rejected")
+	}
+
+	// Subscriptions belong to the user who created them
+	if len(service.GetSubscriptions(2)) != 0 || service.RevokeSubscription(2, subscription.ID) == nil {
+		t.Fatal("Expected another user not to see or revoke the subscription")
+	}
+	if err := service.RevokeSubscription(1, subscription.ID); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := newExportService().Authenticate(token); ok {
+		t.Fatal("Expected a revoked token to be rejected")
+	}
+}
+
+func TestCalendarExportWrite(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Skipf("Time zone data not available: %v", err)
+	}
+	service := NewCalendarExportService(nil, &models.CalendarExportConfig{Name: "Wood family", Location: chicago})
+	service.now = func() time.Time { return time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC) }
+
+	start, end := service.Range()
+	if !start.Equal(time.Date(2024, 5, 16, 0, 0, 0, 0, chicago)) || !end.Equal(time.Date(2025, 6, 15, 0, 0, 0, 0, chicago)) {
+		t.Fatalf("Unexpected range %v to %v", start, end)
+	}
+
+	events := []CalendarEvent{
+		{ID: "swim", Title: "[Family] Swimming", Start: "2024-06-20T17:00:00Z", End: "2024-06-20T18:00:00Z", CalendarID: "family"},
+		{ID: "trip", Title: "[Family] Camping", Start: "2024-06-21", End: "2024-06-23", AllDay: true, CalendarID: "family"},
+		{ID: "broken", Title: "Broken", Start: "soon"},
+	}
+	var buf bytes.Buffer
+	if err := service.Write(&buf, events); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "DTSTART;TZID=America/Chicago:20240620T120000\r\n") || !strings.Contains(out, "BEGIN:VTIMEZONE\r\n") {
+		t.Fatalf("Expected the timed event in the home zone:\n%s", out)
+	}
+
+	parsed, err := ical.Parse(strings.NewReader(out), chicago)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if parsed.Name != "Wood family" || len(parsed.Events) != 2 {
+		t.Fatalf("Unexpected calendar: %+v", parsed)
+	}
+	if parsed.Events[0].UID != "swim/family@woodhome" || !parsed.Events[0].Start.Equal(time.Date(2024, 6, 20, 17, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Unexpected timed event: %+v", parsed.Events[0])
+	}
+	if trip := parsed.Events[1]; !trip.AllDay || trip.Summary != "[Family] Camping" || trip.End.Sub(trip.Start) != 48*time.Hour {
+		t.Fatalf("Unexpected all-day event: %+v", trip)
+	}
+}
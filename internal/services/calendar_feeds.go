@@ -0,0 +1,606 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"woodhome-webapp/internal/color"
+	"woodhome-webapp/internal/ical"
+	"woodhome-webapp/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// CalendarFeedPrefix marks the calendar IDs of ICS feeds, so they can be told apart from Google calendars
+const CalendarFeedPrefix = "ics:"
+
+// defaultFeedColor is used for a feed added without a color
+const defaultFeedColor = "#8e63ce"
+
+// calendarFeedState is a feed with its parsed calendar
+type calendarFeedState struct {
+	feed     models.CalendarFeed
+	calendar *ical.Calendar
+}
+
+// CalendarFeedService subscribes to iCalendar feeds published as .ics links, such as school,
+// sports league and trash pickup calendars. Feeds are fetched on a schedule with conditional
+// requests and their events are served alongside the Google events.
+type CalendarFeedService struct {
+	config     *models.CalendarFeedConfig
+	db         *sql.DB
+	feeds      map[string]*calendarFeedState
+	httpClient *http.Client
+	now        func() time.Time
+	fetchMu    sync.Mutex // One fetch at a time
+	mu         sync.RWMutex
+}
+
+// NewCalendarFeedService creates a new CalendarFeedService instance.
+// Feeds are kept in memory only when db is nil.
+func NewCalendarFeedService(db *sql.DB, config *models.CalendarFeedConfig) *CalendarFeedService {
+	if config == nil {
+		config = &models.CalendarFeedConfig{}
+	}
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = time.Hour
+	}
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = time.Minute
+	}
+	if config.FetchTimeout <= 0 {
+		config.FetchTimeout = 30 * time.Second
+	}
+	if config.MaxSize <= 0 {
+		config.MaxSize = 10 << 20
+	}
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+
+	return &CalendarFeedService{
+		config:     config,
+		db:         db,
+		feeds:      make(map[string]*calendarFeedState),
+		httpClient: &http.Client{Timeout: config.FetchTimeout},
+		now:        time.Now,
+	}
+}
+
+// Start loads the saved feeds and fetches each one when it is due
+func (s *CalendarFeedService) Start(ctx context.Context) error {
+	logrus.Info("Starting calendar feed service...")
+
+	if err := s.load(); err != nil {
+		return fmt.Errorf("failed to load calendar feeds: %w", err)
+	}
+
+	go s.startLoop(ctx)
+	return nil
+}
+
+// startLoop fetches due feeds right away and then on every check interval
+func (s *CalendarFeedService) startLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		s.refreshDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshDue fetches every enabled feed whose refresh interval has passed
+func (s *CalendarFeedService) refreshDue(ctx context.Context) {
+	now := s.now()
+
+	s.mu.RLock()
+	var due []string
+	for id, state := range s.feeds {
+		if !state.feed.Enabled {
+			continue
+		}
+		interval := s.config.RefreshInterval
+		if state.feed.RefreshMinutes > 0 {
+			interval = time.Duration(state.feed.RefreshMinutes) * time.Minute
+		}
+		if state.feed.LastFetch == nil || now.Sub(*state.feed.LastFetch) >= interval {
+			due = append(due, id)
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, id := range due {
+		if _, err := s.Refresh(ctx, id); err != nil {
+			logrus.Warnf("Calendar feeds: %v", err)
+		}
+	}
+}
+
+// GetFeeds returns every feed ordered by name
+func (s *CalendarFeedService) GetFeeds() []*models.CalendarFeed {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	feeds := make([]*models.CalendarFeed, 0, len(s.feeds))
+	for _, state := range s.feeds {
+		feed := state.feed
+		feeds = append(feeds, &feed)
+	}
+	sort.Slice(feeds, func(i, j int) bool {
+		return strings.ToLower(feeds[i].Name) < strings.ToLower(feeds[j].Name)
+	})
+	return feeds
+}
+
+// GetFeed returns a single feed
+func (s *CalendarFeedService) GetFeed(id string) (*models.CalendarFeed, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state, exists := s.feeds[id]
+	if !exists {
+		return nil, false
+	}
+	feed := state.feed
+	return &feed, true
+}
+
+// CreateFeed validates and saves a new feed. It is fetched on the next check.
+func (s *CalendarFeedService) CreateFeed(feed models.CalendarFeed) (*models.CalendarFeed, error) {
+	if err := validateCalendarFeed(&feed); err != nil {
+		return nil, err
+	}
+	feed = models.CalendarFeed{
+		ID:             uuid.NewString(),
+		Name:           feed.Name,
+		URL:            feed.URL,
+		Color:          feed.Color,
+		Enabled:        feed.Enabled,
+		RefreshMinutes: feed.RefreshMinutes,
+		UpdatedAt:      s.now(),
+	}
+
+	if err := s.save(feed); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.feeds[feed.ID] = &calendarFeedState{feed: feed}
+	s.mu.Unlock()
+
+	logrus.Infof("Calendar feeds: added %q", feed.URL)
+	return &feed, nil
+}
+
+// UpdateFeed replaces the settings of a feed. A new URL is fetched from scratch.
+func (s *CalendarFeedService) UpdateFeed(id string, update models.CalendarFeed) (*models.CalendarFeed, error) {
+	if err := validateCalendarFeed(&update); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	state, exists := s.feeds[id]
+	if !exists {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("feed %s not found", id)
+	}
+	feed := state.feed
+	urlChanged := feed.URL != update.URL
+	feed.Name = update.Name
+	feed.URL = update.URL
+	feed.Color = update.Color
+	feed.Enabled = update.Enabled
+	feed.RefreshMinutes = update.RefreshMinutes
+	feed.UpdatedAt = s.now()
+	if urlChanged {
+		feed.ETag = ""
+		feed.LastModified = ""
+		feed.LastFetch = nil
+		feed.LastChange = nil
+		feed.LastError = ""
+		feed.Events = 0
+	}
+	s.mu.Unlock()
+
+	if err := s.save(feed); err != nil {
+		return nil, err
+	}
+	if urlChanged {
+		if err := s.saveBody(id, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state.feed = feed
+	if urlChanged {
+		state.calendar = nil
+	}
+	return &feed, nil
+}
+
+// DeleteFeed removes a feed and its stored copy
+func (s *CalendarFeedService) DeleteFeed(id string) error {
+	s.mu.Lock()
+	if _, exists := s.feeds[id]; !exists {
+		s.mu.Unlock()
+		return fmt.Errorf("feed %s not found", id)
+	}
+	delete(s.feeds, id)
+	s.mu.Unlock()
+
+	if s.db != nil {
+		if _, err := s.db.Exec(`DELETE FROM calendar_feeds WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete feed: %w", err)
+		}
+		if _, err := s.db.Exec(`DELETE FROM calendar_feed_bodies WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete feed: %w", err)
+		}
+	}
+	return nil
+}
+
+// Refresh fetches a feed, asking the server to skip the download when nothing has changed.
+// A feed that fails to fetch or parse keeps serving its last good copy.
+func (s *CalendarFeedService) Refresh(ctx context.Context, id string) (*models.CalendarFeed, error) {
+	s.fetchMu.Lock()
+	defer s.fetchMu.Unlock()
+
+	feed, exists := s.GetFeed(id)
+	if !exists {
+		return nil, fmt.Errorf("feed %s not found", id)
+	}
+
+	body, notModified, etag, lastModified, err := s.fetch(ctx, *feed)
+	var parsed *ical.Calendar
+	if err == nil && !notModified {
+		parsed, err = ical.Parse(bytes.NewReader(body), s.config.Location)
+	}
+
+	// The feed may have been edited or removed during the download
+	s.mu.Lock()
+	state, exists := s.feeds[id]
+	if !exists || state.feed.URL != feed.URL {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("feed %s changed while it was fetched", id)
+	}
+	now := s.now()
+	current := state.feed
+	current.LastFetch = &now
+	if err != nil {
+		current.LastError = err.Error()
+	} else {
+		current.LastError = ""
+		if !notModified {
+			current.ETag = etag
+			current.LastModified = lastModified
+			current.LastChange = &now
+			current.Events = len(parsed.Events)
+			if current.Name == "" {
+				current.Name = parsed.Name
+			}
+		}
+	}
+	state.feed = current
+	if parsed != nil {
+		state.calendar = parsed
+	}
+	s.mu.Unlock()
+	feed = &current
+
+	if saveErr := s.save(current); saveErr != nil {
+		return nil, saveErr
+	}
+	if parsed != nil {
+		if saveErr := s.saveBody(id, body); saveErr != nil {
+			return nil, saveErr
+		}
+	}
+
+	if err != nil {
+		return feed, fmt.Errorf("failed to refresh %s: %w", feed.URL, err)
+	}
+	if !notModified {
+		logrus.Infof("Calendar feeds: %s has %d events", feed.Name, feed.Events)
+	}
+	return feed, nil
+}
+
+// fetch downloads a feed with If-None-Match and If-Modified-Since from the last download
+func (s *CalendarFeedService) fetch(ctx context.Context, feed models.CalendarFeed) (body []byte, notModified bool, etag, lastModified string, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fetchURL(feed.URL), nil)
+	if err != nil {
+		return nil, false, "", "", err
+	}
+	req.Header.Set("Accept", "text/calendar, */*;q=0.5")
+	s.mu.RLock()
+	hasCopy := s.feeds[feed.ID] != nil && s.feeds[feed.ID].calendar != nil
+	s.mu.RUnlock()
+	if hasCopy {
+		// Only ask for changes when the last copy can still be served
+		if feed.ETag != "" {
+			req.Header.Set("If-None-Match", feed.ETag)
+		}
+		if feed.LastModified != "" {
+			req.Header.Set("If-Modified-Since", feed.LastModified)
+		}
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, false, "", "", err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return nil, true, "", "", nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, false, "", "", fmt.Errorf("feed returned %s", resp.Status)
+	}
+
+	body, err = io.ReadAll(io.LimitReader(resp.Body, s.config.MaxSize+1))
+	if err != nil {
+		return nil, false, "", "", fmt.Errorf("failed to read feed: %w", err)
+	}
+	if int64(len(body)) > s.config.MaxSize {
+		return nil, false, "", "", fmt.Errorf("feed is larger than %d bytes", s.config.MaxSize)
+	}
+	return body, false, resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"), nil
+}
+
+// GetCalendars lists the enabled feeds as calendars
+func (s *CalendarFeedService) GetCalendars() []CalendarInfo {
+	calendars := []CalendarInfo{}
+	for _, feed := range s.GetFeeds() {
+		if feed.Enabled {
+			calendars = append(calendars, CalendarInfo{ID: CalendarFeedPrefix + feed.ID, Name: feed.Name, Color: feed.Color})
+		}
+	}
+	return calendars
+}
+
+// GetEvents returns the occurrences of the enabled feeds overlapping start to end, in the
+// same form as Google events. When calendarIDs is not empty only those feeds are included.
+func (s *CalendarFeedService) GetEvents(start, end time.Time, calendarIDs []string) []CalendarEvent {
+	selected := make(map[string]bool)
+	for _, id := range calendarIDs {
+		selected[strings.TrimPrefix(id, CalendarFeedPrefix)] = true
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type occurrence struct {
+		start time.Time
+		event CalendarEvent
+	}
+	var occurrences []occurrence
+	for id, state := range s.feeds {
+		if !state.feed.Enabled || state.calendar == nil || (len(selected) > 0 && !selected[id]) {
+			continue
+		}
+		for _, instance := range state.calendar.Expand(start, end) {
+			occurrences = append(occurrences, occurrence{start: instance.Start, event: s.toCalendarEvent(state.feed, instance)})
+		}
+	}
+
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].start.Before(occurrences[j].start)
+	})
+	events := make([]CalendarEvent, 0, len(occurrences))
+	for _, o := range occurrences {
+		events = append(events, o.event)
+	}
+	return events
+}
+
+// toCalendarEvent converts a feed occurrence the way Google events are converted
+func (s *CalendarFeedService) toCalendarEvent(feed models.CalendarFeed, instance ical.Instance) CalendarEvent {
+	event := CalendarEvent{
+		ID:            fmt.Sprintf("%s%s:%s:%d", CalendarFeedPrefix, feed.ID, instance.Event.UID, instance.Start.Unix()),
+		Title:         instance.Event.Summary,
+		Description:   instance.Event.Description,
+		Color:         feed.Color,
+		AllDay:        instance.AllDay,
+		CalendarID:    CalendarFeedPrefix + feed.ID,
+		CalendarColor: feed.Color,
+	}
+	if feed.Name != "" {
+		event.Title = "[" + feed.Name + "] " + event.Title
+	}
+	if instance.AllDay {
+		event.Start = instance.Start.Format("2006-01-02")
+		event.End = instance.End.Format("2006-01-02")
+	} else {
+		event.Start = instance.Start.In(s.config.Location).Format(time.RFC3339)
+		event.End = instance.End.In(s.config.Location).Format(time.RFC3339)
+	}
+	return event
+}
+
+// SplitCalendarFeedIDs separates the feed calendar IDs from the Google calendar IDs
+func SplitCalendarFeedIDs(calendarIDs []string) (googleIDs, feedIDs []string) {
+	for _, id := range calendarIDs {
+		if strings.HasPrefix(id, CalendarFeedPrefix) {
+			feedIDs = append(feedIDs, id)
+		} else {
+			googleIDs = append(googleIDs, id)
+		}
+	}
+	return googleIDs, feedIDs
+}
+
+// fetchURL turns a webcal:// link, as calendar sites publish them, into the https:// one it stands for
+func fetchURL(feedURL string) string {
+	if strings.HasPrefix(strings.ToLower(feedURL), "webcal://") {
+		return "https://" + feedURL[len("webcal://"):]
+	}
+	return feedURL
+}
+
+// validateCalendarFeed checks a feed and normalizes its color
+func validateCalendarFeed(feed *models.CalendarFeed) error {
+	feed.URL = strings.TrimSpace(feed.URL)
+	if feed.URL == "" {
+		return fmt.Errorf("feed url is required")
+	}
+	parsed, err := url.Parse(fetchURL(feed.URL))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("feed url must be an http, https or webcal link")
+	}
+	if feed.RefreshMinutes < 0 {
+		return fmt.Errorf("refresh_minutes cannot be negative")
+	}
+
+	if feed.Color == "" {
+		feed.Color = defaultFeedColor
+	}
+	rgb, err := color.ParseHex(feed.Color)
+	if err != nil {
+		return fmt.Errorf("invalid feed color: %w", err)
+	}
+	feed.Color = rgb.Hex()
+	feed.Name = strings.TrimSpace(feed.Name)
+	return nil
+}
+
+// load reads the saved feeds and their last downloaded copies
+func (s *CalendarFeedService) load() error {
+	if s.db == nil {
+		return nil
+	}
+
+	for _, statement := range []string{`
+		CREATE TABLE IF NOT EXISTS calendar_feeds (
+			id TEXT PRIMARY KEY,
+			data TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`, `
+		CREATE TABLE IF NOT EXISTS calendar_feed_bodies (
+			id TEXT PRIMARY KEY,
+			body BLOB NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+	} {
+		if _, err := s.db.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	feeds := make(map[string]*calendarFeedState)
+	rows, err := s.db.Query(`SELECT id, data FROM calendar_feeds`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return err
+		}
+		var feed models.CalendarFeed
+		if err := json.Unmarshal([]byte(data), &feed); err != nil {
+			logrus.Warnf("Calendar feeds: ignoring unreadable feed %s: %v", id, err)
+			continue
+		}
+		feed.ID = id
+		feeds[id] = &calendarFeedState{feed: feed}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = s.db.Query(`SELECT id, body FROM calendar_feed_bodies`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var body []byte
+		if err := rows.Scan(&id, &body); err != nil {
+			return err
+		}
+		state, exists := feeds[id]
+		if !exists {
+			continue
+		}
+		parsed, err := ical.Parse(bytes.NewReader(body), s.config.Location)
+		if err != nil {
+			logrus.Warnf("Calendar feeds: ignoring unreadable copy of %s: %v", state.feed.Name, err)
+			continue
+		}
+		state.calendar = parsed
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.feeds = feeds
+	logrus.Infof("Calendar feeds: loaded %d feeds", len(feeds))
+	return nil
+}
+
+// save stores a feed
+func (s *CalendarFeedService) save(feed models.CalendarFeed) error {
+	if s.db == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(feed)
+	if err != nil {
+		return fmt.Errorf("failed to marshal feed: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+		INSERT OR REPLACE INTO calendar_feeds (id, data, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+	`, feed.ID, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save feed: %w", err)
+	}
+	return nil
+}
+
+// saveBody stores the last downloaded copy of a feed, or removes it when body is nil
+func (s *CalendarFeedService) saveBody(id string, body []byte) error {
+	if s.db == nil {
+		return nil
+	}
+
+	var err error
+	if body == nil {
+		_, err = s.db.Exec(`DELETE FROM calendar_feed_bodies WHERE id = ?`, id)
+	} else {
+		_, err = s.db.Exec(`
+			INSERT OR REPLACE INTO calendar_feed_bodies (id, body, updated_at)
+			VALUES (?, ?, CURRENT_TIMESTAMP)
+		`, id, body)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save feed copy: %w", err)
+	}
+	return nil
+}
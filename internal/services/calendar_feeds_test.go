@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"woodhome-webapp/internal/database"
+	"woodhome-webapp/internal/models"
+)
+
+const trashFeed = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"X-WR-CALNAME:Trash pickup\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:recycling@city\r\n" +
+	"SUMMARY:Recycling\r\n" +
+	"DTSTART;VALUE=DATE:20240604\r\n" +
+	"RRULE:FREQ=WEEKLY;INTERVAL=2\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:yard@city\r\n" +
+	"SUMMARY:Yard waste\r\n" +
+	"DTSTART;TZID=America/Chicago:20240620T070000\r\n" +
+	"DURATION:PT1H\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestCalendarFeedRefresh(t *testing.T) {
+	var requests, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(trashFeed))
+	}))
+	defer server.Close()
+
+	db, err := database.OpenSQLite(filepath.Join(t.TempDir(), "home.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Skipf("Time zone data not available: %v", err)
+	}
+	newFeedService := func() *CalendarFeedService {
+		service := NewCalendarFeedService(db, &models.CalendarFeedConfig{Location: chicago})
+		service.httpClient = server.Client()
+		if err := service.load(); err != nil {
+			t.Fatalf("Failed to load feeds: %v", err)
+		}
+		return service
+	}
+	service := newFeedService()
+	ctx := context.Background()
+
+	if _, err := service.CreateFeed(models.CalendarFeed{URL: "ftp://example.com/feed.ics"}); err == nil {
+		t.Fatal("Expected a non HTTP URL to be rejected")
+	}
+	feed, err := service.CreateFeed(models.CalendarFeed{URL: server.URL + "/trash.ics", Enabled: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if feed.Color != defaultFeedColor {
+		t.Fatalf("Expected the default color, got %q", feed.Color)
+	}
+
+	refreshed, err := service.Refresh(ctx, feed.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if refreshed.Name != "Trash pickup" || refreshed.Events != 2 || refreshed.ETag != `"v1"` {
+		t.Fatalf("Unexpected feed after the first fetch: %+v", refreshed)
+	}
+
+	// An unchanged feed is not downloaded again
+	if _, err := service.Refresh(ctx, feed.ID); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if requests != 2 || notModified != 1 {
+		t.Fatalf("Expected a conditional second request, got %d requests and %d not modified", requests, notModified)
+	}
+
+	june := func(service *CalendarFeedService, ids []string) []CalendarEvent {
+		return service.GetEvents(time.Date(2024, 6, 1, 0, 0, 0, 0, chicago), time.Date(2024, 7, 1, 0, 0, 0, 0, chicago), ids)
+	}
+	events := june(service, nil)
+	var got []string
+	for _, event := range events {
+		got = append(got, event.Start+" "+event.Title)
+	}
+	want := []string{
+		"2024-06-04 [Trash pickup] Recycling",
+		"2024-06-18 [Trash pickup] Recycling",
+		"2024-06-20T07:00:00-05:00 [Trash pickup] Yard waste",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Unexpected events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if events[0].CalendarID != CalendarFeedPrefix+feed.ID || !events[0].AllDay || events[0].End != "2024-06-05" {
+		t.Fatalf("Unexpected all-day event: %+v", events[0])
+	}
+
+	// Filtering by calendar only keeps the selected feeds
+	if len(june(service, []string{CalendarFeedPrefix + "other"})) != 0 {
+		t.Fatal("Expected no events for another feed")
+	}
+	googleIDs, feedIDs := SplitCalendarFeedIDs([]string{"primary", CalendarFeedPrefix + feed.ID})
+	if len(googleIDs) != 1 || len(feedIDs) != 1 || len(june(service, feedIDs)) != 3 {
+		t.Fatalf("Unexpected split of calendar IDs: %v and %v", googleIDs, feedIDs)
+	}
+
+	// The parsed copy survives a restart, so the next fetch can still be conditional
+	service = newFeedService()
+	if len(june(service, nil)) != 3 {
+		t.Fatalf("Expected the stored events after a restart, got %+v", june(service, nil))
+	}
+	if _, err := service.Refresh(ctx, feed.ID); err != nil || notModified != 2 {
+		t.Fatalf("Expected a conditional request after the restart, got %d not modified and %v", notModified, err)
+	}
+
+	// A disabled feed keeps its copy but is not served
+	if _, err := service.UpdateFeed(feed.ID, models.CalendarFeed{Name: "Trash", URL: feed.URL, Enabled: false}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(june(service, nil)) != 0 || len(service.GetCalendars()) != 0 {
+		t.Fatal("Expected a disabled feed to be left out")
+	}
+
+	if err := service.DeleteFeed(feed.ID); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(newFeedService().GetFeeds()) != 0 {
+		t.Fatal("Expected the feed to be deleted from the database")
+	}
+}
+
+func TestCalendarFeedFetchURL(t *testing.T) {
+	if got := fetchURL("webcal://example.com/school.ics"); got != "https://example.com/school.ics" {
+		t.Fatalf("Unexpected URL %q", got)
+	}
+	if got := fetchURL("https://example.com/school.ics"); got != "https://example.com/school.ics" {
+		t.Fatalf("Unexpected URL %q", got)
+	}
+}
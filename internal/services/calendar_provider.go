@@ -0,0 +1,317 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+)
+
+var (
+	// ErrSyncTokenExpired is returned by CalendarProvider.Changes when the provider no longer
+	// accepts a sync token and the calendar has to be listed in full
+	ErrSyncTokenExpired = errors.New("sync token is no longer valid")
+	// ErrEventConflict is returned when an event was changed since its ETag was read
+	ErrEventConflict = errors.New("event was changed by someone else")
+	// ErrEventReadOnly is returned for events that cannot be changed through WoodHome
+	ErrEventReadOnly = errors.New("event cannot be changed here")
+)
+
+// CalendarProvider is a calendar backend, such as Google Calendar or a CalDAV server like
+// iCloud or Nextcloud
+type CalendarProvider interface {
+	// ListCalendars lists the calendars of the account
+	ListCalendars(ctx context.Context) ([]ProviderCalendar, error)
+	// ListEvents lists the occurrences of a calendar's events overlapping start to end
+	ListEvents(ctx context.Context, calendarID string, start, end time.Time) ([]ProviderEvent, error)
+	// Changes lists what changed in a calendar since syncToken, or every event when syncToken
+	// is empty. Recurring events are expanded from start to end.
+	Changes(ctx context.Context, calendarID, syncToken string, start, end time.Time) (*ProviderChanges, error)
+	// CreateEvent adds an event to a calendar
+	CreateEvent(ctx context.Context, calendarID string, event ProviderEvent) (*ProviderEvent, error)
+	// UpdateEvent replaces an event, failing with ErrEventConflict when its ETag is stale
+	UpdateEvent(ctx context.Context, calendarID string, event ProviderEvent) (*ProviderEvent, error)
+	// DeleteEvent removes an event, failing with ErrEventConflict when its ETag is stale
+	DeleteEvent(ctx context.Context, calendarID string, event ProviderEvent) error
+}
+
+// ProviderCalendar is a calendar of a provider account
+type ProviderCalendar struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Color    string `json:"color"`
+	ReadOnly bool   `json:"read_only,omitempty"`
+}
+
+// ProviderEvent is one occurrence of an event. Resource names what the provider stores it
+// as: a recurring CalDAV event is one resource with many occurrences, while Google lists
+// every occurrence on its own.
+type ProviderEvent struct {
+	ID          string    `json:"id"`
+	Resource    string    `json:"resource"`
+	ETag        string    `json:"etag,omitempty"`
+	Summary     string    `json:"summary"`
+	Description string    `json:"description,omitempty"`
+	Location    string    `json:"location,omitempty"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	AllDay      bool      `json:"all_day,omitempty"`
+	Recurring   bool      `json:"recurring,omitempty"`
+}
+
+// ProviderChanges is the result of CalendarProvider.Changes. Events replace every stored
+// occurrence of their resources.
+type ProviderChanges struct {
+	Events    []ProviderEvent
+	Deleted   []string // Resources removed since the sync token
+	SyncToken string   // For the next call, empty when the provider has none
+	Full      bool     // Events are the whole calendar and replace what is stored
+}
+
+// GoogleCalendarProvider is the CalendarProvider for a Google account
+type GoogleCalendarProvider struct {
+	calendarService *CalendarService
+	token           *oauth2.Token
+	newService      func(ctx context.Context) (*calendar.Service, error)
+}
+
+// NewGoogleCalendarProvider creates a provider for the account a token belongs to. The token
+// is refreshed in place, so callers can save it afterwards.
+func NewGoogleCalendarProvider(calendarService *CalendarService, token *oauth2.Token) *GoogleCalendarProvider {
+	p := &GoogleCalendarProvider{
+		calendarService: calendarService,
+		token:           token,
+	}
+	p.newService = func(ctx context.Context) (*calendar.Service, error) {
+		return p.calendarService.newGoogleService(ctx, p.token)
+	}
+	return p
+}
+
+// ListCalendars lists the selected, visible calendars as the dashboard shows them
+func (p *GoogleCalendarProvider) ListCalendars(ctx context.Context) ([]ProviderCalendar, error) {
+	srv, err := p.newService(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := listCalendars(ctx, srv)
+	if err != nil {
+		return nil, err
+	}
+
+	var calendars []ProviderCalendar
+	for _, entry := range entries {
+		if !entry.Selected || entry.Hidden {
+			continue
+		}
+		calendars = append(calendars, ProviderCalendar{
+			ID:       entry.Id,
+			Name:     entry.Summary,
+			Color:    p.calendarService.calendarEntryColor(entry),
+			ReadOnly: entry.AccessRole != "owner" && entry.AccessRole != "writer",
+		})
+	}
+	return calendars, nil
+}
+
+// ListEvents lists the occurrences overlapping start to end
+func (p *GoogleCalendarProvider) ListEvents(ctx context.Context, calendarID string, start, end time.Time) ([]ProviderEvent, error) {
+	srv, err := p.newService(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []ProviderEvent
+	pageToken := ""
+	for {
+		call := srv.Events.List(calendarID).
+			SingleEvents(true).
+			TimeMin(start.Format(time.RFC3339)).
+			TimeMax(end.Format(time.RFC3339)).
+			MaxResults(calendarSyncPageSize).
+			Context(ctx)
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+		page, err := call.Do()
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page.Items {
+			if event, ok := fromGoogleEvent(item); ok {
+				events = append(events, event)
+			}
+		}
+		if page.NextPageToken == "" {
+			return events, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// Changes uses Google's sync tokens. A full listing starts at start, as Google expands
+// recurring events itself.
+func (p *GoogleCalendarProvider) Changes(ctx context.Context, calendarID, syncToken string, start, end time.Time) (*ProviderChanges, error) {
+	srv, err := p.newService(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := &ProviderChanges{Full: syncToken == ""}
+	pageToken := ""
+	for {
+		call := srv.Events.List(calendarID).
+			SingleEvents(true).
+			MaxResults(calendarSyncPageSize).
+			Context(ctx)
+		if syncToken != "" {
+			call = call.SyncToken(syncToken)
+		} else {
+			call = call.TimeMin(start.Format(time.RFC3339))
+		}
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+
+		page, err := call.Do()
+		if isSyncTokenExpired(err) {
+			return nil, ErrSyncTokenExpired
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page.Items {
+			if item.Status == "cancelled" {
+				changes.Deleted = append(changes.Deleted, item.Id)
+				continue
+			}
+			if event, ok := fromGoogleEvent(item); ok {
+				changes.Events = append(changes.Events, event)
+			}
+		}
+
+		if page.NextPageToken == "" {
+			changes.SyncToken = page.NextSyncToken
+			return changes, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// CreateEvent inserts an event
+func (p *GoogleCalendarProvider) CreateEvent(ctx context.Context, calendarID string, event ProviderEvent) (*ProviderEvent, error) {
+	srv, err := p.newService(ctx)
+	if err != nil {
+		return nil, err
+	}
+	created, err := srv.Events.Insert(calendarID, toGoogleEvent(event)).Context(ctx).Do()
+	if err != nil {
+		return nil, googleEventError(err)
+	}
+	result, _ := fromGoogleEvent(created)
+	return &result, nil
+}
+
+// UpdateEvent patches an event or a single occurrence, sending its ETag as If-Match
+func (p *GoogleCalendarProvider) UpdateEvent(ctx context.Context, calendarID string, event ProviderEvent) (*ProviderEvent, error) {
+	srv, err := p.newService(ctx)
+	if err != nil {
+		return nil, err
+	}
+	call := srv.Events.Patch(calendarID, event.ID, toGoogleEvent(event)).Context(ctx)
+	if event.ETag != "" {
+		call.Header().Set("If-Match", event.ETag)
+	}
+	updated, err := call.Do()
+	if err != nil {
+		return nil, googleEventError(err)
+	}
+	result, _ := fromGoogleEvent(updated)
+	return &result, nil
+}
+
+// DeleteEvent deletes an event or a single occurrence, sending its ETag as If-Match
+func (p *GoogleCalendarProvider) DeleteEvent(ctx context.Context, calendarID string, event ProviderEvent) error {
+	srv, err := p.newService(ctx)
+	if err != nil {
+		return err
+	}
+	call := srv.Events.Delete(calendarID, event.ID).Context(ctx)
+	if event.ETag != "" {
+		call.Header().Set("If-Match", event.ETag)
+	}
+	if err := call.Do(); err != nil {
+		return googleEventError(err)
+	}
+	return nil
+}
+
+// fromGoogleEvent converts a Google event. Every occurrence is a resource of its own.
+func fromGoogleEvent(item *calendar.Event) (ProviderEvent, bool) {
+	if item.Start == nil {
+		return ProviderEvent{}, false
+	}
+	event := ProviderEvent{
+		ID:          item.Id,
+		Resource:    item.Id,
+		ETag:        item.Etag,
+		Summary:     item.Summary,
+		Description: item.Description,
+		Location:    item.Location,
+		Recurring:   item.RecurringEventId != "",
+	}
+
+	parse := func(value *calendar.EventDateTime) (time.Time, error) {
+		if value.DateTime != "" {
+			return time.Parse(time.RFC3339, value.DateTime)
+		}
+		event.AllDay = true
+		return time.Parse("2006-01-02", value.Date)
+	}
+	start, err := parse(item.Start)
+	if err != nil {
+		return ProviderEvent{}, false
+	}
+	event.Start, event.End = start, start
+	if item.End != nil {
+		if end, err := parse(item.End); err == nil {
+			event.End = end
+		}
+	}
+	return event, true
+}
+
+// toGoogleEvent converts an event for Insert and Patch
+func toGoogleEvent(event ProviderEvent) *calendar.Event {
+	item := &calendar.Event{
+		Summary:     event.Summary,
+		Description: event.Description,
+		Location:    event.Location,
+	}
+	if event.AllDay {
+		item.Start = &calendar.EventDateTime{Date: event.Start.Format("2006-01-02")}
+		item.End = &calendar.EventDateTime{Date: event.End.Format("2006-01-02")}
+	} else {
+		item.Start = &calendar.EventDateTime{DateTime: event.Start.Format(time.RFC3339)}
+		item.End = &calendar.EventDateTime{DateTime: event.End.Format(time.RFC3339)}
+	}
+	return item
+}
+
+// googleEventError turns a failed If-Match into ErrEventConflict, and a refused write into
+// ErrEventReadOnly, as tokens granted only the calendar.readonly scope cannot change events
+func googleEventError(err error) error {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden {
+		return fmt.Errorf("%w: %v", ErrEventReadOnly, err)
+	}
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed {
+		return fmt.Errorf("%w: %v", ErrEventConflict, err)
+	}
+	return err
+}
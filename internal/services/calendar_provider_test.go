@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
+)
+
+func TestGoogleCalendarProviderChanges(t *testing.T) {
+	api := &fakeCalendarAPI{full: []*calendar.Event{
+		{Id: "swim", Etag: `"1"`, Summary: "Swimming", Start: &calendar.EventDateTime{DateTime: "2024-06-20T17:00:00Z"}, End: &calendar.EventDateTime{DateTime: "2024-06-20T18:00:00Z"}},
+		{Id: "trip", Summary: "Camping", Start: &calendar.EventDateTime{Date: "2024-06-21"}, End: &calendar.EventDateTime{Date: "2024-06-23"}},
+	}}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	provider := NewGoogleCalendarProvider(NewCalendarService(nil), nil)
+	provider.newService = func(ctx context.Context) (*calendar.Service, error) {
+		return calendar.NewService(ctx, option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+	}
+	ctx := context.Background()
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)
+
+	calendars, err := provider.ListCalendars(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(calendars) != 1 || calendars[0].ID != "family" || calendars[0].Color != "#16a765" {
+		t.Fatalf("Unexpected calendars: %+v", calendars)
+	}
+
+	changes, err := provider.Changes(ctx, "family", "", start, end)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !changes.Full || len(changes.Events) != 2 || changes.SyncToken != "token-full" || changes.Events[0].ETag != `"1"` || !changes.Events[1].AllDay {
+		t.Fatalf("Unexpected full sync: %+v", changes)
+	}
+
+	api.changes = []*calendar.Event{{Id: "swim", Status: "cancelled"}}
+	changes, err = provider.Changes(ctx, "family", changes.SyncToken, start, end)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if changes.Full || len(changes.Events) != 0 || len(changes.Deleted) != 1 || changes.Deleted[0] != "swim" {
+		t.Fatalf("Unexpected changes: %+v", changes)
+	}
+
+	api.expireToken = true
+	if _, err := provider.Changes(ctx, "family", changes.SyncToken, start, end); !errors.Is(err, ErrSyncTokenExpired) {
+		t.Fatalf("Expected the token to expire, got %v", err)
+	}
+
+	// Tokens granted only the read-only scope cannot change events
+	readOnly := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error":{"code":403,"message":"Request had insufficient authentication scopes."}}`))
+	}))
+	defer readOnly.Close()
+	provider.newService = func(ctx context.Context) (*calendar.Service, error) {
+		return calendar.NewService(ctx, option.WithEndpoint(readOnly.URL+"/"), option.WithHTTPClient(readOnly.Client()))
+	}
+	swim := ProviderEvent{Summary: "Swimming", Start: start, End: start.Add(time.Hour)}
+	if _, err := provider.CreateEvent(ctx, "family", swim); !errors.Is(err, ErrEventReadOnly) {
+		t.Fatalf("Expected the event to be read only, got %v", err)
+	}
+}
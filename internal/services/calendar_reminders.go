@@ -0,0 +1,650 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"woodhome-webapp/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// reminderMaxMinutes is the longest reminder Google allows, four weeks
+const reminderMaxMinutes = 4 * 7 * 24 * 60
+
+// reminderRetention is how long delivered reminders are kept after their event started
+const reminderRetention = 7 * 24 * time.Hour
+
+// ErrReminderRuleNotFound is returned for a calendar without reminder rule
+var ErrReminderRuleNotFound = errors.New("reminder rule not found")
+
+// CalendarEventSource lists the events of the household calendars from start to end. When
+// calendarIDs is not empty only those calendars are included.
+type CalendarEventSource func(ctx context.Context, start, end time.Time, calendarIDs []string) ([]CalendarEvent, error)
+
+// CalendarReminderService delivers calendar reminders to the home, as a toast on the
+// dashboards, an email, a Sonos announcement or a flash of the lights, chosen per calendar.
+// Events are read ahead on every check and each reminder is delivered once when it comes
+// due. Deliveries are saved, so a restart neither repeats a reminder nor drops one that came
+// due while WoodHome was down, as long as that was within the late window.
+type CalendarReminderService struct {
+	config       *models.CalendarReminderConfig
+	db           *sql.DB
+	rules        map[string]*models.CalendarReminderRule // By calendar ID
+	reminders    map[string]*models.CalendarReminder
+	eventSource  CalendarEventSource
+	eventBus     *EventBus
+	emailService *EmailService
+	sonosService *SonosService
+	hueService   *HueService
+	now          func() time.Time
+	checkMu      sync.Mutex // One check at a time
+	mu           sync.RWMutex
+}
+
+// NewCalendarReminderService creates a new CalendarReminderService instance.
+// Rules and deliveries are kept in memory only when db is nil.
+func NewCalendarReminderService(eventSource CalendarEventSource, db *sql.DB, config *models.CalendarReminderConfig) *CalendarReminderService {
+	if config == nil {
+		config = &models.CalendarReminderConfig{}
+	}
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = 30 * time.Second
+	}
+	if config.Lookahead <= 0 {
+		config.Lookahead = 7 * 24 * time.Hour
+	}
+	if config.LateWindow <= 0 {
+		config.LateWindow = 15 * time.Minute
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 3
+	}
+	if len(config.DefaultMinutes) == 0 {
+		config.DefaultMinutes = []int{10}
+	}
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+
+	return &CalendarReminderService{
+		config:      config,
+		db:          db,
+		rules:       make(map[string]*models.CalendarReminderRule),
+		reminders:   make(map[string]*models.CalendarReminder),
+		eventSource: eventSource,
+		now:         time.Now,
+	}
+}
+
+// SetEventBus delivers dashboard reminders as events
+func (s *CalendarReminderService) SetEventBus(eventBus *EventBus) {
+	s.eventBus = eventBus
+}
+
+// SetEmailService delivers email reminders
+func (s *CalendarReminderService) SetEmailService(emailService *EmailService) {
+	s.emailService = emailService
+}
+
+// SetSonosService delivers spoken reminders
+func (s *CalendarReminderService) SetSonosService(sonosService *SonosService) {
+	s.sonosService = sonosService
+}
+
+// SetHueService delivers reminders by flashing the lights
+func (s *CalendarReminderService) SetHueService(hueService *HueService) {
+	s.hueService = hueService
+}
+
+// Start loads the rules and past deliveries and starts checking for due reminders
+func (s *CalendarReminderService) Start(ctx context.Context) error {
+	logrus.Info("Starting calendar reminder service...")
+
+	if err := s.load(); err != nil {
+		return fmt.Errorf("failed to load calendar reminders: %w", err)
+	}
+
+	go s.startLoop(ctx)
+	return nil
+}
+
+// startLoop checks for due reminders right away and then on every check interval
+func (s *CalendarReminderService) startLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		s.check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetRules returns the reminder rule of every calendar
+func (s *CalendarReminderService) GetRules() []*models.CalendarReminderRule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rules := make([]*models.CalendarReminderRule, 0, len(s.rules))
+	for _, rule := range s.rules {
+		copied := *rule
+		rules = append(rules, &copied)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].CalendarID < rules[j].CalendarID
+	})
+	return rules
+}
+
+// GetRule returns the reminder rule of a calendar
+func (s *CalendarReminderService) GetRule(calendarID string) (*models.CalendarReminderRule, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rule, exists := s.rules[calendarID]
+	if !exists {
+		return nil, false
+	}
+	copied := *rule
+	return &copied, true
+}
+
+// SetRule adds or replaces the reminder rule of a calendar. Reminders that came due before
+// the rule was set are not delivered late.
+func (s *CalendarReminderService) SetRule(rule models.CalendarReminderRule) (*models.CalendarReminderRule, error) {
+	if err := validateCalendarReminderRule(&rule); err != nil {
+		return nil, err
+	}
+	rule.UpdatedAt = s.now()
+
+	if err := s.saveRule(rule); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.rules[rule.CalendarID] = &rule
+	s.mu.Unlock()
+
+	logrus.Infof("Calendar reminders: %s reminders of %s", rule.Channel, rule.CalendarID)
+	copied := rule
+	return &copied, nil
+}
+
+// DeleteRule stops reminding of a calendar
+func (s *CalendarReminderService) DeleteRule(calendarID string) error {
+	if _, exists := s.GetRule(calendarID); !exists {
+		return fmt.Errorf("calendar %s: %w", calendarID, ErrReminderRuleNotFound)
+	}
+
+	if s.db != nil {
+		if _, err := s.db.Exec(`DELETE FROM calendar_reminder_rules WHERE calendar_id = ?`, calendarID); err != nil {
+			return fmt.Errorf("failed to delete reminder rule: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	delete(s.rules, calendarID)
+	s.mu.Unlock()
+
+	logrus.Infof("Calendar reminders: removed the rule of %s", calendarID)
+	return nil
+}
+
+// GetReminders returns the reminders delivered, failed or missed, the latest first
+func (s *CalendarReminderService) GetReminders() []*models.CalendarReminder {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reminders := make([]*models.CalendarReminder, 0, len(s.reminders))
+	for _, reminder := range s.reminders {
+		copied := *reminder
+		reminders = append(reminders, &copied)
+	}
+	sort.Slice(reminders, func(i, j int) bool {
+		if !reminders[i].DueAt.Equal(reminders[j].DueAt) {
+			return reminders[i].DueAt.After(reminders[j].DueAt)
+		}
+		return reminders[i].ID < reminders[j].ID
+	})
+	return reminders
+}
+
+// TestRule delivers a sample reminder the way the rule of a calendar would
+func (s *CalendarReminderService) TestRule(ctx context.Context, calendarID string) error {
+	rule, exists := s.GetRule(calendarID)
+	if !exists {
+		return fmt.Errorf("calendar %s: %w", calendarID, ErrReminderRuleNotFound)
+	}
+
+	now := s.now()
+	return s.deliver(ctx, *rule, models.CalendarReminder{
+		ID:         "test",
+		CalendarID: calendarID,
+		Title:      "Test reminder",
+		EventStart: now.Add(10 * time.Minute),
+		Minutes:    10,
+		DueAt:      now,
+		Channel:    rule.Channel,
+	})
+}
+
+// check delivers every reminder that has come due. Each one is saved once delivered, or once
+// it failed too often or came due longer ago than the late window.
+func (s *CalendarReminderService) check(ctx context.Context) {
+	s.checkMu.Lock()
+	defer s.checkMu.Unlock()
+
+	now := s.now()
+	s.prune(now)
+
+	rules := make(map[string]models.CalendarReminderRule)
+	var calendarIDs []string
+	for _, rule := range s.GetRules() {
+		if rule.Enabled {
+			rules[rule.CalendarID] = *rule
+			calendarIDs = append(calendarIDs, rule.CalendarID)
+		}
+	}
+	if len(rules) == 0 || s.eventSource == nil {
+		return
+	}
+
+	events, err := s.eventSource(ctx, now.Add(-s.config.LateWindow), now.Add(s.config.Lookahead), calendarIDs)
+	if err != nil {
+		logrus.Warnf("Calendar reminders: failed to read events: %v", err)
+		return
+	}
+
+	for _, event := range events {
+		rule, exists := rules[event.CalendarID]
+		if !exists {
+			continue
+		}
+		start, _, ok := eventTimeRange(event, s.config.Location)
+		if !ok || start.Before(now.Add(-s.config.LateWindow)) {
+			continue
+		}
+
+		for _, minutes := range s.reminderMinutes(event, rule) {
+			due := start.Add(-time.Duration(minutes) * time.Minute)
+			if due.After(now) {
+				continue
+			}
+			s.remind(ctx, rule, event, start, minutes, due, now)
+		}
+	}
+}
+
+// remind delivers one due reminder unless it was handled before
+func (s *CalendarReminderService) remind(ctx context.Context, rule models.CalendarReminderRule, event CalendarEvent, start time.Time, minutes int, due, now time.Time) {
+	id := event.ID + "|" + strconv.FormatInt(start.Unix(), 10) + "|" + strconv.Itoa(minutes)
+
+	s.mu.RLock()
+	var reminder models.CalendarReminder
+	stored, exists := s.reminders[id]
+	if exists {
+		reminder = *stored
+	}
+	s.mu.RUnlock()
+
+	if exists && (reminder.Status != models.ReminderFailed || reminder.Attempts >= s.config.MaxAttempts) {
+		return
+	}
+	if !exists {
+		reminder = models.CalendarReminder{
+			ID:         id,
+			EventID:    event.ID,
+			CalendarID: event.CalendarID,
+			Title:      event.Title,
+			EventStart: start,
+			AllDay:     event.AllDay,
+			Minutes:    minutes,
+			DueAt:      due,
+		}
+	}
+	reminder.Channel = rule.Channel
+
+	if now.Sub(due) > s.config.LateWindow {
+		// Too late to be useful. Only note it when it should have been delivered.
+		if !exists && due.Before(rule.UpdatedAt) {
+			return
+		}
+		reminder.Status = models.ReminderMissed
+		logrus.Warnf("Calendar reminders: missed the reminder of %q due at %s", reminder.Title, due.Format(time.RFC3339))
+	} else {
+		reminder.Attempts++
+		if err := s.deliver(ctx, rule, reminder); err != nil {
+			reminder.Status = models.ReminderFailed
+			reminder.LastError = err.Error()
+			logrus.Warnf("Calendar reminders: failed to deliver the reminder of %q: %v", reminder.Title, err)
+		} else {
+			delivered := s.now()
+			reminder.Status = models.ReminderDelivered
+			reminder.DeliveredAt = &delivered
+			reminder.LastError = ""
+			logrus.Infof("Calendar reminders: delivered the reminder of %q by %s", reminder.Title, rule.Channel)
+		}
+	}
+
+	if err := s.saveReminder(reminder); err != nil {
+		logrus.Warnf("Calendar reminders: %v", err)
+	}
+	s.mu.Lock()
+	s.reminders[id] = &reminder
+	s.mu.Unlock()
+}
+
+// reminderMinutes returns the minutes before the start of an event its reminders are due.
+// Events without reminders of their own use the defaults of the rule, or of the home.
+func (s *CalendarReminderService) reminderMinutes(event CalendarEvent, rule models.CalendarReminderRule) []int {
+	var minutes []int
+	switch {
+	case event.Reminders != nil && len(event.Reminders.Overrides) > 0:
+		for _, override := range event.Reminders.Overrides {
+			minutes = append(minutes, override.Minutes)
+		}
+	case event.Reminders != nil && !event.Reminders.UseDefault:
+		// Reminders were turned off for the event
+		return nil
+	case len(rule.DefaultMinutes) > 0:
+		minutes = rule.DefaultMinutes
+	default:
+		minutes = s.config.DefaultMinutes
+	}
+
+	seen := make(map[int]bool)
+	var unique []int
+	for _, m := range minutes {
+		if m < 0 || seen[m] {
+			continue
+		}
+		seen[m] = true
+		unique = append(unique, m)
+	}
+	sort.Ints(unique)
+	return unique
+}
+
+// deliver sends a reminder through the channel of its calendar's rule
+func (s *CalendarReminderService) deliver(ctx context.Context, rule models.CalendarReminderRule, reminder models.CalendarReminder) error {
+	message := s.reminderMessage(reminder)
+
+	switch rule.Channel {
+	case models.ReminderChannelDashboard:
+		if s.eventBus == nil {
+			return fmt.Errorf("the event stream is not available")
+		}
+		s.eventBus.Publish(models.EventCalendarReminder, reminder.CalendarID, map[string]interface{}{
+			"id":          reminder.ID,
+			"event_id":    reminder.EventID,
+			"calendar_id": reminder.CalendarID,
+			"title":       reminder.Title,
+			"start":       reminder.EventStart,
+			"all_day":     reminder.AllDay,
+			"minutes":     reminder.Minutes,
+			"message":     message,
+		})
+		return nil
+	case models.ReminderChannelEmail:
+		if !s.emailService.Configured() {
+			return fmt.Errorf("email is not configured")
+		}
+		return s.emailService.SendText(rule.Recipients, "Reminder: "+plainEventTitle(reminder.Title), message+"\n")
+	case models.ReminderChannelSonos:
+		if s.sonosService == nil {
+			return fmt.Errorf("Sonos is not available")
+		}
+		if rule.Target == "" {
+			return s.sonosService.SayAll(ctx, message, rule.Volume)
+		}
+		return s.sonosService.Say(ctx, rule.Target, message, rule.Volume)
+	case models.ReminderChannelHue:
+		if s.hueService == nil {
+			return fmt.Errorf("Hue is not available")
+		}
+		alert := "lselect" // Flash for 15 seconds
+		if rule.Target == "" {
+			return s.hueService.SetAllLightsState(&models.HueGroupState{Alert: &alert})
+		}
+		return s.hueService.SetGroupState(rule.Target, &models.HueGroupState{Alert: &alert})
+	}
+	return fmt.Errorf("unknown channel %q", rule.Channel)
+}
+
+// reminderMessage describes a reminder the way it is shown, spoken or emailed
+func (s *CalendarReminderService) reminderMessage(reminder models.CalendarReminder) string {
+	title := plainEventTitle(reminder.Title)
+	start := reminder.EventStart.In(s.config.Location)
+
+	if reminder.AllDay {
+		today := s.now().In(s.config.Location).Format("2006-01-02")
+		switch start.Format("2006-01-02") {
+		case today:
+			return title + " is today"
+		case s.now().In(s.config.Location).AddDate(0, 0, 1).Format("2006-01-02"):
+			return title + " is tomorrow"
+		}
+		return title + " is on " + start.Format("Monday, January 2")
+	}
+	if reminder.Minutes == 0 {
+		return title + " is starting now"
+	}
+	return fmt.Sprintf("%s starts at %s, in %s", title, start.Format("3:04 PM"), reminderLead(reminder.Minutes))
+}
+
+// reminderTitle drops the calendar name Google events are prefixed with, such as "[Family] "
+func plainEventTitle(title string) string {
+	if strings.HasPrefix(title, "[") {
+		if end := strings.Index(title, "] "); end > 0 {
+			return title[end+2:]
+		}
+	}
+	return title
+}
+
+// reminderLead describes the minutes before an event, such as "30 minutes" or "2 hours"
+func reminderLead(minutes int) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return "1 " + unit
+		}
+		return strconv.Itoa(n) + " " + unit + "s"
+	}
+
+	switch {
+	case minutes%(24*60) == 0:
+		return plural(minutes/(24*60), "day")
+	case minutes%60 == 0:
+		return plural(minutes/60, "hour")
+	}
+	return plural(minutes, "minute")
+}
+
+// validateCalendarReminderRule checks a rule
+func validateCalendarReminderRule(rule *models.CalendarReminderRule) error {
+	rule.CalendarID = strings.TrimSpace(rule.CalendarID)
+	if rule.CalendarID == "" {
+		return fmt.Errorf("calendar_id is required")
+	}
+
+	switch rule.Channel {
+	case models.ReminderChannelDashboard, models.ReminderChannelSonos, models.ReminderChannelHue:
+	case models.ReminderChannelEmail:
+		if len(rule.Recipients) == 0 {
+			return fmt.Errorf("email reminders need recipients")
+		}
+		for _, recipient := range rule.Recipients {
+			if !strings.Contains(recipient, "@") || strings.ContainsAny(recipient, " \r\n") {
+				return fmt.Errorf("invalid recipient %q", recipient)
+			}
+		}
+	default:
+		return fmt.Errorf("channel must be %s, %s, %s or %s", models.ReminderChannelDashboard,
+			models.ReminderChannelEmail, models.ReminderChannelSonos, models.ReminderChannelHue)
+	}
+
+	if rule.Volume < 0 || rule.Volume > 100 {
+		return fmt.Errorf("volume must be between 0 and 100")
+	}
+	for _, minutes := range rule.DefaultMinutes {
+		if minutes < 0 || minutes > reminderMaxMinutes {
+			return fmt.Errorf("default minutes must be between 0 and %d", reminderMaxMinutes)
+		}
+	}
+	return nil
+}
+
+// prune forgets reminders of events that started long ago
+func (s *CalendarReminderService) prune(now time.Time) {
+	s.mu.Lock()
+	var expired []string
+	for id, reminder := range s.reminders {
+		if now.Sub(reminder.EventStart) > reminderRetention {
+			expired = append(expired, id)
+			delete(s.reminders, id)
+		}
+	}
+	s.mu.Unlock()
+
+	if s.db == nil {
+		return
+	}
+	for _, id := range expired {
+		if _, err := s.db.Exec(`DELETE FROM calendar_reminders WHERE id = ?`, id); err != nil {
+			logrus.Warnf("Calendar reminders: failed to delete reminder: %v", err)
+		}
+	}
+}
+
+// load reads the rules and deliveries
+func (s *CalendarReminderService) load() error {
+	if s.db == nil {
+		return nil
+	}
+
+	for _, statement := range []string{`
+		CREATE TABLE IF NOT EXISTS calendar_reminder_rules (
+			calendar_id TEXT PRIMARY KEY,
+			data TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`, `
+		CREATE TABLE IF NOT EXISTS calendar_reminders (
+			id TEXT PRIMARY KEY,
+			data TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+	} {
+		if _, err := s.db.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	rules := make(map[string]*models.CalendarReminderRule)
+	if err := s.loadRows(`SELECT calendar_id, data FROM calendar_reminder_rules`, func(id string, data []byte) error {
+		var rule models.CalendarReminderRule
+		if err := json.Unmarshal(data, &rule); err != nil {
+			return err
+		}
+		rule.CalendarID = id
+		rules[id] = &rule
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	reminders := make(map[string]*models.CalendarReminder)
+	if err := s.loadRows(`SELECT id, data FROM calendar_reminders`, func(id string, data []byte) error {
+		var reminder models.CalendarReminder
+		if err := json.Unmarshal(data, &reminder); err != nil {
+			return err
+		}
+		reminder.ID = id
+		reminders[id] = &reminder
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.rules = rules
+	s.reminders = reminders
+	s.mu.Unlock()
+
+	logrus.Infof("Calendar reminders: loaded %d rules and %d reminders", len(rules), len(reminders))
+	return nil
+}
+
+// loadRows reads the id and data columns of a table, skipping unreadable rows
+func (s *CalendarReminderService) loadRows(query string, read func(id string, data []byte) error) error {
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			return err
+		}
+		if err := read(id, []byte(data)); err != nil {
+			logrus.Warnf("Calendar reminders: ignoring unreadable row %s: %v", id, err)
+		}
+	}
+	return rows.Err()
+}
+
+// saveRule stores a rule
+func (s *CalendarReminderService) saveRule(rule models.CalendarReminderRule) error {
+	if s.db == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(rule)
+	if err != nil {
+		return fmt.Errorf("failed to marshal reminder rule: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+		INSERT OR REPLACE INTO calendar_reminder_rules (calendar_id, data, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+	`, rule.CalendarID, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save reminder rule: %w", err)
+	}
+	return nil
+}
+
+// saveReminder stores a delivery
+func (s *CalendarReminderService) saveReminder(reminder models.CalendarReminder) error {
+	if s.db == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(reminder)
+	if err != nil {
+		return fmt.Errorf("failed to marshal reminder: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+		INSERT OR REPLACE INTO calendar_reminders (id, data, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+	`, reminder.ID, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save reminder: %w", err)
+	}
+	return nil
+}
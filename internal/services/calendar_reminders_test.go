@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/smtp"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"woodhome-webapp/internal/database"
+	"woodhome-webapp/internal/models"
+)
+
+func TestCalendarReminders(t *testing.T) {
+	db, err := database.OpenSQLite(filepath.Join(t.TempDir(), "home.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2024, 10, 25, 16, 0, 0, 0, time.UTC)
+	events := []CalendarEvent{
+		{
+			ID: "soccer", CalendarID: "family", Title: "[Family] Soccer practice",
+			Start: now.Add(10 * time.Minute).Format(time.RFC3339), End: now.Add(time.Hour).Format(time.RFC3339),
+			Reminders: &models.EventReminders{Overrides: []*models.ReminderOverride{{Method: "popup", Minutes: 10}, {Method: "email", Minutes: 60}}},
+		},
+		{
+			ID: "pickup", CalendarID: "school", Title: "Pickup",
+			Start: now.Add(30 * time.Minute).Format(time.RFC3339), End: now.Add(45 * time.Minute).Format(time.RFC3339),
+		},
+		{
+			ID: "quiet", CalendarID: "family", Title: "No reminders",
+			Start: now.Format(time.RFC3339), End: now.Add(time.Hour).Format(time.RFC3339),
+			Reminders: &models.EventReminders{},
+		},
+		{
+			ID: "work", CalendarID: "work", Title: "Standup",
+			Start: now.Format(time.RFC3339), End: now.Add(time.Hour).Format(time.RFC3339),
+		},
+	}
+
+	eventBus := NewEventBus()
+	published, unsubscribe := eventBus.Subscribe()
+	defer unsubscribe()
+	toasts := func() []string {
+		var messages []string
+		for {
+			select {
+			case event := <-published:
+				messages = append(messages, event.Data.(map[string]interface{})["message"].(string))
+			default:
+				return messages
+			}
+		}
+	}
+
+	var emails []string
+	failEmail := true
+	emailService := NewEmailService(&models.EmailConfig{SMTPHost: "smtp.example.com", From: "home@example.com"})
+	emailService.sendMail = func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+		if failEmail {
+			failEmail = false
+			return errors.New("connection refused")
+		}
+		emails = append(emails, string(msg))
+		return nil
+	}
+
+	newReminderService := func() *CalendarReminderService {
+		service := NewCalendarReminderService(func(ctx context.Context, start, end time.Time, calendarIDs []string) ([]CalendarEvent, error) {
+			return events, nil
+		}, db, &models.CalendarReminderConfig{Location: time.UTC})
+		service.SetEventBus(eventBus)
+		service.SetEmailService(emailService)
+		service.now = func() time.Time { return now }
+		if err := service.load(); err != nil {
+			t.Fatalf("Failed to load reminders: %v", err)
+		}
+		return service
+	}
+	service := newReminderService()
+
+	if _, err := service.SetRule(models.CalendarReminderRule{CalendarID: "school", Channel: models.ReminderChannelEmail}); err == nil {
+		t.Fatal("Expected email reminders without recipients to be rejected")
+	}
+	service.now = func() time.Time { return now.Add(-2 * time.Hour) }
+	if _, err := service.SetRule(models.CalendarReminderRule{CalendarID: "family", Channel: models.ReminderChannelDashboard, Enabled: true}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := service.SetRule(models.CalendarReminderRule{
+		CalendarID: "school", Channel: models.ReminderChannelEmail, Enabled: true,
+		Recipients: []string{"parents@example.com"}, DefaultMinutes: []int{30},
+	}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	service.now = func() time.Time { return now }
+
+	// The hour ahead reminder came due before the check, the other two are due now. The email
+	// fails once and is sent on the next check, without the toast being shown again.
+	service.check(context.Background())
+	if messages := toasts(); len(messages) != 1 || messages[0] != "Soccer practice starts at 4:10 PM, in 10 minutes" {
+		t.Fatalf("Unexpected toasts: %v", messages)
+	}
+	if len(emails) != 0 {
+		t.Fatal("Expected the first email to fail")
+	}
+	service.check(context.Background())
+	if len(toasts()) != 0 || len(emails) != 1 || !strings.Contains(emails[0], "Subject: Reminder: Pickup") || !strings.Contains(emails[0], "Pickup starts at 4:30 PM, in 30 minutes") {
+		t.Fatalf("Unexpected emails: %v", emails)
+	}
+
+	reminders := service.GetReminders()
+	if len(reminders) != 3 {
+		t.Fatalf("Expected 3 reminders, got %+v", reminders)
+	}
+	statuses := make(map[string]*models.CalendarReminder)
+	for _, reminder := range reminders {
+		statuses[reminder.EventID+"/"+reminder.Status] = reminder
+	}
+	if statuses["soccer/delivered"] == nil || statuses["soccer/missed"] == nil || statuses["pickup/delivered"] == nil || statuses["pickup/delivered"].Attempts != 2 {
+		t.Fatalf("Unexpected reminders: %+v", reminders)
+	}
+
+	// After a restart nothing is repeated, and a reminder that came due while WoodHome was
+	// down is delivered late
+	events = append(events, CalendarEvent{
+		ID: "dinner", CalendarID: "family", Title: "Dinner",
+		Start: now.Add(15 * time.Minute).Format(time.RFC3339), End: now.Add(time.Hour).Format(time.RFC3339),
+		Reminders: &models.EventReminders{Overrides: []*models.ReminderOverride{{Method: "popup", Minutes: 20}}},
+	})
+	service = newReminderService()
+	service.check(context.Background())
+	if messages := toasts(); len(messages) != 1 || messages[0] != "Dinner starts at 4:15 PM, in 20 minutes" {
+		t.Fatalf("Unexpected toasts after a restart: %v", messages)
+	}
+	if len(emails) != 1 || len(service.GetReminders()) != 4 {
+		t.Fatal("Expected no reminder to be repeated")
+	}
+
+	// Reminders are forgotten a week after their event
+	service.now = func() time.Time { return now.Add(8 * 24 * time.Hour) }
+	events = nil
+	service.check(context.Background())
+	if service = newReminderService(); len(service.GetReminders()) != 0 || len(service.GetRules()) != 2 {
+		t.Fatal("Expected only the rules to be left")
+	}
+}
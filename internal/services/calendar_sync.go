@@ -0,0 +1,568 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"woodhome-webapp/internal/models"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// calendarSyncPageSize is the most events asked for per page
+const calendarSyncPageSize = 250
+
+// storedEvent is an event in the local store with its parsed times
+type storedEvent struct {
+	event CalendarEvent
+	start time.Time
+	end   time.Time
+}
+
+// syncedCalendar is a calendar in the local store
+type syncedCalendar struct {
+	info      models.CalendarSyncCalendar
+	syncToken string
+	events    map[string]storedEvent
+}
+
+// syncedCalendarRecord is how a calendar's sync state is saved
+type syncedCalendarRecord struct {
+	Info      models.CalendarSyncCalendar `json:"info"`
+	SyncToken string                      `json:"sync_token"`
+}
+
+// CalendarSyncService keeps a local copy of the household's Google calendars. After a full
+// sync of each calendar it only pulls changes using the sync token Google returns, so the
+// dashboard reads events instantly and keeps working through short Google outages.
+type CalendarSyncService struct {
+	calendarService *CalendarService
+	config          *models.CalendarSyncConfig
+	db              *sql.DB
+	calendars       map[string]*syncedCalendar
+	loaded          bool // The store holds a previous sync
+	lastSync        time.Time
+	lastError       string
+	newService      func(ctx context.Context) (*calendar.Service, error)
+	now             func() time.Time
+	syncMu          sync.Mutex // One sync at a time
+	mu              sync.RWMutex
+}
+
+// NewCalendarSyncService creates a new CalendarSyncService instance.
+// The store is kept in memory only when db is nil.
+func NewCalendarSyncService(calendarService *CalendarService, db *sql.DB, config *models.CalendarSyncConfig) *CalendarSyncService {
+	if config == nil {
+		config = &models.CalendarSyncConfig{}
+	}
+	if config.UserID == 0 {
+		config.UserID = HouseholdUserID
+	}
+	if config.Interval <= 0 {
+		config.Interval = 5 * time.Minute
+	}
+	if config.PastDays <= 0 {
+		config.PastDays = 90
+	}
+
+	s := &CalendarSyncService{
+		calendarService: calendarService,
+		config:          config,
+		db:              db,
+		calendars:       make(map[string]*syncedCalendar),
+		now:             time.Now,
+	}
+	s.newService = s.googleService
+	return s
+}
+
+// Start loads the local store and keeps it in sync in the background
+func (s *CalendarSyncService) Start(ctx context.Context) error {
+	logrus.Info("Starting calendar sync service...")
+
+	if err := s.load(); err != nil {
+		return fmt.Errorf("failed to load calendar store: %w", err)
+	}
+
+	go s.startLoop(ctx)
+	return nil
+}
+
+// startLoop syncs right away and then on every interval until the context is cancelled
+func (s *CalendarSyncService) startLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Sync(ctx); err != nil {
+			logrus.Warnf("Calendar sync: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// UserID returns the account whose calendars are synced
+func (s *CalendarSyncService) UserID() int {
+	return s.config.UserID
+}
+
+// Ready reports whether events can be served from the store
+func (s *CalendarSyncService) Ready() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.loaded || !s.lastSync.IsZero()
+}
+
+// Sync pulls the changes of every visible calendar. A calendar that fails keeps its
+// stored events and is retried on the next sync.
+func (s *CalendarSyncService) Sync(ctx context.Context) error {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	err := s.syncAll(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.lastError = err.Error()
+		return err
+	}
+	s.lastError = ""
+	s.lastSync = s.now()
+	return nil
+}
+
+// SyncCalendar pulls the changes of one stored calendar, such as after Google reports a change
+func (s *CalendarSyncService) SyncCalendar(ctx context.Context, calendarID string) error {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	s.mu.RLock()
+	cal, exists := s.calendars[calendarID]
+	var entry *calendar.CalendarListEntry
+	if exists {
+		entry = &calendar.CalendarListEntry{Id: cal.info.ID, Summary: cal.info.Name, BackgroundColor: cal.info.Color}
+	}
+	s.mu.RUnlock()
+	if !exists {
+		return fmt.Errorf("calendar %s is not synced", calendarID)
+	}
+
+	srv, err := s.newService(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Google Calendar: %w", err)
+	}
+	return s.syncCalendar(ctx, srv, entry)
+}
+
+// syncAll syncs the visible calendars and drops the ones no longer shown
+func (s *CalendarSyncService) syncAll(ctx context.Context) error {
+	srv, err := s.newService(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Google Calendar: %w", err)
+	}
+
+	entries, err := listCalendars(ctx, srv)
+	if err != nil {
+		return fmt.Errorf("failed to list calendars: %w", err)
+	}
+
+	visible := make(map[string]bool)
+	failed := 0
+	for _, entry := range entries {
+		// Skip calendars that are not selected or are hidden, as GetCalendarEvents does
+		if !entry.Selected || entry.Hidden {
+			continue
+		}
+		visible[entry.Id] = true
+		if err := s.syncCalendar(ctx, srv, entry); err != nil {
+			logrus.Warnf("Calendar sync: failed to sync %s: %v", entry.Summary, err)
+			failed++
+		}
+	}
+
+	s.mu.Lock()
+	var removed []string
+	for id := range s.calendars {
+		if !visible[id] {
+			delete(s.calendars, id)
+			removed = append(removed, id)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, id := range removed {
+		if err := s.deleteCalendar(id); err != nil {
+			logrus.Warnf("Calendar sync: failed to remove calendar %s: %v", id, err)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d calendars failed to sync", failed, len(visible))
+	}
+	return nil
+}
+
+// syncCalendar pulls the changes of one calendar, falling back to a full sync when Google
+// no longer accepts its sync token
+func (s *CalendarSyncService) syncCalendar(ctx context.Context, srv *calendar.Service, entry *calendar.CalendarListEntry) error {
+	s.mu.RLock()
+	syncToken := ""
+	if existing, exists := s.calendars[entry.Id]; exists {
+		syncToken = existing.syncToken
+	}
+	s.mu.RUnlock()
+
+	items, nextToken, err := s.fetchEvents(ctx, srv, entry.Id, syncToken)
+	if syncToken != "" && isSyncTokenExpired(err) {
+		logrus.Infof("Calendar sync: sync token of %s expired, syncing it again in full", entry.Summary)
+		syncToken = ""
+		items, nextToken, err = s.fetchEvents(ctx, srv, entry.Id, "")
+	}
+
+	s.mu.Lock()
+	cal, exists := s.calendars[entry.Id]
+	if !exists {
+		cal = &syncedCalendar{
+			info:   models.CalendarSyncCalendar{ID: entry.Id},
+			events: make(map[string]storedEvent),
+		}
+		s.calendars[entry.Id] = cal
+	}
+	cal.info.Name = entry.Summary
+	cal.info.Color = s.calendarService.calendarEntryColor(entry)
+	if err != nil {
+		cal.info.LastError = err.Error()
+		s.mu.Unlock()
+		return err
+	}
+
+	full := syncToken == ""
+	if full {
+		cal.events = make(map[string]storedEvent)
+	}
+	var changed []storedEvent
+	var deleted []string
+	for _, item := range items {
+		if item.Status == "cancelled" {
+			delete(cal.events, item.Id)
+			deleted = append(deleted, item.Id)
+			continue
+		}
+		event := toCalendarEvent(item, entry, cal.info.Color)
+		start, end, ok := eventTimeRange(event, time.Local)
+		if !ok {
+			continue
+		}
+		stored := storedEvent{event: event, start: start, end: end}
+		cal.events[item.Id] = stored
+		changed = append(changed, stored)
+	}
+
+	now := s.now()
+	cal.syncToken = nextToken
+	cal.info.Events = len(cal.events)
+	cal.info.LastSync = &now
+	cal.info.LastError = ""
+	if full {
+		cal.info.LastFullSync = &now
+	}
+	record := syncedCalendarRecord{Info: cal.info, SyncToken: cal.syncToken}
+	s.mu.Unlock()
+
+	if len(items) > 0 || full {
+		logrus.Infof("Calendar sync: %s has %d changes (full sync: %t)", entry.Summary, len(items), full)
+	}
+	return s.saveCalendar(record, full, changed, deleted)
+}
+
+// fetchEvents lists the events of a calendar, either every event since PastDays ago
+// or the changes since syncToken. It returns the token for the next sync.
+func (s *CalendarSyncService) fetchEvents(ctx context.Context, srv *calendar.Service, calendarID, syncToken string) ([]*calendar.Event, string, error) {
+	var items []*calendar.Event
+	pageToken := ""
+
+	for {
+		call := srv.Events.List(calendarID).
+			SingleEvents(true).
+			MaxResults(calendarSyncPageSize).
+			Context(ctx)
+		if syncToken != "" {
+			call = call.SyncToken(syncToken)
+		} else {
+			call = call.TimeMin(s.now().AddDate(0, 0, -s.config.PastDays).Format(time.RFC3339))
+		}
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+
+		page, err := call.Do()
+		if err != nil {
+			return nil, "", err
+		}
+		items = append(items, page.Items...)
+
+		if page.NextPageToken == "" {
+			return items, page.NextSyncToken, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// GetEvents returns the stored events overlapping start to end, ordered by start.
+// When calendarIDs is not empty only those calendars are included.
+func (s *CalendarSyncService) GetEvents(start, end time.Time, calendarIDs []string) []CalendarEvent {
+	selected := make(map[string]bool)
+	for _, id := range calendarIDs {
+		selected[id] = true
+	}
+
+	s.mu.RLock()
+	var matches []storedEvent
+	for id, cal := range s.calendars {
+		if len(selected) > 0 && !selected[id] {
+			continue
+		}
+		for _, stored := range cal.events {
+			overlaps := stored.start.Before(end) && stored.end.After(start)
+			instant := stored.start.Equal(stored.end) && !stored.start.Before(start) && stored.start.Before(end)
+			if overlaps || instant {
+				matches = append(matches, stored)
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		if !matches[i].start.Equal(matches[j].start) {
+			return matches[i].start.Before(matches[j].start)
+		}
+		return matches[i].event.ID < matches[j].event.ID
+	})
+
+	events := make([]CalendarEvent, 0, len(matches))
+	for _, stored := range matches {
+		events = append(events, stored.event)
+	}
+	return events
+}
+
+// GetStatus reports the last sync of the store and of each calendar
+func (s *CalendarSyncService) GetStatus() *models.CalendarSyncStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := &models.CalendarSyncStatus{
+		Ready:     s.loaded || !s.lastSync.IsZero(),
+		LastError: s.lastError,
+		Calendars: make([]*models.CalendarSyncCalendar, 0, len(s.calendars)),
+	}
+	if !s.lastSync.IsZero() {
+		lastSync := s.lastSync
+		status.LastSync = &lastSync
+	}
+	for _, cal := range s.calendars {
+		info := cal.info
+		status.Calendars = append(status.Calendars, &info)
+	}
+	sort.Slice(status.Calendars, func(i, j int) bool {
+		return status.Calendars[i].Name < status.Calendars[j].Name
+	})
+	return status
+}
+
+// googleService connects to Google Calendar with the household account and saves a refreshed token
+func (s *CalendarSyncService) googleService(ctx context.Context) (*calendar.Service, error) {
+	token, err := LoadOAuthToken(s.config.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load calendar token: %w", err)
+	}
+	srv, err := s.calendarService.newGoogleService(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := SaveOAuthToken(s.config.UserID, token); err != nil {
+		return nil, fmt.Errorf("failed to save calendar token: %w", err)
+	}
+	return srv, nil
+}
+
+// load reads the stored calendars and events
+func (s *CalendarSyncService) load() error {
+	if s.db == nil {
+		return nil
+	}
+
+	for _, statement := range []string{`
+		CREATE TABLE IF NOT EXISTS calendar_sync_calendars (
+			id TEXT PRIMARY KEY,
+			data TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`, `
+		CREATE TABLE IF NOT EXISTS calendar_sync_events (
+			calendar_id TEXT NOT NULL,
+			event_id TEXT NOT NULL,
+			data TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (calendar_id, event_id)
+		)`,
+	} {
+		if _, err := s.db.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	calendars := make(map[string]*syncedCalendar)
+	rows, err := s.db.Query(`SELECT id, data FROM calendar_sync_calendars`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return err
+		}
+		var record syncedCalendarRecord
+		if err := json.Unmarshal([]byte(data), &record); err != nil {
+			logrus.Warnf("Calendar sync: ignoring unreadable calendar %s: %v", id, err)
+			continue
+		}
+		record.Info.ID = id
+		calendars[id] = &syncedCalendar{info: record.Info, syncToken: record.SyncToken, events: make(map[string]storedEvent)}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = s.db.Query(`SELECT calendar_id, data FROM calendar_sync_events`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var calendarID, data string
+		if err := rows.Scan(&calendarID, &data); err != nil {
+			return err
+		}
+		cal, exists := calendars[calendarID]
+		if !exists {
+			continue
+		}
+		var event CalendarEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			continue
+		}
+		start, end, ok := eventTimeRange(event, time.Local)
+		if !ok {
+			continue
+		}
+		cal.events[event.ID] = storedEvent{event: event, start: start, end: end}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calendars = calendars
+	s.loaded = len(calendars) > 0
+	logrus.Infof("Calendar sync: loaded %d events from %d calendars", count, len(calendars))
+	return nil
+}
+
+// saveCalendar stores the sync state and event changes of a calendar in one transaction
+func (s *CalendarSyncService) saveCalendar(record syncedCalendarRecord, full bool, changed []storedEvent, deleted []string) error {
+	if s.db == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal calendar: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to save calendar: %w", err)
+	}
+	defer tx.Rollback()
+
+	if full {
+		if _, err := tx.Exec(`DELETE FROM calendar_sync_events WHERE calendar_id = ?`, record.Info.ID); err != nil {
+			return fmt.Errorf("failed to save calendar: %w", err)
+		}
+	}
+	for _, id := range deleted {
+		if _, err := tx.Exec(`DELETE FROM calendar_sync_events WHERE calendar_id = ? AND event_id = ?`, record.Info.ID, id); err != nil {
+			return fmt.Errorf("failed to save calendar: %w", err)
+		}
+	}
+	for _, stored := range changed {
+		eventData, err := json.Marshal(stored.event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+		_, err = tx.Exec(`
+			INSERT OR REPLACE INTO calendar_sync_events (calendar_id, event_id, data, updated_at)
+			VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		`, record.Info.ID, stored.event.ID, string(eventData))
+		if err != nil {
+			return fmt.Errorf("failed to save calendar: %w", err)
+		}
+	}
+	_, err = tx.Exec(`
+		INSERT OR REPLACE INTO calendar_sync_calendars (id, data, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+	`, record.Info.ID, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save calendar: %w", err)
+	}
+	return tx.Commit()
+}
+
+// deleteCalendar removes a calendar and its events from the store
+func (s *CalendarSyncService) deleteCalendar(id string) error {
+	if s.db == nil {
+		return nil
+	}
+	if _, err := s.db.Exec(`DELETE FROM calendar_sync_events WHERE calendar_id = ?`, id); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`DELETE FROM calendar_sync_calendars WHERE id = ?`, id)
+	return err
+}
+
+// listCalendars returns every entry of the calendar list
+func listCalendars(ctx context.Context, srv *calendar.Service) ([]*calendar.CalendarListEntry, error) {
+	var entries []*calendar.CalendarListEntry
+	err := srv.CalendarList.List().Pages(ctx, func(page *calendar.CalendarList) error {
+		entries = append(entries, page.Items...)
+		return nil
+	})
+	return entries, err
+}
+
+// isSyncTokenExpired reports whether Google rejected a sync token, which it does with 410 Gone
+func isSyncTokenExpired(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusGone
+}
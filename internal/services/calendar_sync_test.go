@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"woodhome-webapp/internal/database"
+	"woodhome-webapp/internal/models"
+
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
+)
+
+// fakeCalendarAPI serves a calendar list and the events of one calendar, answering
+// sync tokens with whatever changes the test queues up
+type fakeCalendarAPI struct {
+	full        []*calendar.Event
+	changes     []*calendar.Event
+	expireToken bool
+	fullSyncs   int
+	syncTokens  []string
+	watches     []*calendar.Channel
+	stops       []string
+}
+
+func (f *fakeCalendarAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/users/me/calendarList"):
+		json.NewEncoder(w).Encode(&calendar.CalendarList{Items: []*calendar.CalendarListEntry{
+			{Id: "family", Summary: "Family", BackgroundColor: "#16a765", Selected: true},
+			{Id: "hidden", Summary: "Hidden", Selected: true, Hidden: true},
+		}})
+	case strings.HasSuffix(r.URL.Path, "/calendars/family/events/watch"):
+		var channel calendar.Channel
+		json.NewDecoder(r.Body).Decode(&channel)
+		f.watches = append(f.watches, &channel)
+		json.NewEncoder(w).Encode(&calendar.Channel{Id: channel.Id, ResourceId: "family-events"})
+	case strings.HasSuffix(r.URL.Path, "/channels/stop"):
+		var channel calendar.Channel
+		json.NewDecoder(r.Body).Decode(&channel)
+		f.stops = append(f.stops, channel.Id)
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasSuffix(r.URL.Path, "/calendars/family/events"):
+		syncToken := r.URL.Query().Get("syncToken")
+		if syncToken == "" {
+			f.fullSyncs++
+			json.NewEncoder(w).Encode(&calendar.Events{Items: f.full, NextSyncToken: "token-full"})
+			return
+		}
+
+		f.syncTokens = append(f.syncTokens, syncToken)
+		if f.expireToken {
+			f.expireToken = false
+			w.WriteHeader(http.StatusGone)
+			w.Write([]byte(`{"error":{"code":410,"message":"Sync token is no longer valid"}}`))
+			return
+		}
+		json.NewEncoder(w).Encode(&calendar.Events{Items: f.changes, NextSyncToken: "token-changes"})
+		f.changes = nil
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestCalendarSyncAppliesChanges(t *testing.T) {
+	api := &fakeCalendarAPI{full: []*calendar.Event{
+		{Id: "swim", Summary: "Swimming", Start: &calendar.EventDateTime{DateTime: "2024-06-20T17:00:00Z"}, End: &calendar.EventDateTime{DateTime: "2024-06-20T18:00:00Z"}},
+		{Id: "trip", Summary: "Camping", Start: &calendar.EventDateTime{Date: "2024-06-21"}, End: &calendar.EventDateTime{Date: "2024-06-23"}},
+	}}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	db, err := database.OpenSQLite(filepath.Join(t.TempDir(), "home.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	newSyncService := func() *CalendarSyncService {
+		service := NewCalendarSyncService(NewCalendarService(nil), db, &models.CalendarSyncConfig{})
+		service.newService = func(ctx context.Context) (*calendar.Service, error) {
+			return calendar.NewService(ctx, option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+		}
+		if err := service.load(); err != nil {
+			t.Fatalf("Failed to load store: %v", err)
+		}
+		return service
+	}
+	service := newSyncService()
+	ctx := context.Background()
+
+	if service.Ready() {
+		t.Fatal("Expected an empty store not to be ready")
+	}
+	if err := service.Sync(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	week := func() []CalendarEvent {
+		return service.GetEvents(time.Date(2024, 6, 17, 0, 0, 0, 0, time.UTC), time.Date(2024, 6, 24, 0, 0, 0, 0, time.UTC), nil)
+	}
+	events := week()
+	if len(events) != 2 || events[0].Title != "[Family] Swimming" || events[0].CalendarColor != "#16a765" || !events[1].AllDay {
+		t.Fatalf("Unexpected events after the full sync: %+v", events)
+	}
+
+	// Changes arrive through the sync token, including a deletion
+	api.changes = []*calendar.Event{
+		{Id: "swim", Status: "cancelled"},
+		{Id: "dentist", Summary: "Dentist", Start: &calendar.EventDateTime{DateTime: "2024-06-19T09:00:00Z"}, End: &calendar.EventDateTime{DateTime: "2024-06-19T09:30:00Z"}},
+	}
+	if err := service.Sync(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	events = week()
+	if len(events) != 2 || events[0].ID != "dentist" || events[1].ID != "trip" {
+		t.Fatalf("Unexpected events after the incremental sync: %+v", events)
+	}
+	if api.fullSyncs != 1 || len(api.syncTokens) != 1 || api.syncTokens[0] != "token-full" {
+		t.Fatalf("Expected one full sync and one incremental sync, got %d full and tokens %v", api.fullSyncs, api.syncTokens)
+	}
+
+	// The store survives a restart along with its sync token
+	service = newSyncService()
+	if !service.Ready() || len(week()) != 2 {
+		t.Fatalf("Expected the stored events after a restart, got %+v", week())
+	}
+
+	// An expired sync token falls back to a full sync that replaces the stored events
+	api.expireToken = true
+	if err := service.Sync(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if api.fullSyncs != 2 || api.syncTokens[1] != "token-changes" {
+		t.Fatalf("Expected a full resync after the stored token expired, got %d full and tokens %v", api.fullSyncs, api.syncTokens)
+	}
+	events = week()
+	if len(events) != 2 || events[0].ID != "swim" {
+		t.Fatalf("Unexpected events after the resync: %+v", events)
+	}
+
+	status := service.GetStatus()
+	if len(status.Calendars) != 1 || status.Calendars[0].Events != 2 || status.Calendars[0].LastFullSync == nil || status.LastSync == nil {
+		t.Fatalf("Unexpected status: %+v", status)
+	}
+}
@@ -0,0 +1,536 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"woodhome-webapp/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// watchChannel is an open channel with the token Google echoes back on every notification
+type watchChannel struct {
+	info  models.CalendarWatchChannel
+	token string
+}
+
+// watchChannelRecord is how a channel is saved
+type watchChannelRecord struct {
+	Channel models.CalendarWatchChannel `json:"channel"`
+	Token   string                      `json:"token"`
+}
+
+// CalendarWatchService opens Google Calendar push notification channels on the synced
+// calendars, so a change made on a phone reaches the dashboard within seconds instead of
+// on the next sync interval. Channels are renewed before they expire and stopped on logout.
+type CalendarWatchService struct {
+	syncService  *CalendarSyncService
+	cacheService *CalendarCacheService
+	config       *models.CalendarWatchConfig
+	db           *sql.DB
+	channels     map[string]*watchChannel // By channel ID
+	stopped      bool
+	pending      map[string]bool // Calendars waiting for a refresh
+	refresh      chan struct{}
+	now          func() time.Time
+	renewMu      sync.Mutex // One renewal or stop at a time
+	mu           sync.RWMutex
+}
+
+// NewCalendarWatchService creates a new CalendarWatchService instance.
+// Channels are only opened when config has a webhook URL.
+func NewCalendarWatchService(syncService *CalendarSyncService, db *sql.DB, config *models.CalendarWatchConfig) *CalendarWatchService {
+	if config == nil {
+		config = &models.CalendarWatchConfig{}
+	}
+	if config.TTL <= 0 {
+		config.TTL = 7 * 24 * time.Hour
+	}
+	if config.RenewBefore <= 0 {
+		config.RenewBefore = time.Hour
+	}
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = 5 * time.Minute
+	}
+
+	return &CalendarWatchService{
+		syncService: syncService,
+		config:      config,
+		db:          db,
+		channels:    make(map[string]*watchChannel),
+		pending:     make(map[string]bool),
+		refresh:     make(chan struct{}, 1),
+		now:         time.Now,
+	}
+}
+
+// SetCacheService sets the calendar cache cleared when Google reports a change
+func (s *CalendarWatchService) SetCacheService(cacheService *CalendarCacheService) {
+	s.cacheService = cacheService
+}
+
+// Start loads the saved channels, keeps them renewed and refreshes calendars Google reports as changed
+func (s *CalendarWatchService) Start(ctx context.Context) error {
+	logrus.Info("Starting calendar watch service...")
+
+	if err := s.load(); err != nil {
+		return fmt.Errorf("failed to load calendar watch channels: %w", err)
+	}
+	if s.config.WebhookURL == "" {
+		logrus.Info("Calendar watch: no webhook URL configured, relying on interval sync")
+	}
+
+	go s.startLoop(ctx)
+	go s.refreshLoop(ctx)
+	return nil
+}
+
+// startLoop renews channels right away and then on every check interval
+func (s *CalendarWatchService) startLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Renew(ctx); err != nil {
+			logrus.Warnf("Calendar watch: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshLoop syncs the calendars queued by notifications
+func (s *CalendarWatchService) refreshLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.refresh:
+			s.refreshPending(ctx)
+		}
+	}
+}
+
+// refreshPending syncs every queued calendar and clears the cached responses
+func (s *CalendarWatchService) refreshPending(ctx context.Context) {
+	s.mu.Lock()
+	calendarIDs := make([]string, 0, len(s.pending))
+	for id := range s.pending {
+		calendarIDs = append(calendarIDs, id)
+	}
+	s.pending = make(map[string]bool)
+	s.mu.Unlock()
+
+	for _, id := range calendarIDs {
+		if err := s.syncService.SyncCalendar(ctx, id); err != nil {
+			logrus.Warnf("Calendar watch: failed to refresh %s: %v", id, err)
+		}
+	}
+	s.invalidateCache()
+}
+
+// Renew opens a channel on every synced calendar that has none or whose channel expires
+// soon, and stops channels of calendars that are no longer synced
+func (s *CalendarWatchService) Renew(ctx context.Context) error {
+	if s.config.WebhookURL == "" {
+		return nil
+	}
+
+	s.renewMu.Lock()
+	defer s.renewMu.Unlock()
+
+	s.mu.RLock()
+	stopped := s.stopped
+	s.mu.RUnlock()
+	if stopped {
+		return nil
+	}
+
+	calendars := s.syncService.GetStatus().Calendars
+	if len(calendars) == 0 {
+		return nil
+	}
+
+	srv, err := s.syncService.newService(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Google Calendar: %w", err)
+	}
+
+	renewAt := s.now().Add(s.config.RenewBefore)
+	synced := make(map[string]bool)
+	failed := 0
+	for _, cal := range calendars {
+		synced[cal.ID] = true
+
+		current := s.channelFor(cal.ID)
+		if current != nil && current.info.Expiration.After(renewAt) {
+			continue
+		}
+		if err := s.open(ctx, srv, cal); err != nil {
+			logrus.Warnf("Calendar watch: failed to watch %s: %v", cal.Name, err)
+			failed++
+			continue
+		}
+		if current != nil {
+			s.stop(ctx, srv, current)
+		}
+	}
+
+	for _, channel := range s.channelList() {
+		if !synced[channel.info.CalendarID] {
+			s.stop(ctx, srv, channel)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d calendars could not be watched", failed, len(calendars))
+	}
+	return nil
+}
+
+// StopAll stops every channel and opens no more until Resume, such as after a logout
+func (s *CalendarWatchService) StopAll(ctx context.Context) error {
+	s.renewMu.Lock()
+	defer s.renewMu.Unlock()
+
+	if err := s.setStopped(true); err != nil {
+		return err
+	}
+
+	channels := s.channelList()
+	if len(channels) == 0 {
+		return nil
+	}
+
+	srv, err := s.syncService.newService(ctx)
+	if err != nil {
+		// Forget the channels anyway, Google stops them when they expire
+		for _, channel := range channels {
+			s.forget(channel.info.ID)
+		}
+		return fmt.Errorf("failed to connect to Google Calendar: %w", err)
+	}
+	for _, channel := range channels {
+		s.stop(ctx, srv, channel)
+	}
+	logrus.Infof("Calendar watch: stopped %d channels", len(channels))
+	return nil
+}
+
+// Resume allows channels to be opened again, such as after a login
+func (s *CalendarWatchService) Resume(ctx context.Context) error {
+	if err := s.setStopped(false); err != nil {
+		return err
+	}
+	return s.Renew(ctx)
+}
+
+// HasChannel reports whether a channel is open
+func (s *CalendarWatchService) HasChannel(channelID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, exists := s.channels[channelID]
+	return exists
+}
+
+// Notify handles a notification from Google. A change clears the cached responses and
+// queues an incremental sync of the calendar; the initial sync message is only recorded.
+func (s *CalendarWatchService) Notify(notification models.CalendarWatchNotification) error {
+	s.mu.Lock()
+	channel, exists := s.channels[notification.ChannelID]
+	if !exists {
+		s.mu.Unlock()
+		return fmt.Errorf("channel %s not found", notification.ChannelID)
+	}
+	if subtle.ConstantTimeCompare([]byte(notification.Token), []byte(channel.token)) != 1 {
+		s.mu.Unlock()
+		return fmt.Errorf("invalid token for channel %s", notification.ChannelID)
+	}
+	if notification.ResourceID != "" && channel.info.ResourceID != "" && notification.ResourceID != channel.info.ResourceID {
+		s.mu.Unlock()
+		return fmt.Errorf("resource %s does not belong to channel %s", notification.ResourceID, notification.ChannelID)
+	}
+
+	now := s.now()
+	channel.info.LastMessage = &now
+	channel.info.Messages++
+	record := watchChannelRecord{Channel: channel.info, Token: channel.token}
+	calendarID := channel.info.CalendarID
+	if notification.ResourceState != "sync" {
+		s.pending[calendarID] = true
+	}
+	s.mu.Unlock()
+
+	if err := s.save(record); err != nil {
+		logrus.Warnf("Calendar watch: failed to save channel %s: %v", record.Channel.ID, err)
+	}
+	if notification.ResourceState == "sync" {
+		return nil
+	}
+
+	logrus.Infof("Calendar watch: %s changed (message %s)", record.Channel.CalendarName, notification.MessageNumber)
+	s.invalidateCache()
+	select {
+	case s.refresh <- struct{}{}:
+	default: // A refresh is already queued
+	}
+	return nil
+}
+
+// GetStatus reports the open channels
+func (s *CalendarWatchService) GetStatus() *models.CalendarWatchStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := &models.CalendarWatchStatus{
+		Enabled:  s.config.WebhookURL != "",
+		Stopped:  s.stopped,
+		Channels: make([]*models.CalendarWatchChannel, 0, len(s.channels)),
+	}
+	for _, channel := range s.channels {
+		info := channel.info
+		status.Channels = append(status.Channels, &info)
+	}
+	sort.Slice(status.Channels, func(i, j int) bool {
+		return status.Channels[i].CalendarName < status.Channels[j].CalendarName
+	})
+	return status
+}
+
+// open asks Google to post changes of a calendar to the webhook
+func (s *CalendarWatchService) open(ctx context.Context, srv *calendar.Service, cal *models.CalendarSyncCalendar) error {
+	token, err := GenerateStateToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate channel token: %w", err)
+	}
+
+	request := &calendar.Channel{
+		Id:      uuid.New().String(),
+		Token:   token,
+		Type:    "web_hook",
+		Address: s.config.WebhookURL,
+		Params:  map[string]string{"ttl": strconv.Itoa(int(s.config.TTL.Seconds()))},
+	}
+	response, err := srv.Events.Watch(cal.ID, request).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	channel := &watchChannel{
+		info: models.CalendarWatchChannel{
+			ID:           request.Id,
+			CalendarID:   cal.ID,
+			CalendarName: cal.Name,
+			ResourceID:   response.ResourceId,
+			Expiration:   now.Add(s.config.TTL),
+			CreatedAt:    now,
+		},
+		token: token,
+	}
+	if response.Expiration > 0 {
+		channel.info.Expiration = time.UnixMilli(response.Expiration)
+	}
+
+	s.mu.Lock()
+	s.channels[channel.info.ID] = channel
+	s.mu.Unlock()
+
+	logrus.Infof("Calendar watch: watching %s until %s", cal.Name, channel.info.Expiration.Format(time.RFC3339))
+	return s.save(watchChannelRecord{Channel: channel.info, Token: token})
+}
+
+// stop asks Google to stop a channel and forgets it. A channel Google no longer knows is just forgotten.
+func (s *CalendarWatchService) stop(ctx context.Context, srv *calendar.Service, channel *watchChannel) {
+	err := srv.Channels.Stop(&calendar.Channel{Id: channel.info.ID, ResourceId: channel.info.ResourceID}).Context(ctx).Do()
+	var apiErr *googleapi.Error
+	if err != nil && !(errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound) {
+		logrus.Warnf("Calendar watch: failed to stop channel %s: %v", channel.info.ID, err)
+	}
+	s.forget(channel.info.ID)
+}
+
+// forget removes a channel
+func (s *CalendarWatchService) forget(channelID string) {
+	s.mu.Lock()
+	delete(s.channels, channelID)
+	s.mu.Unlock()
+
+	if err := s.delete(channelID); err != nil {
+		logrus.Warnf("Calendar watch: failed to delete channel %s: %v", channelID, err)
+	}
+}
+
+// channelFor returns the newest channel open on a calendar
+func (s *CalendarWatchService) channelFor(calendarID string) *watchChannel {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var newest *watchChannel
+	for _, channel := range s.channels {
+		if channel.info.CalendarID == calendarID && (newest == nil || channel.info.Expiration.After(newest.info.Expiration)) {
+			newest = channel
+		}
+	}
+	return newest
+}
+
+// channelList returns every open channel
+func (s *CalendarWatchService) channelList() []*watchChannel {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	channels := make([]*watchChannel, 0, len(s.channels))
+	for _, channel := range s.channels {
+		channels = append(channels, channel)
+	}
+	return channels
+}
+
+// invalidateCache clears the cached calendar responses so the next request sees the change
+func (s *CalendarWatchService) invalidateCache() {
+	if s.cacheService != nil {
+		s.cacheService.InvalidateAllCache(nil)
+	}
+}
+
+// load reads the saved channels, dropping the ones that have expired
+func (s *CalendarWatchService) load() error {
+	if s.db == nil {
+		return nil
+	}
+
+	for _, statement := range []string{`
+		CREATE TABLE IF NOT EXISTS calendar_watch_channels (
+			id TEXT PRIMARY KEY,
+			data TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`, `
+		CREATE TABLE IF NOT EXISTS calendar_watch_state (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			stopped BOOLEAN NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+	} {
+		if _, err := s.db.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	// A logout stops the channels until the next login, even across restarts
+	var stopped bool
+	err := s.db.QueryRow(`SELECT stopped FROM calendar_watch_state WHERE id = 1`).Scan(&stopped)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	rows, err := s.db.Query(`SELECT id, data FROM calendar_watch_channels`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	now := s.now()
+	channels := make(map[string]*watchChannel)
+	var expired []string
+	for rows.Next() {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			return err
+		}
+		var record watchChannelRecord
+		if err := json.Unmarshal([]byte(data), &record); err != nil {
+			logrus.Warnf("Calendar watch: ignoring unreadable channel %s: %v", id, err)
+			continue
+		}
+		if !record.Channel.Expiration.After(now) {
+			expired = append(expired, id)
+			continue
+		}
+		record.Channel.ID = id
+		channels[id] = &watchChannel{info: record.Channel, token: record.Token}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, id := range expired {
+		if err := s.delete(id); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.channels = channels
+	s.stopped = stopped
+	logrus.Infof("Calendar watch: loaded %d channels", len(channels))
+	return nil
+}
+
+// setStopped records whether channels may be opened
+func (s *CalendarWatchService) setStopped(stopped bool) error {
+	s.mu.Lock()
+	s.stopped = stopped
+	s.mu.Unlock()
+
+	if s.db == nil {
+		return nil
+	}
+	_, err := s.db.Exec(`
+		INSERT OR REPLACE INTO calendar_watch_state (id, stopped, updated_at)
+		VALUES (1, ?, CURRENT_TIMESTAMP)
+	`, stopped)
+	if err != nil {
+		return fmt.Errorf("failed to save calendar watch state: %w", err)
+	}
+	return nil
+}
+
+// save stores a channel
+func (s *CalendarWatchService) save(record watchChannelRecord) error {
+	if s.db == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal channel: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+		INSERT OR REPLACE INTO calendar_watch_channels (id, data, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+	`, record.Channel.ID, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save channel: %w", err)
+	}
+	return nil
+}
+
+// delete removes a stored channel
+func (s *CalendarWatchService) delete(channelID string) error {
+	if s.db == nil {
+		return nil
+	}
+	_, err := s.db.Exec(`DELETE FROM calendar_watch_channels WHERE id = ?`, channelID)
+	return err
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"woodhome-webapp/internal/database"
+	"woodhome-webapp/internal/models"
+
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
+)
+
+func TestCalendarWatchChannels(t *testing.T) {
+	api := &fakeCalendarAPI{full: []*calendar.Event{
+		{Id: "swim", Summary: "Swimming", Start: &calendar.EventDateTime{DateTime: "2024-06-20T17:00:00Z"}, End: &calendar.EventDateTime{DateTime: "2024-06-20T18:00:00Z"}},
+	}}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	db, err := database.OpenSQLite(filepath.Join(t.TempDir(), "home.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	syncService := NewCalendarSyncService(NewCalendarService(nil), nil, nil)
+	syncService.newService = func(ctx context.Context) (*calendar.Service, error) {
+		return calendar.NewService(ctx, option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+	}
+	if err := syncService.Sync(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	cacheService := NewCalendarCacheService(syncService.calendarService, DefaultCalendarCacheConfig())
+	now := time.Date(2024, 6, 20, 12, 0, 0, 0, time.UTC)
+	newWatchService := func() *CalendarWatchService {
+		service := NewCalendarWatchService(syncService, db, &models.CalendarWatchConfig{
+			WebhookURL: "https://home.example.com/webhooks/google/calendar",
+			TTL:        24 * time.Hour,
+		})
+		service.now = func() time.Time { return now }
+		service.SetCacheService(cacheService)
+		if err := service.load(); err != nil {
+			t.Fatalf("Failed to load channels: %v", err)
+		}
+		return service
+	}
+	service := newWatchService()
+
+	if err := service.Renew(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(api.watches) != 1 || api.watches[0].Address != "https://home.example.com/webhooks/google/calendar" || api.watches[0].Params["ttl"] != "86400" {
+		t.Fatalf("Expected one channel on the family calendar, got %+v", api.watches)
+	}
+	channel := api.watches[0]
+
+	// A stand-in for Google posts notifications the way the webhook receives them
+	if service.HasChannel("unknown") {
+		t.Fatal("Expected an unknown channel to be rejected")
+	}
+	if err := service.Notify(models.CalendarWatchNotification{ChannelID: channel.Id, Token: "forged", ResourceState: "exists"}); err == nil {
+		t.Fatal("Expected a notification with the wrong token to be rejected")
+	}
+	if err := service.Notify(models.CalendarWatchNotification{ChannelID: channel.Id, Token: channel.Token, ResourceID: "family-events", ResourceState: "sync"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(service.pending) != 0 {
+		t.Fatal("Expected the initial sync message not to refresh anything")
+	}
+
+	cacheService.cacheService.Set(models.NewCacheKey("events", "stale"), []CalendarEvent{}, time.Hour)
+	api.changes = []*calendar.Event{
+		{Id: "dentist", Summary: "Dentist", Start: &calendar.EventDateTime{DateTime: "2024-06-19T09:00:00Z"}, End: &calendar.EventDateTime{DateTime: "2024-06-19T09:30:00Z"}},
+	}
+	if err := service.Notify(models.CalendarWatchNotification{ChannelID: channel.Id, Token: channel.Token, ResourceID: "family-events", ResourceState: "exists", MessageNumber: "2"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cacheService.GetCacheItemCount() != 0 {
+		t.Fatal("Expected a change to clear the cached responses")
+	}
+	service.refreshPending(ctx)
+	events := syncService.GetEvents(time.Date(2024, 6, 17, 0, 0, 0, 0, time.UTC), time.Date(2024, 6, 24, 0, 0, 0, 0, time.UTC), nil)
+	if len(events) != 2 || events[0].ID != "dentist" {
+		t.Fatalf("Expected the change to be synced, got %+v", events)
+	}
+	if status := service.GetStatus(); len(status.Channels) != 1 || status.Channels[0].Messages != 2 {
+		t.Fatalf("Unexpected status: %+v", status)
+	}
+
+	// Nothing happens until the channel is about to expire, then it is replaced
+	now = now.Add(12 * time.Hour)
+	service.Renew(ctx)
+	if len(api.watches) != 1 {
+		t.Fatalf("Expected the channel to be kept, got %d watches", len(api.watches))
+	}
+	now = now.Add(11*time.Hour + 30*time.Minute)
+	if err := service.Renew(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(api.watches) != 2 || len(api.stops) != 1 || api.stops[0] != channel.Id || service.HasChannel(channel.Id) || !service.HasChannel(api.watches[1].Id) {
+		t.Fatalf("Expected the channel to be replaced, got %d watches and stops %v", len(api.watches), api.stops)
+	}
+
+	// A logout stops the channels, and they stay stopped across a restart until the next login
+	if err := service.StopAll(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(api.stops) != 2 || len(service.GetStatus().Channels) != 0 {
+		t.Fatalf("Expected every channel to be stopped, got stops %v", api.stops)
+	}
+	service = newWatchService()
+	service.Renew(ctx)
+	if len(api.watches) != 2 || !service.GetStatus().Stopped {
+		t.Fatal("Expected no channels to be opened after a logout")
+	}
+	if err := service.Resume(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(api.watches) != 3 || len(service.GetStatus().Channels) != 1 {
+		t.Fatalf("Expected a channel after logging in again, got %d watches", len(api.watches))
+	}
+}
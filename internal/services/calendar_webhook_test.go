@@ -0,0 +1,67 @@
+package services_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"woodhome-webapp/internal/handlers"
+	"woodhome-webapp/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+func TestCalendarWebhookRoute(t *testing.T) {
+	service, channel := services.OpenTestCalendarWatch(t)
+
+	// Mounted the way the server mounts it, outside the session-protected API
+	router := mux.NewRouter()
+	handlers.NewCalendarWebhookHandler(service).RegisterRoutes(router.PathPrefix("/webhooks/google/calendar").Subrouter())
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{"missing channel", map[string]string{"X-Goog-Resource-State": "exists"}, http.StatusBadRequest},
+		{"missing state", map[string]string{"X-Goog-Channel-ID": channel.Id}, http.StatusBadRequest},
+		{"unknown channel", map[string]string{"X-Goog-Channel-ID": "unknown", "X-Goog-Channel-Token": channel.Token, "X-Goog-Resource-State": "exists"}, http.StatusNotFound},
+		{"forged token", map[string]string{"X-Goog-Channel-ID": channel.Id, "X-Goog-Channel-Token": "forged", "X-Goog-Resource-State": "exists"}, http.StatusForbidden},
+		{"foreign resource", map[string]string{"X-Goog-Channel-ID": channel.Id, "X-Goog-Channel-Token": channel.Token, "X-Goog-Resource-ID": "other-events", "X-Goog-Resource-State": "exists"}, http.StatusForbidden},
+		{"sync", map[string]string{"X-Goog-Channel-ID": channel.Id, "X-Goog-Channel-Token": channel.Token, "X-Goog-Resource-ID": "family-events", "X-Goog-Resource-State": "sync", "X-Goog-Message-Number": "1"}, http.StatusNoContent},
+		{"change", map[string]string{"X-Goog-Channel-ID": channel.Id, "X-Goog-Channel-Token": channel.Token, "X-Goog-Resource-ID": "family-events", "X-Goog-Resource-State": "exists", "X-Goog-Message-Number": "2"}, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest("POST", server.URL+"/webhooks/google/calendar", nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		for key, value := range tt.headers {
+			req.Header.Set(key, value)
+		}
+		resp, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, resp.StatusCode)
+		}
+	}
+
+	// Only the accepted notifications are counted
+	if status := service.GetStatus(); len(status.Channels) != 1 || status.Channels[0].Messages != 2 {
+		t.Fatalf("Unexpected status: %+v", status)
+	}
+
+	resp, err := server.Client().Get(server.URL + "/webhooks/google/calendar")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected GET to be refused, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"woodhome-webapp/internal/models"
+)
+
+// EmailService sends household emails, such as calendar reminders, with the configured SMTP
+// settings
+type EmailService struct {
+	config   *models.EmailConfig
+	sendMail func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error
+	now      func() time.Time
+}
+
+// NewEmailService creates a new EmailService instance
+func NewEmailService(config *models.EmailConfig) *EmailService {
+	if config == nil {
+		config = &models.EmailConfig{}
+	}
+	if config.SMTPPort == 0 {
+		config.SMTPPort = 587
+	}
+
+	return &EmailService{
+		config:   config,
+		sendMail: smtp.SendMail,
+		now:      time.Now,
+	}
+}
+
+// Configured reports whether emails can be sent
+func (s *EmailService) Configured() bool {
+	return s != nil && s.config.SMTPHost != "" && s.config.From != ""
+}
+
+// SendText sends a plain text email
+func (s *EmailService) SendText(to []string, subject, body string) error {
+	return s.send(to, subject, "text/plain", body)
+}
+
+// SendHTML sends an HTML email
+func (s *EmailService) SendHTML(to []string, subject, body string) error {
+	return s.send(to, subject, "text/html", body)
+}
+
+// send builds the message and hands it to the SMTP server
+func (s *EmailService) send(to []string, subject, contentType, body string) error {
+	if !s.Configured() {
+		return fmt.Errorf("email is not configured, set SMTP_HOST and FROM_EMAIL")
+	}
+	if len(to) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+	for _, address := range to {
+		if strings.ContainsAny(address, "\r\n") {
+			return fmt.Errorf("invalid recipient %q", address)
+		}
+	}
+
+	var msg strings.Builder
+	msg.WriteString("From: " + s.config.From + "\r\n")
+	msg.WriteString("To: " + strings.Join(to, ", ") + "\r\n")
+	msg.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	msg.WriteString("Date: " + s.now().Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: " + contentType + "; charset=UTF-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if s.config.Password != "" {
+		auth = smtp.PlainAuth("", s.config.From, s.config.Password, s.config.SMTPHost)
+	}
+	addr := s.config.SMTPHost + ":" + strconv.Itoa(s.config.SMTPPort)
+	if err := s.sendMail(addr, auth, s.config.From, to, []byte(msg.String())); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"woodhome-webapp/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// eventBufferSize is the number of events a subscriber can fall behind before events are dropped
+const eventBufferSize = 64
+
+// EventBus fans out home events to in-process subscribers and dashboard streams
+type EventBus struct {
+	subscribers map[chan models.Event]struct{}
+	mu          sync.RWMutex
+}
+
+// NewEventBus creates a new EventBus instance
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscribers: make(map[chan models.Event]struct{}),
+	}
+}
+
+// Publish sends an event to every subscriber without blocking the publisher
+func (b *EventBus) Publish(eventType, source string, data interface{}) {
+	if b == nil {
+		return
+	}
+
+	event := models.Event{
+		Type:      eventType,
+		Source:    source,
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			logrus.Warnf("Event bus: subscriber is falling behind, dropped %s event", eventType)
+		}
+	}
+}
+
+// Subscribe returns a channel receiving all published events and a function to unsubscribe
+func (b *EventBus) Subscribe() (<-chan models.Event, func()) {
+	ch := make(chan models.Event, eventBufferSize)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+	return ch, unsubscribe
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"woodhome-webapp/internal/models"
+
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
+)
+
+// OpenTestCalendarWatch returns a watch service with one channel open on a stand-in for
+// Google Calendar, for tests of the handlers that use it
+func OpenTestCalendarWatch(t *testing.T) (*CalendarWatchService, *calendar.Channel) {
+	t.Helper()
+
+	api := &fakeCalendarAPI{}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	syncService := NewCalendarSyncService(NewCalendarService(nil), nil, nil)
+	syncService.newService = func(ctx context.Context) (*calendar.Service, error) {
+		return calendar.NewService(ctx, option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+	}
+	if err := syncService.Sync(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	service := NewCalendarWatchService(syncService, nil, &models.CalendarWatchConfig{
+		WebhookURL: "https://home.example.com/webhooks/google/calendar",
+		TTL:        24 * time.Hour,
+	})
+	service.SetCacheService(NewCalendarCacheService(syncService.calendarService, DefaultCalendarCacheConfig()))
+	if err := service.Renew(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(api.watches) != 1 {
+		t.Fatalf("Expected one channel, got %d", len(api.watches))
+	}
+	return service, api.watches[0]
+}
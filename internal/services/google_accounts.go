@@ -0,0 +1,566 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"woodhome-webapp/internal/models"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+var (
+	// ErrNoGoogleAccounts is returned when no Google account has been linked yet
+	ErrNoGoogleAccounts = errors.New("no Google account linked")
+	// ErrGoogleAccountNotFound is returned for an unknown linked Google account
+	ErrGoogleAccountNotFound = errors.New("Google account not found")
+	// ErrGoogleAccountNotLinked is returned when an account that was never linked signs in
+	ErrGoogleAccountNotLinked = errors.New("Google account is not linked to the household")
+)
+
+// GoogleAccountService links several Google accounts to the household, such as each parent's
+// and a shared family account. Each account has its own token row and calendar list, and
+// the calendars and events of all of them are merged into one family view. A calendar shared
+// between accounts is read through the account linked first.
+type GoogleAccountService struct {
+	calendarService *CalendarService
+	cacheService    *CalendarCacheService
+	syncService     *CalendarSyncService
+	db              *sql.DB
+	accounts        map[int]*models.GoogleAccount // By user ID
+	loadToken       func(userID int) (*oauth2.Token, error)
+	saveToken       func(userID int, token *oauth2.Token) error
+	deleteToken     func(userID int) error
+	primaryEmail    func(ctx context.Context, token *oauth2.Token) (string, error)
+	listCalendars   func(ctx context.Context, token *oauth2.Token) ([]CalendarInfo, error)
+	listEvents      func(ctx context.Context, token *oauth2.Token, start, end time.Time, calendarIDs []string) ([]CalendarEvent, error)
+	now             func() time.Time
+	linkMu          sync.Mutex // One link at a time, so two new accounts get different user IDs
+	mu              sync.RWMutex
+}
+
+// NewGoogleAccountService creates a new GoogleAccountService instance.
+// Accounts are kept in memory only when db is nil; their tokens are always saved.
+func NewGoogleAccountService(calendarService *CalendarService, db *sql.DB) *GoogleAccountService {
+	s := &GoogleAccountService{
+		calendarService: calendarService,
+		db:              db,
+		accounts:        make(map[int]*models.GoogleAccount),
+		loadToken:       LoadOAuthToken,
+		saveToken:       SaveOAuthToken,
+		deleteToken:     DeleteOAuthToken,
+		now:             time.Now,
+	}
+	s.primaryEmail = s.googlePrimaryEmail
+	s.listCalendars = func(ctx context.Context, token *oauth2.Token) ([]CalendarInfo, error) {
+		if s.cacheService != nil {
+			return s.cacheService.GetCalendars(ctx, token)
+		}
+		return s.calendarService.GetCalendars(ctx, token)
+	}
+	s.listEvents = func(ctx context.Context, token *oauth2.Token, start, end time.Time, calendarIDs []string) ([]CalendarEvent, error) {
+		if s.cacheService != nil {
+			return s.cacheService.GetCalendarEventsFiltered(ctx, token, start, end, calendarIDs)
+		}
+		return s.calendarService.GetCalendarEventsFiltered(ctx, token, start, end, calendarIDs)
+	}
+	return s
+}
+
+// SetCacheService reads calendars and events through the cache behind the calendar routes
+func (s *GoogleAccountService) SetCacheService(cacheService *CalendarCacheService) {
+	s.cacheService = cacheService
+}
+
+// SetSyncService serves the events of the account the local store syncs from the store
+func (s *GoogleAccountService) SetSyncService(syncService *CalendarSyncService) {
+	s.syncService = syncService
+}
+
+// Start loads the linked accounts and identifies any linked before accounts had addresses
+func (s *GoogleAccountService) Start(ctx context.Context) error {
+	logrus.Info("Starting Google account service...")
+
+	if err := s.load(); err != nil {
+		return fmt.Errorf("failed to load Google accounts: %w", err)
+	}
+
+	go s.identifyAccounts(ctx)
+	return nil
+}
+
+// identifyAccounts looks up the address of accounts saved without one
+func (s *GoogleAccountService) identifyAccounts(ctx context.Context) {
+	for _, account := range s.GetAccounts() {
+		if account.Email != "" {
+			continue
+		}
+		token, err := s.loadToken(account.UserID)
+		if err != nil {
+			continue
+		}
+		email, err := s.primaryEmail(ctx, token)
+		if err != nil {
+			logrus.Warnf("Google accounts: failed to identify account %d: %v", account.UserID, err)
+			continue
+		}
+		s.update(account.UserID, func(stored *models.GoogleAccount) {
+			stored.Email = email
+		})
+	}
+}
+
+// GetAccounts returns every linked account, the primary one first
+func (s *GoogleAccountService) GetAccounts() []*models.GoogleAccount {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	accounts := make([]*models.GoogleAccount, 0, len(s.accounts))
+	for _, account := range s.accounts {
+		copied := *account
+		accounts = append(accounts, &copied)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].UserID < accounts[j].UserID
+	})
+	return accounts
+}
+
+// GetAccount returns a linked account by user ID
+func (s *GoogleAccountService) GetAccount(userID int) (*models.GoogleAccount, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	account, exists := s.accounts[userID]
+	if !exists {
+		return nil, false
+	}
+	copied := *account
+	return &copied, true
+}
+
+// LinkAccount saves the token of a Google account linked from the settings. An account linked
+// before is recognized by its address and keeps its user ID; a new one gets the next free user
+// ID, and the first account linked becomes the primary one.
+func (s *GoogleAccountService) LinkAccount(ctx context.Context, token *oauth2.Token) (*models.GoogleAccount, error) {
+	return s.link(ctx, token, true)
+}
+
+// SignIn saves the token of a Google account signing in to WoodHome. Only linked accounts may
+// sign in, apart from the first account, which becomes the primary one; further accounts are
+// linked from the settings by a signed-in household member.
+func (s *GoogleAccountService) SignIn(ctx context.Context, token *oauth2.Token) (*models.GoogleAccount, error) {
+	return s.link(ctx, token, false)
+}
+
+// link saves the token of an account, adding the account when allowNew is set or when no
+// account has been linked yet
+func (s *GoogleAccountService) link(ctx context.Context, token *oauth2.Token, allowNew bool) (*models.GoogleAccount, error) {
+	email, err := s.primaryEmail(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to identify Google account: %w", err)
+	}
+
+	s.linkMu.Lock()
+	defer s.linkMu.Unlock()
+
+	var account *models.GoogleAccount
+	var unidentified []*models.GoogleAccount
+	for _, existing := range s.GetAccounts() {
+		if strings.EqualFold(existing.Email, email) {
+			account = existing
+			break
+		}
+		if existing.Email == "" {
+			unidentified = append(unidentified, existing)
+		}
+	}
+	for _, existing := range unidentified {
+		if account != nil {
+			break
+		}
+		if s.replacesUnidentified(ctx, existing, email, allowNew) {
+			account = existing
+		}
+	}
+	if account == nil {
+		if !allowNew && len(s.GetAccounts()) > 0 {
+			return nil, fmt.Errorf("%s: %w", email, ErrGoogleAccountNotLinked)
+		}
+		account = &models.GoogleAccount{UserID: HouseholdUserID, Primary: true, LinkedAt: s.now()}
+		for _, existing := range s.GetAccounts() {
+			account.Primary = false
+			if existing.UserID >= account.UserID {
+				account.UserID = existing.UserID + 1
+			}
+		}
+	}
+	account.Email = email
+	account.LastError = ""
+
+	// Google only returns a refresh token the first time an account agrees to share its calendars
+	if token.RefreshToken == "" {
+		if previous, err := s.loadToken(account.UserID); err == nil && previous.RefreshToken != "" {
+			token.RefreshToken = previous.RefreshToken
+		}
+	}
+	if err := s.saveToken(account.UserID, token); err != nil {
+		return nil, fmt.Errorf("failed to save Google token: %w", err)
+	}
+	if err := s.save(*account); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.accounts[account.UserID] = account
+	s.mu.Unlock()
+
+	logrus.Infof("Google accounts: linked %s as user %d", email, account.UserID)
+	copied := *account
+	return &copied, nil
+}
+
+// replacesUnidentified reports whether an account of email takes over an account saved before
+// accounts had addresses. That is the case when the saved token turns out to belong to the same
+// address. A saved token that is missing or that Google rejects can never identify the account,
+// so a link from the settings may take it over, but a sign-in never does. When the token cannot
+// be checked, for example while Google is unreachable, the account is kept.
+func (s *GoogleAccountService) replacesUnidentified(ctx context.Context, account *models.GoogleAccount, email string, linking bool) bool {
+	token, err := s.loadToken(account.UserID)
+	if err != nil {
+		return linking
+	}
+	owner, err := s.primaryEmail(ctx, token)
+	if err != nil {
+		return linking && googleTokenRejected(err)
+	}
+	s.update(account.UserID, func(stored *models.GoogleAccount) {
+		stored.Email = owner
+	})
+	return strings.EqualFold(owner, email)
+}
+
+// googleTokenRejected reports whether Google refused a token, rather than failing to answer
+func googleTokenRejected(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		return retrieveErr.Response != nil && retrieveErr.Response.StatusCode < http.StatusInternalServerError
+	}
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusUnauthorized
+}
+
+// UnlinkAccount removes an account and its token. The primary account stays linked, as the
+// calendar sync and day checks use it.
+func (s *GoogleAccountService) UnlinkAccount(userID int) error {
+	account, exists := s.GetAccount(userID)
+	if !exists {
+		return fmt.Errorf("account %d: %w", userID, ErrGoogleAccountNotFound)
+	}
+	if account.Primary {
+		return fmt.Errorf("the primary Google account cannot be unlinked")
+	}
+
+	if token, err := s.loadToken(userID); err == nil && s.cacheService != nil {
+		s.cacheService.InvalidateAllCache(token)
+	}
+	if err := s.deleteToken(userID); err != nil {
+		return fmt.Errorf("failed to delete Google token: %w", err)
+	}
+	if s.db != nil {
+		if _, err := s.db.Exec(`DELETE FROM google_accounts WHERE user_id = ?`, userID); err != nil {
+			return fmt.Errorf("failed to delete Google account: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	delete(s.accounts, userID)
+	s.mu.Unlock()
+
+	logrus.Infof("Google accounts: unlinked %s", account.Email)
+	return nil
+}
+
+// GetCalendars lists the calendars of every linked account, each tagged with its account. It
+// fails only when no account could be read.
+func (s *GoogleAccountService) GetCalendars(ctx context.Context) ([]CalendarInfo, error) {
+	owned, err := s.ownedCalendars(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	calendars := []CalendarInfo{}
+	for _, account := range owned {
+		calendars = append(calendars, account.calendars...)
+	}
+	return calendars, nil
+}
+
+// GetEvents returns the events of every linked account from start to end, each tagged with
+// its account. When calendarIDs is not empty only those calendars are included.
+func (s *GoogleAccountService) GetEvents(ctx context.Context, start, end time.Time, calendarIDs []string) ([]CalendarEvent, error) {
+	owned, err := s.ownedCalendars(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]bool)
+	for _, id := range calendarIDs {
+		selected[id] = true
+	}
+
+	events := []CalendarEvent{}
+	var firstErr error
+	read := false
+	for _, account := range owned {
+		var ids []string
+		for _, cal := range account.calendars {
+			if len(selected) == 0 || selected[cal.ID] {
+				ids = append(ids, cal.ID)
+			}
+		}
+		if len(ids) == 0 {
+			read = true
+			continue
+		}
+
+		var accountEvents []CalendarEvent
+		if s.syncService != nil && s.syncService.Ready() && s.syncService.UserID() == account.UserID {
+			accountEvents = s.syncService.GetEvents(start, end, ids)
+		} else {
+			err := s.withToken(account.UserID, func(token *oauth2.Token) error {
+				var err error
+				accountEvents, err = s.listEvents(ctx, token, start, end, ids)
+				return err
+			})
+			if err != nil {
+				logrus.Warnf("Google accounts: failed to fetch events of %s: %v", account.Email, err)
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+		}
+
+		read = true
+		for _, event := range accountEvents {
+			event.Account = account.Email
+			events = append(events, event)
+		}
+	}
+	if !read && firstErr != nil {
+		return nil, firstErr
+	}
+	return events, nil
+}
+
+// ownedAccountCalendars is an account with the calendars read through it
+type ownedAccountCalendars struct {
+	models.GoogleAccount
+	calendars []CalendarInfo
+}
+
+// ownedCalendars lists the calendars of each account that could be read. A calendar shared
+// between accounts belongs to the one linked first.
+func (s *GoogleAccountService) ownedCalendars(ctx context.Context) ([]ownedAccountCalendars, error) {
+	accounts := s.GetAccounts()
+	if len(accounts) == 0 {
+		return nil, ErrNoGoogleAccounts
+	}
+
+	seen := make(map[string]bool)
+	var owned []ownedAccountCalendars
+	var firstErr error
+	for _, account := range accounts {
+		var list []CalendarInfo
+		err := s.withToken(account.UserID, func(token *oauth2.Token) error {
+			var err error
+			list, err = s.listCalendars(ctx, token)
+			return err
+		})
+		s.recordListing(account.UserID, len(list), err)
+		if err != nil {
+			logrus.Warnf("Google accounts: failed to list calendars of %s: %v", account.Email, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		entry := ownedAccountCalendars{GoogleAccount: *account, calendars: []CalendarInfo{}}
+		for _, cal := range list {
+			if seen[cal.ID] {
+				continue
+			}
+			seen[cal.ID] = true
+			cal.Account = account.Email
+			entry.calendars = append(entry.calendars, cal)
+		}
+		owned = append(owned, entry)
+	}
+	if len(owned) == 0 {
+		return nil, firstErr
+	}
+	return owned, nil
+}
+
+// withToken calls fn with an account's token and saves the token when fn refreshed it
+func (s *GoogleAccountService) withToken(userID int, fn func(token *oauth2.Token) error) error {
+	token, err := s.loadToken(userID)
+	if err != nil {
+		return fmt.Errorf("failed to load Google token: %w", err)
+	}
+	previous := *token
+	if err := fn(token); err != nil {
+		return err
+	}
+	if token.AccessToken != previous.AccessToken || !token.Expiry.Equal(previous.Expiry) {
+		if err := s.saveToken(userID, token); err != nil {
+			logrus.Warnf("Google accounts: failed to save refreshed token of user %d: %v", userID, err)
+		}
+	}
+	return nil
+}
+
+// recordListing notes the result of listing an account's calendars, saving the account when
+// its count or error changed
+func (s *GoogleAccountService) recordListing(userID, calendars int, err error) {
+	lastError := ""
+	if err != nil {
+		lastError = err.Error()
+	}
+	now := s.now()
+	s.update(userID, func(account *models.GoogleAccount) {
+		account.LastError = lastError
+		if err == nil {
+			account.Calendars = calendars
+			account.LastSync = &now
+		}
+	})
+}
+
+// update changes a stored account, saving it when more than its last sync changed
+func (s *GoogleAccountService) update(userID int, change func(account *models.GoogleAccount)) {
+	s.mu.Lock()
+	account, exists := s.accounts[userID]
+	if !exists {
+		s.mu.Unlock()
+		return
+	}
+	before := *account
+	change(account)
+	after := *account
+	s.mu.Unlock()
+
+	// Compare without the last sync, but save the copy taken under the lock
+	saved := after
+	before.LastSync, after.LastSync = nil, nil
+	if before != after {
+		if err := s.save(saved); err != nil {
+			logrus.Warnf("Google accounts: %v", err)
+		}
+	}
+}
+
+// googlePrimaryEmail identifies the account a token belongs to by its primary calendar, whose
+// ID is the account's address, so no scope beyond the calendar is needed
+func (s *GoogleAccountService) googlePrimaryEmail(ctx context.Context, token *oauth2.Token) (string, error) {
+	srv, err := s.calendarService.newGoogleService(ctx, token)
+	if err != nil {
+		return "", err
+	}
+	primary, err := srv.Calendars.Get("primary").Context(ctx).Do()
+	if err != nil {
+		return "", err
+	}
+	return primary.Id, nil
+}
+
+// load reads the linked accounts. A token saved before accounts were linked one by one
+// becomes the primary account.
+func (s *GoogleAccountService) load() error {
+	accounts := make(map[int]*models.GoogleAccount)
+	if s.db != nil {
+		_, err := s.db.Exec(`
+			CREATE TABLE IF NOT EXISTS google_accounts (
+				user_id INTEGER PRIMARY KEY,
+				data TEXT NOT NULL,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		rows, err := s.db.Query(`SELECT user_id, data FROM google_accounts`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var userID int
+			var data string
+			if err := rows.Scan(&userID, &data); err != nil {
+				return err
+			}
+			var account models.GoogleAccount
+			if err := json.Unmarshal([]byte(data), &account); err != nil {
+				logrus.Warnf("Google accounts: ignoring unreadable account %d: %v", userID, err)
+				continue
+			}
+			account.UserID = userID
+			accounts[userID] = &account
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	if len(accounts) == 0 {
+		if _, err := s.loadToken(HouseholdUserID); err == nil {
+			account := models.GoogleAccount{UserID: HouseholdUserID, Primary: true, LinkedAt: s.now()}
+			if err := s.save(account); err != nil {
+				return err
+			}
+			accounts[HouseholdUserID] = &account
+		}
+	}
+
+	s.mu.Lock()
+	s.accounts = accounts
+	s.mu.Unlock()
+
+	logrus.Infof("Google accounts: loaded %d accounts", len(accounts))
+	return nil
+}
+
+// save stores an account
+func (s *GoogleAccountService) save(account models.GoogleAccount) error {
+	if s.db == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(account)
+	if err != nil {
+		return fmt.Errorf("failed to marshal Google account: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+		INSERT OR REPLACE INTO google_accounts (user_id, data, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+	`, account.UserID, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save Google account: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"woodhome-webapp/internal/database"
+
+	"golang.org/x/oauth2"
+)
+
+func TestGoogleAccounts(t *testing.T) {
+	db, err := database.OpenSQLite(filepath.Join(t.TempDir(), "home.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// A token saved before several accounts could be linked
+	tokens := map[int]*oauth2.Token{
+		HouseholdUserID: {AccessToken: "dad", RefreshToken: "dad-refresh"},
+	}
+	emails := map[string]string{"dad": "dad@example.com", "mom": "mom@example.com", "mom-again": "mom@example.com"}
+	calendars := map[string][]CalendarInfo{
+		"dad@example.com": {{ID: "dad@example.com", Name: "Dad"}, {ID: "family", Name: "Family"}},
+		"mom@example.com": {{ID: "mom@example.com", Name: "Mom"}, {ID: "family", Name: "Family"}},
+	}
+	newGoogleAccountService := func() *GoogleAccountService {
+		service := NewGoogleAccountService(NewCalendarService(nil), db)
+		service.loadToken = func(userID int) (*oauth2.Token, error) {
+			token, exists := tokens[userID]
+			if !exists {
+				return nil, errors.New("no token")
+			}
+			copied := *token
+			return &copied, nil
+		}
+		service.saveToken = func(userID int, token *oauth2.Token) error {
+			copied := *token
+			tokens[userID] = &copied
+			return nil
+		}
+		service.deleteToken = func(userID int) error {
+			delete(tokens, userID)
+			return nil
+		}
+		service.primaryEmail = func(ctx context.Context, token *oauth2.Token) (string, error) {
+			return emails[token.AccessToken], nil
+		}
+		service.listCalendars = func(ctx context.Context, token *oauth2.Token) ([]CalendarInfo, error) {
+			return calendars[emails[token.AccessToken]], nil
+		}
+		service.listEvents = func(ctx context.Context, token *oauth2.Token, start, end time.Time, calendarIDs []string) ([]CalendarEvent, error) {
+			if token.AccessToken == "mom" {
+				// Refreshed while fetching
+				token.AccessToken = "mom-again"
+			}
+			var events []CalendarEvent
+			for _, id := range calendarIDs {
+				events = append(events, CalendarEvent{ID: id + "-event", CalendarID: id})
+			}
+			return events, nil
+		}
+		if err := service.load(); err != nil {
+			t.Fatalf("Failed to load accounts: %v", err)
+		}
+		return service
+	}
+	service := newGoogleAccountService()
+
+	accounts := service.GetAccounts()
+	if len(accounts) != 1 || accounts[0].UserID != HouseholdUserID || !accounts[0].Primary || accounts[0].Email != "" {
+		t.Fatalf("Expected the saved token to become the primary account, got %+v", accounts)
+	}
+	service.identifyAccounts(context.Background())
+	if account, _ := service.GetAccount(HouseholdUserID); account.Email != "dad@example.com" {
+		t.Fatalf("Expected the primary account to be identified, got %+v", account)
+	}
+
+	mom, err := service.LinkAccount(context.Background(), &oauth2.Token{AccessToken: "mom", RefreshToken: "mom-refresh"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if mom.UserID != 2 || mom.Primary || tokens[2].AccessToken != "mom" {
+		t.Fatalf("Unexpected account: %+v", mom)
+	}
+
+	// The family calendar both parents see is listed once, through the account linked first
+	list, err := service.GetCalendars(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(list) != 3 || list[1].ID != "family" || list[1].Account != "dad@example.com" || list[2].Account != "mom@example.com" {
+		t.Fatalf("Unexpected calendars: %+v", list)
+	}
+
+	events, err := service.GetEvents(context.Background(), time.Now(), time.Now().Add(24*time.Hour), []string{"family", "mom@example.com"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(events) != 2 || events[0].CalendarID != "family" || events[0].Account != "dad@example.com" || events[1].Account != "mom@example.com" {
+		t.Fatalf("Unexpected events: %+v", events)
+	}
+	if tokens[2].AccessToken != "mom-again" {
+		t.Fatal("Expected the refreshed token to be saved")
+	}
+
+	// Signing in again keeps the user ID and the refresh token Google only returns once
+	relinked, err := service.LinkAccount(context.Background(), &oauth2.Token{AccessToken: "mom-again"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if relinked.UserID != 2 || tokens[2].RefreshToken != "mom-refresh" || len(service.GetAccounts()) != 2 {
+		t.Fatalf("Unexpected relinked account: %+v", relinked)
+	}
+
+	// Signing in only accepts the linked accounts
+	emails["kid"] = "kid@example.com"
+	if _, err := service.SignIn(context.Background(), &oauth2.Token{AccessToken: "kid"}); !errors.Is(err, ErrGoogleAccountNotLinked) {
+		t.Fatalf("Expected an unlinked account to be refused, got %v", err)
+	}
+	if _, exists := tokens[3]; exists || len(service.GetAccounts()) != 2 {
+		t.Fatal("Expected the refused account not to be saved")
+	}
+	if account, err := service.SignIn(context.Background(), &oauth2.Token{AccessToken: "mom-again"}); err != nil || account.UserID != 2 {
+		t.Fatalf("Expected a linked account to sign in, got %+v (%v)", account, err)
+	}
+
+	// Accounts survive a restart, and only the other accounts can be unlinked
+	service = newGoogleAccountService()
+	if accounts := service.GetAccounts(); len(accounts) != 2 || accounts[1].Email != "mom@example.com" || accounts[1].Calendars != 2 {
+		t.Fatalf("Unexpected accounts after a restart: %+v", accounts)
+	}
+	if err := service.UnlinkAccount(HouseholdUserID); err == nil {
+		t.Fatal("Expected the primary account to be kept")
+	}
+	if err := service.UnlinkAccount(2); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, exists := tokens[2]; exists {
+		t.Fatal("Expected the token to be deleted")
+	}
+	if err := service.UnlinkAccount(2); !errors.Is(err, ErrGoogleAccountNotFound) {
+		t.Fatalf("Expected the account to be gone, got %v", err)
+	}
+	if service = newGoogleAccountService(); len(service.GetAccounts()) != 1 {
+		t.Fatal("Expected only the primary account to be left")
+	}
+}
+
+func TestGoogleAccountsKeepUnidentifiedAccounts(t *testing.T) {
+	tokens := map[int]*oauth2.Token{
+		HouseholdUserID: {AccessToken: "dad", RefreshToken: "dad-refresh"},
+	}
+	var identifyErr error
+	service := NewGoogleAccountService(NewCalendarService(nil), nil)
+	service.loadToken = func(userID int) (*oauth2.Token, error) {
+		token, exists := tokens[userID]
+		if !exists {
+			return nil, errors.New("no token")
+		}
+		copied := *token
+		return &copied, nil
+	}
+	service.saveToken = func(userID int, token *oauth2.Token) error {
+		copied := *token
+		tokens[userID] = &copied
+		return nil
+	}
+	service.primaryEmail = func(ctx context.Context, token *oauth2.Token) (string, error) {
+		if token.AccessToken == "dad" && identifyErr != nil {
+			return "", identifyErr
+		}
+		return token.AccessToken + "@example.com", nil
+	}
+	if err := service.load(); err != nil {
+		t.Fatalf("Failed to load accounts: %v", err)
+	}
+
+	// While Google cannot be reached the saved account is kept and the new one gets its own ID
+	identifyErr = errors.New("dial tcp: network is unreachable")
+	mom, err := service.LinkAccount(context.Background(), &oauth2.Token{AccessToken: "mom"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if mom.UserID != 2 || tokens[HouseholdUserID].AccessToken != "dad" {
+		t.Fatalf("Expected the primary token to be kept, got %+v", mom)
+	}
+
+	// A token Google refuses can never be identified, but signing in never takes the account over
+	identifyErr = &oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusBadRequest}, ErrorCode: "invalid_grant"}
+	if _, err := service.SignIn(context.Background(), &oauth2.Token{AccessToken: "stranger"}); !errors.Is(err, ErrGoogleAccountNotLinked) {
+		t.Fatalf("Expected a foreign account to be refused, got %v", err)
+	}
+	if _, exists := tokens[3]; exists || tokens[HouseholdUserID].AccessToken != "dad" {
+		t.Fatal("Expected the refused sign-in not to be saved")
+	}
+
+	// Linking from the settings lets the next new account take its place
+	grandma, err := service.LinkAccount(context.Background(), &oauth2.Token{AccessToken: "grandma"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if grandma.UserID != HouseholdUserID || !grandma.Primary || len(service.GetAccounts()) != 2 {
+		t.Fatalf("Expected the unusable primary account to be replaced, got %+v", grandma)
+	}
+}
+
+func TestGoogleAccountsFirstSignIn(t *testing.T) {
+	tokens := make(map[int]*oauth2.Token)
+	service := NewGoogleAccountService(NewCalendarService(nil), nil)
+	service.loadToken = func(userID int) (*oauth2.Token, error) {
+		return nil, errors.New("no token")
+	}
+	service.saveToken = func(userID int, token *oauth2.Token) error {
+		tokens[userID] = token
+		return nil
+	}
+	service.primaryEmail = func(ctx context.Context, token *oauth2.Token) (string, error) {
+		return token.AccessToken + "@example.com", nil
+	}
+	if err := service.load(); err != nil {
+		t.Fatalf("Failed to load accounts: %v", err)
+	}
+
+	// The first account to sign in becomes the primary one
+	dad, err := service.SignIn(context.Background(), &oauth2.Token{AccessToken: "dad"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if dad.UserID != HouseholdUserID || !dad.Primary || tokens[HouseholdUserID] == nil {
+		t.Fatalf("Unexpected account: %+v", dad)
+	}
+	if _, err := service.SignIn(context.Background(), &oauth2.Token{AccessToken: "mom"}); !errors.Is(err, ErrGoogleAccountNotLinked) {
+		t.Fatalf("Expected a second account to need linking, got %v", err)
+	}
+}
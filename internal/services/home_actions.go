@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"woodhome-webapp/internal/models"
+)
+
+// homeActionMaxDelay caps the wait before a single step
+const homeActionMaxDelay = 10 * time.Minute
+
+// homeActions runs the Hue and Sonos steps shared by home scenes and routines
+type homeActions struct {
+	hueService   *HueService
+	sonosService *SonosService
+}
+
+// runStep performs a single step
+func (a *homeActions) runStep(ctx context.Context, step models.HomeSceneStep) error {
+	switch step.Kind {
+	case models.HomeSceneStepHueScene, models.HomeSceneStepHueGroup, models.HomeSceneStepHueLight:
+		if a.hueService == nil {
+			return fmt.Errorf("Hue is not available")
+		}
+	case models.HomeSceneStepSonos:
+		if a.sonosService == nil {
+			return fmt.Errorf("Sonos is not available")
+		}
+	}
+
+	switch step.Kind {
+	case models.HomeSceneStepHueScene:
+		return a.hueService.ActivateScene(step.Target)
+	case models.HomeSceneStepHueGroup:
+		return a.hueService.SetGroupState(step.Target, step.State)
+	case models.HomeSceneStepHueLight:
+		return a.hueService.SetLightState(step.Target, lightStateOf(step.State))
+	case models.HomeSceneStepSonos:
+		return a.runSonosAction(ctx, step)
+	}
+	return fmt.Errorf("unknown step kind %q", step.Kind)
+}
+
+// runSonosAction performs the Sonos action of a step
+func (a *homeActions) runSonosAction(ctx context.Context, step models.HomeSceneStep) error {
+	room := step.Target
+
+	switch step.Action {
+	case models.HomeSceneSonosPlay:
+		return a.sonosService.PlayDevice(ctx, room)
+	case models.HomeSceneSonosPause:
+		return a.sonosService.PauseDevice(ctx, room)
+	case models.HomeSceneSonosStop:
+		return a.sonosService.StopDevice(ctx, room)
+	case models.HomeSceneSonosVolume:
+		volume, _ := strconv.Atoi(step.Value)
+		return a.sonosService.SetVolume(ctx, room, volume)
+	case models.HomeSceneSonosMute:
+		return a.sonosService.SetMute(ctx, room, step.Value == "on")
+	case models.HomeSceneSonosLineIn:
+		return a.sonosService.SetLineIn(ctx, room, step.Value)
+	case models.HomeSceneSonosNightMode:
+		return a.sonosService.SetNightMode(ctx, room, step.Value == "on")
+	case models.HomeSceneSonosFavorite:
+		return a.sonosService.PlayFavorite(ctx, room, step.Value)
+	case models.HomeSceneSonosJoin:
+		return a.sonosService.JoinGroup(ctx, room, step.Value)
+	case models.HomeSceneSonosLeave:
+		return a.sonosService.LeaveGroup(ctx, room)
+	case models.HomeSceneSonosPauseOthers:
+		for _, other := range a.otherPlayingCoordinators(room) {
+			if err := a.sonosService.PauseGroup(ctx, other); err != nil {
+				return fmt.Errorf("failed to pause %s: %w", other, err)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown Sonos action %q", step.Action)
+}
+
+// otherPlayingCoordinators returns the rooms coordinating a playing group other than the group of room
+func (a *homeActions) otherPlayingCoordinators(room string) []string {
+	devices := a.sonosService.GetDevices()
+
+	keepGroup := ""
+	for _, device := range devices {
+		if device.Room == room {
+			keepGroup = device.GroupID
+		}
+	}
+
+	var rooms []string
+	for _, device := range devices {
+		if device.Room == room || (keepGroup != "" && device.GroupID == keepGroup) {
+			continue
+		}
+		if device.IsCoordinator() && device.State == "PLAYING" {
+			rooms = append(rooms, device.Room)
+		}
+	}
+	sort.Strings(rooms)
+	return rooms
+}
+
+// validateHomeSceneStep checks a single step
+func validateHomeSceneStep(step *models.HomeSceneStep) error {
+	if step.Target == "" {
+		return fmt.Errorf("target is required")
+	}
+	if step.DelayMs < 0 || time.Duration(step.DelayMs)*time.Millisecond > homeActionMaxDelay {
+		return fmt.Errorf("delay must be between 0 and %d ms", homeActionMaxDelay.Milliseconds())
+	}
+
+	switch step.Kind {
+	case models.HomeSceneStepHueScene:
+	case models.HomeSceneStepHueGroup, models.HomeSceneStepHueLight:
+		if step.State == nil {
+			return fmt.Errorf("%s steps need a state", step.Kind)
+		}
+	case models.HomeSceneStepSonos:
+		return validateSonosAction(step)
+	default:
+		return fmt.Errorf("kind must be %s, %s, %s or %s", models.HomeSceneStepHueScene, models.HomeSceneStepHueGroup,
+			models.HomeSceneStepHueLight, models.HomeSceneStepSonos)
+	}
+	return nil
+}
+
+// validateSonosAction checks the action and value of a Sonos step
+func validateSonosAction(step *models.HomeSceneStep) error {
+	switch step.Action {
+	case models.HomeSceneSonosPlay, models.HomeSceneSonosPause, models.HomeSceneSonosStop,
+		models.HomeSceneSonosLineIn, models.HomeSceneSonosLeave, models.HomeSceneSonosPauseOthers:
+	case models.HomeSceneSonosVolume:
+		volume, err := strconv.Atoi(step.Value)
+		if err != nil || volume < 0 || volume > 100 {
+			return fmt.Errorf("volume must be between 0 and 100")
+		}
+	case models.HomeSceneSonosMute, models.HomeSceneSonosNightMode:
+		if step.Value != "on" && step.Value != "off" {
+			return fmt.Errorf("%s value must be on or off", step.Action)
+		}
+	case models.HomeSceneSonosFavorite, models.HomeSceneSonosJoin:
+		if step.Value == "" {
+			return fmt.Errorf("%s needs a value", step.Action)
+		}
+	default:
+		return fmt.Errorf("unknown Sonos action %q", step.Action)
+	}
+	return nil
+}
+
+// lightStateOf converts a group action into the same state for a single light
+func lightStateOf(state *models.HueGroupState) *models.HueLightState {
+	return &models.HueLightState{
+		On:             state.On,
+		Brightness:     state.Brightness,
+		Hue:            state.Hue,
+		Saturation:     state.Saturation,
+		ColorTemp:      state.ColorTemp,
+		XY:             state.XY,
+		Effect:         state.Effect,
+		Alert:          state.Alert,
+		TransitionTime: state.TransitionTime,
+	}
+}
+
+// sleepContext waits for d or until the context ends
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
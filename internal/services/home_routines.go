@@ -0,0 +1,785 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"woodhome-webapp/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	homeRoutineMaxWait    = 24 * time.Hour
+	homeRoutineMaxFade    = 6553 * time.Second // Longest Hue transition time
+	homeRoutineRecentRuns = 20                 // Finished runs kept for reporting
+)
+
+// homeRoutineExecution is a run held in memory. Its fields are guarded by the service lock.
+type homeRoutineExecution struct {
+	run       *models.HomeRoutineRun
+	done      map[string]bool
+	current   map[string]models.HomeRoutineCurrentStep
+	ctx       context.Context
+	cancel    context.CancelFunc
+	cancelled bool // Cancelled by a user rather than by the service stopping
+	finished  chan struct{}
+	saveMu    sync.Mutex // Keeps the saves of a run in order
+}
+
+// HomeRoutineService runs routines of Hue and Sonos steps with waits and parallel branches.
+// Running routines are saved after every step so they can be resumed or aborted after a restart.
+type HomeRoutineService struct {
+	homeActions
+	db       *sql.DB
+	eventBus *EventBus
+	routines map[string]*models.HomeRoutine
+	runs     map[string]*homeRoutineExecution
+	ctx      context.Context // Lifetime of the service, runs stop with it and stay saved
+	now      func() time.Time
+	sleep    func(ctx context.Context, d time.Duration) error
+	mu       sync.Mutex
+}
+
+// NewHomeRoutineService creates a new HomeRoutineService instance.
+// Routines and runs are kept in memory only when db is nil.
+func NewHomeRoutineService(hueService *HueService, sonosService *SonosService, db *sql.DB) *HomeRoutineService {
+	return &HomeRoutineService{
+		homeActions: homeActions{hueService: hueService, sonosService: sonosService},
+		db:          db,
+		routines:    make(map[string]*models.HomeRoutine),
+		runs:        make(map[string]*homeRoutineExecution),
+		ctx:         context.Background(),
+		now:         time.Now,
+		sleep:       sleepContext,
+	}
+}
+
+// SetEventBus sets the bus that routine runs are published on
+func (s *HomeRoutineService) SetEventBus(eventBus *EventBus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.eventBus = eventBus
+}
+
+// Start loads the saved routines and resumes or aborts the runs left by the last shutdown
+func (s *HomeRoutineService) Start(ctx context.Context) error {
+	logrus.Info("Starting home routine service...")
+
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return fmt.Errorf("failed to load home routines: %w", err)
+	}
+	runs, err := s.loadRuns()
+	if err != nil {
+		return fmt.Errorf("failed to load running routines: %w", err)
+	}
+	for _, run := range runs {
+		s.recover(run)
+	}
+	return nil
+}
+
+// GetRoutines returns every routine sorted by name
+func (s *HomeRoutineService) GetRoutines() []*models.HomeRoutine {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	routines := make([]*models.HomeRoutine, 0, len(s.routines))
+	for _, routine := range s.routines {
+		copied := *routine
+		routines = append(routines, &copied)
+	}
+	sort.Slice(routines, func(i, j int) bool {
+		return routines[i].Name < routines[j].Name
+	})
+	return routines
+}
+
+// GetRoutine returns a single routine
+func (s *HomeRoutineService) GetRoutine(id string) (*models.HomeRoutine, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	routine, exists := s.routines[id]
+	if !exists {
+		return nil, false
+	}
+	copied := *routine
+	return &copied, true
+}
+
+// CreateRoutine validates and saves a new routine
+func (s *HomeRoutineService) CreateRoutine(routine models.HomeRoutine) (*models.HomeRoutine, error) {
+	if err := validateHomeRoutine(&routine); err != nil {
+		return nil, err
+	}
+	routine.ID = uuid.NewString()
+	routine.UpdatedAt = s.now()
+
+	if err := s.save(routine); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routines[routine.ID] = &routine
+
+	logrus.Infof("Home routines: created routine %q with %d steps", routine.Name, countRoutineSteps(routine.Steps))
+	copied := routine
+	return &copied, nil
+}
+
+// UpdateRoutine replaces a routine. Runs that already started keep the old steps.
+func (s *HomeRoutineService) UpdateRoutine(id string, routine models.HomeRoutine) (*models.HomeRoutine, error) {
+	if _, exists := s.GetRoutine(id); !exists {
+		return nil, fmt.Errorf("routine %s not found", id)
+	}
+	if err := validateHomeRoutine(&routine); err != nil {
+		return nil, err
+	}
+	routine.ID = id
+	routine.UpdatedAt = s.now()
+
+	if err := s.save(routine); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routines[id] = &routine
+
+	copied := routine
+	return &copied, nil
+}
+
+// DeleteRoutine removes a routine. A run that already started carries on.
+func (s *HomeRoutineService) DeleteRoutine(id string) error {
+	s.mu.Lock()
+	if _, exists := s.routines[id]; !exists {
+		s.mu.Unlock()
+		return fmt.Errorf("routine %s not found", id)
+	}
+	delete(s.routines, id)
+	s.mu.Unlock()
+
+	if s.db != nil {
+		if _, err := s.db.Exec(`DELETE FROM home_routines WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete routine: %w", err)
+		}
+	}
+	return nil
+}
+
+// Run starts a routine in the background and returns its run
+func (s *HomeRoutineService) Run(id string) (*models.HomeRoutineRun, error) {
+	s.mu.Lock()
+	routine, exists := s.routines[id]
+	if !exists {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("routine %s not found", id)
+	}
+	for _, exec := range s.runs {
+		if exec.run.RoutineID == id && exec.run.Status == models.HomeRoutineRunning {
+			s.mu.Unlock()
+			return nil, fmt.Errorf("routine %s is already running", routine.Name)
+		}
+	}
+
+	exec := s.register(&models.HomeRoutineRun{
+		ID:          uuid.NewString(),
+		RoutineID:   id,
+		RoutineName: routine.Name,
+		Steps:       routine.Steps,
+		OnRestart:   routine.OnRestart,
+		Status:      models.HomeRoutineRunning,
+		StartedAt:   s.now(),
+		Total:       countRoutineSteps(routine.Steps),
+		Completed:   []string{},
+		WaitUntil:   make(map[string]time.Time),
+		Results:     []models.HomeRoutineStepResult{},
+	})
+	s.publish(exec.run)
+	run := s.snapshot(exec)
+	s.mu.Unlock()
+
+	logrus.Infof("Home routines: running %q", routine.Name)
+	s.persist(exec)
+	go s.execute(exec)
+	return run, nil
+}
+
+// Cancel stops a running routine. Steps that have not started are not run.
+func (s *HomeRoutineService) Cancel(runID string) (*models.HomeRoutineRun, error) {
+	s.mu.Lock()
+	exec, exists := s.runs[runID]
+	if !exists {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("run %s not found", runID)
+	}
+	if exec.run.Status != models.HomeRoutineRunning {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("run %s is not running", runID)
+	}
+	exec.cancelled = true
+	s.mu.Unlock()
+
+	exec.cancel()
+	<-exec.finished
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshot(exec), nil
+}
+
+// GetRuns returns the running and recently finished runs, newest first
+func (s *HomeRoutineService) GetRuns() []*models.HomeRoutineRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runs := make([]*models.HomeRoutineRun, 0, len(s.runs))
+	for _, exec := range s.runs {
+		runs = append(runs, s.snapshot(exec))
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].StartedAt.After(runs[j].StartedAt)
+	})
+	return runs
+}
+
+// GetRun returns a single run
+func (s *HomeRoutineService) GetRun(runID string) (*models.HomeRoutineRun, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exec, exists := s.runs[runID]
+	if !exists {
+		return nil, false
+	}
+	return s.snapshot(exec), true
+}
+
+// recover resumes or aborts a run that was in flight when the server stopped
+func (s *HomeRoutineService) recover(run *models.HomeRoutineRun) {
+	if run.WaitUntil == nil {
+		run.WaitUntil = make(map[string]time.Time)
+	}
+
+	s.mu.Lock()
+	exec := s.register(run)
+	if run.OnRestart != models.HomeRoutineRestartResume {
+		s.mu.Unlock()
+		logrus.Infof("Home routines: aborting %q, which was running when the server stopped", run.RoutineName)
+		exec.cancel()
+		s.finish(exec, models.HomeRoutineAborted)
+		return
+	}
+	run.Resumed++
+	s.mu.Unlock()
+
+	logrus.Infof("Home routines: resuming %q after %d of %d steps", run.RoutineName, run.Done, run.Total)
+	s.persist(exec)
+	go s.execute(exec)
+}
+
+// register adds a run to the service. Callers must hold the lock.
+func (s *HomeRoutineService) register(run *models.HomeRoutineRun) *homeRoutineExecution {
+	exec := &homeRoutineExecution{
+		run:      run,
+		done:     make(map[string]bool),
+		current:  make(map[string]models.HomeRoutineCurrentStep),
+		finished: make(chan struct{}),
+	}
+	for _, path := range run.Completed {
+		exec.done[path] = true
+	}
+	exec.ctx, exec.cancel = context.WithCancel(s.ctx)
+	s.runs[run.ID] = exec
+	return exec
+}
+
+// execute runs the steps of a run and records how it ended
+func (s *HomeRoutineService) execute(exec *homeRoutineExecution) {
+	defer exec.cancel()
+
+	err := s.runSteps(exec.ctx, exec, exec.run.Steps, "")
+
+	s.mu.Lock()
+	cancelled := exec.cancelled
+	s.mu.Unlock()
+
+	switch {
+	case err == nil:
+		s.finish(exec, models.HomeRoutineCompleted)
+	case cancelled:
+		s.finish(exec, models.HomeRoutineCancelled)
+	default:
+		// The service is stopping; the run stays saved as running for the next start
+		logrus.Infof("Home routines: %q stopped with the service", exec.run.RoutineName)
+		close(exec.finished)
+	}
+}
+
+// runSteps runs steps in order, skipping those a resumed run already finished.
+// It only fails when the context ends.
+func (s *HomeRoutineService) runSteps(ctx context.Context, exec *homeRoutineExecution, steps []models.HomeRoutineStep, prefix string) error {
+	for i, step := range steps {
+		path := routineStepPath(prefix, i)
+
+		s.mu.Lock()
+		done := exec.done[path]
+		s.mu.Unlock()
+		if done {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := s.runRoutineStep(ctx, exec, step, path); err != nil {
+			return err
+		}
+		s.complete(exec, step, path)
+	}
+	return nil
+}
+
+// runRoutineStep runs a single step of the graph
+func (s *HomeRoutineService) runRoutineStep(ctx context.Context, exec *homeRoutineExecution, step models.HomeRoutineStep, path string) error {
+	switch step.Kind {
+	case models.HomeRoutineStepParallel:
+		var wg sync.WaitGroup
+		errs := make([]error, len(step.Branches))
+		for b, branch := range step.Branches {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[b] = s.runSteps(ctx, exec, branch, path+"."+strconv.Itoa(b))
+			}()
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case models.HomeRoutineStepWait:
+		return s.wait(ctx, exec, step, path)
+
+	case models.HomeRoutineStepFade:
+		// A fade that started before a restart is left to finish on the bridge
+		s.mu.Lock()
+		_, started := exec.run.WaitUntil[path]
+		s.mu.Unlock()
+		if !started {
+			state := *step.State
+			transition := step.DurationSec * 10
+			state.TransitionTime = &transition
+			fade := step.HomeSceneStep
+			fade.Kind = models.HomeSceneStepHueGroup
+			fade.State = &state
+			s.runAction(ctx, exec, step, path, fade)
+		}
+		return s.wait(ctx, exec, step, path)
+	}
+
+	if step.DelayMs > 0 {
+		s.setCurrent(exec, step, path, nil)
+		if err := s.sleep(ctx, time.Duration(step.DelayMs)*time.Millisecond); err != nil {
+			return err
+		}
+	}
+	s.runAction(ctx, exec, step, path, step.HomeSceneStep)
+	return nil
+}
+
+// runAction performs a Hue or Sonos action and records its result. A failed action does not stop the routine.
+func (s *HomeRoutineService) runAction(ctx context.Context, exec *homeRoutineExecution, step models.HomeRoutineStep, path string, action models.HomeSceneStep) {
+	s.setCurrent(exec, step, path, nil)
+
+	result := models.HomeRoutineStepResult{
+		Path:   path,
+		Kind:   step.Kind,
+		Target: step.Target,
+		Action: step.Action,
+		Label:  step.Label,
+		Result: models.HomeSceneResultOK,
+	}
+	if err := s.runStep(ctx, action); err != nil {
+		logrus.Warnf("Home routines: step %s (%s %s) of %q failed: %v", path, step.Kind, step.Target, exec.run.RoutineName, err)
+		result.Result = models.HomeSceneResultFailed
+		result.Error = err.Error()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result.At = s.now()
+	exec.run.Results = append(exec.run.Results, result)
+	if result.Result == models.HomeSceneResultFailed {
+		exec.run.Failed++
+	}
+}
+
+// wait holds a branch until the end of a wait or fade. The end is saved so a resumed run
+// only waits for what is left.
+func (s *HomeRoutineService) wait(ctx context.Context, exec *homeRoutineExecution, step models.HomeRoutineStep, path string) error {
+	s.mu.Lock()
+	until, started := exec.run.WaitUntil[path]
+	if !started {
+		until = s.now().Add(time.Duration(step.DurationSec) * time.Second)
+		exec.run.WaitUntil[path] = until
+	}
+	s.mu.Unlock()
+
+	s.setCurrent(exec, step, path, &until)
+	if !started {
+		s.persist(exec)
+	}
+
+	if remaining := until.Sub(s.now()); remaining > 0 {
+		return s.sleep(ctx, remaining)
+	}
+	return nil
+}
+
+// setCurrent reports a step as running
+func (s *HomeRoutineService) setCurrent(exec *homeRoutineExecution, step models.HomeRoutineStep, path string, until *time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exec.current[path] = models.HomeRoutineCurrentStep{
+		Path:   path,
+		Kind:   step.Kind,
+		Target: step.Target,
+		Label:  step.Label,
+		Until:  until,
+	}
+}
+
+// complete marks a step as finished and saves the run
+func (s *HomeRoutineService) complete(exec *homeRoutineExecution, step models.HomeRoutineStep, path string) {
+	s.mu.Lock()
+	delete(exec.current, path)
+	delete(exec.run.WaitUntil, path)
+	exec.done[path] = true
+	exec.run.Completed = append(exec.run.Completed, path)
+	if step.Kind != models.HomeRoutineStepParallel {
+		exec.run.Done++
+	}
+	s.mu.Unlock()
+
+	s.persist(exec)
+}
+
+// finish records how a run ended and forgets the saved run
+func (s *HomeRoutineService) finish(exec *homeRoutineExecution, status string) {
+	s.mu.Lock()
+	finishedAt := s.now()
+	exec.run.Status = status
+	exec.run.FinishedAt = &finishedAt
+	exec.current = make(map[string]models.HomeRoutineCurrentStep)
+	s.publish(exec.run)
+	s.trimRuns()
+	id, name, failed := exec.run.ID, exec.run.RoutineName, exec.run.Failed
+	s.mu.Unlock()
+
+	logrus.Infof("Home routines: %q %s with %d failed steps", name, status, failed)
+	if s.db != nil {
+		if _, err := s.db.Exec(`DELETE FROM home_routine_runs WHERE id = ?`, id); err != nil {
+			logrus.Warnf("Home routines: failed to remove saved run %s: %v", id, err)
+		}
+	}
+	close(exec.finished)
+}
+
+// trimRuns drops the oldest finished runs. Callers must hold the lock.
+func (s *HomeRoutineService) trimRuns() {
+	var finished []*models.HomeRoutineRun
+	for _, exec := range s.runs {
+		if exec.run.Status != models.HomeRoutineRunning {
+			finished = append(finished, exec.run)
+		}
+	}
+	if len(finished) <= homeRoutineRecentRuns {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].FinishedAt.After(*finished[j].FinishedAt)
+	})
+	for _, run := range finished[homeRoutineRecentRuns:] {
+		delete(s.runs, run.ID)
+	}
+}
+
+// snapshot returns a copy of a run with its progress. Callers must hold the lock.
+func (s *HomeRoutineService) snapshot(exec *homeRoutineExecution) *models.HomeRoutineRun {
+	run := *exec.run
+	run.Completed = append([]string{}, exec.run.Completed...)
+	run.Results = append([]models.HomeRoutineStepResult{}, exec.run.Results...)
+	run.WaitUntil = make(map[string]time.Time, len(exec.run.WaitUntil))
+	for path, until := range exec.run.WaitUntil {
+		run.WaitUntil[path] = until
+	}
+
+	run.Current = make([]models.HomeRoutineCurrentStep, 0, len(exec.current))
+	for _, current := range exec.current {
+		run.Current = append(run.Current, current)
+	}
+	sort.Slice(run.Current, func(i, j int) bool {
+		return run.Current[i].Path < run.Current[j].Path
+	})
+
+	run.Percent = 100
+	if run.Total > 0 {
+		run.Percent = run.Done * 100 / run.Total
+	}
+	return &run
+}
+
+// publish reports a run on the event bus. Callers must hold the lock.
+func (s *HomeRoutineService) publish(run *models.HomeRoutineRun) {
+	if s.eventBus == nil {
+		return
+	}
+	s.eventBus.Publish(models.EventHomeRoutine, run.RoutineID, map[string]interface{}{
+		"routine": run.RoutineName,
+		"run":     run.ID,
+		"status":  run.Status,
+		"done":    run.Done,
+		"total":   run.Total,
+		"failed":  run.Failed,
+	})
+}
+
+// load reads the saved routines
+func (s *HomeRoutineService) load() error {
+	if s.db == nil {
+		return nil
+	}
+
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS home_routines (
+			id TEXT PRIMARY KEY,
+			data TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.db.Query(`SELECT id, data FROM home_routines`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for rows.Next() {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			return err
+		}
+		var routine models.HomeRoutine
+		if err := json.Unmarshal([]byte(data), &routine); err != nil {
+			logrus.Warnf("Home routines: ignoring unreadable routine %s: %v", id, err)
+			continue
+		}
+		routine.ID = id
+		s.routines[id] = &routine
+	}
+	logrus.Infof("Home routines: loaded %d routines", len(s.routines))
+	return rows.Err()
+}
+
+// save stores a routine
+func (s *HomeRoutineService) save(routine models.HomeRoutine) error {
+	if s.db == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(routine)
+	if err != nil {
+		return fmt.Errorf("failed to marshal routine: %w", err)
+	}
+	_, err = s.db.Exec(`
+		INSERT OR REPLACE INTO home_routines (id, data, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+	`, routine.ID, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save routine: %w", err)
+	}
+	return nil
+}
+
+// loadRuns reads the runs that were in flight when the server stopped
+func (s *HomeRoutineService) loadRuns() ([]*models.HomeRoutineRun, error) {
+	if s.db == nil {
+		return nil, nil
+	}
+
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS home_routine_runs (
+			id TEXT PRIMARY KEY,
+			data TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`SELECT id, data FROM home_routine_runs`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []*models.HomeRoutineRun
+	for rows.Next() {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, err
+		}
+		var run models.HomeRoutineRun
+		if err := json.Unmarshal([]byte(data), &run); err != nil {
+			logrus.Warnf("Home routines: ignoring unreadable run %s: %v", id, err)
+			continue
+		}
+		run.ID = id
+		runs = append(runs, &run)
+	}
+	return runs, rows.Err()
+}
+
+// persist saves the progress of a run. Failures are logged, the run carries on.
+func (s *HomeRoutineService) persist(exec *homeRoutineExecution) {
+	if s.db == nil {
+		return
+	}
+
+	exec.saveMu.Lock()
+	defer exec.saveMu.Unlock()
+
+	s.mu.Lock()
+	id := exec.run.ID
+	data, err := json.Marshal(exec.run)
+	s.mu.Unlock()
+	if err != nil {
+		logrus.Warnf("Home routines: failed to marshal run %s: %v", id, err)
+		return
+	}
+
+	_, err = s.db.Exec(`
+		INSERT OR REPLACE INTO home_routine_runs (id, data, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+	`, id, string(data))
+	if err != nil {
+		logrus.Warnf("Home routines: failed to save run %s: %v", id, err)
+	}
+}
+
+// validateHomeRoutine checks the step graph of a routine
+func validateHomeRoutine(routine *models.HomeRoutine) error {
+	if routine.Name == "" {
+		return fmt.Errorf("routine name is required")
+	}
+	if len(routine.Steps) == 0 {
+		return fmt.Errorf("a routine needs at least one step")
+	}
+
+	switch routine.OnRestart {
+	case "":
+		routine.OnRestart = models.HomeRoutineRestartAbort
+	case models.HomeRoutineRestartResume, models.HomeRoutineRestartAbort:
+	default:
+		return fmt.Errorf("on_restart must be %s or %s", models.HomeRoutineRestartResume, models.HomeRoutineRestartAbort)
+	}
+	return validateRoutineSteps(routine.Steps)
+}
+
+// validateRoutineSteps checks a list of steps and the branches inside them
+func validateRoutineSteps(steps []models.HomeRoutineStep) error {
+	for i := range steps {
+		if err := validateRoutineStep(&steps[i]); err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// validateRoutineStep checks a single step
+func validateRoutineStep(step *models.HomeRoutineStep) error {
+	if step.Kind != models.HomeRoutineStepParallel && len(step.Branches) > 0 {
+		return fmt.Errorf("only %s steps have branches", models.HomeRoutineStepParallel)
+	}
+
+	switch step.Kind {
+	case models.HomeRoutineStepWait:
+		if step.DurationSec <= 0 || time.Duration(step.DurationSec)*time.Second > homeRoutineMaxWait {
+			return fmt.Errorf("wait duration must be between 1 and %d seconds", int(homeRoutineMaxWait.Seconds()))
+		}
+	case models.HomeRoutineStepFade:
+		if step.Target == "" {
+			return fmt.Errorf("target is required")
+		}
+		if step.State == nil {
+			return fmt.Errorf("fade steps need a state")
+		}
+		if step.DurationSec <= 0 || time.Duration(step.DurationSec)*time.Second > homeRoutineMaxFade {
+			return fmt.Errorf("fade duration must be between 1 and %d seconds", int(homeRoutineMaxFade.Seconds()))
+		}
+	case models.HomeRoutineStepParallel:
+		if len(step.Branches) == 0 {
+			return fmt.Errorf("parallel steps need at least one branch")
+		}
+		for b, branch := range step.Branches {
+			if len(branch) == 0 {
+				return fmt.Errorf("branch %d is empty", b+1)
+			}
+			if err := validateRoutineSteps(branch); err != nil {
+				return fmt.Errorf("branch %d: %w", b+1, err)
+			}
+		}
+	default:
+		return validateHomeSceneStep(&step.HomeSceneStep)
+	}
+	return nil
+}
+
+// countRoutineSteps counts the steps that report progress, leaving out parallel steps themselves
+func countRoutineSteps(steps []models.HomeRoutineStep) int {
+	count := 0
+	for _, step := range steps {
+		if step.Kind == models.HomeRoutineStepParallel {
+			for _, branch := range step.Branches {
+				count += countRoutineSteps(branch)
+			}
+			continue
+		}
+		count++
+	}
+	return count
+}
+
+// routineStepPath addresses step i of the list at prefix
+func routineStepPath(prefix string, i int) string {
+	if prefix == "" {
+		return strconv.Itoa(i)
+	}
+	return prefix + "." + strconv.Itoa(i)
+}
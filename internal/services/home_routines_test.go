@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"woodhome-webapp/internal/database"
+	"woodhome-webapp/internal/models"
+)
+
+// waitForRun polls a run until check accepts it
+func waitForRun(t *testing.T, service *HomeRoutineService, runID string, check func(run *models.HomeRoutineRun) bool) *models.HomeRoutineRun {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if run, exists := service.GetRun(runID); exists && check(run) {
+			return run
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	run, _ := service.GetRun(runID)
+	t.Fatalf("Run %s did not reach the expected state, last seen %+v", runID, run)
+	return nil
+}
+
+func isFinished(run *models.HomeRoutineRun) bool {
+	return run.Status != models.HomeRoutineRunning
+}
+
+func TestHomeRoutineRunsStepGraph(t *testing.T) {
+	var mu sync.Mutex
+	groupBodies := make(map[string][]map[string]interface{})
+	bridge := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			body, _ := io.ReadAll(r.Body)
+			var decoded map[string]interface{}
+			json.Unmarshal(body, &decoded)
+			mu.Lock()
+			groupBodies[r.URL.Path] = append(groupBodies[r.URL.Path], decoded)
+			mu.Unlock()
+			w.Write([]byte(`[{"success":{}}]`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer bridge.Close()
+
+	var sonosCommands []string
+	jishi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sonosCommands = append(sonosCommands, r.URL.Path)
+		mu.Unlock()
+		w.Write([]byte(`{"status":"success"}`))
+	}))
+	defer jishi.Close()
+
+	hueService := NewHueService(nil)
+	hueService.primary().baseURL = bridge.URL
+	hueService.primary().commands.Start(t.Context())
+
+	sonosService := NewSonosService(&models.SonosServiceConfig{JishiURL: jishi.URL, Timeout: 5 * time.Second})
+	sonosService.devices["kitchen"] = &models.SonosDevice{UUID: "kitchen", Room: "Kitchen", GroupID: "kitchen", Coordinator: "kitchen", State: "PLAYING"}
+	sonosService.devices["nursery"] = &models.SonosDevice{UUID: "nursery", Room: "Nursery", GroupID: "nursery", Coordinator: "nursery", State: "STOPPED"}
+
+	service := NewHomeRoutineService(hueService, sonosService, nil)
+	now := time.Date(2026, 1, 10, 21, 30, 0, 0, time.Local)
+	service.now = func() time.Time { return now }
+	var delays []time.Duration
+	service.sleep = func(ctx context.Context, d time.Duration) error {
+		mu.Lock()
+		delays = append(delays, d)
+		mu.Unlock()
+		return nil
+	}
+
+	dim, off := 1, false
+	routine, err := service.CreateRoutine(models.HomeRoutine{
+		Name: "Good night",
+		Steps: []models.HomeRoutineStep{
+			{HomeSceneStep: models.HomeSceneStep{Kind: models.HomeRoutineStepFade, Target: "1", State: &models.HueGroupState{Brightness: &dim}}, DurationSec: 300},
+			{HomeSceneStep: models.HomeSceneStep{Kind: models.HomeSceneStepHueGroup, Target: "2", State: &models.HueGroupState{On: &off}}},
+			{HomeSceneStep: models.HomeSceneStep{Kind: models.HomeRoutineStepParallel}, Branches: [][]models.HomeRoutineStep{
+				{
+					{HomeSceneStep: models.HomeSceneStep{Kind: models.HomeSceneStepSonos, Target: "Nursery", Action: models.HomeSceneSonosPauseOthers}},
+				},
+				{
+					{HomeSceneStep: models.HomeSceneStep{Kind: models.HomeSceneStepSonos, Target: "Nursery", Action: models.HomeSceneSonosFavorite, Value: "White Noise"}},
+					{HomeSceneStep: models.HomeSceneStep{Kind: models.HomeSceneStepSonos, Target: "Nursery", Action: models.HomeSceneSonosVolume, Value: "15"}},
+					{HomeSceneStep: models.HomeSceneStep{Kind: models.HomeRoutineStepWait}, DurationSec: 45 * 60},
+					{HomeSceneStep: models.HomeSceneStep{Kind: models.HomeSceneStepSonos, Target: "Nursery", Action: models.HomeSceneSonosPause}},
+				},
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if routine.OnRestart != models.HomeRoutineRestartAbort {
+		t.Fatalf("Expected routines to abort on restart by default, got %q", routine.OnRestart)
+	}
+
+	started, err := service.Run(routine.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	run := waitForRun(t, service, started.ID, isFinished)
+	if run.Status != models.HomeRoutineCompleted || run.Total != 7 || run.Done != 7 || run.Percent != 100 || run.Failed != 0 {
+		t.Fatalf("Expected a completed run of 7 steps, got %+v", run)
+	}
+
+	mu.Lock()
+	fade := groupBodies["/groups/1/action"]
+	if len(fade) != 1 || fade[0]["transitiontime"] != float64(3000) {
+		t.Fatalf("Expected a 5 minute fade of the living room, got %v", fade)
+	}
+	if len(delays) != 2 || delays[0] != 5*time.Minute || delays[1] != 45*time.Minute {
+		t.Fatalf("Expected to wait for the fade and the white noise, got %v", delays)
+	}
+	nursery := []string{}
+	pausedKitchen := false
+	for _, command := range sonosCommands {
+		if command == "/Kitchen/pause" {
+			pausedKitchen = true
+		} else {
+			nursery = append(nursery, command)
+		}
+	}
+	expected := []string{"/Nursery/favorite/White Noise", "/Nursery/volume/15", "/Nursery/pause"}
+	if !pausedKitchen || len(nursery) != len(expected) {
+		t.Fatalf("Unexpected Sonos commands: %v", sonosCommands)
+	}
+	for i := range expected {
+		if nursery[i] != expected[i] {
+			t.Fatalf("Expected the nursery branch to run in order, got %v", sonosCommands)
+		}
+	}
+	sonosCommands = nil
+	mu.Unlock()
+
+	// Cancelling during the white noise skips the pause at the end
+	service.sleep = func(ctx context.Context, d time.Duration) error {
+		if d == 45*time.Minute {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}
+	started, err = service.Run(routine.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	waitForRun(t, service, started.ID, func(run *models.HomeRoutineRun) bool {
+		for _, current := range run.Current {
+			if current.Path == "2.1.2" && current.Until != nil {
+				return true
+			}
+		}
+		return false
+	})
+	if _, err := service.Run(routine.ID); err == nil {
+		t.Fatal("Expected a running routine not to start twice")
+	}
+
+	cancelled, err := service.Cancel(started.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cancelled.Status != models.HomeRoutineCancelled || cancelled.Done != 5 || len(cancelled.Current) != 0 {
+		t.Fatalf("Expected a cancelled run stopped in the wait, got %+v", cancelled)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, command := range sonosCommands {
+		if command == "/Nursery/pause" {
+			t.Fatalf("Expected the cancelled run not to pause the nursery, got %v", sonosCommands)
+		}
+	}
+}
+
+func TestHomeRoutineResumesAfterRestart(t *testing.T) {
+	var mu sync.Mutex
+	var sonosCommands []string
+	jishi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sonosCommands = append(sonosCommands, r.URL.Path)
+		mu.Unlock()
+		w.Write([]byte(`{"status":"success"}`))
+	}))
+	defer jishi.Close()
+
+	db, err := database.OpenSQLite(filepath.Join(t.TempDir(), "home.db"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	sonosService := NewSonosService(&models.SonosServiceConfig{JishiURL: jishi.URL, Timeout: 5 * time.Second})
+	steps := []models.HomeRoutineStep{
+		{HomeSceneStep: models.HomeSceneStep{Kind: models.HomeSceneStepSonos, Target: "Kitchen", Action: models.HomeSceneSonosPlay}},
+		{HomeSceneStep: models.HomeSceneStep{Kind: models.HomeRoutineStepWait}, DurationSec: 600},
+		{HomeSceneStep: models.HomeSceneStep{Kind: models.HomeSceneStepSonos, Target: "Kitchen", Action: models.HomeSceneSonosPause}},
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	before := NewHomeRoutineService(nil, sonosService, db)
+	if err := before.Start(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resumable, err := before.CreateRoutine(models.HomeRoutine{Name: "Kitchen timer", Steps: steps, OnRestart: models.HomeRoutineRestartResume})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	abortable, err := before.CreateRoutine(models.HomeRoutine{Name: "Abort me", Steps: steps[1:]})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	waiting := func(run *models.HomeRoutineRun) bool {
+		return len(run.Current) == 1 && run.Current[0].Until != nil
+	}
+	resumeRun, _ := before.Run(resumable.ID)
+	abortRun, _ := before.Run(abortable.ID)
+	inWait := waitForRun(t, before, resumeRun.ID, waiting)
+	waitForRun(t, before, abortRun.ID, waiting)
+
+	// Stopping the service leaves both runs saved as running
+	stop()
+	before.mu.Lock()
+	finished := []chan struct{}{before.runs[resumeRun.ID].finished, before.runs[abortRun.ID].finished}
+	before.mu.Unlock()
+	for _, done := range finished {
+		<-done
+	}
+
+	mu.Lock()
+	sonosCommands = nil
+	mu.Unlock()
+
+	after := NewHomeRoutineService(nil, sonosService, db)
+	until := inWait.WaitUntil["1"]
+	after.now = func() time.Time { return until.Add(-4 * time.Minute) }
+	var delays []time.Duration
+	after.sleep = func(ctx context.Context, d time.Duration) error {
+		mu.Lock()
+		delays = append(delays, d)
+		mu.Unlock()
+		return nil
+	}
+	if err := after.Start(t.Context()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	resumed := waitForRun(t, after, resumeRun.ID, isFinished)
+	if resumed.Status != models.HomeRoutineCompleted || resumed.Resumed != 1 || resumed.Done != 3 {
+		t.Fatalf("Expected the resumed run to complete, got %+v", resumed)
+	}
+	aborted := waitForRun(t, after, abortRun.ID, isFinished)
+	if aborted.Status != models.HomeRoutineAborted || aborted.Done != 0 {
+		t.Fatalf("Expected the other run to be aborted, got %+v", aborted)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sonosCommands) != 1 || sonosCommands[0] != "/Kitchen/pause" {
+		t.Fatalf("Expected only the remaining step to run, got %v", sonosCommands)
+	}
+	if len(delays) != 1 || delays[0] != 4*time.Minute {
+		t.Fatalf("Expected to wait only for the rest of the wait, got %v", delays)
+	}
+
+	var saved int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM home_routine_runs`).Scan(&saved); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if saved != 0 {
+		t.Fatalf("Expected finished runs to be removed from the database, %d left", saved)
+	}
+}
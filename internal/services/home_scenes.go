@@ -0,0 +1,610 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"woodhome-webapp/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// homeSceneLight is the state of a light before a scene changed it
+type homeSceneLight struct {
+	id    string
+	state models.HueLightState
+}
+
+// homeScenePlayer is the state of a Sonos player before a scene changed it.
+// Only what the scene's steps can change is put back.
+type homeScenePlayer struct {
+	room        string
+	playing     bool
+	volume      int
+	mute        bool
+	coordinator string // Room of the group coordinator, empty when the player was on its own
+
+	restorePlayback bool
+	restoreVolume   bool
+	restoreMute     bool
+	restoreGroup    bool
+}
+
+// homeSceneSnapshot holds what is needed to end a scene
+type homeSceneSnapshot struct {
+	lights  []homeSceneLight
+	players []*homeScenePlayer
+}
+
+// homeSceneRun tracks the last activation of a scene
+type homeSceneRun struct {
+	activation models.HomeSceneActivation
+	snapshot   *homeSceneSnapshot
+	running    bool
+}
+
+// HomeSceneService runs scenes that combine Hue and Sonos actions, such as "Movie Night"
+type HomeSceneService struct {
+	homeActions
+	db       *sql.DB
+	eventBus *EventBus
+	scenes   map[string]*models.HomeScene
+	runs     map[string]*homeSceneRun
+	ctx      context.Context // Lifetime of the service, delays stop with it
+	now      func() time.Time
+	sleep    func(ctx context.Context, d time.Duration) error
+	mu       sync.Mutex
+}
+
+// NewHomeSceneService creates a new HomeSceneService instance.
+// Scenes are kept in memory only when db is nil.
+func NewHomeSceneService(hueService *HueService, sonosService *SonosService, db *sql.DB) *HomeSceneService {
+	return &HomeSceneService{
+		homeActions: homeActions{hueService: hueService, sonosService: sonosService},
+		db:          db,
+		scenes:      make(map[string]*models.HomeScene),
+		runs:        make(map[string]*homeSceneRun),
+		ctx:         context.Background(),
+		now:         time.Now,
+		sleep:       sleepContext,
+	}
+}
+
+// SetEventBus sets the bus that scene activations are published on
+func (s *HomeSceneService) SetEventBus(eventBus *EventBus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.eventBus = eventBus
+}
+
+// Start loads the saved scenes
+func (s *HomeSceneService) Start(ctx context.Context) error {
+	logrus.Info("Starting home scene service...")
+
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return fmt.Errorf("failed to load home scenes: %w", err)
+	}
+	return nil
+}
+
+// GetScenes returns every scene sorted by name
+func (s *HomeSceneService) GetScenes() []*models.HomeScene {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scenes := make([]*models.HomeScene, 0, len(s.scenes))
+	for _, scene := range s.scenes {
+		copied := *scene
+		scenes = append(scenes, &copied)
+	}
+	sort.Slice(scenes, func(i, j int) bool {
+		return scenes[i].Name < scenes[j].Name
+	})
+	return scenes
+}
+
+// GetScene returns a single scene
+func (s *HomeSceneService) GetScene(id string) (*models.HomeScene, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scene, exists := s.scenes[id]
+	if !exists {
+		return nil, false
+	}
+	copied := *scene
+	return &copied, true
+}
+
+// CreateScene validates and saves a new scene
+func (s *HomeSceneService) CreateScene(scene models.HomeScene) (*models.HomeScene, error) {
+	if err := validateHomeScene(&scene); err != nil {
+		return nil, err
+	}
+	scene.ID = uuid.NewString()
+	scene.UpdatedAt = s.now()
+
+	if err := s.save(scene); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scenes[scene.ID] = &scene
+
+	logrus.Infof("Home scenes: created scene %q with %d steps", scene.Name, len(scene.Steps))
+	copied := scene
+	return &copied, nil
+}
+
+// UpdateScene replaces a scene. A snapshot held by an earlier activation is kept.
+func (s *HomeSceneService) UpdateScene(id string, scene models.HomeScene) (*models.HomeScene, error) {
+	if _, exists := s.GetScene(id); !exists {
+		return nil, fmt.Errorf("scene %s not found", id)
+	}
+	if err := validateHomeScene(&scene); err != nil {
+		return nil, err
+	}
+	scene.ID = id
+	scene.UpdatedAt = s.now()
+
+	if err := s.save(scene); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scenes[id] = &scene
+
+	copied := scene
+	return &copied, nil
+}
+
+// DeleteScene removes a scene
+func (s *HomeSceneService) DeleteScene(id string) error {
+	s.mu.Lock()
+	if _, exists := s.scenes[id]; !exists {
+		s.mu.Unlock()
+		return fmt.Errorf("scene %s not found", id)
+	}
+	delete(s.scenes, id)
+	delete(s.runs, id)
+	s.mu.Unlock()
+
+	if s.db != nil {
+		if _, err := s.db.Exec(`DELETE FROM home_scenes WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete scene: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetActivation returns the last activation of a scene
+func (s *HomeSceneService) GetActivation(id string) (*models.HomeSceneActivation, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run, exists := s.runs[id]
+	if !exists {
+		return nil, false
+	}
+	activation := run.activation
+	return &activation, true
+}
+
+// Activate runs the steps of a scene in order and reports the result of each one.
+// A failed step does not stop the scene. When the scene takes a snapshot, the state of
+// everything it touches is saved first; activating it again keeps the original snapshot.
+func (s *HomeSceneService) Activate(id string) (*models.HomeSceneActivation, error) {
+	s.mu.Lock()
+	scene, exists := s.scenes[id]
+	if !exists {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("scene %s not found", id)
+	}
+	previous := s.runs[id]
+	if previous != nil && previous.running {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("scene %s is already running", scene.Name)
+	}
+
+	run := &homeSceneRun{running: true}
+	if scene.Snapshot {
+		if previous != nil && previous.snapshot != nil {
+			run.snapshot = previous.snapshot
+		} else {
+			run.snapshot = s.capture(scene)
+		}
+	}
+	run.activation = models.HomeSceneActivation{
+		SceneID:   id,
+		StartedAt: s.now(),
+		Results:   []models.HomeSceneStepResult{},
+	}
+	s.runs[id] = run
+	steps := append([]models.HomeSceneStep(nil), scene.Steps...)
+	name := scene.Name
+	ctx := s.ctx
+	s.mu.Unlock()
+
+	logrus.Infof("Home scenes: activating %q", name)
+	results, interrupted := s.runSteps(ctx, steps)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run.running = false
+	run.activation.Results = results
+	run.activation.Failed = countFailed(results)
+	run.activation.Interrupted = interrupted
+	run.activation.FinishedAt = s.now()
+	run.activation.Active = run.snapshot != nil
+	s.publish(id, name, "activated", &run.activation)
+
+	activation := run.activation
+	return &activation, nil
+}
+
+// End puts back the state saved when the scene was activated
+func (s *HomeSceneService) End(id string) (*models.HomeSceneActivation, error) {
+	s.mu.Lock()
+	scene, exists := s.scenes[id]
+	if !exists {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("scene %s not found", id)
+	}
+	run := s.runs[id]
+	if run == nil || run.snapshot == nil {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("scene %s is not active", scene.Name)
+	}
+	if run.running {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("scene %s is still running", scene.Name)
+	}
+	snapshot := run.snapshot
+	run.snapshot = nil
+	name := scene.Name
+	ctx := s.ctx
+	s.mu.Unlock()
+
+	logrus.Infof("Home scenes: ending %q", name)
+	results := s.restore(ctx, snapshot)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	endedAt := s.now()
+	run.activation.Active = false
+	run.activation.EndedAt = &endedAt
+	run.activation.EndResults = results
+	s.publish(id, name, "ended", &run.activation)
+
+	activation := run.activation
+	return &activation, nil
+}
+
+// runSteps runs the steps in order, waiting the delay of each step first.
+// Steps left when the context ends are reported as skipped.
+func (s *HomeSceneService) runSteps(ctx context.Context, steps []models.HomeSceneStep) ([]models.HomeSceneStepResult, bool) {
+	results := make([]models.HomeSceneStepResult, 0, len(steps))
+	interrupted := false
+
+	for i, step := range steps {
+		result := models.HomeSceneStepResult{Step: i, Kind: step.Kind, Target: step.Target, Action: step.Action}
+
+		if !interrupted && step.DelayMs > 0 {
+			if err := s.sleep(ctx, time.Duration(step.DelayMs)*time.Millisecond); err != nil {
+				interrupted = true
+			}
+		}
+		if interrupted {
+			result.Result = models.HomeSceneResultSkipped
+			result.At = s.now()
+			results = append(results, result)
+			continue
+		}
+
+		if err := s.runStep(ctx, step); err != nil {
+			logrus.Warnf("Home scenes: step %d (%s %s) failed: %v", i+1, step.Kind, step.Target, err)
+			result.Result = models.HomeSceneResultFailed
+			result.Error = err.Error()
+		} else {
+			result.Result = models.HomeSceneResultOK
+		}
+		result.At = s.now()
+		results = append(results, result)
+	}
+	return results, interrupted
+}
+
+// capture saves the state of the lights and players a scene changes.
+// Callers must hold the lock.
+func (s *HomeSceneService) capture(scene *models.HomeScene) *homeSceneSnapshot {
+	snapshot := &homeSceneSnapshot{}
+
+	if s.hueService != nil {
+		lights := make(map[string]*models.HueLight)
+		for _, light := range s.hueService.GetLights() {
+			lights[light.ID] = light
+		}
+		scenes := make(map[string]*models.HueScene)
+		for _, hueScene := range s.hueService.GetScenes() {
+			scenes[hueScene.ID] = hueScene
+		}
+
+		seen := make(map[string]bool)
+		for _, step := range scene.Steps {
+			var lightIDs []string
+			switch step.Kind {
+			case models.HomeSceneStepHueLight:
+				lightIDs = []string{step.Target}
+			case models.HomeSceneStepHueGroup:
+				if group, exists := s.hueService.GetGroup(step.Target); exists {
+					lightIDs = group.Lights
+				}
+			case models.HomeSceneStepHueScene:
+				if hueScene, exists := scenes[step.Target]; exists {
+					lightIDs = hueScene.Lights
+				}
+			}
+			for _, lightID := range lightIDs {
+				light, exists := lights[lightID]
+				if !exists || seen[lightID] || !light.IsReachable {
+					continue
+				}
+				seen[lightID] = true
+				snapshot.lights = append(snapshot.lights, homeSceneLight{id: lightID, state: lightSnapshotState(light)})
+			}
+		}
+	}
+
+	if s.sonosService != nil {
+		devices := make(map[string]*models.SonosDevice)
+		roomsByUUID := make(map[string]string)
+		for _, device := range s.sonosService.GetDevices() {
+			devices[device.Room] = device
+			roomsByUUID[device.UUID] = device.Room
+		}
+
+		players := make(map[string]*homeScenePlayer)
+		player := func(room string) *homeScenePlayer {
+			if existing, exists := players[room]; exists {
+				return existing
+			}
+			device, exists := devices[room]
+			if !exists {
+				return nil
+			}
+			created := &homeScenePlayer{
+				room:    room,
+				playing: device.State == "PLAYING",
+				volume:  device.Volume,
+				mute:    device.Mute,
+			}
+			if device.IsGrouped() {
+				created.coordinator = roomsByUUID[device.Coordinator]
+			}
+			players[room] = created
+			snapshot.players = append(snapshot.players, created)
+			return created
+		}
+
+		for _, step := range scene.Steps {
+			if step.Kind != models.HomeSceneStepSonos {
+				continue
+			}
+			if step.Action == models.HomeSceneSonosPauseOthers {
+				for _, room := range s.otherPlayingCoordinators(step.Target) {
+					if p := player(room); p != nil {
+						p.restorePlayback = true
+					}
+				}
+				continue
+			}
+
+			p := player(step.Target)
+			if p == nil {
+				continue
+			}
+			switch step.Action {
+			case models.HomeSceneSonosVolume:
+				p.restoreVolume = true
+			case models.HomeSceneSonosMute:
+				p.restoreMute = true
+			case models.HomeSceneSonosJoin, models.HomeSceneSonosLeave:
+				p.restoreGroup = true
+				p.restorePlayback = true
+			case models.HomeSceneSonosNightMode:
+				// The zone data does not report night mode, so it cannot be put back
+			default:
+				p.restorePlayback = true
+			}
+		}
+	}
+
+	return snapshot
+}
+
+// restore puts back a snapshot and reports the outcome per light and player setting
+func (s *HomeSceneService) restore(ctx context.Context, snapshot *homeSceneSnapshot) []models.HomeSceneStepResult {
+	results := []models.HomeSceneStepResult{}
+	record := func(kind, target, action string, err error) {
+		result := models.HomeSceneStepResult{Step: len(results), Kind: kind, Target: target, Action: action, Result: models.HomeSceneResultOK, At: s.now()}
+		if err != nil {
+			logrus.Warnf("Home scenes: failed to restore %s %s: %v", kind, target, err)
+			result.Result = models.HomeSceneResultFailed
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+
+	for _, light := range snapshot.lights {
+		state := light.state
+		record(models.HomeSceneStepHueLight, light.id, "", s.hueService.SetLightState(light.id, &state))
+	}
+
+	for _, player := range snapshot.players {
+		if player.restoreGroup {
+			if player.coordinator == "" {
+				record(models.HomeSceneStepSonos, player.room, models.HomeSceneSonosLeave, s.sonosService.LeaveGroup(ctx, player.room))
+			} else {
+				record(models.HomeSceneStepSonos, player.room, models.HomeSceneSonosJoin, s.sonosService.JoinGroup(ctx, player.room, player.coordinator))
+			}
+		}
+		if player.restoreVolume {
+			record(models.HomeSceneStepSonos, player.room, models.HomeSceneSonosVolume, s.sonosService.SetVolume(ctx, player.room, player.volume))
+		}
+		if player.restoreMute {
+			record(models.HomeSceneStepSonos, player.room, models.HomeSceneSonosMute, s.sonosService.SetMute(ctx, player.room, player.mute))
+		}
+		// Grouped players follow their coordinator
+		if player.restorePlayback && player.coordinator == "" {
+			if player.playing {
+				record(models.HomeSceneStepSonos, player.room, models.HomeSceneSonosPlay, s.sonosService.PlayDevice(ctx, player.room))
+			} else {
+				record(models.HomeSceneStepSonos, player.room, models.HomeSceneSonosPause, s.sonosService.PauseDevice(ctx, player.room))
+			}
+		}
+	}
+
+	return results
+}
+
+// publish sends an activation to the event bus. Callers must hold the lock.
+func (s *HomeSceneService) publish(id, name, result string, activation *models.HomeSceneActivation) {
+	if s.eventBus == nil {
+		return
+	}
+	s.eventBus.Publish(models.EventHomeScene, id, map[string]interface{}{
+		"scene":  name,
+		"result": result,
+		"failed": activation.Failed,
+		"active": activation.Active,
+	})
+}
+
+// load reads the saved scenes
+func (s *HomeSceneService) load() error {
+	if s.db == nil {
+		return nil
+	}
+
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS home_scenes (
+			id TEXT PRIMARY KEY,
+			data TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.db.Query(`SELECT id, data FROM home_scenes`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for rows.Next() {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			return err
+		}
+		var scene models.HomeScene
+		if err := json.Unmarshal([]byte(data), &scene); err != nil {
+			logrus.Warnf("Home scenes: ignoring unreadable scene %s: %v", id, err)
+			continue
+		}
+		scene.ID = id
+		s.scenes[id] = &scene
+	}
+	logrus.Infof("Home scenes: loaded %d scenes", len(s.scenes))
+	return rows.Err()
+}
+
+// save stores a scene
+func (s *HomeSceneService) save(scene models.HomeScene) error {
+	if s.db == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(scene)
+	if err != nil {
+		return fmt.Errorf("failed to marshal scene: %w", err)
+	}
+	_, err = s.db.Exec(`
+		INSERT OR REPLACE INTO home_scenes (id, data, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+	`, scene.ID, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save scene: %w", err)
+	}
+	return nil
+}
+
+// validateHomeScene checks the steps of a scene
+func validateHomeScene(scene *models.HomeScene) error {
+	if scene.Name == "" {
+		return fmt.Errorf("scene name is required")
+	}
+	if len(scene.Steps) == 0 {
+		return fmt.Errorf("a scene needs at least one step")
+	}
+
+	for i := range scene.Steps {
+		if err := validateHomeSceneStep(&scene.Steps[i]); err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// lightSnapshotState returns the state that puts a light back the way it is now
+func lightSnapshotState(light *models.HueLight) models.HueLightState {
+	on := light.IsOn
+	state := models.HueLightState{On: &on}
+	if !on {
+		return state
+	}
+
+	brightness := light.Brightness
+	state.Brightness = &brightness
+	switch light.ColorMode {
+	case "ct":
+		colorTemp := light.ColorTemp
+		state.ColorTemp = &colorTemp
+	case "xy":
+		state.XY = append([]float64(nil), light.XY...)
+	case "hs":
+		hue, saturation := light.Hue, light.Saturation
+		state.Hue = &hue
+		state.Saturation = &saturation
+	}
+	return state
+}
+
+// countFailed counts the failed step results
+func countFailed(results []models.HomeSceneStepResult) int {
+	failed := 0
+	for _, result := range results {
+		if result.Result == models.HomeSceneResultFailed {
+			failed++
+		}
+	}
+	return failed
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"woodhome-webapp/internal/models"
+)
+
+func TestHomeSceneActivateAndEnd(t *testing.T) {
+	var mu sync.Mutex
+	var lightBodies []map[string]interface{}
+	bridge := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/lights":
+			w.Write([]byte(`{"1":{"name":"Sofa","type":"Extended color light","state":{"on":true,"bri":200,"colormode":"ct","ct":370,"reachable":true}}}`))
+		case r.Method == "GET" && r.URL.Path == "/groups":
+			w.Write([]byte(`{"1":{"name":"Living room","type":"Room","lights":["1"],"state":{"any_on":true}}}`))
+		case r.Method == "PUT":
+			body, _ := io.ReadAll(r.Body)
+			var decoded map[string]interface{}
+			json.Unmarshal(body, &decoded)
+			mu.Lock()
+			if r.URL.Path == "/lights/1/state" {
+				lightBodies = append(lightBodies, decoded)
+			}
+			mu.Unlock()
+			w.Write([]byte(`[{"success":{}}]`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer bridge.Close()
+
+	var sonosCommands []string
+	jishi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sonosCommands = append(sonosCommands, r.URL.Path)
+		mu.Unlock()
+		w.Write([]byte(`{"status":"success"}`))
+	}))
+	defer jishi.Close()
+
+	hueService := NewHueService(nil)
+	hueService.primary().baseURL = bridge.URL
+	hueService.primary().commands.Start(t.Context())
+	if err := hueService.refreshResources(true, hueResourceLights, hueResourceGroups); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	sonosService := NewSonosService(&models.SonosServiceConfig{JishiURL: jishi.URL, Timeout: 5 * time.Second})
+	sonosService.devices["tv"] = &models.SonosDevice{UUID: "tv", Room: "Living Room", GroupID: "tv", Coordinator: "tv", State: "STOPPED", Volume: 20}
+	sonosService.devices["kitchen"] = &models.SonosDevice{UUID: "kitchen", Room: "Kitchen", GroupID: "kitchen", Coordinator: "kitchen", State: "PLAYING", Volume: 30}
+
+	service := NewHomeSceneService(hueService, sonosService, nil)
+	var delays []time.Duration
+	service.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+
+	dim := 30
+	scene, err := service.CreateScene(models.HomeScene{
+		Name:     "Movie Night",
+		Snapshot: true,
+		Steps: []models.HomeSceneStep{
+			{Kind: models.HomeSceneStepSonos, Target: "Living Room", Action: models.HomeSceneSonosPauseOthers},
+			{Kind: models.HomeSceneStepSonos, Target: "Living Room", Action: models.HomeSceneSonosLineIn},
+			{Kind: models.HomeSceneStepSonos, Target: "Living Room", Action: models.HomeSceneSonosNightMode, Value: "on"},
+			{Kind: models.HomeSceneStepHueGroup, Target: "1", State: &models.HueGroupState{Brightness: &dim}, DelayMs: 1500},
+			{Kind: models.HomeSceneStepHueScene, Target: "unknown:1"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	activation, err := service.Activate(scene.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(activation.Results) != 5 || activation.Failed != 1 || activation.Results[4].Result != models.HomeSceneResultFailed {
+		t.Fatalf("Expected the unknown bridge scene to fail on its own, got %+v", activation.Results)
+	}
+	if !activation.Active || len(delays) != 1 || delays[0] != 1500*time.Millisecond {
+		t.Fatalf("Expected an active scene that waited once, got %+v and %v", activation, delays)
+	}
+
+	mu.Lock()
+	expected := []string{"/Kitchen/pause", "/Living Room/linein", "/Living Room/nightmode/on"}
+	if len(sonosCommands) != len(expected) {
+		t.Fatalf("Expected Sonos commands %v, got %v", expected, sonosCommands)
+	}
+	for i := range expected {
+		if sonosCommands[i] != expected[i] {
+			t.Fatalf("Expected Sonos commands %v, got %v", expected, sonosCommands)
+		}
+	}
+	sonosCommands = nil
+	mu.Unlock()
+
+	ended, err := service.End(scene.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ended.Active || ended.EndedAt == nil || countFailed(ended.EndResults) != 0 {
+		t.Fatalf("Expected a cleanly ended scene, got %+v", ended)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// The kitchen plays again and the living room, which was stopped, is paused
+	if len(sonosCommands) != 2 || sonosCommands[0] != "/Kitchen/play" || sonosCommands[1] != "/Living Room/pause" {
+		t.Fatalf("Unexpected Sonos restore commands: %v", sonosCommands)
+	}
+	if len(lightBodies) == 0 {
+		t.Fatal("Expected the sofa light to be restored")
+	}
+	restored := lightBodies[len(lightBodies)-1]
+	if restored["bri"] != float64(200) || restored["ct"] != float64(370) || restored["on"] != true {
+		t.Fatalf("Expected the prior light state to be restored, got %v", restored)
+	}
+
+	if _, err := service.End(scene.ID); err == nil {
+		t.Fatal("Expected ending an inactive scene to fail")
+	}
+}
@@ -0,0 +1,731 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"woodhome-webapp/internal/color"
+	"woodhome-webapp/internal/ical"
+	"woodhome-webapp/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/calendar/v3"
+)
+
+// HouseholdCalendarPrefix starts the dashboard IDs of household calendars and their events
+const HouseholdCalendarPrefix = "household:"
+
+// The calendar created the first time the service starts
+const (
+	defaultHouseholdCalendarName  = "Household"
+	defaultHouseholdCalendarColor = "#f691b2"
+)
+
+var (
+	// ErrHouseholdNotFound is returned for an unknown household calendar or event
+	ErrHouseholdNotFound = errors.New("not found")
+	// ErrInvalidHouseholdEvent is returned for an event input that cannot be saved
+	ErrInvalidHouseholdEvent = errors.New("invalid event")
+)
+
+// householdEventState is an event with the form it is expanded in
+type householdEventState struct {
+	event    models.HouseholdEvent
+	expanded *ical.Event
+}
+
+// HouseholdCalendarService keeps calendars in the WoodHome database, for what does not belong
+// in anyone's Google account. Recurring events are expanded here, and the events are served
+// alongside the Google events.
+type HouseholdCalendarService struct {
+	config    *models.HouseholdCalendarConfig
+	db        *sql.DB
+	calendars map[string]*models.HouseholdCalendar // By dashboard calendar ID
+	events    map[string]*householdEventState      // By event ID
+	now       func() time.Time
+	mu        sync.RWMutex
+}
+
+// NewHouseholdCalendarService creates a new HouseholdCalendarService instance.
+// Calendars are kept in memory only when db is nil.
+func NewHouseholdCalendarService(db *sql.DB, config *models.HouseholdCalendarConfig) *HouseholdCalendarService {
+	if config == nil {
+		config = &models.HouseholdCalendarConfig{}
+	}
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+
+	return &HouseholdCalendarService{
+		config:    config,
+		db:        db,
+		calendars: make(map[string]*models.HouseholdCalendar),
+		events:    make(map[string]*householdEventState),
+		now:       time.Now,
+	}
+}
+
+// Start loads the calendars and their events
+func (s *HouseholdCalendarService) Start(ctx context.Context) error {
+	logrus.Info("Starting household calendar service...")
+
+	if err := s.load(); err != nil {
+		return fmt.Errorf("failed to load household calendars: %w", err)
+	}
+	return nil
+}
+
+// GetCalendarList returns every household calendar, the default one first
+func (s *HouseholdCalendarService) GetCalendarList() []*models.HouseholdCalendar {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	calendars := make([]*models.HouseholdCalendar, 0, len(s.calendars))
+	for _, cal := range s.calendars {
+		copied := *cal
+		calendars = append(calendars, &copied)
+	}
+	sort.Slice(calendars, func(i, j int) bool {
+		if calendars[i].Default != calendars[j].Default {
+			return calendars[i].Default
+		}
+		return calendars[i].Name < calendars[j].Name
+	})
+	return calendars
+}
+
+// GetCalendar returns a household calendar by ID
+func (s *HouseholdCalendarService) GetCalendar(id string) (*models.HouseholdCalendar, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cal, exists := s.calendars[id]
+	if !exists {
+		return nil, false
+	}
+	copied := *cal
+	return &copied, true
+}
+
+// CreateCalendar validates and saves a new calendar. The first calendar becomes the default.
+func (s *HouseholdCalendarService) CreateCalendar(cal models.HouseholdCalendar) (*models.HouseholdCalendar, error) {
+	if err := validateHouseholdCalendar(&cal); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cal = models.HouseholdCalendar{
+		ID:        HouseholdCalendarPrefix + uuid.NewString(),
+		Name:      cal.Name,
+		Color:     cal.Color,
+		Default:   len(s.calendars) == 0,
+		UpdatedAt: s.now(),
+	}
+	if err := s.saveCalendar(cal); err != nil {
+		return nil, err
+	}
+	s.calendars[cal.ID] = &cal
+
+	logrus.Infof("Household calendar: added %q", cal.Name)
+	copied := cal
+	return &copied, nil
+}
+
+// UpdateCalendar renames or recolors a calendar. Setting Default moves the default to it.
+func (s *HouseholdCalendarService) UpdateCalendar(id string, update models.HouseholdCalendar) (*models.HouseholdCalendar, error) {
+	if err := validateHouseholdCalendar(&update); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, exists := s.calendars[id]
+	if !exists {
+		return nil, fmt.Errorf("calendar %s %w", id, ErrHouseholdNotFound)
+	}
+	cal := *existing
+	cal.Name = update.Name
+	cal.Color = update.Color
+	cal.UpdatedAt = s.now()
+	if update.Default && !cal.Default {
+		for _, other := range s.calendars {
+			if other.Default {
+				previous := *other
+				previous.Default = false
+				if err := s.saveCalendar(previous); err != nil {
+					return nil, err
+				}
+				*other = previous
+			}
+		}
+		cal.Default = true
+	}
+	if err := s.saveCalendar(cal); err != nil {
+		return nil, err
+	}
+	*existing = cal
+
+	copied := cal
+	return &copied, nil
+}
+
+// DeleteCalendar removes a calendar with its events. The default calendar cannot be removed.
+func (s *HouseholdCalendarService) DeleteCalendar(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cal, exists := s.calendars[id]
+	if !exists {
+		return fmt.Errorf("calendar %s %w", id, ErrHouseholdNotFound)
+	}
+	if cal.Default {
+		return fmt.Errorf("the default calendar cannot be deleted")
+	}
+
+	if s.db != nil {
+		if _, err := s.db.Exec(`DELETE FROM household_events WHERE calendar_id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete calendar events: %w", err)
+		}
+		if _, err := s.db.Exec(`DELETE FROM household_calendars WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete calendar: %w", err)
+		}
+	}
+	for eventID, state := range s.events {
+		if state.event.CalendarID == id {
+			delete(s.events, eventID)
+		}
+	}
+	delete(s.calendars, id)
+
+	logrus.Infof("Household calendar: removed %q", cal.Name)
+	return nil
+}
+
+// GetCalendars lists the household calendars the way Google calendars are listed
+func (s *HouseholdCalendarService) GetCalendars() []CalendarInfo {
+	calendars := []CalendarInfo{}
+	for _, cal := range s.GetCalendarList() {
+		calendars = append(calendars, CalendarInfo{ID: cal.ID, Name: cal.Name, Color: cal.Color})
+	}
+	return calendars
+}
+
+// GetEvents returns the occurrences overlapping start to end, in the same form as Google
+// events. When calendarIDs is not empty only those calendars are included.
+func (s *HouseholdCalendarService) GetEvents(start, end time.Time, calendarIDs []string) []CalendarEvent {
+	selected := make(map[string]bool)
+	for _, id := range calendarIDs {
+		selected[id] = true
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	expansion := &ical.Calendar{}
+	for _, state := range s.events {
+		if len(selected) == 0 || selected[state.event.CalendarID] {
+			expansion.Events = append(expansion.Events, state.expanded)
+		}
+	}
+
+	instances := expansion.Expand(start, end)
+	events := make([]CalendarEvent, 0, len(instances))
+	for _, instance := range instances {
+		state := s.events[instance.Event.UID]
+		events = append(events, s.toCalendarEvent(state.event, instance))
+	}
+	return events
+}
+
+// toCalendarEvent converts an occurrence the way Google events are converted. Occurrences of
+// a recurring event get IDs of their own, which name the occurrence they stand for.
+func (s *HouseholdCalendarService) toCalendarEvent(event models.HouseholdEvent, instance ical.Instance) CalendarEvent {
+	var calendarColor string
+	if cal, exists := s.calendars[event.CalendarID]; exists {
+		calendarColor = cal.Color
+	}
+	converted := CalendarEvent{
+		ID:            HouseholdCalendarPrefix + event.ID,
+		Title:         event.Title,
+		Description:   event.Description,
+		Color:         calendarColor,
+		AllDay:        instance.AllDay,
+		CalendarID:    event.CalendarID,
+		CalendarColor: calendarColor,
+		Reminders:     event.Reminders,
+	}
+	if event.Color != "" {
+		converted.Color = event.Color
+	}
+	if instance.Event.Recurring() {
+		converted.ID += ":" + strconv.FormatInt(instance.Start.Unix(), 10)
+	}
+	if instance.AllDay {
+		converted.Start = instance.Start.Format("2006-01-02")
+		converted.End = instance.End.Format("2006-01-02")
+	} else {
+		converted.Start = instance.Start.In(s.config.Location).Format(time.RFC3339)
+		converted.End = instance.End.In(s.config.Location).Format(time.RFC3339)
+	}
+	return converted
+}
+
+// CreateEvent adds an event. Events posted to the primary calendar, or to none, go to the
+// default household calendar.
+func (s *HouseholdCalendarService) CreateEvent(input models.HouseholdEventInput) (*CalendarEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	calendarID := input.CalendarID
+	if calendarID == "" || calendarID == "primary" {
+		for _, cal := range s.calendars {
+			if cal.Default {
+				calendarID = cal.ID
+			}
+		}
+	}
+	if _, exists := s.calendars[calendarID]; !exists {
+		return nil, fmt.Errorf("calendar %s %w", input.CalendarID, ErrHouseholdNotFound)
+	}
+
+	now := s.now()
+	event, err := s.eventFromInput(input, models.HouseholdEvent{
+		ID:         uuid.NewString(),
+		CalendarID: calendarID,
+		CreatedAt:  now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	event.UpdatedAt = now
+
+	state, err := s.store(event)
+	if err != nil {
+		return nil, err
+	}
+	converted := s.toCalendarEvent(event, firstInstance(state.expanded))
+	return &converted, nil
+}
+
+// UpdateEvent changes an event. Through the ID of one occurrence the whole series changes:
+// moving the occurrence moves every occurrence by as much. Recurrence, reminders and colors
+// left out of the input are kept.
+func (s *HouseholdCalendarService) UpdateEvent(eventID string, input models.HouseholdEventInput) (*CalendarEvent, error) {
+	id, occurrence, ok := parseHouseholdEventID(eventID)
+	if !ok {
+		return nil, fmt.Errorf("event %s %w", eventID, ErrHouseholdNotFound)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, exists := s.events[id]
+	if !exists {
+		return nil, fmt.Errorf("event %s %w", eventID, ErrHouseholdNotFound)
+	}
+	stored := state.event
+	if input.CalendarID != "" && input.CalendarID != "primary" {
+		if _, exists := s.calendars[input.CalendarID]; !exists {
+			return nil, fmt.Errorf("calendar %s %w", input.CalendarID, ErrHouseholdNotFound)
+		}
+		stored.CalendarID = input.CalendarID
+	}
+	if input.Recurrence == nil {
+		input.Recurrence = stored.Recurrence
+	}
+	if input.Reminders == nil {
+		input.Reminders = stored.Reminders
+	}
+	if input.ColorID == "" && input.Color == "" {
+		input.Color = stored.Color
+	}
+
+	event, err := s.eventFromInput(input, stored)
+	if err != nil {
+		return nil, err
+	}
+	if !occurrence.IsZero() && state.expanded.Recurring() && event.AllDay == stored.AllDay {
+		shiftSeries(&event, stored, occurrence, s.location(event.TimeZone))
+	}
+	event.UpdatedAt = s.now()
+
+	state, err = s.store(event)
+	if err != nil {
+		return nil, err
+	}
+	converted := s.toCalendarEvent(event, firstInstance(state.expanded))
+	return &converted, nil
+}
+
+// DeleteEvent removes an event. Through the ID of one occurrence only that occurrence is
+// removed, unless series is set.
+func (s *HouseholdCalendarService) DeleteEvent(eventID string, series bool) error {
+	id, occurrence, ok := parseHouseholdEventID(eventID)
+	if !ok {
+		return fmt.Errorf("event %s %w", eventID, ErrHouseholdNotFound)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, exists := s.events[id]
+	if !exists {
+		return fmt.Errorf("event %s %w", eventID, ErrHouseholdNotFound)
+	}
+
+	if !occurrence.IsZero() && !series && state.expanded.Recurring() {
+		event := state.event
+		event.ExDates = append(append([]time.Time{}, event.ExDates...), occurrence)
+		event.UpdatedAt = s.now()
+		_, err := s.store(event)
+		return err
+	}
+
+	if s.db != nil {
+		if _, err := s.db.Exec(`DELETE FROM household_events WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete event: %w", err)
+		}
+	}
+	delete(s.events, id)
+	return nil
+}
+
+// store saves an event and replaces its expanded form. The caller holds the lock.
+func (s *HouseholdCalendarService) store(event models.HouseholdEvent) (*householdEventState, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event: %w", err)
+	}
+	if s.db != nil {
+		_, err = s.db.Exec(`
+			INSERT OR REPLACE INTO household_events (id, calendar_id, data, updated_at)
+			VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		`, event.ID, event.CalendarID, string(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to save event: %w", err)
+		}
+	}
+
+	state := &householdEventState{event: event, expanded: s.expand(event)}
+	s.events[event.ID] = state
+	return state, nil
+}
+
+// expand builds the form an event is expanded in
+func (s *HouseholdCalendarService) expand(event models.HouseholdEvent) *ical.Event {
+	expanded := ical.NewEvent(event.ID, event.Start, event.End, event.AllDay, s.location(event.TimeZone))
+	expanded.Summary = event.Title
+	expanded.Description = event.Description
+	expanded.Location = event.Location
+	expanded.ExDates = event.ExDates
+	for _, line := range event.Recurrence {
+		if rule, err := ical.ParseRule(strings.TrimPrefix(line, "RRULE:")); err == nil {
+			expanded.Rule = rule
+		}
+	}
+	return expanded
+}
+
+// eventFromInput applies an input to an event, parsing and checking its times, rule and color
+func (s *HouseholdCalendarService) eventFromInput(input models.HouseholdEventInput, event models.HouseholdEvent) (models.HouseholdEvent, error) {
+	event.Title = strings.TrimSpace(input.Title)
+	if event.Title == "" {
+		event.Title = strings.TrimSpace(input.Summary)
+	}
+	if event.Title == "" {
+		return event, fmt.Errorf("%w: title is required", ErrInvalidHouseholdEvent)
+	}
+	event.Description = input.Description
+	event.Location = input.Location
+	event.AllDay = input.AllDay
+	if input.Start == nil || input.End == nil {
+		return event, fmt.Errorf("%w: start and end are required", ErrInvalidHouseholdEvent)
+	}
+
+	event.TimeZone = s.config.Location.String()
+	if input.Start.TimeZone != "" {
+		if _, err := time.LoadLocation(input.Start.TimeZone); err != nil {
+			return event, fmt.Errorf("%w: unknown time zone %s", ErrInvalidHouseholdEvent, input.Start.TimeZone)
+		}
+		event.TimeZone = input.Start.TimeZone
+	}
+	loc := s.location(event.TimeZone)
+
+	if event.AllDay {
+		var err error
+		if event.Start, err = time.ParseInLocation("2006-01-02", input.Start.Date, loc); err != nil {
+			return event, fmt.Errorf("%w: start %v", ErrInvalidHouseholdEvent, err)
+		}
+		if event.End, err = time.ParseInLocation("2006-01-02", input.End.Date, loc); err != nil {
+			return event, fmt.Errorf("%w: end %v", ErrInvalidHouseholdEvent, err)
+		}
+		// The event modal sends the last day, Google sends the day after it
+		if event.End.Equal(event.Start) {
+			event.End = event.Start.AddDate(0, 0, 1)
+		}
+	} else {
+		if input.Start.DateTime.IsZero() || input.End.DateTime.IsZero() {
+			return event, fmt.Errorf("%w: start and end times are required", ErrInvalidHouseholdEvent)
+		}
+		event.Start = input.Start.DateTime
+		event.End = input.End.DateTime
+		if event.End.Equal(event.Start) {
+			event.End = event.Start.Add(time.Hour)
+		}
+	}
+	if event.End.Before(event.Start) {
+		return event, fmt.Errorf("%w: end must be after start", ErrInvalidHouseholdEvent)
+	}
+
+	event.Recurrence = nil
+	for _, line := range input.Recurrence {
+		value, found := strings.CutPrefix(strings.TrimSpace(line), "RRULE:")
+		if !found {
+			return event, fmt.Errorf("%w: only RRULE recurrence lines are supported", ErrInvalidHouseholdEvent)
+		}
+		if len(event.Recurrence) > 0 {
+			return event, fmt.Errorf("%w: only one RRULE is supported", ErrInvalidHouseholdEvent)
+		}
+		if _, err := ical.ParseRule(value); err != nil {
+			return event, fmt.Errorf("%w: %v", ErrInvalidHouseholdEvent, err)
+		}
+		event.Recurrence = append(event.Recurrence, "RRULE:"+value)
+	}
+	if len(event.Recurrence) == 0 {
+		event.ExDates = nil
+	}
+
+	event.Reminders = input.Reminders
+	if event.Reminders != nil {
+		for _, override := range event.Reminders.Overrides {
+			if override == nil || override.Minutes < 0 {
+				return event, fmt.Errorf("%w: reminder minutes cannot be negative", ErrInvalidHouseholdEvent)
+			}
+		}
+	}
+
+	event.Color = ""
+	if input.ColorID != "" {
+		event.Color = getEventColor(&calendar.Event{ColorId: input.ColorID})
+		if event.Color == "" {
+			return event, fmt.Errorf("%w: unknown color %s", ErrInvalidHouseholdEvent, input.ColorID)
+		}
+	} else if input.Color != "" {
+		rgb, err := color.ParseHex(input.Color)
+		if err != nil {
+			return event, fmt.Errorf("%w: invalid color: %v", ErrInvalidHouseholdEvent, err)
+		}
+		event.Color = rgb.Hex()
+	}
+	return event, nil
+}
+
+// location returns the zone an event was created in
+func (s *HouseholdCalendarService) location(name string) *time.Location {
+	if loc, err := time.LoadLocation(name); err == nil && name != "" {
+		return loc
+	}
+	return s.config.Location
+}
+
+// shiftSeries moves a series edited through one of its occurrences. The new times of the
+// occurrence tell how far the first occurrence and the deleted occurrences move.
+func shiftSeries(event *models.HouseholdEvent, stored models.HouseholdEvent, occurrence time.Time, loc *time.Location) {
+	event.ExDates = append([]time.Time{}, event.ExDates...)
+	if event.AllDay {
+		// Days across a daylight saving change are not 24 hours long
+		days := int(math.Round(event.Start.Sub(occurrence).Hours() / 24))
+		length := int(math.Round(event.End.Sub(event.Start).Hours() / 24))
+		first := stored.Start.In(loc)
+		event.Start = time.Date(first.Year(), first.Month(), first.Day()+days, 0, 0, 0, 0, loc)
+		event.End = event.Start.AddDate(0, 0, length)
+		for i, exdate := range event.ExDates {
+			event.ExDates[i] = exdate.AddDate(0, 0, days)
+		}
+		return
+	}
+
+	shift := event.Start.Sub(occurrence)
+	length := event.End.Sub(event.Start)
+	event.Start = stored.Start.Add(shift)
+	event.End = event.Start.Add(length)
+	for i, exdate := range event.ExDates {
+		event.ExDates[i] = exdate.Add(shift)
+	}
+}
+
+// firstInstance is the first occurrence of an event
+func firstInstance(event *ical.Event) ical.Instance {
+	return ical.Instance{Event: event, Start: event.Start, End: event.End, AllDay: event.AllDay}
+}
+
+// SplitHouseholdCalendarIDs separates the household calendar IDs from the other calendar IDs
+func SplitHouseholdCalendarIDs(calendarIDs []string) (otherIDs, householdIDs []string) {
+	for _, id := range calendarIDs {
+		if strings.HasPrefix(id, HouseholdCalendarPrefix) {
+			householdIDs = append(householdIDs, id)
+		} else {
+			otherIDs = append(otherIDs, id)
+		}
+	}
+	return otherIDs, householdIDs
+}
+
+// IsHouseholdID reports whether a dashboard calendar or event ID belongs to a household calendar
+func IsHouseholdID(id string) bool {
+	return strings.HasPrefix(id, HouseholdCalendarPrefix)
+}
+
+// parseHouseholdEventID splits a dashboard event ID into the event ID and, for an occurrence
+// of a recurring event, the start of the occurrence
+func parseHouseholdEventID(eventID string) (id string, occurrence time.Time, ok bool) {
+	rest, found := strings.CutPrefix(eventID, HouseholdCalendarPrefix)
+	if !found || rest == "" {
+		return "", time.Time{}, false
+	}
+	id, unix, found := strings.Cut(rest, ":")
+	if !found {
+		return id, time.Time{}, true
+	}
+	seconds, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return id, time.Unix(seconds, 0), true
+}
+
+// validateHouseholdCalendar checks a calendar and normalizes its color
+func validateHouseholdCalendar(cal *models.HouseholdCalendar) error {
+	cal.Name = strings.TrimSpace(cal.Name)
+	if cal.Name == "" {
+		return fmt.Errorf("calendar name is required")
+	}
+	if cal.Color == "" {
+		cal.Color = defaultHouseholdCalendarColor
+	}
+	rgb, err := color.ParseHex(cal.Color)
+	if err != nil {
+		return fmt.Errorf("invalid calendar color: %w", err)
+	}
+	cal.Color = rgb.Hex()
+	return nil
+}
+
+// load reads the saved calendars and events, creating the default calendar on the first start
+func (s *HouseholdCalendarService) load() error {
+	if s.db != nil {
+		for _, statement := range []string{`
+			CREATE TABLE IF NOT EXISTS household_calendars (
+				id TEXT PRIMARY KEY,
+				data TEXT NOT NULL,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`, `
+			CREATE TABLE IF NOT EXISTS household_events (
+				id TEXT PRIMARY KEY,
+				calendar_id TEXT NOT NULL,
+				data TEXT NOT NULL,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+		} {
+			if _, err := s.db.Exec(statement); err != nil {
+				return err
+			}
+		}
+	}
+
+	calendars := make(map[string]*models.HouseholdCalendar)
+	events := make(map[string]*householdEventState)
+	if s.db != nil {
+		rows, err := s.db.Query(`SELECT id, data FROM household_calendars`)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id, data string
+			if err := rows.Scan(&id, &data); err != nil {
+				rows.Close()
+				return err
+			}
+			var cal models.HouseholdCalendar
+			if err := json.Unmarshal([]byte(data), &cal); err != nil {
+				logrus.Warnf("Household calendar: ignoring unreadable calendar %s: %v", id, err)
+				continue
+			}
+			cal.ID = id
+			calendars[id] = &cal
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		rows, err = s.db.Query(`SELECT id, data FROM household_events`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id, data string
+			if err := rows.Scan(&id, &data); err != nil {
+				return err
+			}
+			var event models.HouseholdEvent
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				logrus.Warnf("Household calendar: ignoring unreadable event %s: %v", id, err)
+				continue
+			}
+			event.ID = id
+			events[id] = &householdEventState{event: event, expanded: s.expand(event)}
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	s.calendars = calendars
+	s.events = events
+	s.mu.Unlock()
+
+	if len(calendars) == 0 {
+		if _, err := s.CreateCalendar(models.HouseholdCalendar{Name: defaultHouseholdCalendarName, Color: defaultHouseholdCalendarColor}); err != nil {
+			return err
+		}
+	}
+	logrus.Infof("Household calendar: loaded %d calendars with %d events", len(s.GetCalendarList()), len(events))
+	return nil
+}
+
+// saveCalendar stores a calendar. The caller holds the lock.
+func (s *HouseholdCalendarService) saveCalendar(cal models.HouseholdCalendar) error {
+	if s.db == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(cal)
+	if err != nil {
+		return fmt.Errorf("failed to marshal calendar: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+		INSERT OR REPLACE INTO household_calendars (id, data, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+	`, cal.ID, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save calendar: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"woodhome-webapp/internal/database"
+	"woodhome-webapp/internal/models"
+)
+
+func TestHouseholdCalendar(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Skip("No time zone data")
+	}
+
+	db, err := database.OpenSQLite(filepath.Join(t.TempDir(), "home.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	newHouseholdService := func() *HouseholdCalendarService {
+		service := NewHouseholdCalendarService(db, &models.HouseholdCalendarConfig{Location: chicago})
+		if err := service.load(); err != nil {
+			t.Fatalf("Failed to load calendars: %v", err)
+		}
+		return service
+	}
+	service := newHouseholdService()
+
+	calendars := service.GetCalendarList()
+	if len(calendars) != 1 || !calendars[0].Default || !strings.HasPrefix(calendars[0].ID, HouseholdCalendarPrefix) {
+		t.Fatalf("Expected the default calendar, got %+v", calendars)
+	}
+	chores, err := service.CreateCalendar(models.HouseholdCalendar{Name: "Chores", Color: "#16A765"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if chores.Default || chores.Color != "#16a765" {
+		t.Fatalf("Unexpected calendar: %+v", chores)
+	}
+
+	// Posted the way the event modal posts to the primary calendar
+	gameNight := time.Date(2024, 10, 25, 19, 0, 0, 0, chicago)
+	created, err := service.CreateEvent(models.HouseholdEventInput{
+		CalendarID: "primary",
+		Title:      "Game night",
+		Start:      &models.EventDateTime{DateTime: gameNight.UTC(), TimeZone: "America/Chicago"},
+		End:        &models.EventDateTime{DateTime: gameNight.Add(2 * time.Hour).UTC(), TimeZone: "America/Chicago"},
+		Recurrence: []string{"RRULE:FREQ=WEEKLY;COUNT=4"},
+		Reminders:  &models.EventReminders{Overrides: []*models.ReminderOverride{{Method: "popup", Minutes: 30}}},
+		ColorID:    "11",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if created.CalendarID != calendars[0].ID || created.Color != "#dc2127" || created.CalendarColor != calendars[0].Color {
+		t.Fatalf("Unexpected event: %+v", created)
+	}
+	if _, err := service.CreateEvent(models.HouseholdEventInput{
+		CalendarID: chores.ID,
+		Title:      "Take out trash",
+		AllDay:     true,
+		Start:      &models.EventDateTime{Date: "2024-10-29"},
+		End:        &models.EventDateTime{Date: "2024-10-29"},
+	}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := service.CreateEvent(models.HouseholdEventInput{
+		Title:      "Every hour",
+		Start:      &models.EventDateTime{DateTime: gameNight},
+		End:        &models.EventDateTime{DateTime: gameNight.Add(time.Hour)},
+		Recurrence: []string{"RRULE:FREQ=HOURLY"},
+	}); !errors.Is(err, ErrInvalidHouseholdEvent) {
+		t.Fatalf("Expected the rule to be rejected, got %v", err)
+	}
+
+	// Game nights keep their local time after daylight saving ends, and the
+	// event survives a restart
+	service = newHouseholdService()
+	start := time.Date(2024, 10, 1, 0, 0, 0, 0, chicago)
+	end := time.Date(2024, 12, 1, 0, 0, 0, 0, chicago)
+	events := service.GetEvents(start, end, nil)
+	if len(events) != 5 || events[0].Start != "2024-10-25T19:00:00-05:00" || events[2].Title != "Game night" || events[2].Start != "2024-11-01T19:00:00-05:00" || events[3].Start != "2024-11-08T19:00:00-06:00" {
+		t.Fatalf("Unexpected events: %+v", events)
+	}
+	if events[1].Title != "Take out trash" || events[1].Start != "2024-10-29" || events[1].End != "2024-10-30" || !events[1].AllDay {
+		t.Fatalf("Unexpected all-day event: %+v", events[1])
+	}
+	if len(service.GetEvents(start, end, []string{chores.ID})) != 1 {
+		t.Fatal("Expected other calendars to be filtered out")
+	}
+
+	// Deleting one occurrence leaves the rest of the series
+	if err := service.DeleteEvent(events[2].ID, false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	events = service.GetEvents(start, end, []string{calendars[0].ID})
+	if len(events) != 3 || events[1].Start != "2024-11-08T19:00:00-06:00" {
+		t.Fatalf("Unexpected events after deleting an occurrence: %+v", events)
+	}
+
+	// Moving an occurrence an hour later moves the series and keeps the rule, reminders and color
+	occurrence := time.Date(2024, 11, 8, 20, 0, 0, 0, chicago)
+	updated, err := service.UpdateEvent(events[1].ID, models.HouseholdEventInput{
+		Title: "Board games",
+		Start: &models.EventDateTime{DateTime: occurrence.UTC(), TimeZone: "America/Chicago"},
+		End:   &models.EventDateTime{DateTime: occurrence.Add(2 * time.Hour).UTC(), TimeZone: "America/Chicago"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if updated.Start != "2024-10-25T20:00:00-05:00" || updated.Color != "#dc2127" {
+		t.Fatalf("Unexpected updated event: %+v", updated)
+	}
+	events = service.GetEvents(start, end, []string{calendars[0].ID})
+	if len(events) != 3 || events[1].Start != "2024-11-08T20:00:00-06:00" || events[2].Title != "Board games" {
+		t.Fatalf("Unexpected events after the update: %+v", events)
+	}
+
+	if err := service.DeleteEvent(events[0].ID, true); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := service.DeleteEvent(events[0].ID, true); !errors.Is(err, ErrHouseholdNotFound) {
+		t.Fatalf("Expected the series to be gone, got %v", err)
+	}
+
+	// Removing a calendar removes its events, but the default calendar stays
+	if err := service.DeleteCalendar(calendars[0].ID); err == nil {
+		t.Fatal("Expected the default calendar to be kept")
+	}
+	if err := service.DeleteCalendar(chores.ID); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if service = newHouseholdService(); len(service.GetCalendarList()) != 1 || len(service.GetEvents(start, end, nil)) != 0 {
+		t.Fatal("Expected only the empty default calendar to be left")
+	}
+}
@@ -0,0 +1,257 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"woodhome-webapp/internal/models"
+)
+
+// hueIDSeparator separates the bridge name from the bridge's own ID, e.g. garage:3
+const hueIDSeparator = ":"
+
+// hueBridge is one bridge managed by the HueService.
+// Each bridge has its own command queue and is polled independently.
+// info, lastUpdate and resourceStatus are guarded by the HueService lock.
+type hueBridge struct {
+	id         string
+	prefix     string // Prepended to resource IDs, empty for the primary bridge
+	ip         string
+	username   string
+	baseURL    string
+	httpClient *http.Client
+	commands   *hueCommandQueue
+	fetches    map[string]*hueResourceFetch
+	started    sync.Once // Commands and polling
+
+	info           *models.HueBridge
+	lastUpdate     time.Time
+	resourceStatus map[string]*models.HueResourceStatus
+}
+
+// newHueBridge creates the state of one bridge. IDs of the primary bridge are not prefixed,
+// so single-bridge homes and references saved before a second bridge was added keep working.
+func newHueBridge(config models.HueBridgeConfig, primary bool, httpClient *http.Client) *hueBridge {
+	b := &hueBridge{
+		id:             config.ID,
+		ip:             config.IP,
+		username:       config.Username,
+		httpClient:     httpClient,
+		fetches:        newHueResourceFetches(),
+		resourceStatus: make(map[string]*models.HueResourceStatus),
+	}
+	if !primary {
+		b.prefix = config.ID + hueIDSeparator
+	}
+
+	b.commands = newHueCommandQueue(func(method, path string, payload interface{}) error {
+		_, err := b.bridgeRequest(method, path, payload)
+		return err
+	})
+	return b
+}
+
+// connect sets up the base URL once the bridge address and username are known
+func (b *hueBridge) connect() bool {
+	if b.ip == "" || b.username == "" {
+		return false
+	}
+	b.baseURL = fmt.Sprintf("http://%s/api/%s", b.ip, b.username)
+	return true
+}
+
+// namespaced returns the service-wide ID of one of the bridge's resources
+func (b *hueBridge) namespaced(localID string) string {
+	if localID == "" {
+		return ""
+	}
+	return b.prefix + localID
+}
+
+// namespacedAll returns the service-wide IDs of a list of the bridge's resources
+func (b *hueBridge) namespacedAll(localIDs []string) []string {
+	if localIDs == nil {
+		return nil
+	}
+	ids := make([]string, len(localIDs))
+	for i, localID := range localIDs {
+		ids[i] = b.namespaced(localID)
+	}
+	return ids
+}
+
+// namespaceScene rewrites the IDs of a scene read from the bridge into service-wide IDs
+func (b *hueBridge) namespaceScene(scene *models.HueScene) {
+	scene.ID = b.namespaced(scene.ID)
+	scene.BridgeID = b.id
+	scene.Group = b.namespaced(scene.Group)
+	scene.Lights = b.namespacedAll(scene.Lights)
+	if scene.LightStates != nil {
+		states := make(map[string]*models.HueSceneLightState, len(scene.LightStates))
+		for lightID, state := range scene.LightStates {
+			states[b.namespaced(lightID)] = state
+		}
+		scene.LightStates = states
+	}
+}
+
+// bridgeRequest sends a JSON request to the bridge API and checks the response for errors
+func (b *hueBridge) bridgeRequest(method, path string, payload interface{}) ([]models.HueAPIResponse, error) {
+	if b.baseURL == "" {
+		return nil, fmt.Errorf("bridge not configured")
+	}
+
+	var reqBody io.Reader
+	if payload != nil {
+		payloadBytes, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reqBody = bytes.NewReader(payloadBytes)
+	}
+
+	req, err := http.NewRequest(method, b.baseURL+path, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var responses []models.HueAPIResponse
+	if err := json.Unmarshal(body, &responses); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	// Check for errors in the response
+	for _, response := range responses {
+		if response.Error != nil {
+			return nil, fmt.Errorf("API error: %s", response.Error.Description)
+		}
+	}
+
+	return responses, nil
+}
+
+// primary returns the bridge configured by HUE_BRIDGE_IP
+func (h *HueService) primary() *hueBridge {
+	return h.bridges[0]
+}
+
+// resolve splits a service-wide ID into its bridge and the bridge's own ID.
+// IDs without a bridge prefix belong to the primary bridge.
+func (h *HueService) resolve(id string) (*hueBridge, string, error) {
+	if bridgeID, localID, found := strings.Cut(id, hueIDSeparator); found {
+		b, err := h.bridgeByID(bridgeID)
+		if err != nil {
+			return nil, "", err
+		}
+		return b, localID, nil
+	}
+	return h.primary(), id, nil
+}
+
+// resolveAll converts service-wide IDs into IDs on the given bridge.
+// The result is never nil, so an empty list is sent to the bridge as [].
+func (h *HueService) resolveAll(b *hueBridge, ids []string) ([]string, error) {
+	localIDs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		owner, localID, err := h.resolve(id)
+		if err != nil {
+			return nil, err
+		}
+		if owner != b {
+			return nil, fmt.Errorf("%s is not on bridge %s", id, b.id)
+		}
+		localIDs = append(localIDs, localID)
+	}
+	return localIDs, nil
+}
+
+// bridgeByID returns a bridge by name, or the primary bridge for an empty name
+func (h *HueService) bridgeByID(bridgeID string) (*hueBridge, error) {
+	if bridgeID == "" {
+		return h.primary(), nil
+	}
+	for _, b := range h.bridges {
+		if b.id == bridgeID {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown bridge %s", bridgeID)
+}
+
+// connectedBridges returns the bridges with a base URL
+func (h *HueService) connectedBridges() []*hueBridge {
+	bridges := make([]*hueBridge, 0, len(h.bridges))
+	for _, b := range h.bridges {
+		if b.baseURL != "" {
+			bridges = append(bridges, b)
+		}
+	}
+	return bridges
+}
+
+// GetBridgeStatuses reports the connection and refresh state of every bridge
+func (h *HueService) GetBridgeStatuses() []*models.HueBridgeStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	statuses := make([]*models.HueBridgeStatus, 0, len(h.bridges))
+	for i, b := range h.bridges {
+		status := &models.HueBridgeStatus{
+			ID:         b.id,
+			IP:         b.ip,
+			Primary:    i == 0,
+			Configured: b.baseURL != "",
+			LastUpdate: b.lastUpdate,
+			Refresh:    b.refreshStatus(),
+		}
+		if b.info != nil {
+			info := *b.info
+			status.Bridge = &info
+		}
+		for _, light := range h.lights {
+			if light.BridgeID == b.id {
+				status.Lights++
+			}
+		}
+		for _, group := range h.groups {
+			if group.BridgeID == b.id && group.Type == models.HueGroupTypeRoom {
+				status.Rooms++
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// mergeBridgeItems returns a copy of items with the entries of one bridge replaced
+func mergeBridgeItems[T any](items, replacement map[string]T, ofBridge func(T) bool) map[string]T {
+	merged := make(map[string]T, len(items)+len(replacement))
+	for id, item := range items {
+		if !ofBridge(item) {
+			merged[id] = item
+		}
+	}
+	for id, item := range replacement {
+		merged[id] = item
+	}
+	return merged
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"woodhome-webapp/internal/models"
+)
+
+func TestMultipleBridgesAreNamespaced(t *testing.T) {
+	newBridge := func(name string, commands *[]string, mu *sync.Mutex) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.Method == "GET" && r.URL.Path == "/lights":
+				w.Write([]byte(`{"1":{"name":"` + name + ` lamp","type":"Dimmable light","state":{"on":false,"bri":100,"reachable":true}}}`))
+			case r.Method == "GET" && r.URL.Path == "/groups":
+				w.Write([]byte(`{"1":{"name":"` + name + `","type":"Room","lights":["1"],"state":{"any_on":false}}}`))
+			case r.Method == "PUT":
+				mu.Lock()
+				*commands = append(*commands, r.URL.Path)
+				mu.Unlock()
+				w.Write([]byte(`[{"success":{}}]`))
+			default:
+				w.Write([]byte(`{}`))
+			}
+		}))
+	}
+
+	var mu sync.Mutex
+	var homeCommands, garageCommands []string
+	home := newBridge("House", &homeCommands, &mu)
+	defer home.Close()
+	garage := newBridge("Garage", &garageCommands, &mu)
+	defer garage.Close()
+
+	service := NewHueService(&models.HueServiceConfig{
+		Bridges: []models.HueBridgeConfig{{ID: "garage"}},
+	})
+	service.primary().baseURL = home.URL
+	garageBridge, err := service.bridgeByID("garage")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	garageBridge.baseURL = garage.URL
+
+	if err := service.refreshResources(true, hueResourceLights, hueResourceGroups); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if lights := service.GetLights(); len(lights) != 2 {
+		t.Fatalf("Expected the lights of both bridges, got %d", len(lights))
+	}
+	room, exists := service.GetGroup("garage:1")
+	if !exists || room.BridgeID != "garage" || len(room.Lights) != 1 || room.Lights[0] != "garage:1" {
+		t.Fatalf("Expected the garage room with namespaced lights, got %+v", room)
+	}
+	if room, exists := service.GetGroup("1"); !exists || room.Name != "House" || room.BridgeID != "home" {
+		t.Fatalf("Expected unprefixed IDs for the primary bridge, got %+v", room)
+	}
+
+	// Refreshing one bridge keeps the state of the other
+	if err := service.refreshBridge(garageBridge, true, hueResourceLights); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if lights := service.GetLights(); len(lights) != 2 {
+		t.Fatalf("Expected the lights of both bridges after refreshing one, got %d", len(lights))
+	}
+
+	on := true
+	service.primary().commands.Start(t.Context())
+	garageBridge.commands.Start(t.Context())
+	if err := service.SetLightState("garage:1", &models.HueLightState{On: &on}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	mu.Lock()
+	if len(garageCommands) != 1 || garageCommands[0] != "/lights/1/state" || len(homeCommands) != 0 {
+		t.Fatalf("Expected the command to go to the garage bridge with its own ID, got %v and %v", garageCommands, homeCommands)
+	}
+	mu.Unlock()
+
+	// All lights flash on every bridge
+	alert := "lselect"
+	if err := service.SetAllLightsState(&models.HueGroupState{Alert: &alert}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	mu.Lock()
+	if len(garageCommands) != 2 || garageCommands[1] != "/groups/0/action" || len(homeCommands) != 1 || homeCommands[0] != "/groups/0/action" {
+		t.Fatalf("Expected group 0 of both bridges to be alerted, got %v and %v", garageCommands, homeCommands)
+	}
+	mu.Unlock()
+
+	if _, _, err := service.resolve("shed:1"); err == nil {
+		t.Fatal("Expected an unknown bridge to be refused")
+	}
+	if statuses := service.GetBridgeStatuses(); len(statuses) != 2 || statuses[1].Lights != 1 || statuses[1].Rooms != 1 {
+		t.Fatalf("Unexpected bridge statuses: %+v", statuses)
+	}
+}
+
+func TestDeleteSceneAcceptsStringSuccess(t *testing.T) {
+	var mu sync.Mutex
+	scenes := `{"abc":{"name":"Relax","type":"LightScene","lights":["1"]}}`
+	bridge := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/scenes":
+			w.Write([]byte(scenes))
+		case r.Method == "GET" && r.URL.Path == "/scenes/abc":
+			w.Write([]byte(`[{"error":{"type":3,"address":"/scenes/abc","description":"resource, /scenes/abc, not available"}}]`))
+		case r.Method == "DELETE" && r.URL.Path == "/scenes/abc":
+			scenes = `{}`
+			w.Write([]byte(`[{"success":"/scenes/abc deleted"}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer bridge.Close()
+
+	service := NewHueService(nil)
+	service.primary().baseURL = bridge.URL
+	if err := service.refreshResources(true, hueResourceScenes); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if scenes := service.GetScenes(); len(scenes) != 1 {
+		t.Fatalf("Expected the scene to be loaded, got %d", len(scenes))
+	}
+
+	if err := service.DeleteScene("abc"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if scenes := service.GetScenes(); len(scenes) != 0 {
+		t.Fatalf("Expected the scene to be removed, got %d", len(scenes))
+	}
+	if _, err := service.GetScene("abc"); !errors.Is(err, ErrHueResourceNotFound) {
+		t.Fatalf("Expected the deleted scene to be reported missing, got %v", err)
+	}
+}
+
+func TestBridgeAuthenticatedLaterSendsCommands(t *testing.T) {
+	bridge := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/api":
+			w.Write([]byte(`[{"success":{"username":"woodhome"}}]`))
+		case r.Method == "PUT" && r.URL.Path == "/api/woodhome/lights/1/state":
+			w.Write([]byte(`[{"success":{"/lights/1/state/on":true}}]`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer bridge.Close()
+
+	service := NewHueService(&models.HueServiceConfig{BridgeIP: strings.TrimPrefix(bridge.URL, "http://"), PollInterval: time.Minute})
+	if err := service.Start(t.Context()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := service.StartAuth(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	result := make(chan error, 1)
+	on := true
+	go func() { result <- service.SetLightState("1", &models.HueLightState{On: &on}) }()
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the command to be sent after authenticating")
+	}
+}
@@ -0,0 +1,458 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"woodhome-webapp/internal/color"
+	"woodhome-webapp/internal/models"
+	"woodhome-webapp/internal/solar"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Circadian tolerances: smaller target changes are not sent, larger light differences count as a manual change
+const (
+	circadianMiredStep       = 5
+	circadianBrightnessStep  = 5
+	circadianMiredSlack      = 12
+	circadianBrightnessSlack = 12
+)
+
+// ambianceMaxMired is the warmest color temperature of white ambiance lights
+const ambianceMaxMired = 454
+
+// circadianRoomState tracks a room between controller ticks
+type circadianRoomState struct {
+	room  models.HueCircadianRoom
+	wasOn bool
+}
+
+// circadianApply is a room the controller adjusts once the lock is released
+type circadianApply struct {
+	state *circadianRoomState
+	group *models.HueGroup
+}
+
+// HueCircadianService makes Hue rooms follow the sun
+type HueCircadianService struct {
+	config     *models.HueCircadianConfig
+	hueService *HueService
+	db         *sql.DB
+	rooms      map[string]*circadianRoomState
+	now        func() time.Time
+	mu         sync.Mutex
+}
+
+// NewHueCircadianService creates a new HueCircadianService instance.
+// Rooms are kept in memory only when db is nil.
+func NewHueCircadianService(hueService *HueService, db *sql.DB, config *models.HueCircadianConfig) *HueCircadianService {
+	if config == nil {
+		config = &models.HueCircadianConfig{}
+	}
+	if config.Interval <= 0 {
+		config.Interval = 30 * time.Second
+	}
+	if config.TransitionTime <= 0 {
+		config.TransitionTime = 40 // 4 seconds
+	}
+	if config.MaxKelvin <= 0 {
+		config.MaxKelvin = 5000
+	}
+	if config.EveningKelvin <= 0 {
+		config.EveningKelvin = 2700
+	}
+	if config.NightKelvin <= 0 {
+		config.NightKelvin = 2000
+	}
+	if config.MaxBrightness <= 0 {
+		config.MaxBrightness = 254
+	}
+	if config.EveningBrightness <= 0 {
+		config.EveningBrightness = 150
+	}
+	if config.NightBrightness <= 0 {
+		config.NightBrightness = 25
+	}
+	if config.NightStart == "" {
+		config.NightStart = "22:30"
+	}
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+
+	c := &HueCircadianService{
+		config:     config,
+		hueService: hueService,
+		db:         db,
+		rooms:      make(map[string]*circadianRoomState),
+		now:        time.Now,
+	}
+	for _, roomID := range config.Rooms {
+		c.rooms[roomID] = &circadianRoomState{room: models.HueCircadianRoom{RoomID: roomID, Enabled: true}}
+	}
+	return c
+}
+
+// Start begins adjusting the enabled rooms on every interval
+func (c *HueCircadianService) Start(ctx context.Context) error {
+	if c.config.Latitude == 0 && c.config.Longitude == 0 {
+		logrus.Warn("Circadian lighting: HOME_LATITUDE and HOME_LONGITUDE are not set, sun times will be wrong")
+	}
+
+	logrus.Info("Starting Hue circadian lighting service...")
+	if err := c.load(); err != nil {
+		return fmt.Errorf("failed to load circadian rooms: %w", err)
+	}
+	go c.startLoop(ctx)
+	return nil
+}
+
+// startLoop runs the controller until the context is cancelled
+func (c *HueCircadianService) startLoop(ctx context.Context) {
+	ticker := time.NewTicker(c.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.tick()
+		}
+	}
+}
+
+// SetRoom enables or disables the circadian mode for a room
+func (c *HueCircadianService) SetRoom(roomID string, enabled bool) (*models.HueCircadianRoom, error) {
+	if _, exists := c.hueService.GetGroup(roomID); !exists {
+		return nil, fmt.Errorf("room %s not found", roomID)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.save(models.HueCircadianRoom{RoomID: roomID, Enabled: enabled}); err != nil {
+		return nil, err
+	}
+	state, exists := c.rooms[roomID]
+	if !exists {
+		state = &circadianRoomState{room: models.HueCircadianRoom{RoomID: roomID}}
+		c.rooms[roomID] = state
+	}
+	state.room.Enabled = enabled
+	state.room.Paused = false
+	state.room.LastApplied = nil
+	// Treat the next tick as a power-on so the room is adjusted right away
+	state.wasOn = false
+
+	room := state.room
+	return &room, nil
+}
+
+// RemoveRoom forgets the circadian mode of a room
+func (c *HueCircadianService) RemoveRoom(roomID string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.rooms[roomID]; !exists {
+		return false, nil
+	}
+	if c.db != nil {
+		if _, err := c.db.Exec(`DELETE FROM hue_circadian_rooms WHERE id = ?`, roomID); err != nil {
+			return false, fmt.Errorf("failed to delete circadian room: %w", err)
+		}
+	}
+	delete(c.rooms, roomID)
+	return true, nil
+}
+
+// Resume lifts a manual-change pause without waiting for the next power-on
+func (c *HueCircadianService) Resume(roomID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state, exists := c.rooms[roomID]
+	if !exists {
+		return fmt.Errorf("circadian mode is not set up for room %s", roomID)
+	}
+	state.room.Paused = false
+	state.room.LastApplied = nil
+	state.wasOn = false
+	return nil
+}
+
+// GetStatus returns the current target and the state of every room
+func (c *HueCircadianService) GetStatus() *models.HueCircadianStatus {
+	now := c.now().In(c.config.Location)
+	sun := solar.TimesFor(now, c.config.Latitude, c.config.Longitude)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status := &models.HueCircadianStatus{
+		Target:  circadianTarget(now, sun, c.config),
+		Sunrise: sun.Sunrise,
+		Sunset:  sun.Sunset,
+		Rooms:   make([]*models.HueCircadianRoom, 0, len(c.rooms)),
+	}
+	for _, state := range c.rooms {
+		room := state.room
+		status.Rooms = append(status.Rooms, &room)
+	}
+	sort.Slice(status.Rooms, func(i, j int) bool {
+		return status.Rooms[i].RoomID < status.Rooms[j].RoomID
+	})
+	return status
+}
+
+// tick adjusts every enabled room that is on and not paused.
+// The rooms are picked under the lock and adjusted after it is released,
+// since the group command lane only sends one command per second.
+func (c *HueCircadianService) tick() {
+	now := c.now().In(c.config.Location)
+	target := circadianTarget(now, solar.TimesFor(now, c.config.Latitude, c.config.Longitude), c.config)
+
+	for _, update := range c.pendingRooms(now, target) {
+		c.apply(update.state, update.group, target)
+	}
+}
+
+// pendingRooms returns the rooms that need the target, pausing rooms that were changed by hand
+func (c *HueCircadianService) pendingRooms(now time.Time, target models.HueCircadianTarget) []circadianApply {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var updates []circadianApply
+	for roomID, state := range c.rooms {
+		if !state.room.Enabled {
+			continue
+		}
+		group, exists := c.hueService.GetGroup(roomID)
+		if !exists {
+			continue
+		}
+
+		if !group.IsOn {
+			state.wasOn = false
+			continue
+		}
+
+		poweredOn := !state.wasOn
+		state.wasOn = true
+		if poweredOn {
+			if state.room.Paused {
+				logrus.Infof("Circadian lighting: room %s was switched on, resuming", group.Name)
+				state.room.Paused = false
+			}
+			updates = append(updates, circadianApply{state: state, group: group})
+			continue
+		}
+
+		if state.room.Paused {
+			continue
+		}
+		if state.room.LastApplied != nil && c.manuallyChanged(group, state.room.LastApplied) {
+			logrus.Infof("Circadian lighting: lights in room %s were changed by hand, pausing until next power-on", group.Name)
+			state.room.Paused = true
+			state.room.PausedAt = now
+			continue
+		}
+		if state.room.LastApplied == nil ||
+			abs(state.room.LastApplied.Mired-target.Mired) >= circadianMiredStep ||
+			abs(state.room.LastApplied.Brightness-target.Brightness) >= circadianBrightnessStep {
+			updates = append(updates, circadianApply{state: state, group: group})
+		}
+	}
+	return updates
+}
+
+// apply sends the target to a room and records the result
+func (c *HueCircadianService) apply(state *circadianRoomState, group *models.HueGroup, target models.HueCircadianTarget) {
+	mired := target.Mired
+	brightness := target.Brightness
+	transition := c.config.TransitionTime
+	groupState := &models.HueGroupState{
+		ColorTemp:      &mired,
+		Brightness:     &brightness,
+		TransitionTime: &transition,
+	}
+
+	err := c.hueService.SetGroupState(group.ID, groupState)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		logrus.Warnf("Circadian lighting: failed to adjust room %s: %v", group.Name, err)
+		state.room.LastError = err.Error()
+		return
+	}
+
+	applied := target
+	state.room.LastApplied = &applied
+	state.room.LastAppliedAt = c.now()
+	state.room.LastError = ""
+	logrus.Debugf("Circadian lighting: room %s set to %dK at brightness %d", group.Name, target.Kelvin, target.Brightness)
+}
+
+// manuallyChanged reports whether a lit white light of the room no longer shows what was last applied
+func (c *HueCircadianService) manuallyChanged(group *models.HueGroup, applied *models.HueCircadianTarget) bool {
+	lights := make(map[string]*models.HueLight)
+	for _, light := range c.hueService.GetLights() {
+		lights[light.ID] = light
+	}
+
+	for _, lightID := range group.Lights {
+		light, exists := lights[lightID]
+		if !exists || !light.IsOn || !light.IsReachable || !lightSupportsColorTemp(light) {
+			continue
+		}
+		if light.ColorMode != "" && light.ColorMode != "ct" {
+			return true
+		}
+
+		expected := applied.Mired
+		if light.Type == "Color temperature light" && expected > ambianceMaxMired {
+			expected = ambianceMaxMired
+		}
+		if abs(light.ColorTemp-expected) > circadianMiredSlack ||
+			abs(light.Brightness-applied.Brightness) > circadianBrightnessSlack {
+			return true
+		}
+	}
+	return false
+}
+
+// circadianTarget computes the color temperature and brightness for a moment of the day.
+// Between sunrise and sunset the light follows a sine from the evening values up to the
+// mid-day maximum, after sunset it fades to the night light which lasts until sunrise.
+func circadianTarget(now time.Time, sun solar.Times, config *models.HueCircadianConfig) models.HueCircadianTarget {
+	sunrise, sunset := sun.Sunrise, sun.Sunset
+	if sun.MidnightSun || sun.PolarNight {
+		// Pretend the day lasts twelve hours around solar noon
+		sunrise = sun.SolarNoon.Add(-6 * time.Hour)
+		sunset = sun.SolarNoon.Add(6 * time.Hour)
+	}
+	nightStart := clockTime(now, config.NightStart, sunset.Add(3*time.Hour))
+
+	var kelvin, brightness float64
+	var phase string
+	switch {
+	case !now.Before(sunrise) && now.Before(sunset) && now.Before(nightStart):
+		phase = "day"
+		progress := now.Sub(sunrise).Seconds() / sunset.Sub(sunrise).Seconds()
+		level := math.Sin(math.Pi * progress)
+		kelvin = lerp(float64(config.EveningKelvin), float64(config.MaxKelvin), level)
+		brightness = lerp(float64(config.EveningBrightness), float64(config.MaxBrightness), level)
+
+	case !now.Before(sunset) && now.Before(nightStart):
+		phase = "evening"
+		progress := now.Sub(sunset).Seconds() / nightStart.Sub(sunset).Seconds()
+		kelvin = lerp(float64(config.EveningKelvin), float64(config.NightKelvin), progress)
+		brightness = lerp(float64(config.EveningBrightness), float64(config.NightBrightness), progress)
+
+	default:
+		phase = "night"
+		kelvin = float64(config.NightKelvin)
+		brightness = float64(config.NightBrightness)
+	}
+
+	mired := color.ClampMired(color.KelvinToMired(int(math.Round(kelvin))))
+	return models.HueCircadianTarget{
+		Phase:      phase,
+		Kelvin:     color.MiredToKelvin(mired),
+		Mired:      mired,
+		Brightness: int(math.Round(math.Max(1, math.Min(254, brightness)))),
+	}
+}
+
+// clockTime returns the given HH:MM on the day of now, or fallback if it cannot be parsed
+func clockTime(now time.Time, clock string, fallback time.Time) time.Time {
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		return fallback
+	}
+	return time.Date(now.Year(), now.Month(), now.Day(), parsed.Hour(), parsed.Minute(), 0, 0, now.Location())
+}
+
+// lerp interpolates between a and b
+func lerp(a, b, t float64) float64 {
+	t = math.Max(0, math.Min(1, t))
+	return a + (b-a)*t
+}
+
+// abs returns the absolute value of an int
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+// load reads the saved room settings, which take precedence over the configured rooms
+func (c *HueCircadianService) load() error {
+	if c.db == nil {
+		return nil
+	}
+
+	_, err := c.db.Exec(`
+		CREATE TABLE IF NOT EXISTS hue_circadian_rooms (
+			id TEXT PRIMARY KEY,
+			data TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	rows, err := c.db.Query(`SELECT id, data FROM hue_circadian_rooms`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for rows.Next() {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			return err
+		}
+		var room models.HueCircadianRoom
+		if err := json.Unmarshal([]byte(data), &room); err != nil {
+			logrus.Warnf("Circadian lighting: ignoring unreadable room %s: %v", id, err)
+			continue
+		}
+		c.rooms[id] = &circadianRoomState{room: models.HueCircadianRoom{RoomID: id, Enabled: room.Enabled}}
+	}
+	return rows.Err()
+}
+
+// save stores whether circadian mode is enabled for a room
+func (c *HueCircadianService) save(room models.HueCircadianRoom) error {
+	if c.db == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(room)
+	if err != nil {
+		return fmt.Errorf("failed to marshal circadian room: %w", err)
+	}
+	_, err = c.db.Exec(`
+		INSERT OR REPLACE INTO hue_circadian_rooms (id, data, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+	`, room.RoomID, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save circadian room: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"woodhome-webapp/internal/database"
+	"woodhome-webapp/internal/models"
+	"woodhome-webapp/internal/solar"
+)
+
+func TestCircadianTarget(t *testing.T) {
+	config := NewHueCircadianService(nil, nil, nil).config
+	day := func(hour, minute int) time.Time {
+		return time.Date(2024, 3, 20, hour, minute, 0, 0, time.UTC)
+	}
+	sun := solar.Times{Sunrise: day(6, 0), SolarNoon: day(12, 0), Sunset: day(18, 0)}
+
+	noon := circadianTarget(day(12, 0), sun, config)
+	if noon.Phase != "day" || noon.Kelvin < 4900 || noon.Brightness != 254 {
+		t.Fatalf("Expected cool bright white at noon, got %+v", noon)
+	}
+
+	morning := circadianTarget(day(7, 0), sun, config)
+	if morning.Kelvin >= noon.Kelvin || morning.Brightness >= noon.Brightness {
+		t.Fatalf("Expected the morning to be warmer and dimmer than noon, got %+v", morning)
+	}
+
+	evening := circadianTarget(day(20, 0), sun, config)
+	if evening.Phase != "evening" || evening.Kelvin > 2700 || evening.Brightness >= 150 {
+		t.Fatalf("Expected warm dim light in the evening, got %+v", evening)
+	}
+
+	for _, late := range []time.Time{day(23, 0), day(3, 0)} {
+		night := circadianTarget(late, sun, config)
+		if night.Phase != "night" || night.Kelvin != 2000 || night.Brightness != 25 {
+			t.Fatalf("Expected night light at %s, got %+v", late.Format(time.Kitchen), night)
+		}
+	}
+}
+
+func TestCircadianRoomsAreSaved(t *testing.T) {
+	db, err := database.OpenSQLite(filepath.Join(t.TempDir(), "home.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	hueService := NewHueService(nil)
+	hueService.groups["1"] = &models.HueGroup{ID: "1", Name: "Kitchen", Type: "Room"}
+	hueService.groups["2"] = &models.HueGroup{ID: "2", Name: "Bedroom", Type: "Room"}
+
+	newCircadianService := func() *HueCircadianService {
+		service := NewHueCircadianService(hueService, db, &models.HueCircadianConfig{Rooms: []string{"2"}})
+		if err := service.load(); err != nil {
+			t.Fatalf("Failed to load rooms: %v", err)
+		}
+		return service
+	}
+	enabled := func(service *HueCircadianService) map[string]bool {
+		rooms := make(map[string]bool)
+		for _, room := range service.GetStatus().Rooms {
+			rooms[room.RoomID] = room.Enabled
+		}
+		return rooms
+	}
+
+	service := newCircadianService()
+	if _, err := service.SetRoom("1", true); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := service.SetRoom("2", false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// A room disabled in the app stays disabled even though it is configured
+	service = newCircadianService()
+	if rooms := enabled(service); len(rooms) != 2 || !rooms["1"] || rooms["2"] {
+		t.Fatalf("Unexpected rooms after a restart: %v", rooms)
+	}
+
+	if removed, err := service.RemoveRoom("1"); err != nil || !removed {
+		t.Fatalf("Expected the room to be removed, got %v", err)
+	}
+	if rooms := enabled(newCircadianService()); len(rooms) != 1 || rooms["2"] {
+		t.Fatalf("Unexpected rooms after removing one: %v", rooms)
+	}
+}
+
+func TestCircadianUsesHomeTimeZone(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Skip("No time zone data")
+	}
+	service := NewHueCircadianService(nil, nil, &models.HueCircadianConfig{
+		Latitude:  41.88,
+		Longitude: -87.63,
+		Location:  chicago,
+	})
+
+	// 10 PM in Chicago is 3 AM the next day in UTC, which is still the evening at home
+	service.now = func() time.Time { return time.Date(2024, 3, 21, 3, 0, 0, 0, time.UTC) }
+	if target := service.GetStatus().Target; target.Phase != "evening" {
+		t.Fatalf("Expected the evening at 10 PM at home, got %+v", target)
+	}
+	service.now = func() time.Time { return time.Date(2024, 3, 21, 4, 0, 0, 0, time.UTC) }
+	if target := service.GetStatus().Target; target.Phase != "night" {
+		t.Fatalf("Expected the night light after 10:30 PM at home, got %+v", target)
+	}
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"fmt"
+	"math"
+
+	"woodhome-webapp/internal/color"
+	"woodhome-webapp/internal/models"
+)
+
+// LightColorState converts a color request into a light state that the given light can show
+func (h *HueService) LightColorState(lightID string, req *models.HueColorRequest) (*models.HueLightState, error) {
+	h.mu.RLock()
+	light, exists := h.lights[lightID]
+	h.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("light %s not found", lightID)
+	}
+
+	state := &models.HueLightState{TransitionTime: req.TransitionTime}
+
+	switch {
+	case req.Hex != nil:
+		if !lightSupportsColor(light) {
+			return nil, fmt.Errorf("light %s does not support colors", light.Name)
+		}
+		rgb, err := color.ParseHex(*req.Hex)
+		if err != nil {
+			return nil, err
+		}
+		xy, _ := rgb.ToXY()
+		xy = lightGamut(light).Clamp(xy)
+		state.XY = []float64{xy.X, xy.Y}
+		state.Brightness = hexBrightness(rgb)
+
+	case req.Kelvin != nil:
+		switch {
+		case lightSupportsColorTemp(light):
+			mired := color.ClampMired(color.KelvinToMired(*req.Kelvin))
+			state.ColorTemp = &mired
+		case lightSupportsColor(light):
+			xy := lightGamut(light).Clamp(color.KelvinToXY(*req.Kelvin))
+			state.XY = []float64{xy.X, xy.Y}
+		default:
+			return nil, fmt.Errorf("light %s does not support color temperature", light.Name)
+		}
+
+	case len(req.XY) == 2:
+		xy := lightGamut(light).Clamp(color.XY{X: req.XY[0], Y: req.XY[1]})
+		state.XY = []float64{xy.X, xy.Y}
+
+	case req.ColorTemp != nil:
+		mired := color.ClampMired(*req.ColorTemp)
+		state.ColorTemp = &mired
+
+	case req.Hue != nil || req.Saturation != nil:
+		state.Hue = req.Hue
+		state.Saturation = req.Saturation
+
+	default:
+		return nil, fmt.Errorf("no color given")
+	}
+
+	return state, nil
+}
+
+// GroupColorState converts a color request into a group action.
+// Groups mix lights of different gamuts, so xy colors are clamped to the widest gamut
+// and the bridge maps them onto each light.
+func GroupColorState(req *models.HueColorRequest) (*models.HueGroupState, error) {
+	state := &models.HueGroupState{TransitionTime: req.TransitionTime}
+
+	switch {
+	case req.Hex != nil:
+		rgb, err := color.ParseHex(*req.Hex)
+		if err != nil {
+			return nil, err
+		}
+		xy, _ := rgb.ToXY()
+		xy = color.GamutC.Clamp(xy)
+		state.XY = []float64{xy.X, xy.Y}
+		state.Brightness = hexBrightness(rgb)
+
+	case req.Kelvin != nil:
+		mired := color.ClampMired(color.KelvinToMired(*req.Kelvin))
+		state.ColorTemp = &mired
+
+	case len(req.XY) == 2:
+		xy := color.GamutC.Clamp(color.XY{X: req.XY[0], Y: req.XY[1]})
+		state.XY = []float64{xy.X, xy.Y}
+
+	case req.ColorTemp != nil:
+		mired := color.ClampMired(*req.ColorTemp)
+		state.ColorTemp = &mired
+
+	case req.Hue != nil || req.Saturation != nil:
+		state.Hue = req.Hue
+		state.Saturation = req.Saturation
+
+	default:
+		return nil, fmt.Errorf("no color given")
+	}
+
+	return state, nil
+}
+
+// hexBrightness maps the HSV value of a hex color to a Hue brightness (1-254)
+func hexBrightness(rgb color.RGB) *int {
+	_, _, value := rgb.ToHSV()
+	brightness := int(math.Max(1, math.Round(value*254)))
+	return &brightness
+}
+
+// lightGamut returns the gamut reported by the light, falling back to its model ID
+func lightGamut(light *models.HueLight) color.Gamut {
+	if gamut, ok := color.GamutFromPoints(light.ColorGamut); ok {
+		return gamut
+	}
+	if gamut, ok := color.GamutByType(light.GamutType); ok {
+		return gamut
+	}
+	return color.GamutForModel(light.ModelID)
+}
+
+// lightSupportsColor reports whether a light can show xy or hue/sat colors
+func lightSupportsColor(light *models.HueLight) bool {
+	switch light.Type {
+	case "Extended color light", "Color light":
+		return true
+	}
+	return len(light.ColorGamut) > 0 || len(light.XY) > 0
+}
+
+// lightSupportsColorTemp reports whether a light accepts mired color temperatures
+func lightSupportsColorTemp(light *models.HueLight) bool {
+	switch light.Type {
+	case "Extended color light", "Color temperature light":
+		return true
+	}
+	return light.ColorTemp > 0
+}
+
+// annotateLightColor fills in the hex and Kelvin description of a light's current color
+func annotateLightColor(light *models.HueLight) {
+	if light.ColorTemp > 0 {
+		light.Kelvin = color.MiredToKelvin(light.ColorTemp)
+	}
+
+	// Colors are reported at full brightness, brightness is reported separately
+	switch light.ColorMode {
+	case "xy":
+		if len(light.XY) == 2 {
+			light.Hex = color.FromXY(color.XY{X: light.XY[0], Y: light.XY[1]}, 1).Hex()
+		}
+	case "hs":
+		light.Hex = color.FromHueSat(light.Hue, light.Saturation, 254).Hex()
+	case "ct":
+		if light.Kelvin > 0 {
+			light.Hex = color.KelvinToRGB(light.Kelvin).Hex()
+		}
+	}
+}
+
+// hasColor reports whether a color request sets any color
+func hasColor(req *models.HueColorRequest) bool {
+	return req.Hex != nil || req.Kelvin != nil || len(req.XY) == 2 ||
+		req.ColorTemp != nil || req.Hue != nil || req.Saturation != nil
+}
+
+// annotateSceneLightState fills in the preview color of a stored scene light state
+func annotateSceneLightState(state *models.HueSceneLightState) {
+	switch {
+	case len(state.XY) == 2:
+		state.Hex = color.FromXY(color.XY{X: state.XY[0], Y: state.XY[1]}, 1).Hex()
+	case state.ColorTemp != nil && *state.ColorTemp > 0:
+		state.Hex = color.KelvinToRGB(color.MiredToKelvin(*state.ColorTemp)).Hex()
+	case state.Hue != nil && state.Saturation != nil:
+		state.Hex = color.FromHueSat(*state.Hue, *state.Saturation, 254).Hex()
+	}
+}
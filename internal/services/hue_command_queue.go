@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Bridge command rates recommended by Philips Hue
+const (
+	hueLightCommandInterval = 100 * time.Millisecond // ~10 light commands per second
+	hueGroupCommandInterval = time.Second            // ~1 group command per second
+)
+
+// hueColorModes maps the state keys that select a color to their color mode.
+// Hue and saturation together make up one mode, so either can be changed on its own.
+var hueColorModes = map[string]string{"xy": "xy", "ct": "ct", "hue": "hs", "sat": "hs"}
+
+// hueCommand is a pending state change for one light or group
+type hueCommand struct {
+	path    string
+	payload map[string]interface{}
+	waiters []chan error
+}
+
+// hueCommandLane sends the commands of one kind at a fixed rate.
+// Commands for the same target are coalesced while they wait, so the latest value wins.
+type hueCommandLane struct {
+	interval time.Duration
+	pending  map[string]*hueCommand
+	order    []string
+	wake     chan struct{}
+	stopped  bool
+	mu       sync.Mutex
+}
+
+// hueCommandQueue schedules state changes to a bridge within its rate limits
+type hueCommandQueue struct {
+	send   func(method, path string, payload interface{}) error
+	lights *hueCommandLane
+	groups *hueCommandLane
+}
+
+// newHueCommandQueue creates a command queue that sends commands through send
+func newHueCommandQueue(send func(method, path string, payload interface{}) error) *hueCommandQueue {
+	return &hueCommandQueue{
+		send:   send,
+		lights: newHueCommandLane(hueLightCommandInterval),
+		groups: newHueCommandLane(hueGroupCommandInterval),
+	}
+}
+
+// newHueCommandLane creates an empty lane sending at most one command per interval
+func newHueCommandLane(interval time.Duration) *hueCommandLane {
+	return &hueCommandLane{
+		interval: interval,
+		pending:  make(map[string]*hueCommand),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Start runs the lanes until the context is cancelled
+func (q *hueCommandQueue) Start(ctx context.Context) {
+	go q.lights.run(ctx, q.send)
+	go q.groups.run(ctx, q.send)
+}
+
+// SetLightState queues a light state change and waits for the bridge result
+func (q *hueCommandQueue) SetLightState(lightID string, state interface{}) error {
+	return q.enqueue(q.lights, "light:"+lightID, fmt.Sprintf("/lights/%s/state", lightID), state)
+}
+
+// SetGroupState queues a group action and waits for the bridge result
+func (q *hueCommandQueue) SetGroupState(groupID string, state interface{}) error {
+	return q.enqueue(q.groups, "group:"+groupID, fmt.Sprintf("/groups/%s/action", groupID), state)
+}
+
+// enqueue adds a command to a lane and waits for it to be sent
+func (q *hueCommandQueue) enqueue(lane *hueCommandLane, key, path string, state interface{}) error {
+	payload, err := statePayload(state)
+	if err != nil {
+		return err
+	}
+
+	result := make(chan error, 1)
+
+	lane.mu.Lock()
+	if lane.stopped {
+		lane.mu.Unlock()
+		return fmt.Errorf("hue command queue stopped")
+	}
+	if cmd, exists := lane.pending[key]; exists {
+		mergeStatePayload(cmd.payload, payload)
+		cmd.waiters = append(cmd.waiters, result)
+	} else {
+		lane.pending[key] = &hueCommand{path: path, payload: payload, waiters: []chan error{result}}
+		lane.order = append(lane.order, key)
+	}
+	lane.mu.Unlock()
+
+	select {
+	case lane.wake <- struct{}{}:
+	default:
+	}
+
+	return <-result
+}
+
+// run sends queued commands in arrival order, one per interval
+func (l *hueCommandLane) run(ctx context.Context, send func(method, path string, payload interface{}) error) {
+	for {
+		select {
+		case <-ctx.Done():
+			l.fail(fmt.Errorf("hue command queue stopped"))
+			return
+		case <-l.wake:
+		}
+
+		for {
+			cmd := l.next()
+			if cmd == nil {
+				break
+			}
+
+			err := send("PUT", cmd.path, cmd.payload)
+			for _, waiter := range cmd.waiters {
+				waiter <- err
+			}
+
+			select {
+			case <-ctx.Done():
+				l.fail(fmt.Errorf("hue command queue stopped"))
+				return
+			case <-time.After(l.interval):
+			}
+		}
+	}
+}
+
+// next removes and returns the oldest pending command
+func (l *hueCommandLane) next() *hueCommand {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.order) == 0 {
+		return nil
+	}
+	key := l.order[0]
+	l.order = l.order[1:]
+	cmd := l.pending[key]
+	delete(l.pending, key)
+	return cmd
+}
+
+// fail returns an error to everyone still waiting on the lane and refuses new commands
+func (l *hueCommandLane) fail(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, cmd := range l.pending {
+		for _, waiter := range cmd.waiters {
+			waiter <- err
+		}
+	}
+	l.pending = make(map[string]*hueCommand)
+	l.order = nil
+	l.stopped = true
+}
+
+// statePayload converts a light or group state into a JSON object
+func statePayload(state interface{}) (map[string]interface{}, error) {
+	stateBytes, err := json.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(stateBytes, &payload); err != nil {
+		return nil, fmt.Errorf("failed to marshal state: %w", err)
+	}
+	return payload, nil
+}
+
+// mergeStatePayload overlays a newer state onto a pending one.
+// A newer color in another color mode replaces the pending color keys.
+func mergeStatePayload(pending, newer map[string]interface{}) {
+	modes := make(map[string]bool)
+	for key := range newer {
+		if mode, isColor := hueColorModes[key]; isColor {
+			modes[mode] = true
+		}
+	}
+	if len(modes) > 0 {
+		for key := range pending {
+			if mode, isColor := hueColorModes[key]; isColor && !modes[mode] {
+				delete(pending, key)
+			}
+		}
+	}
+	for key, value := range newer {
+		pending[key] = value
+	}
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"woodhome-webapp/internal/models"
+)
+
+func TestHueCommandQueueCoalesces(t *testing.T) {
+	var mu sync.Mutex
+	var sent []map[string]interface{}
+	block := make(chan struct{})
+
+	queue := newHueCommandQueue(func(method, path string, payload interface{}) error {
+		<-block
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, payload.(map[string]interface{}))
+		return nil
+	})
+	queue.lights.interval = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	queue.Start(ctx)
+
+	on := true
+	xy := []float64{0.5, 0.4}
+	results := make(chan error, 4)
+
+	// The first command is picked up and blocks the lane while the others queue up behind it
+	go func() { results <- queue.SetLightState("1", &models.HueLightState{On: &on}) }()
+	time.Sleep(20 * time.Millisecond)
+
+	for _, bri := range []int{50, 100} {
+		bri := bri
+		go func() { results <- queue.SetLightState("2", &models.HueLightState{Brightness: &bri, XY: xy}) }()
+		time.Sleep(20 * time.Millisecond)
+	}
+	ct := 300
+	go func() { results <- queue.SetLightState("2", &models.HueLightState{ColorTemp: &ct}) }()
+	time.Sleep(20 * time.Millisecond)
+	close(block)
+
+	for i := 0; i < 4; i++ {
+		if err := <-results; err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sent) != 2 {
+		t.Fatalf("Expected 2 commands after coalescing, got %d: %v", len(sent), sent)
+	}
+	merged := sent[1]
+	if merged["bri"] != float64(100) || merged["ct"] != float64(300) {
+		t.Fatalf("Expected latest brightness and color temperature, got %v", merged)
+	}
+	if _, exists := merged["xy"]; exists {
+		t.Fatalf("Expected newer color temperature to replace xy, got %v", merged)
+	}
+}
+
+func TestHueCommandQueueReturnsErrors(t *testing.T) {
+	queue := newHueCommandQueue(func(method, path string, payload interface{}) error {
+		return fmt.Errorf("API error: resource, %s, not available", path)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	queue.Start(ctx)
+
+	on := true
+	if err := queue.SetGroupState("7", &models.HueGroupState{On: &on}); err == nil {
+		t.Fatal("Expected bridge error to be returned")
+	}
+
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	if err := queue.SetGroupState("7", &models.HueGroupState{On: &on}); err == nil {
+		t.Fatal("Expected stopped queue to refuse commands")
+	}
+}
+
+func TestMergeStatePayloadKeepsColorMode(t *testing.T) {
+	// Hue and saturation are one color mode, so a newer hue keeps the pending saturation
+	pending := map[string]interface{}{"on": true, "sat": 200.0}
+	mergeStatePayload(pending, map[string]interface{}{"hue": 10000.0})
+	if pending["sat"] != 200.0 || pending["hue"] != 10000.0 || pending["on"] != true {
+		t.Fatalf("Expected hue and saturation to be combined, got %v", pending)
+	}
+
+	// Switching to another color mode drops both
+	mergeStatePayload(pending, map[string]interface{}{"xy": []float64{0.5, 0.4}})
+	if _, exists := pending["hue"]; exists {
+		t.Fatalf("Expected xy to replace hue, got %v", pending)
+	}
+	if _, exists := pending["sat"]; exists {
+		t.Fatalf("Expected xy to replace saturation, got %v", pending)
+	}
+}
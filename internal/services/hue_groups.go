@@ -0,0 +1,363 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+
+	"woodhome-webapp/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CreateGroup creates a room or zone on the bridge of its lights, or on the bridge given in the request
+func (h *HueService) CreateGroup(req *models.HueGroupRequest) (*models.HueGroup, error) {
+	if req.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if req.Type != models.HueGroupTypeRoom && req.Type != models.HueGroupTypeZone {
+		return nil, fmt.Errorf("type must be %s or %s", models.HueGroupTypeRoom, models.HueGroupTypeZone)
+	}
+
+	bridgeID := req.BridgeID
+	if bridgeID == "" && len(req.Lights) > 0 {
+		owner, _, err := h.resolve(req.Lights[0])
+		if err != nil {
+			return nil, err
+		}
+		bridgeID = owner.id
+	}
+	b, err := h.bridgeByID(bridgeID)
+	if err != nil {
+		return nil, err
+	}
+	lights, err := h.resolveAll(b, req.Lights)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := map[string]interface{}{
+		"name":   req.Name,
+		"type":   req.Type,
+		"lights": lights,
+	}
+	if req.Class != "" {
+		payload["class"] = req.Class
+	}
+
+	responses, err := b.bridgeRequest("POST", "/groups", payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", req.Type, err)
+	}
+	groupID := b.namespaced(createdID(responses))
+	if groupID == "" {
+		return nil, fmt.Errorf("bridge did not return a group ID")
+	}
+
+	logrus.Infof("Created Hue %s %s (%s)", req.Type, req.Name, groupID)
+	h.refreshGroupsAfterChange(b)
+
+	group, exists := h.GetGroup(groupID)
+	if !exists {
+		return &models.HueGroup{ID: groupID, BridgeID: b.id, Name: req.Name, Type: req.Type, Class: req.Class, Lights: req.Lights}, nil
+	}
+	return group, nil
+}
+
+// UpdateGroup renames a room or zone, changes its class or replaces its lights
+func (h *HueService) UpdateGroup(groupID string, req *models.HueGroupRequest) error {
+	b, localID, err := h.resolve(groupID)
+	if err != nil {
+		return err
+	}
+
+	payload := make(map[string]interface{})
+	if req.Name != "" {
+		payload["name"] = req.Name
+	}
+	if req.Class != "" {
+		payload["class"] = req.Class
+	}
+	if req.Lights != nil {
+		lights, err := h.resolveAll(b, req.Lights)
+		if err != nil {
+			return err
+		}
+		payload["lights"] = lights
+	}
+	if len(payload) == 0 {
+		return fmt.Errorf("nothing to update")
+	}
+
+	if _, err := b.bridgeRequest("PUT", fmt.Sprintf("/groups/%s", localID), payload); err != nil {
+		return fmt.Errorf("failed to update group: %w", err)
+	}
+
+	h.refreshGroupsAfterChange(b)
+	return nil
+}
+
+// DeleteGroup removes a room or zone from the bridge; its lights are kept
+func (h *HueService) DeleteGroup(groupID string) error {
+	b, localID, err := h.resolve(groupID)
+	if err != nil {
+		return err
+	}
+	if _, err := b.bridgeRequest("DELETE", fmt.Sprintf("/groups/%s", localID), nil); err != nil {
+		return fmt.Errorf("failed to delete group: %w", err)
+	}
+
+	h.mu.Lock()
+	delete(h.groups, groupID)
+	h.mu.Unlock()
+
+	logrus.Infof("Deleted Hue group %s", groupID)
+	return nil
+}
+
+// AddLightToGroup adds a light to a zone, or moves it into a room.
+// A light can only be in one room, so it is taken out of its current room first.
+// Groups cannot span bridges, so the light must be on the bridge of the group.
+func (h *HueService) AddLightToGroup(groupID, lightID string) error {
+	group, exists := h.GetGroup(groupID)
+	if !exists {
+		return fmt.Errorf("group %s not found", groupID)
+	}
+	if slices.Contains(group.Lights, lightID) {
+		return nil
+	}
+	b, _, err := h.resolve(groupID)
+	if err != nil {
+		return err
+	}
+	if _, err := h.resolveAll(b, []string{lightID}); err != nil {
+		return err
+	}
+
+	if group.Type == models.HueGroupTypeRoom {
+		for _, room := range h.GetRooms() {
+			if room.ID != groupID && room.BridgeID == group.BridgeID && slices.Contains(room.Lights, lightID) {
+				if err := h.setGroupLights(room.ID, removeString(room.Lights, lightID)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	lights := append(append([]string{}, group.Lights...), lightID)
+	if err := h.setGroupLights(groupID, lights); err != nil {
+		return err
+	}
+
+	h.refreshGroupsAfterChange(b)
+	return nil
+}
+
+// RemoveLightFromGroup takes a light out of a room or zone
+func (h *HueService) RemoveLightFromGroup(groupID, lightID string) error {
+	group, exists := h.GetGroup(groupID)
+	if !exists {
+		return fmt.Errorf("group %s not found", groupID)
+	}
+	if !slices.Contains(group.Lights, lightID) {
+		return nil
+	}
+
+	if err := h.setGroupLights(groupID, removeString(group.Lights, lightID)); err != nil {
+		return err
+	}
+
+	b, _, err := h.resolve(groupID)
+	if err != nil {
+		return err
+	}
+	h.refreshGroupsAfterChange(b)
+	return nil
+}
+
+// setGroupLights replaces the lights of a group
+func (h *HueService) setGroupLights(groupID string, lights []string) error {
+	b, localID, err := h.resolve(groupID)
+	if err != nil {
+		return err
+	}
+	localLights, err := h.resolveAll(b, lights)
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]interface{}{"lights": localLights}
+	if _, err := b.bridgeRequest("PUT", fmt.Sprintf("/groups/%s", localID), payload); err != nil {
+		return fmt.Errorf("failed to update lights of group %s: %w", groupID, err)
+	}
+	return nil
+}
+
+// RenameLight changes the name of a light
+func (h *HueService) RenameLight(lightID, name string) error {
+	if name == "" {
+		return fmt.Errorf("name is required")
+	}
+	b, localID, err := h.resolve(lightID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := b.bridgeRequest("PUT", fmt.Sprintf("/lights/%s", localID), map[string]string{"name": name}); err != nil {
+		return fmt.Errorf("failed to rename light: %w", err)
+	}
+
+	h.mu.Lock()
+	if existing, exists := h.lights[lightID]; exists {
+		light := *existing
+		light.Name = name
+		h.lights[lightID] = &light
+	}
+	h.mu.Unlock()
+
+	return nil
+}
+
+// DeleteLight removes a light from the bridge
+func (h *HueService) DeleteLight(lightID string) error {
+	b, localID, err := h.resolve(lightID)
+	if err != nil {
+		return err
+	}
+	if _, err := b.bridgeRequest("DELETE", fmt.Sprintf("/lights/%s", localID), nil); err != nil {
+		return fmt.Errorf("failed to delete light: %w", err)
+	}
+
+	h.mu.Lock()
+	delete(h.lights, lightID)
+	h.mu.Unlock()
+
+	logrus.Infof("Deleted Hue light %s", lightID)
+	h.refreshGroupsAfterChange(b)
+	return nil
+}
+
+// SearchLights starts a search for new lights on every bridge. The bridges search for about 40 seconds.
+func (h *HueService) SearchLights() error {
+	bridges := h.connectedBridges()
+	if len(bridges) == 0 {
+		return fmt.Errorf("bridge not configured")
+	}
+
+	for _, b := range bridges {
+		if _, err := b.bridgeRequest("POST", "/lights", map[string]interface{}{}); err != nil {
+			return fmt.Errorf("failed to start light search on bridge %s: %w", b.id, err)
+		}
+	}
+
+	logrus.Info("Started search for new Hue lights")
+	return nil
+}
+
+// GetLightSearch reports the progress of the last light search and the lights it found on every bridge.
+// The search is active while any bridge is still searching.
+func (h *HueService) GetLightSearch() (*models.HueLightSearch, error) {
+	bridges := h.connectedBridges()
+	if len(bridges) == 0 {
+		return nil, fmt.Errorf("bridge not configured")
+	}
+
+	search := &models.HueLightSearch{Status: "none", Lights: []*models.HueLight{}}
+	for _, b := range bridges {
+		found, err := b.lightSearch()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get light search of bridge %s: %w", b.id, err)
+		}
+
+		switch {
+		case found.Status == "active":
+			search.Status = "active"
+		case found.Status == "done" && search.Status != "active":
+			search.Status = "done"
+		}
+		if found.LastScan > search.LastScan {
+			search.LastScan = found.LastScan
+		}
+		search.Lights = append(search.Lights, found.Lights...)
+
+		// Pick up newly found lights right away
+		if len(found.Lights) > 0 {
+			if err := h.refreshBridge(b, false, hueResourceLights); err != nil {
+				logrus.Warnf("Failed to refresh lights of bridge %s after search: %v", b.id, err)
+			}
+		}
+	}
+
+	sort.Slice(search.Lights, func(i, j int) bool {
+		return search.Lights[i].ID < search.Lights[j].ID
+	})
+	return search, nil
+}
+
+// lightSearch reads the light search of one bridge with namespaced light IDs
+func (b *hueBridge) lightSearch() (*models.HueLightSearch, error) {
+	body, err := b.fetchResource("/lights/new")
+	if err != nil {
+		return nil, err
+	}
+
+	var searchData map[string]json.RawMessage
+	if err := json.Unmarshal(body, &searchData); err != nil {
+		return nil, fmt.Errorf("failed to decode light search: %w", err)
+	}
+
+	search := &models.HueLightSearch{Lights: []*models.HueLight{}}
+	for key, value := range searchData {
+		if key == "lastscan" {
+			var lastScan string
+			json.Unmarshal(value, &lastScan)
+			switch lastScan {
+			case "active", "none":
+				search.Status = lastScan
+			default:
+				search.Status = "done"
+				search.LastScan = lastScan
+			}
+			continue
+		}
+
+		var found struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(value, &found); err != nil {
+			continue
+		}
+		search.Lights = append(search.Lights, &models.HueLight{ID: b.namespaced(key), BridgeID: b.id, Name: found.Name})
+	}
+	return search, nil
+}
+
+// refreshGroupsAfterChange reloads the groups of a bridge after a change made through it
+func (h *HueService) refreshGroupsAfterChange(b *hueBridge) {
+	if err := h.refreshBridge(b, true, hueResourceGroups); err != nil {
+		logrus.Warnf("Failed to refresh groups after change: %v", err)
+	}
+}
+
+// createdID returns the ID the bridge assigned to a created resource
+func createdID(responses []models.HueAPIResponse) string {
+	for _, response := range responses {
+		success, _ := response.Success.(map[string]interface{})
+		if id, ok := success["id"].(string); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+// removeString returns a copy of a string slice without a value
+func removeString(values []string, value string) []string {
+	result := make([]string, 0, len(values))
+	for _, candidate := range values {
+		if candidate != value {
+			result = append(result, candidate)
+		}
+	}
+	return result
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestAddLightToGroupMovesBetweenRooms(t *testing.T) {
+	var mu sync.Mutex
+	groups := map[string]map[string]interface{}{
+		"1": {"name": "Kitchen", "type": "Room", "lights": []string{"3", "4"}},
+		"2": {"name": "Dining", "type": "Room", "lights": []string{"5"}},
+		"3": {"name": "Downstairs", "type": "Zone", "lights": []string{"3", "5"}},
+	}
+
+	bridge := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/groups":
+			json.NewEncoder(w).Encode(groups)
+		case r.Method == "PUT":
+			groupID := r.URL.Path[len("/groups/"):]
+			body, _ := io.ReadAll(r.Body)
+			var update struct {
+				Lights []string `json:"lights"`
+			}
+			json.Unmarshal(body, &update)
+			groups[groupID]["lights"] = update.Lights
+			w.Write([]byte(`[{"success":{"/groups/` + groupID + `/lights":[]}}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer bridge.Close()
+
+	service := NewHueService(nil)
+	service.primary().baseURL = bridge.URL
+	if err := service.refreshResources(true, hueResourceGroups); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if rooms, zones := service.GetRooms(), service.GetZones(); len(rooms) != 2 || len(zones) != 1 {
+		t.Fatalf("Expected 2 rooms and 1 zone, got %d and %d", len(rooms), len(zones))
+	}
+
+	if err := service.AddLightToGroup("2", "3"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	kitchen, _ := service.GetGroup("1")
+	dining, _ := service.GetGroup("2")
+	zone, _ := service.GetGroup("3")
+	if len(kitchen.Lights) != 1 || kitchen.Lights[0] != "4" {
+		t.Fatalf("Expected light 3 to leave the kitchen, got %v", kitchen.Lights)
+	}
+	if len(dining.Lights) != 2 {
+		t.Fatalf("Expected light 3 to join the dining room, got %v", dining.Lights)
+	}
+	if len(zone.Lights) != 2 {
+		t.Fatalf("Expected zones to be left alone, got %v", zone.Lights)
+	}
+}
+
+func TestDeleteGroupAndLight(t *testing.T) {
+	var mu sync.Mutex
+	lights := map[string]map[string]interface{}{
+		"3": {"name": "Pendant", "type": "Dimmable light", "state": map[string]interface{}{"on": false, "reachable": true}},
+		"4": {"name": "Counter", "type": "Dimmable light", "state": map[string]interface{}{"on": false, "reachable": true}},
+	}
+	groups := map[string]map[string]interface{}{
+		"1": {"name": "Kitchen", "type": "Room", "lights": []string{"3", "4"}},
+		"2": {"name": "Downstairs", "type": "Zone", "lights": []string{"3"}},
+	}
+
+	bridge := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/lights":
+			json.NewEncoder(w).Encode(lights)
+		case r.Method == "GET" && r.URL.Path == "/groups":
+			json.NewEncoder(w).Encode(groups)
+		case r.Method == "DELETE" && r.URL.Path == "/groups/2":
+			delete(groups, "2")
+			w.Write([]byte(`[{"success":"/groups/2 deleted"}]`))
+		case r.Method == "DELETE" && r.URL.Path == "/lights/3":
+			delete(lights, "3")
+			groups["1"]["lights"] = []string{"4"}
+			w.Write([]byte(`[{"success":"/lights/3 deleted"}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer bridge.Close()
+
+	service := NewHueService(nil)
+	service.primary().baseURL = bridge.URL
+	if err := service.refreshResources(true, hueResourceLights, hueResourceGroups); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := service.DeleteGroup("2"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, exists := service.GetGroup("2"); exists {
+		t.Fatal("Expected the zone to be removed")
+	}
+
+	if err := service.DeleteLight("3"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if lights := service.GetLights(); len(lights) != 1 || lights[0].ID != "4" {
+		t.Fatalf("Expected only the counter light to be left, got %+v", lights)
+	}
+	if kitchen, _ := service.GetGroup("1"); len(kitchen.Lights) != 1 || kitchen.Lights[0] != "4" {
+		t.Fatalf("Expected the deleted light to leave the kitchen, got %v", kitchen.Lights)
+	}
+}
@@ -0,0 +1,753 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"woodhome-webapp/internal/color"
+	"woodhome-webapp/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Light routine defaults
+const (
+	hueRoutineWakeKelvin  = 2700
+	hueRoutineSleepKelvin = 2000
+	hueRoutineMaxDuration = 180 // Minutes
+)
+
+// A wake-up starts at a dim red, warms up to sunriseMidKelvin half way
+// and then moves through the whites to the routine's final color temperature
+var sunriseStartXY = color.XY{X: 0.675, Y: 0.322}
+
+const sunriseMidKelvin = 2000
+
+// routineDays maps the accepted day names to weekdays
+var routineDays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// HueDayChecker finds calendar events that cancel a routine for the day
+type HueDayChecker interface {
+	FindEvent(ctx context.Context, day time.Time, calendarIDs, keywords []string) (*CalendarEvent, error)
+}
+
+// routineRun tracks a fade in progress
+type routineRun struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	start    time.Time
+	end      time.Time
+	progress float64
+}
+
+// routineState tracks a routine between scheduler ticks
+type routineState struct {
+	routine    models.HueLightRoutine
+	run        *routineRun
+	lastDate   string // Day of the last scheduled run, so a window starts once
+	skipNext   string // Day of an upcoming run cancelled from the dashboard
+	lastRunAt  time.Time
+	lastResult string
+	lastReason string
+}
+
+// HueLightRoutineService runs wake-up and sleep fades for Hue rooms.
+// The bridge caps transition times, so long fades are sent as a series of shorter segments.
+type HueLightRoutineService struct {
+	config     *models.HueLightRoutineConfig
+	hueService *HueService
+	db         *sql.DB
+	dayChecker HueDayChecker
+	eventBus   *EventBus
+	routines   map[string]*routineState
+	ctx        context.Context // Lifetime of the service, fades stop with it
+	now        func() time.Time
+	mu         sync.Mutex
+}
+
+// NewHueLightRoutineService creates a new HueLightRoutineService instance.
+// Routines are kept in memory only when db is nil.
+func NewHueLightRoutineService(hueService *HueService, db *sql.DB, config *models.HueLightRoutineConfig) *HueLightRoutineService {
+	if config == nil {
+		config = &models.HueLightRoutineConfig{}
+	}
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = 15 * time.Second
+	}
+	if config.StepInterval <= 0 {
+		config.StepInterval = time.Minute
+	}
+	if config.DefaultDuration <= 0 {
+		config.DefaultDuration = 30
+	}
+	if config.CalendarTimeout <= 0 {
+		config.CalendarTimeout = 20 * time.Second
+	}
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+
+	return &HueLightRoutineService{
+		config:     config,
+		hueService: hueService,
+		db:         db,
+		routines:   make(map[string]*routineState),
+		ctx:        context.Background(),
+		now:        time.Now,
+	}
+}
+
+// SetDayChecker sets the calendar used to skip routines on days like "no school"
+func (s *HueLightRoutineService) SetDayChecker(checker HueDayChecker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dayChecker = checker
+}
+
+// SetEventBus sets the bus that routine progress is published on
+func (s *HueLightRoutineService) SetEventBus(eventBus *EventBus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.eventBus = eventBus
+}
+
+// Start loads the saved routines and begins checking their schedules
+func (s *HueLightRoutineService) Start(ctx context.Context) error {
+	logrus.Info("Starting Hue light routine service...")
+
+	if err := s.load(); err != nil {
+		return fmt.Errorf("failed to load light routines: %w", err)
+	}
+
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+
+	go s.startLoop(ctx)
+	return nil
+}
+
+// startLoop checks the schedules until the context is cancelled
+func (s *HueLightRoutineService) startLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.cancelAll()
+			return
+		case <-ticker.C:
+			s.tick(ctx)
+		}
+	}
+}
+
+// GetRoutines returns every routine with its schedule, ordered by time of day
+func (s *HueLightRoutineService) GetRoutines() []*models.HueLightRoutineStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]*models.HueLightRoutineStatus, 0, len(s.routines))
+	for _, state := range s.routines {
+		statuses = append(statuses, s.status(state))
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Routine.Time != statuses[j].Routine.Time {
+			return statuses[i].Routine.Time < statuses[j].Routine.Time
+		}
+		return statuses[i].Routine.Name < statuses[j].Routine.Name
+	})
+	return statuses
+}
+
+// GetRoutine returns a routine with its schedule
+func (s *HueLightRoutineService) GetRoutine(id string) (*models.HueLightRoutineStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, exists := s.routines[id]
+	if !exists {
+		return nil, false
+	}
+	return s.status(state), true
+}
+
+// CreateRoutine validates and saves a new routine
+func (s *HueLightRoutineService) CreateRoutine(routine models.HueLightRoutine) (*models.HueLightRoutineStatus, error) {
+	if err := s.validate(&routine); err != nil {
+		return nil, err
+	}
+	routine.ID = uuid.NewString()
+
+	if err := s.save(routine); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := &routineState{routine: routine}
+	s.routines[routine.ID] = state
+	logrus.Infof("Light routines: created %s routine %q for room %s at %s", routine.Type, routine.Name, routine.RoomID, routine.Time)
+	return s.status(state), nil
+}
+
+// UpdateRoutine replaces a routine. A fade in progress keeps running with the old settings.
+func (s *HueLightRoutineService) UpdateRoutine(id string, routine models.HueLightRoutine) (*models.HueLightRoutineStatus, error) {
+	s.mu.Lock()
+	_, exists := s.routines[id]
+	s.mu.Unlock()
+	if !exists {
+		return nil, fmt.Errorf("routine %s not found", id)
+	}
+
+	if err := s.validate(&routine); err != nil {
+		return nil, err
+	}
+	routine.ID = id
+
+	if err := s.save(routine); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, exists := s.routines[id]
+	if !exists {
+		return nil, fmt.Errorf("routine %s not found", id)
+	}
+	state.routine = routine
+	return s.status(state), nil
+}
+
+// DeleteRoutine stops and removes a routine
+func (s *HueLightRoutineService) DeleteRoutine(id string) error {
+	s.mu.Lock()
+	state, exists := s.routines[id]
+	if !exists {
+		s.mu.Unlock()
+		return fmt.Errorf("routine %s not found", id)
+	}
+	if state.run != nil {
+		state.run.cancel()
+	}
+	delete(s.routines, id)
+	s.mu.Unlock()
+
+	if s.db != nil {
+		if _, err := s.db.Exec(`DELETE FROM hue_light_routines WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete routine: %w", err)
+		}
+	}
+	return nil
+}
+
+// RunNow starts a routine immediately, ending one duration from now
+func (s *HueLightRoutineService) RunNow(id string) (*models.HueLightRoutineStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, exists := s.routines[id]
+	if !exists {
+		return nil, fmt.Errorf("routine %s not found", id)
+	}
+	if state.run != nil {
+		return nil, fmt.Errorf("routine %s is already running", state.routine.Name)
+	}
+
+	start := s.now()
+	run := s.startRun(s.ctx, state, start, start.Add(time.Duration(state.routine.Duration)*time.Minute))
+	go s.execute(state, run)
+	return s.status(state), nil
+}
+
+// Cancel stops a running fade and leaves the lights where they are.
+// If the routine isn't running, its next scheduled run is skipped instead.
+func (s *HueLightRoutineService) Cancel(id string) (*models.HueLightRoutineStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, exists := s.routines[id]
+	if !exists {
+		return nil, fmt.Errorf("routine %s not found", id)
+	}
+
+	if state.run != nil {
+		state.run.cancel()
+		return s.status(state), nil
+	}
+
+	_, end, ok := nextWindow(state.routine, s.homeNow())
+	if !ok {
+		return nil, fmt.Errorf("routine %s has no upcoming run", state.routine.Name)
+	}
+	state.skipNext = dateKey(end)
+	logrus.Infof("Light routines: skipping %q on %s", state.routine.Name, state.skipNext)
+	return s.status(state), nil
+}
+
+// tick starts every routine whose fade window has begun
+func (s *HueLightRoutineService) tick(ctx context.Context) {
+	now := s.homeNow()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, state := range s.routines {
+		if !state.routine.Enabled || state.run != nil {
+			continue
+		}
+		start, end, ok := currentWindow(state.routine, now)
+		if !ok || state.lastDate == dateKey(end) {
+			continue
+		}
+		state.lastDate = dateKey(end)
+		go s.begin(ctx, state, start, end)
+	}
+}
+
+// begin checks whether today's run should be skipped and otherwise runs the fade
+func (s *HueLightRoutineService) begin(ctx context.Context, state *routineState, start, end time.Time) {
+	s.mu.Lock()
+	routine := state.routine
+	skipped := state.skipNext == dateKey(end)
+	if skipped {
+		state.skipNext = ""
+	}
+	checker := s.dayChecker
+	s.mu.Unlock()
+
+	if skipped {
+		s.finish(state, "skipped", "cancelled from the dashboard")
+		return
+	}
+
+	if checker != nil && len(routine.SkipKeywords) > 0 {
+		checkCtx, cancel := context.WithTimeout(ctx, s.config.CalendarTimeout)
+		event, err := checker.FindEvent(checkCtx, end, routine.SkipCalendarIDs, routine.SkipKeywords)
+		cancel()
+		if err != nil {
+			// Better to run on a day off than to miss a school day
+			logrus.Warnf("Light routines: failed to check the calendar for %q, running anyway: %v", routine.Name, err)
+		} else if event != nil {
+			s.finish(state, "skipped", event.Title)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	if _, exists := s.routines[routine.ID]; !exists || state.run != nil {
+		s.mu.Unlock()
+		return
+	}
+	run := s.startRun(ctx, state, start, end)
+	s.mu.Unlock()
+
+	s.execute(state, run)
+}
+
+// startRun marks a routine as running. The caller must hold s.mu.
+func (s *HueLightRoutineService) startRun(ctx context.Context, state *routineState, start, end time.Time) *routineRun {
+	runCtx, cancel := context.WithCancel(ctx)
+	run := &routineRun{ctx: runCtx, cancel: cancel, start: start, end: end}
+	state.run = run
+
+	logrus.Infof("Light routines: starting %q in room %s, ending at %s", state.routine.Name, state.routine.RoomID, end.Format("15:04"))
+	s.publish(state, "started", "")
+	return run
+}
+
+// execute runs the fade of a started routine and records how it ended
+func (s *HueLightRoutineService) execute(state *routineState, run *routineRun) {
+	s.mu.Lock()
+	routine := state.routine
+	s.mu.Unlock()
+
+	result, reason := s.fade(routine, run)
+	run.cancel()
+
+	s.mu.Lock()
+	if state.run == run {
+		state.run = nil
+	}
+	s.mu.Unlock()
+
+	s.finish(state, result, reason)
+}
+
+// fade sends the routine to the room one segment at a time until it ends or is cancelled
+func (s *HueLightRoutineService) fade(routine models.HueLightRoutine, run *routineRun) (string, string) {
+	group, exists := s.hueService.GetGroup(routine.RoomID)
+	if !exists {
+		return "failed", fmt.Sprintf("room %s not found", routine.RoomID)
+	}
+
+	// A bedtime fade dims from wherever the room is now
+	fromBrightness := group.Brightness
+	if routine.Type == models.HueRoutineSleep && !group.IsOn {
+		return "skipped", "the lights are already off"
+	}
+
+	total := run.end.Sub(run.start)
+	progressAt := func(at time.Time) float64 {
+		if total <= 0 {
+			return 1
+		}
+		return math.Max(0, math.Min(1, at.Sub(run.start).Seconds()/total.Seconds()))
+	}
+
+	first := true
+	for {
+		now := s.now()
+		s.mu.Lock()
+		run.progress = progressAt(now)
+		s.mu.Unlock()
+
+		if !first {
+			// Someone switching the room off ends the routine
+			if group, exists := s.hueService.GetGroup(routine.RoomID); exists && !group.IsOn {
+				return "cancelled", "the lights were switched off"
+			}
+		}
+
+		if first && routine.Type == models.HueRoutineWake {
+			// Switch on at the current point of the sunrise before fading onwards
+			if err := s.hueService.SetGroupState(routine.RoomID, wakeState(routine, run.progress, 0)); err != nil {
+				return "failed", err.Error()
+			}
+		}
+		first = false
+
+		next := now.Add(s.config.StepInterval)
+		if next.After(run.end) {
+			next = run.end
+		}
+		transition := int(next.Sub(now) / (100 * time.Millisecond))
+
+		var state *models.HueGroupState
+		if routine.Type == models.HueRoutineWake {
+			state = wakeState(routine, progressAt(next), transition)
+		} else {
+			state = sleepState(routine, fromBrightness, progressAt(next), transition)
+		}
+		if err := s.hueService.SetGroupState(routine.RoomID, state); err != nil {
+			return "failed", err.Error()
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-run.ctx.Done():
+			timer.Stop()
+			return "cancelled", "cancelled from the dashboard"
+		case <-timer.C:
+		}
+
+		if !next.Before(run.end) {
+			break
+		}
+	}
+
+	if routine.Type == models.HueRoutineSleep {
+		off := false
+		if err := s.hueService.SetGroupState(routine.RoomID, &models.HueGroupState{On: &off}); err != nil {
+			return "failed", err.Error()
+		}
+	}
+	return "completed", ""
+}
+
+// wakeState returns the room state at a point of the sunrise: dim red warming to orange
+// during the first half, then brightening through the whites to the final color temperature
+func wakeState(routine models.HueLightRoutine, progress float64, transition int) *models.HueGroupState {
+	on := true
+	// Brightness is perceived logarithmically, so ease in
+	brightness := int(math.Round(lerp(1, float64(routine.Brightness), progress*progress)))
+	state := &models.HueGroupState{
+		On:             &on,
+		Brightness:     &brightness,
+		TransitionTime: &transition,
+	}
+
+	if progress < 0.5 {
+		warm := color.KelvinToXY(sunriseMidKelvin)
+		t := progress / 0.5
+		state.XY = []float64{lerp(sunriseStartXY.X, warm.X, t), lerp(sunriseStartXY.Y, warm.Y, t)}
+	} else {
+		from := float64(color.KelvinToMired(sunriseMidKelvin))
+		to := float64(color.KelvinToMired(routine.Kelvin))
+		mired := color.ClampMired(int(math.Round(lerp(from, to, (progress-0.5)/0.5))))
+		state.ColorTemp = &mired
+	}
+	return state
+}
+
+// sleepState returns the room state at a point of the bedtime fade
+func sleepState(routine models.HueLightRoutine, fromBrightness int, progress float64, transition int) *models.HueGroupState {
+	brightness := int(math.Round(lerp(float64(fromBrightness), 1, progress)))
+	mired := color.ClampMired(color.KelvinToMired(routine.Kelvin))
+	return &models.HueGroupState{
+		Brightness:     &brightness,
+		ColorTemp:      &mired,
+		TransitionTime: &transition,
+	}
+}
+
+// finish records the outcome of a run
+func (s *HueLightRoutineService) finish(state *routineState, result, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state.lastRunAt = s.now()
+	state.lastResult = result
+	state.lastReason = reason
+
+	if reason != "" {
+		logrus.Infof("Light routines: %q %s: %s", state.routine.Name, result, reason)
+	} else {
+		logrus.Infof("Light routines: %q %s", state.routine.Name, result)
+	}
+	s.publish(state, result, reason)
+}
+
+// publish sends a routine event to the dashboard. The caller must hold s.mu.
+func (s *HueLightRoutineService) publish(state *routineState, result, reason string) {
+	if s.eventBus == nil {
+		return
+	}
+	s.eventBus.Publish(models.EventHueRoutine, state.routine.ID, map[string]interface{}{
+		"routine": state.routine.Name,
+		"type":    state.routine.Type,
+		"room_id": state.routine.RoomID,
+		"result":  result,
+		"reason":  reason,
+	})
+}
+
+// status reports a routine with its schedule. The caller must hold s.mu.
+func (s *HueLightRoutineService) status(state *routineState) *models.HueLightRoutineStatus {
+	status := &models.HueLightRoutineStatus{
+		Routine:    state.routine,
+		SkipNext:   state.skipNext,
+		LastRunAt:  state.lastRunAt,
+		LastResult: state.lastResult,
+		LastReason: state.lastReason,
+	}
+	if state.run != nil {
+		status.Running = true
+		status.Progress = state.run.progress
+		status.StartedAt = state.run.start
+		status.EndsAt = state.run.end
+	}
+	if state.routine.Enabled {
+		now := s.homeNow()
+		// Look past a skipped run to the one after it
+		for attempt := 0; attempt < 2; attempt++ {
+			start, end, ok := nextWindow(state.routine, now)
+			if !ok {
+				break
+			}
+			if dateKey(end) != state.skipNext {
+				status.NextRun = &start
+				break
+			}
+			now = start.Add(time.Second)
+		}
+	}
+	return status
+}
+
+// cancelAll stops every running fade
+func (s *HueLightRoutineService) cancelAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, state := range s.routines {
+		if state.run != nil {
+			state.run.cancel()
+		}
+	}
+}
+
+// validate fills in defaults and checks a routine
+func (s *HueLightRoutineService) validate(routine *models.HueLightRoutine) error {
+	switch routine.Type {
+	case models.HueRoutineWake, models.HueRoutineSleep:
+	default:
+		return fmt.Errorf("routine type must be %q or %q", models.HueRoutineWake, models.HueRoutineSleep)
+	}
+
+	if _, exists := s.hueService.GetGroup(routine.RoomID); !exists {
+		return fmt.Errorf("room %s not found", routine.RoomID)
+	}
+	if _, err := time.Parse("15:04", routine.Time); err != nil {
+		return fmt.Errorf("time must be HH:MM: %w", err)
+	}
+
+	days := make([]string, 0, len(routine.Days))
+	for _, day := range routine.Days {
+		key := strings.ToLower(strings.TrimSpace(day))
+		if len(key) > 3 {
+			key = key[:3]
+		}
+		if _, ok := routineDays[key]; !ok {
+			return fmt.Errorf("unknown day %q", day)
+		}
+		days = append(days, key)
+	}
+	routine.Days = days
+
+	if routine.Duration <= 0 {
+		routine.Duration = s.config.DefaultDuration
+	}
+	if routine.Duration > hueRoutineMaxDuration {
+		return fmt.Errorf("duration must be at most %d minutes", hueRoutineMaxDuration)
+	}
+
+	if routine.Kelvin <= 0 {
+		routine.Kelvin = hueRoutineWakeKelvin
+		if routine.Type == models.HueRoutineSleep {
+			routine.Kelvin = hueRoutineSleepKelvin
+		}
+	}
+	if routine.Brightness <= 0 || routine.Brightness > 254 {
+		routine.Brightness = 254
+	}
+	if routine.Name == "" {
+		routine.Name = "Wake-up " + routine.Time
+		if routine.Type == models.HueRoutineSleep {
+			routine.Name = "Bedtime " + routine.Time
+		}
+	}
+	return nil
+}
+
+// load reads the saved routines
+func (s *HueLightRoutineService) load() error {
+	if s.db == nil {
+		return nil
+	}
+
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS hue_light_routines (
+			id TEXT PRIMARY KEY,
+			data TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.db.Query(`SELECT id, data FROM hue_light_routines`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for rows.Next() {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			return err
+		}
+		var routine models.HueLightRoutine
+		if err := json.Unmarshal([]byte(data), &routine); err != nil {
+			logrus.Warnf("Light routines: ignoring unreadable routine %s: %v", id, err)
+			continue
+		}
+		routine.ID = id
+		s.routines[id] = &routineState{routine: routine}
+	}
+	logrus.Infof("Light routines: loaded %d routines", len(s.routines))
+	return rows.Err()
+}
+
+// save stores a routine
+func (s *HueLightRoutineService) save(routine models.HueLightRoutine) error {
+	if s.db == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(routine)
+	if err != nil {
+		return fmt.Errorf("failed to marshal routine: %w", err)
+	}
+	_, err = s.db.Exec(`
+		INSERT OR REPLACE INTO hue_light_routines (id, data, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+	`, routine.ID, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save routine: %w", err)
+	}
+	return nil
+}
+
+// homeNow returns the current time in the home time zone, which routine times and days are in
+func (s *HueLightRoutineService) homeNow() time.Time {
+	return s.now().In(s.config.Location)
+}
+
+// currentWindow returns the fade window that contains now, if any.
+// Windows are keyed by the day they end on, so a fade may start before midnight.
+func currentWindow(routine models.HueLightRoutine, now time.Time) (time.Time, time.Time, bool) {
+	duration := time.Duration(routine.Duration) * time.Minute
+	for offset := 0; offset <= 1; offset++ {
+		end := clockTime(now.AddDate(0, 0, offset), routine.Time, time.Time{})
+		start := end.Add(-duration)
+		if !end.IsZero() && runsOn(routine, end) && !now.Before(start) && now.Before(end) {
+			return start, end, true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// nextWindow returns the first fade window that starts after now
+func nextWindow(routine models.HueLightRoutine, now time.Time) (time.Time, time.Time, bool) {
+	duration := time.Duration(routine.Duration) * time.Minute
+	for offset := 0; offset <= 8; offset++ {
+		end := clockTime(now.AddDate(0, 0, offset), routine.Time, time.Time{})
+		start := end.Add(-duration)
+		if !end.IsZero() && runsOn(routine, end) && start.After(now) {
+			return start, end, true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// runsOn reports whether the routine is scheduled for the day of t
+func runsOn(routine models.HueLightRoutine, t time.Time) bool {
+	if len(routine.Days) == 0 {
+		return true
+	}
+	for _, day := range routine.Days {
+		if routineDays[day] == t.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// dateKey identifies the day of t
+func dateKey(t time.Time) string {
+	return t.Format("2006-01-02")
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"woodhome-webapp/internal/color"
+	"woodhome-webapp/internal/models"
+)
+
+func TestRoutineWindows(t *testing.T) {
+	// Wednesday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 5, day, hour, minute, 0, 0, time.UTC)
+	}
+	wake := models.HueLightRoutine{Type: models.HueRoutineWake, Time: "07:00", Duration: 30, Days: []string{"mon", "wed"}}
+
+	start, end, ok := currentWindow(wake, at(15, 6, 45))
+	if !ok || !start.Equal(at(15, 6, 30)) || !end.Equal(at(15, 7, 0)) {
+		t.Fatalf("Expected the 06:30-07:00 window, got %v %v %v", start, end, ok)
+	}
+	if _, _, ok := currentWindow(wake, at(15, 7, 0)); ok {
+		t.Fatal("Expected the window to be over at the wake time")
+	}
+	if _, _, ok := currentWindow(wake, at(16, 6, 45)); ok {
+		t.Fatal("Expected no window on a Thursday")
+	}
+
+	// The next run after Wednesday morning is on Monday
+	start, _, ok = nextWindow(wake, at(15, 8, 0))
+	if !ok || !start.Equal(at(20, 6, 30)) {
+		t.Fatalf("Expected the next run on Monday 06:30, got %v %v", start, ok)
+	}
+
+	// A fade ending after midnight starts the evening before
+	sleep := models.HueLightRoutine{Type: models.HueRoutineSleep, Time: "00:10", Duration: 20}
+	start, end, ok = currentWindow(sleep, at(15, 23, 55))
+	if !ok || !start.Equal(at(15, 23, 50)) || !end.Equal(at(16, 0, 10)) {
+		t.Fatalf("Expected the 23:50-00:10 window, got %v %v %v", start, end, ok)
+	}
+}
+
+func TestWakeState(t *testing.T) {
+	routine := models.HueLightRoutine{Type: models.HueRoutineWake, Kelvin: 2700, Brightness: 254}
+
+	first := wakeState(routine, 0, 0)
+	if *first.Brightness != 1 || len(first.XY) != 2 || first.XY[0] != sunriseStartXY.X {
+		t.Fatalf("Expected the sunrise to start at a dim red, got bri %d xy %v", *first.Brightness, first.XY)
+	}
+
+	last := wakeState(routine, 1, 600)
+	if *last.Brightness != 254 || last.ColorTemp == nil || *last.ColorTemp != color.KelvinToMired(2700) {
+		t.Fatalf("Expected the sunrise to end at bright 2700K, got %+v", last)
+	}
+	if *last.TransitionTime != 600 {
+		t.Fatalf("Expected a 600 decisecond transition, got %d", *last.TransitionTime)
+	}
+
+	if mid := wakeState(routine, 0.5, 0); *mid.Brightness >= 254/2 {
+		t.Fatalf("Expected brightness to ease in, got %d half way", *mid.Brightness)
+	}
+}
+
+func TestMatchesKeyword(t *testing.T) {
+	keywords := []string{"No School", " teacher workshop "}
+	if !matchesKeyword("NO SCHOOL - Winter Break", keywords) {
+		t.Fatal("Expected a case-insensitive match")
+	}
+	if !matchesKeyword("Teacher Workshop day", keywords) {
+		t.Fatal("Expected trimmed keywords to match")
+	}
+	if matchesKeyword("School concert", keywords) {
+		t.Fatal("Expected no match")
+	}
+}
+
+func TestRoutinesUseHomeTimeZone(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Skip("No time zone data")
+	}
+	service := NewHueLightRoutineService(nil, nil, &models.HueLightRoutineConfig{Location: chicago})
+	service.routines["wake"] = &routineState{routine: models.HueLightRoutine{
+		ID: "wake", Type: models.HueRoutineWake, Enabled: true, Time: "06:30", Duration: 30, Days: []string{"fri"},
+	}}
+
+	// 10 AM UTC on a Friday is 5 AM in Chicago, so this morning's wake-up is still ahead
+	service.now = func() time.Time { return time.Date(2024, 10, 25, 10, 0, 0, 0, time.UTC) }
+	status, _ := service.GetRoutine("wake")
+	if status.NextRun == nil || !status.NextRun.Equal(time.Date(2024, 10, 25, 6, 0, 0, 0, chicago)) {
+		t.Fatalf("Expected the fade to start at 6:00 AM at home, got %v", status.NextRun)
+	}
+}
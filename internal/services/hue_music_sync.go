@@ -0,0 +1,308 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"woodhome-webapp/internal/color"
+	"woodhome-webapp/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxAlbumArtBytes caps how much of an album art response is decoded
+const maxAlbumArtBytes = 10 * 1024 * 1024
+
+// HueMusicSyncService makes Hue rooms follow the album art of Sonos rooms
+type HueMusicSyncService struct {
+	config       *models.HueMusicSyncConfig
+	hueService   *HueService
+	sonosService *SonosService
+	httpClient   *http.Client
+	mappings     map[string]*models.HueMusicSyncMapping
+	status       map[string]*models.HueMusicSyncStatus
+	lastTrack    map[string]string
+	mu           sync.RWMutex
+}
+
+// NewHueMusicSyncService creates a new HueMusicSyncService instance
+func NewHueMusicSyncService(hueService *HueService, sonosService *SonosService, config *models.HueMusicSyncConfig) *HueMusicSyncService {
+	if config == nil {
+		config = &models.HueMusicSyncConfig{
+			PollInterval:          5 * time.Second,
+			DefaultTransitionTime: 20, // 2 seconds
+			DefaultPaletteSize:    5,
+			ArtFetchTimeout:       10 * time.Second,
+		}
+	}
+
+	return &HueMusicSyncService{
+		config:       config,
+		hueService:   hueService,
+		sonosService: sonosService,
+		httpClient: &http.Client{
+			Timeout: config.ArtFetchTimeout,
+		},
+		mappings:  make(map[string]*models.HueMusicSyncMapping),
+		status:    make(map[string]*models.HueMusicSyncStatus),
+		lastTrack: make(map[string]string),
+	}
+}
+
+// Start begins watching the mapped Sonos rooms for track changes
+func (m *HueMusicSyncService) Start(ctx context.Context) error {
+	logrus.Info("Starting Hue music sync service...")
+	go m.startPolling(ctx)
+	return nil
+}
+
+// startPolling checks the mapped Sonos rooms on every poll interval
+func (m *HueMusicSyncService) startPolling(ctx context.Context) {
+	ticker := time.NewTicker(m.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.syncAll(ctx)
+		}
+	}
+}
+
+// SetMapping creates or replaces the mapping for a Sonos room
+func (m *HueMusicSyncService) SetMapping(mapping models.HueMusicSyncMapping) (*models.HueMusicSyncMapping, error) {
+	if mapping.SonosRoom == "" {
+		return nil, fmt.Errorf("sonos room is required")
+	}
+	if mapping.HueRoomID == "" {
+		return nil, fmt.Errorf("hue room ID is required")
+	}
+	if mapping.TransitionTime <= 0 {
+		mapping.TransitionTime = m.config.DefaultTransitionTime
+	}
+	if mapping.PaletteSize <= 0 {
+		mapping.PaletteSize = m.config.DefaultPaletteSize
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.mappings[mapping.SonosRoom] = &mapping
+	m.status[mapping.SonosRoom] = &models.HueMusicSyncStatus{Mapping: mapping}
+	// Forget the last track so the new mapping is applied on the next poll
+	delete(m.lastTrack, mapping.SonosRoom)
+
+	logrus.Infof("Hue music sync: %s now drives Hue room %s (enabled: %v)", mapping.SonosRoom, mapping.HueRoomID, mapping.Enabled)
+	return &mapping, nil
+}
+
+// RemoveMapping stops a Sonos room from driving its Hue room
+func (m *HueMusicSyncService) RemoveMapping(sonosRoom string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.mappings[sonosRoom]; !exists {
+		return false
+	}
+	delete(m.mappings, sonosRoom)
+	delete(m.status, sonosRoom)
+	delete(m.lastTrack, sonosRoom)
+	return true
+}
+
+// GetStatus returns the state of every music sync mapping
+func (m *HueMusicSyncService) GetStatus() []*models.HueMusicSyncStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	statuses := make([]*models.HueMusicSyncStatus, 0, len(m.status))
+	for _, status := range m.status {
+		copied := *status
+		statuses = append(statuses, &copied)
+	}
+	return statuses
+}
+
+// syncAll applies the current album art of every enabled mapping that changed track
+func (m *HueMusicSyncService) syncAll(ctx context.Context) {
+	m.mu.RLock()
+	mappings := make([]models.HueMusicSyncMapping, 0, len(m.mappings))
+	for _, mapping := range m.mappings {
+		if mapping.Enabled {
+			mappings = append(mappings, *mapping)
+		}
+	}
+	m.mu.RUnlock()
+
+	for _, mapping := range mappings {
+		if err := m.syncMapping(ctx, mapping, false); err != nil {
+			logrus.Warnf("Hue music sync failed for %s: %v", mapping.SonosRoom, err)
+			m.recordError(mapping.SonosRoom, err)
+		}
+	}
+}
+
+// SyncNow applies the current album art of a Sonos room immediately
+func (m *HueMusicSyncService) SyncNow(ctx context.Context, sonosRoom string) (*models.HueMusicSyncStatus, error) {
+	m.mu.RLock()
+	mapping, exists := m.mappings[sonosRoom]
+	m.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("no music sync mapping for %s", sonosRoom)
+	}
+
+	if err := m.syncMapping(ctx, *mapping, true); err != nil {
+		m.recordError(sonosRoom, err)
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	// The mapping may have been removed while the sync was running
+	status, exists := m.status[sonosRoom]
+	if !exists {
+		return nil, fmt.Errorf("no music sync mapping for %s", sonosRoom)
+	}
+	statusCopy := *status
+	return &statusCopy, nil
+}
+
+// syncMapping spreads the palette of the room's current album art across its Hue lights
+func (m *HueMusicSyncService) syncMapping(ctx context.Context, mapping models.HueMusicSyncMapping, force bool) error {
+	track, playbackState, err := m.sonosService.GetNowPlaying(ctx, mapping.SonosRoom)
+	if err != nil {
+		return fmt.Errorf("failed to get now playing: %w", err)
+	}
+	if track == nil || track.Art == "" {
+		return nil
+	}
+	if !force && playbackState != "PLAYING" {
+		return nil
+	}
+
+	trackKey := strings.Join([]string{track.Artist, track.Album, track.Title, track.Art}, "|")
+	m.mu.RLock()
+	unchanged := m.lastTrack[mapping.SonosRoom] == trackKey
+	m.mu.RUnlock()
+	if unchanged && !force {
+		return nil
+	}
+
+	img, err := m.fetchAlbumArt(ctx, track.Art)
+	if err != nil {
+		return err
+	}
+
+	palette := extractPalette(img, mapping.PaletteSize)
+	if len(palette) == 0 {
+		return fmt.Errorf("album art has no usable colors")
+	}
+
+	if err := m.applyPalette(mapping, palette); err != nil {
+		return err
+	}
+
+	hexPalette := make([]string, len(palette))
+	for i, c := range palette {
+		hexPalette[i] = c.Hex()
+	}
+
+	m.mu.Lock()
+	// Don't bring back the status of a mapping removed while the sync was running
+	if _, exists := m.mappings[mapping.SonosRoom]; exists {
+		m.lastTrack[mapping.SonosRoom] = trackKey
+		m.status[mapping.SonosRoom] = &models.HueMusicSyncStatus{
+			Mapping:      mapping,
+			CurrentTrack: track,
+			Palette:      hexPalette,
+			LastApplied:  time.Now(),
+		}
+	}
+	m.mu.Unlock()
+
+	logrus.Infof("Hue music sync: applied %d colors from %s - %s to room %s",
+		len(palette), track.Artist, track.Title, mapping.HueRoomID)
+	return nil
+}
+
+// fetchAlbumArt downloads and decodes an album art image
+func (m *HueMusicSyncService) fetchAlbumArt(ctx context.Context, artURL string) (image.Image, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", artURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create album art request: %w", err)
+	}
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch album art: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("album art returned status %d", resp.StatusCode)
+	}
+
+	img, _, err := image.Decode(io.LimitReader(resp.Body, maxAlbumArtBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode album art: %w", err)
+	}
+	return img, nil
+}
+
+// applyPalette assigns palette colors round-robin to the lit color lights of the Hue room
+func (m *HueMusicSyncService) applyPalette(mapping models.HueMusicSyncMapping, palette []color.RGB) error {
+	room, exists := m.hueService.GetGroup(mapping.HueRoomID)
+	if !exists {
+		return fmt.Errorf("hue room %s not found", mapping.HueRoomID)
+	}
+
+	lights := make(map[string]*models.HueLight)
+	for _, light := range m.hueService.GetLights() {
+		lights[light.ID] = light
+	}
+
+	transition := mapping.TransitionTime
+	applied := 0
+	for _, lightID := range room.Lights {
+		light, exists := lights[lightID]
+		if !exists || !light.IsOn || !light.IsReachable {
+			continue
+		}
+		// Skip white-only lights, they cannot show a palette color
+		if !lightSupportsColor(light) {
+			continue
+		}
+
+		xy, _ := palette[applied%len(palette)].ToXY()
+		xy = lightGamut(light).Clamp(xy)
+
+		state := &models.HueLightState{
+			XY:             []float64{xy.X, xy.Y},
+			TransitionTime: &transition,
+		}
+		if err := m.hueService.SetLightState(lightID, state); err != nil {
+			return fmt.Errorf("failed to set light %s: %w", lightID, err)
+		}
+		applied++
+	}
+
+	return nil
+}
+
+// recordError stores the last sync error for a mapping
+func (m *HueMusicSyncService) recordError(sonosRoom string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if status, exists := m.status[sonosRoom]; exists {
+		status.LastError = err.Error()
+	}
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"woodhome-webapp/internal/models"
+)
+
+func TestSyncNowMappingRemovedDuringSync(t *testing.T) {
+	var service *HueMusicSyncService
+	jishi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The mapping is removed while the now playing request is in flight
+		service.RemoveMapping("Kitchen")
+		w.Write([]byte(`{"playbackState":"PLAYING"}`))
+	}))
+	defer jishi.Close()
+
+	sonosService := NewSonosService(&models.SonosServiceConfig{JishiURL: jishi.URL, Timeout: 5 * time.Second})
+	service = NewHueMusicSyncService(NewHueService(nil), sonosService, nil)
+	if _, err := service.SetMapping(models.HueMusicSyncMapping{SonosRoom: "Kitchen", HueRoomID: "1", Enabled: true}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	status, err := service.SyncNow(t.Context(), "Kitchen")
+	if err == nil {
+		t.Fatalf("Expected an error for a removed mapping, got %+v", status)
+	}
+	if statuses := service.GetStatus(); len(statuses) != 0 {
+		t.Errorf("Expected no statuses after removal, got %d", len(statuses))
+	}
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"image"
+	"math"
+	"sort"
+
+	"woodhome-webapp/internal/color"
+
+	// Register decoders for the album art formats Sonos services hand out
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// paletteSampleSize is the number of samples taken along each image axis
+const paletteSampleSize = 64
+
+// paletteMinDistance is the minimum RGB distance between two palette colors
+const paletteMinDistance = 48.0
+
+// paletteBucket accumulates weighted color samples for one quantized color
+type paletteBucket struct {
+	r, g, b float64
+	weight  float64
+}
+
+// extractPalette returns up to count dominant colors of an image, most dominant first.
+// Near-black and transparent pixels are ignored and saturated pixels are favored,
+// since those make for the most recognizable light colors.
+func extractPalette(img image.Image, count int) []color.RGB {
+	if img == nil || count <= 0 {
+		return nil
+	}
+
+	bounds := img.Bounds()
+	stepX := bounds.Dx() / paletteSampleSize
+	if stepX < 1 {
+		stepX = 1
+	}
+	stepY := bounds.Dy() / paletteSampleSize
+	if stepY < 1 {
+		stepY = 1
+	}
+
+	// Quantize samples to 4 bits per channel
+	buckets := make(map[int]*paletteBucket)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += stepY {
+		for x := bounds.Min.X; x < bounds.Max.X; x += stepX {
+			r16, g16, b16, a16 := img.At(x, y).RGBA()
+			if a16 < 0x8000 {
+				continue
+			}
+			r, g, b := float64(r16>>8), float64(g16>>8), float64(b16>>8)
+
+			maxC := math.Max(r, math.Max(g, b))
+			minC := math.Min(r, math.Min(g, b))
+			if maxC < 24 {
+				continue
+			}
+			saturation := (maxC - minC) / maxC
+
+			key := int(r16>>12)<<8 | int(g16>>12)<<4 | int(b16>>12)
+			bucket, exists := buckets[key]
+			if !exists {
+				bucket = &paletteBucket{}
+				buckets[key] = bucket
+			}
+			weight := 1 + 4*saturation
+			bucket.r += r * weight
+			bucket.g += g * weight
+			bucket.b += b * weight
+			bucket.weight += weight
+		}
+	}
+
+	sorted := make([]*paletteBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		sorted = append(sorted, bucket)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].weight > sorted[j].weight
+	})
+
+	palette := make([]color.RGB, 0, count)
+	for _, bucket := range sorted {
+		candidate := color.RGB{
+			R: uint8(bucket.r / bucket.weight),
+			G: uint8(bucket.g / bucket.weight),
+			B: uint8(bucket.b / bucket.weight),
+		}
+
+		distinct := true
+		for _, picked := range palette {
+			if candidate.Distance(picked) < paletteMinDistance {
+				distinct = false
+				break
+			}
+		}
+		if !distinct {
+			continue
+		}
+
+		palette = append(palette, candidate)
+		if len(palette) == count {
+			break
+		}
+	}
+
+	return palette
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"image"
+	imagecolor "image/color"
+	"testing"
+)
+
+func TestExtractPalette(t *testing.T) {
+	// Left two thirds red, right third blue, with a black border row
+	img := image.NewRGBA(image.Rect(0, 0, 90, 90))
+	for y := 0; y < 90; y++ {
+		for x := 0; x < 90; x++ {
+			switch {
+			case y == 0:
+				img.Set(x, y, imagecolor.RGBA{0, 0, 0, 255})
+			case x < 60:
+				img.Set(x, y, imagecolor.RGBA{220, 20, 20, 255})
+			default:
+				img.Set(x, y, imagecolor.RGBA{20, 20, 220, 255})
+			}
+		}
+	}
+
+	palette := extractPalette(img, 3)
+	if len(palette) != 2 {
+		t.Fatalf("Expected 2 distinct colors, got %d: %v", len(palette), palette)
+	}
+	if palette[0].R < 200 || palette[0].B > 40 {
+		t.Fatalf("Expected red to be most dominant, got %v", palette[0])
+	}
+	if palette[1].B < 200 || palette[1].R > 40 {
+		t.Fatalf("Expected blue second, got %v", palette[1])
+	}
+}
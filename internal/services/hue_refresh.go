@@ -0,0 +1,493 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"woodhome-webapp/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrHueResourceNotFound is returned when the bridge has no resource at a path
+var ErrHueResourceNotFound = errors.New("resource not available")
+
+// hueErrorResourceNotAvailable is the bridge error type for an unknown resource
+const hueErrorResourceNotAvailable = 3
+
+// Bridge resources kept in memory by the refresh pipeline
+const (
+	hueResourceConfig  = "config"
+	hueResourceLights  = "lights"
+	hueResourceGroups  = "groups"
+	hueResourceScenes  = "scenes"
+	hueResourceSensors = "sensors"
+)
+
+// hueResources lists every resource refreshed on the regular poll
+var hueResources = []string{hueResourceConfig, hueResourceLights, hueResourceGroups, hueResourceScenes, hueResourceSensors}
+
+// hueResourceFetch serializes refreshes of one resource, so an older response never replaces a newer one.
+// A stale resource is applied on the next refresh even when the bridge returns the same body.
+type hueResourceFetch struct {
+	hash  [sha256.Size]byte
+	stale atomic.Bool
+	mu    sync.Mutex
+}
+
+// markStale makes the next refresh of resources replace the optimistic state written since the last one
+func (b *hueBridge) markStale(resources ...string) {
+	for _, resource := range resources {
+		if fetch, exists := b.fetches[resource]; exists {
+			fetch.stale.Store(true)
+		}
+	}
+}
+
+// newHueResourceFetches creates the fetch state for every resource
+func newHueResourceFetches() map[string]*hueResourceFetch {
+	fetches := make(map[string]*hueResourceFetch, len(hueResources))
+	for _, resource := range hueResources {
+		fetches[resource] = &hueResourceFetch{}
+	}
+	return fetches
+}
+
+// refreshResources refreshes resources of every connected bridge concurrently.
+// Unchanged resources are skipped unless force is set.
+func (h *HueService) refreshResources(force bool, resources ...string) error {
+	bridges := h.connectedBridges()
+	if len(bridges) == 0 {
+		return fmt.Errorf("bridge not configured")
+	}
+
+	errs := make([]error, len(bridges))
+	var wg sync.WaitGroup
+	for i, b := range bridges {
+		wg.Add(1)
+		go func(i int, b *hueBridge) {
+			defer wg.Done()
+			if err := h.refreshBridge(b, force, resources...); err != nil {
+				errs[i] = fmt.Errorf("bridge %s: %w", b.id, err)
+			}
+		}(i, b)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// refreshBridge fetches resources of one bridge concurrently and swaps each one in as it arrives.
+// The service lock is only taken to swap, never across a bridge request.
+func (h *HueService) refreshBridge(b *hueBridge, force bool, resources ...string) error {
+	if b.baseURL == "" {
+		return fmt.Errorf("bridge not configured")
+	}
+
+	errs := make([]error, len(resources))
+	var wg sync.WaitGroup
+	for i, resource := range resources {
+		wg.Add(1)
+		go func(i int, resource string) {
+			defer wg.Done()
+			if err := h.refreshResource(b, resource, force); err != nil {
+				errs[i] = fmt.Errorf("failed to update %s: %w", resource, err)
+			}
+		}(i, resource)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// refreshResource fetches one resource of a bridge and swaps it in when it changed
+func (h *HueService) refreshResource(b *hueBridge, resource string, force bool) error {
+	fetch, exists := b.fetches[resource]
+	if !exists {
+		return fmt.Errorf("unknown resource %s", resource)
+	}
+
+	fetch.mu.Lock()
+	defer fetch.mu.Unlock()
+
+	changed := false
+	body, err := b.fetchResource("/" + resource)
+	if err == nil {
+		hash := resourceHash(resource, body)
+		if fetch.stale.Swap(false) || force || hash != fetch.hash {
+			if err = h.applyResource(b, resource, body); err == nil {
+				fetch.hash = hash
+				changed = true
+			}
+		}
+	}
+
+	h.recordResourceStatus(b, resource, changed, err)
+	return err
+}
+
+// hueVolatileConfigKeys are bridge config fields that change on every poll
+var hueVolatileConfigKeys = []string{"UTC", "localtime", "whitelist"}
+
+// resourceHash fingerprints a resource body to detect changes between polls.
+// The bridge clock and the API key last use dates are left out of the config.
+func resourceHash(resource string, body []byte) [sha256.Size]byte {
+	if resource != hueResourceConfig {
+		return sha256.Sum256(body)
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal(body, &config); err != nil {
+		return sha256.Sum256(body)
+	}
+	for _, key := range hueVolatileConfigKeys {
+		delete(config, key)
+	}
+	stable, err := json.Marshal(config)
+	if err != nil {
+		return sha256.Sum256(body)
+	}
+	return sha256.Sum256(stable)
+}
+
+// fetchResource reads a resource from the bridge
+func (b *hueBridge) fetchResource(path string) ([]byte, error) {
+	resp, err := b.httpClient.Get(b.baseURL + path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bridge returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Errors such as an unauthorized user come back as a list instead of an object
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		var responses []models.HueAPIResponse
+		if err := json.Unmarshal(trimmed, &responses); err == nil {
+			for _, response := range responses {
+				if response.Error != nil && response.Error.Type == hueErrorResourceNotAvailable {
+					return nil, fmt.Errorf("%s: %w", path, ErrHueResourceNotFound)
+				}
+				if response.Error != nil {
+					return nil, fmt.Errorf("API error: %s", response.Error.Description)
+				}
+			}
+		}
+		return nil, fmt.Errorf("unexpected response for %s", path)
+	}
+
+	return body, nil
+}
+
+// applyResource decodes a bridge resource and swaps it into the service state.
+// IDs are namespaced by bridge and only the entries of that bridge are replaced.
+func (h *HueService) applyResource(b *hueBridge, resource string, body []byte) error {
+	switch resource {
+	case hueResourceConfig:
+		bridge, err := b.parseBridgeConfig(body)
+		if err != nil {
+			return err
+		}
+		h.mu.Lock()
+		b.info = bridge
+		h.mu.Unlock()
+
+	case hueResourceLights:
+		parsed, err := parseLights(body)
+		if err != nil {
+			return err
+		}
+		lights := make(map[string]*models.HueLight, len(parsed))
+		for _, light := range parsed {
+			light.ID = b.namespaced(light.ID)
+			light.BridgeID = b.id
+			lights[light.ID] = light
+		}
+		h.mu.Lock()
+		h.lights = mergeBridgeItems(h.lights, lights, func(light *models.HueLight) bool { return light.BridgeID == b.id })
+		h.mu.Unlock()
+		logrus.Debugf("Successfully updated %d lights of bridge %s", len(lights), b.id)
+
+	case hueResourceGroups:
+		parsed, err := parseGroups(body)
+		if err != nil {
+			return err
+		}
+		groups := make(map[string]*models.HueGroup, len(parsed))
+		for _, group := range parsed {
+			group.ID = b.namespaced(group.ID)
+			group.BridgeID = b.id
+			group.Lights = b.namespacedAll(group.Lights)
+			groups[group.ID] = group
+		}
+		h.mu.Lock()
+		h.groups = mergeBridgeItems(h.groups, groups, func(group *models.HueGroup) bool { return group.BridgeID == b.id })
+		h.mu.Unlock()
+
+	case hueResourceScenes:
+		parsed, err := parseScenes(body)
+		if err != nil {
+			return err
+		}
+		scenes := make(map[string]*models.HueScene, len(parsed))
+		for _, scene := range parsed {
+			b.namespaceScene(scene)
+			scenes[scene.ID] = scene
+		}
+		h.mu.Lock()
+		h.scenes = mergeBridgeItems(h.scenes, scenes, func(scene *models.HueScene) bool { return scene.BridgeID == b.id })
+		h.mu.Unlock()
+
+	case hueResourceSensors:
+		parsed, err := parseSensors(body)
+		if err != nil {
+			return err
+		}
+		sensors := make(map[string]*models.HueSensor, len(parsed))
+		for _, sensor := range parsed {
+			sensor.ID = b.namespaced(sensor.ID)
+			sensor.BridgeID = b.id
+			sensors[sensor.ID] = sensor
+		}
+		h.mu.Lock()
+		for sensorID, sensor := range sensors {
+			if previous, exists := h.sensors[sensorID]; exists {
+				h.publishSensorEvents(previous, sensor)
+			}
+		}
+		h.sensors = mergeBridgeItems(h.sensors, sensors, func(sensor *models.HueSensor) bool { return sensor.BridgeID == b.id })
+		h.mu.Unlock()
+	}
+
+	return nil
+}
+
+// recordResourceStatus stores the outcome of a resource refresh
+func (h *HueService) recordResourceStatus(b *hueBridge, resource string, changed bool, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	status := models.HueResourceStatus{Resource: resource, BridgeID: b.id}
+	if previous, exists := b.resourceStatus[resource]; exists {
+		status = *previous
+	}
+
+	now := time.Now()
+	if err != nil {
+		status.LastError = err.Error()
+		status.LastErrorAt = now
+	} else {
+		status.LastChecked = now
+		status.LastError = ""
+		if changed {
+			status.LastChanged = now
+		}
+	}
+	b.resourceStatus[resource] = &status
+}
+
+// GetRefreshStatus returns the freshness of every resource of every bridge
+func (h *HueService) GetRefreshStatus() []*models.HueResourceStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	statuses := make([]*models.HueResourceStatus, 0, len(hueResources)*len(h.bridges))
+	for _, b := range h.bridges {
+		statuses = append(statuses, b.refreshStatus()...)
+	}
+	return statuses
+}
+
+// refreshStatus returns the freshness of the bridge resources.
+// Callers must hold the service lock.
+func (b *hueBridge) refreshStatus() []*models.HueResourceStatus {
+	statuses := make([]*models.HueResourceStatus, 0, len(hueResources))
+	for _, resource := range hueResources {
+		if status, exists := b.resourceStatus[resource]; exists {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+// parseBridgeConfig builds the bridge description from the bridge config
+func (b *hueBridge) parseBridgeConfig(body []byte) (*models.HueBridge, error) {
+	var config map[string]interface{}
+	if err := json.Unmarshal(body, &config); err != nil {
+		return nil, fmt.Errorf("failed to decode bridge config: %w", err)
+	}
+
+	bridge := &models.HueBridge{
+		ID:       b.id,
+		IP:       b.ip,
+		Username: b.username,
+		IsOnline: true,
+		LastSeen: time.Now(),
+	}
+
+	if name, ok := config["name"].(string); ok {
+		bridge.Name = name
+	}
+	if version, ok := config["swversion"].(string); ok {
+		bridge.SwVersion = version
+	}
+	if api, ok := config["apiversion"].(string); ok {
+		bridge.API = api
+	}
+	if modelID, ok := config["modelid"].(string); ok {
+		bridge.ModelID = modelID
+	}
+
+	return bridge, nil
+}
+
+// parseLights decodes the bridge light list
+func parseLights(body []byte) (map[string]*models.HueLight, error) {
+	var lightsData map[string]interface{}
+	if err := json.Unmarshal(body, &lightsData); err != nil {
+		return nil, fmt.Errorf("failed to decode lights data: %w", err)
+	}
+
+	lights := make(map[string]*models.HueLight, len(lightsData))
+	for lightID, lightData := range lightsData {
+		// Extract the state data and merge it with the main light data
+		lightMap, ok := lightData.(map[string]interface{})
+		if !ok {
+			logrus.Warnf("Failed to parse light %s data", lightID)
+			continue
+		}
+
+		// Extract state data if it exists
+		if stateData, exists := lightMap["state"]; exists {
+			if stateMap, ok := stateData.(map[string]interface{}); ok {
+				// Merge state data into the main light data
+				for key, value := range stateMap {
+					lightMap[key] = value
+				}
+			}
+		}
+
+		// Extract the color gamut reported under capabilities.control
+		if capabilities, ok := lightMap["capabilities"].(map[string]interface{}); ok {
+			if control, ok := capabilities["control"].(map[string]interface{}); ok {
+				if gamut, exists := control["colorgamut"]; exists {
+					lightMap["colorgamut"] = gamut
+				}
+				if gamutType, exists := control["colorgamuttype"]; exists {
+					lightMap["colorgamuttype"] = gamutType
+				}
+			}
+		}
+
+		lightBytes, err := json.Marshal(lightMap)
+		if err != nil {
+			logrus.Warnf("Failed to marshal light %s: %v", lightID, err)
+			continue
+		}
+
+		var light models.HueLight
+		if err := json.Unmarshal(lightBytes, &light); err != nil {
+			logrus.Warnf("Failed to unmarshal light %s: %v", lightID, err)
+			continue
+		}
+
+		light.ID = lightID
+		light.LastSeen = time.Now()
+		annotateLightColor(&light)
+		lights[lightID] = &light
+	}
+
+	return lights, nil
+}
+
+// parseGroups decodes the bridge group list; rooms and zones are groups of type Room and Zone
+func parseGroups(body []byte) (map[string]*models.HueGroup, error) {
+	var groupsData map[string]interface{}
+	if err := json.Unmarshal(body, &groupsData); err != nil {
+		return nil, fmt.Errorf("failed to decode groups data: %w", err)
+	}
+
+	groups := make(map[string]*models.HueGroup, len(groupsData))
+	for groupID, groupData := range groupsData {
+		groupMap, ok := groupData.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		// Extract state data if it exists
+		var isOn bool
+		var brightness int
+		if stateData, exists := groupMap["state"]; exists {
+			if stateMap, ok := stateData.(map[string]interface{}); ok {
+				if anyOn, exists := stateMap["any_on"]; exists {
+					if anyOnBool, ok := anyOn.(bool); ok {
+						isOn = anyOnBool
+					}
+				}
+			}
+		}
+
+		// Extract action data for brightness
+		if actionData, exists := groupMap["action"]; exists {
+			if actionMap, ok := actionData.(map[string]interface{}); ok {
+				if bri, exists := actionMap["bri"]; exists {
+					if briFloat, ok := bri.(float64); ok {
+						brightness = int(briFloat)
+					}
+				}
+			}
+		}
+
+		groupBytes, err := json.Marshal(groupData)
+		if err != nil {
+			continue
+		}
+
+		var group models.HueGroup
+		if err := json.Unmarshal(groupBytes, &group); err != nil {
+			continue
+		}
+
+		group.ID = groupID
+		group.IsOn = isOn
+		group.Brightness = brightness
+		groups[groupID] = &group
+	}
+
+	return groups, nil
+}
+
+// parseScenes decodes the bridge scene list
+func parseScenes(body []byte) (map[string]*models.HueScene, error) {
+	var scenesData map[string]json.RawMessage
+	if err := json.Unmarshal(body, &scenesData); err != nil {
+		return nil, fmt.Errorf("failed to decode scenes data: %w", err)
+	}
+
+	scenes := make(map[string]*models.HueScene, len(scenesData))
+	for sceneID, sceneData := range scenesData {
+		var scene models.HueScene
+		if err := json.Unmarshal(sceneData, &scene); err != nil {
+			continue
+		}
+
+		scene.ID = sceneID
+		scenes[sceneID] = &scene
+	}
+
+	return scenes, nil
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"woodhome-webapp/internal/models"
+)
+
+func TestRefreshResourcesSkipsUnchanged(t *testing.T) {
+	var lightsBody atomic.Value
+	lightsBody.Store(`{"1":{"name":"Hallway","type":"Extended color light","state":{"on":true,"bri":200,"colormode":"ct","ct":370,"reachable":true}}}`)
+
+	bridge := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/lights":
+			w.Write([]byte(lightsBody.Load().(string)))
+		case "/groups":
+			w.Write([]byte(`[{"error":{"type":1,"address":"/groups","description":"unauthorized user"}}]`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer bridge.Close()
+
+	service := NewHueService(nil)
+	service.primary().baseURL = bridge.URL
+
+	if err := service.refreshResources(false, hueResourceLights, hueResourceGroups); err == nil {
+		t.Fatal("Expected the groups API error to be returned")
+	}
+
+	lights := service.GetLights()
+	if len(lights) != 1 || !lights[0].IsOn || lights[0].Kelvin != 2703 {
+		t.Fatalf("Unexpected lights: %+v", lights)
+	}
+
+	statuses := make(map[string]string)
+	for _, status := range service.GetRefreshStatus() {
+		statuses[status.Resource] = status.LastError
+	}
+	if statuses[hueResourceLights] != "" || statuses[hueResourceGroups] != "API error: unauthorized user" {
+		t.Fatalf("Unexpected refresh status: %v", statuses)
+	}
+
+	// An unchanged response keeps the current snapshot, including optimistic changes
+	service.mu.Lock()
+	service.lights["1"].IsOn = false
+	service.mu.Unlock()
+	if err := service.refreshResources(false, hueResourceLights); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if service.GetLights()[0].IsOn {
+		t.Fatal("Expected unchanged lights response to be skipped")
+	}
+
+	lightsBody.Store(`{"1":{"name":"Hallway","type":"Extended color light","state":{"on":true,"bri":100,"reachable":true}}}`)
+	if err := service.refreshResources(false, hueResourceLights); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if light := service.GetLights()[0]; !light.IsOn || light.Brightness != 100 {
+		t.Fatalf("Expected changed lights to be swapped in, got %+v", light)
+	}
+}
+
+func TestRefreshResourcesIgnoresBridgeClock(t *testing.T) {
+	var configBody atomic.Value
+	configBody.Store(`{"name":"Home","swversion":"1967054020","UTC":"2024-10-25T16:00:00","localtime":"2024-10-25T11:00:00"}`)
+
+	bridge := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(configBody.Load().(string)))
+	}))
+	defer bridge.Close()
+
+	service := NewHueService(nil)
+	service.primary().baseURL = bridge.URL
+	lastChanged := func() time.Time {
+		for _, status := range service.GetRefreshStatus() {
+			if status.Resource == hueResourceConfig {
+				return status.LastChanged
+			}
+		}
+		return time.Time{}
+	}
+
+	if err := service.refreshResources(false, hueResourceConfig); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	first := lastChanged()
+
+	configBody.Store(`{"name":"Home","swversion":"1967054020","UTC":"2024-10-25T16:00:05","localtime":"2024-10-25T11:00:05"}`)
+	if err := service.refreshResources(false, hueResourceConfig); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !lastChanged().Equal(first) {
+		t.Fatal("Expected a config that only differs in the bridge clock to be unchanged")
+	}
+
+	configBody.Store(`{"name":"Home","swversion":"1968096020","UTC":"2024-10-25T16:00:10","localtime":"2024-10-25T11:00:10"}`)
+	if err := service.refreshResources(false, hueResourceConfig); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if lastChanged().Equal(first) {
+		t.Fatal("Expected a new software version to be picked up")
+	}
+}
+
+func TestRefreshReplacesOptimisticState(t *testing.T) {
+	bridge := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/lights":
+			w.Write([]byte(`{"1":{"name":"Hallway","type":"Dimmable light","state":{"on":false,"bri":100,"reachable":true}}}`))
+		case r.Method == "PUT":
+			w.Write([]byte(`[{"success":{"/lights/1/state/on":true}}]`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer bridge.Close()
+
+	service := NewHueService(nil)
+	service.primary().baseURL = bridge.URL
+	service.primary().commands.Start(t.Context())
+	if err := service.refreshResources(false, hueResourceLights); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The light was switched back off elsewhere, so the bridge returns the body of the last poll
+	on := true
+	if err := service.SetLightState("1", &models.HueLightState{On: &on}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !service.GetLights()[0].IsOn {
+		t.Fatal("Expected the change to be applied right away")
+	}
+	if err := service.refreshResources(false, hueResourceLights); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if service.GetLights()[0].IsOn {
+		t.Fatal("Expected the bridge state to replace the optimistic state")
+	}
+}
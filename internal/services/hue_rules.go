@@ -0,0 +1,210 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"woodhome-webapp/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The bridge accepts at most eight conditions and eight actions per rule
+const hueRuleMaxItems = 8
+
+// GetRules fetches the rules stored on every bridge
+func (h *HueService) GetRules() ([]*models.HueRule, error) {
+	bridges := h.connectedBridges()
+	if len(bridges) == 0 {
+		return nil, fmt.Errorf("bridge not configured")
+	}
+
+	rules := []*models.HueRule{}
+	for _, b := range bridges {
+		body, err := b.fetchResource("/rules")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get rules of bridge %s: %w", b.id, err)
+		}
+
+		var rulesMap map[string]*models.HueRule
+		if err := json.Unmarshal(body, &rulesMap); err != nil {
+			return nil, fmt.Errorf("failed to decode rules: %w", err)
+		}
+
+		for id, rule := range rulesMap {
+			rule.ID = b.namespaced(id)
+			rule.BridgeID = b.id
+			rules = append(rules, rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].Name < rules[j].Name
+	})
+	return rules, nil
+}
+
+// GetRule fetches a single rule from the bridge
+func (h *HueService) GetRule(ruleID string) (*models.HueRule, error) {
+	b, localID, err := h.resolve(ruleID)
+	if err != nil {
+		return nil, err
+	}
+	if b.baseURL == "" {
+		return nil, fmt.Errorf("bridge not configured")
+	}
+
+	body, err := b.fetchResource("/rules/" + localID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rule: %w", err)
+	}
+
+	var rule models.HueRule
+	if err := json.Unmarshal(body, &rule); err != nil {
+		return nil, fmt.Errorf("failed to decode rule: %w", err)
+	}
+
+	rule.ID = ruleID
+	rule.BridgeID = b.id
+	return &rule, nil
+}
+
+// CreateRule stores a new rule on the bridge given in the request, or the primary bridge
+func (h *HueService) CreateRule(req *models.HueRuleRequest) (*models.HueRule, error) {
+	if req.Name == "" {
+		return nil, fmt.Errorf("rule name is required")
+	}
+	if len(req.Conditions) == 0 || len(req.Actions) == 0 {
+		return nil, fmt.Errorf("a rule needs at least one condition and one action")
+	}
+
+	b, err := h.bridgeByID(req.BridgeID)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := rulePayload(req)
+	if err != nil {
+		return nil, err
+	}
+
+	responses, err := b.bridgeRequest("POST", "/rules", payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rule: %w", err)
+	}
+	ruleID := b.namespaced(createdID(responses))
+	if ruleID == "" {
+		return nil, fmt.Errorf("bridge did not return a rule ID")
+	}
+
+	logrus.Infof("Created Hue rule %s (%s)", req.Name, ruleID)
+	return h.GetRule(ruleID)
+}
+
+// UpdateRule changes the given fields of a rule. Conditions and actions are replaced as a whole.
+func (h *HueService) UpdateRule(ruleID string, req *models.HueRuleRequest) (*models.HueRule, error) {
+	b, localID, err := h.resolve(ruleID)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := rulePayload(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) == 0 {
+		return nil, fmt.Errorf("nothing to update")
+	}
+
+	if _, err := b.bridgeRequest("PUT", fmt.Sprintf("/rules/%s", localID), payload); err != nil {
+		return nil, fmt.Errorf("failed to update rule: %w", err)
+	}
+
+	return h.GetRule(ruleID)
+}
+
+// DeleteRule removes a rule from the bridge
+func (h *HueService) DeleteRule(ruleID string) error {
+	b, localID, err := h.resolve(ruleID)
+	if err != nil {
+		return err
+	}
+	if _, err := b.bridgeRequest("DELETE", fmt.Sprintf("/rules/%s", localID), nil); err != nil {
+		return fmt.Errorf("failed to delete rule: %w", err)
+	}
+
+	logrus.Infof("Deleted Hue rule %s", ruleID)
+	return nil
+}
+
+// rulePayload converts the fields set in a rule request into a bridge payload
+func rulePayload(req *models.HueRuleRequest) (map[string]interface{}, error) {
+	payload := make(map[string]interface{})
+
+	if req.Name != "" {
+		payload["name"] = req.Name
+	}
+	if req.Status != "" {
+		if req.Status != "enabled" && req.Status != "disabled" {
+			return nil, fmt.Errorf("status must be enabled or disabled")
+		}
+		payload["status"] = req.Status
+	}
+
+	if len(req.Conditions) > 0 {
+		if len(req.Conditions) > hueRuleMaxItems {
+			return nil, fmt.Errorf("a rule has at most %d conditions", hueRuleMaxItems)
+		}
+		for i := range req.Conditions {
+			if err := validateCondition(&req.Conditions[i]); err != nil {
+				return nil, fmt.Errorf("condition %d: %w", i+1, err)
+			}
+		}
+		payload["conditions"] = req.Conditions
+	}
+
+	if len(req.Actions) > 0 {
+		if len(req.Actions) > hueRuleMaxItems {
+			return nil, fmt.Errorf("a rule has at most %d actions", hueRuleMaxItems)
+		}
+		actions := make([]models.HueCommand, len(req.Actions))
+		for i, action := range req.Actions {
+			if err := validateCommand(&action); err != nil {
+				return nil, fmt.Errorf("action %d: %w", i+1, err)
+			}
+			// Rule actions are relative to the bridge user
+			actions[i] = models.HueCommand{
+				Address: relativeAddress(action.Address),
+				Method:  strings.ToUpper(action.Method),
+				Body:    action.Body,
+			}
+		}
+		payload["actions"] = actions
+	}
+
+	return payload, nil
+}
+
+// validateCondition checks the operator and value of a rule condition
+func validateCondition(condition *models.HueRuleCondition) error {
+	if !strings.HasPrefix(condition.Address, "/") {
+		return fmt.Errorf("address must start with /")
+	}
+
+	switch condition.Operator {
+	case models.HueRuleOpChanged:
+		if condition.Value != "" {
+			return fmt.Errorf("%s takes no value", condition.Operator)
+		}
+	case models.HueRuleOpEquals, models.HueRuleOpGreaterThan, models.HueRuleOpLessThan,
+		models.HueRuleOpChangedAt, models.HueRuleOpStable, models.HueRuleOpNotStable,
+		models.HueRuleOpIn, models.HueRuleOpNotIn:
+		if condition.Value == "" {
+			return fmt.Errorf("%s needs a value", condition.Operator)
+		}
+	default:
+		return fmt.Errorf("unknown operator %q", condition.Operator)
+	}
+	return nil
+}
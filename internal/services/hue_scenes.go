@@ -0,0 +1,167 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"woodhome-webapp/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// GetScene fetches a scene from the bridge including its per-light states
+func (h *HueService) GetScene(sceneID string) (*models.HueScene, error) {
+	b, localID, err := h.resolve(sceneID)
+	if err != nil {
+		return nil, err
+	}
+	if b.baseURL == "" {
+		return nil, fmt.Errorf("bridge not configured")
+	}
+
+	body, err := b.fetchResource("/scenes/" + localID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scene: %w", err)
+	}
+
+	var scene models.HueScene
+	if err := json.Unmarshal(body, &scene); err != nil {
+		return nil, fmt.Errorf("failed to decode scene: %w", err)
+	}
+
+	scene.ID = localID
+	b.namespaceScene(&scene)
+	for _, state := range scene.LightStates {
+		annotateSceneLightState(state)
+	}
+	return &scene, nil
+}
+
+// CreateSceneFromRoom stores the current state of a room's lights as a new scene
+func (h *HueService) CreateSceneFromRoom(roomID, name string) (*models.HueScene, error) {
+	if name == "" {
+		return nil, fmt.Errorf("scene name is required")
+	}
+	if roomID == "" {
+		return nil, fmt.Errorf("room ID is required")
+	}
+	b, localRoomID, err := h.resolve(roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The bridge captures the current light states of the group for GroupScenes
+	payload := map[string]interface{}{
+		"name":    name,
+		"type":    "GroupScene",
+		"group":   localRoomID,
+		"recycle": false,
+	}
+	responses, err := b.bridgeRequest("POST", "/scenes", payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scene: %w", err)
+	}
+
+	sceneID := b.namespaced(createdID(responses))
+	if sceneID == "" {
+		return nil, fmt.Errorf("bridge did not return a scene ID")
+	}
+
+	logrus.Infof("Created Hue scene %s (%s) for room %s", name, sceneID, roomID)
+
+	scene, err := h.GetScene(sceneID)
+	if err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	h.scenes[sceneID] = sceneSummary(scene)
+	h.mu.Unlock()
+
+	return scene, nil
+}
+
+// RenameScene changes the name of a scene
+func (h *HueService) RenameScene(sceneID, name string) error {
+	if name == "" {
+		return fmt.Errorf("scene name is required")
+	}
+	b, localID, err := h.resolve(sceneID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := b.bridgeRequest("PUT", fmt.Sprintf("/scenes/%s", localID), map[string]string{"name": name}); err != nil {
+		return fmt.Errorf("failed to rename scene: %w", err)
+	}
+
+	h.mu.Lock()
+	if existing, exists := h.scenes[sceneID]; exists {
+		scene := *existing
+		scene.Name = name
+		h.scenes[sceneID] = &scene
+	}
+	h.mu.Unlock()
+
+	return nil
+}
+
+// DeleteScene removes a scene from the bridge
+func (h *HueService) DeleteScene(sceneID string) error {
+	b, localID, err := h.resolve(sceneID)
+	if err != nil {
+		return err
+	}
+	if _, err := b.bridgeRequest("DELETE", fmt.Sprintf("/scenes/%s", localID), nil); err != nil {
+		return fmt.Errorf("failed to delete scene: %w", err)
+	}
+
+	h.mu.Lock()
+	delete(h.scenes, sceneID)
+	h.mu.Unlock()
+
+	logrus.Infof("Deleted Hue scene %s", sceneID)
+	return nil
+}
+
+// SetSceneLightState changes the state a scene stores for one of its lights
+func (h *HueService) SetSceneLightState(sceneID, lightID string, req *models.HueSceneLightRequest) error {
+	b, localID, err := h.resolve(sceneID)
+	if err != nil {
+		return err
+	}
+	localLightIDs, err := h.resolveAll(b, []string{lightID})
+	if err != nil {
+		return err
+	}
+
+	state := &models.HueLightState{}
+	if hasColor(&req.HueColorRequest) {
+		colorState, err := h.LightColorState(lightID, &req.HueColorRequest)
+		if err != nil {
+			return err
+		}
+		state = colorState
+	} else if req.On == nil && req.Brightness == nil {
+		return fmt.Errorf("no light state given")
+	}
+
+	state.On = req.On
+	if req.Brightness != nil {
+		state.Brightness = req.Brightness
+	}
+
+	path := fmt.Sprintf("/scenes/%s/lightstates/%s", localID, localLightIDs[0])
+	if _, err := b.bridgeRequest("PUT", path, state); err != nil {
+		return fmt.Errorf("failed to update scene light state: %w", err)
+	}
+
+	return nil
+}
+
+// sceneSummary returns a copy of a scene without its light states, as kept in the scene list
+func sceneSummary(scene *models.HueScene) *models.HueScene {
+	summary := *scene
+	summary.LightStates = nil
+	return &summary
+}
@@ -0,0 +1,404 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"woodhome-webapp/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// scheduleDayBits are the bridge's weekday bits for recurring schedules, Monday first
+var scheduleDayBits = []struct {
+	day string
+	bit int
+}{
+	{"mon", 64}, {"tue", 32}, {"wed", 16}, {"thu", 8}, {"fri", 4}, {"sat", 2}, {"sun", 1},
+}
+
+// GetSchedules fetches the schedules stored on every bridge
+func (h *HueService) GetSchedules() ([]*models.HueSchedule, error) {
+	bridges := h.connectedBridges()
+	if len(bridges) == 0 {
+		return nil, fmt.Errorf("bridge not configured")
+	}
+
+	schedules := []*models.HueSchedule{}
+	for _, b := range bridges {
+		body, err := b.fetchResource("/schedules")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get schedules of bridge %s: %w", b.id, err)
+		}
+
+		var schedulesMap map[string]*models.HueSchedule
+		if err := json.Unmarshal(body, &schedulesMap); err != nil {
+			return nil, fmt.Errorf("failed to decode schedules: %w", err)
+		}
+
+		for id, schedule := range schedulesMap {
+			schedule.ID = b.namespaced(id)
+			schedule.BridgeID = b.id
+			annotateSchedule(schedule)
+			schedules = append(schedules, schedule)
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].Name < schedules[j].Name
+	})
+	return schedules, nil
+}
+
+// GetSchedule fetches a single schedule from the bridge
+func (h *HueService) GetSchedule(scheduleID string) (*models.HueSchedule, error) {
+	b, localID, err := h.resolve(scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	if b.baseURL == "" {
+		return nil, fmt.Errorf("bridge not configured")
+	}
+
+	body, err := b.fetchResource("/schedules/" + localID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule: %w", err)
+	}
+
+	var schedule models.HueSchedule
+	if err := json.Unmarshal(body, &schedule); err != nil {
+		return nil, fmt.Errorf("failed to decode schedule: %w", err)
+	}
+
+	schedule.ID = scheduleID
+	schedule.BridgeID = b.id
+	annotateSchedule(&schedule)
+	return &schedule, nil
+}
+
+// CreateSchedule stores a new schedule on the bridge given in the request, or the primary bridge
+func (h *HueService) CreateSchedule(req *models.HueScheduleRequest) (*models.HueSchedule, error) {
+	if req.Name == "" {
+		return nil, fmt.Errorf("schedule name is required")
+	}
+	if req.Command == nil {
+		return nil, fmt.Errorf("schedule command is required")
+	}
+	if req.Time == nil && req.LocalTime == "" {
+		return nil, fmt.Errorf("schedule time is required")
+	}
+
+	b, err := h.bridgeByID(req.BridgeID)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := b.schedulePayload(req)
+	if err != nil {
+		return nil, err
+	}
+
+	responses, err := b.bridgeRequest("POST", "/schedules", payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schedule: %w", err)
+	}
+	scheduleID := b.namespaced(createdID(responses))
+	if scheduleID == "" {
+		return nil, fmt.Errorf("bridge did not return a schedule ID")
+	}
+
+	logrus.Infof("Created Hue schedule %s (%s) at %s", req.Name, scheduleID, payload["localtime"])
+	return h.GetSchedule(scheduleID)
+}
+
+// UpdateSchedule changes the given fields of a schedule
+func (h *HueService) UpdateSchedule(scheduleID string, req *models.HueScheduleRequest) (*models.HueSchedule, error) {
+	b, localID, err := h.resolve(scheduleID)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := b.schedulePayload(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) == 0 {
+		return nil, fmt.Errorf("nothing to update")
+	}
+
+	if _, err := b.bridgeRequest("PUT", fmt.Sprintf("/schedules/%s", localID), payload); err != nil {
+		return nil, fmt.Errorf("failed to update schedule: %w", err)
+	}
+
+	return h.GetSchedule(scheduleID)
+}
+
+// DeleteSchedule removes a schedule from the bridge
+func (h *HueService) DeleteSchedule(scheduleID string) error {
+	b, localID, err := h.resolve(scheduleID)
+	if err != nil {
+		return err
+	}
+	if _, err := b.bridgeRequest("DELETE", fmt.Sprintf("/schedules/%s", localID), nil); err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+
+	logrus.Infof("Deleted Hue schedule %s", scheduleID)
+	return nil
+}
+
+// schedulePayload converts the fields set in a schedule request into a bridge payload
+func (b *hueBridge) schedulePayload(req *models.HueScheduleRequest) (map[string]interface{}, error) {
+	payload := make(map[string]interface{})
+
+	if req.Name != "" {
+		payload["name"] = req.Name
+	}
+	if req.Description != "" {
+		payload["description"] = req.Description
+	}
+
+	if req.Command != nil {
+		if err := validateCommand(req.Command); err != nil {
+			return nil, err
+		}
+		// Schedules call the full API path, unlike rule actions
+		payload["command"] = models.HueCommand{
+			Address: "/api/" + b.username + relativeAddress(req.Command.Address),
+			Method:  strings.ToUpper(req.Command.Method),
+			Body:    req.Command.Body,
+		}
+	}
+
+	switch {
+	case req.Time != nil:
+		localTime, err := formatScheduleTime(req.Time)
+		if err != nil {
+			return nil, err
+		}
+		payload["localtime"] = localTime
+	case req.LocalTime != "":
+		if _, err := parseScheduleTime(req.LocalTime); err != nil {
+			return nil, err
+		}
+		payload["localtime"] = req.LocalTime
+	}
+
+	if req.Status != "" {
+		if req.Status != "enabled" && req.Status != "disabled" {
+			return nil, fmt.Errorf("status must be enabled or disabled")
+		}
+		payload["status"] = req.Status
+	}
+	if req.AutoDelete != nil {
+		payload["autodelete"] = *req.AutoDelete
+	}
+
+	return payload, nil
+}
+
+// annotateSchedule makes the command address relative and decodes the local time
+func annotateSchedule(schedule *models.HueSchedule) {
+	schedule.Command.Address = relativeAddress(schedule.Command.Address)
+	if scheduleTime, err := parseScheduleTime(schedule.LocalTime); err == nil {
+		schedule.Time = scheduleTime
+	}
+}
+
+// validateCommand checks a schedule command or rule action
+func validateCommand(command *models.HueCommand) error {
+	if !strings.HasPrefix(command.Address, "/") {
+		return fmt.Errorf("command address must start with /")
+	}
+	switch strings.ToUpper(command.Method) {
+	case "PUT", "POST":
+		if command.Body == nil {
+			return fmt.Errorf("command body is required for %s", command.Method)
+		}
+	case "DELETE":
+	default:
+		return fmt.Errorf("command method must be PUT, POST or DELETE")
+	}
+	return nil
+}
+
+// relativeAddress strips the /api/<username> prefix from a bridge address
+func relativeAddress(address string) string {
+	if !strings.HasPrefix(address, "/api/") {
+		return address
+	}
+	rest := strings.TrimPrefix(address, "/api/")
+	if i := strings.Index(rest, "/"); i >= 0 {
+		return rest[i:]
+	}
+	return "/"
+}
+
+// formatScheduleTime encodes a schedule time in the bridge's localtime format:
+// 2024-05-01T07:00:00 once, W124/T07:00:00 on weekdays, PT00:10:00 or R05/PT00:10:00 timers,
+// each optionally followed by A00:30:00 for a random delay.
+func formatScheduleTime(t *models.HueScheduleTime) (string, error) {
+	var localTime string
+
+	switch t.Kind {
+	case models.HueScheduleOnce:
+		date, err := time.Parse("2006-01-02", t.Date)
+		if err != nil {
+			return "", fmt.Errorf("date must be YYYY-MM-DD: %w", err)
+		}
+		clock, err := normalizeClock(t.Time)
+		if err != nil {
+			return "", err
+		}
+		localTime = date.Format("2006-01-02") + "T" + clock
+
+	case models.HueScheduleRecurring:
+		bits := 0
+		for _, day := range t.Weekdays {
+			bit := scheduleDayBit(day)
+			if bit == 0 {
+				return "", fmt.Errorf("unknown weekday %q", day)
+			}
+			bits |= bit
+		}
+		if bits == 0 {
+			return "", fmt.Errorf("at least one weekday is required")
+		}
+		clock, err := normalizeClock(t.Time)
+		if err != nil {
+			return "", err
+		}
+		localTime = fmt.Sprintf("W%03d/T%s", bits, clock)
+
+	case models.HueScheduleTimer:
+		duration, err := normalizeClock(t.Duration)
+		if err != nil {
+			return "", fmt.Errorf("invalid duration: %w", err)
+		}
+		switch {
+		case t.Repeat < 0:
+			localTime = "R/PT" + duration
+		case t.Repeat > 0:
+			if t.Repeat > 99 {
+				return "", fmt.Errorf("a timer repeats at most 99 times")
+			}
+			localTime = fmt.Sprintf("R%02d/PT%s", t.Repeat, duration)
+		default:
+			localTime = "PT" + duration
+		}
+
+	default:
+		return "", fmt.Errorf("schedule kind must be %s, %s or %s", models.HueScheduleOnce, models.HueScheduleRecurring, models.HueScheduleTimer)
+	}
+
+	if t.Random != "" {
+		random, err := normalizeClock(t.Random)
+		if err != nil {
+			return "", fmt.Errorf("invalid random delay: %w", err)
+		}
+		localTime += "A" + random
+	}
+	return localTime, nil
+}
+
+// parseScheduleTime decodes a bridge localtime, see formatScheduleTime
+func parseScheduleTime(localTime string) (*models.HueScheduleTime, error) {
+	t := &models.HueScheduleTime{}
+
+	value := localTime
+	if i := strings.Index(value, "A"); i >= 0 {
+		random, err := normalizeClock(value[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid random delay in %q", localTime)
+		}
+		t.Random = random
+		value = value[:i]
+	}
+
+	switch {
+	case strings.HasPrefix(value, "W"):
+		parts := strings.SplitN(value[1:], "/T", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid recurring time %q", localTime)
+		}
+		bits, err := strconv.Atoi(parts[0])
+		if err != nil || bits <= 0 || bits > 127 {
+			return nil, fmt.Errorf("invalid weekdays in %q", localTime)
+		}
+		clock, err := normalizeClock(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid time in %q", localTime)
+		}
+		t.Kind = models.HueScheduleRecurring
+		t.Time = clock
+		for _, day := range scheduleDayBits {
+			if bits&day.bit != 0 {
+				t.Weekdays = append(t.Weekdays, day.day)
+			}
+		}
+
+	case strings.HasPrefix(value, "R"), strings.HasPrefix(value, "PT"):
+		t.Kind = models.HueScheduleTimer
+		if strings.HasPrefix(value, "R") {
+			parts := strings.SplitN(value[1:], "/", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid timer %q", localTime)
+			}
+			t.Repeat = -1
+			if parts[0] != "" {
+				repeat, err := strconv.Atoi(parts[0])
+				if err != nil || repeat <= 0 {
+					return nil, fmt.Errorf("invalid timer repetitions in %q", localTime)
+				}
+				t.Repeat = repeat
+			}
+			value = parts[1]
+		}
+		if !strings.HasPrefix(value, "PT") {
+			return nil, fmt.Errorf("invalid timer %q", localTime)
+		}
+		duration, err := normalizeClock(strings.TrimPrefix(value, "PT"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid timer duration in %q", localTime)
+		}
+		t.Duration = duration
+
+	default:
+		at, err := time.Parse("2006-01-02T15:04:05", value)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported schedule time %q", localTime)
+		}
+		t.Kind = models.HueScheduleOnce
+		t.Date = at.Format("2006-01-02")
+		t.Time = at.Format("15:04:05")
+	}
+
+	return t, nil
+}
+
+// normalizeClock turns HH:MM or HH:MM:SS into HH:MM:SS
+func normalizeClock(value string) (string, error) {
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed.Format("15:04:05"), nil
+		}
+	}
+	return "", fmt.Errorf("time must be HH:MM or HH:MM:SS, got %q", value)
+}
+
+// scheduleDayBit returns the bridge bit of a weekday name, or 0 if unknown
+func scheduleDayBit(day string) int {
+	key := strings.ToLower(strings.TrimSpace(day))
+	if len(key) > 3 {
+		key = key[:3]
+	}
+	for _, candidate := range scheduleDayBits {
+		if candidate.day == key {
+			return candidate.bit
+		}
+	}
+	return 0
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"woodhome-webapp/internal/models"
+)
+
+func TestScheduleTimeRoundTrip(t *testing.T) {
+	tests := []struct {
+		localTime string
+		time      models.HueScheduleTime
+	}{
+		{"2024-05-01T07:00:00", models.HueScheduleTime{Kind: models.HueScheduleOnce, Date: "2024-05-01", Time: "07:00:00"}},
+		{"W124/T06:45:00", models.HueScheduleTime{Kind: models.HueScheduleRecurring, Time: "06:45:00", Weekdays: []string{"mon", "tue", "wed", "thu", "fri"}}},
+		{"W003/T21:00:00A00:15:00", models.HueScheduleTime{Kind: models.HueScheduleRecurring, Time: "21:00:00", Weekdays: []string{"sat", "sun"}, Random: "00:15:00"}},
+		{"PT00:10:00", models.HueScheduleTime{Kind: models.HueScheduleTimer, Duration: "00:10:00"}},
+		{"R05/PT00:01:30", models.HueScheduleTime{Kind: models.HueScheduleTimer, Duration: "00:01:30", Repeat: 5}},
+		{"R/PT01:00:00", models.HueScheduleTime{Kind: models.HueScheduleTimer, Duration: "01:00:00", Repeat: -1}},
+	}
+
+	for _, tt := range tests {
+		parsed, err := parseScheduleTime(tt.localTime)
+		if err != nil {
+			t.Fatalf("parseScheduleTime(%q) error: %v", tt.localTime, err)
+		}
+		if !reflect.DeepEqual(*parsed, tt.time) {
+			t.Fatalf("parseScheduleTime(%q) = %+v, want %+v", tt.localTime, *parsed, tt.time)
+		}
+
+		formatted, err := formatScheduleTime(&tt.time)
+		if err != nil {
+			t.Fatalf("formatScheduleTime(%+v) error: %v", tt.time, err)
+		}
+		if formatted != tt.localTime {
+			t.Fatalf("formatScheduleTime(%+v) = %q, want %q", tt.time, formatted, tt.localTime)
+		}
+	}
+
+	// Short clock times and full day names are accepted
+	formatted, err := formatScheduleTime(&models.HueScheduleTime{Kind: models.HueScheduleRecurring, Time: "7:30", Weekdays: []string{"Monday"}})
+	if err != nil || formatted != "W064/T07:30:00" {
+		t.Fatalf("Expected W064/T07:30:00, got %q (%v)", formatted, err)
+	}
+
+	if _, err := parseScheduleTime("2024-05-01T07:00:00/2024-05-01T08:00:00"); err == nil {
+		t.Fatal("Expected time intervals to be rejected")
+	}
+}
+
+func TestScheduleCommandAddress(t *testing.T) {
+	service := NewHueService(&models.HueServiceConfig{Username: "user123"})
+
+	payload, err := service.primary().schedulePayload(&models.HueScheduleRequest{
+		Command: &models.HueCommand{Address: "/groups/2/action", Method: "put", Body: map[string]bool{"on": true}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	command := payload["command"].(models.HueCommand)
+	if command.Address != "/api/user123/groups/2/action" || command.Method != "PUT" {
+		t.Fatalf("Expected the full API address, got %+v", command)
+	}
+
+	if address := relativeAddress("/api/user123/lights/1/state"); address != "/lights/1/state" {
+		t.Fatalf("Expected /lights/1/state, got %s", address)
+	}
+}
+
+func TestRulePayloadValidation(t *testing.T) {
+	porchLight := &models.HueRuleRequest{
+		Name: "Porch light at dusk",
+		Conditions: []models.HueRuleCondition{
+			{Address: "/sensors/1/state/daylight", Operator: models.HueRuleOpEquals, Value: "false"},
+			{Address: "/sensors/1/state/lastupdated", Operator: models.HueRuleOpChanged},
+		},
+		Actions: []models.HueCommand{
+			{Address: "/api/user123/groups/4/action", Method: "PUT", Body: map[string]bool{"on": true}},
+		},
+	}
+	payload, err := rulePayload(porchLight)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if actions := payload["actions"].([]models.HueCommand); actions[0].Address != "/groups/4/action" {
+		t.Fatalf("Expected rule actions to be relative, got %s", actions[0].Address)
+	}
+
+	invalid := []models.HueRuleCondition{
+		{Address: "/sensors/1/state/daylight", Operator: "equals", Value: "true"},
+		{Address: "/sensors/1/state/daylight", Operator: models.HueRuleOpEquals},
+		{Address: "/sensors/1/state/lastupdated", Operator: models.HueRuleOpChanged, Value: "true"},
+		{Address: "sensors/1/state/daylight", Operator: models.HueRuleOpEquals, Value: "true"},
+	}
+	for _, condition := range invalid {
+		if _, err := rulePayload(&models.HueRuleRequest{Conditions: []models.HueRuleCondition{condition}}); err == nil {
+			t.Fatalf("Expected condition %+v to be rejected", condition)
+		}
+	}
+}
+
+func TestDeleteScheduleAndRule(t *testing.T) {
+	var mu sync.Mutex
+	resources := map[string]map[string]map[string]interface{}{
+		"schedules": {"1": {"name": "Wake up", "status": "enabled"}},
+		"rules":     {"2": {"name": "Hallway motion", "status": "enabled"}},
+	}
+
+	bridge := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		resource, id, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		items, exists := resources[resource]
+		switch {
+		case exists && r.Method == "GET" && id == "":
+			json.NewEncoder(w).Encode(items)
+		case exists && r.Method == "DELETE" && items[id] != nil:
+			delete(items, id)
+			w.Write([]byte(`[{"success":"` + r.URL.Path + ` deleted"}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer bridge.Close()
+
+	service := NewHueService(nil)
+	service.primary().baseURL = bridge.URL
+
+	if err := service.DeleteSchedule("1"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if schedules, err := service.GetSchedules(); err != nil || len(schedules) != 0 {
+		t.Fatalf("Expected the schedule to be gone, got %+v (%v)", schedules, err)
+	}
+
+	if err := service.DeleteRule("2"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if rules, err := service.GetRules(); err != nil || len(rules) != 0 {
+		t.Fatalf("Expected the rule to be gone, got %+v (%v)", rules, err)
+	}
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"woodhome-webapp/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// tapButtons maps Hue tap switch event codes to button numbers
+var tapButtons = map[int]int{34: 1, 16: 2, 17: 3, 18: 4}
+
+// buttonActions maps the last digit of a dimmer switch event code to its action
+var buttonActions = map[int]string{
+	0: "initial_press",
+	1: "hold",
+	2: "short_release",
+	3: "long_release",
+}
+
+// SetEventBus sets the bus that sensor motion and button events are published on
+func (h *HueService) SetEventBus(eventBus *EventBus) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.eventBus = eventBus
+}
+
+// startSensorPolling polls the sensors of a bridge more often than the rest of the state
+func (h *HueService) startSensorPolling(ctx context.Context, b *hueBridge) {
+	ticker := time.NewTicker(h.config.SensorPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := h.refreshBridge(b, false, hueResourceSensors); err != nil {
+				logrus.Debugf("Failed to update sensors of bridge %s: %v", b.id, err)
+			}
+		}
+	}
+}
+
+// parseSensors decodes the bridge sensor list
+func parseSensors(body []byte) (map[string]*models.HueSensor, error) {
+	var sensorsData map[string]json.RawMessage
+	if err := json.Unmarshal(body, &sensorsData); err != nil {
+		return nil, fmt.Errorf("failed to decode sensors: %w", err)
+	}
+
+	sensors := make(map[string]*models.HueSensor, len(sensorsData))
+	for sensorID, sensorData := range sensorsData {
+		var sensor models.HueSensor
+		if err := json.Unmarshal(sensorData, &sensor); err != nil {
+			logrus.Warnf("Failed to unmarshal sensor %s: %v", sensorID, err)
+			continue
+		}
+
+		sensor.ID = sensorID
+		sensor.Kind = sensorKind(sensor.Type)
+		sensor.DeviceID = strings.SplitN(sensor.UniqueID, "-", 2)[0]
+		sensor.LastSeen = time.Now()
+		if sensor.State.Temperature != nil {
+			celsius := float64(*sensor.State.Temperature) / 100
+			sensor.Temperature = &celsius
+		}
+		if sensor.State.LightLevel != nil {
+			lux := math.Round(math.Pow(10, float64(*sensor.State.LightLevel-1)/10000)*10) / 10
+			sensor.Lux = &lux
+		}
+		sensors[sensorID] = &sensor
+	}
+
+	return sensors, nil
+}
+
+// publishSensorEvents compares a sensor with its previous state and publishes what changed.
+// Callers must hold the lock.
+func (h *HueService) publishSensorEvents(previous, current *models.HueSensor) {
+	if h.eventBus == nil {
+		return
+	}
+
+	switch current.Kind {
+	case models.HueSensorMotion:
+		if current.State.Presence == nil {
+			return
+		}
+		if previous.State.Presence != nil && *previous.State.Presence == *current.State.Presence {
+			return
+		}
+		h.eventBus.Publish(models.EventHueMotion, "hue", models.HueMotionEvent{
+			SensorID: current.ID,
+			Name:     current.Name,
+			DeviceID: current.DeviceID,
+			Presence: *current.State.Presence,
+		})
+
+	case models.HueSensorSwitch:
+		if current.State.ButtonEvent == nil || current.State.LastUpdated == previous.State.LastUpdated {
+			return
+		}
+		code := *current.State.ButtonEvent
+		button, action := decodeButtonEvent(current.Type, code)
+		h.eventBus.Publish(models.EventHueButton, "hue", models.HueButtonEvent{
+			SensorID: current.ID,
+			Name:     current.Name,
+			Button:   button,
+			Action:   action,
+			Code:     code,
+		})
+	}
+}
+
+// decodeButtonEvent splits a bridge button event code into a button number and action
+func decodeButtonEvent(sensorType string, code int) (int, string) {
+	if sensorType == "ZGPSwitch" {
+		// Tap switches only report presses
+		return tapButtons[code], "initial_press"
+	}
+	return code / 1000, buttonActions[code%1000]
+}
+
+// sensorKind maps a bridge sensor type to a sensor kind
+func sensorKind(sensorType string) string {
+	switch sensorType {
+	case "ZLLPresence", "CLIPPresence":
+		return models.HueSensorMotion
+	case "ZLLTemperature", "CLIPTemperature":
+		return models.HueSensorTemperature
+	case "ZLLLightLevel", "CLIPLightLevel":
+		return models.HueSensorLightLevel
+	case "ZLLSwitch", "ZGPSwitch", "CLIPSwitch":
+		return models.HueSensorSwitch
+	case "Daylight":
+		return models.HueSensorDaylight
+	}
+	return models.HueSensorOther
+}
+
+// GetSensors returns all sensors, optionally only those of one kind
+func (h *HueService) GetSensors(kind string) []*models.HueSensor {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	sensors := make([]*models.HueSensor, 0, len(h.sensors))
+	for _, sensor := range h.sensors {
+		if kind != "" && sensor.Kind != kind {
+			continue
+		}
+		sensors = append(sensors, sensor)
+	}
+	return sensors
+}
+
+// GetSensor returns a single sensor
+func (h *HueService) GetSensor(sensorID string) (*models.HueSensor, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	sensor, exists := h.sensors[sensorID]
+	return sensor, exists
+}
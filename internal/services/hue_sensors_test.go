@@ -0,0 +1,67 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"woodhome-webapp/internal/models"
+)
+
+func TestDecodeButtonEvent(t *testing.T) {
+	tests := []struct {
+		sensorType string
+		code       int
+		button     int
+		action     string
+	}{
+		{"ZLLSwitch", 1002, 1, "short_release"},
+		{"ZLLSwitch", 4001, 4, "hold"},
+		{"ZLLSwitch", 2003, 2, "long_release"},
+		{"ZGPSwitch", 34, 1, "initial_press"},
+		{"ZGPSwitch", 18, 4, "initial_press"},
+	}
+
+	for _, tt := range tests {
+		button, action := decodeButtonEvent(tt.sensorType, tt.code)
+		if button != tt.button || action != tt.action {
+			t.Fatalf("decodeButtonEvent(%s, %d) = %d %s, want %d %s",
+				tt.sensorType, tt.code, button, action, tt.button, tt.action)
+		}
+	}
+}
+
+func TestPublishSensorEvents(t *testing.T) {
+	bus := NewEventBus()
+	events, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+
+	service := NewHueService(nil)
+	service.SetEventBus(bus)
+
+	absent, present := false, true
+	previous := &models.HueSensor{ID: "5", Kind: models.HueSensorMotion, State: models.HueSensorState{Presence: &absent}}
+	current := &models.HueSensor{ID: "5", Kind: models.HueSensorMotion, State: models.HueSensorState{Presence: &present}}
+
+	// Unchanged presence publishes nothing
+	service.publishSensorEvents(previous, previous)
+	service.publishSensorEvents(previous, current)
+
+	select {
+	case event := <-events:
+		if event.Type != models.EventHueMotion {
+			t.Fatalf("Expected %s event, got %s", models.EventHueMotion, event.Type)
+		}
+		motion, ok := event.Data.(models.HueMotionEvent)
+		if !ok || !motion.Presence || motion.SensorID != "5" {
+			t.Fatalf("Unexpected motion event data: %+v", event.Data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a motion event")
+	}
+
+	select {
+	case event := <-events:
+		t.Fatalf("Expected a single event, also got %+v", event)
+	default:
+	}
+}
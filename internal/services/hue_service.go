@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -16,18 +17,19 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-// HueService handles Philips Hue operations
+// HueService handles Philips Hue operations.
+// Lights, groups, scenes and sensors of all bridges share flat maps keyed by service-wide ID.
 type HueService struct {
 	config     *models.HueServiceConfig
-	bridge     *models.HueBridge
+	bridges    []*hueBridge // The primary bridge comes first
 	lights     map[string]*models.HueLight
-	rooms      map[string]*models.HueRoom
 	groups     map[string]*models.HueGroup
 	scenes     map[string]*models.HueScene
+	sensors    map[string]*models.HueSensor
+	eventBus   *EventBus
 	httpClient *http.Client
+	ctx        context.Context // Of Start, for bridges connected later
 	mu         sync.RWMutex
-	lastUpdate time.Time
-	baseURL    string
 	authStatus *models.HueAuthStatus
 }
 
@@ -42,6 +44,8 @@ func NewHueService(config *models.HueServiceConfig) *HueService {
 			PollInterval: 20 * time.Second,
 			AutoDiscover: true,
 			AuthRequired: true,
+
+			SensorPollInterval: 2 * time.Second,
 		}
 	}
 
@@ -52,28 +56,49 @@ func NewHueService(config *models.HueServiceConfig) *HueService {
 		RequiresAuth:    config.Username == "",
 	}
 
-	return &HueService{
+	if config.BridgeID == "" {
+		config.BridgeID = "home"
+	}
+
+	h := &HueService{
 		config:     config,
 		lights:     make(map[string]*models.HueLight),
-		rooms:      make(map[string]*models.HueRoom),
 		groups:     make(map[string]*models.HueGroup),
 		scenes:     make(map[string]*models.HueScene),
+		sensors:    make(map[string]*models.HueSensor),
 		authStatus: authStatus,
 		httpClient: &http.Client{
 			Timeout: config.Timeout,
 		},
 	}
+
+	primary := models.HueBridgeConfig{ID: config.BridgeID, IP: config.BridgeIP, Username: config.Username}
+	h.bridges = append(h.bridges, newHueBridge(primary, true, h.httpClient))
+	for _, bridgeConfig := range config.Bridges {
+		if _, err := h.bridgeByID(bridgeConfig.ID); bridgeConfig.ID == "" || err == nil || strings.Contains(bridgeConfig.ID, hueIDSeparator) {
+			logrus.Warnf("Ignoring Hue bridge %s without a unique ID", bridgeConfig.IP)
+			continue
+		}
+		h.bridges = append(h.bridges, newHueBridge(bridgeConfig, false, h.httpClient))
+	}
+	return h
 }
 
-// Start initializes the Hue service
+// Start initializes the Hue service and starts polling every configured bridge
 func (h *HueService) Start(ctx context.Context) error {
 	logrus.Info("Starting Hue service...")
 
+	h.mu.Lock()
+	h.ctx = ctx
+	h.mu.Unlock()
+
 	// Load authentication from environment or config file
 	if err := h.loadAuthConfig(); err != nil {
 		logrus.Warnf("Failed to load Hue authentication: %v", err)
-		logrus.Info("Hue service will run in offline mode. Configure HUE_BRIDGE_IP and HUE_USERNAME environment variables.")
-		return nil
+		if len(h.bridges) == 1 {
+			logrus.Info("Hue service will run in offline mode. Configure HUE_BRIDGE_IP and HUE_USERNAME environment variables.")
+			return nil
+		}
 	}
 
 	// Auto-discover bridge if not configured
@@ -83,20 +108,31 @@ func (h *HueService) Start(ctx context.Context) error {
 		}
 	}
 
-	// Set up base URL if bridge is configured
-	if h.config.BridgeIP != "" && h.config.Username != "" {
-		h.baseURL = fmt.Sprintf("http://%s/api/%s", h.config.BridgeIP, h.config.Username)
+	// Set up base URLs of the bridges that are configured
+	primary := h.primary()
+	primary.ip = h.config.BridgeIP
+	primary.username = h.config.Username
+	if primary.connect() {
 		h.authStatus.IsAuthenticated = true
 		h.authStatus.RequiresAuth = false
-		logrus.Infof("Hue service configured with bridge %s", h.config.BridgeIP)
-	} else {
+	}
+
+	connected := 0
+	for _, b := range h.bridges {
+		if b.baseURL == "" && !b.connect() {
+			logrus.Warnf("Hue bridge %s has no IP or username, skipping it", b.id)
+			continue
+		}
+		logrus.Infof("Hue service configured with bridge %s at %s", b.id, b.ip)
+		connected++
+		h.startBridge(ctx, b)
+	}
+
+	if connected == 0 {
 		logrus.Info("Hue service not configured - running in offline mode")
 		return nil
 	}
 
-	// Start polling for updates
-	go h.startPolling(ctx)
-
 	logrus.Info("Hue service started successfully")
 	return nil
 }
@@ -128,17 +164,16 @@ func (h *HueService) discoverBridge() error {
 	// Use the first discovered bridge
 	bridge := bridges[0]
 	h.config.BridgeIP = bridge.InternalIPAddress
-	h.baseURL = fmt.Sprintf("http://%s/api/%s", bridge.InternalIPAddress, h.config.Username)
 
 	logrus.Infof("Discovered bridge at %s", bridge.InternalIPAddress)
 	return nil
 }
 
-// startPolling starts the polling loop for device updates
-func (h *HueService) startPolling(ctx context.Context) {
+// startPolling starts the polling loop for device updates of one bridge
+func (h *HueService) startPolling(ctx context.Context, b *hueBridge) {
 	// Load data immediately on startup
-	if err := h.updateDevices(); err != nil {
-		logrus.Errorf("Failed to update devices on startup: %v", err)
+	if err := h.updateDevices(b); err != nil {
+		logrus.Errorf("Failed to update devices of bridge %s on startup: %v", b.id, err)
 	}
 
 	ticker := time.NewTicker(h.config.PollInterval)
@@ -149,213 +184,22 @@ func (h *HueService) startPolling(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			if err := h.updateDevices(); err != nil {
-				logrus.Errorf("Failed to update devices: %v", err)
+			if err := h.updateDevices(b); err != nil {
+				logrus.Errorf("Failed to update devices of bridge %s: %v", b.id, err)
 			}
 		}
 	}
 }
 
-// updateDevices fetches the latest device states from the bridge
-func (h *HueService) updateDevices() error {
-	if h.baseURL == "" {
-		return fmt.Errorf("bridge not configured")
-	}
+// updateDevices refreshes every resource of a bridge that changed since the last poll
+func (h *HueService) updateDevices(b *hueBridge) error {
+	err := h.refreshBridge(b, false, hueResources...)
 
 	h.mu.Lock()
-	defer h.mu.Unlock()
-
-	// Update lights
-	if err := h.updateLights(); err != nil {
-		return fmt.Errorf("failed to update lights: %w", err)
-	}
-
-	// Update rooms/groups
-	if err := h.updateRooms(); err != nil {
-		return fmt.Errorf("failed to update rooms: %w", err)
-	}
-
-	// Update scenes
-	if err := h.updateScenes(); err != nil {
-		return fmt.Errorf("failed to update scenes: %w", err)
-	}
-
-	h.lastUpdate = time.Now()
-	return nil
-}
-
-// updateLights fetches all lights from the bridge
-func (h *HueService) updateLights() error {
-	logrus.Infof("Updating lights from bridge: %s", h.baseURL+"/lights")
-	resp, err := h.httpClient.Get(h.baseURL + "/lights")
-	if err != nil {
-		logrus.Errorf("Failed to fetch lights from bridge: %v", err)
-		return err
-	}
-	defer resp.Body.Close()
-
-	logrus.Infof("Hue bridge response status: %d", resp.StatusCode)
-
-	var lightsData map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&lightsData); err != nil {
-		logrus.Errorf("Failed to decode lights data: %v", err)
-		return err
-	}
-
-	logrus.Infof("Received %d lights from bridge", len(lightsData))
-	for lightID, lightData := range lightsData {
-		// Extract the state data and merge it with the main light data
-		lightMap, ok := lightData.(map[string]interface{})
-		if !ok {
-			logrus.Warnf("Failed to parse light %s data", lightID)
-			continue
-		}
-
-		// Extract state data if it exists
-		if stateData, exists := lightMap["state"]; exists {
-			if stateMap, ok := stateData.(map[string]interface{}); ok {
-				// Merge state data into the main light data
-				for key, value := range stateMap {
-					lightMap[key] = value
-				}
-			}
-		}
-
-		lightBytes, err := json.Marshal(lightMap)
-		if err != nil {
-			logrus.Warnf("Failed to marshal light %s: %v", lightID, err)
-			continue
-		}
-
-		var light models.HueLight
-		if err := json.Unmarshal(lightBytes, &light); err != nil {
-			logrus.Warnf("Failed to unmarshal light %s: %v", lightID, err)
-			continue
-		}
-
-		light.ID = lightID
-		light.LastSeen = time.Now()
-		h.lights[lightID] = &light
-		logrus.Debugf("Updated light %s: %s (on: %v)", lightID, light.Name, light.IsOn)
-	}
+	b.lastUpdate = time.Now()
+	h.mu.Unlock()
 
-	logrus.Debugf("Successfully updated %d lights", len(h.lights))
-	return nil
-}
-
-// updateRooms fetches all rooms/groups from the bridge
-func (h *HueService) updateRooms() error {
-	resp, err := h.httpClient.Get(h.baseURL + "/groups")
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	var groupsData map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&groupsData); err != nil {
-		return err
-	}
-
-	for groupID, groupData := range groupsData {
-		groupMap, ok := groupData.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		// Extract state data if it exists
-		var isOn bool
-		var brightness int
-		if stateData, exists := groupMap["state"]; exists {
-			if stateMap, ok := stateData.(map[string]interface{}); ok {
-				if anyOn, exists := stateMap["any_on"]; exists {
-					if anyOnBool, ok := anyOn.(bool); ok {
-						isOn = anyOnBool
-					}
-				}
-			}
-		}
-
-		// Extract action data for brightness
-		if actionData, exists := groupMap["action"]; exists {
-			if actionMap, ok := actionData.(map[string]interface{}); ok {
-				if bri, exists := actionMap["bri"]; exists {
-					if briFloat, ok := bri.(float64); ok {
-						brightness = int(briFloat)
-					}
-				}
-			}
-		}
-
-		groupBytes, err := json.Marshal(groupData)
-		if err != nil {
-			continue
-		}
-
-		var group models.HueGroup
-		if err := json.Unmarshal(groupBytes, &group); err != nil {
-			continue
-		}
-
-		group.ID = groupID
-		group.IsOn = isOn
-		group.Brightness = brightness
-		h.groups[groupID] = &group
-
-		// Also create room entry for room-type groups
-		if group.Type == "Room" {
-			room := &models.HueRoom{
-				ID:         group.ID,
-				Name:       group.Name,
-				Type:       group.Type,
-				Class:      group.Class,
-				Lights:     group.Lights,
-				IsOn:       isOn,
-				Brightness: brightness,
-				Hue:        group.Hue,
-				Saturation: group.Saturation,
-				ColorTemp:  group.ColorTemp,
-				ColorMode:  group.ColorMode,
-				XY:         group.XY,
-				Effect:     group.Effect,
-				Alert:      group.Alert,
-			}
-			h.rooms[groupID] = room
-			logrus.Debugf("Updated room %s: %s (on: %v, brightness: %d)", groupID, room.Name, room.IsOn, room.Brightness)
-		}
-	}
-
-	return nil
-}
-
-// updateScenes fetches all scenes from the bridge
-func (h *HueService) updateScenes() error {
-	resp, err := h.httpClient.Get(h.baseURL + "/scenes")
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	var scenesData map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&scenesData); err != nil {
-		return err
-	}
-
-	for sceneID, sceneData := range scenesData {
-		sceneBytes, err := json.Marshal(sceneData)
-		if err != nil {
-			continue
-		}
-
-		var scene models.HueScene
-		if err := json.Unmarshal(sceneBytes, &scene); err != nil {
-			continue
-		}
-
-		scene.ID = sceneID
-		h.scenes[sceneID] = &scene
-	}
-
-	return nil
+	return err
 }
 
 // GetLights returns all lights
@@ -373,15 +217,35 @@ func (h *HueService) GetLights() []*models.HueLight {
 
 // GetRooms returns all rooms
 func (h *HueService) GetRooms() []*models.HueRoom {
+	return h.getGroupsOfType(models.HueGroupTypeRoom)
+}
+
+// GetZones returns all zones
+func (h *HueService) GetZones() []*models.HueRoom {
+	return h.getGroupsOfType(models.HueGroupTypeZone)
+}
+
+// GetGroup returns a single group, room or zone
+func (h *HueService) GetGroup(groupID string) (*models.HueGroup, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	group, exists := h.groups[groupID]
+	return group, exists
+}
+
+// getGroupsOfType returns the groups of one bridge group type
+func (h *HueService) getGroupsOfType(groupType string) []*models.HueGroup {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	logrus.Infof("HueService: GetRooms() called, returning %d rooms", len(h.rooms))
-	rooms := make([]*models.HueRoom, 0, len(h.rooms))
-	for _, room := range h.rooms {
-		rooms = append(rooms, room)
+	groups := make([]*models.HueGroup, 0, len(h.groups))
+	for _, group := range h.groups {
+		if group.Type == groupType {
+			groups = append(groups, group)
+		}
 	}
-	return rooms
+	return groups
 }
 
 // GetScenes returns all scenes
@@ -396,195 +260,210 @@ func (h *HueService) GetScenes() []*models.HueScene {
 	return scenes
 }
 
-// SetLightState updates the state of a specific light
+// SetLightState queues a state change for a specific light.
+// The change is applied to the in-memory state right away; it blocks until the bridge has accepted it.
 func (h *HueService) SetLightState(lightID string, state *models.HueLightState) error {
-	if h.baseURL == "" {
-		return fmt.Errorf("bridge not configured")
-	}
-
-	stateBytes, err := json.Marshal(state)
+	b, localID, err := h.resolve(lightID)
 	if err != nil {
-		return fmt.Errorf("failed to marshal state: %w", err)
-	}
-
-	url := fmt.Sprintf("%s/lights/%s/state", h.baseURL, lightID)
-	req, err := http.NewRequest("PUT", url, strings.NewReader(string(stateBytes)))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := h.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
+		return err
 	}
-
-	var responses []models.HueAPIResponse
-	if err := json.Unmarshal(body, &responses); err != nil {
-		return fmt.Errorf("failed to unmarshal response: %w", err)
+	if b.baseURL == "" {
+		return fmt.Errorf("bridge not configured")
 	}
 
-	// Check for errors in the response
-	for _, response := range responses {
-		if response.Error != nil {
-			return fmt.Errorf("API error: %s", response.Error.Description)
-		}
-	}
+	h.mu.Lock()
+	h.applyLightState(lightID, state)
+	h.mu.Unlock()
+	b.markStale(hueResourceLights)
 
-	// Refresh the light state after successful update
-	if err := h.updateLights(); err != nil {
-		logrus.Warnf("Failed to refresh light state after update: %v", err)
+	if err := b.commands.SetLightState(localID, state); err != nil {
+		h.refreshAfterFailure(b)
+		return err
 	}
-
 	return nil
 }
 
 // RefreshLights refreshes the light state from the bridge
 func (h *HueService) RefreshLights() error {
-	return h.updateLights()
+	return h.refreshResources(true, hueResourceLights)
 }
 
 // RefreshRooms refreshes the room state from the bridge
 func (h *HueService) RefreshRooms() error {
-	return h.updateRooms()
+	return h.refreshResources(true, hueResourceGroups)
 }
 
-// SetGroupState updates the state of a specific group/room
-func (h *HueService) SetGroupState(groupID string, state *models.HueGroupState) error {
-	if h.baseURL == "" {
-		return fmt.Errorf("bridge not configured")
-	}
-
-	stateBytes, err := json.Marshal(state)
-	if err != nil {
-		return fmt.Errorf("failed to marshal state: %w", err)
-	}
-
-	url := fmt.Sprintf("%s/groups/%s/action", h.baseURL, groupID)
-	req, err := http.NewRequest("PUT", url, strings.NewReader(string(stateBytes)))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
+// LastUpdate returns when a bridge was last polled
+func (h *HueService) LastUpdate() time.Time {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 
-	resp, err := h.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+	var lastUpdate time.Time
+	for _, b := range h.bridges {
+		if b.lastUpdate.After(lastUpdate) {
+			lastUpdate = b.lastUpdate
+		}
 	}
-	defer resp.Body.Close()
+	return lastUpdate
+}
 
-	body, err := io.ReadAll(resp.Body)
+// SetGroupState queues an action for a specific group/room.
+// The change is applied to the in-memory state right away; it blocks until the bridge has accepted it.
+func (h *HueService) SetGroupState(groupID string, state *models.HueGroupState) error {
+	b, localID, err := h.resolve(groupID)
 	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
+		return err
 	}
-
-	var responses []models.HueAPIResponse
-	if err := json.Unmarshal(body, &responses); err != nil {
-		return fmt.Errorf("failed to unmarshal response: %w", err)
+	if b.baseURL == "" {
+		return fmt.Errorf("bridge not configured")
 	}
 
-	// Check for errors in the response
-	for _, response := range responses {
-		if response.Error != nil {
-			return fmt.Errorf("API error: %s", response.Error.Description)
-		}
-	}
+	h.mu.Lock()
+	h.applyGroupState(b, groupID, localID, state)
+	h.mu.Unlock()
+	b.markStale(hueResourceGroups, hueResourceLights)
 
-	// Refresh the group and light states after successful update
-	if err := h.updateRooms(); err != nil {
-		logrus.Warnf("Failed to refresh group state after update: %v", err)
-	}
-	if err := h.updateLights(); err != nil {
-		logrus.Warnf("Failed to refresh light state after update: %v", err)
+	if err := b.commands.SetGroupState(localID, state); err != nil {
+		h.refreshAfterFailure(b)
+		return err
 	}
-
 	return nil
 }
 
-// ActivateScene activates a specific scene
-func (h *HueService) ActivateScene(sceneID string) error {
-	if h.baseURL == "" {
+// SetAllLightsState sends a state to group 0, which holds all lights, of every bridge at once
+func (h *HueService) SetAllLightsState(state *models.HueGroupState) error {
+	bridges := h.connectedBridges()
+	if len(bridges) == 0 {
 		return fmt.Errorf("bridge not configured")
 	}
 
-	url := fmt.Sprintf("%s/scenes/%s", h.baseURL, sceneID)
-	req, err := http.NewRequest("PUT", url, strings.NewReader(`{"recall":{"action":"activate"}}`))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+	errs := make([]error, len(bridges))
+	var wg sync.WaitGroup
+	for i, b := range bridges {
+		wg.Add(1)
+		go func(i int, b *hueBridge) {
+			defer wg.Done()
+			if err := h.SetGroupState(b.namespaced("0"), state); err != nil {
+				errs[i] = fmt.Errorf("bridge %s: %w", b.id, err)
+			}
+		}(i, b)
 	}
+	wg.Wait()
 
-	req.Header.Set("Content-Type", "application/json")
+	return errors.Join(errs...)
+}
 
-	resp, err := h.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+// applyLightState optimistically applies a light state to the in-memory light.
+// Callers must hold the write lock.
+func (h *HueService) applyLightState(lightID string, state *models.HueLightState) {
+	existing, exists := h.lights[lightID]
+	if !exists {
+		return
+	}
+
+	// Replace rather than mutate, lights handed out by GetLights stay unchanged
+	light := *existing
+	if state.On != nil {
+		light.IsOn = *state.On
+	}
+	if state.Brightness != nil {
+		light.Brightness = *state.Brightness
+	}
+	switch {
+	case len(state.XY) == 2:
+		light.XY = state.XY
+		light.ColorMode = "xy"
+	case state.ColorTemp != nil:
+		light.ColorTemp = *state.ColorTemp
+		light.ColorMode = "ct"
+	case state.Hue != nil || state.Saturation != nil:
+		if state.Hue != nil {
+			light.Hue = *state.Hue
+		}
+		if state.Saturation != nil {
+			light.Saturation = *state.Saturation
+		}
+		light.ColorMode = "hs"
 	}
-	defer resp.Body.Close()
+	annotateLightColor(&light)
+	h.lights[lightID] = &light
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
+// applyGroupState optimistically applies a group action to the group and its lights.
+// Callers must hold the write lock.
+func (h *HueService) applyGroupState(b *hueBridge, groupID, localID string, state *models.HueGroupState) {
+	lightState := &models.HueLightState{
+		On:         state.On,
+		Brightness: state.Brightness,
+		Hue:        state.Hue,
+		Saturation: state.Saturation,
+		ColorTemp:  state.ColorTemp,
+		XY:         state.XY,
+	}
+
+	var lightIDs []string
+	switch {
+	case localID == "0":
+		// Group 0 is every light on the bridge
+		for lightID, light := range h.lights {
+			if light.BridgeID == b.id {
+				lightIDs = append(lightIDs, lightID)
+			}
+		}
+	case h.groups[groupID] != nil:
+		lightIDs = h.groups[groupID].Lights
 	}
-
-	var responses []models.HueAPIResponse
-	if err := json.Unmarshal(body, &responses); err != nil {
-		return fmt.Errorf("failed to unmarshal response: %w", err)
+	for _, lightID := range lightIDs {
+		h.applyLightState(lightID, lightState)
 	}
 
-	// Check for errors in the response
-	for _, response := range responses {
-		if response.Error != nil {
-			return fmt.Errorf("API error: %s", response.Error.Description)
+	if existing, exists := h.groups[groupID]; exists {
+		group := *existing
+		if state.On != nil {
+			group.IsOn = *state.On
+		}
+		if state.Brightness != nil {
+			group.Brightness = *state.Brightness
 		}
+		h.groups[groupID] = &group
 	}
-
-	return nil
 }
 
-// GetBridgeInfo returns information about the connected bridge
-func (h *HueService) GetBridgeInfo() (*models.HueBridge, error) {
-	if h.baseURL == "" {
-		return nil, fmt.Errorf("bridge not configured")
-	}
+// refreshAfterFailure reloads the bridge state in the background to undo optimistic changes
+func (h *HueService) refreshAfterFailure(b *hueBridge) {
+	go func() {
+		if err := h.refreshBridge(b, true, hueResourceLights, hueResourceGroups); err != nil {
+			logrus.Warnf("Failed to refresh Hue state of bridge %s after failed command: %v", b.id, err)
+		}
+	}()
+}
 
-	resp, err := h.httpClient.Get(h.baseURL + "/config")
+// ActivateScene activates a specific scene
+func (h *HueService) ActivateScene(sceneID string) error {
+	b, localID, err := h.resolve(sceneID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get bridge info: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
+	recall := map[string]interface{}{"recall": map[string]string{"action": "activate"}}
+	_, err = b.bridgeRequest("PUT", fmt.Sprintf("/scenes/%s", localID), recall)
+	return err
+}
 
-	var config map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&config); err != nil {
-		return nil, fmt.Errorf("failed to decode bridge config: %w", err)
+// GetBridgeInfo returns information about the primary bridge from the last poll
+func (h *HueService) GetBridgeInfo() (*models.HueBridge, error) {
+	primary := h.primary()
+	if primary.baseURL == "" {
+		return nil, fmt.Errorf("bridge not configured")
 	}
 
-	bridge := &models.HueBridge{
-		ID:       h.config.BridgeIP,
-		IP:       h.config.BridgeIP,
-		Username: h.config.Username,
-		IsOnline: true,
-		LastSeen: time.Now(),
-	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 
-	if name, ok := config["name"].(string); ok {
-		bridge.Name = name
-	}
-	if version, ok := config["swversion"].(string); ok {
-		bridge.SwVersion = version
-	}
-	if api, ok := config["apiversion"].(string); ok {
-		bridge.API = api
+	if primary.info == nil {
+		return nil, fmt.Errorf("bridge info not loaded yet")
 	}
-
-	return bridge, nil
+	bridge := *primary.info
+	return &bridge, nil
 }
 
 // GetAuthStatus returns the current authentication status
@@ -644,9 +523,18 @@ func (h *HueService) StartAuth() error {
 			h.authStatus.Username = authResp.Success.Username
 			h.authStatus.RequiresAuth = false
 			h.authStatus.LastAuth = time.Now()
-			h.baseURL = fmt.Sprintf("http://%s/api/%s", h.config.BridgeIP, authResp.Success.Username)
+			primary := h.primary()
+			primary.ip = h.config.BridgeIP
+			primary.username = authResp.Success.Username
+			primary.connect()
+			ctx := h.ctx
 			h.mu.Unlock()
 
+			// The bridge was not connected when the service started
+			if ctx != nil {
+				h.startBridge(ctx, primary)
+			}
+
 			logrus.Infof("Successfully authenticated with Hue bridge. Username: %s", authResp.Success.Username)
 			return nil
 		}
@@ -655,13 +543,26 @@ func (h *HueService) StartAuth() error {
 	return fmt.Errorf("unexpected authentication response")
 }
 
+// startBridge starts sending queued commands to a bridge and polling it for updates.
+// It only starts a bridge once, whether it was connected at startup or authenticated later.
+func (h *HueService) startBridge(ctx context.Context, b *hueBridge) {
+	b.started.Do(func() {
+		b.commands.Start(ctx)
+		go h.startPolling(ctx, b)
+		if h.config.SensorPollInterval > 0 {
+			go h.startSensorPolling(ctx, b)
+		}
+	})
+}
+
 // TestAuth tests if the current authentication is valid
 func (h *HueService) TestAuth() error {
-	if h.baseURL == "" {
+	baseURL := h.primary().baseURL
+	if baseURL == "" {
 		return fmt.Errorf("not authenticated")
 	}
 
-	resp, err := h.httpClient.Get(h.baseURL + "/config")
+	resp, err := h.httpClient.Get(baseURL + "/config")
 	if err != nil {
 		return fmt.Errorf("failed to test authentication: %w", err)
 	}
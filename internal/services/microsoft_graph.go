@@ -0,0 +1,460 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// MicrosoftGraphURL is the Microsoft Graph API the Microsoft 365 calendars are read from
+const MicrosoftGraphURL = "https://graph.microsoft.com/v1.0"
+
+// graphMaxResponse bounds a single Graph response
+const graphMaxResponse = 16 << 20
+
+// graphDateTimeLayout is how Graph writes the dateTime of a dateTimeTimeZone
+const graphDateTimeLayout = "2006-01-02T15:04:05.9999999"
+
+// windowsZones maps the Windows time zone names Outlook uses to IANA names, for the zones
+// a household is likely to meet. Graph also accepts and returns IANA names.
+var windowsZones = map[string]string{
+	"Dateline Standard Time":          "Etc/GMT+12",
+	"Hawaiian Standard Time":          "Pacific/Honolulu",
+	"Alaskan Standard Time":           "America/Anchorage",
+	"Pacific Standard Time":           "America/Los_Angeles",
+	"US Mountain Standard Time":       "America/Phoenix",
+	"Mountain Standard Time":          "America/Denver",
+	"Central Standard Time":           "America/Chicago",
+	"Eastern Standard Time":           "America/New_York",
+	"US Eastern Standard Time":        "America/Indiana/Indianapolis",
+	"Atlantic Standard Time":          "America/Halifax",
+	"Newfoundland Standard Time":      "America/St_Johns",
+	"SA Pacific Standard Time":        "America/Bogota",
+	"E. South America Standard Time":  "America/Sao_Paulo",
+	"Greenwich Standard Time":         "Atlantic/Reykjavik",
+	"GMT Standard Time":               "Europe/London",
+	"W. Europe Standard Time":         "Europe/Berlin",
+	"Romance Standard Time":           "Europe/Paris",
+	"Central Europe Standard Time":    "Europe/Budapest",
+	"Central European Standard Time":  "Europe/Warsaw",
+	"E. Europe Standard Time":         "Europe/Chisinau",
+	"FLE Standard Time":               "Europe/Kiev",
+	"GTB Standard Time":               "Europe/Bucharest",
+	"Russian Standard Time":           "Europe/Moscow",
+	"Israel Standard Time":            "Asia/Jerusalem",
+	"Arabian Standard Time":           "Asia/Dubai",
+	"India Standard Time":             "Asia/Kolkata",
+	"China Standard Time":             "Asia/Shanghai",
+	"Singapore Standard Time":         "Asia/Singapore",
+	"Tokyo Standard Time":             "Asia/Tokyo",
+	"Korea Standard Time":             "Asia/Seoul",
+	"AUS Eastern Standard Time":       "Australia/Sydney",
+	"E. Australia Standard Time":      "Australia/Brisbane",
+	"W. Australia Standard Time":      "Australia/Perth",
+	"New Zealand Standard Time":       "Pacific/Auckland",
+	"Coordinated Universal Time":      "UTC",
+	"UTC":                             "UTC",
+	"tzone://Microsoft/Utc":           "UTC",
+	"Mountain Standard Time (Mexico)": "America/Mazatlan",
+	"Central Standard Time (Mexico)":  "America/Mexico_City",
+}
+
+// graphColors are the hex values of Outlook's named calendar colors, for calendars without a
+// hexColor of their own
+var graphColors = map[string]string{
+	"lightBlue":   "#4f9bd9",
+	"lightGreen":  "#5fb760",
+	"lightOrange": "#f09a36",
+	"lightGray":   "#a0a0a0",
+	"lightYellow": "#e8c22e",
+	"lightTeal":   "#3fb6b0",
+	"lightPink":   "#e86ea3",
+	"lightBrown":  "#a9825a",
+	"lightRed":    "#e05050",
+}
+
+// MicrosoftGraphProvider is the CalendarProvider for a Microsoft 365 or Outlook.com account,
+// read through Microsoft Graph. Recurring events are expanded by Graph, so every occurrence is
+// a resource of its own, as with Google.
+type MicrosoftGraphProvider struct {
+	baseURL    string
+	location   *time.Location
+	httpClient *http.Client
+}
+
+// NewMicrosoftGraphProvider creates a provider signing its requests with tokens from
+// tokenSource. Graph sends times in the zone of loc when it has an IANA name, and in UTC
+// otherwise.
+func NewMicrosoftGraphProvider(baseURL string, tokenSource oauth2.TokenSource, loc *time.Location, httpClient *http.Client) *MicrosoftGraphProvider {
+	if baseURL == "" {
+		baseURL = MicrosoftGraphURL
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	client := *httpClient
+	client.Transport = &oauth2.Transport{Source: tokenSource, Base: httpClient.Transport}
+
+	return &MicrosoftGraphProvider{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		location:   loc,
+		httpClient: &client,
+	}
+}
+
+// Graph JSON, as far as this client reads it
+type graphCalendar struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Color    string `json:"color"`
+	HexColor string `json:"hexColor"`
+	CanEdit  bool   `json:"canEdit"`
+}
+
+type graphDateTime struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+type graphEvent struct {
+	ID      string `json:"id"`
+	ETag    string `json:"@odata.etag,omitempty"`
+	Subject string `json:"subject"`
+	Body    *struct {
+		ContentType string `json:"contentType"`
+		Content     string `json:"content"`
+	} `json:"body,omitempty"`
+	Location *struct {
+		DisplayName string `json:"displayName"`
+	} `json:"location,omitempty"`
+	Start       *graphDateTime `json:"start,omitempty"`
+	End         *graphDateTime `json:"end,omitempty"`
+	IsAllDay    bool           `json:"isAllDay"`
+	IsCancelled bool           `json:"isCancelled"`
+	Type        string         `json:"type"` // singleInstance, occurrence, exception or seriesMaster
+	Removed     *struct {
+		Reason string `json:"reason"`
+	} `json:"@removed,omitempty"`
+}
+
+type graphCalendarPage struct {
+	Value    []graphCalendar `json:"value"`
+	NextLink string          `json:"@odata.nextLink"`
+}
+
+type graphEventPage struct {
+	Value     []graphEvent `json:"value"`
+	NextLink  string       `json:"@odata.nextLink"`
+	DeltaLink string       `json:"@odata.deltaLink"`
+}
+
+// Me returns the name and address of the signed in user
+func (p *MicrosoftGraphProvider) Me(ctx context.Context) (name, email string, err error) {
+	var me struct {
+		DisplayName       string `json:"displayName"`
+		Mail              string `json:"mail"`
+		UserPrincipalName string `json:"userPrincipalName"`
+	}
+	if err := p.get(ctx, p.baseURL+"/me?$select=displayName,mail,userPrincipalName", &me); err != nil {
+		return "", "", err
+	}
+	email = me.Mail
+	if email == "" {
+		email = me.UserPrincipalName
+	}
+	return me.DisplayName, email, nil
+}
+
+// ListCalendars lists the user's calendars, including the ones shared with them
+func (p *MicrosoftGraphProvider) ListCalendars(ctx context.Context) ([]ProviderCalendar, error) {
+	var calendars []ProviderCalendar
+	next := p.baseURL + "/me/calendars?$select=id,name,color,hexColor,canEdit&$top=100"
+	for next != "" {
+		var page graphCalendarPage
+		if err := p.get(ctx, next, &page); err != nil {
+			return nil, err
+		}
+		for _, entry := range page.Value {
+			color := entry.HexColor
+			if color == "" {
+				color = graphColors[entry.Color]
+			}
+			calendars = append(calendars, ProviderCalendar{
+				ID:       entry.ID,
+				Name:     entry.Name,
+				Color:    color,
+				ReadOnly: !entry.CanEdit,
+			})
+		}
+		next = page.NextLink
+	}
+	return calendars, nil
+}
+
+// ListEvents lists the occurrences overlapping start to end through the calendar view
+func (p *MicrosoftGraphProvider) ListEvents(ctx context.Context, calendarID string, start, end time.Time) ([]ProviderEvent, error) {
+	var events []ProviderEvent
+	next := p.calendarURL(calendarID) + "/calendarView?" + p.window(start, end) + "&$top=100"
+	for next != "" {
+		var page graphEventPage
+		if err := p.get(ctx, next, &page); err != nil {
+			return nil, err
+		}
+		for _, item := range page.Value {
+			if event, ok := p.fromGraphEvent(item); ok && !item.IsCancelled {
+				events = append(events, event)
+			}
+		}
+		next = page.NextLink
+	}
+	return events, nil
+}
+
+// Changes uses a delta query on the calendar view. The sync token is the delta link Graph
+// returns, which keeps the window of the full listing it came from.
+func (p *MicrosoftGraphProvider) Changes(ctx context.Context, calendarID, syncToken string, start, end time.Time) (*ProviderChanges, error) {
+	changes := &ProviderChanges{Full: syncToken == ""}
+	next := syncToken
+	if next == "" {
+		next = p.calendarURL(calendarID) + "/calendarView/delta?" + p.window(start, end)
+	}
+
+	for {
+		var page graphEventPage
+		err := p.get(ctx, next, &page)
+		var statusErr *graphStatusError
+		if errors.As(err, &statusErr) && statusErr.code == http.StatusGone && syncToken != "" {
+			return nil, ErrSyncTokenExpired
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page.Value {
+			if item.Removed != nil || item.IsCancelled {
+				changes.Deleted = append(changes.Deleted, item.ID)
+				continue
+			}
+			if event, ok := p.fromGraphEvent(item); ok {
+				changes.Events = append(changes.Events, event)
+			}
+		}
+
+		if page.NextLink == "" {
+			changes.SyncToken = page.DeltaLink
+			return changes, nil
+		}
+		next = page.NextLink
+	}
+}
+
+// CreateEvent adds an event to a calendar
+func (p *MicrosoftGraphProvider) CreateEvent(ctx context.Context, calendarID string, event ProviderEvent) (*ProviderEvent, error) {
+	var created graphEvent
+	if err := p.send(ctx, http.MethodPost, p.calendarURL(calendarID)+"/events", "", p.toGraphEvent(event), &created); err != nil {
+		return nil, err
+	}
+	result, _ := p.fromGraphEvent(created)
+	return &result, nil
+}
+
+// UpdateEvent patches an event, or a single occurrence which Graph then keeps as an exception
+func (p *MicrosoftGraphProvider) UpdateEvent(ctx context.Context, calendarID string, event ProviderEvent) (*ProviderEvent, error) {
+	var updated graphEvent
+	if err := p.send(ctx, http.MethodPatch, p.baseURL+"/me/events/"+url.PathEscape(event.ID), event.ETag, p.toGraphEvent(event), &updated); err != nil {
+		return nil, err
+	}
+	result, _ := p.fromGraphEvent(updated)
+	return &result, nil
+}
+
+// DeleteEvent deletes an event or a single occurrence
+func (p *MicrosoftGraphProvider) DeleteEvent(ctx context.Context, calendarID string, event ProviderEvent) error {
+	return p.send(ctx, http.MethodDelete, p.baseURL+"/me/events/"+url.PathEscape(event.ID), event.ETag, nil, nil)
+}
+
+// calendarURL returns the URL of a calendar
+func (p *MicrosoftGraphProvider) calendarURL(calendarID string) string {
+	return p.baseURL + "/me/calendars/" + url.PathEscape(calendarID)
+}
+
+// window returns the query of a calendar view from start to end
+func (p *MicrosoftGraphProvider) window(start, end time.Time) string {
+	return url.Values{
+		"startDateTime": {start.UTC().Format("2006-01-02T15:04:05Z")},
+		"endDateTime":   {end.UTC().Format("2006-01-02T15:04:05Z")},
+	}.Encode()
+}
+
+// timeZone is the zone Graph is asked to send times in
+func (p *MicrosoftGraphProvider) timeZone() string {
+	if name := p.location.String(); name != "Local" && name != "" {
+		return name
+	}
+	return "UTC"
+}
+
+// fromGraphEvent converts a Graph event. All-day events start at midnight of their date.
+func (p *MicrosoftGraphProvider) fromGraphEvent(item graphEvent) (ProviderEvent, bool) {
+	if item.Start == nil {
+		return ProviderEvent{}, false
+	}
+	event := ProviderEvent{
+		ID:        item.ID,
+		Resource:  item.ID,
+		ETag:      item.ETag,
+		Summary:   item.Subject,
+		AllDay:    item.IsAllDay,
+		Recurring: item.Type == "occurrence" || item.Type == "exception" || item.Type == "seriesMaster",
+	}
+	if item.Body != nil {
+		event.Description = strings.TrimSpace(item.Body.Content)
+	}
+	if item.Location != nil {
+		event.Location = item.Location.DisplayName
+	}
+
+	start, err := p.parseDateTime(item.Start, item.IsAllDay)
+	if err != nil {
+		return ProviderEvent{}, false
+	}
+	event.Start, event.End = start, start
+	if item.End != nil {
+		if end, err := p.parseDateTime(item.End, item.IsAllDay); err == nil {
+			event.End = end
+		}
+	}
+	return event, true
+}
+
+// parseDateTime reads a dateTimeTimeZone, whose zone may be an IANA or a Windows name
+func (p *MicrosoftGraphProvider) parseDateTime(value *graphDateTime, allDay bool) (time.Time, error) {
+	wall, err := time.Parse(graphDateTimeLayout, value.DateTime)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if allDay {
+		return time.Date(wall.Year(), wall.Month(), wall.Day(), 0, 0, 0, 0, p.location), nil
+	}
+
+	loc := p.location
+	name := value.TimeZone
+	if iana, exists := windowsZones[name]; exists {
+		name = iana
+	}
+	if name != "" {
+		if zone, err := time.LoadLocation(name); err == nil {
+			loc = zone
+		}
+	}
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), loc), nil
+}
+
+// toGraphEvent converts an event for a POST or PATCH. Times are sent in UTC, which Outlook
+// shows in each reader's own zone, and all-day events as midnight of their dates.
+func (p *MicrosoftGraphProvider) toGraphEvent(event ProviderEvent) map[string]interface{} {
+	dateTime := func(t time.Time) graphDateTime {
+		if event.AllDay {
+			return graphDateTime{DateTime: t.Format("2006-01-02") + "T00:00:00", TimeZone: "UTC"}
+		}
+		return graphDateTime{DateTime: t.UTC().Format("2006-01-02T15:04:05"), TimeZone: "UTC"}
+	}
+	return map[string]interface{}{
+		"subject":  event.Summary,
+		"body":     map[string]string{"contentType": "text", "content": event.Description},
+		"location": map[string]string{"displayName": event.Location},
+		"isAllDay": event.AllDay,
+		"start":    dateTime(event.Start),
+		"end":      dateTime(event.End),
+	}
+}
+
+// graphStatusError is an error answer from Graph
+type graphStatusError struct {
+	method  string
+	target  string
+	code    int
+	message string
+}
+
+func (e *graphStatusError) Error() string {
+	return fmt.Sprintf("%s %s returned %d: %s", e.method, e.target, e.code, e.message)
+}
+
+// get reads a Graph URL into out
+func (p *MicrosoftGraphProvider) get(ctx context.Context, target string, out interface{}) error {
+	return p.send(ctx, http.MethodGet, target, "", nil, out)
+}
+
+// send runs a Graph request. Links from Graph are only followed on the Graph host, as every
+// request carries the account's token. A failed If-Match is returned as ErrEventConflict.
+func (p *MicrosoftGraphProvider) send(ctx context.Context, method, target, etag string, body, out interface{}) error {
+	if !strings.HasPrefix(target, p.baseURL+"/") {
+		return fmt.Errorf("refusing to follow %s outside %s", target, p.baseURL)
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+	req.Header.Add("Prefer", fmt.Sprintf(`outlook.timezone="%s"`, p.timeZone()))
+	req.Header.Add("Prefer", `outlook.body-content-type="text"`)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return fmt.Errorf("%w: %s %s", ErrEventConflict, method, target)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var failure struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, graphMaxResponse)).Decode(&failure)
+		message := failure.Error.Message
+		if message == "" {
+			message = resp.Status
+		}
+		return &graphStatusError{method: method, target: target, code: resp.StatusCode, message: message}
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, graphMaxResponse)).Decode(out); err != nil {
+		return fmt.Errorf("failed to parse %s response: %w", method, err)
+	}
+	return nil
+}
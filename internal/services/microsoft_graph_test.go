@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"woodhome-webapp/internal/models"
+
+	"golang.org/x/oauth2"
+)
+
+// fakeGraphAPI serves the parts of Microsoft Graph the calendar provider uses: a profile, two
+// calendars over two pages, a calendar view delta and event writes checked against ETags
+type fakeGraphAPI struct {
+	mu          sync.Mutex
+	url         string
+	events      map[string]map[string]interface{}
+	changes     []map[string]interface{}
+	expireToken bool
+	refreshes   int
+	prefer      []string
+}
+
+func newFakeGraphAPI(t *testing.T) (*fakeGraphAPI, *httptest.Server) {
+	fake := &fakeGraphAPI{events: map[string]map[string]interface{}{
+		"standup-1": {"id": "standup-1", "@odata.etag": `W/"1"`, "subject": "Standup", "type": "occurrence",
+			"start": map[string]string{"dateTime": "2024-06-17T09:00:00.0000000", "timeZone": "Pacific Standard Time"},
+			"end":   map[string]string{"dateTime": "2024-06-17T09:15:00.0000000", "timeZone": "Pacific Standard Time"}},
+		"standup-2": {"id": "standup-2", "@odata.etag": `W/"1"`, "subject": "Standup", "type": "occurrence",
+			"start": map[string]string{"dateTime": "2024-06-18T09:00:00.0000000", "timeZone": "Pacific Standard Time"},
+			"end":   map[string]string{"dateTime": "2024-06-18T09:15:00.0000000", "timeZone": "Pacific Standard Time"}},
+		"offsite": {"id": "offsite", "@odata.etag": `W/"1"`, "subject": "Offsite", "type": "singleInstance", "isAllDay": true,
+			"body":  map[string]string{"contentType": "text", "content": "Bring a laptop\r\n"},
+			"start": map[string]string{"dateTime": "2024-06-19T00:00:00.0000000", "timeZone": "America/Chicago"},
+			"end":   map[string]string{"dateTime": "2024-06-21T00:00:00.0000000", "timeZone": "America/Chicago"}},
+		"london": {"id": "london", "@odata.etag": `W/"1"`, "subject": "Call with London", "type": "singleInstance",
+			"location": map[string]string{"displayName": "Teams"},
+			"start":    map[string]string{"dateTime": "2024-06-20T15:30:00.0000000", "timeZone": "Europe/London"},
+			"end":      map[string]string{"dateTime": "2024-06-20T16:00:00.0000000", "timeZone": "Europe/London"}},
+	}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	fake.url = server.URL
+	return fake, server
+}
+
+func (f *fakeGraphAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.URL.Path == "/token" {
+		f.refreshes++
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "graph-token", "token_type": "Bearer", "expires_in": 3600})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer graph-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"code":"InvalidAuthenticationToken","message":"Access token is empty."}}`))
+		return
+	}
+	f.prefer = r.Header.Values("Prefer")
+
+	switch path := r.URL.Path; {
+	case path == "/v1.0/me":
+		json.NewEncoder(w).Encode(map[string]string{"displayName": "Alex Wood", "userPrincipalName": "alex@contoso.com"})
+	case path == "/v1.0/me/calendars" && r.URL.Query().Get("page") == "":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"value":           []map[string]interface{}{{"id": "work", "name": "Calendar", "color": "auto", "hexColor": "#1f5fbf", "canEdit": true}},
+			"@odata.nextLink": f.url + "/v1.0/me/calendars?page=2",
+		})
+	case path == "/v1.0/me/calendars":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"value": []map[string]interface{}{{"id": "holidays", "name": "United States holidays", "color": "lightGreen", "hexColor": "", "canEdit": false}},
+		})
+	case path == "/v1.0/me/calendars/work/calendarView/delta":
+		token := r.URL.Query().Get("$deltatoken")
+		var items []map[string]interface{}
+		switch {
+		case token == "" && (r.URL.Query().Get("startDateTime") == "" || r.URL.Query().Get("endDateTime") == ""):
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		case token == "":
+			for _, event := range f.events {
+				items = append(items, event)
+			}
+		case f.expireToken:
+			w.WriteHeader(http.StatusGone)
+			w.Write([]byte(`{"error":{"code":"SyncStateNotFound","message":"The sync state generation is not found."}}`))
+			return
+		default:
+			items, f.changes = f.changes, nil
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"value":            items,
+			"@odata.deltaLink": f.url + "/v1.0/me/calendars/work/calendarView/delta?$deltatoken=next",
+		})
+	case path == "/v1.0/me/calendars/holidays/calendarView/delta":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"value":            []interface{}{},
+			"@odata.deltaLink": f.url + "/v1.0/me/calendars/holidays/calendarView/delta?$deltatoken=next",
+		})
+	case path == "/v1.0/me/calendars/work/events" && r.Method == http.MethodPost:
+		var event map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&event)
+		event["id"], event["@odata.etag"], event["type"] = "created", `W/"1"`, "singleInstance"
+		f.events["created"] = event
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(event)
+	case strings.HasPrefix(path, "/v1.0/me/events/"):
+		id := strings.TrimPrefix(path, "/v1.0/me/events/")
+		event, exists := f.events[id]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if match := r.Header.Get("If-Match"); match != "" && match != event["@odata.etag"] {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(f.events, id)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		var patch map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&patch)
+		for key, value := range patch {
+			event[key] = value
+		}
+		event["@odata.etag"] = `W/"2"`
+		json.NewEncoder(w).Encode(event)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestMicrosoftGraphProvider(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Skipf("Time zone data not available: %v", err)
+	}
+	fake, server := newFakeGraphAPI(t)
+	provider := NewMicrosoftGraphProvider(server.URL+"/v1.0", oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "graph-token"}), chicago, server.Client())
+	ctx := context.Background()
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)
+
+	name, email, err := provider.Me(ctx)
+	if err != nil || name != "Alex Wood" || email != "alex@contoso.com" {
+		t.Fatalf("Unexpected profile %q %q: %v", name, email, err)
+	}
+
+	calendars, err := provider.ListCalendars(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(calendars) != 2 || calendars[0].Color != "#1f5fbf" || calendars[0].ReadOnly || calendars[1].Color != "#5fb760" || !calendars[1].ReadOnly {
+		t.Fatalf("Unexpected calendars: %+v", calendars)
+	}
+
+	changes, err := provider.Changes(ctx, "work", "", start, end)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !changes.Full || len(changes.Events) != 4 || !strings.HasSuffix(changes.SyncToken, "$deltatoken=next") {
+		t.Fatalf("Unexpected full sync: %+v", changes)
+	}
+	if prefer := strings.Join(fake.prefer, ","); !strings.Contains(prefer, `outlook.timezone="America/Chicago"`) {
+		t.Fatalf("Expected times to be asked for in the home zone, got %q", prefer)
+	}
+	events := make(map[string]ProviderEvent)
+	for _, event := range changes.Events {
+		events[event.ID] = event
+	}
+
+	// Windows and IANA zone names are both understood
+	if standup := events["standup-1"]; !standup.Recurring || !standup.Start.Equal(time.Date(2024, 6, 17, 16, 0, 0, 0, time.UTC)) || standup.End.Sub(standup.Start) != 15*time.Minute {
+		t.Fatalf("Unexpected occurrence: %+v", standup)
+	}
+	if london := events["london"]; london.Recurring || london.Location != "Teams" || !london.Start.Equal(time.Date(2024, 6, 20, 14, 30, 0, 0, time.UTC)) {
+		t.Fatalf("Unexpected event: %+v", london)
+	}
+	if offsite := events["offsite"]; !offsite.AllDay || offsite.Description != "Bring a laptop" || !offsite.Start.Equal(time.Date(2024, 6, 19, 0, 0, 0, 0, chicago)) || offsite.End.Sub(offsite.Start) != 48*time.Hour {
+		t.Fatalf("Unexpected all-day event: %+v", offsite)
+	}
+
+	// A cancelled occurrence and a removed event both come through the delta link
+	fake.mu.Lock()
+	fake.changes = []map[string]interface{}{
+		{"id": "standup-2", "isCancelled": true, "type": "occurrence"},
+		{"id": "london", "@removed": map[string]string{"reason": "deleted"}},
+	}
+	fake.mu.Unlock()
+	changes, err = provider.Changes(ctx, "work", changes.SyncToken, start, end)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if changes.Full || len(changes.Events) != 0 || len(changes.Deleted) != 2 {
+		t.Fatalf("Unexpected changes: %+v", changes)
+	}
+	fake.mu.Lock()
+	fake.expireToken = true
+	fake.mu.Unlock()
+	if _, err := provider.Changes(ctx, "work", changes.SyncToken, start, end); !errors.Is(err, ErrSyncTokenExpired) {
+		t.Fatalf("Expected the token to expire, got %v", err)
+	}
+
+	// Writes carry the ETag, so a stale copy is refused
+	standup := events["standup-1"]
+	standup.Summary = "Standup (moved)"
+	updated, err := provider.UpdateEvent(ctx, "work", standup)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if updated.Summary != "Standup (moved)" || updated.ETag != `W/"2"` || !updated.Start.Equal(standup.Start) {
+		t.Fatalf("Unexpected updated event: %+v", updated)
+	}
+	if _, err := provider.UpdateEvent(ctx, "work", standup); !errors.Is(err, ErrEventConflict) {
+		t.Fatalf("Expected a conflict, got %v", err)
+	}
+	if err := provider.DeleteEvent(ctx, "work", *updated); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	created, err := provider.CreateEvent(ctx, "work", ProviderEvent{
+		Summary: "School play",
+		Start:   time.Date(2024, 6, 25, 18, 0, 0, 0, chicago),
+		End:     time.Date(2024, 6, 25, 19, 0, 0, 0, chicago),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if created.ID != "created" || !created.Start.Equal(time.Date(2024, 6, 25, 23, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Unexpected created event: %+v", created)
+	}
+
+	// Links are only followed on the Graph host
+	if _, err := provider.Changes(ctx, "work", "https://example.com/delta", start, end); err == nil {
+		t.Fatal("Expected a foreign delta link to be refused")
+	}
+}
+
+func TestMicrosoftAccountLink(t *testing.T) {
+	fake, server := newFakeGraphAPI(t)
+	service := NewCalendarAccountService(nil, &models.CalendarAccountConfig{Location: time.UTC, GraphURL: server.URL + "/v1.0"})
+	service.httpClient = server.Client()
+	service.now = func() time.Time { return time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC) }
+	service.microsoftOAuth = &oauth2.Config{ClientID: "woodhome", Endpoint: oauth2.Endpoint{TokenURL: server.URL + "/token"}}
+	tokens := make(map[string]*oauth2.Token)
+	service.loadToken = func(provider, accountID string) (*oauth2.Token, error) {
+		if token, exists := tokens[provider+"/"+accountID]; exists {
+			return token, nil
+		}
+		return nil, errors.New("no token")
+	}
+	service.saveToken = func(provider, accountID string, token *oauth2.Token) error {
+		tokens[provider+"/"+accountID] = token
+		return nil
+	}
+	service.deleteToken = func(provider, accountID string) error {
+		delete(tokens, provider+"/"+accountID)
+		return nil
+	}
+	ctx := context.Background()
+
+	if _, err := service.CreateAccount(models.CalendarAccount{Provider: "microsoft", Username: "alex@contoso.com"}); err == nil {
+		t.Fatal("Expected Microsoft accounts to need a sign in")
+	}
+	account, err := service.LinkMicrosoftAccount(ctx, &oauth2.Token{AccessToken: "graph-token", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if account.Provider != models.CalendarProviderMicrosoft || account.Name != "Alex Wood" || account.Username != "alex@contoso.com" || !account.Enabled {
+		t.Fatalf("Unexpected account: %+v", account)
+	}
+
+	// Signing in again keeps the account and replaces its token, here with one that has expired
+	relinked, err := service.LinkMicrosoftAccount(ctx, &oauth2.Token{AccessToken: "graph-token", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Hour)})
+	if err != nil || relinked.ID != account.ID || len(service.GetAccounts()) != 1 {
+		t.Fatalf("Expected the same account, got %+v and %v", relinked, err)
+	}
+	if err := service.SyncAccount(ctx, account.ID); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if stored := tokens[models.CalendarProviderMicrosoft+"/"+account.ID]; fake.refreshes != 1 || stored.Expiry.Before(time.Now()) {
+		t.Fatalf("Expected the refreshed token to be saved, got %d refreshes and %+v", fake.refreshes, stored)
+	}
+
+	calendars := service.GetCalendars()
+	if len(calendars) != 2 || calendars[0].Color != "#1f5fbf" {
+		t.Fatalf("Unexpected calendars: %+v", calendars)
+	}
+	events := service.GetEvents(time.Date(2024, 6, 17, 0, 0, 0, 0, time.UTC), time.Date(2024, 6, 24, 0, 0, 0, 0, time.UTC), []string{calendars[0].ID})
+	if len(events) != 4 || events[0].Title != "[Calendar] Standup" || events[0].Start != "2024-06-17T16:00:00Z" || events[0].CalendarColor != "#1f5fbf" {
+		t.Fatalf("Unexpected events: %+v", events)
+	}
+
+	// Only the name and whether it syncs can be changed
+	updated, err := service.UpdateAccount(account.ID, models.CalendarAccount{Name: "Alex (work)", URL: "https://example.com", Username: "someone@example.com", Enabled: true})
+	if err != nil || updated.Name != "Alex (work)" || updated.Username != "alex@contoso.com" || updated.URL != "" || len(updated.Calendars) != 2 {
+		t.Fatalf("Unexpected update %+v: %v", updated, err)
+	}
+
+	if err := service.DeleteAccount(account.ID); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(tokens) != 0 {
+		t.Fatal("Expected the token to be deleted with the account")
+	}
+}
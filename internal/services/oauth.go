@@ -8,6 +8,7 @@ import (
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
+	"golang.org/x/oauth2/microsoft"
 	"google.golang.org/api/calendar/v3"
 )
 
@@ -58,6 +59,41 @@ func NewGoogleOAuthConfigWithRequest(r *http.Request) *oauth2.Config {
 	}
 }
 
+// microsoftCalendarScopes lets WoodHome read and change the signed in user's calendars, and
+// keep doing so with a refresh token
+var microsoftCalendarScopes = []string{"offline_access", "User.Read", "Calendars.ReadWrite"}
+
+// NewMicrosoftOAuthConfig creates a new OAuth2 configuration for Microsoft 365 calendars.
+// MICROSOFT_TENANT limits sign in to one organization and defaults to any account.
+func NewMicrosoftOAuthConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     os.Getenv("MICROSOFT_CLIENT_ID"),
+		ClientSecret: os.Getenv("MICROSOFT_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("MICROSOFT_REDIRECT_URL"),
+		Scopes:       microsoftCalendarScopes,
+		Endpoint:     microsoft.AzureADEndpoint(os.Getenv("MICROSOFT_TENANT")),
+	}
+}
+
+// NewMicrosoftOAuthConfigWithRequest creates a Microsoft OAuth2 configuration with a redirect
+// URL on the host the request came in on
+func NewMicrosoftOAuthConfigWithRequest(r *http.Request) *oauth2.Config {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	host := r.Host
+	if host == "" {
+		host = "localhost:3000"
+		scheme = "http"
+	}
+
+	config := NewMicrosoftOAuthConfig()
+	config.RedirectURL = scheme + "://" + host + "/auth/microsoft/callback"
+	return config
+}
+
 // GenerateStateToken creates a cryptographically secure random state token
 func GenerateStateToken() (string, error) {
 	b := make([]byte, 32)
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"database/sql"
+	"time"
+
+	"golang.org/x/oauth2"
+	_ "modernc.org/sqlite"
+)
+
+// oauthTokenDBPath is the SQLite file holding the Google OAuth tokens
+const oauthTokenDBPath = "./oauth_tokens.db"
+
+// HouseholdUserID is the user of the primary Google account, the one background jobs use
+const HouseholdUserID = 1
+
+// SaveOAuthToken stores a user's OAuth token in SQLite
+func SaveOAuthToken(userID int, token *oauth2.Token) error {
+	// Open SQLite database
+	db, err := sql.Open("sqlite", oauthTokenDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// Create table if not exists
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS oauth_tokens (
+			user_id INTEGER PRIMARY KEY,
+			access_token TEXT NOT NULL,
+			refresh_token TEXT,
+			token_type TEXT DEFAULT 'Bearer',
+			expiry DATETIME NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Insert or update token
+	_, err = db.Exec(`
+		INSERT OR REPLACE INTO oauth_tokens 
+		(user_id, access_token, refresh_token, token_type, expiry, updated_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, userID, token.AccessToken, token.RefreshToken, token.TokenType, token.Expiry.Format(time.RFC3339))
+
+	return err
+}
+
+// LoadOAuthToken reads a user's OAuth token from SQLite
+func LoadOAuthToken(userID int) (*oauth2.Token, error) {
+	// Open SQLite database
+	db, err := sql.Open("sqlite", oauthTokenDBPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var accessToken, refreshToken, tokenType, expiryStr string
+	err = db.QueryRow(`
+		SELECT access_token, refresh_token, token_type, expiry 
+		FROM oauth_tokens WHERE user_id = ?
+	`, userID).Scan(&accessToken, &refreshToken, &tokenType, &expiryStr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse expiry
+	expiry, err := time.Parse(time.RFC3339, expiryStr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oauth2.Token{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    tokenType,
+		Expiry:       expiry,
+	}, nil
+}
+
+// DeleteOAuthToken removes a user's OAuth token, when a Google account is unlinked
+func DeleteOAuthToken(userID int) error {
+	db, err := sql.Open("sqlite", oauthTokenDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`DELETE FROM oauth_tokens WHERE user_id = ?`, userID)
+	return err
+}
+
+// SaveProviderOAuthToken stores the OAuth token of a linked calendar account, such as a
+// Microsoft 365 account, next to the Google tokens
+func SaveProviderOAuthToken(provider, accountID string, token *oauth2.Token) error {
+	db, err := sql.Open("sqlite", oauthTokenDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := createProviderOAuthTokenTable(db); err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		INSERT OR REPLACE INTO provider_oauth_tokens
+		(provider, account_id, access_token, refresh_token, token_type, expiry, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, provider, accountID, token.AccessToken, token.RefreshToken, token.TokenType, token.Expiry.Format(time.RFC3339))
+
+	return err
+}
+
+// LoadProviderOAuthToken reads the OAuth token of a linked calendar account
+func LoadProviderOAuthToken(provider, accountID string) (*oauth2.Token, error) {
+	db, err := sql.Open("sqlite", oauthTokenDBPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	if err := createProviderOAuthTokenTable(db); err != nil {
+		return nil, err
+	}
+
+	var accessToken, refreshToken, tokenType, expiryStr string
+	err = db.QueryRow(`
+		SELECT access_token, refresh_token, token_type, expiry
+		FROM provider_oauth_tokens WHERE provider = ? AND account_id = ?
+	`, provider, accountID).Scan(&accessToken, &refreshToken, &tokenType, &expiryStr)
+	if err != nil {
+		return nil, err
+	}
+
+	expiry, err := time.Parse(time.RFC3339, expiryStr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oauth2.Token{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    tokenType,
+		Expiry:       expiry,
+	}, nil
+}
+
+// DeleteProviderOAuthToken removes the OAuth token of an unlinked calendar account
+func DeleteProviderOAuthToken(provider, accountID string) error {
+	db, err := sql.Open("sqlite", oauthTokenDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := createProviderOAuthTokenTable(db); err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`DELETE FROM provider_oauth_tokens WHERE provider = ? AND account_id = ?`, provider, accountID)
+	return err
+}
+
+// createProviderOAuthTokenTable creates the table of linked account tokens
+func createProviderOAuthTokenTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS provider_oauth_tokens (
+			provider TEXT NOT NULL,
+			account_id TEXT NOT NULL,
+			access_token TEXT NOT NULL,
+			refresh_token TEXT,
+			token_type TEXT DEFAULT 'Bearer',
+			expiry DATETIME NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (provider, account_id)
+		)
+	`)
+	return err
+}
@@ -0,0 +1,497 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"woodhome-webapp/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// RoomService keeps the household room registry, which maps Hue groups,
+// Sonos players and calendars onto the rooms of the house
+type RoomService struct {
+	hueService   *HueService
+	sonosService *SonosService
+	db           *sql.DB
+	rooms        map[string]*models.Room
+	now          func() time.Time
+	mu           sync.RWMutex
+}
+
+// NewRoomService creates a new RoomService instance.
+// Rooms are kept in memory only when db is nil.
+func NewRoomService(hueService *HueService, sonosService *SonosService, db *sql.DB) *RoomService {
+	return &RoomService{
+		hueService:   hueService,
+		sonosService: sonosService,
+		db:           db,
+		rooms:        make(map[string]*models.Room),
+		now:          time.Now,
+	}
+}
+
+// Start loads the saved rooms
+func (s *RoomService) Start(ctx context.Context) error {
+	logrus.Info("Starting room service...")
+
+	if err := s.load(); err != nil {
+		return fmt.Errorf("failed to load rooms: %w", err)
+	}
+	return nil
+}
+
+// GetRooms returns every room sorted by name
+func (s *RoomService) GetRooms() []*models.Room {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rooms := make([]*models.Room, 0, len(s.rooms))
+	for _, room := range s.rooms {
+		copied := *room
+		rooms = append(rooms, &copied)
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		return rooms[i].Name < rooms[j].Name
+	})
+	return rooms
+}
+
+// GetRoom returns a room by ID or, failing that, by name
+func (s *RoomService) GetRoom(idOrName string) (*models.Room, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	room := s.findRoom(idOrName)
+	if room == nil {
+		return nil, false
+	}
+	copied := *room
+	return &copied, true
+}
+
+// findRoom looks a room up by ID or name. Callers must hold the lock.
+func (s *RoomService) findRoom(idOrName string) *models.Room {
+	if room, exists := s.rooms[idOrName]; exists {
+		return room
+	}
+	key := roomKey(idOrName)
+	for _, room := range s.rooms {
+		if roomKey(room.Name) == key {
+			return room
+		}
+	}
+	return nil
+}
+
+// CreateRoom adds a room. Its ID is derived from the name.
+func (s *RoomService) CreateRoom(room models.Room) (*models.Room, error) {
+	if err := s.validate(&room); err != nil {
+		return nil, err
+	}
+	room.ID = roomSlug(room.Name)
+	if room.ID == "" {
+		return nil, fmt.Errorf("room name needs at least one letter or digit")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing := s.findRoom(room.ID); existing != nil {
+		return nil, fmt.Errorf("room %s already exists", existing.Name)
+	}
+	if room.Permissions.Restricted && room.Permissions.AccessKey == "" {
+		room.Permissions.AccessKey = uuid.NewString()
+	}
+	room.UpdatedAt = s.now()
+
+	if err := s.save(room); err != nil {
+		return nil, err
+	}
+	s.rooms[room.ID] = &room
+
+	logrus.Infof("Rooms: created room %s", room.Name)
+	copied := room
+	return &copied, nil
+}
+
+// UpdateRoom replaces the devices, calendars and permissions of a room.
+// The ID stays the same when the room is renamed.
+func (s *RoomService) UpdateRoom(id string, room models.Room) (*models.Room, error) {
+	if err := s.validate(&room); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, exists := s.rooms[id]
+	if !exists {
+		return nil, fmt.Errorf("room %s not found", id)
+	}
+	if other := s.findRoom(room.Name); other != nil && other.ID != id {
+		return nil, fmt.Errorf("room %s already exists", other.Name)
+	}
+
+	room.ID = id
+	if room.Permissions.Restricted && room.Permissions.AccessKey == "" {
+		room.Permissions.AccessKey = existing.Permissions.AccessKey
+		if room.Permissions.AccessKey == "" {
+			room.Permissions.AccessKey = uuid.NewString()
+		}
+	}
+	room.UpdatedAt = s.now()
+
+	if err := s.save(room); err != nil {
+		return nil, err
+	}
+	s.rooms[id] = &room
+
+	copied := room
+	return &copied, nil
+}
+
+// DeleteRoom removes a room; its devices are not touched
+func (s *RoomService) DeleteRoom(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.rooms[id]; !exists {
+		return fmt.Errorf("room %s not found", id)
+	}
+	if s.db != nil {
+		if _, err := s.db.Exec(`DELETE FROM rooms WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete room: %w", err)
+		}
+	}
+	delete(s.rooms, id)
+	return nil
+}
+
+// CanControl reports whether a caller may control a room
+func (s *RoomService) CanControl(room *models.Room, signedIn bool, accessKey string) bool {
+	if !room.Permissions.Restricted || signedIn {
+		return true
+	}
+	return accessKey != "" &&
+		subtle.ConstantTimeCompare([]byte(accessKey), []byte(room.Permissions.AccessKey)) == 1
+}
+
+// Suggest proposes rooms for the Hue groups and Sonos players that are not mapped to a room yet
+func (s *RoomService) Suggest() []*models.RoomSuggestion {
+	var groups []*models.HueGroup
+	if s.hueService != nil {
+		groups = append(s.hueService.GetRooms(), s.hueService.GetZones()...)
+	}
+	var players []*models.SonosDevice
+	if s.sonosService != nil {
+		players = s.sonosService.GetDevices()
+	}
+	return suggestRooms(groups, players, s.GetRooms())
+}
+
+// AllOff switches off the lights of a room and silences its players.
+// A player grouped with players in other rooms leaves the group, so the music keeps playing there.
+func (s *RoomService) AllOff(ctx context.Context, room *models.Room) error {
+	var errs []error
+
+	if s.hueService != nil {
+		off := false
+		for _, groupID := range room.HueGroups {
+			if err := s.hueService.SetGroupState(groupID, &models.HueGroupState{On: &off}); err != nil {
+				errs = append(errs, fmt.Errorf("failed to switch off Hue group %s: %w", groupID, err))
+			}
+		}
+	}
+
+	if s.sonosService != nil {
+		inRoom := make(map[string]bool, len(room.SonosPlayers))
+		for _, player := range room.SonosPlayers {
+			inRoom[player] = true
+		}
+		sharedGroups := make(map[string]bool)
+		for _, group := range s.sonosService.GetGroups() {
+			for _, member := range group.Members {
+				if !inRoom[member.Room] {
+					sharedGroups[group.ID] = true
+					break
+				}
+			}
+		}
+
+		devices := make(map[string]*models.SonosDevice)
+		for _, device := range s.sonosService.GetDevices() {
+			devices[device.Room] = device
+		}
+		for _, player := range room.SonosPlayers {
+			device, exists := devices[player]
+			if !exists {
+				continue
+			}
+			var err error
+			switch {
+			case sharedGroups[device.GroupID]:
+				err = s.sonosService.LeaveGroup(ctx, player)
+			case device.State == "PLAYING":
+				err = s.sonosService.PauseDevice(ctx, player)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to stop Sonos player %s: %w", player, err))
+			}
+		}
+	}
+
+	logrus.Infof("Rooms: switched off everything in %s", room.Name)
+	return errors.Join(errs...)
+}
+
+// GetStatus combines the last known state of the lights and players of a room
+func (s *RoomService) GetStatus(room *models.Room) *models.RoomStatus {
+	status := &models.RoomStatus{Room: room, Players: []models.RoomPlayerStatus{}}
+
+	if s.hueService != nil {
+		lights := make(map[string]*models.HueLight)
+		for _, light := range s.hueService.GetLights() {
+			lights[light.ID] = light
+		}
+
+		seen := make(map[string]bool)
+		brightness := 0
+		for _, groupID := range room.HueGroups {
+			group, exists := s.hueService.GetGroup(groupID)
+			if !exists {
+				status.MissingHue = append(status.MissingHue, groupID)
+				continue
+			}
+			for _, lightID := range group.Lights {
+				light, exists := lights[lightID]
+				if !exists || seen[lightID] {
+					continue
+				}
+				seen[lightID] = true
+				status.Lights.Total++
+				if light.IsReachable {
+					status.Lights.Reachable++
+				}
+				if light.IsOn {
+					status.Lights.On++
+					brightness += light.Brightness
+				}
+			}
+		}
+		status.Lights.AnyOn = status.Lights.On > 0
+		if status.Lights.On > 0 {
+			status.Lights.Brightness = brightness / status.Lights.On
+		}
+	}
+
+	if s.sonosService != nil {
+		devices := make(map[string]*models.SonosDevice)
+		for _, device := range s.sonosService.GetDevices() {
+			devices[device.Room] = device
+		}
+		for _, player := range room.SonosPlayers {
+			device, exists := devices[player]
+			if !exists {
+				status.MissingSonos = append(status.MissingSonos, player)
+				continue
+			}
+			status.Players = append(status.Players, models.RoomPlayerStatus{
+				Name:   device.Room,
+				State:  device.State,
+				Volume: device.Volume,
+				Mute:   device.Mute,
+				Track:  device.CurrentTrack,
+				Online: device.IsOnline,
+			})
+			if device.State == "PLAYING" && !status.IsPlaying {
+				status.IsPlaying = true
+				status.NowPlaying = device.CurrentTrack
+			}
+		}
+	}
+
+	return status
+}
+
+// validate cleans up a room before it is saved
+func (s *RoomService) validate(room *models.Room) error {
+	room.Name = strings.TrimSpace(room.Name)
+	if room.Name == "" {
+		return fmt.Errorf("room name is required")
+	}
+	room.HueGroups = uniqueStrings(room.HueGroups)
+	room.SonosPlayers = uniqueStrings(room.SonosPlayers)
+	room.CalendarIDs = uniqueStrings(room.CalendarIDs)
+	if !room.Permissions.Restricted {
+		room.Permissions.AccessKey = ""
+	}
+	return nil
+}
+
+// load reads the saved rooms
+func (s *RoomService) load() error {
+	if s.db == nil {
+		return nil
+	}
+
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS rooms (
+			id TEXT PRIMARY KEY,
+			data TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.db.Query(`SELECT id, data FROM rooms`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for rows.Next() {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			return err
+		}
+		var room models.Room
+		if err := json.Unmarshal([]byte(data), &room); err != nil {
+			logrus.Warnf("Rooms: ignoring unreadable room %s: %v", id, err)
+			continue
+		}
+		room.ID = id
+		s.rooms[id] = &room
+	}
+	logrus.Infof("Rooms: loaded %d rooms", len(s.rooms))
+	return rows.Err()
+}
+
+// save stores a room
+func (s *RoomService) save(room models.Room) error {
+	if s.db == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(room)
+	if err != nil {
+		return fmt.Errorf("failed to marshal room: %w", err)
+	}
+	_, err = s.db.Exec(`
+		INSERT OR REPLACE INTO rooms (id, data, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+	`, room.ID, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save room: %w", err)
+	}
+	return nil
+}
+
+// suggestRooms groups unmapped Hue groups and Sonos players by name.
+// Names match when they only differ in case, spacing and punctuation, so "Living room" matches "LivingRoom".
+func suggestRooms(groups []*models.HueGroup, players []*models.SonosDevice, rooms []*models.Room) []*models.RoomSuggestion {
+	mappedHue := make(map[string]bool)
+	mappedSonos := make(map[string]bool)
+	roomsByKey := make(map[string]*models.Room)
+	for _, room := range rooms {
+		roomsByKey[roomKey(room.Name)] = room
+		for _, groupID := range room.HueGroups {
+			mappedHue[groupID] = true
+		}
+		for _, player := range room.SonosPlayers {
+			mappedSonos[player] = true
+		}
+	}
+
+	suggestions := make(map[string]*models.RoomSuggestion)
+	suggestion := func(name string) *models.RoomSuggestion {
+		key := roomKey(name)
+		if existing, exists := suggestions[key]; exists {
+			return existing
+		}
+		created := &models.RoomSuggestion{Name: name, HueGroups: []string{}, SonosPlayers: []string{}}
+		if room, exists := roomsByKey[key]; exists {
+			created.Name = room.Name
+			created.RoomID = room.ID
+		}
+		suggestions[key] = created
+		return created
+	}
+
+	for _, group := range groups {
+		if !mappedHue[group.ID] && roomKey(group.Name) != "" {
+			entry := suggestion(group.Name)
+			entry.HueGroups = append(entry.HueGroups, group.ID)
+		}
+	}
+	for _, player := range players {
+		if !mappedSonos[player.Room] && roomKey(player.Room) != "" {
+			entry := suggestion(player.Room)
+			entry.SonosPlayers = append(entry.SonosPlayers, player.Room)
+		}
+	}
+
+	result := make([]*models.RoomSuggestion, 0, len(suggestions))
+	for _, entry := range suggestions {
+		sort.Strings(entry.HueGroups)
+		sort.Strings(entry.SonosPlayers)
+		result = append(result, entry)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// roomKey returns the letters and digits of a name in lower case, for matching names
+func roomKey(name string) string {
+	return strings.ReplaceAll(roomSlug(name), "-", "")
+}
+
+// roomSlug turns a room name into an ID such as living-room
+func roomSlug(name string) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+			slug.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return slug.String()
+}
+
+// uniqueStrings returns the non-empty values of a list without duplicates, never nil
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value != "" && !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
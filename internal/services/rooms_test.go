@@ -0,0 +1,78 @@
+package services
+
+import (
+	"testing"
+
+	"woodhome-webapp/internal/models"
+)
+
+func TestSuggestRoomsMatchesNames(t *testing.T) {
+	groups := []*models.HueGroup{
+		{ID: "1", Name: "Living room", Type: models.HueGroupTypeRoom},
+		{ID: "garage:1", Name: "Garage", Type: models.HueGroupTypeRoom},
+		{ID: "2", Name: "Kitchen", Type: models.HueGroupTypeRoom},
+	}
+	players := []*models.SonosDevice{
+		{Room: "LivingRoom"},
+		{Room: "Kitchen"},
+		{Room: "Office"},
+	}
+	rooms := []*models.Room{
+		{ID: "kitchen", Name: "Kitchen", HueGroups: []string{"2"}},
+	}
+
+	suggestions := suggestRooms(groups, players, rooms)
+	byName := make(map[string]*models.RoomSuggestion)
+	for _, suggestion := range suggestions {
+		byName[suggestion.Name] = suggestion
+	}
+
+	if len(suggestions) != 4 {
+		t.Fatalf("Expected 4 suggestions, got %d: %+v", len(suggestions), suggestions)
+	}
+	living := byName["Living room"]
+	if living == nil || len(living.HueGroups) != 1 || len(living.SonosPlayers) != 1 || living.SonosPlayers[0] != "LivingRoom" {
+		t.Fatalf("Expected the living room light group and player together, got %+v", living)
+	}
+	kitchen := byName["Kitchen"]
+	if kitchen == nil || kitchen.RoomID != "kitchen" || len(kitchen.HueGroups) != 0 || len(kitchen.SonosPlayers) != 1 {
+		t.Fatalf("Expected only the unmapped kitchen player for the existing room, got %+v", kitchen)
+	}
+	if office := byName["Office"]; office == nil || len(office.HueGroups) != 0 {
+		t.Fatalf("Expected a player-only suggestion for the office, got %+v", office)
+	}
+}
+
+func TestRoomPermissions(t *testing.T) {
+	service := NewRoomService(nil, nil, nil)
+
+	room, err := service.CreateRoom(models.Room{Name: "Kids' Bedroom", Permissions: models.RoomPermissions{Restricted: true}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if room.ID != "kids-bedroom" || room.Permissions.AccessKey == "" {
+		t.Fatalf("Expected a slug ID and a generated access key, got %+v", room)
+	}
+	if _, exists := service.GetRoom("kids bedroom"); !exists {
+		t.Fatal("Expected the room to be found by name")
+	}
+	if _, err := service.CreateRoom(models.Room{Name: "Kids bedroom"}); err == nil {
+		t.Fatal("Expected a duplicate room name to be refused")
+	}
+
+	if service.CanControl(room, false, "") || service.CanControl(room, false, "wrong") {
+		t.Fatal("Expected a restricted room to refuse guests without its key")
+	}
+	if !service.CanControl(room, false, room.Permissions.AccessKey) || !service.CanControl(room, true, "") {
+		t.Fatal("Expected the access key and a household sign-in to be accepted")
+	}
+
+	// The key is kept when the room is updated without one
+	updated, err := service.UpdateRoom(room.ID, models.Room{Name: "Kids' Bedroom", Permissions: models.RoomPermissions{Restricted: true}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if updated.Permissions.AccessKey != room.Permissions.AccessKey {
+		t.Fatal("Expected the access key to survive an update")
+	}
+}
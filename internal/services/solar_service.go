@@ -0,0 +1,469 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"woodhome-webapp/internal/models"
+	"woodhome-webapp/internal/solar"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// solarTriggerGrace is how late a trigger may still fire, so a server that was down
+// at sunset does not switch the porch lights on at midnight
+const solarTriggerGrace = 15 * time.Minute
+
+// solarMaxDays caps the days returned at once
+const solarMaxDays = 31
+
+// SolarRoutineRunner runs the home routine of a trigger
+type SolarRoutineRunner interface {
+	Run(id string) (*models.HomeRoutineRun, error)
+}
+
+// solarTriggerState tracks the schedule of a trigger
+type solarTriggerState struct {
+	trigger   models.SolarTrigger
+	offset    solar.Offset
+	next      time.Time // Zero when the sun event does not happen within a year
+	lastFired *time.Time
+	lastError string
+}
+
+// SolarService computes sunrise, sunset and twilight at the home and fires triggers relative to them
+type SolarService struct {
+	config        *models.SolarConfig
+	db            *sql.DB
+	eventBus      *EventBus
+	routineRunner SolarRoutineRunner
+	triggers      map[string]*solarTriggerState
+	now           func() time.Time
+	mu            sync.Mutex
+}
+
+// NewSolarService creates a new SolarService instance.
+// Triggers are kept in memory only when db is nil.
+func NewSolarService(db *sql.DB, config *models.SolarConfig) *SolarService {
+	if config == nil {
+		config = &models.SolarConfig{}
+	}
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = 30 * time.Second
+	}
+
+	return &SolarService{
+		config:   config,
+		db:       db,
+		triggers: make(map[string]*solarTriggerState),
+		now:      time.Now,
+	}
+}
+
+// SetEventBus sets the bus that trigger firings are published on
+func (s *SolarService) SetEventBus(eventBus *EventBus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.eventBus = eventBus
+}
+
+// SetRoutineRunner sets the service that runs the home routines of triggers
+func (s *SolarService) SetRoutineRunner(runner SolarRoutineRunner) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routineRunner = runner
+}
+
+// Start loads the saved triggers and begins checking them
+func (s *SolarService) Start(ctx context.Context) error {
+	if s.config.Latitude == 0 && s.config.Longitude == 0 {
+		logrus.Warn("Sun times: HOME_LATITUDE and HOME_LONGITUDE are not set, sun times will be wrong")
+	}
+
+	logrus.Info("Starting sun time service...")
+	if err := s.load(); err != nil {
+		return fmt.Errorf("failed to load sun triggers: %w", err)
+	}
+
+	go s.startLoop(ctx)
+	return nil
+}
+
+// startLoop checks the triggers until the context is cancelled
+func (s *SolarService) startLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.tick()
+		}
+	}
+}
+
+// GetStatus returns today's and tomorrow's sun times, the next sun event and the triggers
+func (s *SolarService) GetStatus() *models.SolarStatus {
+	now := s.now().In(s.config.Location)
+	today := solar.TimesFor(now, s.config.Latitude, s.config.Longitude)
+	tomorrowDate := time.Date(now.Year(), now.Month(), now.Day()+1, 12, 0, 0, 0, s.config.Location)
+	tomorrow := solar.TimesFor(tomorrowDate, s.config.Latitude, s.config.Longitude)
+
+	status := &models.SolarStatus{
+		Latitude:   s.config.Latitude,
+		Longitude:  s.config.Longitude,
+		TimeZone:   s.config.Location.String(),
+		Now:        now,
+		IsDaylight: today.MidnightSun || (!today.Sunrise.IsZero() && !now.Before(today.Sunrise) && now.Before(today.Sunset)),
+		Today:      solarDay(now, today),
+		Tomorrow:   solarDay(tomorrowDate, tomorrow),
+		Triggers:   s.GetTriggers(),
+	}
+
+	for _, times := range []solar.Times{today, tomorrow} {
+		for _, event := range solar.Events {
+			if at := times.At(event); !at.IsZero() && at.After(now) {
+				status.NextEvent = string(event)
+				status.NextEventAt = &at
+				return status
+			}
+		}
+	}
+	return status
+}
+
+// GetDays returns the sun times for count days starting at from (YYYY-MM-DD, today when empty)
+func (s *SolarService) GetDays(from string, count int) ([]models.SolarDay, error) {
+	if count <= 0 || count > solarMaxDays {
+		return nil, fmt.Errorf("days must be between 1 and %d", solarMaxDays)
+	}
+
+	start := s.now().In(s.config.Location)
+	if from != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", from, s.config.Location)
+		if err != nil {
+			return nil, fmt.Errorf("date must be YYYY-MM-DD")
+		}
+		start = parsed
+	}
+
+	days := make([]models.SolarDay, 0, count)
+	for i := 0; i < count; i++ {
+		date := time.Date(start.Year(), start.Month(), start.Day()+i, 12, 0, 0, 0, s.config.Location)
+		days = append(days, solarDay(date, solar.TimesFor(date, s.config.Latitude, s.config.Longitude)))
+	}
+	return days, nil
+}
+
+// GetTriggers returns every trigger ordered by its next firing
+func (s *SolarService) GetTriggers() []*models.SolarTriggerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	triggers := make([]*models.SolarTriggerStatus, 0, len(s.triggers))
+	for _, state := range s.triggers {
+		triggers = append(triggers, s.status(state))
+	}
+	sort.Slice(triggers, func(i, j int) bool {
+		if triggers[i].NextFire == nil || triggers[j].NextFire == nil {
+			return triggers[j].NextFire == nil && triggers[i].NextFire != nil
+		}
+		return triggers[i].NextFire.Before(*triggers[j].NextFire)
+	})
+	return triggers
+}
+
+// GetTrigger returns a single trigger
+func (s *SolarService) GetTrigger(id string) (*models.SolarTriggerStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, exists := s.triggers[id]
+	if !exists {
+		return nil, false
+	}
+	return s.status(state), true
+}
+
+// CreateTrigger validates and saves a new trigger
+func (s *SolarService) CreateTrigger(trigger models.SolarTrigger) (*models.SolarTriggerStatus, error) {
+	offset, err := validateSolarTrigger(&trigger)
+	if err != nil {
+		return nil, err
+	}
+	trigger.ID = uuid.NewString()
+	trigger.UpdatedAt = s.now()
+
+	if err := s.save(trigger); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := &solarTriggerState{trigger: trigger, offset: offset}
+	s.schedule(state, s.now())
+	s.triggers[trigger.ID] = state
+
+	logrus.Infof("Sun times: created trigger %q at %s", trigger.Name, trigger.At)
+	return s.status(state), nil
+}
+
+// UpdateTrigger replaces a trigger and schedules it again
+func (s *SolarService) UpdateTrigger(id string, trigger models.SolarTrigger) (*models.SolarTriggerStatus, error) {
+	if _, exists := s.GetTrigger(id); !exists {
+		return nil, fmt.Errorf("trigger %s not found", id)
+	}
+	offset, err := validateSolarTrigger(&trigger)
+	if err != nil {
+		return nil, err
+	}
+	trigger.ID = id
+	trigger.UpdatedAt = s.now()
+
+	if err := s.save(trigger); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, exists := s.triggers[id]
+	if !exists {
+		state = &solarTriggerState{}
+		s.triggers[id] = state
+	}
+	state.trigger = trigger
+	state.offset = offset
+	s.schedule(state, s.now())
+	return s.status(state), nil
+}
+
+// DeleteTrigger removes a trigger
+func (s *SolarService) DeleteTrigger(id string) error {
+	s.mu.Lock()
+	if _, exists := s.triggers[id]; !exists {
+		s.mu.Unlock()
+		return fmt.Errorf("trigger %s not found", id)
+	}
+	delete(s.triggers, id)
+	s.mu.Unlock()
+
+	if s.db != nil {
+		if _, err := s.db.Exec(`DELETE FROM solar_triggers WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete trigger: %w", err)
+		}
+	}
+	return nil
+}
+
+// tick fires every trigger whose time has come and schedules its next firing
+func (s *SolarService) tick() {
+	now := s.now()
+
+	s.mu.Lock()
+	var due []*solarTriggerState
+	for _, state := range s.triggers {
+		if !state.trigger.Enabled || state.next.IsZero() || now.Before(state.next) {
+			continue
+		}
+		if late := now.Sub(state.next); late > solarTriggerGrace {
+			logrus.Warnf("Sun times: skipping trigger %q, missed by %s", state.trigger.Name, late.Round(time.Minute))
+		} else {
+			due = append(due, state)
+		}
+		s.schedule(state, now)
+	}
+	runner := s.routineRunner
+	s.mu.Unlock()
+
+	for _, state := range due {
+		s.fire(state, now, runner)
+	}
+}
+
+// fire publishes a trigger and runs its routine
+func (s *SolarService) fire(state *solarTriggerState, now time.Time, runner SolarRoutineRunner) {
+	s.mu.Lock()
+	trigger := state.trigger
+	state.lastFired = &now
+	state.lastError = ""
+	if s.eventBus != nil {
+		s.eventBus.Publish(models.EventSolar, trigger.ID, map[string]interface{}{
+			"trigger": trigger.Name,
+			"at":      trigger.At,
+			"event":   string(state.offset.Event),
+		})
+	}
+	s.mu.Unlock()
+
+	logrus.Infof("Sun times: firing %q (%s)", trigger.Name, trigger.At)
+	if trigger.RoutineID == "" {
+		return
+	}
+
+	var err error
+	if runner == nil {
+		err = fmt.Errorf("home routines are not available")
+	} else {
+		_, err = runner.Run(trigger.RoutineID)
+	}
+	if err != nil {
+		logrus.Warnf("Sun times: trigger %q could not run its routine: %v", trigger.Name, err)
+		s.mu.Lock()
+		state.lastError = err.Error()
+		s.mu.Unlock()
+	}
+}
+
+// schedule sets the next firing of a trigger after now. Callers must hold the lock.
+func (s *SolarService) schedule(state *solarTriggerState, now time.Time) {
+	next, ok := solar.Next(now.In(s.config.Location), s.config.Latitude, s.config.Longitude, state.offset)
+	if !ok {
+		state.next = time.Time{}
+		return
+	}
+	state.next = next
+}
+
+// status returns the API view of a trigger. Callers must hold the lock.
+func (s *SolarService) status(state *solarTriggerState) *models.SolarTriggerStatus {
+	status := &models.SolarTriggerStatus{
+		SolarTrigger: state.trigger,
+		LastFired:    state.lastFired,
+		LastError:    state.lastError,
+	}
+	if state.trigger.Enabled && !state.next.IsZero() {
+		next := state.next
+		status.NextFire = &next
+	}
+	return status
+}
+
+// load reads the saved triggers and schedules them
+func (s *SolarService) load() error {
+	if s.db == nil {
+		return nil
+	}
+
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS solar_triggers (
+			id TEXT PRIMARY KEY,
+			data TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.db.Query(`SELECT id, data FROM solar_triggers`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for rows.Next() {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			return err
+		}
+		var trigger models.SolarTrigger
+		if err := json.Unmarshal([]byte(data), &trigger); err != nil {
+			logrus.Warnf("Sun times: ignoring unreadable trigger %s: %v", id, err)
+			continue
+		}
+		offset, err := solar.ParseOffset(trigger.At)
+		if err != nil {
+			logrus.Warnf("Sun times: ignoring trigger %s: %v", id, err)
+			continue
+		}
+		trigger.ID = id
+		state := &solarTriggerState{trigger: trigger, offset: offset}
+		s.schedule(state, now)
+		s.triggers[id] = state
+	}
+	logrus.Infof("Sun times: loaded %d triggers", len(s.triggers))
+	return rows.Err()
+}
+
+// save stores a trigger
+func (s *SolarService) save(trigger models.SolarTrigger) error {
+	if s.db == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(trigger)
+	if err != nil {
+		return fmt.Errorf("failed to marshal trigger: %w", err)
+	}
+	_, err = s.db.Exec(`
+		INSERT OR REPLACE INTO solar_triggers (id, data, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+	`, trigger.ID, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save trigger: %w", err)
+	}
+	return nil
+}
+
+// validateSolarTrigger checks a trigger and writes its time in the canonical form
+func validateSolarTrigger(trigger *models.SolarTrigger) (solar.Offset, error) {
+	if trigger.Name == "" {
+		return solar.Offset{}, fmt.Errorf("trigger name is required")
+	}
+	offset, err := solar.ParseOffset(trigger.At)
+	if err != nil {
+		return solar.Offset{}, err
+	}
+	trigger.At = offset.String()
+	return offset, nil
+}
+
+// solarDay converts the sun times of a date for the API
+func solarDay(date time.Time, times solar.Times) models.SolarDay {
+	optional := func(t time.Time) *time.Time {
+		if t.IsZero() {
+			return nil
+		}
+		return &t
+	}
+
+	day := models.SolarDay{
+		Date:         date.Format("2006-01-02"),
+		NauticalDawn: optional(times.NauticalDawn),
+		CivilDawn:    optional(times.CivilDawn),
+		Sunrise:      optional(times.Sunrise),
+		SolarNoon:    times.SolarNoon,
+		Sunset:       optional(times.Sunset),
+		CivilDusk:    optional(times.CivilDusk),
+		NauticalDusk: optional(times.NauticalDusk),
+		PolarNight:   times.PolarNight,
+		MidnightSun:  times.MidnightSun,
+	}
+
+	var length time.Duration
+	switch {
+	case times.MidnightSun:
+		length = 24 * time.Hour
+	case !times.PolarNight:
+		length = times.Sunset.Sub(times.Sunrise).Round(time.Minute)
+	}
+	day.DayLength = fmt.Sprintf("%dh%02dm", int(length.Hours()), int(length.Minutes())%60)
+	return day
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"woodhome-webapp/internal/models"
+)
+
+type recordingRoutineRunner struct {
+	mu  sync.Mutex
+	ran []string
+}
+
+func (r *recordingRoutineRunner) Run(id string) (*models.HomeRoutineRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ran = append(r.ran, id)
+	return &models.HomeRoutineRun{RoutineID: id}, nil
+}
+
+func TestSolarTriggerFiresAtOffset(t *testing.T) {
+	// Minneapolis in daylight saving time, where sunset on 2024-06-20 is at 21:03
+	cdt := time.FixedZone("CDT", -5*60*60)
+	now := time.Date(2024, 6, 20, 12, 0, 0, 0, cdt)
+
+	service := NewSolarService(nil, &models.SolarConfig{Latitude: 44.98, Longitude: -93.27, Location: cdt})
+	service.now = func() time.Time { return now }
+	eventBus := NewEventBus()
+	events, unsubscribe := eventBus.Subscribe()
+	defer unsubscribe()
+	service.SetEventBus(eventBus)
+	runner := &recordingRoutineRunner{}
+	service.SetRoutineRunner(runner)
+
+	trigger, err := service.CreateTrigger(models.SolarTrigger{Name: "Porch lights", At: "Sunset-20m", RoutineID: "porch", Enabled: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if trigger.At != "sunset - 20m" || trigger.NextFire == nil {
+		t.Fatalf("Expected a scheduled trigger, got %+v", trigger)
+	}
+	if diff := trigger.NextFire.Sub(time.Date(2024, 6, 20, 20, 43, 0, 0, cdt)); diff < -2*time.Minute || diff > 2*time.Minute {
+		t.Fatalf("Expected the trigger 20 minutes before sunset, got %s", trigger.NextFire)
+	}
+
+	status := service.GetStatus()
+	if !status.IsDaylight || status.NextEvent != "solar_noon" || status.Today.Sunrise == nil || status.Today.CivilDusk == nil {
+		t.Fatalf("Unexpected midday status: %+v", status)
+	}
+
+	service.tick()
+	if len(runner.ran) != 0 {
+		t.Fatal("Expected no firing before the trigger time")
+	}
+
+	firstFire := *trigger.NextFire
+	now = firstFire.Add(time.Minute)
+	service.tick()
+	if len(runner.ran) != 1 || runner.ran[0] != "porch" {
+		t.Fatalf("Expected the porch routine to run once, got %v", runner.ran)
+	}
+	select {
+	case event := <-events:
+		if event.Type != models.EventSolar || event.Source != trigger.ID {
+			t.Fatalf("Unexpected event: %+v", event)
+		}
+	default:
+		t.Fatal("Expected the firing to be published")
+	}
+
+	fired, _ := service.GetTrigger(trigger.ID)
+	if fired.LastFired == nil || fired.NextFire == nil || fired.NextFire.Day() != 21 {
+		t.Fatalf("Expected the trigger to be scheduled for tomorrow, got %+v", fired)
+	}
+
+	// A firing missed by hours, such as while the server was down, is skipped
+	now = fired.NextFire.Add(3 * time.Hour)
+	service.tick()
+	if len(runner.ran) != 1 {
+		t.Fatalf("Expected the missed firing to be skipped, got %v", runner.ran)
+	}
+	if skipped, _ := service.GetTrigger(trigger.ID); skipped.NextFire.Day() != 22 {
+		t.Fatalf("Expected the trigger to move to the next day, got %s", skipped.NextFire)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
 	"strings"
 	"sync"
 	"time"
@@ -456,32 +457,7 @@ func (s *SonosService) createDeviceFromZoneData(data map[string]interface{}, gro
 
 		// Extract current track information
 		if currentTrack, ok := state["currentTrack"].(map[string]interface{}); ok {
-			device.CurrentTrack = &models.TrackInfo{}
-			if artist, ok := currentTrack["artist"].(string); ok {
-				device.CurrentTrack.Artist = artist
-			}
-			if title, ok := currentTrack["title"].(string); ok {
-				device.CurrentTrack.Title = title
-			}
-			if album, ok := currentTrack["album"].(string); ok {
-				device.CurrentTrack.Album = album
-			}
-			// Try different album art field names
-			if art, ok := currentTrack["albumArtURI"].(string); ok {
-				device.CurrentTrack.Art = art
-			} else if art, ok := currentTrack["albumArtUri"].(string); ok {
-				device.CurrentTrack.Art = art
-			} else if art, ok := currentTrack["absoluteAlbumArtUri"].(string); ok {
-				device.CurrentTrack.Art = art
-			}
-			// Extract URI for SPDIF/TV detection
-			if uri, ok := currentTrack["uri"].(string); ok {
-				device.CurrentTrack.URI = uri
-			}
-			// Extract track type for SPDIF/TV detection
-			if trackType, ok := currentTrack["type"].(string); ok {
-				device.CurrentTrack.Type = trackType
-			}
+			device.CurrentTrack = parseTrackInfo(currentTrack)
 
 			// Debug logging for track information
 			logrus.Debugf("Device %s current track: Artist=%s, Title=%s, Album=%s, Art=%s, URI=%s, Type=%s",
@@ -496,6 +472,37 @@ func (s *SonosService) createDeviceFromZoneData(data map[string]interface{}, gro
 	return device
 }
 
+// parseTrackInfo builds a TrackInfo from a Jishi currentTrack object
+func parseTrackInfo(currentTrack map[string]interface{}) *models.TrackInfo {
+	track := &models.TrackInfo{}
+	if artist, ok := currentTrack["artist"].(string); ok {
+		track.Artist = artist
+	}
+	if title, ok := currentTrack["title"].(string); ok {
+		track.Title = title
+	}
+	if album, ok := currentTrack["album"].(string); ok {
+		track.Album = album
+	}
+	// Try different album art field names
+	if art, ok := currentTrack["albumArtURI"].(string); ok {
+		track.Art = art
+	} else if art, ok := currentTrack["albumArtUri"].(string); ok {
+		track.Art = art
+	} else if art, ok := currentTrack["absoluteAlbumArtUri"].(string); ok {
+		track.Art = art
+	}
+	// Extract URI for SPDIF/TV detection
+	if uri, ok := currentTrack["uri"].(string); ok {
+		track.URI = uri
+	}
+	// Extract track type for SPDIF/TV detection
+	if trackType, ok := currentTrack["type"].(string); ok {
+		track.Type = trackType
+	}
+	return track
+}
+
 // cleanupZombieGroups removes groups that appear to be dissolved or invalid
 func (s *SonosService) cleanupZombieGroups() {
 	groupsToRemove := make([]string, 0)
@@ -647,6 +654,51 @@ func (s *SonosService) SetMute(ctx context.Context, deviceName string, mute bool
 	return s.executeJishiCommand(ctx, url, action, deviceName)
 }
 
+// SetLineIn switches a device to line-in via Jishi API. On a soundbar this is the TV input.
+// source names another device whose line-in to play; empty plays the device's own input.
+func (s *SonosService) SetLineIn(ctx context.Context, deviceName, source string) error {
+	url := fmt.Sprintf("%s/%s/linein", s.jishiURL, deviceName)
+	if source != "" {
+		url += "/" + neturl.PathEscape(source)
+	}
+	return s.executeJishiCommand(ctx, url, "linein", deviceName)
+}
+
+// SetNightMode turns night mode of a soundbar on or off via Jishi API
+func (s *SonosService) SetNightMode(ctx context.Context, deviceName string, on bool) error {
+	state := "off"
+	if on {
+		state = "on"
+	}
+	url := fmt.Sprintf("%s/%s/nightmode/%s", s.jishiURL, deviceName, state)
+	return s.executeJishiCommand(ctx, url, "nightmode", deviceName)
+}
+
+// PlayFavorite starts a Sonos favorite on a device via Jishi API
+func (s *SonosService) PlayFavorite(ctx context.Context, deviceName, favorite string) error {
+	url := fmt.Sprintf("%s/%s/favorite/%s", s.jishiURL, deviceName, neturl.PathEscape(favorite))
+	return s.executeJishiCommand(ctx, url, "favorite", deviceName)
+}
+
+// Say announces text on a device via Jishi API, at volume when it is above 0. The device
+// resumes what it was playing afterwards.
+func (s *SonosService) Say(ctx context.Context, deviceName, text string, volume int) error {
+	url := fmt.Sprintf("%s/%s/say/%s", s.jishiURL, deviceName, neturl.PathEscape(text))
+	if volume > 0 {
+		url += fmt.Sprintf("/%d", volume)
+	}
+	return s.executeJishiCommand(ctx, url, "say", deviceName)
+}
+
+// SayAll announces text on every device via Jishi API, at volume when it is above 0
+func (s *SonosService) SayAll(ctx context.Context, text string, volume int) error {
+	url := fmt.Sprintf("%s/sayall/%s", s.jishiURL, neturl.PathEscape(text))
+	if volume > 0 {
+		url += fmt.Sprintf("/%d", volume)
+	}
+	return s.executeJishiCommand(ctx, url, "sayall", "all devices")
+}
+
 // Group Management Methods
 
 // CreateGroup creates a new group via Jishi API
@@ -805,6 +857,28 @@ func (s *SonosService) getDeviceState(ctx context.Context, deviceName string) (m
 	return state, nil
 }
 
+// GetNowPlaying returns the current track and playback state of a room via Jishi API.
+// Unlike the zone data, the returned Art is always an absolute URL when one is known.
+func (s *SonosService) GetNowPlaying(ctx context.Context, roomName string) (*models.TrackInfo, string, error) {
+	state, err := s.getDeviceState(ctx, roomName)
+	if err != nil {
+		return nil, "", err
+	}
+
+	playbackState, _ := state["playbackState"].(string)
+
+	currentTrack, ok := state["currentTrack"].(map[string]interface{})
+	if !ok {
+		return nil, playbackState, nil
+	}
+
+	track := parseTrackInfo(currentTrack)
+	if art, ok := currentTrack["absoluteAlbumArtUri"].(string); ok && art != "" {
+		track.Art = art
+	}
+	return track, playbackState, nil
+}
+
 // executeJishiCommand executes a command via Jishi API
 func (s *SonosService) executeJishiCommand(ctx context.Context, url, action, deviceName string) error {
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
@@ -0,0 +1,146 @@
+package solar
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Event names a sun time of a day
+type Event string
+
+// Sun events
+const (
+	NauticalDawn Event = "nautical_dawn"
+	CivilDawn    Event = "civil_dawn"
+	Sunrise      Event = "sunrise"
+	SolarNoon    Event = "solar_noon"
+	Sunset       Event = "sunset"
+	CivilDusk    Event = "civil_dusk"
+	NauticalDusk Event = "nautical_dusk"
+)
+
+// Events lists the sun events in the order they happen
+var Events = []Event{NauticalDawn, CivilDawn, Sunrise, SolarNoon, Sunset, CivilDusk, NauticalDusk}
+
+// eventAliases are the shorter names accepted by ParseOffset
+var eventAliases = map[string]Event{
+	"dawn": CivilDawn,
+	"dusk": CivilDusk,
+	"noon": SolarNoon,
+}
+
+// searchDays bounds the search for the next event, which may not happen for months near the poles
+const searchDays = 370
+
+// At returns the time of an event, or the zero time when it does not happen that day
+func (t Times) At(event Event) time.Time {
+	switch event {
+	case NauticalDawn:
+		return t.NauticalDawn
+	case CivilDawn:
+		return t.CivilDawn
+	case Sunrise:
+		return t.Sunrise
+	case SolarNoon:
+		return t.SolarNoon
+	case Sunset:
+		return t.Sunset
+	case CivilDusk:
+		return t.CivilDusk
+	case NauticalDusk:
+		return t.NauticalDusk
+	}
+	return time.Time{}
+}
+
+// Offset is a time relative to a sun event, such as "sunset - 20m"
+type Offset struct {
+	Event  Event         `json:"event"`
+	Offset time.Duration `json:"offset"`
+}
+
+// ParseOffset reads an event name optionally followed by a signed Go duration,
+// such as "sunrise", "sunset - 20m", "dusk+1h30m" or "civil_dawn -45m"
+func ParseOffset(value string) (Offset, error) {
+	compact := strings.ToLower(strings.Join(strings.Fields(value), ""))
+	if compact == "" {
+		return Offset{}, fmt.Errorf("sun time is required")
+	}
+
+	name, duration := compact, ""
+	if i := strings.IndexAny(compact, "+-"); i >= 0 {
+		name, duration = compact[:i], compact[i:]
+	}
+
+	event := Event(name)
+	if alias, ok := eventAliases[name]; ok {
+		event = alias
+	}
+	known := false
+	for _, e := range Events {
+		known = known || e == event
+	}
+	if !known {
+		return Offset{}, fmt.Errorf("unknown sun event %q", name)
+	}
+
+	offset := Offset{Event: event}
+	if duration != "" {
+		d, err := time.ParseDuration(duration)
+		if err != nil {
+			return Offset{}, fmt.Errorf("invalid offset %q: %w", duration, err)
+		}
+		if d <= -24*time.Hour || d >= 24*time.Hour {
+			return Offset{}, fmt.Errorf("offset must be less than a day")
+		}
+		offset.Offset = d
+	}
+	return offset, nil
+}
+
+// String formats the offset the way ParseOffset reads it
+func (o Offset) String() string {
+	switch {
+	case o.Offset > 0:
+		return fmt.Sprintf("%s + %s", o.Event, formatDuration(o.Offset))
+	case o.Offset < 0:
+		return fmt.Sprintf("%s - %s", o.Event, formatDuration(-o.Offset))
+	}
+	return string(o.Event)
+}
+
+// On returns the time of the offset on the day of times, or false when the event does not happen that day
+func (o Offset) On(times Times) (time.Time, bool) {
+	at := times.At(o.Event)
+	if at.IsZero() {
+		return time.Time{}, false
+	}
+	return at.Add(o.Offset), true
+}
+
+// Next returns the first time of the offset after the given time, at the given latitude
+// and longitude. Days are taken in the location of after. It returns false when the event
+// does not happen within a year.
+func Next(after time.Time, latitude, longitude float64, offset Offset) (time.Time, bool) {
+	// Start a day early, a positive offset can move yesterday's event past after
+	for day := -1; day <= searchDays; day++ {
+		date := time.Date(after.Year(), after.Month(), after.Day()+day, 12, 0, 0, 0, after.Location())
+		if at, ok := offset.On(TimesFor(date, latitude, longitude)); ok && at.After(after) {
+			return at, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// formatDuration drops the zero units Go adds, so 20 minutes is "20m" rather than "20m0s"
+func formatDuration(d time.Duration) string {
+	formatted := d.String()
+	if strings.HasSuffix(formatted, "m0s") {
+		formatted = strings.TrimSuffix(formatted, "0s")
+	}
+	if strings.HasSuffix(formatted, "h0m") {
+		formatted = strings.TrimSuffix(formatted, "0m")
+	}
+	return formatted
+}
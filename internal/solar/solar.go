@@ -0,0 +1,121 @@
+// Package solar computes sun positions and sunrise/sunset times for a location,
+// using the NOAA sunrise equation (accurate to about a minute outside the polar regions).
+package solar
+
+import (
+	"math"
+	"time"
+)
+
+// Sun altitudes in degrees. Sunrise allows for refraction and the solar disc.
+const (
+	sunriseAltitude  = -0.833
+	civilAltitude    = -6.0
+	nauticalAltitude = -12.0
+)
+
+// julianUnixEpoch is the Julian date of the Unix epoch
+const julianUnixEpoch = 2440587.5
+
+// julian2000 is the Julian date of 2000-01-01 12:00 UTC
+const julian2000 = 2451545.0
+
+// Times holds the sun times of one day. Sunrise and Sunset are zero when the sun
+// does not rise (PolarNight) or does not set (MidnightSun) that day. Dawn and dusk
+// are zero when the sun does not get that far below the horizon, as in summer nights
+// far north.
+type Times struct {
+	NauticalDawn time.Time `json:"nautical_dawn"`
+	CivilDawn    time.Time `json:"civil_dawn"`
+	Sunrise      time.Time `json:"sunrise"`
+	SolarNoon    time.Time `json:"solar_noon"`
+	Sunset       time.Time `json:"sunset"`
+	CivilDusk    time.Time `json:"civil_dusk"`
+	NauticalDusk time.Time `json:"nautical_dusk"`
+	PolarNight   bool      `json:"polar_night,omitempty"`
+	MidnightSun  bool      `json:"midnight_sun,omitempty"`
+}
+
+// day holds the intermediate values of the sunrise equation for one date and location
+type day struct {
+	transit     float64 // Julian date of solar noon
+	declination float64 // Radians
+	latitude    float64 // Radians
+}
+
+// TimesFor returns the sun times for the calendar date of date (in its own location)
+// at the given latitude and longitude in degrees (north and east positive).
+// The returned times are in the location of date.
+func TimesFor(date time.Time, latitude, longitude float64) Times {
+	d := newDay(date, latitude, longitude)
+
+	times := Times{SolarNoon: fromJulian(d.transit, date.Location())}
+	rise, set, ok := d.crossings(sunriseAltitude)
+	switch {
+	case ok:
+		times.Sunrise = fromJulian(rise, date.Location())
+		times.Sunset = fromJulian(set, date.Location())
+	case d.altitudeAtNoon() > 0:
+		times.MidnightSun = true
+	default:
+		times.PolarNight = true
+	}
+
+	if dawn, dusk, ok := d.crossings(civilAltitude); ok {
+		times.CivilDawn = fromJulian(dawn, date.Location())
+		times.CivilDusk = fromJulian(dusk, date.Location())
+	}
+	if dawn, dusk, ok := d.crossings(nauticalAltitude); ok {
+		times.NauticalDawn = fromJulian(dawn, date.Location())
+		times.NauticalDusk = fromJulian(dusk, date.Location())
+	}
+	return times
+}
+
+// newDay solves the sunrise equation up to solar noon
+func newDay(date time.Time, latitude, longitude float64) day {
+	// Julian day number of the calendar date, counted from 2000-01-01 at noon UTC
+	noon := time.Date(date.Year(), date.Month(), date.Day(), 12, 0, 0, 0, time.UTC)
+	n := math.Round(toJulian(noon) - julian2000)
+
+	meanSolarNoon := n - longitude/360
+	meanAnomaly := math.Mod(357.5291+0.98560028*meanSolarNoon, 360)
+	m := radians(meanAnomaly)
+	center := 1.9148*math.Sin(m) + 0.02*math.Sin(2*m) + 0.0003*math.Sin(3*m)
+	eclipticLongitude := radians(math.Mod(meanAnomaly+center+180+102.9372, 360))
+	transit := julian2000 + meanSolarNoon + 0.0053*math.Sin(m) - 0.0069*math.Sin(2*eclipticLongitude)
+	declination := math.Asin(math.Sin(eclipticLongitude) * math.Sin(radians(23.4397)))
+
+	return day{transit: transit, declination: declination, latitude: radians(latitude)}
+}
+
+// crossings returns the Julian dates the sun passes the given altitude in the morning and evening
+func (d day) crossings(altitude float64) (float64, float64, bool) {
+	cosHourAngle := (math.Sin(radians(altitude)) - math.Sin(d.latitude)*math.Sin(d.declination)) /
+		(math.Cos(d.latitude) * math.Cos(d.declination))
+	if cosHourAngle < -1 || cosHourAngle > 1 {
+		return 0, 0, false
+	}
+	hourAngle := degrees(math.Acos(cosHourAngle))
+	return d.transit - hourAngle/360, d.transit + hourAngle/360, true
+}
+
+// altitudeAtNoon returns the sun altitude at solar noon in degrees
+func (d day) altitudeAtNoon() float64 {
+	return 90 - degrees(math.Abs(d.latitude-d.declination))
+}
+
+// toJulian converts a time to a Julian date
+func toJulian(t time.Time) float64 {
+	return float64(t.Unix())/86400 + julianUnixEpoch
+}
+
+// fromJulian converts a Julian date to a time in the given location
+func fromJulian(julian float64, loc *time.Location) time.Time {
+	seconds := (julian - julianUnixEpoch) * 86400
+	return time.Unix(int64(math.Round(seconds)), 0).In(loc)
+}
+
+func radians(deg float64) float64 { return deg * math.Pi / 180 }
+
+func degrees(rad float64) float64 { return rad * 180 / math.Pi }
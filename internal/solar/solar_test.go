@@ -0,0 +1,119 @@
+package solar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimesFor(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+
+	// Published NOAA times for Minneapolis on 2024-06-20: sunrise 5:26, sunset 21:03 CDT
+	times := TimesFor(time.Date(2024, 6, 20, 0, 0, 0, 0, chicago), 44.98, -93.27)
+	assertNear(t, "sunrise", times.Sunrise, time.Date(2024, 6, 20, 5, 26, 0, 0, chicago))
+	assertNear(t, "sunset", times.Sunset, time.Date(2024, 6, 20, 21, 3, 0, 0, chicago))
+
+	// And on 2024-12-21: sunrise 7:49, sunset 16:33 CST
+	times = TimesFor(time.Date(2024, 12, 21, 23, 0, 0, 0, chicago), 44.98, -93.27)
+	assertNear(t, "sunrise", times.Sunrise, time.Date(2024, 12, 21, 7, 49, 0, 0, chicago))
+	assertNear(t, "sunset", times.Sunset, time.Date(2024, 12, 21, 16, 33, 0, 0, chicago))
+}
+
+func TestTimesForPolar(t *testing.T) {
+	// Tromsø has midnight sun in June and polar night in December
+	if times := TimesFor(time.Date(2024, 6, 21, 12, 0, 0, 0, time.UTC), 69.65, 18.96); !times.MidnightSun {
+		t.Fatalf("Expected midnight sun, got %+v", times)
+	}
+	if times := TimesFor(time.Date(2024, 12, 21, 12, 0, 0, 0, time.UTC), 69.65, 18.96); !times.PolarNight {
+		t.Fatalf("Expected polar night, got %+v", times)
+	}
+}
+
+func assertNear(t *testing.T, name string, got, want time.Time) {
+	t.Helper()
+	if diff := got.Sub(want); diff < -2*time.Minute || diff > 2*time.Minute {
+		t.Fatalf("Expected %s near %s, got %s", name, want.Format(time.Kitchen), got.Format(time.Kitchen))
+	}
+}
+
+func TestTwilightOrder(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+
+	times := TimesFor(time.Date(2024, 6, 20, 0, 0, 0, 0, chicago), 44.98, -93.27)
+	for i := 1; i < len(Events); i++ {
+		if !times.At(Events[i-1]).Before(times.At(Events[i])) {
+			t.Fatalf("Expected %s before %s, got %+v", Events[i-1], Events[i], times)
+		}
+	}
+	// Civil twilight lasts a little over half an hour at this latitude in June
+	if dusk := times.CivilDusk.Sub(times.Sunset); dusk < 30*time.Minute || dusk > 45*time.Minute {
+		t.Fatalf("Unexpected civil twilight of %s", dusk)
+	}
+
+	// The sun stays above -12 degrees on June nights in Tromsø
+	times = TimesFor(time.Date(2024, 6, 21, 12, 0, 0, 0, time.UTC), 69.65, 18.96)
+	if !times.NauticalDusk.IsZero() || !times.CivilDawn.IsZero() {
+		t.Fatalf("Expected no twilight during the midnight sun, got %+v", times)
+	}
+}
+
+func TestParseOffset(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Offset
+		text  string
+	}{
+		{"sunset", Offset{Event: Sunset}, "sunset"},
+		{"sunset - 20m", Offset{Event: Sunset, Offset: -20 * time.Minute}, "sunset - 20m"},
+		{"Dusk+1h30m", Offset{Event: CivilDusk, Offset: 90 * time.Minute}, "civil_dusk + 1h30m"},
+		{"nautical_dawn -2h", Offset{Event: NauticalDawn, Offset: -2 * time.Hour}, "nautical_dawn - 2h"},
+	}
+	for _, tt := range tests {
+		got, err := ParseOffset(tt.value)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", tt.value, err)
+		}
+		if got != tt.want || got.String() != tt.text {
+			t.Fatalf("Expected %q to parse as %v (%s), got %v (%s)", tt.value, tt.want, tt.text, got, got)
+		}
+	}
+
+	for _, value := range []string{"", "moonrise", "sunset - soon", "sunrise + 25h"} {
+		if _, err := ParseOffset(value); err == nil {
+			t.Fatalf("Expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+	offset := Offset{Event: Sunset, Offset: -20 * time.Minute}
+
+	// Before the porch lights go on, they go on today
+	next, ok := Next(time.Date(2024, 6, 20, 12, 0, 0, 0, chicago), 44.98, -93.27, offset)
+	if !ok {
+		t.Fatal("Expected a next sunset")
+	}
+	assertNear(t, "sunset - 20m", next, time.Date(2024, 6, 20, 20, 43, 0, 0, chicago))
+
+	// After, they go on tomorrow
+	next, _ = Next(time.Date(2024, 6, 20, 21, 0, 0, 0, chicago), 44.98, -93.27, offset)
+	if next.Day() != 21 {
+		t.Fatalf("Expected the next sunset to be tomorrow, got %s", next)
+	}
+
+	// Tromsø has no sunset until late July
+	next, ok = Next(time.Date(2024, 6, 21, 12, 0, 0, 0, time.UTC), 69.65, 18.96, Offset{Event: Sunset})
+	if !ok || next.Month() != time.July {
+		t.Fatalf("Expected the first sunset in July, got %s", next)
+	}
+}